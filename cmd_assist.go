@@ -0,0 +1,239 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/dnr/craft/pkg/craft"
+	"github.com/spf13/cobra"
+)
+
+var assistCmd = &cobra.Command{
+	Use:   "assist",
+	Short: "Add draft comments from an automated reviewer",
+	Long: `Sends the PR diff to a reviewing program or service and adds what it
+finds as new comments marked draft:
+
+  // ╓───── new ─ draft
+
+'craft send' refuses to send drafts: edit each one and remove "draft" from
+its header, or delete it.
+
+The reviewer is the craft.assistCommand git config (run with sh, reading the
+request on stdin and writing the response on stdout), or craft.assistURL (a
+URL the request is POSTed to). These are git config rather than .craft.yaml
+settings so a PR under review can't choose what runs.
+
+The request is JSON:
+
+  {"pr": 123, "diff": "<unified diff>", "files": {"path": "content", ...}}
+
+with files only with --context. The response is a JSON list of findings:
+
+  [{"path": "main.go", "line": 12, "startLine": 10, "body": "..."}, ...]
+
+where startLine is optional, and lines are of the files as in the diff.
+
+Files are changed but not committed.`,
+	RunE: runAssist,
+	Args: cobra.NoArgs,
+}
+
+var (
+	flagAssistContext bool
+	flagAssistTimeout time.Duration
+)
+
+func init() {
+	assistCmd.Flags().BoolVar(&flagAssistContext, "context", false, "Include the full content of changed files in the request")
+	assistCmd.Flags().DurationVar(&flagAssistTimeout, "timeout", 5*time.Minute, "How long to wait for the reviewer")
+	rootCmd.AddCommand(assistCmd)
+}
+
+// assistRequest is what craft assist sends to the reviewer.
+type assistRequest struct {
+	PR    int               `json:"pr"`
+	Diff  string            `json:"diff"`
+	Files map[string]string `json:"files,omitempty"`
+}
+
+// assistFinding is a comment from the reviewer.
+type assistFinding struct {
+	Path      string `json:"path"`
+	Line      int    `json:"line"`
+	StartLine int    `json:"startLine,omitempty"`
+	Body      string `json:"body"`
+}
+
+func runAssist(cmd *cobra.Command, args []string) error {
+	vcs, err := DetectVCS(".")
+	if err != nil {
+		return err
+	}
+
+	opts := craft.SerializeOptions{FS: craft.DirFS(vcs.Root()), VCS: vcs}
+	pr, err := craft.Deserialize(opts)
+	if errors.As(err, new(craft.ParseErrors)) {
+		return fmt.Errorf("%w\nfix or remove these lines so no comments are lost", err)
+	} else if err != nil {
+		return fmt.Errorf("reading PR state: %w", err)
+	}
+	base := pr.EffectiveBase()
+	if base == "" {
+		return fmt.Errorf("no base commit in PR-STATE.txt, run 'craft get' to refresh")
+	}
+	stateContent, err := craft.ReadFile(opts.FS, craft.PRStateFile)
+	if err != nil {
+		return fmt.Errorf("reading PR state: %w", err)
+	}
+	pr.Body = prStateDescription(string(stateContent))
+
+	opts, cfg, err := getSerializeOptions(vcs, 0)
+	if err != nil {
+		return err
+	}
+	opts.HideResolved = !cfg.IncludeResolved && pr.ResolvedThreadCount() == 0
+
+	// Build the request
+	diff, err := craftDiff(vcs, opts.FS, base, nil)
+	if err != nil {
+		return err
+	}
+	if diff == "" {
+		logInfo("No changes to review.")
+		return nil
+	}
+	contents := make(map[string][]string) // changed files without craft comments
+	files, err := vcs.GetChangedFiles(base)
+	if err != nil {
+		return fmt.Errorf("listing changed files: %w", err)
+	}
+	for _, path := range files {
+		if craft.IsReviewFile(path) {
+			continue
+		}
+		if content, err := craft.ReadFile(opts.FS, path); err == nil {
+			_, text := craft.DecodeFile(stripCraftContent(string(content), path))
+			contents[path] = strings.Split(text, "\n")
+		}
+	}
+	req := assistRequest{PR: pr.Number, Diff: diff}
+	if flagAssistContext {
+		req.Files = make(map[string]string)
+		for path, lines := range contents {
+			req.Files[path] = strings.Join(lines, "\n")
+		}
+	}
+
+	// Ask the reviewer
+	command := configValue(vcs, "assistCommand")
+	url := configValue(vcs, "assistURL")
+	ctx, cancel := context.WithTimeout(cmd.Context(), flagAssistTimeout)
+	defer cancel()
+	logStart("Waiting for reviewer")
+	findings, err := requestAssist(ctx, command, url, req)
+	if err != nil {
+		logEnd("failed!")
+		return err
+	}
+	logEnd("done")
+
+	added := addDraftThreads(pr, findings, contents)
+	if added == 0 {
+		logInfo("No findings.")
+		return nil
+	}
+	if err := craft.Serialize(pr, opts); err != nil {
+		return fmt.Errorf("serializing: %w", err)
+	}
+	logInfo("Added %d draft comment(s). Edit them and remove %q from their headers, or delete them, before 'craft send'.", added, craft.DraftField)
+	return nil
+}
+
+// requestAssist sends req to the reviewer, a shell command or else a URL,
+// and returns its findings.
+func requestAssist(ctx context.Context, command, url string, req assistRequest) ([]assistFinding, error) {
+	data, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp []byte
+	switch {
+	case command != "":
+		cmd := exec.CommandContext(ctx, "sh", "-c", command)
+		cmd.Stdin = bytes.NewReader(data)
+		cmd.Stderr = os.Stderr
+		if resp, err = cmd.Output(); err != nil {
+			return nil, fmt.Errorf("running craft.assistCommand: %w", err)
+		}
+	case url != "":
+		httpReq, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		httpReq.Header.Set("Content-Type", "application/json")
+		httpResp, err := http.DefaultClient.Do(httpReq)
+		if err != nil {
+			return nil, fmt.Errorf("posting to craft.assistURL: %w", err)
+		}
+		defer httpResp.Body.Close()
+		if resp, err = io.ReadAll(httpResp.Body); err != nil {
+			return nil, fmt.Errorf("reading response: %w", err)
+		}
+		if httpResp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("craft.assistURL: %s: %s", httpResp.Status, strings.TrimSpace(string(resp)))
+		}
+	default:
+		return nil, fmt.Errorf("no reviewer configured: set git config craft.assistCommand or craft.assistURL")
+	}
+
+	var findings []assistFinding
+	if err := json.Unmarshal(resp, &findings); err != nil {
+		return nil, fmt.Errorf("parsing reviewer response: %w", err)
+	}
+	return findings, nil
+}
+
+// addDraftThreads adds a new draft thread to pr for each finding on a line of
+// one of the files in contents, warning about the others. Returns how many
+// were added.
+func addDraftThreads(pr *PullRequest, findings []assistFinding, contents map[string][]string) int {
+	var added int
+	for _, f := range findings {
+		lines, ok := contents[f.Path]
+		body := strings.TrimSpace(f.Body)
+		switch {
+		case !ok:
+			logWarn("skipping finding on %s, which the PR doesn't change", f.Path)
+			continue
+		case f.Line < 1 || f.Line > len(lines) || f.StartLine > f.Line:
+			logWarn("skipping finding on %s:%d, which isn't a line of the file", f.Path, f.Line)
+			continue
+		case body == "":
+			continue
+		}
+		thread := ReviewThread{
+			Path:        f.Path,
+			Line:        f.Line,
+			DiffSide:    DiffSideRight,
+			SubjectType: SubjectTypeLine,
+			Comments:    []ReviewComment{{Body: body, IsNew: true, HeaderExtra: []string{craft.DraftField}}},
+		}
+		if f.StartLine >= 1 && f.StartLine < f.Line {
+			thread.StartLine = &f.StartLine
+		}
+		pr.ReviewThreads = append(pr.ReviewThreads, thread)
+		added++
+	}
+	return added
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+
+	"github.com/dnr/craft/pkg/craft"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestAssist(t *testing.T) {
+	req := assistRequest{PR: 7, Diff: "diff --git a/main.go b/main.go\n"}
+	want := []assistFinding{{Path: "main.go", Line: 3, Body: "Nil check?"}}
+
+	// A command gets the request on stdin
+	findings, err := requestAssist(context.Background(), `grep -q '"pr":7' && echo '[{"path":"main.go","line":3,"body":"Nil check?"}]'`, "", req)
+	require.NoError(t, err)
+	assert.Equal(t, want, findings)
+
+	_, err = requestAssist(context.Background(), "exit 1", "", req)
+	assert.ErrorContains(t, err, "craft.assistCommand")
+
+	// A URL gets it POSTed
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var got assistRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&got))
+		assert.Equal(t, req, got)
+		json.NewEncoder(w).Encode(want)
+	}))
+	defer server.Close()
+	findings, err = requestAssist(context.Background(), "", server.URL, req)
+	require.NoError(t, err)
+	assert.Equal(t, want, findings)
+
+	_, err = requestAssist(context.Background(), "", "", req)
+	assert.ErrorContains(t, err, "no reviewer configured")
+}
+
+func TestDraftThreads(t *testing.T) {
+	pr := &PullRequest{ID: "PR_test", Number: 7}
+	contents := map[string][]string{"main.go": {"package main", "", "func main() {}", ""}}
+	added := addDraftThreads(pr, []assistFinding{
+		{Path: "main.go", Line: 3, StartLine: 1, Body: "Docs?"},
+		{Path: "main.go", Line: 9, Body: "Past the end"},
+		{Path: "other.go", Line: 1, Body: "Not in the PR"},
+		{Path: "main.go", Line: 1, Body: "  "},
+	}, contents)
+	assert.Equal(t, 1, added)
+
+	// Drafts are marked in the header and survive a round trip
+	memfs := fstest.MapFS{"main.go": &fstest.MapFile{Data: []byte("package main\n\nfunc main() {}\n")}}
+	opts := craft.SerializeOptions{FS: memfs}
+	require.NoError(t, craft.Serialize(pr, opts))
+	assert.Contains(t, string(memfs["main.go"].Data), "// ╓───── new ─ range -2 ─ draft\n// ║ Docs?\n")
+	got, err := craft.Deserialize(opts)
+	require.NoError(t, err)
+
+	// and can't be sent until that's removed
+	_, err = craft.CollectNewComments(got, craft.CollectOptions{})
+	assert.ErrorContains(t, err, "1 draft comment(s) from craft assist, at main.go:3")
+	got.ReviewThreads[0].Comments[0].HeaderExtra = nil
+	review, err := craft.CollectNewComments(got, craft.CollectOptions{})
+	require.NoError(t, err)
+	assert.Len(t, review.NewThreads, 1)
+}
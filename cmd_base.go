@@ -1,9 +1,10 @@
 package main
 
 import (
+	"errors"
 	"fmt"
-	"os"
 
+	"github.com/dnr/craft/pkg/craft"
 	"github.com/spf13/cobra"
 )
 
@@ -13,20 +14,44 @@ var baseCmd = &cobra.Command{
 	Long: `Prints the base commit OID for the current PR.
 
 This reads from PR-STATE.txt which is populated by 'craft get'.
+For a stacked PR (one whose base branch is another open PR's head), this is
+the parent PR's head, so only the incremental change shows; use --no-stack
+for the base branch commit instead.
 The output can be used with vim-fugitive and vim-gitgutter to set the
 diff base for code review.
 
+--merge-base prints the merge base of the base and the PR head instead,
+worked out locally, which is what GitHub diffs against when the base branch
+has moved on. --format=jj prints a jj revset (with --merge-base, a
+fork_point() of the two, for jj to work out), and --short abbreviates the
+commit IDs, for editors' diff gutters of git and jj alike.
+
 Example usage in vim:
-  :let g:craft_base = system('craft base')`,
+  :let g:craft_base = system('craft base')
+  :let g:craft_base = system('craft base --merge-base --short')`,
 	RunE: runBase,
 	Args: cobra.NoArgs,
 }
 
+var (
+	flagBaseNoStack   bool
+	flagBaseMergeBase bool
+	flagBaseFormat    string
+	flagBaseShort     bool
+)
+
 func init() {
+	baseCmd.Flags().BoolVar(&flagBaseNoStack, "no-stack", false, "Print the base branch commit even for a stacked PR")
+	baseCmd.Flags().BoolVar(&flagBaseMergeBase, "merge-base", false, "Print the merge base of the base and the PR head")
+	baseCmd.Flags().StringVar(&flagBaseFormat, "format", "git", "Output format: git (a commit ID) or jj (a revset)")
+	baseCmd.Flags().BoolVar(&flagBaseShort, "short", false, "Abbreviate commit IDs")
 	rootCmd.AddCommand(baseCmd)
 }
 
 func runBase(cmd *cobra.Command, args []string) error {
+	if flagBaseFormat != "git" && flagBaseFormat != "jj" {
+		return fmt.Errorf("unknown format %q (want git or jj)", flagBaseFormat)
+	}
 	// Detect VCS to find repo root
 	vcs, err := DetectVCS(".")
 	if err != nil {
@@ -34,17 +59,51 @@ func runBase(cmd *cobra.Command, args []string) error {
 	}
 
 	// Deserialize PR state
-	opts := SerializeOptions{FS: DirFS(vcs.Root()), VCS: vcs}
-	pr, err := Deserialize(opts)
-	if err != nil {
+	opts := craft.SerializeOptions{FS: craft.DirFS(vcs.Root()), VCS: vcs}
+	pr, err := craft.Deserialize(opts)
+	if errors.As(err, new(craft.ParseErrors)) {
+		logWarn("%v", err)
+	} else if err != nil {
 		return fmt.Errorf("reading PR state: %w", err)
 	}
 
-	if pr.BaseRefOID == "" {
-		fmt.Fprintln(os.Stderr, "warning: no base commit in PR-STATE.txt, run 'craft get' to refresh")
+	base := pr.EffectiveBase()
+	if flagBaseNoStack {
+		base = pr.BaseRefOID
+	}
+	if base == "" {
+		logWarn("no base commit in PR-STATE.txt, run 'craft get' to refresh")
 		return fmt.Errorf("no base commit found")
 	}
 
-	fmt.Println(pr.BaseRefOID)
+	out, err := formatBase(vcs, base, pr.CheckoutOID(), flagBaseMergeBase, flagBaseFormat, flagBaseShort)
+	if err != nil {
+		return err
+	}
+	fmt.Println(out)
 	return nil
 }
+
+// formatBase returns what craft base prints for base and the PR's head.
+func formatBase(vcs VCS, base, head string, mergeBase bool, format string, short bool) (string, error) {
+	id := func(oid string) string {
+		if short {
+			return craft.ShortOID(oid)
+		}
+		return oid
+	}
+	if !mergeBase {
+		return id(base), nil
+	}
+	if head == "" {
+		return "", fmt.Errorf("no head commit in %s, run 'craft get' to refresh", craft.PRStateFile)
+	}
+	if format == "jj" {
+		return fmt.Sprintf("fork_point(%s | %s)", id(base), id(head)), nil
+	}
+	mb, err := vcs.MergeBase(base, head)
+	if err != nil {
+		return "", fmt.Errorf("finding the merge base: %w", err)
+	}
+	return id(mb), nil
+}
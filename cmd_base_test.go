@@ -0,0 +1,43 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormatBase(t *testing.T) {
+	repo := newTestGitRepo(t, map[string]string{"a.txt": "a\n"})
+	commit := func(name string) string {
+		require.NoError(t, os.WriteFile(filepath.Join(repo.root, name), []byte(name), 0644))
+		require.NoError(t, repo.Commit(name, CommitOptions{}))
+		oid, err := repo.run("rev-parse", "HEAD")
+		require.NoError(t, err)
+		return oid
+	}
+	fork, err := repo.run("rev-parse", "HEAD")
+	require.NoError(t, err)
+	head := commit("pr.txt")
+	_, err = repo.run("checkout", "-q", fork)
+	require.NoError(t, err)
+	base := commit("main.txt") // the base branch moved on
+
+	out, err := formatBase(repo, base, head, false, "git", false)
+	require.NoError(t, err)
+	assert.Equal(t, base, out)
+	out, err = formatBase(repo, base, head, true, "git", false)
+	require.NoError(t, err)
+	assert.Equal(t, fork, out)
+	out, err = formatBase(repo, base, head, true, "git", true)
+	require.NoError(t, err)
+	assert.Equal(t, fork[:12], out)
+	out, err = formatBase(repo, base, head, true, "jj", true)
+	require.NoError(t, err)
+	assert.Equal(t, "fork_point("+base[:12]+" | "+head[:12]+")", out)
+
+	_, err = formatBase(repo, base, "", true, "git", false)
+	assert.Error(t, err)
+}
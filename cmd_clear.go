@@ -6,6 +6,7 @@ import (
 	"path/filepath"
 	"strings"
 
+	"github.com/dnr/craft/pkg/craft"
 	"github.com/spf13/cobra"
 )
 
@@ -39,21 +40,51 @@ func runClear(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
+	cleared, err := clearCraftFiles(vcs, flagClearDryRun)
+	if err != nil {
+		return err
+	}
+
+	if cleared == 0 && !flagClearDryRun {
+		logInfo("No craft comments found.")
+		return nil
+	}
+
+	logInfo("Cleared craft comments from %d file(s)", cleared)
+
+	if !flagClearDryRun && flagClearCommit {
+		commitOpts, err := resolveCommitOptions(vcs, false)
+		if err != nil {
+			return err
+		}
+		logStart("Committing")
+		if err := vcs.Commit(craftCommitMessage("craft: clear review comments", "", 0), commitOpts); err != nil {
+			return fmt.Errorf("committing: %w", err)
+		}
+		logEnd("done")
+	}
+
+	return nil
+}
+
+// clearCraftFiles removes the craft comments from every file and deletes
+// PR-STATE.txt, PR-OUTDATED.txt and PR-DESCRIPTION.md. Returns how many files had comments.
+func clearCraftFiles(vcs VCS, dryRun bool) (int, error) {
 	root := vcs.Root()
 	files, err := vcs.ListFiles()
 	if err != nil {
-		return fmt.Errorf("listing files: %w", err)
+		return 0, fmt.Errorf("listing files: %w", err)
 	}
 
 	var cleared int
 	for _, path := range files {
-		if path == prStateFile {
+		if craft.IsReviewFile(path) {
 			continue
 		}
 
-		changed, err := clearCraftComments(root, path, flagClearDryRun)
+		changed, err := clearCraftComments(root, path, dryRun)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "Warning: %s: %v\n", path, err)
+			logWarn("%s: %v", path, err)
 			continue
 		}
 		if changed {
@@ -61,35 +92,23 @@ func runClear(cmd *cobra.Command, args []string) error {
 		}
 	}
 
-	// Delete PR-STATE.txt
-	prStatePath := filepath.Join(root, prStateFile)
-	if _, err := os.Stat(prStatePath); err == nil {
-		if flagClearDryRun {
-			fmt.Printf("Would delete %s\n", prStateFile)
+	// Delete PR-STATE.txt, PR-OUTDATED.txt and PR-DESCRIPTION.md
+	rootFS := craft.DirFS(root)
+	for _, name := range []string{craft.PRStateFile, craft.OutdatedFile, craft.DescriptionFile} {
+		if _, err := rootFS.Stat(name); err != nil {
+			continue
+		}
+		if dryRun {
+			fmt.Printf("Would delete %s\n", name)
 		} else {
-			if err := os.Remove(prStatePath); err != nil {
-				return fmt.Errorf("removing %s: %w", prStateFile, err)
+			if err := rootFS.Remove(name); err != nil {
+				return cleared, fmt.Errorf("removing %s: %w", name, err)
 			}
-			fmt.Printf("Deleted %s\n", prStateFile)
-		}
-	}
-
-	if cleared == 0 && !flagClearDryRun {
-		fmt.Println("No craft comments found.")
-		return nil
-	}
-
-	fmt.Printf("Cleared craft comments from %d file(s)\n", cleared)
-
-	if !flagClearDryRun && flagClearCommit {
-		fmt.Print("Committing... ")
-		if err := vcs.Commit("craft: clear review comments"); err != nil {
-			return fmt.Errorf("committing: %w", err)
+			logInfo("Deleted %s", name)
 		}
-		fmt.Println("done")
 	}
 
-	return nil
+	return cleared, nil
 }
 
 // clearCraftComments removes all craft comment lines from a file.
@@ -111,23 +130,26 @@ func clearCraftComments(root, path string, dryRun bool) (bool, error) {
 		return true, nil
 	}
 
-	return true, os.WriteFile(fullPath, []byte(cleared), 0644)
+	return true, craft.WriteFile(craft.DirFS(root), path, []byte(cleared))
 }
 
 // clearCraftContent removes all craft comment lines from file content.
 // Returns the cleaned content and whether any changes were made.
 func clearCraftContent(content, path string) (string, bool) {
-	style := getCommentStyle(path)
+	original := content
+	enc, content := craft.DecodeFile(content)
+	style := craft.GetCommentStyle(path)
 	lines := strings.Split(content, "\n")
 
 	var result []string
 	inOutdatedSection := false
 	changed := false
 
-	for _, line := range lines {
+	parsed := craft.ParseCraftLines(lines, style.LinePrefix)
+	for i, line := range lines {
 		// Check for outdated comments header
 		trimmed := strings.TrimSpace(line)
-		if strings.HasSuffix(trimmed, outdatedCommentsHeader) {
+		if trimmed == style.LinePrefix+" "+craft.OutdatedCommentsHeader {
 			inOutdatedSection = true
 			changed = true
 			continue
@@ -140,8 +162,7 @@ func clearCraftContent(content, path string) (string, bool) {
 		}
 
 		// Check for craft box characters
-		_, _, isCraft := parseCraftLine(line, style.linePrefix)
-		if isCraft {
+		if parsed[i].OK {
 			changed = true
 			continue
 		}
@@ -150,7 +171,7 @@ func clearCraftContent(content, path string) (string, bool) {
 	}
 
 	if !changed {
-		return content, false
+		return original, false
 	}
 
 	// Trim trailing blank lines that were before the outdated section
@@ -160,5 +181,5 @@ func clearCraftContent(content, path string) (string, bool) {
 	// Ensure file ends with a newline
 	result = append(result, "")
 
-	return strings.Join(result, "\n"), true
+	return enc.Encode(strings.Join(result, "\n")), true
 }
@@ -5,6 +5,7 @@ import (
 	"testing/fstest"
 	"time"
 
+	"github.com/dnr/craft/pkg/craft"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -180,8 +181,8 @@ func TestClearCraftContentRoundTrip(t *testing.T) {
 	}
 
 	memfs := fstest.MapFS{"main.go": &fstest.MapFile{Data: []byte(original)}}
-	opts := SerializeOptions{FS: memfs}
-	err := Serialize(pr, opts)
+	opts := craft.SerializeOptions{FS: memfs}
+	err := craft.Serialize(pr, opts)
 	require.NoError(t, err)
 
 	// File should now have craft comments
@@ -224,12 +225,12 @@ func TestClearCraftContentWithOutdatedRoundTrip(t *testing.T) {
 	}
 
 	memfs := fstest.MapFS{"file.go": &fstest.MapFile{Data: []byte(original)}}
-	opts := SerializeOptions{FS: memfs}
-	err := Serialize(pr, opts)
+	opts := craft.SerializeOptions{FS: memfs}
+	err := craft.Serialize(pr, opts)
 	require.NoError(t, err)
 
 	serialized := string(memfs["file.go"].Data)
-	assert.Contains(t, serialized, outdatedCommentsHeader)
+	assert.Contains(t, serialized, craft.OutdatedCommentsHeader)
 
 	cleared, changed := clearCraftContent(serialized, "file.go")
 	assert.True(t, changed)
@@ -272,7 +273,7 @@ func TestCollectNewCommentsReplyOnly(t *testing.T) {
 		},
 	}
 
-	review, err := CollectNewComments(pr)
+	review, err := craft.CollectNewComments(pr, craft.CollectOptions{})
 	require.NoError(t, err)
 
 	// Should have both a reply and a new thread
@@ -309,7 +310,7 @@ func TestCollectNewCommentsRepliesOnly(t *testing.T) {
 		},
 	}
 
-	review, err := CollectNewComments(pr)
+	review, err := craft.CollectNewComments(pr, craft.CollectOptions{})
 	require.NoError(t, err)
 
 	assert.Len(t, review.NewThreads, 0)
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/dnr/craft/pkg/craft"
+	"github.com/spf13/cobra"
+)
+
+var composeCmd = &cobra.Command{
+	Use:   "compose file:line",
+	Short: "Insert an empty new comment at a line",
+	Long: `Inserts an empty new comment into a file, with the file's comment prefix,
+the line's indentation and the configured markers, and prints where its body
+goes as file:line:column, for editors to put the cursor there.
+
+On a line of code the comment starts a new thread on it, after any threads
+already there. On a line of a thread it's a reply, at the end of the thread.
+Lines are numbered as in the file, craft comments included, from 1.
+
+An editor mapping can run it on the file and line under the cursor, reload
+the file and move the cursor to the position printed, to add a comment with
+one key. 'craft send' refuses a comment left empty.
+
+Examples:
+  craft compose main.go:42   Start a thread on line 42 of main.go`,
+	RunE: runCompose,
+	Args: cobra.ExactArgs(1),
+}
+
+func init() {
+	rootCmd.AddCommand(composeCmd)
+}
+
+func runCompose(cmd *cobra.Command, args []string) error {
+	path, line, err := parseFileLine(args[0])
+	if err != nil {
+		return err
+	}
+	if craft.IsReviewFile(filepath.Base(path)) {
+		return fmt.Errorf("%s isn't a source file; comments on the PR go in it as text", path)
+	}
+	vcs, err := DetectVCS(".")
+	if err != nil {
+		return err
+	}
+	opts, _, err := getSerializeOptions(vcs, 0)
+	if err != nil {
+		return err
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	composed, cursorLine, cursorCol, err := composeContent(string(content), path, line, opts.Boxes())
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, []byte(composed), info.Mode().Perm()); err != nil {
+		return err
+	}
+	fmt.Printf("%s:%d:%d\n", path, cursorLine, cursorCol)
+	return nil
+}
+
+// parseFileLine parses a file:line argument, with the line from 1.
+func parseFileLine(arg string) (string, int, error) {
+	i := strings.LastIndex(arg, ":")
+	line, err := strconv.Atoi(arg[i+1:])
+	if i < 0 || err != nil || line < 1 {
+		return "", 0, fmt.Errorf("expected file:line, got %q", arg)
+	}
+	return arg[:i], line, nil
+}
+
+// composeContent inserts an empty new comment at line (from 1) of a file's
+// content, written with boxes: a new thread after any on a line of code, or
+// a reply at the end of a thread on one of its lines. It returns the new
+// content and the line and column (from 1, in bytes) just past the end of
+// the comment's empty body line.
+func composeContent(content, path string, line int, boxes craft.BoxSet) (string, int, int, error) {
+	enc, content := craft.DecodeFile(content)
+	lines := strings.Split(content, "\n")
+	n := len(lines)
+	if n > 0 && lines[n-1] == "" {
+		n-- // the newline at the end doesn't start a line
+	}
+	if line > n {
+		return "", 0, 0, fmt.Errorf("%s has %d lines, no line %d", path, n, line)
+	}
+
+	style := craft.GetCommentStyle(path)
+	parsed := craft.ParseCraftLines(lines, style.LinePrefix)
+	i := line - 1
+	inThread := func(l craft.CraftLine) bool {
+		return l.OK && l.Box != craft.BoxStart && l.Box != craft.BoxChange
+	}
+
+	headerBox := boxes.Thread
+	end := i + 1
+	if inThread(parsed[i]) {
+		// A reply, in the thread's alphabet
+		if parsed[i].ASCII {
+			boxes = craft.ASCIIBoxes
+		} else {
+			boxes = craft.UnicodeBoxes
+		}
+		headerBox = boxes.Reply
+		for end < n && inThread(parsed[end]) && parsed[end].Box != craft.BoxThread {
+			end++
+		}
+	} else {
+		for end < n && inThread(parsed[end]) {
+			end++
+		}
+	}
+
+	indent := craft.GetIndent(lines[i])
+	body := indent + craft.FormatCraftLine(style.LinePrefix, boxes.Body, "")
+	comment := []string{
+		indent + craft.FormatCraftLine(style.LinePrefix, headerBox, craft.HeaderStart+" new"),
+		body,
+	}
+	lines = append(lines[:end], append(comment, lines[end:]...)...)
+	return enc.Encode(strings.Join(lines, "\n")), end + 2, len(body) + 1, nil
+}
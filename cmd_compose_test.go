@@ -0,0 +1,53 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/dnr/craft/pkg/craft"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestComposeContent(t *testing.T) {
+	content := "func f() {\n" +
+		"\tx := 1\n" +
+		"\t// ╓───── @alice ─ at 2025-01-15 12:34 ─ prrc kwDOPgi5ks6AAA111\n" +
+		"\t// ║ Rename x\n" +
+		"\ty := 2\n" +
+		"}\n"
+
+	// On a line of code, after its threads
+	got, line, col, err := composeContent(content, "a.go", 2, craft.UnicodeBoxes)
+	require.NoError(t, err)
+	assert.Equal(t, "func f() {\n"+
+		"\tx := 1\n"+
+		"\t// ╓───── @alice ─ at 2025-01-15 12:34 ─ prrc kwDOPgi5ks6AAA111\n"+
+		"\t// ║ Rename x\n"+
+		"\t// ╓───── new\n"+
+		"\t// ║\n"+
+		"\ty := 2\n"+
+		"}\n", got)
+	assert.Equal(t, 6, line)
+	assert.Equal(t, len("\t// ║")+1, col)
+
+	// On a thread, a reply at its end
+	got, line, _, err = composeContent(content, "a.go", 3, craft.ASCIIBoxes)
+	require.NoError(t, err)
+	assert.Equal(t, "func f() {\n"+
+		"\tx := 1\n"+
+		"\t// ╓───── @alice ─ at 2025-01-15 12:34 ─ prrc kwDOPgi5ks6AAA111\n"+
+		"\t// ║ Rename x\n"+
+		"\t// ╟───── new\n"+
+		"\t// ║\n"+
+		"\ty := 2\n"+
+		"}\n", got)
+	assert.Equal(t, 6, line)
+
+	// In the configured markers, with the file's prefix
+	got, _, _, err = composeContent("x = 1\r\n", "a.py", 1, craft.ASCIIBoxes)
+	require.NoError(t, err)
+	assert.Equal(t, "x = 1\r\n# |>----- new\r\n# |\r\n", got)
+
+	_, _, _, err = composeContent(content, "a.go", 7, craft.UnicodeBoxes)
+	assert.ErrorContains(t, err, "has 6 lines")
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing/fstest"
+
+	"github.com/dnr/craft/pkg/craft"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Show and change craft settings",
+	Long: `Shows and changes craft's settings. Each comes from, lowest first: its
+default, the user config file (~/.config/craft/config.yaml), the repo's
+.craft.yaml, git config craft.<name>, the environment (CRAFT_<NAME>, e.g.
+CRAFT_MARK_CHANGES=true), and command flags.
+
+Settings that name a program to run or a service to send code to (pager,
+assistCommand, assistURL) are never taken from .craft.yaml, which comes with
+the code under review.
+
+Snippets, lint and spell are only set in the config files; the user's are
+used where .craft.yaml doesn't set them.
+
+Examples:
+  craft config list
+  craft config get wrapWidth
+  craft config set markChanges true          # In git config for this repo
+  craft config set --user wrapWidth 100      # For all repos
+  craft config set --repo ascii true         # In .craft.yaml, for everyone`,
+}
+
+var configListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the settings, their values and where they come from",
+	RunE:  runConfigList,
+	Args:  cobra.NoArgs,
+}
+
+var configGetCmd = &cobra.Command{
+	Use:   "get <name>",
+	Short: "Print the value of a setting",
+	RunE:  runConfigGet,
+	Args:  cobra.ExactArgs(1),
+}
+
+var configSetCmd = &cobra.Command{
+	Use:   "set <name> <value>",
+	Short: "Change a setting, in git config unless --user or --repo is given",
+	RunE:  runConfigSet,
+	Args:  cobra.ExactArgs(2),
+}
+
+var (
+	flagConfigSetUser bool
+	flagConfigSetRepo bool
+)
+
+func init() {
+	configSetCmd.Flags().BoolVar(&flagConfigSetUser, "user", false, "Set it in the user config file")
+	configSetCmd.Flags().BoolVar(&flagConfigSetRepo, "repo", false, "Set it in .craft.yaml")
+	configSetCmd.MarkFlagsMutuallyExclusive("user", "repo")
+	configCmd.AddCommand(configListCmd, configGetCmd, configSetCmd)
+	rootCmd.AddCommand(configCmd)
+}
+
+// currentConfigLayers returns the config layers of the current repo, or just
+// the user's outside a repo.
+func currentConfigLayers() (*configLayers, error) {
+	vcs, err := DetectVCS(".")
+	if err != nil {
+		return loadConfigLayers(fstest.MapFS{}, nil)
+	}
+	return loadConfigLayers(craft.DirFS(vcs.Root()), vcs)
+}
+
+func runConfigList(cmd *cobra.Command, args []string) error {
+	layers, err := currentConfigLayers()
+	if err != nil {
+		return err
+	}
+	for _, s := range settings {
+		value, source := layers.lookup(s)
+		fmt.Printf("%-16s %-10s %-8s %s\n", s.Name, strconv.Quote(value), source, s.Doc)
+	}
+	return nil
+}
+
+func runConfigGet(cmd *cobra.Command, args []string) error {
+	s, ok := findSetting(args[0])
+	if !ok {
+		return fmt.Errorf("unknown setting %q; 'craft config list' shows them", args[0])
+	}
+	layers, err := currentConfigLayers()
+	if err != nil {
+		return err
+	}
+	value, _ := layers.lookup(s)
+	fmt.Println(value)
+	return nil
+}
+
+func runConfigSet(cmd *cobra.Command, args []string) error {
+	s, ok := findSetting(args[0])
+	if !ok {
+		return fmt.Errorf("unknown setting %q; 'craft config list' shows them", args[0])
+	}
+	value := args[1]
+	if err := validateSetting(s, value); err != nil {
+		return err
+	}
+
+	if flagConfigSetUser {
+		path := userConfigFile()
+		if path == "" {
+			return fmt.Errorf("can't find the user config directory")
+		}
+		return setConfigFileValue(path, s.Name, value)
+	}
+
+	vcs, err := DetectVCS(".")
+	if err != nil {
+		return err
+	}
+	if flagConfigSetRepo {
+		if s.NotInRepo {
+			return fmt.Errorf("%s can't be set in %s; set it in git config or with --user", s.Name, configFile)
+		}
+		return setConfigFileValue(filepath.Join(vcs.Root(), configFile), s.Name, value)
+	}
+	if err := vcs.SetConfigValue("craft."+s.Name, value); err != nil {
+		return fmt.Errorf("setting craft.%s: %w", s.Name, err)
+	}
+	return nil
+}
+
+// validateSetting checks that value is one the setting can have.
+func validateSetting(s setting, value string) error {
+	if _, ok := (&Config{}).boolSettings()[s.Name]; ok {
+		if _, ok := parseConfigBool(value); !ok {
+			return fmt.Errorf("%s must be true or false", s.Name)
+		}
+		return nil
+	}
+	switch s.Name {
+	case "wrapWidth":
+		if width, err := strconv.Atoi(value); err != nil || width <= 0 {
+			return fmt.Errorf("wrapWidth must be a positive number")
+		}
+	case "commentPosition":
+		if value != "above" && value != "below" {
+			return fmt.Errorf("commentPosition must be above or below")
+		}
+	}
+	return nil
+}
+
+// setConfigFileValue sets a top-level value in a YAML config file, creating
+// it if needed.
+func setConfigFileValue(path, name, value string) error {
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	data, err = setYAMLValue(data, name, value)
+	if err != nil {
+		return fmt.Errorf("updating %s: %w", path, err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// setYAMLValue sets the top-level key name of a YAML mapping to value,
+// keeping the rest of the document, comments included.
+func setYAMLValue(data []byte, name, value string) ([]byte, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	if doc.Kind == 0 {
+		doc = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode}}}
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("not a mapping")
+	}
+	valueNode := &yaml.Node{Kind: yaml.ScalarNode, Value: value}
+	found := false
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		if root.Content[i].Value == name {
+			valueNode.LineComment = root.Content[i+1].LineComment
+			root.Content[i+1] = valueNode
+			found = true
+		}
+	}
+	if !found {
+		root.Content = append(root.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: name}, valueNode)
+	}
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(&doc); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
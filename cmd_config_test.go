@@ -0,0 +1,128 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/dnr/craft/pkg/craft"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSettingEnvName(t *testing.T) {
+	for name, want := range map[string]string{
+		"renderEmoji": "CRAFT_RENDER_EMOJI",
+		"assistURL":   "CRAFT_ASSIST_URL",
+		"ascii":       "CRAFT_ASCII",
+	} {
+		assert.Equal(t, want, setting{Name: name}.envName())
+	}
+}
+
+func TestConfigLayers(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", home)
+	require.NoError(t, os.MkdirAll(filepath.Join(home, "craft"), 0755))
+	user := "wrapWidth: 100\nmarkChanges: true\npager: most\nsnippets:\n  nit: \"nit: {body}\"\n  q: \"question: {body}\"\n"
+	require.NoError(t, os.WriteFile(filepath.Join(home, "craft", "config.yaml"), []byte(user), 0644))
+
+	repo := newTestGitRepo(t, map[string]string{
+		configFile: "wrapWidth: 90\nascii: true\npager: evil\nsnippets:\n  nit: \"**nit:** {body}\"\n",
+	})
+	_, err := repo.run("config", "craft.commentPosition", "above")
+	require.NoError(t, err)
+	t.Setenv("CRAFT_ASCII", "off")
+
+	layers, err := loadConfigLayers(craft.DirFS(repo.root), repo)
+	require.NoError(t, err)
+	lookup := func(name string) [2]string {
+		s, ok := findSetting(name)
+		require.True(t, ok, name)
+		value, source := layers.lookup(s)
+		return [2]string{value, source}
+	}
+	assert.Equal(t, [2]string{"90", sourceRepo}, lookup("wrapWidth"))
+	assert.Equal(t, [2]string{"true", sourceUser}, lookup("markChanges"))
+	assert.Equal(t, [2]string{"above", sourceGit}, lookup("commentPosition"))
+	assert.Equal(t, [2]string{"off", sourceEnv}, lookup("ascii"))
+	assert.Equal(t, [2]string{"origin", sourceDefault}, lookup("remoteName"))
+	// A repo can't choose the programs craft runs
+	assert.Equal(t, [2]string{"most", sourceUser}, lookup("PAGER"))
+
+	cfg, err := LoadConfig(craft.DirFS(repo.root), repo)
+	require.NoError(t, err)
+	assert.True(t, cfg.MarkChanges)
+	assert.False(t, cfg.ASCII)
+	assert.Equal(t, map[string]string{"nit": "**nit:** {body}", "q": "question: {body}"}, cfg.Snippets)
+
+	t.Setenv("CRAFT_ASCII", "maybe")
+	_, err = LoadConfig(craft.DirFS(repo.root), repo)
+	assert.ErrorContains(t, err, `invalid value "maybe" for ascii (from env)`)
+}
+
+func TestSetYAMLValue(t *testing.T) {
+	data := []byte("# craft settings\nascii: false # for old terminals\nsnippets:\n  nit: \"**nit:** {body}\"\n")
+	out, err := setYAMLValue(data, "ascii", "true")
+	require.NoError(t, err)
+	out, err = setYAMLValue(out, "wrapWidth", "100")
+	require.NoError(t, err)
+	assert.Equal(t, "# craft settings\nascii: true # for old terminals\nsnippets:\n  nit: \"**nit:** {body}\"\nwrapWidth: 100\n", string(out))
+
+	cfg, err := LoadConfig(fstest.MapFS{configFile: &fstest.MapFile{Data: out}}, nil)
+	require.NoError(t, err)
+	assert.True(t, cfg.ASCII)
+
+	out, err = setYAMLValue(nil, "markChanges", "true")
+	require.NoError(t, err)
+	assert.Equal(t, "markChanges: true\n", string(out))
+
+	_, err = setYAMLValue([]byte("- a list\n"), "ascii", "true")
+	assert.Error(t, err)
+}
+
+func TestValidateSetting(t *testing.T) {
+	valid := map[string]string{"ascii": "yes", "wrapWidth": "100", "commentPosition": "above", "pager": "less"}
+	for name, value := range valid {
+		s, _ := findSetting(name)
+		assert.NoError(t, validateSetting(s, value), name)
+	}
+	invalid := map[string]string{"ascii": "maybe", "wrapWidth": "0", "commentPosition": "left"}
+	for name, value := range invalid {
+		s, _ := findSetting(name)
+		assert.Error(t, validateSetting(s, value), name)
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	fsys := fstest.MapFS{
+		configFile: &fstest.MapFile{Data: []byte("snippets:\n  nit: \"**nit:** {body}\"\n")},
+	}
+	cfg, err := LoadConfig(fsys, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "**nit:** {body}", cfg.Snippets["nit"])
+
+	cfg, err = LoadConfig(fstest.MapFS{}, nil)
+	require.NoError(t, err)
+	assert.Empty(t, cfg.Snippets)
+}
+
+func TestGetSerializeOptions(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	repo := newTestGitRepo(t, map[string]string{configFile: "ascii: true\nnoReflow: true\n"})
+	_, err := repo.run("config", "craft.wrapWidth", "60")
+	require.NoError(t, err)
+
+	opts, cfg, err := getSerializeOptions(repo, 0)
+	require.NoError(t, err)
+	assert.True(t, cfg.ASCII)
+	assert.True(t, opts.ASCII)
+	assert.True(t, opts.NoReflow)
+	assert.Equal(t, 60, opts.WrapWidth)
+	assert.Equal(t, repo, opts.VCS)
+
+	opts, _, err = getSerializeOptions(repo, 40)
+	require.NoError(t, err)
+	assert.Equal(t, 40, opts.WrapWidth)
+}
@@ -0,0 +1,245 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/dnr/craft/pkg/craft"
+	"github.com/pmezard/go-difflib/difflib"
+	"github.com/spf13/cobra"
+)
+
+var diffCmd = &cobra.Command{
+	Use:   "diff [path...]",
+	Short: "Show the PR diff without craft comments",
+	Long: `Shows the diff from the PR base to the working tree, with craft comments
+(and PR-STATE.txt and PR-OUTDATED.txt) left out, so only the code changes in
+the PR and any local edits show.
+
+Paths limit the diff to matching files: a glob, or a directory. The base is
+the same as 'craft base'.
+
+With --base-change, it shows instead how the files the PR changes changed in
+the base, from the base before it last moved (or the PR was retargeted) to
+the base now, as 'craft get' recorded. That's what may have changed in diffs
+reviewed before.
+
+Output goes through a pager when writing to a terminal: the craft.pager git
+config, or $PAGER, or "less -FRX". A diff viewer that reads a unified diff
+on stdin (like delta) can be used as the pager.
+
+Examples:
+  craft diff                  Diff the whole PR
+  craft diff src/             Diff files under src/
+  craft diff --no-pager       Write the diff to stdout
+  craft diff --base-change    Show how the base moved under the PR`,
+	RunE: runDiff,
+}
+
+var (
+	flagDiffNoStack    bool
+	flagDiffNoPager    bool
+	flagDiffBaseChange bool
+)
+
+func init() {
+	diffCmd.Flags().BoolVar(&flagDiffNoStack, "no-stack", false, "Diff against the base branch commit even for a stacked PR")
+	diffCmd.Flags().BoolVar(&flagDiffNoPager, "no-pager", false, "Don't send output through a pager")
+	diffCmd.Flags().BoolVar(&flagDiffBaseChange, "base-change", false, "Show how the base changed since before it last moved, in the files the PR changes")
+	rootCmd.AddCommand(diffCmd)
+}
+
+func runDiff(cmd *cobra.Command, args []string) error {
+	vcs, err := DetectVCS(".")
+	if err != nil {
+		return err
+	}
+
+	opts := craft.SerializeOptions{FS: craft.DirFS(vcs.Root()), VCS: vcs}
+	pr, err := craft.Deserialize(opts)
+	if errors.As(err, new(craft.ParseErrors)) {
+		logWarn("%v", err)
+	} else if err != nil {
+		return fmt.Errorf("reading PR state: %w", err)
+	}
+	base := pr.EffectiveBase()
+	if flagDiffNoStack {
+		base = pr.BaseRefOID
+	}
+	if base == "" {
+		return fmt.Errorf("no base commit in PR-STATE.txt, run 'craft get' to refresh")
+	}
+
+	var diff string
+	if flagDiffBaseChange {
+		if pr.PreviousBaseRefOID == "" {
+			return fmt.Errorf("the base hasn't moved since the first 'craft get' of PR #%d", pr.Number)
+		}
+		diff, err = baseChangeDiff(vcs, pr.PreviousBaseRefOID, pr.BaseRefOID, args)
+	} else {
+		diff, err = craftDiff(vcs, opts.FS, base, args)
+	}
+	if err != nil {
+		return err
+	}
+	if diff == "" {
+		return nil
+	}
+	if useColor(os.Stdout) {
+		diff = colorDiff(diff)
+	}
+	if flagDiffNoPager || !isTerminal(os.Stdout) {
+		_, err := os.Stdout.WriteString(diff)
+		return err
+	}
+	return runPager(vcs, diff)
+}
+
+// craftDiff returns a unified diff of each file changed since base, from its
+// content at base to its content in fsys without craft comments. If patterns
+// are given, only files matching one (as in matchPathFilter) are included.
+func craftDiff(vcs VCS, fsys fs.FS, base string, patterns []string) (string, error) {
+	files, err := vcs.GetChangedFiles(base)
+	if err != nil {
+		return "", fmt.Errorf("listing changed files: %w", err)
+	}
+
+	var buf strings.Builder
+	for _, path := range files {
+		if craft.IsReviewFile(path) {
+			continue
+		}
+		if len(patterns) > 0 && !matchesAnyPath(patterns, path) {
+			continue
+		}
+
+		// A missing version (an added or deleted file) counts as empty
+		fromFile, toFile := "a/"+path, "b/"+path
+		before, err := vcs.GetFileAtCommit(base, path)
+		if err != nil {
+			before, fromFile = "", "/dev/null"
+		}
+		content, err := craft.ReadFile(fsys, path)
+		if errors.Is(err, fs.ErrNotExist) {
+			toFile = "/dev/null"
+		} else if err != nil {
+			return "", fmt.Errorf("reading %s: %w", path, err)
+		}
+		// GetFileAtCommit trims its output, so whitespace at either end of
+		// a file doesn't count
+		after := strings.TrimSpace(stripCraftContent(string(content), path))
+		if before == after {
+			continue // only craft comments changed
+		}
+
+		if err := writeFileDiff(&buf, path, before, after, fromFile, toFile); err != nil {
+			return "", err
+		}
+	}
+	return buf.String(), nil
+}
+
+// baseChangeDiff returns a unified diff of the files the PR changes since
+// base, from their content at prev, the base before it moved, to base. That's
+// how the base under the PR's diffs changed. Patterns are as in craftDiff.
+func baseChangeDiff(vcs VCS, prev, base string, patterns []string) (string, error) {
+	files, err := vcs.GetChangedFiles(base)
+	if err != nil {
+		return "", fmt.Errorf("listing changed files: %w", err)
+	}
+
+	var buf strings.Builder
+	for _, path := range files {
+		if craft.IsReviewFile(path) {
+			continue
+		}
+		if len(patterns) > 0 && !matchesAnyPath(patterns, path) {
+			continue
+		}
+		fromFile, toFile := "a/"+path, "b/"+path
+		before, err := vcs.GetFileAtCommit(prev, path)
+		if err != nil {
+			before, fromFile = "", "/dev/null"
+		}
+		after, err := vcs.GetFileAtCommit(base, path)
+		if err != nil {
+			after, toFile = "", "/dev/null"
+		}
+		if before == after {
+			continue
+		}
+		if err := writeFileDiff(&buf, path, before, after, fromFile, toFile); err != nil {
+			return "", err
+		}
+	}
+	return buf.String(), nil
+}
+
+// writeFileDiff writes the unified diff of path from before to after, both
+// trimmed, to buf.
+func writeFileDiff(buf *strings.Builder, path, before, after, fromFile, toFile string) error {
+	fmt.Fprintf(buf, "diff --git a/%s b/%s\n", path, path)
+	if strings.IndexByte(before, 0) >= 0 || strings.IndexByte(after, 0) >= 0 {
+		fmt.Fprintf(buf, "Binary files %s and %s differ\n", fromFile, toFile)
+		return nil
+	}
+	diff, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        diffLines(before),
+		B:        diffLines(after),
+		FromFile: fromFile,
+		ToFile:   toFile,
+		Context:  craft.DiffContext,
+	})
+	if err != nil {
+		return fmt.Errorf("diffing %s: %w", path, err)
+	}
+	buf.WriteString(diff)
+	return nil
+}
+
+// diffLines splits trimmed file content into lines for difflib.
+func diffLines(content string) []string {
+	if content == "" {
+		return nil
+	}
+	return difflib.SplitLines(content)
+}
+
+func matchesAnyPath(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if matchPathFilter(pattern, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// runPager shows text in the pager from the pager setting, $PAGER or less,
+// run through the shell like git does. Without a shell (as on Windows
+// without Git Bash on the path) the text is written to stdout.
+func runPager(vcs VCS, text string) error {
+	pager := configValue(vcs, "pager")
+	if pager == "" {
+		pager = os.Getenv("PAGER")
+	}
+	if pager == "" {
+		pager = "less -FRX"
+	}
+	sh, err := exec.LookPath("sh")
+	if err != nil {
+		_, err := os.Stdout.WriteString(text)
+		return err
+	}
+	cmd := exec.Command(sh, "-c", pager)
+	cmd.Stdin = strings.NewReader(text)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("running pager %q: %w", pager, err)
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dnr/craft/pkg/craft"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCraftDiff(t *testing.T) {
+	repo := newTestGitRepo(t, map[string]string{
+		"main.go":    "package main\n\nfunc main() {\n\ta()\n}\n",
+		"same.go":    "package main\n",
+		"sub/old.go": "package sub\n",
+	})
+	base, err := repo.run("rev-parse", "HEAD")
+	require.NoError(t, err)
+
+	write := func(name, content string) {
+		require.NoError(t, os.WriteFile(filepath.Join(repo.root, name), []byte(content), 0644))
+	}
+	// A code change with a thread on it, a file with only a thread, a
+	// deleted file and PR-STATE.txt
+	write("main.go", "package main\n\nfunc main() {\n\tb()\n\t// ╓───── @bob ─ 2025-01-01 09:00\n\t// ║ Why b?\n}\n")
+	write("same.go", "package main\n// ╓───── @bob ─ 2025-01-01 09:00\n// ║ Hmm\n")
+	require.NoError(t, os.Remove(filepath.Join(repo.root, "sub/old.go")))
+	write(craft.PRStateFile, "state\n")
+	_, err = repo.run("add", "-A")
+	require.NoError(t, err)
+	_, err = repo.run("commit", "-q", "-m", "review")
+	require.NoError(t, err)
+
+	diff, err := craftDiff(repo, craft.DirFS(repo.root), base, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "diff --git a/main.go b/main.go\n"+
+		"--- a/main.go\n"+
+		"+++ b/main.go\n"+
+		"@@ -1,5 +1,5 @@\n"+
+		" package main\n"+
+		" \n"+
+		" func main() {\n"+
+		"-\ta()\n"+
+		"+\tb()\n"+
+		" }\n"+
+		"diff --git a/sub/old.go b/sub/old.go\n"+
+		"--- a/sub/old.go\n"+
+		"+++ /dev/null\n"+
+		"@@ -1 +0,0 @@\n"+
+		"-package sub\n", diff)
+
+	diff, err = craftDiff(repo, craft.DirFS(repo.root), base, []string{"sub"})
+	require.NoError(t, err)
+	assert.NotContains(t, diff, "main.go")
+	assert.Contains(t, diff, "sub/old.go")
+}
+
+func TestBaseChangeDiff(t *testing.T) {
+	repo := newTestGitRepo(t, map[string]string{
+		"a.go": "package a\n\nvar x = 1\n",
+		"b.go": "package b\n",
+	})
+	commit := func(name, content string) string {
+		require.NoError(t, os.WriteFile(filepath.Join(repo.root, name), []byte(content), 0644))
+		_, err := repo.run("commit", "-q", "-am", "change "+name)
+		require.NoError(t, err)
+		oid, err := repo.run("rev-parse", "HEAD")
+		require.NoError(t, err)
+		return oid
+	}
+	prev, err := repo.run("rev-parse", "HEAD")
+	require.NoError(t, err)
+	commit("b.go", "package b\n\nvar y = 2\n") // not in the PR
+	base := commit("a.go", "package a\n\nvar x = 2\n")
+	commit("a.go", "package a\n\nvar x = 3\n") // the PR
+
+	diff, err := baseChangeDiff(repo, prev, base, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "diff --git a/a.go b/a.go\n"+
+		"--- a/a.go\n"+
+		"+++ b/a.go\n"+
+		"@@ -1,3 +1,3 @@\n"+
+		" package a\n"+
+		" \n"+
+		"-var x = 1\n"+
+		"+var x = 2\n", diff)
+}
@@ -0,0 +1,297 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/dnr/craft/pkg/craft"
+	"github.com/spf13/cobra"
+)
+
+var exportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export the review as a document",
+	Long: `Writes the review in the files as a standalone document: the PR
+description and comments, then the threads grouped by file with the code
+they're on, their authors and whether they're resolved.
+
+Formats:
+  markdown  A Markdown report, e.g. for design docs or post-mortems
+  sarif     SARIF 2.1.0, for code scanning dashboards and SARIF viewers:
+            each thread is a result on its lines, with its comments as the
+            message, and resolved threads are suppressed
+
+Examples:
+  craft export > review.md
+  craft export -o review.md`,
+	RunE: runExport,
+	Args: cobra.NoArgs,
+}
+
+var (
+	flagExportFormat string
+	flagExportOutput string
+)
+
+func init() {
+	exportCmd.Flags().StringVar(&flagExportFormat, "format", "markdown", "Output format: markdown or sarif")
+	exportCmd.Flags().StringVarP(&flagExportOutput, "output", "o", "", "Write to a file instead of stdout")
+	rootCmd.AddCommand(exportCmd)
+}
+
+func runExport(cmd *cobra.Command, args []string) error {
+	vcs, err := DetectVCS(".")
+	if err != nil {
+		return err
+	}
+
+	opts := craft.SerializeOptions{FS: craft.DirFS(vcs.Root()), VCS: vcs}
+	pr, err := craft.Deserialize(opts)
+	if errors.As(err, new(craft.ParseErrors)) {
+		logWarn("%v", err)
+	} else if err != nil {
+		return fmt.Errorf("reading PR state: %w", err)
+	}
+	if stateContent, err := craft.ReadFile(opts.FS, craft.PRStateFile); err == nil {
+		pr.Body = prStateDescription(string(stateContent))
+	}
+
+	var out string
+	switch flagExportFormat {
+	case "markdown":
+		out = exportMarkdown(pr, opts.FS)
+	case "sarif":
+		if out, err = exportSARIF(pr); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unknown --format %q (want markdown or sarif)", flagExportFormat)
+	}
+
+	if flagExportOutput == "" {
+		_, err := os.Stdout.WriteString(out)
+		return err
+	}
+	return os.WriteFile(flagExportOutput, []byte(out), 0644)
+}
+
+// maxExcerptLines bounds the code quoted for each thread.
+const maxExcerptLines = 10
+
+// exportMarkdown formats pr as a Markdown report, quoting code from the
+// files in fsys.
+func exportMarkdown(pr *PullRequest, fsys fs.FS) string {
+	var buf strings.Builder
+	fmt.Fprintf(&buf, "# Review of PR #%d\n\n", pr.Number)
+	if pr.Body != "" {
+		buf.WriteString(pr.Body + "\n\n")
+	}
+
+	if len(pr.IssueComments) > 0 {
+		buf.WriteString("## Comments\n\n")
+		for _, c := range pr.IssueComments {
+			writeMarkdownComment(&buf, c.Author.Login, c.CreatedAt.Format("2006-01-02 15:04"), c.IsNew, c.Body)
+		}
+	}
+
+	threads := slices.Clone(pr.ReviewThreads)
+	slices.SortStableFunc(threads, func(a, b ReviewThread) int {
+		if c := strings.Compare(a.Path, b.Path); c != 0 {
+			return c
+		}
+		return a.Line - b.Line
+	})
+	var lines []string // of the current file, without craft comments
+	for i, thread := range threads {
+		if i == 0 || thread.Path != threads[i-1].Path {
+			fmt.Fprintf(&buf, "## %s\n\n", thread.Path)
+			lines = nil
+			if content, err := craft.ReadFile(fsys, thread.Path); err == nil {
+				_, text := craft.DecodeFile(stripCraftContent(string(content), thread.Path))
+				lines = strings.Split(text, "\n")
+			}
+		}
+
+		var title string
+		start := craft.ThreadStartLine(thread)
+		switch {
+		case thread.SubjectType == SubjectTypeFile:
+			title = "File"
+		case thread.Line < 1:
+			title = fmt.Sprintf("Outdated (was line %d)", thread.OriginalLine)
+		case start < thread.Line:
+			title = fmt.Sprintf("Lines %d-%d", start, thread.Line)
+		default:
+			title = fmt.Sprintf("Line %d", thread.Line)
+		}
+		var states []string
+		if thread.IsResolved {
+			states = append(states, "resolved")
+		}
+		if thread.IsOutdated && thread.Line >= 1 {
+			states = append(states, "outdated")
+		}
+		if len(states) > 0 {
+			title += " (" + strings.Join(states, ", ") + ")"
+		}
+		fmt.Fprintf(&buf, "### %s\n\n", title)
+
+		// The code the thread is on, or what GitHub quoted if it's gone
+		var excerpt []string
+		lang := strings.TrimPrefix(filepath.Ext(thread.Path), ".")
+		if thread.SubjectType != SubjectTypeFile && thread.Line >= 1 && thread.Line <= len(lines) {
+			excerpt = lines[max(start, thread.Line-maxExcerptLines+1)-1 : thread.Line]
+		} else if thread.DiffHunk != "" {
+			hunk := strings.Split(thread.DiffHunk, "\n")
+			if strings.HasPrefix(hunk[0], "@@") {
+				hunk = hunk[1:]
+			}
+			excerpt = hunk[max(len(hunk)-maxExcerptLines, 0):]
+			lang = "diff"
+		}
+		if len(excerpt) > 0 {
+			code := strings.Join(excerpt, "\n")
+			fence := markdownFence(code)
+			fmt.Fprintf(&buf, "%s%s\n%s\n%s\n\n", fence, lang, code, fence)
+		}
+
+		for _, c := range thread.Comments {
+			writeMarkdownComment(&buf, c.Author.Login, c.CreatedAt.Format("2006-01-02 15:04"), c.IsNew, c.Body)
+		}
+	}
+	return buf.String()
+}
+
+// writeMarkdownComment writes a comment as its author line and a quote of
+// its body.
+func writeMarkdownComment(buf *strings.Builder, author, at string, isNew bool, body string) {
+	if isNew {
+		buf.WriteString("**New comment** (not sent)\n")
+	} else {
+		fmt.Fprintf(buf, "**@%s** · %s\n", author, at)
+	}
+	for _, line := range strings.Split(body, "\n") {
+		buf.WriteString(strings.TrimRight("> "+line, " ") + "\n")
+	}
+	buf.WriteString("\n")
+}
+
+// markdownFence returns a code fence longer than any run of backticks in code.
+func markdownFence(code string) string {
+	fence := "```"
+	for strings.Contains(code, fence) {
+		fence += "`"
+	}
+	return fence
+}
+
+// SARIF 2.1.0, just the parts craft export uses
+type sarifLog struct {
+	Version string     `json:"version"`
+	Schema  string     `json:"$schema"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver struct {
+		Name           string `json:"name"`
+		InformationURI string `json:"informationUri"`
+	} `json:"driver"`
+}
+
+type sarifResult struct {
+	RuleID       string             `json:"ruleId"`
+	Level        string             `json:"level"`
+	Kind         string             `json:"kind"`
+	Message      sarifMessage       `json:"message"`
+	Locations    []sarifLocation    `json:"locations"`
+	Suppressions []sarifSuppression `json:"suppressions,omitempty"`
+	Properties   map[string]any     `json:"properties,omitempty"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation struct {
+		ArtifactLocation struct {
+			URI string `json:"uri"`
+		} `json:"artifactLocation"`
+		Region *sarifRegion `json:"region,omitempty"`
+	} `json:"physicalLocation"`
+}
+
+type sarifRegion struct {
+	StartLine int `json:"startLine"`
+	EndLine   int `json:"endLine"`
+}
+
+type sarifSuppression struct {
+	Kind   string `json:"kind"`
+	Status string `json:"status"`
+}
+
+// exportSARIF formats pr's threads as a SARIF log with a result for each.
+// Threads not on a line (file threads and outdated ones) are located by
+// file only.
+func exportSARIF(pr *PullRequest) (string, error) {
+	run := sarifRun{Results: []sarifResult{}}
+	run.Tool.Driver.Name = "craft"
+	run.Tool.Driver.InformationURI = "https://github.com/dnr/craft"
+
+	for _, thread := range pr.ReviewThreads {
+		var msgs []string
+		for _, c := range thread.Comments {
+			author := "new comment"
+			if !c.IsNew {
+				author = "@" + c.Author.Login
+			}
+			msgs = append(msgs, author+": "+c.Body)
+		}
+		result := sarifResult{
+			RuleID:  "review-thread",
+			Level:   "note",
+			Kind:    "review",
+			Message: sarifMessage{Text: strings.Join(msgs, "\n\n")},
+			Properties: map[string]any{
+				"resolved": thread.IsResolved,
+				"outdated": thread.IsOutdated,
+			},
+		}
+		if len(thread.Comments) > 0 && thread.Comments[0].ID != "" {
+			result.Properties["commentId"] = thread.Comments[0].ID
+		}
+		var loc sarifLocation
+		loc.PhysicalLocation.ArtifactLocation.URI = thread.Path
+		if thread.SubjectType != SubjectTypeFile && thread.Line >= 1 {
+			loc.PhysicalLocation.Region = &sarifRegion{StartLine: craft.ThreadStartLine(thread), EndLine: thread.Line}
+		}
+		result.Locations = []sarifLocation{loc}
+		if thread.IsResolved {
+			result.Suppressions = []sarifSuppression{{Kind: "external", Status: "accepted"}}
+		}
+		run.Results = append(run.Results, result)
+	}
+
+	data, err := json.MarshalIndent(sarifLog{
+		Version: "2.1.0",
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Runs:    []sarifRun{run},
+	}, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(data) + "\n", nil
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExportMarkdown(t *testing.T) {
+	at := time.Date(2025, 1, 1, 9, 0, 0, 0, time.UTC)
+	startLine := 3
+	pr := &PullRequest{
+		Number: 7,
+		Body:   "Adds things.",
+		IssueComments: []IssueComment{
+			{Author: Actor{Login: "alice"}, Body: "Looks good", CreatedAt: at},
+		},
+		ReviewThreads: []ReviewThread{
+			{
+				Path: "main.go", Line: 4, StartLine: &startLine, IsResolved: true,
+				SubjectType: SubjectTypeLine,
+				Comments: []ReviewComment{
+					{Author: Actor{Login: "bob"}, Body: "Why?\n\nSee ```x```", CreatedAt: at},
+					{Body: "Because", IsNew: true},
+				},
+			},
+			{
+				Path: "gone.go", OriginalLine: 2, IsOutdated: true, SubjectType: SubjectTypeLine,
+				DiffHunk: "@@ -1,2 +1,2 @@\n package gone\n-var x = 1",
+				Comments: []ReviewComment{{Author: Actor{Login: "carol"}, Body: "Nit", CreatedAt: at}},
+			},
+			{
+				Path: "main.go", SubjectType: SubjectTypeFile,
+				Comments: []ReviewComment{{Author: Actor{Login: "carol"}, Body: "Docs?", CreatedAt: at}},
+			},
+		},
+	}
+	memfs := fstest.MapFS{"main.go": &fstest.MapFile{Data: []byte("package main\n\nfunc main() {\n\tx()\n}\n")}}
+
+	assert.Equal(t, "# Review of PR #7\n\n"+
+		"Adds things.\n\n"+
+		"## Comments\n\n"+
+		"**@alice** · 2025-01-01 09:00\n> Looks good\n\n"+
+		"## gone.go\n\n"+
+		"### Outdated (was line 2)\n\n"+
+		"```diff\n package gone\n-var x = 1\n```\n\n"+
+		"**@carol** · 2025-01-01 09:00\n> Nit\n\n"+
+		"## main.go\n\n"+
+		"### File\n\n"+
+		"**@carol** · 2025-01-01 09:00\n> Docs?\n\n"+
+		"### Lines 3-4 (resolved)\n\n"+
+		"```go\nfunc main() {\n\tx()\n```\n\n"+
+		"**@bob** · 2025-01-01 09:00\n> Why?\n>\n> See ```x```\n\n"+
+		"**New comment** (not sent)\n> Because\n\n",
+		exportMarkdown(pr, memfs))
+}
+
+func TestExportSARIF(t *testing.T) {
+	startLine := 3
+	pr := &PullRequest{
+		ReviewThreads: []ReviewThread{
+			{
+				Path: "main.go", Line: 4, StartLine: &startLine, IsResolved: true, SubjectType: SubjectTypeLine,
+				Comments: []ReviewComment{
+					{ID: "PRRC_1", Author: Actor{Login: "bob"}, Body: "Why?"},
+					{Body: "Because", IsNew: true},
+				},
+			},
+			{
+				Path: "main.go", SubjectType: SubjectTypeFile,
+				Comments: []ReviewComment{{ID: "PRRC_2", Author: Actor{Login: "carol"}, Body: "Docs?"}},
+			},
+		},
+	}
+	out, err := exportSARIF(pr)
+	require.NoError(t, err)
+
+	var log sarifLog
+	require.NoError(t, json.Unmarshal([]byte(out), &log))
+	assert.Equal(t, "2.1.0", log.Version)
+	require.Len(t, log.Runs, 1)
+	assert.Equal(t, "craft", log.Runs[0].Tool.Driver.Name)
+	results := log.Runs[0].Results
+	require.Len(t, results, 2)
+
+	assert.Equal(t, "@bob: Why?\n\nnew comment: Because", results[0].Message.Text)
+	assert.Equal(t, "main.go", results[0].Locations[0].PhysicalLocation.ArtifactLocation.URI)
+	assert.Equal(t, &sarifRegion{StartLine: 3, EndLine: 4}, results[0].Locations[0].PhysicalLocation.Region)
+	assert.Equal(t, []sarifSuppression{{Kind: "external", Status: "accepted"}}, results[0].Suppressions)
+	assert.Equal(t, "PRRC_1", results[0].Properties["commentId"])
+
+	// File threads have no region, and unresolved threads no suppression
+	assert.Nil(t, results[1].Locations[0].PhysicalLocation.Region)
+	assert.Empty(t, results[1].Suppressions)
+}
@@ -0,0 +1,259 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"strings"
+	"syscall"
+
+	"github.com/dnr/craft/pkg/craft"
+	"github.com/spf13/cobra"
+)
+
+var fmtCmd = &cobra.Command{
+	Use:     "fmt",
+	Aliases: []string{"normalize"},
+	Short:   "Re-wrap craft comments in place",
+	Long: `Re-wraps the bodies of all craft comments in source files and PR-STATE.txt
+to the configured width. Headers and verbatim comments are left untouched, and
+running it again makes no further changes.
+
+It also expands shorthand comments: a code comment starting with ++, like
+"//++ Why not a map?", becomes a new comment, a thread on the line above or a
+reply when it follows a thread. Consecutive ++ lines make one comment.
+
+This is useful after hand-editing comments or changing the wrap width.
+
+Examples:
+  craft fmt              Re-wrap at the configured width
+  craft fmt --width 100  Re-wrap at 100 columns
+  craft fmt --dry-run    Show which files would change`,
+	RunE: runFmt,
+	Args: cobra.NoArgs,
+}
+
+var (
+	flagFmtWidth  int
+	flagFmtDryRun bool
+)
+
+func init() {
+	fmtCmd.Flags().IntVar(&flagFmtWidth, "width", 0, "Line width for wrapping comments (default: from config or 80)")
+	fmtCmd.Flags().BoolVar(&flagFmtDryRun, "dry-run", false, "Show what would be changed without modifying files")
+	rootCmd.AddCommand(fmtCmd)
+}
+
+func runFmt(cmd *cobra.Command, args []string) error {
+	vcs, err := DetectVCS(".")
+	if err != nil {
+		return err
+	}
+
+	opts, _, err := getSerializeOptions(vcs, flagFmtWidth)
+	if err != nil {
+		return err
+	}
+
+	changed, err := fmtCraftFiles(opts, flagFmtDryRun)
+	if err != nil {
+		return err
+	}
+	for _, path := range changed {
+		if flagFmtDryRun {
+			fmt.Printf("Would re-wrap %s\n", path)
+		} else {
+			fmt.Printf("Re-wrapped %s\n", path)
+		}
+	}
+	if len(changed) == 0 {
+		fmt.Println("No changes.")
+	}
+	return nil
+}
+
+// fmtCraftFiles re-wraps craft comments in every file and PR-STATE.txt.
+// Returns the paths of files that changed (or would change, with dryRun).
+func fmtCraftFiles(opts craft.SerializeOptions, dryRun bool) ([]string, error) {
+	files, err := craft.ListFiles(opts)
+	if err != nil {
+		return nil, fmt.Errorf("listing files: %w", err)
+	}
+	files = append(files, craft.PRStateFile, craft.OutdatedFile)
+
+	var changed []string
+	seen := make(map[string]bool)
+	for _, path := range files {
+		if seen[path] {
+			continue
+		}
+		seen[path] = true
+
+		content, err := craft.ReadFile(opts.FS, path)
+		if err != nil {
+			if errors.Is(err, syscall.EISDIR) || errors.Is(err, fs.ErrNotExist) {
+				// submodules, or no PR-STATE.txt or PR-OUTDATED.txt
+				continue
+			}
+			return nil, fmt.Errorf("reading %s: %w", path, err)
+		}
+		if strings.IndexByte(string(content), 0) >= 0 {
+			continue // binary
+		}
+
+		var formatted string
+		var ok bool
+		if path == craft.PRStateFile {
+			formatted, ok = fmtPRStateContent(string(content), opts)
+		} else {
+			formatted, ok = fmtCraftContent(string(content), path, opts)
+		}
+		if !ok {
+			continue
+		}
+		changed = append(changed, path)
+		if dryRun {
+			continue
+		}
+		if err := craft.WriteFile(opts.FS, path, []byte(formatted)); err != nil {
+			return nil, fmt.Errorf("writing %s: %w", path, err)
+		}
+	}
+	return changed, nil
+}
+
+// fmtCraftContent re-wraps the bodies of craft comments in a source file.
+// Returns the new content and whether it differs from the input.
+func fmtCraftContent(content, path string, opts craft.SerializeOptions) (string, bool) {
+	original := content
+	enc, content := craft.DecodeFile(content)
+	style := craft.GetCommentStyle(path)
+	prefixLen := craft.TextWidth(craft.CraftPrefix(style.LinePrefix) + craft.BoxBody + " ")
+	width := opts.WidthFor(path)
+
+	var result []string
+	var inComment bool
+	var header craft.Header
+	var indent, bodyBox string
+	var rawLines, bodyLines []string
+
+	flushComment := func() {
+		if inComment && len(bodyLines) > 0 {
+			if header.IsVerbatim {
+				result = append(result, rawLines...)
+			} else {
+				body := craft.ParseCommentBody(bodyLines, false)
+				wrapped := craft.RewrapCommentBody(body, width, prefixLen+len(indent))
+				for _, line := range strings.Split(wrapped, "\n") {
+					result = append(result, indent+craft.FormatCraftLine(style.LinePrefix, bodyBox, line))
+				}
+			}
+		}
+		inComment = false
+		rawLines, bodyLines = nil, nil
+	}
+
+	lines, _ := craft.ExpandShorthand(strings.Split(content, "\n"), style.LinePrefix)
+	for i, parsed := range craft.ParseCraftLines(lines, style.LinePrefix) {
+		line, craftContent := lines[i], parsed.Content
+		if !parsed.OK {
+			flushComment()
+			result = append(result, line)
+			continue
+		}
+		if parsed.Box == craft.BoxHunk {
+			// Quoted hunks come before the body, and suggestion previews
+			// after it
+			if len(bodyLines) > 0 {
+				flushComment()
+			}
+			result = append(result, line)
+			continue
+		}
+		if parsed.Box == craft.BoxStart || parsed.Box == craft.BoxChange {
+			flushComment()
+			result = append(result, line)
+			continue
+		}
+
+		if h, isHeader := craft.ParseHeader(craftContent); isHeader {
+			flushComment()
+			inComment = true
+			header = h
+			indent = craft.GetIndent(line)
+			// Keep the comment's alphabet
+			bodyBox = craft.BoxBody
+			if parsed.ASCII {
+				bodyBox = craft.ASCIIBody
+			}
+			result = append(result, line)
+			continue
+		}
+
+		if !inComment {
+			result = append(result, line) // stray body line
+			continue
+		}
+		rawLines = append(rawLines, line)
+		bodyLines = append(bodyLines, craftContent)
+	}
+	flushComment()
+
+	formatted := enc.Encode(strings.Join(result, "\n"))
+	return formatted, formatted != original
+}
+
+// fmtPRStateContent re-wraps the PR description and issue comment bodies in
+// PR-STATE.txt, laid out the same way as serializePRState.
+// Returns the new content and whether it differs from the input.
+func fmtPRStateContent(content string, opts craft.SerializeOptions) (string, bool) {
+	width := opts.WidthFor(craft.PRStateFile)
+
+	var buf strings.Builder
+	var inSection bool
+	var header craft.Header
+	var isList bool
+	var bodyLines []string
+
+	flushSection := func() {
+		if !inSection {
+			return
+		}
+		if isList {
+			for _, line := range bodyLines {
+				if line != "" {
+					buf.WriteString(line + "\n")
+				}
+			}
+		} else if header.IsVerbatim {
+			if body := craft.ParseCommentBody(bodyLines, true); body != "" {
+				buf.WriteString(craft.EscapeCommentBody(body) + "\n")
+			}
+		} else if body := craft.ParseCommentBody(bodyLines, false); body != "" {
+			buf.WriteString(craft.RewrapCommentBody(body, width, 0) + "\n")
+		}
+		buf.WriteString("\n")
+		bodyLines = nil
+	}
+
+	for _, line := range strings.Split(content, "\n") {
+		h, isHeader := craft.ParseHeader(strings.TrimSpace(line))
+		if !isHeader {
+			if inSection {
+				bodyLines = append(bodyLines, line)
+			} else if line != "" {
+				buf.WriteString(line + "\n") // text before the first header
+			}
+			continue
+		}
+		flushSection()
+		inSection = true
+		header = h
+		isList = craft.IsFileIndexHeader(line) || craft.IsCommitsHeader(line)
+		buf.WriteString(line + "\n")
+	}
+	flushSection()
+
+	formatted := buf.String()
+	return formatted, formatted != content
+}
@@ -0,0 +1,541 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/dnr/craft/pkg/craft"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFmtCraftContent(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+		changed  bool
+	}{
+		{
+			name:     "no craft comments",
+			input:    "package main\n\nfunc main() {\n}\n",
+			expected: "package main\n\nfunc main() {\n}\n",
+			changed:  false,
+		},
+		{
+			name: "hand-edited comment is re-wrapped",
+			input: "func main() {\n" +
+				"\tx := 1\n" +
+				"\t// ╓───── new\n" +
+				"\t// ║ This is a\n" +
+				"\t// ║ comment that was edited by hand and ended up with some very uneven lines\n" +
+				"\ty := 2\n" +
+				"}\n",
+			expected: "func main() {\n" +
+				"\tx := 1\n" +
+				"\t// ╓───── new\n" +
+				"\t// ║ This is a comment that was edited by hand\n" +
+				"\t// ║ and ended up with some very uneven lines\n" +
+				"\ty := 2\n" +
+				"}\n",
+			changed: true,
+		},
+		{
+			name: "paragraphs and replies",
+			input: "line 1\n" +
+				"// ╓───── @alice ─ at 2025-01-15 12:34 ─ prrc kwDOPgi5ks6AAA111\n" +
+				"// ║ First\n" +
+				"// ║ paragraph\n" +
+				"// ║\n" +
+				"// ║ Second\n" +
+				"// ╟───── new\n" +
+				"// ║ Reply\n" +
+				"line 2\n",
+			expected: "line 1\n" +
+				"// ╓───── @alice ─ at 2025-01-15 12:34 ─ prrc kwDOPgi5ks6AAA111\n" +
+				"// ║ First paragraph\n" +
+				"// ║\n" +
+				"// ║ Second\n" +
+				"// ╟───── new\n" +
+				"// ║ Reply\n" +
+				"line 2\n",
+			changed: true,
+		},
+		{
+			name: "verbatim comment is untouched",
+			input: "line 1\n" +
+				"// ╓───── new ─ verbatim\n" +
+				"// ║ keep\n" +
+				"// ║ these   lines\n" +
+				"line 2\n",
+			expected: "line 1\n" +
+				"// ╓───── new ─ verbatim\n" +
+				"// ║ keep\n" +
+				"// ║ these   lines\n" +
+				"line 2\n",
+			changed: false,
+		},
+	}
+
+	opts := craft.SerializeOptions{WrapWidth: 50}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, changed := fmtCraftContent(tt.input, "code.go", opts)
+			assert.Equal(t, tt.expected, got)
+			assert.Equal(t, tt.changed, changed)
+
+			// Idempotent
+			again, changed := fmtCraftContent(got, "code.go", opts)
+			assert.Equal(t, got, again)
+			assert.False(t, changed)
+		})
+	}
+}
+
+func TestFmtCraftFiles(t *testing.T) {
+	body := strings.Repeat("word ", 30)
+	pr := &PullRequest{
+		ID:         "PR_test",
+		Number:     1,
+		HeadRefOID: "abcd1234",
+		Body:       body,
+		ReviewThreads: []ReviewThread{
+			{
+				Path:        "a.go",
+				DiffSide:    DiffSideRight,
+				Line:        1,
+				SubjectType: SubjectTypeLine,
+				Comments: []ReviewComment{
+					{ID: "PRRC_1", Author: Actor{Login: "a"}, Body: body, CreatedAt: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)},
+				},
+			},
+		},
+		IssueComments: []IssueComment{
+			{ID: "IC_1", Author: Actor{Login: "b"}, Body: body},
+		},
+	}
+
+	memfs := fstest.MapFS{
+		"a.go": &fstest.MapFile{Data: []byte("code\n")},
+	}
+	require.NoError(t, craft.Serialize(pr, craft.SerializeOptions{FS: memfs}))
+
+	// Formatting at the width it was serialized with is a no-op
+	changed, err := fmtCraftFiles(craft.SerializeOptions{FS: memfs}, false)
+	require.NoError(t, err)
+	assert.Empty(t, changed)
+
+	// Changing the width matches serializing at that width
+	want := fstest.MapFS{
+		"a.go": &fstest.MapFile{Data: []byte("code\n")},
+	}
+	require.NoError(t, craft.Serialize(pr, craft.SerializeOptions{FS: want, WrapWidth: 40}))
+
+	changed, err = fmtCraftFiles(craft.SerializeOptions{FS: memfs, WrapWidth: 40}, false)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a.go", craft.PRStateFile}, changed)
+	assert.Equal(t, string(want["a.go"].Data), string(memfs["a.go"].Data))
+	assert.Equal(t, string(want[craft.PRStateFile].Data), string(memfs[craft.PRStateFile].Data))
+
+	// Comments still deserialize unmodified
+	pr2, err := craft.Deserialize(craft.SerializeOptions{FS: memfs})
+	require.NoError(t, err)
+	require.Len(t, pr2.ReviewThreads, 1)
+	assert.False(t, pr2.ReviewThreads[0].Comments[0].IsModified)
+	require.Len(t, pr2.IssueComments, 1)
+	assert.False(t, pr2.IssueComments[0].IsModified)
+}
+
+func TestFmtCraftFilesLossless(t *testing.T) {
+	// A table wider than the new width, and an emoji shortcode: fmt must
+	// neither truncate nor render them, or the bodies read as edited and
+	// send would overwrite them.
+	body := "Looks good :tada: but see:\n\n" +
+		"| option | default | description |\n" +
+		"|--------|---------|-------------|\n" +
+		"| width | 0 | wrap comment bodies at this width, or the editorconfig one |\n\n" +
+		strings.Repeat("word ", 30)
+	pr := &PullRequest{
+		ID:         "PR_test",
+		Number:     1,
+		HeadRefOID: "abcd1234",
+		Body:       body,
+		ReviewThreads: []ReviewThread{
+			{
+				Path:        "a.go",
+				DiffSide:    DiffSideRight,
+				Line:        1,
+				SubjectType: SubjectTypeLine,
+				Comments: []ReviewComment{
+					{ID: "PRRC_1", Author: Actor{Login: "a"}, Body: body, CreatedAt: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)},
+				},
+			},
+		},
+		IssueComments: []IssueComment{
+			{ID: "IC_1", Author: Actor{Login: "b"}, Body: body},
+		},
+		Reviews: []Review{
+			{ID: "PRR_1", Author: Actor{Login: "a"}, State: ReviewStatePending, Body: body},
+		},
+	}
+
+	memfs := fstest.MapFS{
+		"a.go": &fstest.MapFile{Data: []byte("code\n")},
+	}
+	require.NoError(t, craft.Serialize(pr, craft.SerializeOptions{FS: memfs, WrapWidth: 120}))
+	before := fstest.MapFS{}
+	for path, f := range memfs {
+		before[path] = &fstest.MapFile{Data: f.Data}
+	}
+
+	_, err := fmtCraftFiles(craft.SerializeOptions{FS: memfs, WrapWidth: 40}, false)
+	require.NoError(t, err)
+	assert.Contains(t, string(memfs[craft.PRStateFile].Data), "wrap comment bodies at this width, or the editorconfig one |")
+	assert.Contains(t, string(memfs[craft.PRStateFile].Data), ":tada:")
+
+	pr2, err := craft.Deserialize(craft.SerializeOptions{FS: memfs})
+	require.NoError(t, err)
+	require.Len(t, pr2.ReviewThreads, 1)
+	assert.False(t, pr2.ReviewThreads[0].Comments[0].IsModified)
+	require.Len(t, pr2.IssueComments, 1)
+	assert.False(t, pr2.IssueComments[0].IsModified)
+	require.Len(t, pr2.Reviews, 1)
+	assert.False(t, pr2.Reviews[0].IsModified)
+
+	// And formatting back restores the files
+	_, err = fmtCraftFiles(craft.SerializeOptions{FS: memfs, WrapWidth: 120}, false)
+	require.NoError(t, err)
+	for path, f := range before {
+		assert.Equal(t, string(f.Data), string(memfs[path].Data), path)
+	}
+}
+
+func TestOutdatedThreadDiffHunk(t *testing.T) {
+	pr := &PullRequest{
+		ID:         "PR_test",
+		Number:     1,
+		HeadRefOID: "abcd1234",
+		ReviewThreads: []ReviewThread{
+			{
+				ID:           "PRRT_outdated",
+				Path:         "file.go",
+				DiffSide:     DiffSideRight,
+				OriginalLine: 12,
+				SubjectType:  SubjectTypeLine,
+				DiffHunk:     "@@ -5,6 +5,8 @@ func f() {\n \tone()\n \ttwo()\n-\tthree()\n+\tfour()\n+\tfive()\n \n ───── not a header\n+\tsix()",
+				Comments: []ReviewComment{
+					{
+						ID:        "PRRC_first",
+						Author:    Actor{Login: "bob"},
+						Body:      "Why six?",
+						CreatedAt: time.Date(2025, 1, 1, 9, 0, 0, 0, time.UTC),
+					},
+					{
+						ID:        "PRRC_reply",
+						Author:    Actor{Login: "alice"},
+						Body:      "Why not?",
+						CreatedAt: time.Date(2025, 1, 1, 10, 0, 0, 0, time.UTC),
+					},
+				},
+			},
+		},
+	}
+
+	for _, ascii := range []bool{false, true} {
+		memfs := fstest.MapFS{"file.go": &fstest.MapFile{Data: []byte("package main\n")}}
+		opts := craft.SerializeOptions{FS: memfs, ASCII: ascii, Originals: pr.CommentBodies()}
+		require.NoError(t, craft.Serialize(pr, opts))
+		content := string(memfs["file.go"].Data)
+
+		// The last lines of the hunk, under the first header only
+		hunk := "// ┆ -\tthree()\n" +
+			"// ┆ +\tfour()\n" +
+			"// ┆ +\tfive()\n" +
+			"// ┆\n" +
+			"// ┆  ───── not a header\n" +
+			"// ┆ +\tsix()\n" +
+			"// ║ Why six?\n"
+		if ascii {
+			hunk = strings.NewReplacer("┆", "|:", "║", "|").Replace(hunk)
+		}
+		assert.Contains(t, content, hunk)
+		assert.NotContains(t, content, "two()")
+		assert.Equal(t, 6, strings.Count(content, opts.Boxes().Hunk))
+
+		// Hunk lines are ignored when reading back
+		pr2, err := craft.Deserialize(opts)
+		require.NoError(t, err)
+		require.Len(t, pr2.ReviewThreads, 1)
+		require.Len(t, pr2.ReviewThreads[0].Comments, 2)
+		for _, c := range pr2.ReviewThreads[0].Comments {
+			assert.False(t, c.IsModified, c.Body)
+		}
+		assert.Equal(t, "Why six?", pr2.ReviewThreads[0].Comments[0].Body)
+
+		// fmt keeps them, and clearing craft content removes them
+		_, changed := fmtCraftContent(content, "file.go", opts)
+		assert.False(t, changed)
+		assert.Equal(t, "package main\n", stripCraftContent(content, "file.go"))
+	}
+}
+
+func TestCommitsSection(t *testing.T) {
+	at := func(day, hour int) time.Time { return time.Date(2025, 1, day, hour, 0, 0, 0, time.UTC) }
+	pr := &PullRequest{
+		ID:         "PR_test",
+		Number:     1,
+		HeadRefOID: "abcd1234",
+		Commits: []Commit{
+			{OID: "89abcdef0123456789abcdef0123456789abcdef", Author: "alice", HasLogin: true, Headline: "Rework it", CommittedAt: at(3, 9)},
+			{OID: "fedcba9876543210fedcba9876543210fedcba98", Author: "Jane Doe", Headline: "Fix typo", CommittedAt: at(4, 9)},
+		},
+		ForcePushes: []ForcePush{
+			{Actor: "alice", BeforeOID: "0123456789abcdef0123456789abcdef01234567", AfterOID: "89abcdef0123456789abcdef0123456789abcdef", CreatedAt: at(3, 10)},
+			{CreatedAt: at(2, 10)},
+		},
+	}
+
+	memfs := fstest.MapFS{}
+	opts := craft.SerializeOptions{FS: memfs}
+	require.NoError(t, craft.Serialize(pr, opts))
+	content := string(memfs[craft.PRStateFile].Data)
+	assert.Contains(t, content, "───── commits\n"+
+		"2025-01-02 10:00 force-push @ghost unknown → unknown\n"+
+		"2025-01-03 09:00 89abcdef0123 @alice Rework it\n"+
+		"2025-01-03 10:00 force-push @alice 0123456789ab → 89abcdef0123\n"+
+		"2025-01-04 09:00 fedcba987654 \"Jane Doe\" Fix typo\n\n")
+
+	pr2, err := craft.Deserialize(opts)
+	require.NoError(t, err)
+	assert.Empty(t, pr2.IssueComments)
+	assert.Equal(t, []Commit{
+		{OID: "89abcdef0123", Author: "alice", HasLogin: true, Headline: "Rework it", CommittedAt: at(3, 9)},
+		{OID: "fedcba987654", Author: "Jane Doe", Headline: "Fix typo", CommittedAt: at(4, 9)},
+	}, pr2.Commits)
+	assert.Equal(t, []ForcePush{
+		{Actor: "ghost", CreatedAt: at(2, 10)},
+		{Actor: "alice", BeforeOID: "0123456789ab", AfterOID: "89abcdef0123", CreatedAt: at(3, 10)},
+	}, pr2.ForcePushes)
+
+	// The section survives another round trip and fmt
+	require.NoError(t, craft.Serialize(pr2, opts))
+	assert.Equal(t, content, string(memfs[craft.PRStateFile].Data))
+	_, changed := fmtPRStateContent(content, opts)
+	assert.False(t, changed)
+}
+
+func TestRangeStartMarker(t *testing.T) {
+	startLine := 4
+	pr := &PullRequest{
+		ID:         "PR_test",
+		Number:     1,
+		HeadRefOID: "abcd1234",
+		ReviewThreads: []ReviewThread{
+			{
+				Path: "main.go", Line: 5, StartLine: &startLine, DiffSide: DiffSideRight, SubjectType: SubjectTypeLine,
+				Comments: []ReviewComment{
+					{ID: "PRRC_range", Author: Actor{Login: "bob"}, Body: "Range", CreatedAt: time.Date(2025, 1, 1, 9, 0, 0, 0, time.UTC)},
+				},
+			},
+			{
+				Path: "main.go", Line: 3, DiffSide: DiffSideRight, SubjectType: SubjectTypeLine,
+				Comments: []ReviewComment{
+					{ID: "PRRC_line", Author: Actor{Login: "bob"}, Body: "Line", CreatedAt: time.Date(2025, 1, 2, 9, 0, 0, 0, time.UTC)},
+				},
+			},
+		},
+	}
+	original := "package main\n\nfunc main() {\n\tx()\n\ty()\n}\n"
+
+	for _, ascii := range []bool{false, true} {
+		memfs := fstest.MapFS{"main.go": &fstest.MapFile{Data: []byte(original)}}
+		opts := craft.SerializeOptions{FS: memfs, ASCII: ascii}
+		require.NoError(t, craft.Serialize(pr, opts))
+		content := string(memfs["main.go"].Data)
+
+		// The marker comes after the thread on the line before
+		expected := "func main() {\n" +
+			"// ╓───── @bob ─ at 2025-01-02 09:00 ─ sum d7852cd0 ─ v2 ─ prrc line\n" +
+			"// ║ Line\n" +
+			"\t// ╒ range start\n" +
+			"\tx()\n" +
+			"\ty()\n" +
+			"\t// ╓───── @bob ─ at 2025-01-01 09:00 ─ range -1 ─ sum 5de74a81 ─ v2 ─ prrc range\n"
+		if ascii {
+			expected = strings.NewReplacer("╓", "|>", "║", "|", "╒", "|^", craft.HeaderStart, craft.ASCIIHeaderStart, craft.HeaderFieldSep, craft.ASCIIFieldSep).Replace(expected)
+		}
+		assert.Contains(t, content, expected)
+
+		// Markers are skipped when reading back, even after a reply
+		content = strings.Replace(content, "Line\n", "Line\n// ╟───── new\n// ║ Reply\n", 1)
+		memfs["main.go"].Data = []byte(content)
+		pr2, err := craft.Deserialize(opts)
+		require.NoError(t, err)
+		require.Len(t, pr2.ReviewThreads, 2)
+		assert.Equal(t, 3, pr2.ReviewThreads[0].Line)
+		assert.Len(t, pr2.ReviewThreads[0].Comments, 2)
+		assert.Equal(t, 5, pr2.ReviewThreads[1].Line)
+		require.NotNil(t, pr2.ReviewThreads[1].StartLine)
+		assert.Equal(t, 4, *pr2.ReviewThreads[1].StartLine)
+
+		_, changed := fmtCraftContent(content, "main.go", opts)
+		assert.False(t, changed)
+		assert.Equal(t, original, stripCraftContent(content, "main.go"))
+	}
+
+	// Not needed with comments above the range
+	memfs := fstest.MapFS{"main.go": &fstest.MapFile{Data: []byte(original)}}
+	require.NoError(t, craft.Serialize(pr, craft.SerializeOptions{FS: memfs, CommentsAbove: true}))
+	assert.NotContains(t, string(memfs["main.go"].Data), craft.RangeStartText)
+}
+
+func TestCRLFAndBOMPreserved(t *testing.T) {
+	original := "\uFEFFpackage main\r\n\r\nfunc main() {\r\n}\r\n"
+	memfs := fstest.MapFS{
+		"main.go": &fstest.MapFile{Data: []byte(original)},
+	}
+	opts := craft.SerializeOptions{FS: memfs}
+	pr := &PullRequest{
+		ID:         "PR_kwDOPgi5ks6k-agY",
+		HeadRefOID: "abc123",
+		ReviewThreads: []ReviewThread{
+			{
+				Path: "main.go", Line: 3, DiffSide: DiffSideRight, SubjectType: SubjectTypeLine,
+				Comments: []ReviewComment{{
+					ID:        "PRRC_kwDOPgi5ks6AAA111",
+					Author:    Actor{Login: "alice"},
+					Body:      "First paragraph\n\nSecond",
+					CreatedAt: time.Date(2025, 1, 15, 12, 34, 0, 0, time.UTC),
+				}},
+			},
+			{
+				Path: "main.go", Line: 99, OriginalLine: 99, DiffSide: DiffSideRight, SubjectType: SubjectTypeLine,
+				Comments: []ReviewComment{{IsNew: true, Body: "Outdated"}},
+			},
+		},
+	}
+	require.NoError(t, craft.Serialize(pr, opts))
+
+	data := string(memfs["main.go"].Data)
+	assert.True(t, strings.HasPrefix(data, "\uFEFFpackage main\r\n"))
+	assert.Equal(t, strings.Count(data, "\n"), strings.Count(data, "\r\n"), "mixed line endings in %q", data)
+	assert.Contains(t, data, "func main() {\r\n// ╓───── @alice")
+
+	pr2, err := craft.Deserialize(opts)
+	require.NoError(t, err)
+	require.Len(t, pr2.ReviewThreads, 2)
+	assert.Equal(t, "First paragraph\n\nSecond", pr2.ReviewThreads[0].Comments[0].Body)
+	assert.Equal(t, 3, pr2.ReviewThreads[0].Line)
+
+	formatted, changed := fmtCraftContent(data, "main.go", opts)
+	assert.False(t, changed, "fmt changed %q", formatted)
+
+	cleared, changed := clearCraftContent(data, "main.go")
+	assert.True(t, changed)
+	assert.Equal(t, original, cleared)
+
+	// Mixed line endings are left alone
+	enc, text := craft.DecodeFile("a\r\nb\nc\r\n")
+	assert.Equal(t, craft.FileEncoding{}, enc)
+	assert.Equal(t, "a\r\nb\nc\r\n", text)
+}
+
+func TestDeserializeFileIndex(t *testing.T) {
+	pr := &PullRequest{
+		ID:         "PR_kwDOPgi5ks6k-agY",
+		HeadRefOID: "abc123",
+		ReviewThreads: []ReviewThread{{
+			Path: "indexed.go", Line: 1, DiffSide: DiffSideRight, SubjectType: SubjectTypeLine,
+			Comments: []ReviewComment{{
+				ID:        "PRRC_kwDOPgi5ks6AAA111",
+				Author:    Actor{Login: "alice"},
+				Body:      "Indexed",
+				CreatedAt: time.Date(2025, 1, 15, 12, 34, 0, 0, time.UTC),
+			}},
+		}},
+	}
+	memfs := fstest.MapFS{
+		"indexed.go":   &fstest.MapFile{Data: []byte("package main\n")},
+		"changed.go":   &fstest.MapFile{Data: []byte("package main\n// ╓───── new\n// ║ Changed\n")},
+		"untouched.go": &fstest.MapFile{Data: []byte("package main\n// ╓───── new\n// ║ Untouched\n")},
+	}
+	require.NoError(t, craft.Serialize(pr, craft.SerializeOptions{FS: memfs}))
+	assert.Contains(t, string(memfs[craft.PRStateFile].Data), "\n───── files\nindexed.go\n")
+
+	bodies := func(pr *PullRequest) []string {
+		var result []string
+		for _, thread := range pr.ReviewThreads {
+			result = append(result, thread.Comments[0].Body)
+		}
+		return result
+	}
+
+	opts := craft.SerializeOptions{FS: memfs, VCS: fileListVCS{changed: []string{"changed.go"}}}
+	pr2, err := craft.Deserialize(opts)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Changed", "Indexed"}, bodies(pr2))
+	assert.Empty(t, pr2.IssueComments)
+
+	opts.FullScan = true
+	pr2, err = craft.Deserialize(opts)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Changed", "Indexed", "Untouched"}, bodies(pr2))
+
+	// Without a VCS, every file is read
+	pr2, err = craft.Deserialize(craft.SerializeOptions{FS: memfs})
+	require.NoError(t, err)
+	assert.Len(t, pr2.ReviewThreads, 3)
+
+	// fmt leaves the index alone
+	_, changed := fmtPRStateContent(string(memfs[craft.PRStateFile].Data), opts)
+	assert.False(t, changed)
+}
+
+func TestSuggestionPreview(t *testing.T) {
+	start := 2
+	pr := &PullRequest{
+		ID: "PR_x", Number: 1, HeadRefOID: "abc123",
+		ReviewThreads: []ReviewThread{{
+			Path:      "main.go",
+			Line:      3,
+			StartLine: &start,
+			DiffSide:  DiffSideRight,
+			Comments: []ReviewComment{{
+				ID:        "PRRC_1",
+				Author:    Actor{Login: "alice"},
+				Body:      "Simpler:\n\n```suggestion\n\tx := 1\n\ty := 3\n```",
+				CreatedAt: time.Date(2025, 1, 15, 12, 34, 0, 0, time.UTC),
+			}},
+		}},
+	}
+	memfs := fstest.MapFS{
+		"main.go": &fstest.MapFile{Data: []byte("func f() {\n\tx := 1\n\ty := 2\n}\n")},
+	}
+	opts := craft.SerializeOptions{FS: memfs}
+	require.NoError(t, craft.Serialize(pr, opts))
+	content := string(memfs["main.go"].Data)
+	assert.Contains(t, content, "\t// ║ ```\n"+
+		"\t// ┆  \tx := 1\n"+
+		"\t// ┆ -\ty := 2\n"+
+		"\t// ┆ +\ty := 3\n"+
+		"}\n")
+
+	got, err := craft.Deserialize(opts)
+	require.NoError(t, err)
+	require.Len(t, got.ReviewThreads, 1)
+	assert.Equal(t, pr.ReviewThreads[0].Comments[0].Body, got.ReviewThreads[0].Comments[0].Body)
+	assert.False(t, got.ReviewThreads[0].Comments[0].IsModified)
+
+	formatted, changed := fmtCraftContent(content, "main.go", opts)
+	assert.False(t, changed, formatted)
+}
+
+// fileListVCS is a VCS that only implements file listing.
+type fileListVCS struct {
+	VCS
+	changed []string
+}
+
+func (v fileListVCS) GetChangedFiles(commit string) ([]string, error) { return v.changed, nil }
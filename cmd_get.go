@@ -1,36 +1,124 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
 	"strconv"
+	"strings"
+	"time"
 
+	"github.com/dnr/craft/pkg/craft"
 	"github.com/spf13/cobra"
 )
 
 var getCmd = &cobra.Command{
-	Use:   "get [pr-number]",
+	Use:   "get [pr-number | pr-url]",
 	Short: "Fetch a PR and set up for review",
 	Long: `Fetches a pull request from GitHub, creates a local branch, and
 serializes the PR review state into the source files.
 
-If no PR number is given and you're already on a pr-N branch, it refreshes
-that PR.
+The PR can be given by number, by URL (as pasted from a browser), or with
+--branch by its head branch. If no PR is given and you're already on a pr-N
+branch, it refreshes that PR.
+
+Resolved threads are left out unless --include-resolved is given. They're
+still on GitHub, and a later get can bring them back.
+
+--author, --since, --unresolved-only and --path serialize only the threads
+that match all of the given filters. A later send serializes every thread
+again.
+
+With --outdated-file, outdated and resolved threads are collected in
+PR-OUTDATED.txt, grouped by file, instead of being left in the source files.
+Once the file exists, later runs keep using it.
+
+Comments edited on GitHub after they were posted have "edited" in their
+headers. With --show-edits, review comments also quote their body from
+before the last edit, under the header:
+
+  // ╟───── @alice ─ at 2025-01-15 12:34 ─ edited ─ ...
+  // ┆ before edit:
+  // ┆ Rename this to count.
+  // ║ Rename this to total.
+
+With --commit, one commit of the PR is checked out instead of its head,
+for commit-by-commit review of a large PR. Only the threads started on that
+commit are serialized, diffs (craft diff, :Ctool) are against the commit
+before it, and send comments on that commit. Run get without --commit to go
+back to the whole PR.
+
+With --in-place, for the author of a PR, the review is serialized onto the
+working copy of your own branch as it is, with no pr-N branch, no commit,
+and uncommitted changes kept. Threads are moved from their lines in the PR
+to the same code in your files. Reply in the files, or add "resolved" to a
+thread's header to resolve it, and 'craft send' sends the replies and
+resolves the threads, leaving your code alone. 'craft clear --commit=false'
+removes the review.
+
+With --worktree (git only), the PR branch is checked out in a separate
+worktree at ../<repo>-pr-N instead of the current checkout, which is left
+alone. Running it again refreshes that worktree.
 
 Examples:
-  craft get 123        # Fetch PR #123
-  craft get            # Refresh current PR`,
-	RunE: runGet,
-	Args: cobra.MaximumNArgs(1),
+  craft get 123             # Fetch PR #123
+  craft get                 # Refresh current PR
+  craft get https://github.com/owner/repo/pull/123
+  craft get --branch feature-x         # Fetch the open PR from feature-x
+  craft get --worktree 123  # Review PR #123 in ../<repo>-pr-123
+  craft get --author alice --since 2d  # Only threads alice commented on lately
+  craft get --commit 1a2b3c4 123       # Review one commit of PR #123
+  craft get --in-place 123  # Answer reviews of your PR #123 on your branch`,
+	RunE:              runGet,
+	Args:              cobra.MaximumNArgs(1),
+	ValidArgsFunction: completePRNumbers,
 }
 
 var (
-	flagGetRemote string
-	flagGetForce  bool
+	flagGetRemote   string
+	flagGetBranch   string
+	flagGetForce    bool
+	flagGetWidth    int
+	flagGetWorktree bool
+	flagGetOutdated bool
+	flagGetResolved bool
+	flagGetEdits    bool
+	flagGetCommit   string
+	flagGetInPlace  bool
+	flagGetNoCommit bool
+	flagGetNoSign   bool
+
+	flagGetAuthors    []string
+	flagGetSince      string
+	flagGetUnresolved bool
+	flagGetPaths      []string
 )
 
 func init() {
 	getCmd.Flags().StringVar(&flagGetRemote, "remote", "", "Git remote name (default: from config or 'origin')")
+	getCmd.Flags().StringVar(&flagGetBranch, "branch", "", "Fetch the open PR whose head is this branch")
 	getCmd.Flags().BoolVar(&flagGetForce, "force", false, "Force refresh even with uncommitted changes")
+	getCmd.Flags().IntVar(&flagGetWidth, "width", 0, "Line width for wrapping comments (default: from config or 80)")
+	getCmd.Flags().BoolVar(&flagGetInPlace, "in-place", false, "Serialize onto the working copy of your own branch, without switching branches or committing")
+	getCmd.Flags().BoolVar(&flagGetNoCommit, "no-commit", false, "Leave the craft comments uncommitted (default: from the autoCommit setting)")
+	getCmd.Flags().BoolVar(&flagGetNoSign, "no-sign", false, "Don't sign the commit, even if git or jj is configured to")
+	getCmd.Flags().BoolVar(&flagGetWorktree, "worktree", false, "Check out the PR in a separate git worktree at ../<repo>-pr-N")
+	getCmd.Flags().BoolVar(&flagGetOutdated, "outdated-file", false, "Collect outdated and resolved threads in PR-OUTDATED.txt")
+	getCmd.Flags().StringVar(&flagGetCommit, "commit", "", "Review only this commit of the PR (a prefix of its hash)")
+	getCmd.Flags().BoolVar(&flagGetEdits, "show-edits", false, "Quote the previous body of edited review comments (a query per comment)")
+	getCmd.Flags().BoolVar(&flagGetResolved, "include-resolved", false, "Serialize resolved threads too")
+	getCmd.Flags().StringSliceVar(&flagGetAuthors, "author", nil, "Only threads with a comment by this user (repeatable)")
+	getCmd.Flags().StringVar(&flagGetSince, "since", "", "Only threads with a comment created or edited since a date (2006-01-02), time or duration ago (36h, 2d)")
+	getCmd.Flags().BoolVar(&flagGetUnresolved, "unresolved-only", false, "Only unresolved threads")
+	getCmd.Flags().StringSliceVar(&flagGetPaths, "path", nil, "Only threads on files matching a glob or under a directory (repeatable)")
+	getCmd.MarkFlagsMutuallyExclusive("include-resolved", "unresolved-only")
+	getCmd.MarkFlagsMutuallyExclusive("in-place", "worktree")
+	getCmd.MarkFlagsMutuallyExclusive("in-place", "commit")
 }
 
 func runGet(cmd *cobra.Command, args []string) error {
@@ -39,7 +127,7 @@ func runGet(cmd *cobra.Command, args []string) error {
 	if err != nil {
 		return err
 	}
-	fmt.Printf("Using %s repository at %s\n", vcs.Name(), vcs.Root())
+	logInfo("Using %s repository at %s", vcs.Name(), vcs.Root())
 
 	// Determine remote and GitHub repo
 	remote := resolveRemote(vcs, flagGetRemote)
@@ -47,78 +135,540 @@ func runGet(cmd *cobra.Command, args []string) error {
 	if err != nil {
 		return err
 	}
-	fmt.Printf("GitHub repo: %s/%s\n", owner, repo)
+	logInfo("GitHub repo: %s/%s", owner, repo)
+
+	filter := threadFilter{
+		Authors:        flagGetAuthors,
+		UnresolvedOnly: flagGetUnresolved,
+		Paths:          flagGetPaths,
+	}
+	if flagGetSince != "" {
+		if filter.Since, err = parseSince(flagGetSince, time.Now()); err != nil {
+			return err
+		}
+	}
 
 	// Determine PR number
 	var prNumber int
-	if len(args) == 1 {
-		prNumber, err = strconv.Atoi(args[0])
-		if err != nil {
-			return fmt.Errorf("invalid PR number: %s", args[0])
+	if len(args) == 1 && flagGetBranch != "" {
+		return fmt.Errorf("give a PR or --branch, not both")
+	} else if len(args) == 1 {
+		if prNumber, err = parsePRArg(args[0], owner, repo); err != nil {
+			return err
 		}
+	} else if flagGetBranch != "" {
+		if prNumber, err = client.FindPRByBranch(cmd.Context(), owner, repo, flagGetBranch); err != nil {
+			return err
+		}
+	} else if flagGetInPlace {
+		// Refresh an earlier get --in-place
+		pr, err := craft.Deserialize(craft.SerializeOptions{FS: craft.DirFS(vcs.Root()), VCS: vcs})
+		if err != nil || !pr.InPlace {
+			return fmt.Errorf("--in-place needs a PR number")
+		}
+		prNumber = pr.Number
 	} else {
 		prNumber, err = prNumberFromBranch(vcs)
 		if err != nil {
 			return err
 		}
 	}
-	fmt.Printf("PR number: %d\n", prNumber)
+	logInfo("PR number: %d", prNumber)
 
-	// Check for uncommitted changes
-	if !flagGetForce {
-		hasChanges, err := vcs.HasUncommittedChanges()
+	if flagGetInPlace {
+		return runGetInPlace(cmd.Context(), vcs, client, owner, repo, prNumber, filter)
+	}
+
+	// In worktree mode, only the worktree is touched, if it exists yet
+	var gitRepo *GitRepo
+	var worktreePath string
+	checkVCS := vcs
+	if flagGetWorktree {
+		var ok bool
+		if gitRepo, ok = vcs.(*GitRepo); !ok {
+			return fmt.Errorf("--worktree is only supported in git repositories")
+		}
+		worktreePath, err = gitRepo.PRWorktreePath(prNumber)
 		if err != nil {
+			return fmt.Errorf("finding worktree path: %w", err)
+		}
+		checkVCS = nil
+		if _, err := os.Stat(filepath.Join(worktreePath, ".git")); err == nil {
+			checkVCS = &GitRepo{root: worktreePath}
+		}
+	}
+
+	commit, err := resolveAutoCommit(vcs, flagGetNoCommit)
+	if err != nil {
+		return err
+	}
+	commitOpts, err := resolveCommitOptions(vcs, flagGetNoSign)
+	if err != nil {
+		return err
+	}
+
+	// Check for uncommitted changes. Changes only to craft comments (from
+	// --no-commit, or hand edits) don't count, and are discarded before
+	// switching branches, as long as there are no new comments in them.
+	var craftChanges []string
+	if !flagGetForce && checkVCS != nil {
+		if craftChanges, err = checkVCS.GetUncommittedFiles(); err != nil {
 			return fmt.Errorf("checking for uncommitted changes: %w", err)
 		}
-		if hasChanges {
-			return fmt.Errorf("uncommitted changes detected; use --force to discard or commit/send first")
+		if len(craftChanges) > 0 {
+			hasChanges, err := hasNonCraftChanges(checkVCS)
+			if err != nil {
+				return fmt.Errorf("checking for uncommitted changes: %w", err)
+			}
+			if hasChanges {
+				return fmt.Errorf("uncommitted changes detected; use --force to discard or commit/send first")
+			}
+			if err := checkUnsentComments(checkVCS); err != nil {
+				return err
+			}
 		}
 	}
 
 	// Fetch PR data from GitHub API
-	fmt.Print("Fetching PR data from GitHub... ")
+	logStart("Fetching PR data from GitHub")
 	pr, err := client.FetchPullRequest(cmd.Context(), owner, repo, prNumber)
 	if err != nil {
 		return fmt.Errorf("fetching PR: %w", err)
 	}
-	fmt.Println("done")
-	fmt.Printf("PR: %s\n", pr.Title)
-	fmt.Printf("Head: %s (%s)\n", pr.HeadRefName, pr.HeadRefOID[:12])
+	logEnd("done")
+	if flagGetEdits {
+		logStart("Fetching edit history")
+		if err := client.FetchPreviousBodies(cmd.Context(), pr); err != nil {
+			return fmt.Errorf("fetching edits: %w", err)
+		}
+		logEnd("done")
+	}
+	logInfo("PR: %s", pr.Title)
+	logInfo("Head: %s (%s)", pr.HeadRefName, pr.HeadRefOID[:12])
+	if pr.IsClosed() {
+		logWarn("PR #%d is %s; its review is for reading, and craft send refuses it without --force", prNumber, strings.ToLower(pr.State))
+	} else if pr.IsDraft {
+		logInfo("PR #%d is a DRAFT", prNumber)
+	}
+	if pr.StackParent != 0 {
+		logInfo("Stacked on PR #%d (%s)", pr.StackParent, pr.BaseRefName)
+	}
+	if last, err := loadPRCache(vcs, prNumber); err == nil {
+		if msg := noteBaseChange(last, pr); msg != "" {
+			logWarn("%s", msg)
+		}
+	}
+	if flagGetCommit != "" {
+		i, err := findPRCommit(pr, flagGetCommit)
+		if err != nil {
+			return err
+		}
+		focusCommit(pr, pr.Commits[i].OID)
+		logInfo("Commit: %s (%d of %d) %s", pr.Commits[i].OID[:12], i+1, len(pr.Commits), pr.Commits[i].Headline)
+	}
+
+	// Kept for craft switch, which writes it out again without fetching
+	if err := savePRCache(vcs, pr); err != nil {
+		logWarn("keeping a copy of PR #%d for craft switch: %v", prNumber, err)
+	}
 
-	// Fetch the PR branch from remote
-	fmt.Print("Fetching PR branch... ")
+	// Fetch the PR branch from remote. A merged or closed PR's head can be
+	// gone, but its threads can still be read on the current branch.
+	logStart("Fetching PR branch")
+	headGone := false
 	if err := vcs.FetchPRBranch(remote, prNumber); err != nil {
-		return fmt.Errorf("fetching PR branch: %w", err)
+		if !pr.IsClosed() || flagGetWorktree {
+			return fmt.Errorf("fetching PR branch: %w", err)
+		}
+		logEnd("gone")
+		logWarn("fetching PR branch: %v", err)
+		headGone = true
+	} else {
+		logEnd("done")
+	}
+	if headGone {
+		return runGetHeadGone(vcs, pr, filter)
+	}
+
+	// The parent's head is the effective base, so make sure it's available
+	if pr.StackParent != 0 {
+		logStart("Fetching parent PR #%d branch", pr.StackParent)
+		if err := vcs.FetchPRBranch(remote, pr.StackParent); err != nil {
+			return fmt.Errorf("fetching parent PR branch: %w", err)
+		}
+		logEnd("done")
+	}
+
+	// Uncommitted comments would be carried over to the new branch
+	if len(craftChanges) > 0 {
+		logInfo("Discarding uncommitted changes to craft comments")
+		if err := checkVCS.RevertFiles(craftChanges); err != nil {
+			return fmt.Errorf("discarding craft comments: %w", err)
+		}
+	} else if !commit && checkVCS != nil {
+		if _, err := clearCraftFiles(checkVCS, false); err != nil {
+			return fmt.Errorf("clearing the last review: %w", err)
+		}
 	}
-	fmt.Println("done")
 
 	// Create/switch to local branch
-	fmt.Print("Switching to local branch... ")
-	if err := vcs.CreateAndSwitchBranch(prNumber, pr.HeadRefOID); err != nil {
-		return fmt.Errorf("creating branch: %w", err)
+	if flagGetWorktree {
+		logStart("Setting up worktree at %s", worktreePath)
+		wt, err := gitRepo.AddPRWorktree(worktreePath, prNumber, pr.CheckoutOID())
+		if err != nil {
+			return fmt.Errorf("creating worktree: %w", err)
+		}
+		vcs = wt
+	} else {
+		logStart("Switching to local branch")
+		if err := vcs.CreateAndSwitchBranch(prNumber, pr.CheckoutOID()); err != nil {
+			return fmt.Errorf("creating branch: %w", err)
+		}
+	}
+	logEnd("done")
+
+	// Move threads on renamed files to the new path
+	if err := retargetRenamedThreads(vcs, pr); err != nil {
+		logWarn("%v", err)
 	}
-	fmt.Println("done")
+
+	// Leave out threads that don't match the filters
+	numThreads := len(pr.ReviewThreads)
+	filter.apply(pr)
 
 	// Serialize PR state to files
-	fmt.Print("Serializing PR state... ")
-	opts := SerializeOptions{FS: DirFS(vcs.Root()), VCS: vcs}
-	if err := Serialize(pr, opts); err != nil {
+	logStart("Serializing PR state")
+	opts, err := prSerializeOptions(vcs, pr)
+	if err != nil {
+		return err
+	}
+	applied := craft.MarkAppliedSuggestions(opts, pr)
+	if err := craft.Serialize(pr, opts); err != nil {
 		return fmt.Errorf("serializing: %w", err)
 	}
-	fmt.Println("done")
+	logEnd("done")
+	if err := runHook(vcs, "post-get", pr); err != nil {
+		logWarn("%v", err)
+	}
 
 	// Commit the changes
-	fmt.Print("Committing... ")
-	commitMsg := fmt.Sprintf("craft: PR #%d state\n\n%s", prNumber, pr.Title)
-	if err := vcs.Commit(commitMsg); err != nil {
-		return fmt.Errorf("committing: %w", err)
+	if commit {
+		logStart("Committing")
+		commitMsg := craftCommitMessage(fmt.Sprintf("craft: PR #%d state", prNumber), pr.Title, prNumber)
+		if err := vcs.Commit(commitMsg, commitOpts); err != nil {
+			return fmt.Errorf("committing: %w", err)
+		}
+		logEnd("done")
 	}
-	fmt.Println("done")
 
 	// Summary
-	fmt.Printf("\nReady for review on branch pr-%d\n", prNumber)
-	fmt.Printf("  %d review threads\n", len(pr.ReviewThreads))
-	fmt.Printf("  %d issue comments\n", len(pr.IssueComments))
+	logInfo("Ready for review on branch pr-%d", prNumber)
+	if pr.ReviewCommitOID != "" {
+		logInfo("  at commit %s", pr.ReviewCommitOID[:12])
+	}
+	if flagGetWorktree {
+		logInfo("  in worktree %s", worktreePath)
+	}
+	if len(pr.ReviewThreads) < numThreads {
+		logInfo("  %d of %d review threads (filtered)", len(pr.ReviewThreads), numThreads)
+	} else {
+		logInfo("  %d review threads", len(pr.ReviewThreads))
+	}
+	if n := pr.ResolvedThreadCount(); n > 0 && opts.HideResolved {
+		logInfo("  (%d resolved, hidden; use --include-resolved to show them)", n)
+	}
+	if applied > 0 {
+		logInfo("  %d with their suggestion applied; 'craft resolve --applied' resolves them", applied)
+	}
+	logInfo("  %d issue comments", len(pr.IssueComments))
+	if !commit {
+		logInfo("  nothing was committed; the comments are only in the working copy")
+	}
+
+	return nil
+}
+
+// checkUnsentComments returns an error if the review in the files of vcs has
+// new comments, which switching to another would discard. Comments that
+// can't be read might be new, so they count too, as does a review that can't
+// be read at all.
+func checkUnsentComments(vcs VCS) error {
+	last, err := craft.Deserialize(craft.SerializeOptions{FS: craft.DirFS(vcs.Root()), VCS: vcs})
+	var parseErrs craft.ParseErrors
+	if err != nil && !errors.As(err, &parseErrs) {
+		return fmt.Errorf("reading the review to check for unsent comments: %w\nuse --force to discard it", err)
+	}
+	if n := computeStats(last).NewComments; n > 0 {
+		return fmt.Errorf("%d new comment(s) not sent; send them first, or use --force to discard them", n)
+	}
+	if parseErrs != nil {
+		return fmt.Errorf("%w\nthese lines may be unsent comments; fix them, or use --force to discard them", parseErrs)
+	}
+	return nil
+}
+
+// prSerializeOptions returns the options to serialize pr into vcs with,
+// from the config and get's flags. Its comments, as fetched, are the
+// originals that reading the files back restores.
+func prSerializeOptions(vcs VCS, pr *PullRequest) (craft.SerializeOptions, error) {
+	opts, cfg, err := getSerializeOptions(vcs, flagGetWidth)
+	if err != nil {
+		return opts, err
+	}
+	opts.OutdatedFile = opts.OutdatedFile || flagGetOutdated
+	opts.HideResolved = !flagGetResolved && !cfg.IncludeResolved
+	opts.Originals = pr.CommentBodies()
+	return opts, nil
+}
+
+// runGetHeadGone serializes a merged or closed PR whose head can't be
+// fetched onto the current branch, to read its threads. Nothing is
+// committed, since the branch isn't the PR's.
+func runGetHeadGone(vcs VCS, pr *PullRequest, filter threadFilter) error {
+	logInfo("Staying on the current branch, since the PR's head is gone")
+	opts := craft.SerializeOptions{FS: craft.DirFS(vcs.Root()), VCS: vcs}
+	if !flagGetForce {
+		if _, err := fs.Stat(opts.FS, craft.PRStateFile); err == nil {
+			return fmt.Errorf("%s exists; send or clear the review first, or use --force to replace it", craft.PRStateFile)
+		}
+	}
+
+	numThreads := len(pr.ReviewThreads)
+	filter.apply(pr)
+
+	logStart("Serializing PR state")
+	opts, err := prSerializeOptions(vcs, pr)
+	if err != nil {
+		return err
+	}
+	// Threads go on the same code if it's here, as for get --in-place
+	craft.PlaceInWorkingCopy(opts, pr)
+	applied := craft.MarkAppliedSuggestions(opts, pr)
+	if err := craft.Serialize(pr, opts); err != nil {
+		return fmt.Errorf("serializing: %w", err)
+	}
+	logEnd("done")
 
+	logInfo("PR #%d (%s) is on the current branch, to read", pr.Number, strings.ToLower(pr.State))
+	if len(pr.ReviewThreads) < numThreads {
+		logInfo("  %d of %d review threads (filtered)", len(pr.ReviewThreads), numThreads)
+	} else {
+		logInfo("  %d review threads", len(pr.ReviewThreads))
+	}
+	if applied > 0 {
+		logInfo("  %d with their suggestion applied; 'craft resolve --applied' resolves them", applied)
+	}
+	logInfo("  nothing was committed; 'craft clear' removes the comments")
 	return nil
 }
+
+// runGetInPlace serializes the PR onto the working copy as it is, for its
+// author to answer reviews from their own branch.
+func runGetInPlace(ctx context.Context, vcs VCS, client GitHubAPI, owner, repo string, prNumber int, filter threadFilter) error {
+	opts := craft.SerializeOptions{FS: craft.DirFS(vcs.Root()), VCS: vcs}
+
+	// Serializing again would lose comments not sent yet
+	if !flagGetForce {
+		if _, err := fs.Stat(opts.FS, craft.PRStateFile); err == nil {
+			return fmt.Errorf("%s exists; send or clear the review first, or use --force to replace it", craft.PRStateFile)
+		}
+	}
+
+	logStart("Fetching PR data from GitHub")
+	pr, err := client.FetchPullRequest(ctx, owner, repo, prNumber)
+	if err != nil {
+		return fmt.Errorf("fetching PR: %w", err)
+	}
+	logEnd("done")
+	if flagGetEdits {
+		logStart("Fetching edit history")
+		if err := client.FetchPreviousBodies(ctx, pr); err != nil {
+			return fmt.Errorf("fetching edits: %w", err)
+		}
+		logEnd("done")
+	}
+	logInfo("PR: %s", pr.Title)
+	logInfo("Head: %s (%s)", pr.HeadRefName, pr.HeadRefOID[:12])
+	pr.InPlace = true
+
+	numThreads := len(pr.ReviewThreads)
+	filter.apply(pr)
+
+	logStart("Serializing PR state")
+	opts, err = prSerializeOptions(vcs, pr)
+	if err != nil {
+		return err
+	}
+	craft.PlaceInWorkingCopy(opts, pr)
+	applied := craft.MarkAppliedSuggestions(opts, pr)
+	if err := craft.Serialize(pr, opts); err != nil {
+		return fmt.Errorf("serializing: %w", err)
+	}
+	logEnd("done")
+
+	logInfo("Ready to answer the review of PR #%d in place", prNumber)
+	if len(pr.ReviewThreads) < numThreads {
+		logInfo("  %d of %d review threads (filtered)", len(pr.ReviewThreads), numThreads)
+	} else {
+		logInfo("  %d review threads", len(pr.ReviewThreads))
+	}
+	if n := pr.ResolvedThreadCount(); n > 0 && opts.HideResolved {
+		logInfo("  (%d resolved, hidden; use --include-resolved to show them)", n)
+	}
+	if applied > 0 {
+		logInfo("  %d with their suggestion applied; 'craft resolve --applied' resolves them", applied)
+	}
+	logInfo("  nothing was committed; 'craft send' sends replies and resolves threads")
+	return nil
+}
+
+// parsePRArg returns the PR number given by arg, a number or the URL of a PR
+// in owner/repo.
+func parsePRArg(arg, owner, repo string) (int, error) {
+	if number, err := strconv.Atoi(arg); err == nil {
+		return number, nil
+	}
+	urlOwner, urlRepo, number, err := ParseGitHubPRURL(arg)
+	if err != nil {
+		return 0, fmt.Errorf("invalid PR number or URL: %s", arg)
+	}
+	// GitHub names aren't case sensitive
+	if !strings.EqualFold(urlOwner, owner) || !strings.EqualFold(urlRepo, repo) {
+		return 0, fmt.Errorf("PR is in %s/%s, but this repository is %s/%s; use --remote for another remote", urlOwner, urlRepo, owner, repo)
+	}
+	return number, nil
+}
+
+// findPRCommit returns the index in pr.Commits of the commit whose hash
+// starts with prefix.
+func findPRCommit(pr *PullRequest, prefix string) (int, error) {
+	prefix = strings.ToLower(prefix)
+	if len(prefix) < 4 {
+		return 0, fmt.Errorf("commit %q is too short, give at least 4 characters", prefix)
+	}
+	found := -1
+	for i, c := range pr.Commits {
+		if !strings.HasPrefix(c.OID, prefix) {
+			continue
+		}
+		if found >= 0 {
+			return 0, fmt.Errorf("commit %q is ambiguous", prefix)
+		}
+		found = i
+	}
+	if found < 0 {
+		return 0, fmt.Errorf("%s isn't a commit of PR #%d", prefix, pr.Number)
+	}
+	return found, nil
+}
+
+// focusCommit sets pr up for review of its commit oid. Only the threads
+// started on that commit are kept, at their original lines, which are lines
+// of the commit's files.
+func focusCommit(pr *PullRequest, oid string) {
+	pr.ReviewCommitOID = oid
+	pr.ReviewThreads = slices.DeleteFunc(pr.ReviewThreads, func(thread ReviewThread) bool {
+		return thread.OriginalCommitOID != oid
+	})
+	for i := range pr.ReviewThreads {
+		thread := &pr.ReviewThreads[i]
+		if thread.OriginalLine >= 1 {
+			thread.Line, thread.StartLine = thread.OriginalLine, thread.OriginalStartLine
+		}
+		thread.IsOutdated = false
+	}
+}
+
+// noteBaseChange compares pr's base with last, the PR as of the last get,
+// and sets pr.PreviousBaseRefOID to the base before the last move. If the
+// base moved since last, it returns a warning that the diffs reviewed then
+// may have changed.
+func noteBaseChange(last, pr *PullRequest) string {
+	if last.BaseRefOID == "" || last.BaseRefOID == pr.BaseRefOID {
+		pr.PreviousBaseRefOID = last.PreviousBaseRefOID
+		return ""
+	}
+	pr.PreviousBaseRefOID = last.BaseRefOID
+	var what string
+	if last.BaseRefName != "" && last.BaseRefName != pr.BaseRefName {
+		what = fmt.Sprintf("PR #%d was retargeted from %s to %s", pr.Number, last.BaseRefName, pr.BaseRefName)
+	} else {
+		what = fmt.Sprintf("the base branch %s of PR #%d moved", pr.BaseRefName, pr.Number)
+	}
+	return fmt.Sprintf("%s since the last get (%s..%s), so diffs reviewed before may have changed; 'craft diff --base-change' shows how",
+		what, craft.ShortOID(last.BaseRefOID), craft.ShortOID(pr.BaseRefOID))
+}
+
+// threadFilter selects the review threads craft get serializes. A thread
+// must match every filter that's set.
+type threadFilter struct {
+	Authors        []string  // Some comment is by one of these logins
+	Since          time.Time // Some comment was created or edited at or after this
+	UnresolvedOnly bool      // The thread isn't resolved
+	Paths          []string  // The file matches one of these globs, or is under one as a directory
+}
+
+// apply removes the threads that don't match f from pr.
+func (f threadFilter) apply(pr *PullRequest) {
+	pr.ReviewThreads = slices.DeleteFunc(pr.ReviewThreads, func(thread ReviewThread) bool {
+		return !f.match(thread)
+	})
+}
+
+// match reports whether thread passes the filter.
+func (f threadFilter) match(thread ReviewThread) bool {
+	if f.UnresolvedOnly && thread.IsResolved {
+		return false
+	}
+	if len(f.Paths) > 0 && !slices.ContainsFunc(f.Paths, func(pattern string) bool {
+		return matchPathFilter(pattern, thread.Path)
+	}) {
+		return false
+	}
+	if len(f.Authors) > 0 && !slices.ContainsFunc(thread.Comments, func(c ReviewComment) bool {
+		return slices.ContainsFunc(f.Authors, func(login string) bool {
+			return strings.EqualFold(strings.TrimPrefix(login, "@"), c.Author.Login)
+		})
+	}) {
+		return false
+	}
+	if !f.Since.IsZero() && !slices.ContainsFunc(thread.Comments, func(c ReviewComment) bool {
+		return !c.CreatedAt.Before(f.Since) || !c.UpdatedAt.Before(f.Since)
+	}) {
+		return false
+	}
+	return true
+}
+
+// matchPathFilter reports whether a repo-relative path matches a --path
+// pattern: a glob as in path.Match, or a directory containing the file.
+func matchPathFilter(pattern, name string) bool {
+	pattern = strings.TrimPrefix(path.Clean(filepath.ToSlash(pattern)), "./")
+	if pattern == "." {
+		return true
+	}
+	if ok, _ := path.Match(pattern, name); ok {
+		return true
+	}
+	return strings.HasPrefix(name, pattern+"/")
+}
+
+// parseSince parses the --since flag: a date, a date and time (UTC, as in
+// comment headers), an RFC 3339 timestamp, or a duration before now, which
+// may use d for days.
+func parseSince(s string, now time.Time) (time.Time, error) {
+	for _, layout := range []string{"2006-01-02", "2006-01-02 15:04", time.RFC3339} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		if n, err := strconv.Atoi(days); err == nil && n >= 0 {
+			return now.AddDate(0, 0, -n), nil
+		}
+	}
+	if d, err := time.ParseDuration(s); err == nil && d >= 0 {
+		return now.Add(-d), nil
+	}
+	return time.Time{}, fmt.Errorf("invalid --since %q: want a date (2006-01-02), time (2006-01-02 15:04) or duration (36h, 2d)", s)
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dnr/craft/pkg/craft"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestThreadFilter(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2025, 1, d, 12, 0, 0, 0, time.UTC) }
+	thread := func(path string, resolved bool, comments ...ReviewComment) ReviewThread {
+		return ReviewThread{Path: path, IsResolved: resolved, Comments: comments}
+	}
+	comment := func(login string, created, updated time.Time) ReviewComment {
+		return ReviewComment{Author: Actor{Login: login}, CreatedAt: created, UpdatedAt: updated}
+	}
+	threads := []ReviewThread{
+		thread("main.go", false, comment("alice", day(1), day(1))),
+		thread("pkg/util.go", true, comment("bob", day(1), day(1)), comment("Alice", day(5), day(5))),
+		thread("pkg/sub/x.go", false, comment("bob", day(2), day(9))),
+		thread("docs/a.md", true, comment("carol", day(3), day(3))),
+	}
+
+	tests := []struct {
+		name   string
+		filter threadFilter
+		want   []string
+	}{
+		{"none", threadFilter{}, []string{"main.go", "pkg/util.go", "pkg/sub/x.go", "docs/a.md"}},
+		{"author", threadFilter{Authors: []string{"alice"}}, []string{"main.go", "pkg/util.go"}},
+		{"author with @", threadFilter{Authors: []string{"@carol", "nobody"}}, []string{"docs/a.md"}},
+		{"since created", threadFilter{Since: day(3)}, []string{"pkg/util.go", "pkg/sub/x.go", "docs/a.md"}},
+		{"since edited", threadFilter{Since: day(6)}, []string{"pkg/sub/x.go"}},
+		{"unresolved", threadFilter{UnresolvedOnly: true}, []string{"main.go", "pkg/sub/x.go"}},
+		{"directory", threadFilter{Paths: []string{"pkg/"}}, []string{"pkg/util.go", "pkg/sub/x.go"}},
+		{"glob", threadFilter{Paths: []string{"*.go", "docs/*.md"}}, []string{"main.go", "docs/a.md"}},
+		{"dot", threadFilter{Paths: []string{"./pkg/sub"}}, []string{"pkg/sub/x.go"}},
+		{"combined", threadFilter{Authors: []string{"bob"}, UnresolvedOnly: true}, []string{"pkg/sub/x.go"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pr := &PullRequest{ReviewThreads: append([]ReviewThread(nil), threads...)}
+			tt.filter.apply(pr)
+			var paths []string
+			for _, thread := range pr.ReviewThreads {
+				paths = append(paths, thread.Path)
+			}
+			assert.Equal(t, tt.want, paths)
+		})
+	}
+}
+
+func TestParseSince(t *testing.T) {
+	now := time.Date(2025, 3, 10, 15, 30, 0, 0, time.UTC)
+	tests := []struct {
+		in   string
+		want time.Time
+	}{
+		{"2025-03-01", time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)},
+		{"2025-03-01 09:15", time.Date(2025, 3, 1, 9, 15, 0, 0, time.UTC)},
+		{"2025-03-01T09:15:00Z", time.Date(2025, 3, 1, 9, 15, 0, 0, time.UTC)},
+		{"36h", time.Date(2025, 3, 9, 3, 30, 0, 0, time.UTC)},
+		{"2d", time.Date(2025, 3, 8, 15, 30, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		got, err := parseSince(tt.in, now)
+		require.NoError(t, err, tt.in)
+		assert.True(t, tt.want.Equal(got), "%s: got %v", tt.in, got)
+	}
+
+	for _, in := range []string{"yesterday", "-2d", "-1h", "2025-13-01"} {
+		_, err := parseSince(in, now)
+		assert.Error(t, err, in)
+	}
+}
+
+func TestParsePRArg(t *testing.T) {
+	tests := []struct {
+		in   string
+		want int
+	}{
+		{"123", 123},
+		{"https://github.com/dnr/craft/pull/123", 123},
+		{"https://github.com/DNR/Craft/pull/45/files#diff-abc", 45},
+		{"http://github.com/dnr/craft/pull/6?w=1", 6},
+		{"github.com/dnr/craft/pull/7/", 7},
+	}
+	for _, tt := range tests {
+		got, err := parsePRArg(tt.in, "dnr", "craft")
+		require.NoError(t, err, tt.in)
+		assert.Equal(t, tt.want, got, tt.in)
+	}
+
+	for _, in := range []string{
+		"abc",
+		"https://github.com/dnr/craft/issues/123",
+		"https://github.com/dnr/craft/pull/x",
+		"https://github.com/dnr/craft",
+		"https://example.com/dnr/craft/pull/1",
+		"https://github.com/other/craft/pull/1",
+	} {
+		_, err := parsePRArg(in, "dnr", "craft")
+		assert.Error(t, err, in)
+	}
+}
+
+func TestCommitReview(t *testing.T) {
+	startLine := 8
+	pr := &PullRequest{
+		Number:     7,
+		HeadRefOID: "cccc3333",
+		BaseRefOID: "base0000",
+		Commits: []Commit{
+			{OID: "aaaa1111", Headline: "First"},
+			{OID: "abab2222", Headline: "Second"},
+			{OID: "cccc3333", Headline: "Third"},
+		},
+		ReviewThreads: []ReviewThread{
+			{ID: "on-first", OriginalCommitOID: "aaaa1111", Line: 0, OriginalLine: 5, IsOutdated: true},
+			{ID: "on-second", OriginalCommitOID: "abab2222", Line: 12, OriginalLine: 10, OriginalStartLine: &startLine},
+			{ID: "on-third", OriginalCommitOID: "cccc3333", Line: 3, OriginalLine: 3},
+		},
+	}
+
+	_, err := findPRCommit(pr, "a")
+	assert.ErrorContains(t, err, "too short")
+	_, err = findPRCommit(pr, "aaAA")
+	assert.NoError(t, err)
+	_, err = findPRCommit(pr, "dddd")
+	assert.ErrorContains(t, err, "isn't a commit of PR #7")
+	pr.Commits = append(pr.Commits, Commit{OID: "abab2229"})
+	_, err = findPRCommit(pr, "abab")
+	assert.ErrorContains(t, err, "ambiguous")
+	pr.Commits = pr.Commits[:3]
+	i, err := findPRCommit(pr, "abab2")
+	require.NoError(t, err)
+	assert.Equal(t, 1, i)
+
+	assert.Equal(t, "cccc3333", pr.CheckoutOID())
+	focusCommit(pr, "abab2222")
+	assert.Equal(t, "abab2222", pr.CheckoutOID())
+	assert.Equal(t, "aaaa1111", pr.EffectiveBase())
+	require.Len(t, pr.ReviewThreads, 1)
+	thread := pr.ReviewThreads[0]
+	assert.Equal(t, "on-second", thread.ID)
+	assert.Equal(t, 10, thread.Line)
+	assert.Equal(t, &startLine, thread.StartLine)
+
+	// The first commit is reviewed against the base
+	pr.ReviewCommitOID = "aaaa1111"
+	assert.Equal(t, "base0000", pr.EffectiveBase())
+}
+
+func TestNoteBaseChange(t *testing.T) {
+	last := &PullRequest{Number: 7, BaseRefName: "main", BaseRefOID: "aaaa"}
+
+	pr := &PullRequest{Number: 7, BaseRefName: "main", BaseRefOID: "aaaa"}
+	assert.Empty(t, noteBaseChange(last, pr))
+	assert.Empty(t, pr.PreviousBaseRefOID)
+
+	pr = &PullRequest{Number: 7, BaseRefName: "main", BaseRefOID: "bbbb"}
+	assert.Equal(t, "the base branch main of PR #7 moved since the last get (aaaa..bbbb), so diffs reviewed before may have changed; 'craft diff --base-change' shows how", noteBaseChange(last, pr))
+	assert.Equal(t, "aaaa", pr.PreviousBaseRefOID)
+
+	pr = &PullRequest{Number: 7, BaseRefName: "release", BaseRefOID: "cccc"}
+	assert.Contains(t, noteBaseChange(last, pr), "PR #7 was retargeted from main to release since the last get (aaaa..cccc)")
+
+	// The last move is kept until the base moves again
+	last.PreviousBaseRefOID = "0000"
+	pr = &PullRequest{Number: 7, BaseRefName: "main", BaseRefOID: "aaaa"}
+	assert.Empty(t, noteBaseChange(last, pr))
+	assert.Equal(t, "0000", pr.PreviousBaseRefOID)
+}
+
+func TestCheckUnsentComments(t *testing.T) {
+	repo := newTestGitRepo(t, map[string]string{"main.go": "one\ntwo\n"})
+	opts := craft.SerializeOptions{FS: craft.DirFS(repo.root), VCS: repo}
+	pr := &PullRequest{ID: "PR_x", Number: 5}
+	require.NoError(t, craft.Serialize(pr, opts))
+	require.NoError(t, checkUnsentComments(repo))
+
+	// A new comment next to a malformed one still counts
+	write := func(content string) {
+		require.NoError(t, craft.WriteFile(opts.FS, "main.go", []byte(content)))
+	}
+	write("one\n// ╓───── new\n// ║ Unsent\ntwo\n// ║ stray body\n")
+	assert.ErrorContains(t, checkUnsentComments(repo), "1 new comment(s) not sent")
+
+	// A malformed comment alone might be one
+	write("one\ntwo\n// ║ stray body\n")
+	err := checkUnsentComments(repo)
+	assert.ErrorContains(t, err, "comment body line without a header")
+	assert.ErrorContains(t, err, "--force")
+
+	// And a review that can't be read at all might have some
+	require.NoError(t, os.Remove(filepath.Join(repo.root, craft.PRStateFile)))
+	assert.ErrorContains(t, checkUnsentComments(repo), "--force")
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/dnr/craft/pkg/craft"
+	"github.com/spf13/cobra"
+)
+
+var grepCmd = &cobra.Command{
+	Use:   "grep <pattern>",
+	Short: "Search the bodies of review comments",
+	Long: `Searches the review comments in the tree and PR-STATE.txt for a regular
+expression (Go syntax), and prints each comment that matches as
+
+  path:line: author: matching part of the comment
+
+like craft threads. Bodies are searched as they were written, so a match
+can span wrapped lines, and code outside comments isn't searched.
+
+Examples:
+  craft grep 'nil (check|pointer)'
+  craft grep -i todo`,
+	RunE: runGrep,
+	Args: cobra.ExactArgs(1),
+}
+
+var flagGrepIgnoreCase bool
+
+func init() {
+	grepCmd.Flags().BoolVarP(&flagGrepIgnoreCase, "ignore-case", "i", false, "Match case-insensitively")
+	rootCmd.AddCommand(grepCmd)
+}
+
+func runGrep(cmd *cobra.Command, args []string) error {
+	pattern := args[0]
+	if flagGrepIgnoreCase {
+		pattern = "(?i)" + pattern
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return fmt.Errorf("invalid pattern: %w", err)
+	}
+
+	vcs, err := DetectVCS(".")
+	if err != nil {
+		return err
+	}
+	cwd, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+
+	opts := craft.SerializeOptions{FS: craft.DirFS(vcs.Root()), VCS: vcs}
+	pr, err := craft.Deserialize(opts)
+	if errors.As(err, new(craft.ParseErrors)) {
+		logWarn("%v", err)
+	} else if err != nil {
+		return fmt.Errorf("reading PR state: %w", err)
+	}
+
+	_, err = grepComments(os.Stdout, opts.FS, pr, re, func(path string) string {
+		if rel, err := filepath.Rel(cwd, filepath.Join(vcs.Root(), path)); err == nil {
+			return rel
+		}
+		return path
+	})
+	return err
+}
+
+// maxGrepExcerpt is the most runes of a comment grep prints.
+const maxGrepExcerpt = 100
+
+// grepComments writes a line for each comment matching re: issue comments in
+// PR-STATE.txt, then comments in the files pr has threads in. displayPath maps
+// repo paths to the paths to print. Returns how many comments matched.
+func grepComments(w io.Writer, fsys fs.FS, pr *PullRequest, re *regexp.Regexp, displayPath func(string) string) (int, error) {
+	var n int
+	match := func(path string, line int, author, body string) error {
+		loc := re.FindStringIndex(body)
+		if loc == nil {
+			return nil
+		}
+		n++
+		_, err := fmt.Fprintf(w, "%s:%d: %s: %s\n", displayPath(path), line, author, grepExcerpt(body, loc))
+		return err
+	}
+
+	if len(pr.IssueComments) > 0 {
+		content, err := craft.ReadFile(fsys, craft.PRStateFile)
+		if err != nil {
+			return n, fmt.Errorf("reading PR state: %w", err)
+		}
+		lines := issueCommentLines(string(content))
+		for i, c := range pr.IssueComments {
+			if i >= len(lines) {
+				break
+			}
+			if err := match(craft.PRStateFile, lines[i], commentAuthor(c.Author.Login, c.IsNew), c.Body); err != nil {
+				return n, err
+			}
+		}
+	}
+
+	for _, path := range threadPaths(pr) {
+		content, err := craft.ReadFile(fsys, path)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue // threads on a deleted file are in PR-OUTDATED.txt or gone
+		} else if err != nil {
+			return n, fmt.Errorf("reading %s: %w", path, err)
+		}
+		_, text := craft.DecodeFile(string(content))
+		for _, t := range scanThreads(path, strings.Split(text, "\n")) {
+			for _, c := range t.comments {
+				if err := match(path, t.end+1, commentAuthor(c.author, c.isNew), c.body); err != nil {
+					return n, err
+				}
+			}
+		}
+	}
+	return n, nil
+}
+
+// commentAuthor is how threads and grep show who wrote a comment.
+func commentAuthor(login string, isNew bool) string {
+	if isNew {
+		return "new"
+	}
+	return "@" + login
+}
+
+// issueCommentLines returns the line numbers of the issue comment headers in
+// PR-STATE.txt content, in the order craft.DeserializePRState reads them.
+func issueCommentLines(content string) []int {
+	var result []int
+	for i, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if _, ok := craft.ParseHeader(line); !ok || craft.IsFileIndexHeader(line) || craft.IsCommitsHeader(line) {
+			continue
+		}
+		if fields, _ := craft.HeaderFields(line); slices.Contains(fields, "pr") {
+			continue
+		}
+		result = append(result, i+1)
+	}
+	return result
+}
+
+// grepExcerpt returns the line of body with the match at loc, shortened
+// around the match to at most maxGrepExcerpt runes.
+func grepExcerpt(body string, loc []int) string {
+	start := strings.LastIndex(body[:loc[0]], "\n") + 1
+	end := len(body)
+	if i := strings.Index(body[loc[0]:], "\n"); i >= 0 {
+		end = max(loc[0]+i, loc[1])
+	}
+	line := []rune(strings.ReplaceAll(body[start:end], "\n", " "))
+	if len(line) <= maxGrepExcerpt {
+		return strings.TrimSpace(string(line))
+	}
+	// Keep some context before the match
+	at := len([]rune(body[start:loc[0]]))
+	from := max(0, min(at-maxGrepExcerpt/4, len(line)-maxGrepExcerpt))
+	excerpt := strings.TrimSpace(string(line[from : from+maxGrepExcerpt]))
+	if from > 0 {
+		excerpt = "…" + excerpt
+	}
+	if from+maxGrepExcerpt < len(line) {
+		excerpt += "…"
+	}
+	return excerpt
+}
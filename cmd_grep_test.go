@@ -0,0 +1,63 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/dnr/craft/pkg/craft"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGrepComments(t *testing.T) {
+	state := "───── pr ─ number 5 ─ @alice ─ head abc\n" +
+		"The description\n" +
+		"\n" +
+		"───── @bob ─ at 2025-01-01 09:00 ─ v2\n" +
+		"Looks fine\n" +
+		"\n" +
+		"───── new\n" +
+		"Use y here too\n" +
+		"\n" +
+		"───── files\n" +
+		"main.go\n"
+	memfs := fstest.MapFS{
+		"main.go":         &fstest.MapFile{Data: []byte(lspTestDoc)},
+		craft.PRStateFile: &fstest.MapFile{Data: []byte(state)},
+	}
+	pr := &PullRequest{
+		ReviewThreads: []ReviewThread{{Path: "main.go"}, {Path: "gone.go"}},
+		IssueComments: []IssueComment{
+			{Author: Actor{Login: "bob"}, Body: "Looks fine"},
+			{IsNew: true, Body: "Use y here too"},
+		},
+	}
+	identity := func(path string) string { return path }
+
+	var buf strings.Builder
+	n, err := grepComments(&buf, memfs, pr, regexp.MustCompile(`use y`), identity)
+	require.NoError(t, err)
+	assert.Equal(t, 0, n)
+
+	n, err = grepComments(&buf, memfs, pr, regexp.MustCompile(`(?i)use y|^sure|done`), identity)
+	require.NoError(t, err)
+	assert.Equal(t, 4, n)
+	assert.Equal(t, "PR-STATE.txt:7: new: Use y here too\n"+
+		"main.go:4: @bob: Use y:\n"+
+		"main.go:4: @alice: Sure\n"+
+		"main.go:13: @carol: Done\n", buf.String())
+}
+
+func TestGrepExcerpt(t *testing.T) {
+	body := "First line\n" + strings.Repeat("a", 80) + " needle " + strings.Repeat("b", 80) + "\nLast"
+	re := regexp.MustCompile("needle")
+	excerpt := grepExcerpt(body, re.FindStringIndex(body))
+	assert.Contains(t, excerpt, "needle")
+	assert.True(t, strings.HasPrefix(excerpt, "…a"))
+	assert.True(t, strings.HasSuffix(excerpt, "b…"))
+	assert.Len(t, []rune(excerpt), maxGrepExcerpt+2)
+
+	assert.Equal(t, "Last", grepExcerpt(body, []int{len(body) - 4, len(body)}))
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dnr/craft/pkg/craft"
+	"github.com/spf13/cobra"
+)
+
+var listenCmd = &cobra.Command{
+	Use:   "listen",
+	Short: "Refresh the review when GitHub sends a webhook for the PR",
+	Long: `Runs a web server on localhost that takes GitHub webhook deliveries, and
+runs 'craft get' for the PR under review when one is about it: a push,
+review, comment or thread change. That keeps the files up to date without
+polling.
+
+Deliveries can come from a repository webhook pointed at the dev box, or be
+forwarded with the gh webhook extension:
+
+  gh webhook forward --repo=owner/repo --url=http://localhost:8787/ \
+    --events=pull_request,pull_request_review,pull_request_review_comment,pull_request_review_thread,issue_comment
+
+With --secret (or CRAFT_WEBHOOK_SECRET), deliveries must be signed with it.
+Events come in bursts, so the refresh waits for --debounce without any.
+Your own actions don't cause a refresh. A refresh that would lose comments
+you haven't sent is refused, as 'craft get' would refuse it, and logged.`,
+	RunE: runListen,
+	Args: cobra.NoArgs,
+}
+
+var (
+	flagListenPort     int
+	flagListenSecret   string
+	flagListenDebounce time.Duration
+)
+
+func init() {
+	listenCmd.Flags().IntVar(&flagListenPort, "port", 8787, "Port to listen on, on localhost")
+	listenCmd.Flags().StringVar(&flagListenSecret, "secret", "", "Webhook secret the deliveries are signed with (default: $CRAFT_WEBHOOK_SECRET)")
+	listenCmd.Flags().DurationVar(&flagListenDebounce, "debounce", 2*time.Second, "How long to wait for more events before refreshing")
+	rootCmd.AddCommand(listenCmd)
+}
+
+func runListen(cmd *cobra.Command, args []string) error {
+	vcs, err := DetectVCS(".")
+	if err != nil {
+		return err
+	}
+	var prNumber int
+	if state, err := readPRStateHeader(craft.SerializeOptions{FS: craft.DirFS(vcs.Root()), VCS: vcs}); err == nil && state.Number != 0 {
+		prNumber = state.Number
+	} else if prNumber, err = prNumberFromBranch(vcs); err != nil {
+		return err
+	}
+	client, _, _, err := getGitHubClientAndRepo(vcs, resolveRemote(vcs, ""))
+	if err != nil {
+		return err
+	}
+	me, err := client.FetchViewerLogin(cmd.Context())
+	if err != nil {
+		logWarn("finding your login, so your own actions refresh too: %v", err)
+	}
+
+	secret := flagListenSecret
+	if secret == "" {
+		secret = os.Getenv("CRAFT_WEBHOOK_SECRET")
+	}
+	var mu sync.Mutex // one refresh at a time
+	refresh := func() {
+		mu.Lock()
+		defer mu.Unlock()
+		logInfo("Refreshing PR #%d", prNumber)
+		if err := runGet(cmd, []string{strconv.Itoa(prNumber)}); err != nil {
+			logWarn("refreshing: %v", err)
+		}
+	}
+	handler := &webhookHandler{
+		secret:   secret,
+		prNumber: prNumber,
+		me:       me,
+		trigger:  debounce(flagListenDebounce, refresh),
+	}
+
+	addr := fmt.Sprintf("localhost:%d", flagListenPort)
+	logInfo("Listening for webhooks for PR #%d at http://%s/", prNumber, addr)
+	return http.ListenAndServe(addr, handler)
+}
+
+// webhookHandler takes GitHub webhook deliveries, and calls trigger for
+// those about PR prNumber, except ones caused by me.
+type webhookHandler struct {
+	secret   string // if set, deliveries must be signed with it
+	prNumber int
+	me       string
+	trigger  func()
+}
+
+// webhookPayload is the part of a webhook delivery's payload used to tell
+// which PR it's about.
+type webhookPayload struct {
+	Action      string `json:"action"`
+	PullRequest *struct {
+		Number int `json:"number"`
+	} `json:"pull_request"`
+	Issue *struct {
+		Number      int       `json:"number"`
+		PullRequest *struct{} `json:"pull_request"` // set if the issue is a PR
+	} `json:"issue"`
+	Sender struct {
+		Login string `json:"login"`
+	} `json:"sender"`
+}
+
+// maxWebhookPayload is GitHub's limit on the size of a delivery.
+const maxWebhookPayload = 25 << 20
+
+func (h *webhookHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "webhook deliveries are POSTs", http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxWebhookPayload))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if h.secret != "" && !validWebhookSignature(h.secret, body, r.Header.Get("X-Hub-Signature-256")) {
+		http.Error(w, "bad signature", http.StatusUnauthorized)
+		return
+	}
+	event := r.Header.Get("X-GitHub-Event")
+	if event == "ping" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	var payload webhookPayload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		http.Error(w, "parsing payload: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var number int
+	switch {
+	case payload.PullRequest != nil:
+		number = payload.PullRequest.Number
+	case payload.Issue != nil && payload.Issue.PullRequest != nil:
+		number = payload.Issue.Number
+	}
+	if number != h.prNumber || (h.me != "" && strings.EqualFold(payload.Sender.Login, h.me)) {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	logDebug("Webhook: %s %s by @%s", event, payload.Action, payload.Sender.Login)
+	h.trigger()
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// validWebhookSignature reports whether signature, an X-Hub-Signature-256
+// header, is body's HMAC with secret.
+func validWebhookSignature(secret string, body []byte, signature string) bool {
+	got, err := hex.DecodeString(strings.TrimPrefix(signature, "sha256="))
+	if err != nil || !strings.HasPrefix(signature, "sha256=") {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hmac.Equal(got, mac.Sum(nil))
+}
+
+// debounce returns a function that calls f once calls to it have stopped for
+// wait.
+func debounce(wait time.Duration, f func()) func() {
+	var mu sync.Mutex
+	var timer *time.Timer
+	return func() {
+		mu.Lock()
+		defer mu.Unlock()
+		if timer != nil {
+			timer.Stop()
+		}
+		timer = time.AfterFunc(wait, f)
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWebhookHandler(t *testing.T) {
+	var triggered int
+	h := &webhookHandler{secret: "s3cret", prNumber: 7, me: "me", trigger: func() { triggered++ }}
+	sign := func(body string) string {
+		mac := hmac.New(sha256.New, []byte("s3cret"))
+		mac.Write([]byte(body))
+		return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+	}
+	deliver := func(event, body, signature string) int {
+		req := httptest.NewRequest("POST", "/", strings.NewReader(body))
+		req.Header.Set("X-GitHub-Event", event)
+		req.Header.Set("X-Hub-Signature-256", signature)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	review := `{"action":"submitted","pull_request":{"number":7},"sender":{"login":"bob"}}`
+	assert.Equal(t, http.StatusUnauthorized, deliver("pull_request_review", review, "sha256=00"))
+	assert.Equal(t, 0, triggered)
+	assert.Equal(t, http.StatusAccepted, deliver("pull_request_review", review, sign(review)))
+	assert.Equal(t, 1, triggered)
+
+	for _, body := range []string{
+		`{"action":"synchronize","pull_request":{"number":8},"sender":{"login":"bob"}}`,
+		`{"action":"created","pull_request":{"number":7},"sender":{"login":"Me"}}`,
+		`{"action":"created","issue":{"number":7},"sender":{"login":"bob"}}`, // an issue, not the PR
+	} {
+		assert.Equal(t, http.StatusNoContent, deliver("pull_request", body, sign(body)), body)
+	}
+	comment := `{"action":"created","issue":{"number":7,"pull_request":{}},"sender":{"login":"bob"}}`
+	assert.Equal(t, http.StatusAccepted, deliver("issue_comment", comment, sign(comment)))
+	assert.Equal(t, http.StatusOK, deliver("ping", `{}`, sign(`{}`)))
+	assert.Equal(t, 2, triggered)
+}
+
+func TestDebounce(t *testing.T) {
+	var calls atomic.Int32
+	f := debounce(20*time.Millisecond, func() { calls.Add(1) })
+	f()
+	f()
+	f()
+	assert.Eventually(t, func() bool { return calls.Load() == 1 }, time.Second, 5*time.Millisecond)
+	time.Sleep(40 * time.Millisecond)
+	assert.Equal(t, int32(1), calls.Load())
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/dnr/craft/pkg/craft"
+	"github.com/spf13/cobra"
+)
+
+var localCmd = &cobra.Command{
+	Use:   "local <rev-range>",
+	Short: "Review a local range of commits, without a PR",
+	Long: `Sets up a review of local commits the way craft get does for a PR, for
+self-review before pushing or reviewing with someone at your desk. The range
+is A..B, or A for A..HEAD; as on GitHub, the diff is from the merge base of A
+and B. B must be checked out.
+
+New comments can be written in the files as usual, and 'craft export' turns
+them into a report. Once a PR for the branch is open with the same head,
+'craft send --pr N' sends them to it, and then removes them from the files.
+
+Nothing is committed: 'craft clear --commit=false' removes the review.
+
+Examples:
+  craft local main          # Review what this branch adds to main
+  craft local HEAD~3..HEAD  # Review the last three commits`,
+	RunE: runLocal,
+	Args: cobra.ExactArgs(1),
+}
+
+var (
+	flagLocalForce bool
+	flagLocalWidth int
+)
+
+func init() {
+	localCmd.Flags().BoolVar(&flagLocalForce, "force", false, "Set up the review even with uncommitted changes")
+	localCmd.Flags().IntVar(&flagLocalWidth, "width", 0, "Line width for wrapping comments (default: from config or 80)")
+	rootCmd.AddCommand(localCmd)
+}
+
+func runLocal(cmd *cobra.Command, args []string) error {
+	vcs, err := DetectVCS(".")
+	if err != nil {
+		return err
+	}
+	gitRepo, ok := vcs.(*GitRepo)
+	if !ok {
+		return fmt.Errorf("craft local is only supported in git repositories")
+	}
+
+	if !flagLocalForce {
+		hasChanges, err := vcs.HasUncommittedChanges()
+		if err != nil {
+			return fmt.Errorf("checking for uncommitted changes: %w", err)
+		}
+		if hasChanges {
+			return fmt.Errorf("uncommitted changes detected; use --force to review anyway")
+		}
+	}
+
+	base, head, err := gitRepo.ResolveLocalRange(args[0])
+	if err != nil {
+		return err
+	}
+	current, err := gitRepo.run("rev-parse", "HEAD")
+	if err != nil {
+		return err
+	}
+	if head != current {
+		return fmt.Errorf("%s isn't checked out; check it out first", head[:12])
+	}
+	commits, err := gitRepo.LocalCommits(base, head)
+	if err != nil {
+		return fmt.Errorf("listing commits: %w", err)
+	}
+	if len(commits) == 0 {
+		return fmt.Errorf("no commits in %s", args[0])
+	}
+
+	pr := &PullRequest{
+		IsLocal:    true,
+		BaseRefOID: base,
+		HeadRefOID: head,
+		Commits:    commits,
+	}
+
+	logStart("Serializing review state")
+	opts, _, err := getSerializeOptions(vcs, flagLocalWidth)
+	if err != nil {
+		return err
+	}
+	if err := craft.Serialize(pr, opts); err != nil {
+		return fmt.Errorf("serializing: %w", err)
+	}
+	logEnd("done")
+
+	logInfo("Ready for review of %d commit(s) since %s", len(commits), base[:12])
+	logInfo("  'craft diff' shows the changes; add comments with '───── new' headers")
+	logInfo("  'craft export' writes them up, 'craft send --pr N' sends them once there's a PR")
+	return nil
+}
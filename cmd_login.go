@@ -0,0 +1,137 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var loginCmd = &cobra.Command{
+	Use:   "login --stdin",
+	Short: "Save a GitHub token for machines without a keyring",
+	Long: `Reads a GitHub token from stdin and saves it in a file only you can read,
+token in the craft config directory ($XDG_CONFIG_HOME/craft or
+~/.config/craft).
+
+craft normally uses GH_TOKEN or GITHUB_TOKEN, or the token gh keeps in the
+system keyring. On headless servers and in CI there may be no keyring; a
+saved token is used before gh's, and after the environment variables.
+
+Examples:
+  gh auth token | craft login --stdin   Save gh's token
+  craft login --stdin < token.txt       Save a token from a file
+  craft login --delete                  Remove the saved token`,
+	RunE: runLogin,
+	Args: cobra.NoArgs,
+}
+
+var (
+	flagLoginStdin  bool
+	flagLoginDelete bool
+)
+
+func init() {
+	loginCmd.Flags().BoolVar(&flagLoginStdin, "stdin", false, "Read the token from stdin")
+	loginCmd.Flags().BoolVar(&flagLoginDelete, "delete", false, "Remove the saved token")
+	loginCmd.MarkFlagsMutuallyExclusive("stdin", "delete")
+	rootCmd.AddCommand(loginCmd)
+}
+
+func runLogin(cmd *cobra.Command, args []string) error {
+	path := tokenFile()
+	if path == "" {
+		return fmt.Errorf("no home directory to save the token in")
+	}
+
+	if flagLoginDelete {
+		if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		logInfo("Removed %s", path)
+		return nil
+	}
+
+	if !flagLoginStdin {
+		return fmt.Errorf("pass the token on stdin with --stdin (e.g. 'gh auth token | craft login --stdin')")
+	}
+	data, err := io.ReadAll(cmd.InOrStdin())
+	if err != nil {
+		return fmt.Errorf("reading token: %w", err)
+	}
+	token := strings.TrimSpace(string(data))
+	if token == "" {
+		return fmt.Errorf("no token on stdin")
+	}
+	if strings.ContainsAny(token, " \t\n") {
+		return fmt.Errorf("token on stdin has spaces or more than one line")
+	}
+
+	if err := writeTokenFile(path, token); err != nil {
+		return err
+	}
+	logInfo("Saved token to %s", path)
+	return nil
+}
+
+// tokenFile returns the path of the token saved by 'craft login', or "" if
+// there's no config directory.
+func tokenFile() string {
+	dir := userConfigDir()
+	if dir == "" {
+		return ""
+	}
+	return filepath.Join(dir, "token")
+}
+
+// readTokenFile returns the token saved at path, or "" if there isn't one.
+// Like ssh with private keys, it refuses a file others can read.
+func readTokenFile(path string) (string, error) {
+	if path == "" {
+		return "", nil
+	}
+	fi, err := os.Stat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", nil
+	} else if err != nil {
+		return "", err
+	}
+	// Windows has no permission bits; the file is under the user's profile
+	if runtime.GOOS != "windows" && fi.Mode().Perm()&0077 != 0 {
+		return "", fmt.Errorf("%s can be read by others, run 'chmod 600 %s'", path, path)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// writeTokenFile saves token at path, readable only by the user. It writes a
+// temporary file (which CreateTemp makes 0600) and renames it over path, so
+// the token is never readable by others, even briefly.
+func writeTokenFile(path, token string) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(dir, ".token-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name()) // fails harmlessly after the rename
+	if _, err := f.WriteString(token + "\n"); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTokenFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "craft", "token")
+
+	token, err := readTokenFile(path)
+	require.NoError(t, err)
+	assert.Empty(t, token, "missing file")
+
+	require.NoError(t, writeTokenFile(path, "ghp_abc"))
+	token, err = readTokenFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "ghp_abc", token)
+
+	require.NoError(t, writeTokenFile(path, "ghp_new"))
+	token, err = readTokenFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "ghp_new", token, "overwrites")
+
+	if runtime.GOOS != "windows" {
+		fi, err := os.Stat(path)
+		require.NoError(t, err)
+		assert.Equal(t, os.FileMode(0600), fi.Mode().Perm())
+
+		require.NoError(t, os.Chmod(path, 0644))
+		_, err = readTokenFile(path)
+		assert.ErrorContains(t, err, "chmod 600")
+	}
+}
+
+func TestLoginStdin(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("GH_TOKEN", "")
+	t.Setenv("GITHUB_TOKEN", "")
+	defer func() { flagLoginStdin, flagLoginDelete = false, false }()
+
+	flagLoginStdin = true
+	loginCmd.SetIn(strings.NewReader("ghp_fromstdin\n"))
+	require.NoError(t, runLogin(loginCmd, nil))
+
+	token, err := getGitHubToken()
+	require.NoError(t, err)
+	assert.Equal(t, "ghp_fromstdin", token)
+
+	t.Setenv("GH_TOKEN", "ghp_env")
+	token, err = getGitHubToken()
+	require.NoError(t, err)
+	assert.Equal(t, "ghp_env", token, "environment wins")
+
+	loginCmd.SetIn(strings.NewReader("two words"))
+	assert.Error(t, runLogin(loginCmd, nil))
+	loginCmd.SetIn(strings.NewReader(" \n"))
+	assert.Error(t, runLogin(loginCmd, nil))
+
+	flagLoginStdin, flagLoginDelete = false, true
+	require.NoError(t, runLogin(loginCmd, nil))
+	assert.NoFileExists(t, tokenFile())
+}
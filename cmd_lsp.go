@@ -0,0 +1,32 @@
+package main
+
+import (
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+var lspCmd = &cobra.Command{
+	Use:   "lsp",
+	Short: "Run a language server for craft threads",
+	Long: `Runs a Language Server Protocol server on stdin and stdout, for editors
+with LSP support.
+
+Each thread in an open file is shown as a diagnostic on the code it's on
+(resolved threads as hints), and malformed craft lines as errors. Code actions
+add a new reply to a thread, or apply the latest suggestion in it. Edits go
+through the editor; run 'craft send' as usual to send them.
+
+Diagnostics are refreshed when PR-STATE.txt changes, if the editor reports
+watched file changes.`,
+	RunE: runLSP,
+	Args: cobra.NoArgs,
+}
+
+func init() {
+	rootCmd.AddCommand(lspCmd)
+}
+
+func runLSP(cmd *cobra.Command, args []string) error {
+	return newLSPServer(os.Stdin, os.Stdout).Serve()
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/dnr/craft/pkg/craft"
+	"github.com/spf13/cobra"
+)
+
+var mentionsCmd = &cobra.Command{
+	Use:   "mentions",
+	Short: "List users that can be @mentioned in the current repo",
+	Long: `Prints the logins of all users that can be @mentioned in the GitHub repo,
+one per line. This is intended as a completion source for editors.
+
+Example usage in vim:
+  :let g:craft_mentions = systemlist('craft mentions')`,
+	RunE: runMentions,
+	Args: cobra.NoArgs,
+}
+
+var flagMentionsRemote string
+
+func init() {
+	mentionsCmd.Flags().StringVar(&flagMentionsRemote, "remote", "", "Git remote name (default: from config or 'origin')")
+	rootCmd.AddCommand(mentionsCmd)
+}
+
+func runMentions(cmd *cobra.Command, args []string) error {
+	vcs, err := DetectVCS(".")
+	if err != nil {
+		return err
+	}
+
+	remote := resolveRemote(vcs, flagMentionsRemote)
+	client, owner, repo, err := getGitHubClientAndRepo(vcs, remote)
+	if err != nil {
+		return err
+	}
+
+	users, err := client.FetchMentionableUsers(cmd.Context(), owner, repo)
+	if err != nil {
+		return err
+	}
+	sort.Slice(users, func(i, j int) bool {
+		return strings.ToLower(users[i]) < strings.ToLower(users[j])
+	})
+	for _, u := range users {
+		fmt.Println(u)
+	}
+	return nil
+}
+
+// unknownMentions returns a warning for each mention that doesn't match a
+// known login (case-insensitively), with a suggestion if there's a close match.
+func unknownMentions(mentions, known []string) []string {
+	knownLower := make(map[string]bool, len(known))
+	for _, k := range known {
+		knownLower[strings.ToLower(k)] = true
+	}
+
+	var warnings []string
+	for _, m := range mentions {
+		if knownLower[strings.ToLower(m)] {
+			continue
+		}
+		warning := fmt.Sprintf("@%s is not a known user in this repo", m)
+		if s := closestLogin(m, known); s != "" {
+			warning += fmt.Sprintf(" (did you mean @%s?)", s)
+		}
+		warnings = append(warnings, warning)
+	}
+	return warnings
+}
+
+// closestLogin returns the known login with the smallest edit distance to
+// login, or "" if nothing is reasonably close.
+func closestLogin(login string, known []string) string {
+	best, bestDist := "", len(login)/3+2 // only suggest reasonably close matches
+	for _, k := range known {
+		if d := editDistance(strings.ToLower(login), strings.ToLower(k)); d < bestDist {
+			best, bestDist = k, d
+		}
+	}
+	return best
+}
+
+// editDistance computes the Levenshtein distance between two strings.
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
+
+// warnUnknownMentions checks the review's @mentions against the repo's
+// mentionable users and prints a warning for each one that looks like a typo.
+func warnUnknownMentions(ctx context.Context, client GitHubAPI, owner, repo string, review *craft.ReviewToSend) error {
+	mentions := review.Mentions()
+	if len(mentions) == 0 {
+		return nil
+	}
+
+	logStart("Checking @mentions")
+	known, err := client.FetchMentionableUsers(ctx, owner, repo)
+	if err != nil {
+		logEnd("failed")
+		return err
+	}
+	warnings := unknownMentions(mentions, known)
+	if len(warnings) == 0 {
+		logEnd("ok")
+		return nil
+	}
+	logEnd("found unknown users!")
+	for _, w := range warnings {
+		logWarn("%s", w)
+	}
+	return nil
+}
@@ -0,0 +1,16 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnknownMentions(t *testing.T) {
+	known := []string{"alice", "Bob", "carolyn"}
+	warnings := unknownMentions([]string{"alice", "bob", "alcie", "zed"}, known)
+	assert.Equal(t, []string{
+		"@alcie is not a known user in this repo (did you mean @alice?)",
+		"@zed is not a known user in this repo",
+	}, warnings)
+}
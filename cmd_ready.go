@@ -0,0 +1,145 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"slices"
+	"strings"
+
+	"github.com/dnr/craft/pkg/craft"
+	"github.com/spf13/cobra"
+)
+
+var readyCmd = &cobra.Command{
+	Use:   "ready [PR]",
+	Short: "Mark a draft PR ready for review",
+	Long: `Takes a PR out of draft, so reviewers are asked for reviews.
+
+The PR is the one given (a number or URL), or else the one in PR-STATE.txt,
+or the pr-N branch. PR-STATE.txt is updated if it's for that PR.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runSetDraft(cmd, args, false)
+	},
+	Args:              cobra.MaximumNArgs(1),
+	ValidArgsFunction: completePRNumbers,
+}
+
+var draftCmd = &cobra.Command{
+	Use:   "draft [PR]",
+	Short: "Convert a PR to a draft",
+	Long: `Converts a PR back to a draft, while it's not ready for review.
+
+The PR is the one given (a number or URL), or else the one in PR-STATE.txt,
+or the pr-N branch. PR-STATE.txt is updated if it's for that PR.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runSetDraft(cmd, args, true)
+	},
+	Args:              cobra.MaximumNArgs(1),
+	ValidArgsFunction: completePRNumbers,
+}
+
+func init() {
+	rootCmd.AddCommand(readyCmd)
+	rootCmd.AddCommand(draftCmd)
+}
+
+func runSetDraft(cmd *cobra.Command, args []string, draft bool) error {
+	vcs, err := DetectVCS(".")
+	if err != nil {
+		return err
+	}
+	remote := resolveRemote(vcs, "")
+	client, owner, repo, err := getGitHubClientAndRepo(vcs, remote)
+	if err != nil {
+		return err
+	}
+	opts := craft.SerializeOptions{FS: craft.DirFS(vcs.Root()), VCS: vcs}
+
+	var prNumber int
+	if len(args) == 1 {
+		if prNumber, err = parsePRArg(args[0], owner, repo); err != nil {
+			return err
+		}
+	} else if state, err := readPRStateHeader(opts); err == nil && state.Number != 0 {
+		prNumber = state.Number
+	} else if prNumber, err = prNumberFromBranch(vcs); err != nil {
+		return err
+	}
+
+	ctx := cmd.Context()
+	id, isDraft, err := client.FetchPRDraft(ctx, owner, repo, prNumber)
+	if err != nil {
+		return err
+	}
+	if isDraft == draft {
+		if draft {
+			logInfo("PR #%d is already a draft", prNumber)
+		} else {
+			logInfo("PR #%d is already ready for review", prNumber)
+		}
+	} else if draft {
+		logStart("Converting PR #%d to a draft", prNumber)
+		if err := client.ConvertToDraft(ctx, id); err != nil {
+			return fmt.Errorf("converting to draft: %w", err)
+		}
+		logEnd("done")
+	} else {
+		logStart("Marking PR #%d ready for review", prNumber)
+		if err := client.MarkReadyForReview(ctx, id); err != nil {
+			return fmt.Errorf("marking ready for review: %w", err)
+		}
+		logEnd("done")
+	}
+
+	if err := setPRStateDraft(opts, prNumber, draft); err != nil {
+		logWarn("updating %s: %v", craft.PRStateFile, err)
+	}
+	return nil
+}
+
+// readPRStateHeader reads the metadata of PR-STATE.txt, without the files'
+// threads.
+func readPRStateHeader(opts craft.SerializeOptions) (*PullRequest, error) {
+	content, err := craft.ReadFile(opts.FS, craft.PRStateFile)
+	if err != nil {
+		return nil, err
+	}
+	pr := &PullRequest{}
+	if err := craft.DeserializePRState(opts, pr, string(content)); err != nil {
+		return nil, err
+	}
+	return pr, nil
+}
+
+// setPRStateDraft adds or removes the DRAFT field of PR-STATE.txt's
+// metadata header, if it's for PR number. Only that line is changed.
+func setPRStateDraft(opts craft.SerializeOptions, number int, draft bool) error {
+	content, err := craft.ReadFile(opts.FS, craft.PRStateFile)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	first, rest, _ := strings.Cut(string(content), "\n")
+	fields, ok := craft.HeaderFields(strings.TrimSpace(first))
+	if !ok || !slices.Contains(fields, "pr") || !slices.Contains(fields, fmt.Sprintf("number %d", number)) {
+		return nil
+	}
+	i := slices.Index(fields, craft.DraftPRField)
+	switch {
+	case draft && i < 0:
+		// Before the head, where serializePRState puts it
+		at := slices.IndexFunc(fields, func(f string) bool { return strings.HasPrefix(f, "head ") })
+		if at < 0 {
+			at = len(fields)
+		}
+		fields = slices.Insert(fields, at, craft.DraftPRField)
+	case !draft && i >= 0:
+		fields = slices.Delete(fields, i, i+1)
+	default:
+		return nil
+	}
+	first = craft.HeaderStart + " " + strings.Join(fields, craft.HeaderFieldSep)
+	return craft.WriteFile(opts.FS, craft.PRStateFile, []byte(first+"\n"+rest))
+}
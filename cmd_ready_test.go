@@ -0,0 +1,36 @@
+package main
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/dnr/craft/pkg/craft"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetPRStateDraft(t *testing.T) {
+	const rest = "\nThe description.\n\n───── @bob ─ at 2025-01-15 12:00 ─ ic kwDOabc\nA comment\n"
+	const open = "───── pr ─ number 7 ─ @alice ─ pr kwDOabc ─ head abc123 ─ v2"
+	const draft = "───── pr ─ number 7 ─ @alice ─ pr kwDOabc ─ DRAFT ─ head abc123 ─ v2"
+
+	memfs := fstest.MapFS{craft.PRStateFile: &fstest.MapFile{Data: []byte(open + "\n" + rest)}}
+	opts := craft.SerializeOptions{FS: memfs}
+
+	require.NoError(t, setPRStateDraft(opts, 7, true))
+	assert.Equal(t, draft+"\n"+rest, string(memfs[craft.PRStateFile].Data))
+	pr, err := readPRStateHeader(opts)
+	require.NoError(t, err)
+	assert.True(t, pr.IsDraft)
+
+	require.NoError(t, setPRStateDraft(opts, 7, true), "already a draft")
+	assert.Equal(t, draft+"\n"+rest, string(memfs[craft.PRStateFile].Data))
+
+	require.NoError(t, setPRStateDraft(opts, 8, false), "another PR")
+	assert.Equal(t, draft+"\n"+rest, string(memfs[craft.PRStateFile].Data))
+
+	require.NoError(t, setPRStateDraft(opts, 7, false))
+	assert.Equal(t, open+"\n"+rest, string(memfs[craft.PRStateFile].Data))
+
+	assert.NoError(t, setPRStateDraft(craft.SerializeOptions{FS: fstest.MapFS{}}, 7, true), "no PR-STATE.txt")
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"slices"
+	"strings"
+	"syscall"
+
+	"github.com/dnr/craft/pkg/craft"
+	"github.com/spf13/cobra"
+)
+
+var resolveCmd = &cobra.Command{
+	Use:   "resolve --applied",
+	Short: "Mark threads resolved in the files",
+	Long: `Adds "resolved" to the headers of threads, so the next 'craft send' resolves
+them on GitHub.
+
+With --applied, that's the threads 'craft get' found the suggestion of
+applied to the code, which have "applied" in their headers.
+
+Examples:
+  craft resolve --applied            Resolve threads with applied suggestions
+  craft resolve --applied --dry-run  Show which files would change`,
+	RunE: runResolve,
+	Args: cobra.NoArgs,
+}
+
+var (
+	flagResolveApplied bool
+	flagResolveDryRun  bool
+)
+
+func init() {
+	resolveCmd.Flags().BoolVar(&flagResolveApplied, "applied", false, "Resolve the threads whose suggestion was applied")
+	resolveCmd.Flags().BoolVar(&flagResolveDryRun, "dry-run", false, "Show what would be changed without modifying files")
+	rootCmd.AddCommand(resolveCmd)
+}
+
+func runResolve(cmd *cobra.Command, args []string) error {
+	if !flagResolveApplied {
+		return fmt.Errorf("say which threads to resolve: --applied")
+	}
+	vcs, err := DetectVCS(".")
+	if err != nil {
+		return err
+	}
+	opts := craft.SerializeOptions{FS: craft.DirFS(vcs.Root()), VCS: vcs}
+
+	files, err := craft.ListFiles(opts)
+	if err != nil {
+		return fmt.Errorf("listing files: %w", err)
+	}
+	files = append(files, craft.OutdatedFile)
+	var total int
+	for _, path := range files {
+		content, err := craft.ReadFile(opts.FS, path)
+		if err != nil {
+			if errors.Is(err, syscall.EISDIR) || errors.Is(err, fs.ErrNotExist) {
+				continue // submodules, or no PR-OUTDATED.txt
+			}
+			return fmt.Errorf("reading %s: %w", path, err)
+		}
+		resolved, n := resolveAppliedContent(string(content), path)
+		if n == 0 {
+			continue
+		}
+		total += n
+		if flagResolveDryRun {
+			fmt.Printf("Would resolve %d thread(s) in %s\n", n, path)
+			continue
+		}
+		if err := craft.WriteFile(opts.FS, path, []byte(resolved)); err != nil {
+			return fmt.Errorf("writing %s: %w", path, err)
+		}
+		fmt.Printf("Resolved %d thread(s) in %s\n", n, path)
+	}
+	if total == 0 {
+		fmt.Println("No threads with applied suggestions to resolve.")
+	} else if !flagResolveDryRun {
+		fmt.Println("Run 'craft send' to resolve them on GitHub.")
+	}
+	return nil
+}
+
+// resolveAppliedContent adds "resolved" after "applied" in the craft
+// headers of a file that don't have it, leaving everything else as it is.
+// Returns the new content and how many threads were resolved.
+func resolveAppliedContent(content, path string) (string, int) {
+	lines := strings.Split(content, "\n")
+	var n int
+	for i, parsed := range craft.ParseCraftLines(lines, craft.GetCommentStyle(path).LinePrefix) {
+		if parsed.Box != craft.BoxThread && parsed.Box != craft.BoxReply {
+			continue
+		}
+		fields, ok := craft.HeaderFields(strings.TrimSpace(parsed.Content))
+		if !ok || !slices.Contains(fields, craft.AppliedField) || slices.Contains(fields, "resolved") {
+			continue
+		}
+		sep := craft.HeaderFieldSep
+		if parsed.ASCII && !strings.Contains(lines[i], craft.HeaderStart) {
+			sep = craft.ASCIIFieldSep
+		}
+		applied := sep + craft.AppliedField
+		lines[i] = strings.Replace(lines[i], applied, applied+sep+"resolved", 1)
+		if parsed.Box == craft.BoxThread {
+			n++
+		}
+	}
+	return strings.Join(lines, "\n"), n
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/dnr/craft/pkg/craft"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolveAppliedContent(t *testing.T) {
+	sep := craft.HeaderFieldSep
+	content := strings.Join([]string{
+		"func main() {",
+		"// " + craft.BoxThread + craft.HeaderStart + " alice" + sep + "applied",
+		"// " + craft.BoxBody + "Try this",
+		"// " + craft.BoxThread + craft.HeaderStart + " bob" + sep + "applied" + sep + "resolved",
+		"// " + craft.BoxBody + "Already resolved",
+		"// " + craft.BoxThread + craft.HeaderStart + " carol",
+		"// " + craft.BoxBody + "Not applied",
+		"}",
+	}, "\n")
+
+	got, n := resolveAppliedContent(content, "main.go")
+	assert.Equal(t, 1, n)
+	lines := strings.Split(got, "\n")
+	assert.Equal(t, "// "+craft.BoxThread+craft.HeaderStart+" alice"+sep+"applied"+sep+"resolved", lines[1])
+	assert.Equal(t, strings.Split(content, "\n")[3:], lines[3:])
+
+	again, n := resolveAppliedContent(got, "main.go")
+	require.Equal(t, 0, n)
+	assert.Equal(t, got, again)
+
+	// An ASCII header keeps its separators
+	ascii := "// " + craft.ASCIIThread + craft.ASCIIHeaderStart + " alice" + craft.ASCIIFieldSep + "applied\n// " + craft.ASCIIBody + " Try this"
+	got, n = resolveAppliedContent(ascii, "main.go")
+	assert.Equal(t, 1, n)
+	assert.Equal(t, "// "+craft.ASCIIThread+craft.ASCIIHeaderStart+" alice - applied - resolved", strings.Split(got, "\n")[0])
+}
@@ -1,8 +1,15 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"time"
 
+	"github.com/dnr/craft/pkg/craft"
 	"github.com/spf13/cobra"
 )
 
@@ -11,76 +18,169 @@ var sendCmd = &cobra.Command{
 	Short: "Send review comments to GitHub",
 	Long: `Reads review comments from source files and sends new ones to GitHub.
 
-Must be run from a pr-N branch created by 'craft get'.
+Must be run from a pr-N branch created by 'craft get', or with --pr for a
+review set up by 'craft local'. A local review is sent to that PR if its head
+is the commit reviewed, and then its comments are removed from the files.
+
+Existing threads with "resolved" added to their headers are resolved.
+Existing PR-level comments edited in PR-STATE.txt are edited on GitHub, and
+those with "delete" added to their headers are deleted. The body of your
+pending review, marked "pending" there, is updated if it was edited.
+
+After 'craft get --in-place', only replies can be sent and threads resolved,
+since your files aren't the PR's code. The review is then serialized again
+onto your files as they are, and nothing is committed.
+
+With --description, the title and body edited in PR-DESCRIPTION.md are sent
+instead of comments, as the PR's description. That takes being the PR's
+author or having write access, and is refused if the description was edited
+on GitHub since 'craft get' (--force replaces it anyway).
 
 Examples:
   craft send                    # Send as comment
   craft send --approve          # Send and approve
   craft send --request-changes  # Send and request changes
-  craft send --dry-run          # Show what would be sent`,
+  craft send --dry-run          # Show what would be sent
+  craft send --pr 123           # Send a craft local review to PR #123
+  craft send --description      # Update the PR's title and body`,
 	RunE: runSend,
 }
 
 var (
 	flagSendDryRun               bool
+	flagSendRender               bool
 	flagSendApprove              bool
 	flagSendRequestChanges       bool
 	flagSendDiscardPendingReview bool
 	flagSendPending              bool
 	flagSendReplyOnly            bool
+	flagSendWidth                int
+	flagSendFullScan             bool
+	flagSendSkipDiffCheck        bool
+	flagSendNoVerify             bool
+	flagSendPR                   int
+	flagSendForce                bool
+	flagSendNoCommit             bool
+	flagSendNoSign               bool
+	flagSendDescription          bool
 )
 
 func init() {
-	sendCmd.Flags().BoolVar(&flagSendDryRun, "dry-run", false, "Print what would be sent without sending")
+	sendCmd.Flags().BoolVar(&flagSendDryRun, "dry-run", false, "Print what would be sent without sending, offline")
+	sendCmd.Flags().BoolVar(&flagSendRender, "render", false, "With --dry-run (implied), show comments' markdown rendered, and suggestions as diffs")
 	sendCmd.Flags().BoolVar(&flagSendApprove, "approve", false, "Submit review as approval")
 	sendCmd.Flags().BoolVar(&flagSendRequestChanges, "request-changes", false, "Submit review requesting changes")
 	sendCmd.Flags().BoolVar(&flagSendDiscardPendingReview, "discard-pending-review", false, "Discard existing pending review if one exists (required when adding new threads)")
 	sendCmd.Flags().BoolVar(&flagSendPending, "pending", false, "Leave review in pending state (don't submit)")
 	sendCmd.Flags().BoolVar(&flagSendReplyOnly, "reply-only", false, "Send only replies to existing threads (skip code change check, skip re-serialize)")
+	sendCmd.Flags().IntVar(&flagSendWidth, "width", 0, "Line width for wrapping comments when re-serializing (default: from config or 80)")
+	sendCmd.Flags().BoolVar(&flagSendFullScan, "full-scan", false, "Look for comments in every file, not just those indexed in PR-STATE.txt or changed")
+	sendCmd.Flags().BoolVar(&flagSendSkipDiffCheck, "skip-diff-check", false, "Don't check that new threads are on lines in the PR diff")
+	sendCmd.Flags().BoolVar(&flagSendNoCommit, "no-commit", false, "Don't commit the updated files (default: from the autoCommit setting)")
+	sendCmd.Flags().BoolVar(&flagSendNoSign, "no-sign", false, "Don't sign the commit, even if git or jj is configured to")
+	sendCmd.Flags().BoolVar(&flagSendNoVerify, "no-verify", false, "Don't run the pre-send hook or check new comments for problems")
+	sendCmd.Flags().IntVar(&flagSendPR, "pr", 0, "PR number to send to (default: from the pr-N branch)")
+	sendCmd.Flags().BoolVar(&flagSendForce, "force", false, "Send to a merged or closed PR, or replace a description edited on GitHub")
+	sendCmd.Flags().BoolVar(&flagSendDescription, "description", false, "Send the title and body edited in PR-DESCRIPTION.md, not comments")
 	sendCmd.MarkFlagsMutuallyExclusive("approve", "request-changes", "pending")
 }
 
 func runSend(cmd *cobra.Command, args []string) error {
+	if flagSendRender {
+		flagSendDryRun = true
+	}
+
 	// Detect VCS
 	vcs, err := DetectVCS(".")
 	if err != nil {
 		return err
 	}
 
-	// Get current branch to determine PR number
-	prNumber, err := prNumberFromBranch(vcs)
+	// Deserialize PR state from files
+	logStart("Reading PR state from files")
+	opts, cfg, err := getSerializeOptions(vcs, flagSendWidth)
 	if err != nil {
 		return err
 	}
-	fmt.Printf("PR #%d\n", prNumber)
-
-	// Deserialize PR state from files
-	fmt.Print("Reading PR state from files... ")
-	opts := SerializeOptions{FS: DirFS(vcs.Root()), VCS: vcs}
-	pr, err := Deserialize(opts)
-	if err != nil {
+	opts.FullScan = flagSendFullScan
+	opts.Originals = cachedOriginals(opts)
+	pr, err := craft.Deserialize(opts)
+	if errors.As(err, new(craft.ParseErrors)) {
+		logEnd("failed!")
+		return fmt.Errorf("%w\nfix or remove these lines so no comments are lost", err)
+	} else if err != nil {
 		return fmt.Errorf("deserializing: %w", err)
 	}
-	fmt.Println("done")
+	logEnd("done")
 
-	// Require that craft get was run first
-	if pr.ID == "" {
+	// Require that craft get (or craft local) was run first
+	if pr.ID == "" && !pr.IsLocal {
 		return fmt.Errorf("PR-STATE.txt missing PR ID; run 'craft get' first")
 	}
+	// Review comments on a merged or closed PR are usually a mistake
+	if pr.IsClosed() && !flagSendForce && !flagSendDryRun {
+		return fmt.Errorf("PR is %s; use --force to send to it anyway", strings.ToLower(pr.State))
+	}
+
+	// Determine PR number: a local review has none until one is given
+	prNumber := flagSendPR
+	if prNumber == 0 && pr.InPlace {
+		prNumber = pr.Number
+	}
+	if prNumber == 0 && !pr.IsLocal {
+		if prNumber, err = prNumberFromBranch(vcs); err != nil {
+			return err
+		}
+	}
+	if prNumber == 0 && !flagSendDryRun {
+		return fmt.Errorf("this is a review from 'craft local'; use --pr to send it to a PR")
+	}
+	if prNumber != 0 {
+		logInfo("PR #%d", prNumber)
+	}
 
-	// Check for non-craft code changes (skip in reply-only mode)
-	if pr.HeadRefOID != "" && !flagSendReplyOnly {
-		fmt.Print("Checking for code changes... ")
-		if err := CheckForNonCraftChanges(vcs, pr.HeadRefOID); err != nil {
-			fmt.Println("found!")
+	if flagSendDescription {
+		return sendDescription(cmd.Context(), vcs, opts, prNumber, !pr.InPlace)
+	}
+
+	// Check for non-craft code changes (skip in reply-only mode, and in place,
+	// where they're the author's work)
+	if pr.HeadRefOID != "" && !flagSendReplyOnly && !pr.InPlace {
+		logStart("Checking for code changes")
+		if err := CheckForNonCraftChanges(vcs, pr.CheckoutOID()); err != nil {
+			logEnd("found!")
 			return err
 		}
-		fmt.Println("ok")
+		logEnd("ok")
 	}
 
 	// Collect new comments
-	review, err := CollectNewComments(pr)
-	if err != nil {
+	// Keep showing resolved threads if get --include-resolved was used
+	opts.HideResolved = !cfg.IncludeResolved && pr.ResolvedThreadCount() == 0
+	collectOpts := craft.CollectOptions{Snippets: cfg.Snippets}
+	if !flagSendSkipDiffCheck && !pr.InPlace {
+		collectOpts.VCS = vcs
+	}
+	if !flagSendNoVerify {
+		collectOpts.Lint = &cfg.Lint
+	}
+	// Only your own PR-level comments can be changed; a dry run stays
+	// offline, so doesn't check
+	if !flagSendDryRun && slices.ContainsFunc(pr.IssueComments, craft.IsIssueCommentChanged) {
+		client, _, _, err := getGitHubClientAndRepo(vcs, resolveRemote(vcs, ""))
+		if err != nil {
+			return err
+		}
+		if collectOpts.Viewer, err = client.FetchViewerLogin(cmd.Context()); err != nil {
+			return fmt.Errorf("getting your login: %w", err)
+		}
+	}
+	review, err := craft.CollectNewComments(pr, collectOpts)
+	if errors.As(err, new(craft.LintErrors)) {
+		return fmt.Errorf("%w\nfix them, or use --no-verify to send anyway", err)
+	} else if errors.As(err, new(craft.DiffErrors)) {
+		return fmt.Errorf("%w\nmove them to changed lines (or within 3 lines of them), or use --skip-diff-check", err)
+	} else if err != nil {
 		return err
 	}
 
@@ -88,6 +188,10 @@ func runSend(cmd *cobra.Command, args []string) error {
 	if flagSendReplyOnly && len(review.NewThreads) > 0 {
 		return fmt.Errorf("--reply-only: found %d new thread(s); only replies to existing threads are allowed in this mode", len(review.NewThreads))
 	}
+	// In place, lines are in the working copy, not the PR
+	if pr.InPlace && len(review.NewThreads) > 0 {
+		return fmt.Errorf("found %d new thread(s); after 'craft get --in-place', only replies to existing threads can be sent", len(review.NewThreads))
+	}
 
 	// Set review event
 	if flagSendApprove {
@@ -99,14 +203,45 @@ func runSend(cmd *cobra.Command, args []string) error {
 	}
 
 	if review.IsEmpty() && review.ReviewEvent != "APPROVE" {
-		fmt.Println("No new comments to send.")
+		logInfo("No new comments to send.")
 		return nil
 	}
 
-	fmt.Printf("Found %s\n", review.Summary())
+	logInfo("Found %s", review.Summary())
+
+	if !flagSendNoVerify {
+		if err := runHook(vcs, "pre-send", pr); err != nil {
+			return fmt.Errorf("%w\nuse --no-verify to send anyway", err)
+		}
+	}
+
+	ctx := cmd.Context()
 
+	// A dry run stays offline, resolving only threads that were unresolved
+	// when the PR was last fetched
 	if flagSendDryRun {
-		review.PrintDryRun()
+		if prNumber != 0 && len(review.Resolves) > 0 {
+			if cached, err := loadPRCache(vcs, prNumber); err == nil {
+				review.MatchResolves(cached)
+			} else {
+				logWarn("could not read the PR as last fetched, so all resolves are shown: %v", err)
+			}
+		}
+		printDryRun(review, DryRunOptions{Render: flagSendRender, Code: func(path string, start, end int) []string {
+			lines, err := craft.CodeLines(opts, path)
+			if err != nil || start < 1 || end > len(lines) {
+				return nil
+			}
+			return lines[start-1 : end]
+		}})
+		sc, err := loadSpellChecker(opts.FS, cfg.Spell)
+		if err != nil {
+			logWarn("could not spell check: %v", err)
+		} else if sc != nil {
+			for _, typo := range spellCheckReview(sc, review) {
+				logWarn("%v", typo)
+			}
+		}
 		return nil
 	}
 
@@ -117,66 +252,303 @@ func runSend(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
-	ctx := cmd.Context()
+	// Warn about @mentions that look like typos
+	if err := warnUnknownMentions(ctx, client, owner, repo, review); err != nil {
+		logWarn("could not check @mentions: %v", err)
+	}
+
+	// Only resolve threads that are still unresolved on GitHub
+	if prNumber != 0 && len(review.Resolves) > 0 {
+		logStart("Checking resolved threads")
+		remotePR, err := client.FetchPullRequest(ctx, owner, repo, prNumber)
+		if err != nil {
+			return fmt.Errorf("fetching PR: %w", err)
+		}
+		logEnd("done")
+		review.MatchResolves(remotePR)
+		if review.IsEmpty() && review.ReviewEvent != "APPROVE" {
+			logInfo("No new comments to send.")
+			return nil
+		}
+	}
+
+	// A local review takes on the PR's identity
+	if pr.IsLocal {
+		logStart("Looking up PR")
+		remotePR, err := client.FetchPullRequest(ctx, owner, repo, prNumber)
+		if err != nil {
+			return fmt.Errorf("fetching PR: %w", err)
+		}
+		if remotePR.HeadRefOID != pr.HeadRefOID {
+			logEnd("mismatch!")
+			return fmt.Errorf("PR #%d head is %s, but the review is of %s; push the reviewed commit or review the PR with 'craft get'",
+				prNumber, remotePR.HeadRefOID[:12], pr.HeadRefOID[:12])
+		}
+		logEnd("done")
+		pr.ID, pr.Number = remotePR.ID, remotePR.Number
+	}
 
 	// Check if PR head has changed
-	fmt.Print("Checking PR status... ")
-	currentHead, err := client.FetchPRHead(ctx, owner, repo, prNumber)
+	logStart("Checking PR status")
+	currentHead, state, isDraft, err := client.FetchPRHead(ctx, owner, repo, prNumber)
 	if err != nil {
 		return fmt.Errorf("checking PR head: %w", err)
 	}
-	if currentHead != pr.HeadRefOID {
-		fmt.Println("changed!")
-		fmt.Printf("\nPR has been updated (local: %s, remote: %s)\n", pr.HeadRefOID[:12], currentHead[:12])
-		fmt.Println("\nTo update your local state while preserving your comments:")
-		fmt.Println("  1. Commit your changes:  git add -A && git commit -m 'my comments'")
-		fmt.Println("  2. Fetch new PR head:    git fetch origin refs/pull/" + fmt.Sprint(prNumber) + "/head")
-		fmt.Println("  3. Merge:                git merge FETCH_HEAD")
-		fmt.Println("  4. Resolve any conflicts, then run 'craft send' again")
+	if isClosedState(state) && !flagSendForce {
+		logEnd(strings.ToLower(state) + "!")
+		return fmt.Errorf("PR #%d has been %s; use --force to send to it anyway", prNumber, strings.ToLower(state))
+	}
+	if isDraft && review.ReviewEvent == "APPROVE" {
+		logWarn("PR #%d is a draft; approving it anyway", prNumber)
+	}
+	if pr.InPlace {
+		// The author's pushes don't make replies stale
+		pr.HeadRefOID = currentHead
+	} else if currentHead != pr.HeadRefOID {
+		logEnd("changed!")
+		logInfo("PR has been updated (local: %s, remote: %s)", pr.HeadRefOID[:12], currentHead[:12])
+		logInfo("To update your local state while preserving your comments:")
+		logInfo("  1. Commit your changes:  git add -A && git commit -m 'my comments'")
+		logInfo("  2. Fetch new PR head:    git fetch origin refs/pull/%d/head", prNumber)
+		logInfo("  3. Merge:                git merge FETCH_HEAD")
+		logInfo("  4. Resolve any conflicts, then run 'craft send' again")
 		return fmt.Errorf("PR head has changed; merge required")
 	}
-	fmt.Println("ok")
+	logEnd("ok")
 
 	// Send the review
-	if err := review.Send(ctx, client, pr.ID, pr.HeadRefOID, flagSendDiscardPendingReview); err != nil {
+	if err := review.Send(ctx, client, pr.ID, pr.CheckoutOID(), flagSendDiscardPendingReview); err != nil {
 		return err
 	}
+	if review.SentReviewID != "" || review.SentViaREST {
+		sent := lastSend{
+			PRNumber:      prNumber,
+			ReviewID:      review.SentReviewID,
+			CreatedReview: review.SentReviewCreated,
+			CommentIDs:    review.SentCommentIDs,
+			REST:          review.SentViaREST,
+			Event:         review.ReviewEvent,
+			HasBody:       review.Body != "",
+			SentAt:        time.Now(),
+		}
+		if err := saveLastSend(vcs, sent); err != nil {
+			logWarn("recording the send for craft undo-send: %v", err)
+		}
+	}
+
+	if pr.IsLocal {
+		// The review is on the local branch, not a pr-N one, so leave it as
+		// it was before craft local
+		if _, err := clearCraftFiles(vcs, false); err != nil {
+			return fmt.Errorf("removing sent comments: %w", err)
+		}
+		logInfo("Review sent to PR #%d, and its comments removed from the files.", prNumber)
+		return nil
+	}
 
 	if flagSendReplyOnly {
 		// In reply-only mode, skip re-fetch/re-serialize to preserve code edits.
 		// The user is expected to run 'craft clear' next.
-		fmt.Println("\nReplies sent successfully! (reply-only mode, files unchanged)")
-		fmt.Println("Run 'craft clear' to remove craft comments from source files.")
+		logInfo("Replies sent successfully! (reply-only mode, files unchanged)")
+		logInfo("Run 'craft clear' to remove craft comments from source files.")
 		return nil
 	}
 
 	// Re-fetch PR to get updated state with our new comments
-	fmt.Print("Fetching updated PR state... ")
+	logStart("Fetching updated PR state")
 	updatedPR, err := client.FetchPullRequest(ctx, owner, repo, prNumber)
 	if err != nil {
 		return fmt.Errorf("fetching updated PR: %w", err)
 	}
-	fmt.Println("done")
+	logEnd("done")
+	updatedPR.CopyHeaderExtras(pr)
+	if pr.ReviewCommitOID != "" {
+		focusCommit(updatedPR, pr.ReviewCommitOID)
+	}
+	if err := savePRCache(vcs, updatedPR); err != nil {
+		logWarn("keeping a copy of PR #%d for craft switch: %v", prNumber, err)
+	}
+
+	if pr.InPlace {
+		updatedPR.InPlace = true
+		craft.PlaceInWorkingCopy(opts, updatedPR)
+		craft.MarkAppliedSuggestions(opts, updatedPR)
+		logStart("Updating local files")
+		if err := craft.Serialize(updatedPR, opts); err != nil {
+			return fmt.Errorf("serializing: %w", err)
+		}
+		logEnd("done")
+		logInfo("Review sent successfully! (in place, nothing committed)")
+		return nil
+	}
+
+	if err := retargetRenamedThreads(vcs, updatedPR); err != nil {
+		logWarn("%v", err)
+	}
 
 	// Re-serialize (comments are no longer "new")
-	fmt.Print("Updating local files... ")
-	if err := Serialize(updatedPR, opts); err != nil {
+	craft.MarkAppliedSuggestions(opts, updatedPR)
+	logStart("Updating local files")
+	if err := craft.Serialize(updatedPR, opts); err != nil {
 		return fmt.Errorf("serializing: %w", err)
 	}
-	fmt.Println("done")
+	logEnd("done")
 
 	// Commit the changes
-	fmt.Print("Committing... ")
-	commitMsg := fmt.Sprintf("craft: sent review on PR #%d", prNumber)
-	if err := vcs.Commit(commitMsg); err != nil {
-		return fmt.Errorf("committing: %w", err)
+	commitOpts, err := resolveCommitOptions(vcs, flagSendNoSign)
+	if err != nil {
+		logWarn("%v", err)
+	}
+	if commit, err := resolveAutoCommit(vcs, flagSendNoCommit); err != nil {
+		logWarn("%v", err)
+	} else if commit {
+		logStart("Committing")
+		commitMsg := craftCommitMessage(fmt.Sprintf("craft: sent review on PR #%d", prNumber), "", prNumber)
+		if err := vcs.Commit(commitMsg, commitOpts); err != nil {
+			return fmt.Errorf("committing: %w", err)
+		}
+		logEnd("done")
 	}
-	fmt.Println("done")
 
 	if review.ReviewEvent == "PENDING" {
-		fmt.Println("\nReview left in pending state")
+		logInfo("Review left in pending state")
 	} else {
-		fmt.Println("\nReview sent successfully!")
+		logInfo("Review sent successfully!")
 	}
 	return nil
 }
+
+// sendDescription sends the title and body in PR-DESCRIPTION.md as the
+// description of PR number, if they were edited, and records them as
+// fetched. The file is committed if commit is set and autoCommit allows.
+func sendDescription(ctx context.Context, vcs VCS, opts craft.SerializeOptions, number int, commit bool) error {
+	title, body, fetched, err := craft.ReadDescription(opts.FS)
+	if err != nil {
+		return err
+	}
+	client, owner, repo, err := getGitHubClientAndRepo(vcs, resolveRemote(vcs, ""))
+	if err != nil {
+		return err
+	}
+
+	logStart("Checking PR description")
+	remote, err := client.FetchPRDescription(ctx, owner, repo, number)
+	if err != nil {
+		return err
+	}
+	if title == remote.Title && body == strings.TrimSpace(craft.NormalizeNewlines(remote.Body)) {
+		logEnd("unchanged")
+		logInfo("No changes to the description to send.")
+		return nil
+	}
+	if craft.DescriptionHash(remote.Title, remote.Body) != fetched && !flagSendForce {
+		logEnd("changed!")
+		return fmt.Errorf("the description of PR #%d was edited on GitHub since 'craft get'; get it again to see the edits, or use --force to replace them", number)
+	}
+	if !remote.CanUpdate {
+		logEnd("denied!")
+		return fmt.Errorf("only the author of PR #%d, or someone with write access, can edit its description", number)
+	}
+	logEnd("ok")
+
+	if flagSendDryRun {
+		fmt.Printf("Would set the description of PR #%d to:\n\n# %s\n", number, title)
+		if body != "" {
+			fmt.Printf("\n%s\n", body)
+		}
+		return nil
+	}
+	logStart("Sending PR description")
+	if err := client.UpdatePRDescription(ctx, remote.ID, title, body); err != nil {
+		return fmt.Errorf("updating PR description: %w", err)
+	}
+	logEnd("done")
+
+	// It's on GitHub now, so the next send compares with it
+	if err := craft.WriteFile(opts.FS, craft.DescriptionFile, []byte(craft.FormatDescription(number, title, body))); err != nil {
+		return fmt.Errorf("writing %s: %w", craft.DescriptionFile, err)
+	}
+	if commit {
+		commitOpts, err := resolveCommitOptions(vcs, flagSendNoSign)
+		if err != nil {
+			logWarn("%v", err)
+		}
+		if commit, err := resolveAutoCommit(vcs, flagSendNoCommit); err != nil {
+			logWarn("%v", err)
+		} else if commit {
+			logStart("Committing")
+			commitMsg := craftCommitMessage(fmt.Sprintf("craft: sent description of PR #%d", number), "", number)
+			if err := vcs.Commit(commitMsg, commitOpts); err != nil {
+				return fmt.Errorf("committing: %w", err)
+			}
+			logEnd("done")
+		}
+	}
+	logInfo("Description sent successfully!")
+	return nil
+}
+
+// DryRunOptions configures printDryRun.
+type DryRunOptions struct {
+	Render bool // Show bodies' markdown rendered, as GitHub would (roughly)
+
+	// Optional: returns lines start to end of the code at path, which a
+	// suggestion on them replaces, or nil
+	Code func(path string, start, end int) []string
+}
+
+// printDryRun prints what review would send, without sending it.
+func printDryRun(r *craft.ReviewToSend, opts DryRunOptions) {
+	color := useColor(os.Stdout)
+	heading := func(format string, args ...any) string {
+		return paint(color, ansiBold, fmt.Sprintf(format, args...))
+	}
+	// show returns a body to print, rendered if asked, with a suggestion in
+	// it shown as a diff from lines start to end of path
+	show := func(body, path string, start, end int) string {
+		if !opts.Render {
+			return body
+		}
+		var old []string
+		if opts.Code != nil && path != "" {
+			old = opts.Code(path, start, end)
+		}
+		return strings.ReplaceAll(renderTerminal(body, color, old), "\n", "\n  ")
+	}
+	fmt.Println("\n" + paint(color, ansiYellow, "━━━━━ DRY RUN ━━━━━"))
+	for _, t := range r.NewThreads {
+		if t.Subject == SubjectTypeFile {
+			fmt.Printf("\n%s\n  %s\n", heading("New thread on file %s:", t.Path), show(t.Body, "", 0, 0))
+			continue
+		}
+		path, start := t.Path, t.Line
+		if t.StartLine != nil {
+			start = *t.StartLine
+		}
+		if t.Side == DiffSideLeft {
+			path = "" // the code's gone from the files
+		}
+		fmt.Printf("\n%s\n  %s\n", heading("New thread on %s:%d (%s):", t.Path, t.Line, t.Side), show(t.Body, path, start, t.Line))
+	}
+	for _, reply := range r.Replies {
+		fmt.Printf("\n%s\n  %s\n", heading("Reply in thread %s:%d:", reply.ThreadPath, reply.ThreadLine),
+			show(reply.Body, reply.ThreadPath, reply.ThreadLine, reply.ThreadLine))
+	}
+	for _, res := range r.Resolves {
+		fmt.Printf("\n%s\n", heading("Resolve thread %s:%d", res.ThreadPath, res.ThreadLine))
+	}
+	if r.Body != "" {
+		fmt.Printf("\n%s\n  %s\n", heading("PR-level comment:"), show(r.Body, "", 0, 0))
+	}
+	for _, change := range r.IssueCommentChanges {
+		if change.Delete {
+			fmt.Printf("\n%s\n", heading("Delete PR-level comment by @%s", change.Author))
+		} else {
+			fmt.Printf("\n%s\n  %s\n", heading("Edit PR-level comment by @%s:", change.Author), show(change.Body, "", 0, 0))
+		}
+	}
+	if r.PendingReviewID != "" {
+		fmt.Printf("\n%s\n  %s\n", heading("Pending review body:"), show(r.PendingReviewBody, "", 0, 0))
+	}
+	fmt.Printf("\nReview event: %s\n", r.ReviewEvent)
+}
@@ -0,0 +1,299 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dnr/craft/pkg/craft"
+	"github.com/shurcooL/githubv4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestPRRepo makes a git repo of files with a GitHub remote, on branch
+// pr-number, with caches and config of its own, and changes into it.
+func newTestPRRepo(t *testing.T, number int, files map[string]string) *GitRepo {
+	t.Helper()
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	repo := newTestGitRepo(t, files)
+	_, err := repo.run("remote", "add", "origin", "https://github.com/owner/repo.git")
+	require.NoError(t, err)
+	_, err = repo.run("checkout", "-q", "-b", fmt.Sprintf("pr-%d", number))
+	require.NoError(t, err)
+	t.Chdir(repo.root)
+	return repo
+}
+
+// captureStdout returns what f prints to stdout.
+func captureStdout(t *testing.T, f func()) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	require.NoError(t, err)
+	stdout := os.Stdout
+	os.Stdout = w
+	done := make(chan string)
+	go func() {
+		out, _ := io.ReadAll(r)
+		done <- string(out)
+	}()
+	defer func() { os.Stdout = stdout }()
+	f()
+	w.Close()
+	return <-done
+}
+
+func TestSendDryRunOffline(t *testing.T) {
+	repo := newTestPRRepo(t, 5, map[string]string{"main.go": "one\ntwo\nthree\n"})
+	head, err := repo.run("rev-parse", "HEAD")
+	require.NoError(t, err)
+	head = strings.TrimSpace(head)
+	newAPI := newGitHubAPI
+	newGitHubAPI = func(string) GitHubAPI {
+		t.Fatal("went to GitHub")
+		return nil
+	}
+	t.Cleanup(func() { newGitHubAPI = newAPI })
+	flagSendDryRun = true
+	t.Cleanup(func() { flagSendDryRun = false })
+
+	thread := func(n int, resolved bool) ReviewThread {
+		return ReviewThread{
+			ID: fmt.Sprintf("PRRT_%d", n), Path: "main.go", Line: n, DiffSide: DiffSideRight,
+			SubjectType: SubjectTypeLine, IsResolved: resolved,
+			Comments: []ReviewComment{{ID: fmt.Sprintf("PRRC_%d", n), Author: Actor{Login: "alice"}, Body: "Hm @bob"}},
+		}
+	}
+	// The threads as last fetched, one already resolved on GitHub, and
+	// both resolved in the files
+	fetched := &PullRequest{ID: "PR_x", Number: 5, HeadRefOID: head, BaseRefOID: head,
+		ReviewThreads: []ReviewThread{thread(1, false), thread(2, true)}}
+	require.NoError(t, savePRCache(repo, fetched))
+	local := &PullRequest{ID: "PR_x", Number: 5, HeadRefOID: head, BaseRefOID: head,
+		ReviewThreads: []ReviewThread{thread(1, true), thread(2, true)}}
+	require.NoError(t, craft.Serialize(local, craft.SerializeOptions{FS: craft.DirFS(repo.root), VCS: repo}))
+
+	out := captureStdout(t, func() {
+		require.NoError(t, runSend(sendCmd, nil))
+	})
+	assert.Contains(t, out, "Resolve thread main.go:1")
+	assert.NotContains(t, out, "main.go:2")
+}
+
+func TestSendRestoresOriginalBodies(t *testing.T) {
+	hook := "#!/bin/sh\ncat > \"$HOOK_OUT\"\n"
+	repo := newTestPRRepo(t, 5, map[string]string{
+		"main.go":              "one\ntwo\nthree\n",
+		hooksDir + "/pre-send": hook,
+	})
+	require.NoError(t, os.Chmod(filepath.Join(repo.root, hooksDir, "pre-send"), 0755))
+	out := filepath.Join(t.TempDir(), "pr.json")
+	t.Setenv("HOOK_OUT", out)
+	head, err := repo.run("rev-parse", "HEAD")
+	require.NoError(t, err)
+	head = strings.TrimSpace(head)
+	flagSendDryRun = true
+	t.Cleanup(func() { flagSendDryRun = false })
+
+	// A body the files reflow, as fetched
+	original := "First line\nof a soft-wrapped paragraph."
+	thread := ReviewThread{
+		ID: "PRRT_1", Path: "main.go", Line: 1, DiffSide: DiffSideRight, SubjectType: SubjectTypeLine,
+		Comments: []ReviewComment{{ID: "PRRC_1", Author: Actor{Login: "alice"}, Body: original}},
+	}
+	fetched := &PullRequest{ID: "PR_x", Number: 5, HeadRefOID: head, BaseRefOID: head,
+		ReviewThreads: []ReviewThread{thread}}
+	require.NoError(t, savePRCache(repo, fetched))
+	thread.IsResolved = true
+	local := &PullRequest{ID: "PR_x", Number: 5, HeadRefOID: head, BaseRefOID: head,
+		ReviewThreads: []ReviewThread{thread}}
+	require.NoError(t, craft.Serialize(local, craft.SerializeOptions{FS: craft.DirFS(repo.root), VCS: repo}))
+
+	captureStdout(t, func() {
+		require.NoError(t, runSend(sendCmd, nil))
+	})
+	data, err := os.ReadFile(out)
+	require.NoError(t, err)
+	sent, err := craft.ParsePullRequestJSON(data)
+	require.NoError(t, err)
+	require.Len(t, sent.ReviewThreads, 1)
+	assert.Equal(t, original, sent.ReviewThreads[0].Comments[0].Body)
+	assert.False(t, sent.ReviewThreads[0].Comments[0].IsModified)
+}
+
+func TestSendAfterFmt(t *testing.T) {
+	repo := newTestPRRepo(t, 5, map[string]string{"main.go": "one\ntwo\nthree\n"})
+	head, err := repo.run("rev-parse", "HEAD")
+	require.NoError(t, err)
+	head = strings.TrimSpace(head)
+	t.Setenv("GH_TOKEN", "token")
+	client := &fakeGitHub{}
+	newAPI := newGitHubAPI
+	newGitHubAPI = func(string) GitHubAPI { return client }
+	t.Cleanup(func() { newGitHubAPI = newAPI })
+
+	// Bodies that wrapping at a narrower width could damage
+	body := "See :tada:\n\n" +
+		"| option | description |\n" +
+		"|--------|-------------|\n" +
+		"| width | wrap comment bodies at this width, or the editorconfig one |\n\n" +
+		strings.Repeat("word ", 20)
+	pr := &PullRequest{ID: "PR_x", Number: 5, HeadRefOID: head, BaseRefOID: head,
+		IssueComments: []IssueComment{
+			{ID: "IC_bob", Author: Actor{Login: "bob"}, Body: body},
+			{ID: "IC_me", Author: Actor{Login: "me"}, Body: body},
+		},
+		Reviews: []Review{{ID: "PRR_me", Author: Actor{Login: "me"}, State: ReviewStatePending, Body: body}},
+	}
+	fsys := craft.DirFS(repo.root)
+	require.NoError(t, craft.Serialize(pr, craft.SerializeOptions{FS: fsys, VCS: repo, WrapWidth: 120}))
+	changed, err := fmtCraftFiles(craft.SerializeOptions{FS: fsys, WrapWidth: 40}, false)
+	require.NoError(t, err)
+	assert.Equal(t, []string{craft.PRStateFile}, changed)
+
+	// Re-wrapped, nothing is edited
+	captureStdout(t, func() {
+		require.NoError(t, runSend(sendCmd, nil))
+	})
+	assert.Empty(t, client.calls)
+
+	// And someone else's comment, edited, isn't sent
+	path := filepath.Join(repo.root, craft.PRStateFile)
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	content = []byte(strings.Replace(string(content), "See :tada:", "See :tada: :tada:", 1))
+	require.NoError(t, os.WriteFile(path, content, 0644))
+	captureStdout(t, func() {
+		assert.ErrorContains(t, runSend(sendCmd, nil), "PR-level comment by @bob")
+	})
+	assert.Empty(t, client.calls)
+}
+
+// fakeDescriptionGitHub is a GitHubAPI with a PR description.
+type fakeDescriptionGitHub struct {
+	GitHubAPI
+	desc    PRDescription
+	updated []string
+}
+
+func (f *fakeDescriptionGitHub) FetchPRDescription(ctx context.Context, owner, repo string, number int) (*PRDescription, error) {
+	desc := f.desc
+	return &desc, nil
+}
+
+func (f *fakeDescriptionGitHub) UpdatePRDescription(ctx context.Context, prNodeID, title, body string) error {
+	f.updated = append(f.updated, prNodeID+" "+title+" "+body)
+	return nil
+}
+
+func TestSendDescription(t *testing.T) {
+	t.Setenv("GH_TOKEN", "token")
+	repo := newTestGitRepo(t, map[string]string{"a.txt": "a\n"})
+	_, err := repo.run("remote", "add", "origin", "https://github.com/owner/repo.git")
+	require.NoError(t, err)
+	fake := &fakeDescriptionGitHub{desc: PRDescription{ID: "PR_x", Title: "Old", Body: "Old body", CanUpdate: true}}
+	newAPI := newGitHubAPI
+	newGitHubAPI = func(string) GitHubAPI { return fake }
+	t.Cleanup(func() { newGitHubAPI = newAPI })
+
+	opts := craft.SerializeOptions{FS: craft.DirFS(repo.root), VCS: repo}
+	// Edits to the description fetched as "Old"
+	write := func(title, body string) {
+		content := "<!-- craft: description of PR #5 as fetched (" + craft.DescriptionHash("Old", "Old body") + ") -->\n# " + title + "\n\n" + body + "\n"
+		require.NoError(t, craft.WriteFile(opts.FS, craft.DescriptionFile, []byte(content)))
+	}
+
+	// Unchanged: nothing sent
+	write("Old", "Old body")
+	require.NoError(t, sendDescription(t.Context(), repo, opts, 5, false))
+	assert.Empty(t, fake.updated)
+
+	// Edited: sent, and recorded as fetched
+	write("New", "New body")
+	require.NoError(t, sendDescription(t.Context(), repo, opts, 5, false))
+	assert.Equal(t, []string{"PR_x New New body"}, fake.updated)
+	_, _, fetched, err := craft.ReadDescription(opts.FS)
+	require.NoError(t, err)
+	assert.Equal(t, craft.DescriptionHash("New", "New body"), fetched)
+
+	// Edited on GitHub since
+	fake.updated = nil
+	fake.desc.Body = "Someone else's"
+	write("Newer", "New body")
+	assert.ErrorContains(t, sendDescription(t.Context(), repo, opts, 5, false), "edited on GitHub since")
+
+	// Not allowed
+	fake.desc = PRDescription{ID: "PR_x", Title: "Old", Body: "Old body"}
+	assert.ErrorContains(t, sendDescription(t.Context(), repo, opts, 5, false), "only the author of PR #5")
+	assert.Empty(t, fake.updated)
+}
+
+// fakeGitHub is a GitHubAPI that records the review mutations made, and has
+// a pending review if pending is set. Methods it doesn't override panic.
+type fakeGitHub struct {
+	GitHubAPI
+	pending    bool
+	failDelete string // a comment whose deletion fails
+	calls      []string
+}
+
+func (f *fakeGitHub) GetPendingReview(ctx context.Context, prNodeID string) (githubv4.ID, bool, error) {
+	if f.pending {
+		return "PRR_old", true, nil
+	}
+	return nil, false, nil
+}
+
+func (f *fakeGitHub) DeletePendingReview(ctx context.Context, reviewID githubv4.ID) error {
+	f.calls = append(f.calls, fmt.Sprintf("delete %v", reviewID))
+	return nil
+}
+
+func (f *fakeGitHub) StartReviewWithThreads(ctx context.Context, prNodeID, commitOID string, threads []NewThreadInfo) (githubv4.ID, error) {
+	f.calls = append(f.calls, fmt.Sprintf("start %s@%s with %d thread(s)", prNodeID, commitOID, len(threads)))
+	return "PRR_new", nil
+}
+
+func (f *fakeGitHub) AddFileThread(ctx context.Context, reviewID githubv4.ID, t NewThreadInfo) error {
+	f.calls = append(f.calls, fmt.Sprintf("file thread %v %s", reviewID, t.Path))
+	return nil
+}
+
+func (f *fakeGitHub) AddReviewComment(ctx context.Context, reviewID githubv4.ID, replyToNodeID, body string) (string, error) {
+	f.calls = append(f.calls, fmt.Sprintf("reply %v %s %q", reviewID, replyToNodeID, body))
+	return "PRRC_reply", nil
+}
+
+func (f *fakeGitHub) SubmitReview(ctx context.Context, reviewID githubv4.ID, eventType, body string) error {
+	f.calls = append(f.calls, fmt.Sprintf("submit %v %s %q", reviewID, eventType, body))
+	return nil
+}
+
+func (f *fakeGitHub) ResolveThread(ctx context.Context, threadID string) error {
+	f.calls = append(f.calls, "resolve "+threadID)
+	return nil
+}
+
+func (f *fakeGitHub) UpdateIssueComment(ctx context.Context, commentID, body string) error {
+	f.calls = append(f.calls, fmt.Sprintf("edit %s %q", commentID, body))
+	return nil
+}
+
+func (f *fakeGitHub) DeleteIssueComment(ctx context.Context, commentID string) error {
+	f.calls = append(f.calls, "delete "+commentID)
+	return nil
+}
+
+func (f *fakeGitHub) FetchViewerLogin(ctx context.Context) (string, error) {
+	return "me", nil
+}
+
+func (f *fakeGitHub) UpdateReviewBody(ctx context.Context, reviewID githubv4.ID, body string) error {
+	f.calls = append(f.calls, fmt.Sprintf("body %v %q", reviewID, body))
+	return nil
+}
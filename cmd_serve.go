@@ -0,0 +1,338 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/dnr/craft/pkg/craft"
+	"github.com/spf13/cobra"
+)
+
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Browse and comment on the PR in a local web page",
+	Long: `Runs a web server on localhost showing the PR: its description and
+comments, and each file with threads inline.
+
+New comments and replies written in the page go into the files through the
+same serialization as 'craft get', so they show up in the editor too and are
+sent by 'craft send'. Everything is read from the files on each request; no
+GitHub access is needed.`,
+	RunE: runServe,
+	Args: cobra.NoArgs,
+}
+
+var flagServeAddr string
+
+func init() {
+	serveCmd.Flags().StringVar(&flagServeAddr, "addr", "localhost:8080", "Address to listen on")
+	rootCmd.AddCommand(serveCmd)
+}
+
+func runServe(cmd *cobra.Command, args []string) error {
+	vcs, err := DetectVCS(".")
+	if err != nil {
+		return err
+	}
+	opts, cfg, err := getSerializeOptions(vcs, 0)
+	if err != nil {
+		return err
+	}
+
+	logInfo("Serving PR at http://%s/", flagServeAddr)
+	server := newReviewServer(opts, cfg.IncludeResolved)
+	server.renderEmoji = cfg.RenderEmoji
+	return http.ListenAndServe(flagServeAddr, server)
+}
+
+// reviewServer serves the web pages of craft serve.
+type reviewServer struct {
+	opts            craft.SerializeOptions
+	includeResolved bool
+	renderEmoji     bool       // in the pages, never in what's written back
+	mu              sync.Mutex // one request at a time touches the files
+	handler         http.Handler
+}
+
+func newReviewServer(opts craft.SerializeOptions, includeResolved bool) *reviewServer {
+	mux := http.NewServeMux()
+	s := &reviewServer{opts: opts, includeResolved: includeResolved}
+	mux.HandleFunc("GET /{$}", s.handleIndex)
+	mux.HandleFunc("GET /file", s.handleFile)
+	mux.HandleFunc("POST /comment", s.handleComment)
+	// Other sites open in the browser mustn't be able to post comments
+	s.handler = http.NewCrossOriginProtection().Handler(mux)
+	return s
+}
+
+func (s *reviewServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.handler.ServeHTTP(w, r)
+}
+
+// load reads the PR from the files.
+func (s *reviewServer) load() (*PullRequest, error) {
+	return loadReview(s.opts)
+}
+
+// show reads the PR from the files to show in a page.
+func (s *reviewServer) show() (*PullRequest, error) {
+	pr, err := s.load()
+	if err != nil || !s.renderEmoji {
+		return pr, err
+	}
+	pr.Body = craft.RenderEmojiText(pr.Body)
+	for i := range pr.IssueComments {
+		pr.IssueComments[i].Body = craft.RenderEmojiText(pr.IssueComments[i].Body)
+	}
+	for i := range pr.ReviewThreads {
+		for j := range pr.ReviewThreads[i].Comments {
+			c := &pr.ReviewThreads[i].Comments[j]
+			c.Body = craft.RenderEmojiText(c.Body)
+		}
+	}
+	return pr, nil
+}
+
+// loadReview reads the PR from the files, to show it and add comments to
+// it. Malformed craft lines are left out, like in craft base.
+func loadReview(opts craft.SerializeOptions) (*PullRequest, error) {
+	pr, err := craft.Deserialize(opts)
+	if err != nil && !errors.As(err, new(craft.ParseErrors)) {
+		return nil, err
+	}
+	// Deserialize ignores the description, but it's shown, and kept when
+	// comments are added
+	stateContent, err := craft.ReadFile(opts.FS, craft.PRStateFile)
+	if err != nil {
+		return nil, fmt.Errorf("reading PR state: %w", err)
+	}
+	pr.Body = prStateDescription(string(stateContent))
+	return pr, nil
+}
+
+type serveFile struct {
+	Path    string
+	Threads int
+}
+
+func (s *reviewServer) handleIndex(w http.ResponseWriter, r *http.Request) {
+	pr, err := s.show()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	counts := make(map[string]int)
+	for _, thread := range pr.ReviewThreads {
+		counts[thread.Path]++
+	}
+	var files []serveFile
+	for _, path := range threadPaths(pr) {
+		files = append(files, serveFile{Path: path, Threads: counts[path]})
+	}
+	render(w, serveIndexTemplate, map[string]any{"PR": pr, "Files": files})
+}
+
+// serveLine is a line of a file in the file page, with the threads below it.
+type serveLine struct {
+	Num     int
+	Text    string
+	Threads []ReviewThread
+	Compose bool // show the form for a new thread on this line
+}
+
+func (s *reviewServer) handleFile(w http.ResponseWriter, r *http.Request) {
+	path := r.URL.Query().Get("path")
+	compose, _ := strconv.Atoi(r.URL.Query().Get("line"))
+	pr, err := s.show()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !fs.ValidPath(path) || craft.IsReviewFile(path) {
+		http.NotFound(w, r)
+		return
+	}
+	content, err := craft.ReadFile(s.opts.FS, path)
+	if errors.Is(err, fs.ErrNotExist) {
+		http.NotFound(w, r)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	_, text := craft.DecodeFile(stripCraftContent(string(content), path))
+	var lines []serveLine
+	for i, line := range strings.Split(text, "\n") {
+		lines = append(lines, serveLine{Num: i + 1, Text: line, Compose: i+1 == compose})
+	}
+	// File threads and outdated threads without a line go at the top
+	var fileThreads []ReviewThread
+	for _, thread := range pr.ReviewThreads {
+		if thread.Path != path {
+			continue
+		}
+		if thread.Line >= 1 && thread.Line <= len(lines) {
+			lines[thread.Line-1].Threads = append(lines[thread.Line-1].Threads, thread)
+		} else {
+			fileThreads = append(fileThreads, thread)
+		}
+	}
+	render(w, serveFileTemplate, map[string]any{"Path": path, "Lines": lines, "FileThreads": fileThreads})
+}
+
+func (s *reviewServer) handleComment(w http.ResponseWriter, r *http.Request) {
+	path := r.FormValue("path")
+	if !fs.ValidPath(path) || craft.IsReviewFile(path) {
+		http.Error(w, "bad path", http.StatusBadRequest)
+		return
+	}
+	replyTo := r.FormValue("reply")
+	body := strings.TrimSpace(strings.ReplaceAll(r.FormValue("body"), "\r\n", "\n"))
+	if body == "" {
+		http.Error(w, "empty comment", http.StatusBadRequest)
+		return
+	}
+	pr, err := s.load()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	comment := ReviewComment{Body: body, IsNew: true}
+	if replyTo != "" {
+		i := threadIndexByComment(pr.ReviewThreads, replyTo)
+		if i < 0 {
+			http.Error(w, "no thread with comment "+replyTo, http.StatusBadRequest)
+			return
+		}
+		pr.ReviewThreads[i].Comments = append(pr.ReviewThreads[i].Comments, comment)
+	} else {
+		line, err := strconv.Atoi(r.FormValue("line"))
+		if err != nil || line < 1 {
+			http.Error(w, "bad line", http.StatusBadRequest)
+			return
+		}
+		pr.ReviewThreads = append(pr.ReviewThreads, ReviewThread{
+			Path:        path,
+			Line:        line,
+			DiffSide:    DiffSideRight,
+			SubjectType: SubjectTypeLine,
+			Comments:    []ReviewComment{comment},
+		})
+	}
+
+	if err := writeReview(s.opts, s.includeResolved, pr); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, "/file?path="+template.URLQueryEscaper(path), http.StatusSeeOther)
+}
+
+// writeReview writes pr, with comments added to it, back into the files it
+// was loaded from. Resolved threads stay hidden unless some were shown.
+func writeReview(opts craft.SerializeOptions, includeResolved bool, pr *PullRequest) error {
+	opts.HideResolved = !includeResolved && pr.ResolvedThreadCount() == 0
+	return craft.Serialize(pr, opts)
+}
+
+// threadIndexByComment returns the index of the thread whose first comment
+// has the given node ID, or -1.
+func threadIndexByComment(threads []ReviewThread, commentID string) int {
+	for i, thread := range threads {
+		if len(thread.Comments) > 0 && thread.Comments[0].ID == commentID {
+			return i
+		}
+	}
+	return -1
+}
+
+// render writes a page, or an error if the template fails partway.
+func render(w http.ResponseWriter, tmpl *template.Template, data any) {
+	var buf strings.Builder
+	if err := tmpl.Execute(&buf, data); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write([]byte(buf.String()))
+}
+
+var serveTemplates = template.Must(template.New("").Parse(`
+{{define "style"}}<style>
+body { font-family: sans-serif; margin: 2em; }
+pre { margin: 0; }
+table.code { border-collapse: collapse; font-family: monospace; }
+td.num { color: #888; text-align: right; padding-right: 1em; user-select: none; }
+td.num a { color: inherit; text-decoration: none; }
+.thread { border: 1px solid #ccc; border-radius: 4px; margin: 0.5em 0; padding: 0.5em; font-family: sans-serif; max-width: 50em; }
+.resolved { opacity: 0.5; }
+.comment { margin-bottom: 0.5em; }
+.author { font-weight: bold; }
+.new { background: #ffd; }
+.meta { color: #888; font-size: smaller; }
+textarea { width: 100%; max-width: 50em; }
+</style>{{end}}
+
+{{define "comment"}}<div class="comment{{if .IsNew}} new{{end}}">
+<span class="author">{{if .IsNew}}new{{else}}@{{.Author.Login}}{{end}}</span>
+{{if not .CreatedAt.IsZero}}<span class="meta">{{.CreatedAt.Format "2006-01-02 15:04"}}</span>{{end}}
+<pre>{{.Body}}</pre>
+</div>{{end}}
+
+{{define "thread"}}<div class="thread{{if .IsResolved}} resolved{{end}}">
+{{if .IsResolved}}<div class="meta">resolved</div>{{end}}
+{{if .IsOutdated}}<div class="meta">outdated (line {{.OriginalLine}})</div>{{end}}
+{{range .Comments}}{{template "comment" .}}{{end}}
+{{with index .Comments 0}}{{if .ID}}<form method="post" action="/comment">
+<input type="hidden" name="path" value="{{$.Path}}">
+<input type="hidden" name="reply" value="{{.ID}}">
+<textarea name="body" rows="2" placeholder="Reply"></textarea>
+<button>Reply</button>
+</form>{{end}}{{end}}
+</div>{{end}}
+
+{{define "index"}}<!DOCTYPE html>
+<html><head><title>PR #{{.PR.Number}}</title>{{template "style"}}</head><body>
+<h1>PR #{{.PR.Number}}</h1>
+<pre>{{.PR.Body}}</pre>
+<h2>Files with threads</h2>
+<ul>{{range .Files}}<li><a href="/file?path={{.Path}}">{{.Path}}</a> ({{.Threads}})</li>{{end}}</ul>
+<h2>Comments</h2>
+{{range .PR.IssueComments}}<div class="thread">{{template "comment" .}}</div>{{end}}
+</body></html>{{end}}
+
+{{define "file"}}<!DOCTYPE html>
+<html><head><title>{{.Path}}</title>{{template "style"}}</head><body>
+<p><a href="/">PR</a></p>
+<h1>{{.Path}}</h1>
+{{range .FileThreads}}{{template "thread" .}}{{end}}
+<p class="meta">Click a line number to comment on it.</p>
+<table class="code">
+{{range .Lines}}<tr id="L{{.Num}}"><td class="num"><a href="/file?path={{$.Path}}&line={{.Num}}#L{{.Num}}">{{.Num}}</a></td><td><pre>{{.Text}}</pre></td></tr>
+{{if or .Threads .Compose}}<tr><td></td><td>
+{{range .Threads}}{{template "thread" .}}{{end}}
+{{if .Compose}}<form method="post" action="/comment" class="thread">
+<input type="hidden" name="path" value="{{$.Path}}">
+<input type="hidden" name="line" value="{{.Num}}">
+<textarea name="body" rows="4" placeholder="New comment on line {{.Num}}" autofocus></textarea>
+<button>Comment</button>
+</form>{{end}}
+</td></tr>{{end}}
+{{end}}
+</table>
+</body></html>{{end}}
+`))
+
+var (
+	serveIndexTemplate = serveTemplates.Lookup("index")
+	serveFileTemplate  = serveTemplates.Lookup("file")
+)
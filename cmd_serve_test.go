@@ -0,0 +1,89 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/dnr/craft/pkg/craft"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReviewServer(t *testing.T) {
+	pr := &PullRequest{
+		ID:     "PR_test",
+		Number: 7,
+		Body:   "Adds <things>",
+		ReviewThreads: []ReviewThread{{
+			Path: "main.go", Line: 3, DiffSide: DiffSideRight, SubjectType: SubjectTypeLine,
+			Comments: []ReviewComment{{ID: "PRRC_1", Author: Actor{Login: "bob"}, Body: "Why?", CreatedAt: time.Date(2025, 1, 1, 9, 0, 0, 0, time.UTC)}},
+		}},
+	}
+	memfs := fstest.MapFS{"main.go": &fstest.MapFile{Data: []byte("package main\n\nfunc main() {}\n")}}
+	opts := craft.SerializeOptions{FS: memfs}
+	require.NoError(t, craft.Serialize(pr, opts))
+	server := newReviewServer(opts, false)
+
+	get := func(target string) (int, string) {
+		rec := httptest.NewRecorder()
+		server.ServeHTTP(rec, httptest.NewRequest("GET", target, nil))
+		return rec.Code, rec.Body.String()
+	}
+	post := func(form url.Values) int {
+		req := httptest.NewRequest("POST", "/comment", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rec := httptest.NewRecorder()
+		server.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	code, page := get("/")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Contains(t, page, "PR #7")
+	assert.Contains(t, page, "<pre>Adds &lt;things&gt;</pre>")
+	assert.Contains(t, page, `<a href="/file?path=main.go">main.go</a> (1)`)
+
+	code, page = get("/file?path=main.go&line=1")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Contains(t, page, "<pre>func main() {}</pre>")
+	assert.Contains(t, page, "@bob")
+	assert.Contains(t, page, `placeholder="New comment on line 1"`)
+	assert.NotContains(t, page, "╓", "craft lines aren't shown as code")
+
+	code, _ = get("/file?path=../etc/passwd")
+	assert.Equal(t, http.StatusNotFound, code)
+
+	// A reply and a new thread go into the file
+	assert.Equal(t, http.StatusSeeOther, post(url.Values{"path": {"main.go"}, "reply": {"PRRC_1"}, "body": {"Because"}}))
+	assert.Equal(t, http.StatusSeeOther, post(url.Values{"path": {"main.go"}, "line": {"1"}, "body": {"Package doc?"}}))
+	assert.Equal(t, http.StatusBadRequest, post(url.Values{"path": {"main.go"}, "line": {"1"}, "body": {" "}}))
+
+	got, err := craft.Deserialize(opts)
+	require.NoError(t, err)
+	assert.Contains(t, string(memfs[craft.PRStateFile].Data), "Adds <things>", "the description is kept")
+	require.Len(t, got.ReviewThreads, 2)
+	for _, thread := range got.ReviewThreads {
+		last := thread.Comments[len(thread.Comments)-1]
+		assert.True(t, last.IsNew)
+		switch thread.Line {
+		case 1:
+			assert.Equal(t, "Package doc?", last.Body)
+		case 3:
+			assert.Equal(t, "Because", last.Body)
+		default:
+			t.Errorf("unexpected thread on line %d", thread.Line)
+		}
+	}
+
+	// Emoji are shown as Unicode, but the files keep the shortcodes
+	server.renderEmoji = true
+	assert.Equal(t, http.StatusSeeOther, post(url.Values{"path": {"main.go"}, "line": {"1"}, "body": {"Nice :+1:"}}))
+	_, page = get("/file?path=main.go")
+	assert.Contains(t, page, "Nice 👍")
+	assert.Contains(t, string(memfs["main.go"].Data), "Nice :+1:")
+}
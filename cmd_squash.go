@@ -0,0 +1,157 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/dnr/craft/pkg/craft"
+	"github.com/spf13/cobra"
+)
+
+var squashCmd = &cobra.Command{
+	Use:   "squash",
+	Short: "Squash the commits craft made on the PR branch into one",
+	Long: `Each get, suggest and send makes a "craft: ..." commit on the pr-N branch.
+This squashes them into one commit on top of the branch, after any commits of
+your own, which keep their place. With --drop, the craft commits are dropped
+instead, along with the comments in them, leaving the PR's commits and yours.
+
+Craft's commits are told apart by their Craft-Generated trailer (or, for
+older ones, their "craft: " subject). The commits since the PR head in
+PR-STATE.txt are rewritten, with git rebase; --base picks another commit.
+If one of your commits doesn't apply, the rebase stops for you to finish it
+as usual.
+
+Examples:
+  craft squash          Squash the craft commits into one
+  craft squash --drop   Remove the craft commits`,
+	RunE: runSquash,
+	Args: cobra.NoArgs,
+}
+
+var (
+	flagSquashDrop bool
+	flagSquashBase string
+)
+
+func init() {
+	squashCmd.Flags().BoolVar(&flagSquashDrop, "drop", false, "Drop the craft commits, and their comments, instead of squashing them")
+	squashCmd.Flags().StringVar(&flagSquashBase, "base", "", "Rewrite the commits since this one (default: the PR head in PR-STATE.txt)")
+	rootCmd.AddCommand(squashCmd)
+}
+
+func runSquash(cmd *cobra.Command, args []string) error {
+	vcs, err := DetectVCS(".")
+	if err != nil {
+		return err
+	}
+	gitRepo, ok := vcs.(*GitRepo)
+	if !ok {
+		return fmt.Errorf("craft squash is only supported in git repositories")
+	}
+
+	hasChanges, err := vcs.HasUncommittedChanges()
+	if err != nil {
+		return fmt.Errorf("checking for uncommitted changes: %w", err)
+	}
+	if hasChanges {
+		return fmt.Errorf("uncommitted changes detected; commit or stash them first")
+	}
+
+	base := flagSquashBase
+	var prNumber int
+	if base == "" {
+		state, err := readPRStateHeader(craft.SerializeOptions{FS: craft.DirFS(vcs.Root()), VCS: vcs})
+		if err != nil || state.CheckoutOID() == "" {
+			return fmt.Errorf("no PR head in %s; use --base to say where the craft commits start", craft.PRStateFile)
+		}
+		base, prNumber = state.CheckoutOID(), state.Number
+	}
+	if !gitRepo.IsAncestor(base) {
+		return fmt.Errorf("%s is not an ancestor of HEAD", craft.ShortOID(base))
+	}
+
+	commits, err := gitRepo.Log(base)
+	if err != nil {
+		return fmt.Errorf("listing commits: %w", err)
+	}
+	todo, n := squashTodo(commits, flagSquashDrop)
+	if n == 0 {
+		logInfo("No craft commits since %s", craft.ShortOID(base))
+		return nil
+	}
+	if todo == nil {
+		logInfo("The craft commits are already squashed")
+		return nil
+	}
+
+	if flagSquashDrop {
+		logStart("Dropping %d craft commit(s)", n)
+	} else {
+		logStart("Squashing %d craft commit(s)", n)
+	}
+	if err := gitRepo.Rebase(base, todo); err != nil {
+		return fmt.Errorf("rebasing: %w\nfinish with 'git rebase --continue', or undo with 'git rebase --abort'", err)
+	}
+	if !flagSquashDrop {
+		if prNumber == 0 {
+			prNumber, _ = prNumberFromBranch(vcs)
+		}
+		var subjects []string
+		for _, c := range commits {
+			if isCraftCommitMessage(c.Message) {
+				subject, _, _ := strings.Cut(c.Message, "\n")
+				subjects = append(subjects, "- "+subject)
+			}
+		}
+		subject := "craft: review"
+		if prNumber != 0 {
+			subject = fmt.Sprintf("craft: review of PR #%d", prNumber)
+		}
+		msg := craftCommitMessage(subject, strings.Join(subjects, "\n"), prNumber)
+		commitOpts, err := resolveCommitOptions(vcs, false)
+		if err != nil {
+			return err
+		}
+		amend := append([]string{"commit", "--amend", "--allow-empty", "--quiet", "-m", msg}, gitCommitFlags(commitOpts)...)
+		if err := gitRepo.runNoOutput(amend...); err != nil {
+			return fmt.Errorf("rewording the squashed commit: %w", err)
+		}
+	}
+	logEnd("done")
+	return nil
+}
+
+// squashTodo returns the git rebase -i todo that moves the craft commits to
+// the end and squashes them into one, or drops them, and how many there are.
+// The todo is nil if there's nothing to do.
+func squashTodo(commits []LogEntry, drop bool) ([]string, int) {
+	var todo, craft []string
+	for _, c := range commits {
+		if isCraftCommitMessage(c.Message) {
+			craft = append(craft, c.OID)
+		} else {
+			todo = append(todo, "pick "+c.OID)
+		}
+	}
+	if len(craft) == 0 {
+		return nil, 0
+	}
+	if drop {
+		if len(todo) == 0 {
+			todo = []string{"noop"} // an empty todo aborts the rebase
+		}
+		return todo, len(craft)
+	}
+	if len(craft) == 1 && commits[len(commits)-1].OID == craft[0] {
+		return nil, 1
+	}
+	for i, oid := range craft {
+		if i == 0 {
+			todo = append(todo, "pick "+oid)
+		} else {
+			todo = append(todo, "fixup "+oid)
+		}
+	}
+	return todo, len(craft)
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSquashTodo(t *testing.T) {
+	craft := func(oid string) LogEntry { return LogEntry{OID: oid, Message: craftCommitMessage("craft: x", "", 1)} }
+	mine := func(oid string) LogEntry { return LogEntry{OID: oid, Message: "Fix typo"} }
+
+	todo, n := squashTodo([]LogEntry{craft("a"), mine("b"), craft("c"), craft("d")}, false)
+	assert.Equal(t, 3, n)
+	assert.Equal(t, []string{"pick b", "pick a", "fixup c", "fixup d"}, todo)
+
+	todo, n = squashTodo([]LogEntry{craft("a"), mine("b"), craft("c")}, true)
+	assert.Equal(t, 2, n)
+	assert.Equal(t, []string{"pick b"}, todo)
+
+	todo, _ = squashTodo([]LogEntry{craft("a")}, true)
+	assert.Equal(t, []string{"noop"}, todo)
+
+	todo, n = squashTodo([]LogEntry{mine("a"), craft("b")}, false)
+	assert.Equal(t, 1, n)
+	assert.Nil(t, todo)
+
+	_, n = squashTodo([]LogEntry{mine("a")}, false)
+	assert.Equal(t, 0, n)
+}
+
+func TestGitRebaseCraftCommits(t *testing.T) {
+	repo := newTestGitRepo(t, map[string]string{"a.go": "package a\n"})
+	base, err := repo.run("rev-parse", "HEAD")
+	require.NoError(t, err)
+	commit := func(name, content, msg string) {
+		require.NoError(t, os.WriteFile(filepath.Join(repo.root, name), []byte(content), 0644))
+		_, err := repo.run("add", "-A")
+		require.NoError(t, err)
+		_, err = repo.run("commit", "-q", "-m", msg)
+		require.NoError(t, err)
+	}
+	commit("PR-STATE.txt", "state 1\n", craftCommitMessage("craft: PR #1 state", "", 1))
+	commit("b.go", "package a\n", "Add b")
+	commit("PR-STATE.txt", "state 2\n", craftCommitMessage("craft: sent review on PR #1", "", 1))
+
+	commits, err := repo.Log(base)
+	require.NoError(t, err)
+	require.Len(t, commits, 3)
+	assert.Equal(t, "Add b", commits[1].Message)
+
+	todo, n := squashTodo(commits, false)
+	require.Equal(t, 2, n)
+	require.NoError(t, repo.Rebase(base, todo))
+	subjects, err := repo.run("log", "--format=%s", base+"..HEAD")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"craft: PR #1 state", "Add b"}, strings.Split(subjects, "\n"))
+	state, err := os.ReadFile(filepath.Join(repo.root, "PR-STATE.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "state 2\n", string(state))
+
+	commits, err = repo.Log(base)
+	require.NoError(t, err)
+	todo, _ = squashTodo(commits, true)
+	require.NoError(t, repo.Rebase(base, todo))
+	subjects, err = repo.run("log", "--format=%s", base+"..HEAD")
+	require.NoError(t, err)
+	assert.Equal(t, "Add b", subjects)
+	assert.NoFileExists(t, filepath.Join(repo.root, "PR-STATE.txt"))
+}
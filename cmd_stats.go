@@ -0,0 +1,146 @@
+package main
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"slices"
+
+	"github.com/dnr/craft/pkg/craft"
+	"github.com/spf13/cobra"
+)
+
+var statsCmd = &cobra.Command{
+	Use:   "stats",
+	Short: "Summarize the review threads",
+	Long: `Prints a summary of the review in the files: how many threads are
+resolved, threads and comments by author, how long threads get, the files
+with the most comments, and how many new comments haven't been sent.`,
+	RunE: runStats,
+	Args: cobra.NoArgs,
+}
+
+func init() {
+	rootCmd.AddCommand(statsCmd)
+}
+
+func runStats(cmd *cobra.Command, args []string) error {
+	vcs, err := DetectVCS(".")
+	if err != nil {
+		return err
+	}
+	pr, err := craft.Deserialize(craft.SerializeOptions{FS: craft.DirFS(vcs.Root()), VCS: vcs})
+	if errors.As(err, new(craft.ParseErrors)) {
+		logWarn("%v", err)
+	} else if err != nil {
+		return fmt.Errorf("reading PR state: %w", err)
+	}
+	computeStats(pr).write(os.Stdout)
+	return nil
+}
+
+// reviewStats summarizes the threads and comments of a PR.
+type reviewStats struct {
+	Threads, Resolved, Outdated int
+	Replies                     int // comments after the first in each thread
+	Authors                     []authorStats
+	Files                       []fileStats // most comments first
+	NewComments                 int         // not sent yet, PR-level ones included
+}
+
+type authorStats struct {
+	Login             string
+	Threads, Comments int
+}
+
+type fileStats struct {
+	Path     string
+	Comments int
+}
+
+// maxStatsFiles is how many files stats lists.
+const maxStatsFiles = 5
+
+func computeStats(pr *PullRequest) reviewStats {
+	var s reviewStats
+	authors := make(map[string]*authorStats)
+	author := func(c ReviewComment) *authorStats {
+		login := c.Author.Login
+		if c.IsNew {
+			login = "(new)"
+		}
+		if authors[login] == nil {
+			authors[login] = &authorStats{Login: login}
+		}
+		return authors[login]
+	}
+	files := make(map[string]int)
+
+	for _, thread := range pr.ReviewThreads {
+		if len(thread.Comments) == 0 {
+			continue
+		}
+		s.Threads++
+		if thread.IsResolved {
+			s.Resolved++
+		}
+		if thread.IsOutdated {
+			s.Outdated++
+		}
+		s.Replies += len(thread.Comments) - 1
+		author(thread.Comments[0]).Threads++
+		for _, c := range thread.Comments {
+			author(c).Comments++
+			if c.IsNew {
+				s.NewComments++
+			}
+		}
+		files[thread.Path] += len(thread.Comments)
+	}
+	for _, c := range pr.IssueComments {
+		if c.IsNew {
+			s.NewComments++
+		}
+	}
+
+	for _, a := range authors {
+		s.Authors = append(s.Authors, *a)
+	}
+	slices.SortFunc(s.Authors, func(a, b authorStats) int {
+		return cmp.Or(b.Comments-a.Comments, cmp.Compare(a.Login, b.Login))
+	})
+	for _, path := range slices.Sorted(maps.Keys(files)) {
+		s.Files = append(s.Files, fileStats{Path: path, Comments: files[path]})
+	}
+	slices.SortStableFunc(s.Files, func(a, b fileStats) int { return b.Comments - a.Comments })
+	s.Files = s.Files[:min(len(s.Files), maxStatsFiles)]
+	return s
+}
+
+func (s reviewStats) write(w io.Writer) {
+	fmt.Fprintf(w, "Threads: %d (%d resolved, %d unresolved, %d outdated)\n",
+		s.Threads, s.Resolved, s.Threads-s.Resolved, s.Outdated)
+	if s.Threads > 0 {
+		fmt.Fprintf(w, "Replies per thread: %.1f\n", float64(s.Replies)/float64(s.Threads))
+	}
+	fmt.Fprintf(w, "New comments not sent: %d\n", s.NewComments)
+	if len(s.Authors) > 0 {
+		fmt.Fprintln(w, "\nBy author:")
+		for _, a := range s.Authors {
+			name := a.Login
+			if name != "(new)" {
+				name = "@" + name
+			}
+			fmt.Fprintf(w, "  %-20s %3d threads %4d comments\n", name, a.Threads, a.Comments)
+		}
+	}
+	if len(s.Files) > 0 {
+		fmt.Fprintln(w, "\nMost commented files:")
+		for _, f := range s.Files {
+			fmt.Fprintf(w, "  %4d  %s\n", f.Comments, f.Path)
+		}
+	}
+}
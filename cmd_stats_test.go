@@ -0,0 +1,42 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestComputeStats(t *testing.T) {
+	comment := func(login string) ReviewComment { return ReviewComment{Author: Actor{Login: login}} }
+	pr := &PullRequest{
+		ReviewThreads: []ReviewThread{
+			{Path: "a.go", IsResolved: true, Comments: []ReviewComment{comment("bob"), comment("alice"), comment("bob")}},
+			{Path: "b.go", IsOutdated: true, Comments: []ReviewComment{comment("bob")}},
+			{Path: "a.go", Comments: []ReviewComment{comment("carol"), {Body: "reply", IsNew: true}}},
+			{Path: "c.go"},
+		},
+		IssueComments: []IssueComment{{Author: Actor{Login: "bob"}}, {Body: "LGTM", IsNew: true}},
+	}
+
+	s := computeStats(pr)
+	assert.Equal(t, 3, s.Threads)
+	assert.Equal(t, 1, s.Resolved)
+	assert.Equal(t, 1, s.Outdated)
+	assert.Equal(t, 3, s.Replies)
+	assert.Equal(t, 2, s.NewComments)
+	assert.Equal(t, []authorStats{
+		{Login: "bob", Threads: 2, Comments: 3},
+		{Login: "(new)", Threads: 0, Comments: 1},
+		{Login: "alice", Threads: 0, Comments: 1},
+		{Login: "carol", Threads: 1, Comments: 1},
+	}, s.Authors)
+	assert.Equal(t, []fileStats{{Path: "a.go", Comments: 5}, {Path: "b.go", Comments: 1}}, s.Files)
+
+	var buf strings.Builder
+	s.write(&buf)
+	assert.Contains(t, buf.String(), "Threads: 3 (1 resolved, 2 unresolved, 1 outdated)\n")
+	assert.Contains(t, buf.String(), "Replies per thread: 1.0\n")
+	assert.Contains(t, buf.String(), "New comments not sent: 2\n")
+	assert.Contains(t, buf.String(), "  @bob ")
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/dnr/craft/pkg/craft"
+	"github.com/spf13/cobra"
+)
+
+var statusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show the PR being reviewed",
+	Long: `Prints which PR the files hold the review of, whether it's a draft, merged
+or closed, the commit reviewed, and how many threads and unsent comments
+there are.
+
+Then, from GitHub, it shows what's happened since your last review: whether
+the author has pushed since, and which of your unresolved threads have
+replies. That helps decide whether to re-review (with 'craft get') after
+requesting changes. --offline only reads PR-STATE.txt and the files.`,
+	RunE: runStatus,
+	Args: cobra.NoArgs,
+}
+
+var flagStatusOffline bool
+
+func init() {
+	statusCmd.Flags().BoolVar(&flagStatusOffline, "offline", false, "Don't check GitHub for what's happened since your last review")
+	rootCmd.AddCommand(statusCmd)
+}
+
+func runStatus(cmd *cobra.Command, args []string) error {
+	vcs, err := DetectVCS(".")
+	if err != nil {
+		return err
+	}
+	pr, err := craft.Deserialize(craft.SerializeOptions{FS: craft.DirFS(vcs.Root()), VCS: vcs})
+	if errors.As(err, new(craft.ParseErrors)) {
+		logWarn("%v", err)
+	} else if err != nil {
+		return fmt.Errorf("reading PR state: %w", err)
+	}
+	color := useColor(os.Stdout)
+	writeStatus(os.Stdout, pr, color)
+	if flagStatusOffline || pr.IsLocal || pr.Number == 0 {
+		return nil
+	}
+
+	client, owner, repo, err := getGitHubClientAndRepo(vcs, resolveRemote(vcs, ""))
+	if err != nil {
+		return fmt.Errorf("%w\nuse --offline to only show the local state", err)
+	}
+	ctx := cmd.Context()
+	logStart("Checking GitHub")
+	me, err := client.FetchViewerLogin(ctx)
+	if err != nil {
+		return err
+	}
+	remote, err := client.FetchPullRequest(ctx, owner, repo, pr.Number)
+	if err != nil {
+		return fmt.Errorf("fetching PR: %w", err)
+	}
+	logEnd("done")
+	fmt.Println()
+	computeFollowUp(remote, me).write(os.Stdout, color)
+	return nil
+}
+
+// reviewFollowUp is what's happened on a PR since the viewer's last review.
+type reviewFollowUp struct {
+	Review  *Review // the viewer's last submitted review, or nil
+	Head    string  // the PR's head now
+	Threads []threadFollowUp
+}
+
+// threadFollowUp is one of the viewer's unresolved threads.
+type threadFollowUp struct {
+	Path      string
+	Line      int
+	RepliedBy []string // who commented after the viewer last did
+}
+
+// PushedSince reports whether the PR's head moved after the review.
+func (f reviewFollowUp) PushedSince() bool {
+	return f.Review != nil && f.Review.CommitOID != "" && f.Review.CommitOID != f.Head
+}
+
+// computeFollowUp finds me's last submitted review of remote, and the
+// unresolved threads me started.
+func computeFollowUp(remote *PullRequest, me string) reviewFollowUp {
+	f := reviewFollowUp{Head: remote.HeadRefOID}
+	for i, r := range remote.Reviews {
+		if !strings.EqualFold(r.Author.Login, me) || r.SubmittedAt == nil || r.State == ReviewStatePending {
+			continue
+		}
+		if f.Review == nil || r.SubmittedAt.After(*f.Review.SubmittedAt) {
+			f.Review = &remote.Reviews[i]
+		}
+	}
+
+	for _, thread := range remote.ReviewThreads {
+		if thread.IsResolved || len(thread.Comments) == 0 || !strings.EqualFold(thread.Comments[0].Author.Login, me) {
+			continue
+		}
+		t := threadFollowUp{Path: thread.Path, Line: cmp.Or(thread.Line, thread.OriginalLine)}
+		mine := 0
+		for i, c := range thread.Comments {
+			if strings.EqualFold(c.Author.Login, me) {
+				mine = i
+			}
+		}
+		for _, c := range thread.Comments[mine+1:] {
+			if !slices.Contains(t.RepliedBy, c.Author.Login) {
+				t.RepliedBy = append(t.RepliedBy, c.Author.Login)
+			}
+		}
+		f.Threads = append(f.Threads, t)
+	}
+	return f
+}
+
+func (f reviewFollowUp) write(w io.Writer, color bool) {
+	if f.Review == nil {
+		fmt.Fprintln(w, "You haven't reviewed this PR")
+	} else {
+		state := string(f.Review.State)
+		if f.Review.State == ReviewStateChangesRequested {
+			state = paint(color, ansiBold+ansiYellow, state)
+		}
+		fmt.Fprintf(w, "Your last review: %s at %s\n", state, f.Review.SubmittedAt.Local().Format(craft.TimelineTimeFormat))
+		if f.PushedSince() {
+			fmt.Fprintf(w, "Pushed since: yes, %s -> %s\n", craft.ShortOID(f.Review.CommitOID), craft.ShortOID(f.Head))
+		} else if f.Review.CommitOID != "" {
+			fmt.Fprintln(w, "Pushed since: no")
+		}
+	}
+
+	replied := 0
+	if len(f.Threads) > 0 {
+		fmt.Fprintf(w, "Your unresolved threads: %d\n", len(f.Threads))
+	}
+	for _, t := range f.Threads {
+		status := "no reply"
+		if len(t.RepliedBy) > 0 {
+			replied++
+			var who []string
+			for _, login := range t.RepliedBy {
+				who = append(who, "@"+login)
+			}
+			status = paint(color, ansiGreen, "replied by "+strings.Join(who, ", "))
+		}
+		fmt.Fprintf(w, "  %s:%d: %s\n", t.Path, t.Line, status)
+	}
+
+	if f.Review != nil && f.Review.State == ReviewStateChangesRequested {
+		if f.PushedSince() || replied > 0 {
+			fmt.Fprintln(w, "The author has followed up; 'craft get' to re-review")
+		} else {
+			fmt.Fprintln(w, "Nothing new since you requested changes")
+		}
+	}
+}
+
+// prStateLabel returns how the PR's state is shown: DRAFT, MERGED or CLOSED
+// in capitals, since those change what to do with the review, or "open".
+func prStateLabel(pr *PullRequest) string {
+	switch {
+	case pr.IsClosed():
+		return pr.State
+	case pr.IsDraft:
+		return craft.DraftPRField
+	}
+	return "open"
+}
+
+func writeStatus(w io.Writer, pr *PullRequest, color bool) {
+	if pr.IsLocal {
+		fmt.Fprintln(w, "Local review (no PR)")
+	} else {
+		label := prStateLabel(pr)
+		if label != "open" {
+			label = paint(color, ansiBold+ansiYellow, label)
+		}
+		fmt.Fprintf(w, "PR #%d", pr.Number)
+		if pr.Author.Login != "" {
+			fmt.Fprintf(w, " by @%s", pr.Author.Login)
+		}
+		fmt.Fprintf(w, ": %s\n", label)
+	}
+	if pr.InPlace {
+		fmt.Fprintln(w, "In place on your branch")
+	}
+	if pr.HeadRefOID != "" {
+		fmt.Fprintf(w, "Head: %s\n", craft.ShortOID(pr.HeadRefOID))
+	}
+	if pr.ReviewCommitOID != "" {
+		fmt.Fprintf(w, "Reviewing commit: %s\n", craft.ShortOID(pr.ReviewCommitOID))
+	}
+	if pr.StackParent != 0 {
+		fmt.Fprintf(w, "Stacked on PR #%d\n", pr.StackParent)
+	}
+	s := computeStats(pr)
+	fmt.Fprintf(w, "Threads: %d (%d resolved, %d unresolved)\n", s.Threads, s.Resolved, s.Threads-s.Resolved)
+	fmt.Fprintf(w, "New comments not sent: %d\n", s.NewComments)
+}
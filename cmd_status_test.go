@@ -0,0 +1,93 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteStatus(t *testing.T) {
+	pr := &PullRequest{
+		Number:     7,
+		Author:     Actor{Login: "alice"},
+		IsDraft:    true,
+		HeadRefOID: "abc123def4567890",
+		ReviewThreads: []ReviewThread{
+			{Comments: []ReviewComment{{Author: Actor{Login: "bob"}}}, IsResolved: true},
+			{Comments: []ReviewComment{{Author: Actor{Login: "bob"}}, {IsNew: true}}},
+		},
+	}
+	var buf strings.Builder
+	writeStatus(&buf, pr, false)
+	assert.Equal(t, `PR #7 by @alice: DRAFT
+Head: abc123def456
+Threads: 2 (1 resolved, 1 unresolved)
+New comments not sent: 1
+`, buf.String())
+
+	pr.State = "MERGED"
+	buf.Reset()
+	writeStatus(&buf, pr, true)
+	assert.Contains(t, buf.String(), ": "+ansiBold+ansiYellow+"MERGED"+ansiReset+"\n")
+
+	buf.Reset()
+	writeStatus(&buf, &PullRequest{Number: 8}, false)
+	assert.Contains(t, buf.String(), "PR #8: open\n")
+}
+
+func TestReviewFollowUp(t *testing.T) {
+	at := func(day int) *time.Time {
+		t := time.Date(2025, 1, day, 12, 0, 0, 0, time.Local)
+		return &t
+	}
+	comment := func(login string) ReviewComment { return ReviewComment{Author: Actor{Login: login}} }
+	remote := &PullRequest{
+		HeadRefOID: "def456def456def456",
+		Reviews: []Review{
+			{Author: Actor{Login: "me"}, State: ReviewStateCommented, SubmittedAt: at(1), CommitOID: "aaa111aaa111aaa"},
+			{Author: Actor{Login: "Me"}, State: ReviewStateChangesRequested, SubmittedAt: at(2), CommitOID: "abc123abc123abc"},
+			{Author: Actor{Login: "bob"}, State: ReviewStateApproved, SubmittedAt: at(3)},
+			{Author: Actor{Login: "me"}, State: ReviewStatePending},
+		},
+		ReviewThreads: []ReviewThread{
+			{Path: "a.go", Line: 12, Comments: []ReviewComment{comment("me"), comment("alice"), comment("me"), comment("alice"), comment("bob"), comment("alice")}},
+			{Path: "b.go", OriginalLine: 3, Comments: []ReviewComment{comment("me")}},
+			{Path: "c.go", Line: 1, IsResolved: true, Comments: []ReviewComment{comment("me"), comment("alice")}},
+			{Path: "d.go", Line: 1, Comments: []ReviewComment{comment("alice"), comment("me")}},
+		},
+	}
+
+	f := computeFollowUp(remote, "me")
+	require.NotNil(t, f.Review)
+	assert.Equal(t, ReviewStateChangesRequested, f.Review.State)
+	assert.True(t, f.PushedSince())
+	assert.Equal(t, []threadFollowUp{
+		{Path: "a.go", Line: 12, RepliedBy: []string{"alice", "bob"}},
+		{Path: "b.go", Line: 3},
+	}, f.Threads)
+
+	var buf strings.Builder
+	f.write(&buf, false)
+	assert.Equal(t, `Your last review: CHANGES_REQUESTED at 2025-01-02 12:00
+Pushed since: yes, abc123abc123 -> def456def456
+Your unresolved threads: 2
+  a.go:12: replied by @alice, @bob
+  b.go:3: no reply
+The author has followed up; 'craft get' to re-review
+`, buf.String())
+
+	// Nothing since
+	remote.HeadRefOID = "abc123abc123abc"
+	remote.ReviewThreads = remote.ReviewThreads[1:2]
+	buf.Reset()
+	computeFollowUp(remote, "me").write(&buf, false)
+	assert.Contains(t, buf.String(), "Pushed since: no\n")
+	assert.Contains(t, buf.String(), "Nothing new since you requested changes\n")
+
+	buf.Reset()
+	computeFollowUp(remote, "carol").write(&buf, false)
+	assert.Equal(t, "You haven't reviewed this PR\n", buf.String())
+}
@@ -1,13 +1,16 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
 	"regexp"
+	"slices"
 	"sort"
 	"strings"
 
+	"github.com/dnr/craft/pkg/craft"
 	"github.com/spf13/cobra"
 )
 
@@ -26,24 +29,34 @@ The workflow is:
 4. Run 'craft send' to post the suggestions to GitHub
 
 Changes are classified as:
-- Code modifications: suggestion blocks
+- Code modifications: suggestion blocks, with comments (code, craft or
+  shorthand) added just before or after one as the suggestion's message
 - Added code comments: regular craft comments
 - Pure code additions: skipped (warning shown)
 - Lines with craft box chars: skipped (already comments)
+- New files: skipped, or with --new-files, proposed in a file-level comment
+  on the PR's nearest file (by directory, then name) and removed
 
 Examples:
-  craft suggest            Convert edits and commit
-  craft suggest --dry-run  Show what would be done without changing files`,
+  craft suggest              Convert edits and commit
+  craft suggest --dry-run    Show what would be done without changing files
+  craft suggest --new-files  Also propose files you added, e.g. a test`,
 	RunE: runSuggest,
 	Args: cobra.NoArgs,
 }
 
 var (
-	flagSuggestDryRun bool
+	flagSuggestDryRun   bool
+	flagSuggestNoCommit bool
+	flagSuggestNoSign   bool
+	flagSuggestNewFiles bool
 )
 
 func init() {
 	suggestCmd.Flags().BoolVar(&flagSuggestDryRun, "dry-run", false, "Show what would be done without modifying files")
+	suggestCmd.Flags().BoolVar(&flagSuggestNoCommit, "no-commit", false, "Don't commit the suggestions (default: from the autoCommit setting)")
+	suggestCmd.Flags().BoolVar(&flagSuggestNoSign, "no-sign", false, "Don't sign the commit, even if git or jj is configured to")
+	suggestCmd.Flags().BoolVar(&flagSuggestNewFiles, "new-files", false, "Propose files added since the PR head in comments on the PR's nearest files")
 	rootCmd.AddCommand(suggestCmd)
 }
 
@@ -63,6 +76,7 @@ type Hunk struct {
 	OldStart, OldCount int      // Line range in old file
 	NewStart, NewCount int      // Line range in new file
 	OldLines, NewLines []string // Lines removed/added (without -/+ prefix)
+	Message            []string // Comment text folded into a suggestion, by classifyHunk
 
 	Classification HunkClassification // Set by classifyHunk
 }
@@ -73,31 +87,34 @@ func runSuggest(cmd *cobra.Command, args []string) error {
 	if err != nil {
 		return err
 	}
-	fmt.Printf("Using %s repository at %s\n", vcs.Name(), vcs.Root())
+	logInfo("Using %s repository at %s", vcs.Name(), vcs.Root())
 
 	// Read PR state to get head commit
-	opts := SerializeOptions{FS: DirFS(vcs.Root()), VCS: vcs}
-	pr, err := Deserialize(opts)
-	if err != nil {
+	opts := craft.SerializeOptions{FS: craft.DirFS(vcs.Root()), VCS: vcs}
+	pr, err := craft.Deserialize(opts)
+	if errors.As(err, new(craft.ParseErrors)) {
+		logWarn("%v", err)
+	} else if err != nil {
 		return fmt.Errorf("reading PR state: %w", err)
 	}
 
 	if pr.HeadRefOID == "" {
 		return fmt.Errorf("no head commit in PR-STATE.txt, run 'craft get' first")
 	}
-	fmt.Printf("PR head: %s\n", pr.HeadRefOID[:12])
+	head := pr.CheckoutOID()
+	logInfo("PR head: %s", head[:12])
 
 	// Get list of modified files (comparing PR head to current working tree)
-	files, err := vcs.GetModifiedFiles(pr.HeadRefOID)
+	files, err := vcs.GetModifiedFiles(head)
 	if err != nil {
 		return fmt.Errorf("getting modified files: %w", err)
 	}
 
 	if len(files) == 0 {
-		fmt.Println("No modified files found.")
+		logInfo("No modified files found.")
 		return nil
 	}
-	fmt.Printf("Modified files: %d\n", len(files))
+	logInfo("Modified files: %d", len(files))
 
 	// Process each file
 	var stats struct {
@@ -107,16 +124,25 @@ func runSuggest(cmd *cobra.Command, args []string) error {
 	}
 
 	root := vcs.Root()
+	var newFiles []string
 
 	for _, path := range files {
-		// Skip PR-STATE.txt
-		if path == prStateFile {
+		// Skip PR-STATE.txt and PR-OUTDATED.txt
+		if craft.IsReviewFile(path) {
 			continue
 		}
 
-		result, err := processFileForSuggestions(vcs, root, pr.HeadRefOID, path, flagSuggestDryRun)
+		result, err := processFileForSuggestions(vcs, root, head, path, flagSuggestDryRun)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "Warning: %s: %v\n", path, err)
+			logWarn("%s: %v", path, err)
+			continue
+		}
+		if result.newFile {
+			if flagSuggestNewFiles {
+				newFiles = append(newFiles, path)
+			} else {
+				logWarn("%s: file not in PR head, skipping (use --new-files to propose it in a comment)", path)
+			}
 			continue
 		}
 
@@ -125,22 +151,39 @@ func runSuggest(cmd *cobra.Command, args []string) error {
 		stats.warnings += result.warnings
 	}
 
+	if len(newFiles) > 0 {
+		n, err := suggestNewFiles(vcs, pr, newFiles, flagSuggestDryRun)
+		if err != nil {
+			return err
+		}
+		stats.craftComments += n
+	}
+
 	// Summary
-	fmt.Printf("\nResults:\n")
-	fmt.Printf("  %d suggestions created\n", stats.suggestions)
-	fmt.Printf("  %d craft comments created\n", stats.craftComments)
+	logInfo("Results:")
+	logInfo("  %d suggestions created", stats.suggestions)
+	logInfo("  %d craft comments created", stats.craftComments)
 	if stats.warnings > 0 {
-		fmt.Printf("  %d warnings (pure additions skipped)\n", stats.warnings)
+		logInfo("  %d warnings (pure additions skipped)", stats.warnings)
 	}
 
 	// Commit if not dry-run
-	if !flagSuggestDryRun && (stats.suggestions > 0 || stats.craftComments > 0) {
-		fmt.Print("\nCommitting changes... ")
-		commitMsg := fmt.Sprintf("craft: convert %d edits to suggestions", stats.suggestions+stats.craftComments)
-		if err := vcs.Commit(commitMsg); err != nil {
+	commit, err := resolveAutoCommit(vcs, flagSuggestNoCommit)
+	if err != nil {
+		return err
+	}
+	commitOpts, err := resolveCommitOptions(vcs, flagSuggestNoSign)
+	if err != nil {
+		return err
+	}
+	if commit && !flagSuggestDryRun && (stats.suggestions > 0 || stats.craftComments > 0) {
+		logStart("\nCommitting changes")
+		prNumber, _ := prNumberFromBranch(vcs) // 0 off a pr-N branch
+		commitMsg := craftCommitMessage(fmt.Sprintf("craft: convert %d edits to suggestions", stats.suggestions+stats.craftComments), "", prNumber)
+		if err := vcs.Commit(commitMsg, commitOpts); err != nil {
 			return fmt.Errorf("committing: %w", err)
 		}
-		fmt.Println("done")
+		logEnd("done")
 	}
 
 	return nil
@@ -150,6 +193,7 @@ type processResult struct {
 	suggestions   int
 	craftComments int
 	warnings      int
+	newFile       bool // not in the PR head, so left as it is
 }
 
 // transformResult holds the output of transformFileWithSuggestions.
@@ -172,7 +216,7 @@ func transformFileWithSuggestions(originalContent, diffOutput, path string) tran
 	}
 
 	originalLines := strings.Split(originalContent, "\n")
-	style := getCommentStyle(path)
+	style := craft.GetCommentStyle(path)
 
 	// Classify each hunk
 	for _, hunk := range hunks {
@@ -190,7 +234,7 @@ func transformFileWithSuggestions(originalContent, diffOutput, path string) tran
 		case HunkWarnMixed:
 			result.Stats.warnings++
 			result.Warnings = append(result.Warnings,
-				fmt.Sprintf("%s:%d: craft comments mixed with code changes, skipping (use ,S to add comments to suggestions)", path, hunk.NewStart))
+				fmt.Sprintf("%s:%d: craft comments mixed with code changes, skipping (new comments go in a suggestion just before or after the change)", path, hunk.NewStart))
 		}
 	}
 
@@ -216,9 +260,9 @@ func transformFileWithSuggestions(originalContent, diffOutput, path string) tran
 		// Get indent from the first old line (or first new line if pure add)
 		indent := ""
 		if len(hunk.OldLines) > 0 {
-			indent = getIndent(hunk.OldLines[0])
+			indent = craft.GetIndent(hunk.OldLines[0])
 		} else if len(hunk.NewLines) > 0 {
-			indent = getIndent(hunk.NewLines[0])
+			indent = craft.GetIndent(hunk.NewLines[0])
 		}
 
 		var commentLines []string
@@ -275,8 +319,8 @@ func processFileForSuggestions(vcs VCS, root, headCommit, path string, dryRun bo
 	originalContent, err := vcs.GetFileAtCommit(headCommit, path)
 	if err != nil {
 		// File might not exist at head commit (newly added file)
-		// All changes would be pure additions, skip with warning
-		fmt.Fprintf(os.Stderr, "Warning: %s: file not in PR head, skipping (new file?)\n", path)
+		// All changes would be pure additions, left to the caller
+		result.newFile = true
 		return result, nil
 	}
 
@@ -286,7 +330,7 @@ func processFileForSuggestions(vcs VCS, root, headCommit, path string, dryRun bo
 
 	// Print warnings
 	for _, warning := range transformed.Warnings {
-		fmt.Fprintf(os.Stderr, "Warning: %s\n", warning)
+		logWarn("%s", warning)
 	}
 
 	if result.suggestions == 0 && result.craftComments == 0 {
@@ -300,16 +344,135 @@ func processFileForSuggestions(vcs VCS, root, headCommit, path string, dryRun bo
 			fmt.Printf("%4d: %s\n", i+1, line)
 		}
 	} else {
-		fullPath := filepath.Join(root, path)
-		if err := os.WriteFile(fullPath, []byte(transformed.Content), 0644); err != nil {
+		if err := craft.WriteFile(craft.DirFS(root), path, []byte(transformed.Content)); err != nil {
 			return result, fmt.Errorf("writing file: %w", err)
 		}
-		fmt.Printf("  %s: %d suggestions, %d comments\n", path, result.suggestions, result.craftComments)
+		logInfo("  %s: %d suggestions, %d comments", path, result.suggestions, result.craftComments)
 	}
 
 	return result, nil
 }
 
+// suggestNewFiles turns files added since the PR head into file-level
+// comments proposing them, each on the file of the PR nearest it, since
+// GitHub only takes comments on the PR's files, and removes them. It returns
+// how many it turned into comments.
+func suggestNewFiles(vcs VCS, pr *PullRequest, paths []string, dryRun bool) (int, error) {
+	root := vcs.Root()
+	changed, err := vcs.GetModifiedFiles(pr.EffectiveBase())
+	if err != nil {
+		return 0, fmt.Errorf("listing the PR's files: %w", err)
+	}
+	// The PR's files are those changed since the base and still there at
+	// the head; others changed since the base are the reviewer's
+	var candidates []string
+	for _, path := range changed {
+		if craft.IsReviewFile(path) || slices.Contains(paths, path) {
+			continue
+		}
+		head, err := vcs.GetFileAtCommit(pr.CheckoutOID(), path)
+		if err != nil {
+			continue // deleted by the PR
+		}
+		if base, err := vcs.GetFileAtCommit(pr.EffectiveBase(), path); err == nil && base == head {
+			continue
+		}
+		candidates = append(candidates, path)
+	}
+
+	n := 0
+	for _, path := range paths {
+		target := nearestFile(path, candidates)
+		if target == "" {
+			logWarn("%s: no file in the PR to propose it on, skipping", path)
+			continue
+		}
+		added, err := craft.ReadFile(craft.DirFS(root), path)
+		if err != nil {
+			return n, err
+		}
+		content, err := craft.ReadFile(craft.DirFS(root), target)
+		if err != nil {
+			return n, err
+		}
+		comment := newFileComment(path, string(added), craft.GetCommentStyle(target))
+		result := insertFileComment(string(content), target, comment)
+
+		if dryRun {
+			fmt.Printf("\n--- %s (dry-run, proposing %s) ---\n", target, path)
+			for i, line := range strings.Split(result, "\n") {
+				fmt.Printf("%4d: %s\n", i+1, line)
+			}
+		} else {
+			if err := craft.WriteFile(craft.DirFS(root), target, []byte(result)); err != nil {
+				return n, fmt.Errorf("writing file: %w", err)
+			}
+			if err := os.Remove(filepath.Join(root, path)); err != nil {
+				return n, err
+			}
+			logInfo("  %s: proposed in a comment on %s", path, target)
+		}
+		n++
+	}
+	return n, nil
+}
+
+// nearestFile returns the candidate sharing the most leading directories with
+// path, then the longest start of its name, e.g. foo.go for foo_test.go, or
+// "" if there are none.
+func nearestFile(path string, candidates []string) string {
+	dirs := strings.Split(filepath.ToSlash(filepath.Dir(path)), "/")
+	name := filepath.Base(path)
+	best, bestDirs, bestName := "", -1, -1
+	for _, c := range slices.Sorted(slices.Values(candidates)) {
+		cdirs := strings.Split(filepath.ToSlash(filepath.Dir(c)), "/")
+		shared := 0
+		for shared < len(dirs) && shared < len(cdirs) && dirs[shared] == cdirs[shared] {
+			shared++
+		}
+		cname := filepath.Base(c)
+		sharedName := 0
+		for sharedName < len(name) && sharedName < len(cname) && name[sharedName] == cname[sharedName] {
+			sharedName++
+		}
+		if shared > bestDirs || shared == bestDirs && sharedName > bestName {
+			best, bestDirs, bestName = c, shared, sharedName
+		}
+	}
+	return best
+}
+
+// newFileComment builds a new file-level comment proposing a file at path
+// with content, in a code block.
+func newFileComment(path, content string, style craft.CommentStyle) []string {
+	content = strings.TrimSuffix(content, "\n")
+	fence := markdownFence(content)
+	lines := []string{
+		craft.FormatCraftLine(style.LinePrefix, craft.BoxThread, craft.HeaderStart+" new"+craft.HeaderFieldSep+"file"),
+		craft.FormatCraftLine(style.LinePrefix, craft.BoxBody, "Suggest adding `"+path+"`:"),
+		craft.FormatCraftLine(style.LinePrefix, craft.BoxBody, ""),
+		craft.FormatCraftLine(style.LinePrefix, craft.BoxBody, fence),
+	}
+	for _, line := range strings.Split(content, "\n") {
+		lines = append(lines, craft.FormatCraftLine(style.LinePrefix, craft.BoxBody, line))
+	}
+	return append(lines, craft.FormatCraftLine(style.LinePrefix, craft.BoxBody, fence))
+}
+
+// insertFileComment inserts the lines of a file-level comment into the
+// content of the file at path, after the file-level threads at its top.
+func insertFileComment(content, path string, comment []string) string {
+	enc, content := craft.DecodeFile(content)
+	lines := strings.Split(content, "\n")
+	parsed := craft.ParseCraftLines(lines, craft.GetCommentStyle(path).LinePrefix)
+	i := 0
+	for i < len(parsed) && parsed[i].OK && parsed[i].Box != craft.BoxStart && parsed[i].Box != craft.BoxChange {
+		i++
+	}
+	lines = append(lines[:i], append(comment, lines[i:]...)...)
+	return enc.Encode(strings.Join(lines, "\n"))
+}
+
 // getFileHunks returns parsed diff hunks for a file.
 func getFileHunks(vcs VCS, commit, path string) ([]*Hunk, error) {
 	diffOutput, err := vcs.GetFileDiff(commit, path)
@@ -330,7 +493,7 @@ func CheckForNonCraftChanges(vcs VCS, headCommit string) error {
 	var problems []string
 
 	for _, path := range files {
-		if path == prStateFile {
+		if craft.IsReviewFile(path) {
 			continue
 		}
 
@@ -341,7 +504,7 @@ func CheckForNonCraftChanges(vcs VCS, headCommit string) error {
 			continue
 		}
 
-		style := getCommentStyle(path)
+		style := craft.GetCommentStyle(path)
 
 		for _, hunk := range hunks {
 			switch classifyHunk(hunk, style) {
@@ -423,30 +586,31 @@ func parseUnifiedDiff(diff string) (hunks []*Hunk) {
 }
 
 // classifyHunk determines what to do with a hunk and sets hunk.Classification.
-func classifyHunk(hunk *Hunk, style commentStyle) (classification HunkClassification) {
+func classifyHunk(hunk *Hunk, style craft.CommentStyle) (classification HunkClassification) {
 	defer func() { hunk.Classification = classification }()
 
 	// Filter out empty lines and craft comment lines from new lines
 	var filteredNewLines []string
 	for _, line := range hunk.NewLines {
-		if line != "" && !isCraftCommentLine(line) {
+		if line != "" && !isCraftCommentLine(line) && !craft.IsShorthandLine(line, style) {
 			filteredNewLines = append(filteredNewLines, line)
 		}
 	}
 
-	hasCraftComments := len(filteredNewLines) < len(hunk.NewLines)
-
 	// If all new lines were craft comments and no deletions, preserve as-is
 	if len(filteredNewLines) == 0 && len(hunk.OldLines) == 0 {
 		return HunkCraftComment
 	}
 
-	// If there are deletions, this is a code change -> suggestion
+	// If there are deletions, this is a code change -> suggestion, with any
+	// comments just before or after it as its message
 	if len(hunk.OldLines) > 0 {
-		// But if there are also craft comments mixed in, warn
-		if hasCraftComments {
+		message, code, ok := splitSuggestionMessage(*hunk, style)
+		if !ok {
+			// Craft comments mixed in with the code, warn
 			return HunkWarnMixed
 		}
+		hunk.Message, hunk.NewLines = message, code
 		return HunkSuggestion
 	}
 
@@ -467,35 +631,153 @@ func classifyHunk(hunk *Hunk, style commentStyle) (classification HunkClassifica
 	return HunkWarnPureAdd
 }
 
+// splitSuggestionMessage splits the comments added just before and after a
+// code change off its new lines, returning their text, for the suggestion's
+// message, and the code left. Those are new craft comments, shorthand, and
+// code comments where the old lines don't start (or end) with one, since then
+// it's an edit of the comment. It returns false if other craft lines are in
+// the new lines.
+func splitSuggestionMessage(hunk Hunk, style craft.CommentStyle) (message, code []string, ok bool) {
+	lines := hunk.NewLines
+	leadCode := !isCodeCommentLine(hunk.OldLines[0], style)
+	trailCode := !isCodeCommentLine(hunk.OldLines[len(hunk.OldLines)-1], style)
+
+	start := 0
+	for start < len(lines) && isMessageLine(lines[start], style, leadCode) {
+		start++
+	}
+	end := len(lines)
+	for end > start && isMessageLine(lines[end-1], style, trailCode) {
+		end--
+	}
+	for _, line := range lines[start:end] {
+		if isCraftCommentLine(line) || craft.IsShorthandLine(line, style) {
+			return nil, nil, false
+		}
+	}
+
+	lead, ok := commentText(lines[:start], style)
+	if !ok {
+		return nil, nil, false
+	}
+	trail, ok := commentText(lines[end:], style)
+	if !ok {
+		return nil, nil, false
+	}
+	if len(lead) > 0 && len(trail) > 0 {
+		lead = append(lead, "")
+	}
+	return append(lead, trail...), lines[start:end], true
+}
+
+// isMessageLine reports whether a line added with a code change can be part
+// of a comment folded into its suggestion, code comments only if code is set.
+func isMessageLine(line string, style craft.CommentStyle, code bool) bool {
+	if isCraftCommentLine(line) || craft.IsShorthandLine(line, style) {
+		return true
+	}
+	return code && isCodeCommentLine(line, style)
+}
+
+// commentText returns the text of lines of comments: new craft comments,
+// shorthand and code comments, with a blank line between comments. It
+// returns false if a craft line isn't part of a new comment with text.
+func commentText(lines []string, style craft.CommentStyle) ([]string, bool) {
+	var text []string
+	inNew, hasBody := false, true
+	next := func() {
+		if len(text) > 0 {
+			text = append(text, "")
+		}
+	}
+	for _, line := range lines {
+		if box, content, ok := craft.ParseCraftLine(line, style.LinePrefix); ok {
+			switch box {
+			case craft.BoxThread:
+				if h, ok := craft.ParseHeader(content); !ok || !h.IsNew || !hasBody {
+					return nil, false
+				}
+				next()
+				inNew, hasBody = true, false
+			case craft.BoxBody:
+				if !inNew {
+					return nil, false
+				}
+				text = append(text, content)
+				hasBody = hasBody || strings.TrimSpace(content) != ""
+			default:
+				return nil, false
+			}
+			continue
+		}
+		if !hasBody {
+			return nil, false
+		}
+		if inNew {
+			next()
+			inNew = false
+		}
+		if t, ok := craft.ShorthandText(line, style.LinePrefix); ok {
+			text = append(text, t)
+		} else if isCodeCommentLine(line, style) {
+			text = append(text, strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(line), style.LinePrefix)))
+		} else {
+			return nil, false
+		}
+	}
+	return text, hasBody
+}
+
 // isCraftCommentLine checks if a line contains craft box characters.
 func isCraftCommentLine(line string) bool {
-	return strings.Contains(line, boxThread) ||
-		strings.Contains(line, boxReply) ||
-		strings.Contains(line, boxBody) ||
-		strings.Contains(line, outdatedCommentsHeader)
+	return strings.Contains(line, craft.BoxThread) ||
+		strings.Contains(line, craft.BoxReply) ||
+		strings.Contains(line, craft.BoxBody) ||
+		strings.Contains(line, craft.BoxHunk) ||
+		strings.Contains(line, craft.BoxStart) ||
+		strings.Contains(line, craft.ASCIIStart+" "+craft.RangeStartText) ||
+		strings.Contains(line, craft.BoxChange) ||
+		isASCIIChangeMarker(line) ||
+		strings.Contains(line, craft.ASCIIThread+craft.HeaderStart) ||
+		strings.Contains(line, craft.ASCIIReply+craft.HeaderStart) ||
+		strings.Contains(line, craft.ASCIIThread+craft.ASCIIHeaderStart) ||
+		strings.Contains(line, craft.ASCIIReply+craft.ASCIIHeaderStart) ||
+		strings.Contains(line, craft.OutdatedCommentsHeader)
+}
+
+// isASCIIChangeMarker checks if a line has an ASCII change marker, which
+// unlike the other ASCII markers is only recognized with its text.
+func isASCIIChangeMarker(line string) bool {
+	_, rest, ok := strings.Cut(line, craft.ASCIIChange+" ")
+	return ok && craft.ChangeMarkerRe.MatchString(strings.TrimSpace(rest))
 }
 
 // isCodeCommentLine checks if a line is a code comment (starts with comment prefix).
-func isCodeCommentLine(line string, style commentStyle) bool {
+func isCodeCommentLine(line string, style craft.CommentStyle) bool {
 	trimmed := strings.TrimSpace(line)
-	return strings.HasPrefix(trimmed, style.linePrefix)
+	return strings.HasPrefix(trimmed, style.LinePrefix)
 }
 
 // buildSuggestionComment creates a suggestion comment block.
-func buildSuggestionComment(style commentStyle, indent string, hunk Hunk) []string {
+func buildSuggestionComment(style craft.CommentStyle, indent string, hunk Hunk) []string {
 	var lines []string
 
 	// Header - use OldCount from hunk header for accurate range
 	rangeField := ""
 	if hunk.OldCount > 1 {
-		// headerFieldSep is " ─ " so we don't need extra spaces
-		rangeField = fmt.Sprintf("%srange %d", headerFieldSep, -(hunk.OldCount - 1))
+		// craft.HeaderFieldSep is " ─ " so we don't need extra spaces
+		rangeField = fmt.Sprintf("%srange %d", craft.HeaderFieldSep, -(hunk.OldCount - 1))
 	}
-	header := indent + formatCraftLine(style.linePrefix, boxThread, headerStart+" new"+rangeField)
+	header := indent + craft.FormatCraftLine(style.LinePrefix, craft.BoxThread, craft.HeaderStart+" new"+rangeField)
 	lines = append(lines, header)
 
+	// Comments folded into it
+	for _, text := range hunk.Message {
+		lines = append(lines, indent+craft.FormatCraftLine(style.LinePrefix, craft.BoxBody, text))
+	}
+
 	// ```suggestion
-	lines = append(lines, indent+formatCraftLine(style.linePrefix, boxBody, "```suggestion"))
+	lines = append(lines, indent+craft.FormatCraftLine(style.LinePrefix, craft.BoxBody, "```suggestion"))
 
 	// New lines (the suggested replacement)
 	for _, newLine := range hunk.NewLines {
@@ -503,21 +785,21 @@ func buildSuggestionComment(style commentStyle, indent string, hunk Hunk) []stri
 		if isCraftCommentLine(newLine) {
 			continue
 		}
-		lines = append(lines, indent+formatCraftLine(style.linePrefix, boxBody, newLine))
+		lines = append(lines, indent+craft.FormatCraftLine(style.LinePrefix, craft.BoxBody, newLine))
 	}
 
 	// ```
-	lines = append(lines, indent+formatCraftLine(style.linePrefix, boxBody, "```"))
+	lines = append(lines, indent+craft.FormatCraftLine(style.LinePrefix, craft.BoxBody, "```"))
 
 	return lines
 }
 
 // buildCraftCommentFromCodeComments converts code comments to craft comments.
-func buildCraftCommentFromCodeComments(style commentStyle, indent string, hunk Hunk) []string {
+func buildCraftCommentFromCodeComments(style craft.CommentStyle, indent string, hunk Hunk) []string {
 	var lines []string
 
 	// Header
-	header := indent + formatCraftLine(style.linePrefix, boxThread, headerStart+" new")
+	header := indent + craft.FormatCraftLine(style.LinePrefix, craft.BoxThread, craft.HeaderStart+" new")
 	lines = append(lines, header)
 
 	// Extract comment text from code comment lines
@@ -527,9 +809,9 @@ func buildCraftCommentFromCodeComments(style commentStyle, indent string, hunk H
 		}
 		// Strip the code comment prefix to get just the text
 		trimmed := strings.TrimSpace(newLine)
-		text := strings.TrimPrefix(trimmed, style.linePrefix)
+		text := strings.TrimPrefix(trimmed, style.LinePrefix)
 		text = strings.TrimSpace(text)
-		lines = append(lines, indent+formatCraftLine(style.linePrefix, boxBody, text))
+		lines = append(lines, indent+craft.FormatCraftLine(style.LinePrefix, craft.BoxBody, text))
 	}
 
 	return lines
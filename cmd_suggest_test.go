@@ -4,7 +4,9 @@ import (
 	"os"
 	"os/exec"
 	"testing"
+	"testing/fstest"
 
+	"github.com/dnr/craft/pkg/craft"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -123,12 +125,12 @@ index abc123..def456 100644
 }
 
 func TestClassifyHunk(t *testing.T) {
-	goStyle := commentStyle{linePrefix: "//"}
+	goStyle := craft.CommentStyle{LinePrefix: "//"}
 
 	tests := []struct {
 		name     string
 		hunk     Hunk
-		style    commentStyle
+		style    craft.CommentStyle
 		expected HunkClassification
 	}{
 		{
@@ -194,6 +196,15 @@ func TestClassifyHunk(t *testing.T) {
 			style:    goStyle,
 			expected: HunkCraftComment,
 		},
+		{
+			name: "code change with added code comment -> suggestion",
+			hunk: Hunk{
+				OldLines: []string{"    old code"},
+				NewLines: []string{"    // why", "    new code"},
+			},
+			style:    goStyle,
+			expected: HunkSuggestion,
+		},
 		{
 			name: "code change with craft comment -> warn mixed",
 			hunk: Hunk{
@@ -233,12 +244,12 @@ func TestIsCraftCommentLine(t *testing.T) {
 }
 
 func TestIsCodeCommentLine(t *testing.T) {
-	goStyle := commentStyle{linePrefix: "//"}
-	pyStyle := commentStyle{linePrefix: "#"}
+	goStyle := craft.CommentStyle{LinePrefix: "//"}
+	pyStyle := craft.CommentStyle{LinePrefix: "#"}
 
 	tests := []struct {
 		line     string
-		style    commentStyle
+		style    craft.CommentStyle
 		expected bool
 	}{
 		{"// comment", goStyle, true},
@@ -250,7 +261,7 @@ func TestIsCodeCommentLine(t *testing.T) {
 	}
 
 	for _, tt := range tests {
-		assert.Equal(t, tt.expected, isCodeCommentLine(tt.line, tt.style), "line: %q, prefix: %q", tt.line, tt.style.linePrefix)
+		assert.Equal(t, tt.expected, isCodeCommentLine(tt.line, tt.style), "line: %q, prefix: %q", tt.line, tt.style.LinePrefix)
 	}
 }
 
@@ -344,12 +355,12 @@ func TestTransformMultiLineChange(t *testing.T) {
 `
 
 	// range -2 means 3 lines are being replaced (range = -(OldCount-1))
-	// headerFieldSep is " ─ " so header looks like "new ─ range -2"
+	// craft.HeaderFieldSep is " ─ " so header looks like "new ─ range -2"
 	expected := "func foo() {\n" +
 		"\tline1 := 1\n" +
 		"\tline2 := 2\n" +
 		"\tline3 := 3\n" +
-		"\t// ╓───── new" + headerFieldSep + "range -2\n" +
+		"\t// ╓───── new" + craft.HeaderFieldSep + "range -2\n" +
 		"\t// ║ ```suggestion\n" +
 		"\t// ║ \tnewLine1 := \"a\"\n" +
 		"\t// ║ \tnewLine2 := \"b\"\n" +
@@ -382,7 +393,7 @@ func TestTransformDeletion(t *testing.T) {
 	keep := 1
 	delete1 := 2
 	delete2 := 3
-	// ╓───── new` + headerFieldSep + `range -1
+	// ╓───── new` + craft.HeaderFieldSep + `range -1
 	// ║ ` + "```" + `suggestion
 	// ║ ` + "```" + `
 	alsoKeep := 4
@@ -453,7 +464,7 @@ func TestTransformCodeCommentAlone(t *testing.T) {
 	assert.Equal(t, expected, result.Content)
 }
 
-func TestTransformWarnsMixedCraftAndCodeChanges(t *testing.T) {
+func TestTransformFoldsAdjacentCommentsIntoSuggestion(t *testing.T) {
 	before := `func foo() {
 	x := 1
 	y := 2
@@ -461,11 +472,49 @@ func TestTransformWarnsMixedCraftAndCodeChanges(t *testing.T) {
 `
 
 	// User added a craft comment adjacent to a code edit - these get combined
-	// into one hunk by diff, and we warn about it
+	// into one hunk by diff, and the comment becomes the suggestion's message
 	after := `func foo() {
 	x := 1
 	// ╓───── new
-	// ║ existing craft comment
+	// ║ rename it
+	newY := 22
+	// and change the value
+}
+`
+
+	expected := "func foo() {\n" +
+		"\tx := 1\n" +
+		"\ty := 2\n" +
+		"\t// ╓───── new\n" +
+		"\t// ║ rename it\n" +
+		"\t// ║\n" +
+		"\t// ║ and change the value\n" +
+		"\t// ║ ```suggestion\n" +
+		"\t// ║ \tnewY := 22\n" +
+		"\t// ║ ```\n" +
+		"}\n"
+
+	diff := generateDiff(t, before, after)
+	result := transformFileWithSuggestions(before, diff, "test.go")
+
+	assert.Equal(t, 1, result.Stats.suggestions)
+	assert.Equal(t, 0, result.Stats.warnings)
+	assert.Equal(t, expected, result.Content)
+}
+
+func TestTransformWarnsMixedCraftAndCodeChanges(t *testing.T) {
+	before := `func foo() {
+	x := 1
+	y := 2
+}
+`
+
+	// A craft comment in the middle of a code edit can't be folded into the
+	// suggestion, so we warn about it
+	after := `func foo() {
+	newX := 11
+	// ╓───── new
+	// ║ craft comment
 	newY := 22
 }
 `
@@ -476,7 +525,7 @@ func TestTransformWarnsMixedCraftAndCodeChanges(t *testing.T) {
 	// The mixed hunk should be skipped with a warning
 	assert.Equal(t, 0, result.Stats.suggestions)
 	assert.Equal(t, 1, result.Stats.warnings)
-	assert.Len(t, result.Warnings, 1)
+	require.Len(t, result.Warnings, 1)
 	assert.Contains(t, result.Warnings[0], "craft comments mixed with code changes")
 }
 
@@ -512,3 +561,35 @@ func TestTransformPreservesPureCraftComments(t *testing.T) {
 	assert.Equal(t, 0, result.Stats.warnings)
 	assert.Equal(t, expected, result.Content)
 }
+
+func TestNearestFile(t *testing.T) {
+	candidates := []string{"README.md", "pkg/a/bar.go", "pkg/a/foo.go", "pkg/b/foo.go"}
+	assert.Equal(t, "pkg/a/foo.go", nearestFile("pkg/a/foo_test.go", candidates))
+	assert.Equal(t, "pkg/b/foo.go", nearestFile("pkg/b/new.go", candidates))
+	assert.Equal(t, "README.md", nearestFile("LICENSE", candidates))
+	assert.Equal(t, "", nearestFile("x.go", nil))
+}
+
+func TestSuggestNewFileComment(t *testing.T) {
+	code := "package foo\n\nfunc Foo() {}\n"
+	added := "package foo\n\nfunc TestFoo(t *testing.T) {}\n"
+	comment := newFileComment("foo_test.go", added, craft.GetCommentStyle("foo.go"))
+	content := insertFileComment(code, "foo.go", comment)
+
+	assert.Equal(t, "// ╓───── new ─ file\n"+
+		"// ║ Suggest adding `foo_test.go`:\n"+
+		"// ║\n"+
+		"// ║ ```\n"+
+		"// ║ package foo\n"+
+		"// ║\n"+
+		"// ║ func TestFoo(t *testing.T) {}\n"+
+		"// ║ ```\n"+
+		code, content)
+
+	memfs := fstest.MapFS{"foo.go": &fstest.MapFile{Data: []byte(content)}}
+	threads, err := craft.DeserializeFileComments(craft.SerializeOptions{FS: memfs}, "foo.go")
+	require.NoError(t, err)
+	require.Len(t, threads, 1)
+	assert.Equal(t, SubjectTypeFile, threads[0].SubjectType)
+	assert.Contains(t, threads[0].Comments[0].Body, "func TestFoo(t *testing.T) {}")
+}
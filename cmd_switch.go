@@ -0,0 +1,239 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/dnr/craft/pkg/craft"
+	"github.com/spf13/cobra"
+)
+
+var switchCmd = &cobra.Command{
+	Use:   "switch <PR>",
+	Short: "Switch to reviewing another PR, without fetching it again",
+	Long: `Switches to the pr-N branch of a PR fetched before with 'craft get', and
+writes its review into the files from the copy craft keeps of each PR it
+fetches, without going to GitHub. That makes going back and forth between
+PRs quick.
+
+The review being left is kept first, including comments not sent yet, so
+switching back to it restores them. Uncommitted changes other than to craft
+comments stop the switch. 'craft get' refreshes a PR from GitHub.
+
+Examples:
+  craft switch 123   # Back to reviewing PR #123`,
+	RunE:              runSwitch,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completePRNumbers,
+}
+
+var (
+	flagSwitchNoCommit bool
+	flagSwitchNoSign   bool
+)
+
+func init() {
+	switchCmd.Flags().BoolVar(&flagSwitchNoCommit, "no-commit", false, "Leave the craft comments uncommitted (default: from the autoCommit setting)")
+	switchCmd.Flags().BoolVar(&flagSwitchNoSign, "no-sign", false, "Don't sign the commit, even if git or jj is configured to")
+	rootCmd.AddCommand(switchCmd)
+}
+
+func runSwitch(cmd *cobra.Command, args []string) error {
+	vcs, err := DetectVCS(".")
+	if err != nil {
+		return err
+	}
+	remoteURL, err := vcs.GetRemoteURL(resolveRemote(vcs, ""))
+	if err != nil {
+		return fmt.Errorf("getting remote URL: %w", err)
+	}
+	owner, repo, err := ParseGitHubRemote(remoteURL)
+	if err != nil {
+		return err
+	}
+	prNumber, err := parsePRArg(args[0], owner, repo)
+	if err != nil {
+		return err
+	}
+	commit, err := resolveAutoCommit(vcs, flagSwitchNoCommit)
+	if err != nil {
+		return err
+	}
+	commitOpts, err := resolveCommitOptions(vcs, flagSwitchNoSign)
+	if err != nil {
+		return err
+	}
+
+	pr, err := loadPRCache(vcs, prNumber)
+	if errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("PR #%d hasn't been fetched here; use 'craft get %d'", prNumber, prNumber)
+	} else if err != nil {
+		return err
+	}
+
+	hasChanges, err := hasNonCraftChanges(vcs)
+	if err != nil {
+		return fmt.Errorf("checking for uncommitted changes: %w", err)
+	}
+	if hasChanges {
+		return fmt.Errorf("uncommitted changes detected; commit or stash them first")
+	}
+
+	// Keep the review being left, with what's been written in the files
+	opts := craft.SerializeOptions{FS: craft.DirFS(vcs.Root()), VCS: vcs}
+	if current, err := readPRStateHeader(opts); err == nil && current.Number != 0 && !current.InPlace {
+		if current.Number == prNumber {
+			logInfo("Already reviewing PR #%d", prNumber)
+			return nil
+		}
+		logStart("Keeping the review of PR #%d", current.Number)
+		if err := saveSession(vcs, current.Number); err != nil {
+			return fmt.Errorf("keeping the review of PR #%d: %w", current.Number, err)
+		}
+		logEnd("done")
+	}
+	files, err := vcs.GetUncommittedFiles()
+	if err != nil {
+		return fmt.Errorf("checking for uncommitted changes: %w", err)
+	}
+	if err := vcs.RevertFiles(files); err != nil {
+		return fmt.Errorf("discarding craft comments: %w", err)
+	}
+
+	logStart("Switching to local branch")
+	if err := vcs.CreateAndSwitchBranch(prNumber, pr.CheckoutOID()); err != nil {
+		return fmt.Errorf("creating branch: %w\nif the PR's commits aren't here, use 'craft get %d'", err, prNumber)
+	}
+	logEnd("done")
+
+	logStart("Serializing PR state")
+	opts, err = prSerializeOptions(vcs, pr)
+	if err != nil {
+		return err
+	}
+	if err := craft.Serialize(pr, opts); err != nil {
+		return fmt.Errorf("serializing: %w", err)
+	}
+	logEnd("done")
+
+	if commit {
+		logStart("Committing")
+		commitMsg := craftCommitMessage(fmt.Sprintf("craft: PR #%d state", prNumber), pr.Title, prNumber)
+		if err := vcs.Commit(commitMsg, commitOpts); err != nil {
+			return fmt.Errorf("committing: %w", err)
+		}
+		logEnd("done")
+	}
+
+	logInfo("Reviewing PR #%d on branch pr-%d: %s", prNumber, prNumber, pr.Title)
+	logInfo("  as fetched at %s; 'craft get' refreshes it", pr.LastFetchedAt.Local().Format(craft.TimelineTimeFormat))
+	return nil
+}
+
+// saveSession updates the copy of PR number kept by savePRCache with the
+// threads in the files, including new comments and edits, so switching back
+// to it restores them.
+func saveSession(vcs VCS, number int) error {
+	cached, err := loadPRCache(vcs, number)
+	if errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("it wasn't fetched with 'craft get'; send or clear it first")
+	} else if err != nil {
+		return err
+	}
+	inFiles, err := craft.Deserialize(craft.SerializeOptions{FS: craft.DirFS(vcs.Root()), VCS: vcs})
+	if err != nil && !errors.As(err, new(craft.ParseErrors)) {
+		return err
+	} else if err != nil {
+		return fmt.Errorf("%w\nfix them with 'craft verify' first", err)
+	}
+	mergeSessionThreads(cached, inFiles)
+	return savePRCache(vcs, cached)
+}
+
+// mergeSessionThreads replaces the threads of cached with those of inFiles,
+// keeping the cached ones that weren't written into the files, like
+// resolved threads that were left out.
+func mergeSessionThreads(cached, inFiles *PullRequest) {
+	seen := make(map[string]bool)
+	for _, thread := range inFiles.ReviewThreads {
+		if thread.ID != "" {
+			seen[thread.ID] = true
+		}
+	}
+	threads := inFiles.ReviewThreads
+	for _, thread := range cached.ReviewThreads {
+		if !seen[thread.ID] {
+			threads = append(threads, thread)
+		}
+	}
+	cached.ReviewThreads = threads
+}
+
+// prCachePath returns where the copy of PR number of the repo of vcs is kept.
+func prCachePath(vcs VCS, number int) (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	remoteURL, err := vcs.GetRemoteURL(resolveRemote(vcs, ""))
+	if err != nil {
+		return "", fmt.Errorf("getting remote URL: %w", err)
+	}
+	owner, repo, err := ParseGitHubRemote(remoteURL)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "craft", "prs", owner, repo, strconv.Itoa(number)+".json"), nil
+}
+
+// savePRCache keeps a copy of a fetched PR, for craft switch.
+func savePRCache(vcs VCS, pr *PullRequest) error {
+	path, err := prCachePath(vcs, pr.Number)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(pr)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// loadPRCache reads the copy of PR number kept by savePRCache.
+func loadPRCache(vcs VCS, number int) (*PullRequest, error) {
+	path, err := prCachePath(vcs, number)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	pr, err := craft.ParsePullRequestJSON(data)
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", path, err)
+	}
+	return pr, nil
+}
+
+// cachedOriginals returns the comment bodies of the PR in opts' files, as
+// kept by savePRCache, for SerializeOptions.Originals. It's nil if there's
+// no PR or no copy of it.
+func cachedOriginals(opts craft.SerializeOptions) map[string]string {
+	state, err := readPRStateHeader(opts)
+	if err != nil || state.Number == 0 {
+		return nil
+	}
+	cached, err := loadPRCache(opts.VCS, state.Number)
+	if err != nil {
+		return nil
+	}
+	return cached.CommentBodies()
+}
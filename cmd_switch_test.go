@@ -0,0 +1,44 @@
+package main
+
+import (
+	"io/fs"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPRCache(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+	repo := newTestGitRepo(t, map[string]string{"a.txt": "a\n"})
+	_, err := repo.run("remote", "add", "origin", "https://github.com/owner/repo.git")
+	require.NoError(t, err)
+
+	_, err = loadPRCache(repo, 7)
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+
+	pr := &PullRequest{Number: 7, Title: "Fix it", HeadRefOID: "abc", ReviewThreads: []ReviewThread{{ID: "T1", Path: "a.txt", Line: 1}}}
+	require.NoError(t, savePRCache(repo, pr))
+	got, err := loadPRCache(repo, 7)
+	require.NoError(t, err)
+	assert.Equal(t, "Fix it", got.Title)
+	assert.Equal(t, pr.ReviewThreads, got.ReviewThreads)
+}
+
+func TestMergeSessionThreads(t *testing.T) {
+	cached := &PullRequest{ReviewThreads: []ReviewThread{
+		{ID: "T1", Path: "a.go", Line: 1},
+		{ID: "T2", Path: "a.go", Line: 5, IsResolved: true}, // left out of the files
+	}}
+	inFiles := &PullRequest{ReviewThreads: []ReviewThread{
+		{ID: "T1", Path: "a.go", Line: 1, IsResolved: true, Comments: []ReviewComment{{Body: "Old"}, {Body: "Reply", IsNew: true}}},
+		{Path: "a.go", Line: 9, Comments: []ReviewComment{{Body: "New thread", IsNew: true}}},
+	}}
+	mergeSessionThreads(cached, inFiles)
+	require.Len(t, cached.ReviewThreads, 3)
+	assert.True(t, cached.ReviewThreads[0].IsResolved)
+	assert.Len(t, cached.ReviewThreads[0].Comments, 2)
+	assert.Equal(t, "New thread", cached.ReviewThreads[1].Comments[0].Body)
+	assert.Equal(t, "T2", cached.ReviewThreads[2].ID)
+}
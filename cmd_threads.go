@@ -0,0 +1,107 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/dnr/craft/pkg/craft"
+	"github.com/spf13/cobra"
+)
+
+var threadsCmd = &cobra.Command{
+	Use:   "threads",
+	Short: "List review threads for an editor's quickfix list",
+	Long: `Lists the review threads in the tree, one per line, as
+
+  path:line: author: first line of the comment
+
+where line is the line of the file (craft comments included) that the thread
+is on, and path is relative to the current directory. Editors that read
+compiler output can jump between threads with it.
+
+Examples:
+  craft threads
+  :cexpr system('craft threads')      (in vim)
+  M-x compile RET craft threads       (in emacs)`,
+	RunE: runThreads,
+	Args: cobra.NoArgs,
+}
+
+var flagThreadsFormat string
+
+func init() {
+	threadsCmd.Flags().StringVar(&flagThreadsFormat, "format", "quickfix", "Output format: quickfix")
+	rootCmd.AddCommand(threadsCmd)
+}
+
+func runThreads(cmd *cobra.Command, args []string) error {
+	if flagThreadsFormat != "quickfix" {
+		return fmt.Errorf("unknown --format %q (want quickfix)", flagThreadsFormat)
+	}
+	vcs, err := DetectVCS(".")
+	if err != nil {
+		return err
+	}
+	cwd, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+
+	opts := craft.SerializeOptions{FS: craft.DirFS(vcs.Root()), VCS: vcs}
+	pr, err := craft.Deserialize(opts)
+	if errors.As(err, new(craft.ParseErrors)) {
+		logWarn("%v", err)
+	} else if err != nil {
+		return fmt.Errorf("reading PR state: %w", err)
+	}
+
+	return writeQuickfix(os.Stdout, opts.FS, threadPaths(pr), func(path string) string {
+		if rel, err := filepath.Rel(cwd, filepath.Join(vcs.Root(), path)); err == nil {
+			return rel
+		}
+		return path
+	})
+}
+
+// threadPaths returns the sorted paths of the files pr has threads in.
+func threadPaths(pr *PullRequest) []string {
+	var paths []string
+	for _, thread := range pr.ReviewThreads {
+		paths = append(paths, thread.Path)
+	}
+	slices.Sort(paths)
+	return slices.Compact(paths)
+}
+
+// writeQuickfix writes a line for each thread in the given files, in the
+// format compilers use for errors. displayPath maps repo paths to the paths
+// to print.
+func writeQuickfix(w io.Writer, fsys fs.FS, paths []string, displayPath func(string) string) error {
+	for _, path := range paths {
+		content, err := craft.ReadFile(fsys, path)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue // threads on a deleted file are in PR-OUTDATED.txt or gone
+		} else if err != nil {
+			return fmt.Errorf("reading %s: %w", path, err)
+		}
+		_, text := craft.DecodeFile(string(content))
+		for _, t := range scanThreads(path, strings.Split(text, "\n")) {
+			first := t.comments[0]
+			author := commentAuthor(first.author, first.isNew)
+			summary, _, _ := strings.Cut(first.body, "\n")
+			if t.header.IsResolved {
+				summary = "(resolved) " + summary
+			}
+			if _, err := fmt.Fprintf(w, "%s:%d: %s: %s\n", displayPath(path), t.end+1, author, summary); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteQuickfix(t *testing.T) {
+	memfs := fstest.MapFS{
+		"main.go":  &fstest.MapFile{Data: []byte(lspTestDoc)},
+		"setup.py": &fstest.MapFile{Data: []byte("# ╓───── new ─ file\n# ║ Missing docs\nimport os\n")},
+	}
+	var buf strings.Builder
+	err := writeQuickfix(&buf, memfs, []string{"gone.go", "main.go", "setup.py"}, func(path string) string {
+		return "sub/" + path
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "sub/main.go:4: @bob: Use y:\n"+
+		"sub/main.go:13: @carol: (resolved) Done\n"+
+		"sub/setup.py:1: new: Missing docs\n", buf.String())
+}
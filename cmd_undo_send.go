@@ -0,0 +1,194 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/dnr/craft/pkg/craft"
+	"github.com/shurcooL/githubv4"
+	"github.com/spf13/cobra"
+)
+
+var undoSendCmd = &cobra.Command{
+	Use:   "undo-send",
+	Short: "Take back the review just sent",
+	Long: `Deletes the comments the last 'craft send' of the PR made, for when it went
+too early. A pending review it started is deleted outright; comments already
+in a pending review it added to stay. A send through GitHub's REST API (for
+tokens refused GraphQL) can't be undone.
+
+GitHub keeps a submitted review itself: its comments are deleted and, if the
+send started it, its body emptied, but an approval or request for changes
+stays until you review again. Replies go with the review; resolved threads
+and edited PR-level comments aren't undone. If it stops partway, running it
+again carries on with what's left.
+
+Only a send in the last 10 minutes is undone, unless --force is given. Run
+'craft get' afterwards to bring the files up to date.`,
+	RunE: runUndoSend,
+	Args: cobra.NoArgs,
+}
+
+var flagUndoSendForce bool
+
+// undoSendWindow is how long after a send undo-send undoes it without
+// --force.
+const undoSendWindow = 10 * time.Minute
+
+func init() {
+	undoSendCmd.Flags().BoolVar(&flagUndoSendForce, "force", false, "Undo the last send even if it was more than 10 minutes ago")
+	rootCmd.AddCommand(undoSendCmd)
+}
+
+// lastSend is what 'craft send' records of the review it made, so undo-send
+// can take it back.
+type lastSend struct {
+	PRNumber      int       `json:"prNumber"`
+	ReviewID      string    `json:"reviewId"`
+	CreatedReview bool      `json:"createdReview"`        // not a pending review it added to
+	CommentIDs    []string  `json:"commentIds,omitempty"` // the comments it made, threads and replies
+	REST          bool      `json:"rest,omitempty"`       // sent through REST, which doesn't say what it made
+	Event         string    `json:"event"`                // COMMENT, APPROVE, REQUEST_CHANGES or PENDING
+	HasBody       bool      `json:"hasBody"`              // sent with a PR-level comment
+	SentAt        time.Time `json:"sentAt"`
+}
+
+// lastSendPath returns where the last send of PR number is recorded, beside
+// its copy kept for craft switch.
+func lastSendPath(vcs VCS, number int) (string, error) {
+	path, err := prCachePath(vcs, number)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(path, ".json") + ".sent.json", nil
+}
+
+// saveLastSend records a send of a review.
+func saveLastSend(vcs VCS, sent lastSend) error {
+	path, err := lastSendPath(vcs, sent.PRNumber)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(sent)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// loadLastSend reads the record of the last send of PR number.
+func loadLastSend(vcs VCS, number int) (*lastSend, error) {
+	path, err := lastSendPath(vcs, number)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("no send of PR #%d to undo", number)
+	} else if err != nil {
+		return nil, err
+	}
+	var sent lastSend
+	if err := json.Unmarshal(data, &sent); err != nil {
+		return nil, fmt.Errorf("reading %s: %w", path, err)
+	}
+	return &sent, nil
+}
+
+func runUndoSend(cmd *cobra.Command, args []string) error {
+	vcs, err := DetectVCS(".")
+	if err != nil {
+		return err
+	}
+	opts := craft.SerializeOptions{FS: craft.DirFS(vcs.Root()), VCS: vcs}
+
+	var prNumber int
+	if state, err := readPRStateHeader(opts); err == nil && state.Number != 0 {
+		prNumber = state.Number
+	} else if prNumber, err = prNumberFromBranch(vcs); err != nil {
+		return err
+	}
+	sent, err := loadLastSend(vcs, prNumber)
+	if err != nil {
+		return err
+	}
+	if ago := time.Since(sent.SentAt); ago > undoSendWindow && !flagUndoSendForce {
+		return fmt.Errorf("the last send of PR #%d was %s ago; use --force to undo it anyway", prNumber, ago.Round(time.Minute))
+	}
+
+	client, _, _, err := getGitHubClientAndRepo(vcs, resolveRemote(vcs, ""))
+	if err != nil {
+		return err
+	}
+	if err := undoSend(cmd.Context(), client, sent); err != nil {
+		// What was undone is left out of the record, so running it again
+		// picks up where it stopped
+		if saveErr := saveLastSend(vcs, *sent); saveErr != nil {
+			logWarn("updating the record of the send: %v", saveErr)
+		}
+		return err
+	}
+
+	if path, err := lastSendPath(vcs, prNumber); err == nil {
+		os.Remove(path)
+	}
+	logInfo("Run 'craft get' to update the files.")
+	return nil
+}
+
+// undoSend takes back the review of sent: a pending one it created is
+// deleted, and otherwise the comments it made are deleted and the body of a
+// review it created emptied. Comments that were in a pending review before
+// it was sent stay. What's undone is taken out of sent as it goes.
+func undoSend(ctx context.Context, client GitHubAPI, sent *lastSend) error {
+	if sent.REST {
+		return fmt.Errorf("the last send of PR #%d went through GitHub's REST API, which doesn't say what it made; delete its comments on GitHub", sent.PRNumber)
+	}
+	if sent.Event == "PENDING" && sent.CreatedReview {
+		logStart("Deleting pending review")
+		if err := client.DeletePendingReview(ctx, githubv4.ID(sent.ReviewID)); err != nil {
+			return fmt.Errorf("deleting pending review: %w", err)
+		}
+		logEnd("done")
+		return nil
+	}
+
+	logStart("Deleting %d comment(s)", len(sent.CommentIDs))
+	for len(sent.CommentIDs) > 0 {
+		if err := client.DeleteReviewComment(ctx, sent.CommentIDs[0]); err != nil {
+			logEnd("failed!")
+			return fmt.Errorf("deleting comment: %w", err)
+		}
+		sent.CommentIDs = sent.CommentIDs[1:]
+	}
+	logEnd("done")
+	switch {
+	case sent.HasBody && sent.Event != "PENDING" && sent.CreatedReview:
+		logStart("Emptying review body")
+		if err := client.UpdateReviewBody(ctx, githubv4.ID(sent.ReviewID), ""); err != nil {
+			return fmt.Errorf("emptying review body: %w", err)
+		}
+		sent.HasBody = false
+		logEnd("done")
+	case sent.HasBody && sent.Event != "PENDING":
+		// The send replaced the body of a review that was pending before it
+		logWarn("the review's body stays, since what it was before the send isn't known; edit it on GitHub")
+	}
+	switch sent.Event {
+	case "APPROVE":
+		logWarn("the approval stays on the PR; review again to change it")
+	case "REQUEST_CHANGES":
+		logWarn("the request for changes stays on the PR; review again to change it")
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/dnr/craft/pkg/craft"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func (f *fakeGitHub) FetchReviewCommentIDs(ctx context.Context, reviewID string) ([]string, error) {
+	return []string{"PRRC_1", "PRRC_2"}, nil
+}
+
+func (f *fakeGitHub) DeleteReviewComment(ctx context.Context, commentID string) error {
+	if commentID == f.failDelete {
+		return errors.New("boom")
+	}
+	f.calls = append(f.calls, "delete "+commentID)
+	return nil
+}
+
+func TestUndoSend(t *testing.T) {
+	review := &craft.ReviewToSend{
+		NewThreads:  []NewThreadInfo{{Path: "a.go", Line: 5, Side: DiffSideRight, Body: "line"}},
+		Body:        "Overall",
+		ReviewEvent: "APPROVE",
+	}
+	client := &fakeGitHub{}
+	require.NoError(t, review.Send(t.Context(), client, "PR_x", "abc123", false))
+	assert.Equal(t, "PRR_new", review.SentReviewID)
+	assert.True(t, review.SentReviewCreated)
+	assert.Equal(t, []string{"PRRC_1", "PRRC_2"}, review.SentCommentIDs)
+
+	client = &fakeGitHub{}
+	sent := &lastSend{PRNumber: 1, ReviewID: review.SentReviewID, CreatedReview: true, CommentIDs: review.SentCommentIDs, Event: review.ReviewEvent, HasBody: true}
+	require.NoError(t, undoSend(t.Context(), client, sent))
+	assert.Equal(t, []string{"delete PRRC_1", "delete PRRC_2", `body PRR_new ""`}, client.calls)
+
+	// A pending review craft started goes altogether
+	client = &fakeGitHub{}
+	sent = &lastSend{PRNumber: 1, ReviewID: "PRR_new", CreatedReview: true, Event: "PENDING"}
+	require.NoError(t, undoSend(t.Context(), client, sent))
+	assert.Equal(t, []string{"delete PRR_new"}, client.calls)
+
+	// In a pending review started on GitHub, only the reply sent goes
+	review = &craft.ReviewToSend{
+		Replies:     []ReplyInfo{{ThreadPath: "a.go", ThreadLine: 5, Body: "Done", ReplyToNodeID: "PRRC_theirs"}},
+		ReviewEvent: "PENDING",
+	}
+	client = &fakeGitHub{pending: true}
+	require.NoError(t, review.Send(t.Context(), client, "PR_x", "abc123", false))
+	assert.False(t, review.SentReviewCreated)
+	client = &fakeGitHub{}
+	sent = &lastSend{PRNumber: 1, ReviewID: review.SentReviewID, CommentIDs: review.SentCommentIDs, Event: "PENDING"}
+	require.NoError(t, undoSend(t.Context(), client, sent))
+	assert.Equal(t, []string{"delete PRRC_reply"}, client.calls)
+
+	// Submitting a review that was pending before leaves its body, which
+	// the send replaced, as it is
+	client = &fakeGitHub{}
+	sent = &lastSend{PRNumber: 1, ReviewID: "PRR_old", CommentIDs: []string{"PRRC_reply"}, Event: "COMMENT", HasBody: true}
+	require.NoError(t, undoSend(t.Context(), client, sent))
+	assert.Equal(t, []string{"delete PRRC_reply"}, client.calls)
+
+	// A failure leaves what's still to undo in the record, for a retry
+	client = &fakeGitHub{failDelete: "PRRC_2"}
+	sent = &lastSend{PRNumber: 1, ReviewID: "PRR_new", CreatedReview: true, CommentIDs: []string{"PRRC_1", "PRRC_2"}, Event: "COMMENT", HasBody: true}
+	assert.ErrorContains(t, undoSend(t.Context(), client, sent), "boom")
+	assert.Equal(t, []string{"PRRC_2"}, sent.CommentIDs)
+	assert.True(t, sent.HasBody)
+	client = &fakeGitHub{}
+	require.NoError(t, undoSend(t.Context(), client, sent))
+	assert.Equal(t, []string{"delete PRRC_2", `body PRR_new ""`}, client.calls)
+
+	// A send through REST can't be undone
+	client = &fakeGitHub{}
+	sent = &lastSend{PRNumber: 1, REST: true, Event: "COMMENT"}
+	assert.ErrorContains(t, undoSend(t.Context(), client, sent), "REST")
+	assert.Empty(t, client.calls)
+}
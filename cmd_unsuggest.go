@@ -0,0 +1,112 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/dnr/craft/pkg/craft"
+	"github.com/spf13/cobra"
+)
+
+var unsuggestCmd = &cobra.Command{
+	Use:   "unsuggest file:line",
+	Short: "Apply a suggestion to the code, to try it",
+	Long: `Applies the latest suggestion in a thread to the code it's on, as an edit
+in the working copy, so the change can be built and tested: the inverse of
+'craft suggest'. The line is one of the thread's lines or of the code it's
+on, numbered as in the file, craft comments included, from 1.
+
+The thread stays as it is. The PR's author can keep the edit, committing it
+with their other changes; a reviewer can revert it (e.g. with 'git checkout'
+of the file) and send the suggestion.
+
+Examples:
+  craft unsuggest main.go:42   Apply the suggestion on line 42 of main.go`,
+	RunE: runUnsuggest,
+	Args: cobra.ExactArgs(1),
+}
+
+func init() {
+	rootCmd.AddCommand(unsuggestCmd)
+}
+
+func runUnsuggest(cmd *cobra.Command, args []string) error {
+	path, line, err := parseFileLine(args[0])
+	if err != nil {
+		return err
+	}
+	if craft.IsReviewFile(filepath.Base(path)) {
+		return fmt.Errorf("%s isn't a source file; it has no suggestions", path)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	applied, author, err := unsuggestContent(string(content), path, line)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, []byte(applied), info.Mode().Perm()); err != nil {
+		return err
+	}
+	logInfo("Applied the suggestion from %s to %s", author, path)
+	return nil
+}
+
+// unsuggestContent applies the latest suggestion of the thread at line (from
+// 1) of a file's content, on one of its lines or of the code it's on, to that
+// code. It returns the new content and who made the suggestion.
+func unsuggestContent(content, path string, line int) (string, string, error) {
+	enc, content := craft.DecodeFile(content)
+	lines := strings.Split(content, "\n")
+	if line > len(lines) {
+		return "", "", fmt.Errorf("%s has %d lines, no line %d", path, len(lines), line)
+	}
+	i := line - 1
+	prefix := craft.GetCommentStyle(path).LinePrefix
+
+	// A thread the line is in, or else one on the code at the line
+	threads := scanThreads(path, lines)
+	var thread *lspThread
+	for j, t := range threads {
+		if i >= t.first && i <= t.last {
+			thread = &threads[j]
+			break
+		}
+	}
+	if thread == nil {
+		for j, t := range threads {
+			if _, _, ok := threadSuggestion(t, lines, prefix); ok && i >= t.start && i <= t.end {
+				thread = &threads[j]
+				break
+			}
+		}
+	}
+	if thread == nil {
+		return "", "", fmt.Errorf("%s:%d: no thread there", path, line)
+	}
+	suggestion, k, ok := threadSuggestion(*thread, lines, prefix)
+	if !ok {
+		return "", "", fmt.Errorf("%s:%d: no suggestion in the thread to apply to its code", path, line)
+	}
+
+	var replacement []string
+	if suggestion != "" {
+		replacement = strings.Split(suggestion, "\n")
+	}
+	result := append([]string{}, lines[:thread.start]...)
+	result = append(result, replacement...)
+	result = append(result, lines[thread.end+1:]...)
+
+	author := "a new comment"
+	if c := thread.comments[k]; !c.isNew {
+		author = "@" + c.author
+	}
+	return enc.Encode(strings.Join(result, "\n")), author, nil
+}
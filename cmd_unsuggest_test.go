@@ -0,0 +1,47 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUnsuggestContent(t *testing.T) {
+	content := "func f() {\n" +
+		"\tx := 1\n" +
+		"\ty := 2\n" +
+		"\t// ╓───── @alice ─ at 2025-01-15 12:34 ─ range -1\n" +
+		"\t// ║ Simpler:\n" +
+		"\t// ║ ```suggestion\n" +
+		"\t// ║ \tx, y := 1, 2\n" +
+		"\t// ║ ```\n" +
+		"}\n"
+	want := "func f() {\n" +
+		"\tx, y := 1, 2\n" +
+		"\t// ╓───── @alice ─ at 2025-01-15 12:34 ─ range -1\n" +
+		"\t// ║ Simpler:\n" +
+		"\t// ║ ```suggestion\n" +
+		"\t// ║ \tx, y := 1, 2\n" +
+		"\t// ║ ```\n" +
+		"}\n"
+
+	// From a line of the thread or of its code
+	for _, line := range []int{2, 3, 4, 7} {
+		got, author, err := unsuggestContent(content, "main.go", line)
+		require.NoError(t, err, "line %d", line)
+		assert.Equal(t, want, got, "line %d", line)
+		assert.Equal(t, "@alice", author)
+	}
+
+	_, _, err := unsuggestContent(content, "main.go", 1)
+	assert.ErrorContains(t, err, "no thread there")
+	_, _, err = unsuggestContent(content, "main.go", 20)
+	assert.Error(t, err)
+}
+
+func TestUnsuggestContentNoSuggestion(t *testing.T) {
+	content := "x := 1\n// ╓───── new\n// ║ Why?\n"
+	_, _, err := unsuggestContent(content, "main.go", 2)
+	assert.ErrorContains(t, err, "no suggestion")
+}
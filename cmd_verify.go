@@ -0,0 +1,322 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"strings"
+	"syscall"
+	"testing/fstest"
+
+	"github.com/dnr/craft/pkg/craft"
+	"github.com/spf13/cobra"
+)
+
+var verifyCmd = &cobra.Command{
+	Use:   "verify",
+	Short: "Check that craft comments parse and round-trip cleanly",
+	Long: `Deserializes the craft comments in the tree, serializes them again into
+memory, and reports:
+
+  - files that fail to parse
+  - comments that would be lost by the round trip
+  - files whose content would change (the first differing line)
+
+Differences that 'craft fmt' would fix, stale sum fields on comments edited
+locally, missing version fields and changes to the PR-STATE.txt file index
+are not reported. Every file is scanned. No files are modified.
+
+Exits with an error if any problems are found, so it can be used as a
+pre-send check or a pre-commit hook.`,
+	RunE: runVerify,
+	Args: cobra.NoArgs,
+}
+
+func init() {
+	rootCmd.AddCommand(verifyCmd)
+}
+
+func runVerify(cmd *cobra.Command, args []string) error {
+	vcs, err := DetectVCS(".")
+	if err != nil {
+		return err
+	}
+
+	opts, _, err := getSerializeOptions(vcs, 0)
+	if err != nil {
+		return err
+	}
+
+	problems, err := verifyCraft(opts)
+	if err != nil {
+		return err
+	}
+	for _, p := range problems {
+		fmt.Println(p)
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("%d problem(s) found", len(problems))
+	}
+	fmt.Println("OK")
+	return nil
+}
+
+// verifyProblem is a single issue found by verifyCraft.
+type verifyProblem struct {
+	Path string
+	Line int // 1-based, or 0 if not tied to a line
+	Msg  string
+}
+
+func (p verifyProblem) String() string {
+	if p.Line > 0 {
+		return fmt.Sprintf("%s:%d: %s", p.Path, p.Line, p.Msg)
+	}
+	return fmt.Sprintf("%s: %s", p.Path, p.Msg)
+}
+
+// verifyCraft round-trips the craft comments in opts.FS through an in-memory
+// filesystem and returns any problems found. opts.FS is not modified.
+func verifyCraft(opts craft.SerializeOptions) ([]verifyProblem, error) {
+	var problems []verifyProblem
+
+	stateContent, err := craft.ReadFile(opts.FS, craft.PRStateFile)
+	if err != nil {
+		return nil, fmt.Errorf("reading PR state: %w", err)
+	}
+	pr := &PullRequest{}
+	if err := craft.DeserializePRState(opts, pr, string(stateContent)); err != nil {
+		problems = append(problems, verifyProblem{Path: craft.PRStateFile, Msg: err.Error()})
+	}
+	pr.Body = prStateDescription(string(stateContent))
+
+	files, err := craft.ListFiles(opts)
+	if err != nil {
+		return nil, fmt.Errorf("listing files: %w", err)
+	}
+
+	// Scratch filesystem: files with their craft comments stripped, plus
+	// anything serialization reads besides the files themselves.
+	scratch := fstest.MapFS{}
+	originals := map[string]string{craft.PRStateFile: string(stateContent)}
+	for _, file := range files {
+		content, err := craft.ReadFile(opts.FS, file)
+		if err != nil {
+			if errors.Is(err, syscall.EISDIR) || errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+			return nil, fmt.Errorf("reading %s: %w", file, err)
+		}
+		if path.Base(file) == ".editorconfig" {
+			scratch[file] = &fstest.MapFile{Data: content}
+		}
+
+		threads, err := craft.DeserializeFileComments(opts, file)
+		var parseErrs craft.ParseErrors
+		if errors.As(err, &parseErrs) {
+			for _, pe := range parseErrs {
+				problems = append(problems, verifyProblem{Path: pe.Path, Line: pe.Line, Msg: pe.Msg})
+			}
+		} else if err != nil {
+			problems = append(problems, verifyProblem{Path: file, Msg: err.Error()})
+			continue
+		}
+		if len(threads) == 0 {
+			continue
+		}
+		pr.ReviewThreads = append(pr.ReviewThreads, threads...)
+		originals[file] = string(content)
+		scratch[file] = &fstest.MapFile{Data: []byte(stripCraftContent(string(content), file))}
+	}
+
+	// Threads collected in PR-OUTDATED.txt
+	if content, err := craft.ReadFile(opts.FS, craft.OutdatedFile); err == nil {
+		threads, err := craft.DeserializeOutdatedFile(opts)
+		var parseErrs craft.ParseErrors
+		if errors.As(err, &parseErrs) {
+			for _, pe := range parseErrs {
+				problems = append(problems, verifyProblem{Path: pe.Path, Line: pe.Line, Msg: pe.Msg})
+			}
+		} else if err != nil {
+			problems = append(problems, verifyProblem{Path: craft.OutdatedFile, Msg: err.Error()})
+		}
+		pr.ReviewThreads = append(pr.ReviewThreads, threads...)
+		originals[craft.OutdatedFile] = string(content)
+		opts.OutdatedFile = true
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("reading %s: %w", craft.OutdatedFile, err)
+	}
+
+	scratchOpts := opts
+	scratchOpts.FS = scratch
+	scratchOpts.VCS = nil
+	scratchOpts.Originals = nil
+	if err := craft.Serialize(pr, scratchOpts); err != nil {
+		return nil, fmt.Errorf("serializing: %w", err)
+	}
+
+	// Comments that don't survive a second deserialize
+	pr2, err := craft.Deserialize(scratchOpts)
+	if err != nil {
+		return nil, fmt.Errorf("deserializing round trip: %w", err)
+	}
+	kept := make(map[string]bool)
+	for _, thread := range pr2.ReviewThreads {
+		for _, c := range thread.Comments {
+			kept[verifyCommentKey(thread.Path, c.ID, c.Body)] = true
+		}
+	}
+	for _, c := range pr2.IssueComments {
+		kept[verifyCommentKey(craft.PRStateFile, c.ID, c.Body)] = true
+	}
+	for _, thread := range pr.ReviewThreads {
+		for _, c := range thread.Comments {
+			if !kept[verifyCommentKey(thread.Path, c.ID, c.Body)] {
+				problems = append(problems, verifyProblem{
+					Path: thread.Path,
+					Msg:  fmt.Sprintf("comment %s below source line %d would be lost", verifyCommentName(c.ID, c.Author.Login), thread.Line),
+				})
+			}
+		}
+	}
+	for _, c := range pr.IssueComments {
+		if !kept[verifyCommentKey(craft.PRStateFile, c.ID, c.Body)] {
+			problems = append(problems, verifyProblem{
+				Path: craft.PRStateFile,
+				Msg:  fmt.Sprintf("comment %s would be lost", verifyCommentName(c.ID, c.Author.Login)),
+			})
+		}
+	}
+
+	// Byte differences, ignoring what craft fmt would fix
+	for _, file := range append(files, craft.PRStateFile, craft.OutdatedFile) {
+		original, ok := originals[file]
+		if !ok {
+			continue
+		}
+		delete(originals, file) // craft.PRStateFile may also be listed
+		var roundTrip string
+		if f, ok := scratch[file]; ok {
+			roundTrip = string(f.Data)
+		}
+		if file == craft.PRStateFile {
+			original, _ = fmtPRStateContent(original, opts)
+			// The index may rightly gain files with new comments
+			original, _, _ = craft.SplitFileIndex(original)
+			roundTrip, _, _ = craft.SplitFileIndex(roundTrip)
+		} else {
+			original, _ = fmtCraftContent(original, file, opts)
+			// The round trip has no VCS to mark changed lines with, and
+			// doesn't keep quoted hunks and previous bodies
+			original = stripInfoLines(original, file)
+		}
+		if line, ok := firstDifferentLine(original, roundTrip); ok {
+			problems = append(problems, verifyProblem{
+				Path: file,
+				Line: line,
+				Msg:  "content would change on re-serialize",
+			})
+		}
+	}
+
+	return problems, nil
+}
+
+// stripCraftContent removes craft comments and the outdated comments section
+// from a file, undoing what serializeFileComments adds.
+func stripCraftContent(content, path string) string {
+	enc, content := craft.DecodeFile(content)
+	style := craft.GetCommentStyle(path)
+	lines := strings.Split(content, "\n")
+	var result []string
+	for i, parsed := range craft.ParseCraftLines(lines, style.LinePrefix) {
+		if strings.TrimSpace(lines[i]) == style.LinePrefix+" "+craft.OutdatedCommentsHeader {
+			// Serialize adds one blank line before the section
+			if n := len(result); n > 0 && result[n-1] == "" {
+				result = result[:n-1]
+			}
+			break
+		}
+		if !parsed.OK && !craft.IsShorthandLine(lines[i], style) {
+			result = append(result, lines[i])
+		}
+	}
+	return enc.Encode(strings.Join(result, "\n"))
+}
+
+// stripInfoLines removes the informational craft lines that Deserialize
+// ignores: change markers added with SerializeOptions.MarkChanges, and quoted
+// diff hunks and previous bodies.
+func stripInfoLines(content, path string) string {
+	enc, content := craft.DecodeFile(content)
+	lines := strings.Split(content, "\n")
+	var result []string
+	for i, parsed := range craft.ParseCraftLines(lines, craft.GetCommentStyle(path).LinePrefix) {
+		if parsed.Box != craft.BoxChange && parsed.Box != craft.BoxHunk {
+			result = append(result, lines[i])
+		}
+	}
+	return enc.Encode(strings.Join(result, "\n"))
+}
+
+// prStateDescription returns the PR description from PR-STATE.txt: the body
+// under the metadata header, which craft.DeserializePRState ignores.
+func prStateDescription(content string) string {
+	var bodyLines []string
+	var inDescription bool
+	for _, line := range strings.Split(content, "\n") {
+		if _, isHeader := craft.ParseHeader(strings.TrimSpace(line)); isHeader {
+			if inDescription {
+				break
+			}
+			inDescription = true
+			continue
+		}
+		if inDescription {
+			bodyLines = append(bodyLines, line)
+		}
+	}
+	return craft.ParseCommentBody(bodyLines, false)
+}
+
+// verifyCommentKey identifies a comment across a round trip. New comments have
+// no node ID, so they're identified by their body.
+func verifyCommentKey(path, id, body string) string {
+	if id != "" {
+		return id
+	}
+	return path + "\x00" + body
+}
+
+func verifyCommentName(id, author string) string {
+	switch {
+	case id == "":
+		return "(new)"
+	case author != "":
+		return "by @" + author + " (" + craft.FormatNodeID(id) + ")"
+	default:
+		return craft.FormatNodeID(id)
+	}
+}
+
+// derivedFieldRe matches header fields that serialize recomputes: sum differs
+// whenever a comment has been edited locally, and the version is added to
+// headers written by hand or by an older craft.
+var derivedFieldRe = regexp.MustCompile(` [─-] (?:sum [0-9a-f]+|v[0-9]+)\b`)
+
+// firstDifferentLine returns the 1-based number of the first line that differs
+// between a and b, ignoring derived header fields.
+func firstDifferentLine(a, b string) (int, bool) {
+	aLines, bLines := strings.Split(a, "\n"), strings.Split(b, "\n")
+	for i := 0; i < max(len(aLines), len(bLines)); i++ {
+		if i >= len(aLines) || i >= len(bLines) {
+			return i + 1, true
+		}
+		if derivedFieldRe.ReplaceAllString(aLines[i], "") != derivedFieldRe.ReplaceAllString(bLines[i], "") {
+			return i + 1, true
+		}
+	}
+	return 0, false
+}
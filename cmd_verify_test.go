@@ -0,0 +1,140 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/dnr/craft/pkg/craft"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerifyCraft(t *testing.T) {
+	pr := &PullRequest{
+		ID:         "PR_kwDOPgi5ks6k-agY",
+		Number:     42,
+		HeadRefOID: "abc123",
+		Author:     Actor{Login: "alice"},
+		Body:       "The description.",
+		ReviewThreads: []ReviewThread{
+			{
+				Path: "main.go", Line: 2, DiffSide: DiffSideRight, SubjectType: SubjectTypeLine,
+				Comments: []ReviewComment{{
+					ID:        "PRRC_kwDOPgi5ks6AAA111",
+					Author:    Actor{Login: "bob"},
+					Body:      "Inline comment",
+					CreatedAt: time.Date(2025, 1, 15, 12, 34, 0, 0, time.UTC),
+				}},
+			},
+			{
+				Path: "main.go", Line: 40, OriginalLine: 40, IsOutdated: true, IsResolved: true,
+				DiffSide: DiffSideRight, SubjectType: SubjectTypeLine,
+				Comments: []ReviewComment{{
+					ID:        "PRRC_kwDOPgi5ks6BBB222",
+					Author:    Actor{Login: "carol"},
+					Body:      "Outdated comment",
+					CreatedAt: time.Date(2025, 1, 16, 9, 0, 0, 0, time.UTC),
+				}},
+			},
+		},
+		IssueComments: []IssueComment{{
+			ID:        "IC_kwDOPgi5ks1234567",
+			Author:    Actor{Login: "dave"},
+			Body:      "LGTM",
+			CreatedAt: time.Date(2025, 1, 17, 10, 0, 0, 0, time.UTC),
+		}},
+	}
+
+	setup := func(t *testing.T) (fstest.MapFS, craft.SerializeOptions) {
+		memfs := fstest.MapFS{
+			"main.go": &fstest.MapFile{Data: []byte("package main\n\nfunc main() {\n}\n")},
+		}
+		opts := craft.SerializeOptions{FS: memfs}
+		require.NoError(t, craft.Serialize(pr, opts))
+		return memfs, opts
+	}
+
+	t.Run("clean", func(t *testing.T) {
+		_, opts := setup(t)
+		problems, err := verifyCraft(opts)
+		require.NoError(t, err)
+		assert.Empty(t, problems)
+	})
+
+	t.Run("hand-typed and edited comments", func(t *testing.T) {
+		memfs, opts := setup(t)
+		data := string(memfs["main.go"].Data)
+		data = strings.Replace(data, "// ║ Inline comment\n",
+			"// ║ Inline comment, edited\n// ╟───── new\n// ║ A reply\n// ║ typed by hand\n", 1)
+		memfs["main.go"] = &fstest.MapFile{Data: []byte(data)}
+
+		problems, err := verifyCraft(opts)
+		require.NoError(t, err)
+		assert.Empty(t, problems)
+	})
+
+	t.Run("malformed lines", func(t *testing.T) {
+		memfs, opts := setup(t)
+		data := strings.Replace(string(memfs["main.go"].Data), "func main() {\n",
+			"func main() {\n// ║ stray\n", 1)
+		memfs["main.go"] = &fstest.MapFile{Data: []byte(data)}
+
+		problems, err := verifyCraft(opts)
+		require.NoError(t, err)
+		require.NotEmpty(t, problems)
+		assert.Equal(t, "main.go:6: comment body line without a header", problems[0].String())
+	})
+
+	t.Run("reordered threads", func(t *testing.T) {
+		memfs, opts := setup(t)
+		// A newer thread above an older one on the same line gets reordered
+		data := string(memfs["main.go"].Data)
+		data = strings.Replace(data, "package main\n",
+			"package main\n"+
+				"// ╓───── @erin ─ at 2025-02-01 00:00 ─ prrc kwDOPgi5ks6CCC333\n// ║ Later\n"+
+				"// ╓───── @frank ─ at 2025-01-01 00:00 ─ prrc kwDOPgi5ks6DDD444\n// ║ Earlier\n", 1)
+		memfs["main.go"] = &fstest.MapFile{Data: []byte(data)}
+
+		problems, err := verifyCraft(opts)
+		require.NoError(t, err)
+		require.Len(t, problems, 1)
+		assert.Equal(t, "main.go:2: content would change on re-serialize", problems[0].String())
+	})
+
+	t.Run("outdated file", func(t *testing.T) {
+		memfs := fstest.MapFS{
+			"main.go": &fstest.MapFile{Data: []byte("package main\n\nfunc main() {\n}\n")},
+		}
+		require.NoError(t, craft.Serialize(pr, craft.SerializeOptions{FS: memfs, OutdatedFile: true}))
+		require.Contains(t, string(memfs[craft.OutdatedFile].Data), "Outdated comment")
+
+		// Found without OutdatedFile set
+		opts := craft.SerializeOptions{FS: memfs}
+		problems, err := verifyCraft(opts)
+		require.NoError(t, err)
+		assert.Empty(t, problems)
+
+		data := strings.Replace(string(memfs[craft.OutdatedFile].Data), "║ Outdated comment\n", "║ Outdated comment\n\n║ stray\n", 1)
+		memfs[craft.OutdatedFile] = &fstest.MapFile{Data: []byte(data)}
+		problems, err = verifyCraft(opts)
+		require.NoError(t, err)
+		require.NotEmpty(t, problems)
+		assert.Equal(t, craft.OutdatedFile+":5: comment body line without a header", problems[0].String())
+	})
+}
+
+func TestStripCraftContent(t *testing.T) {
+	memfs := fstest.MapFS{
+		"main.go": &fstest.MapFile{Data: []byte("a\nb\n")},
+	}
+	pr := &PullRequest{
+		ReviewThreads: []ReviewThread{
+			{Path: "main.go", Line: 1, Comments: []ReviewComment{{IsNew: true, Body: "x"}}},
+			{Path: "main.go", Line: 99, Comments: []ReviewComment{{IsNew: true, Body: "y"}}},
+		},
+	}
+	require.NoError(t, craft.Serialize(pr, craft.SerializeOptions{FS: memfs}))
+	assert.Equal(t, "a\nb\n", stripCraftContent(string(memfs["main.go"].Data), "main.go"))
+}
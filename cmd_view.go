@@ -0,0 +1,124 @@
+package main
+
+import (
+	"os"
+	"strings"
+
+	"github.com/dnr/craft/pkg/craft"
+	"github.com/spf13/cobra"
+)
+
+var viewCmd = &cobra.Command{
+	Use:   "view <file>",
+	Short: "Show a file with its craft threads colored",
+	Long: `Shows a file in a pager with its craft threads colored: authors stand out,
+new comments are highlighted and resolved threads are dimmed. Code lines are
+shown as they are.
+
+The pager is the same as for 'craft diff'. Colors are used when writing to a
+terminal, unless NO_COLOR is set, or as set by --color.
+
+Examples:
+  craft view main.go
+  craft view --color=always main.go | less -R`,
+	RunE: runView,
+	Args: cobra.ExactArgs(1),
+}
+
+var flagViewNoPager bool
+
+func init() {
+	viewCmd.Flags().BoolVar(&flagViewNoPager, "no-pager", false, "Don't send output through a pager")
+	rootCmd.AddCommand(viewCmd)
+}
+
+func runView(cmd *cobra.Command, args []string) error {
+	vcs, err := DetectVCS(".")
+	if err != nil {
+		return err
+	}
+
+	content, err := os.ReadFile(args[0])
+	if err != nil {
+		return err
+	}
+	_, cfg, err := getSerializeOptions(vcs, 0)
+	if err != nil {
+		return err
+	}
+	// Decoded, since it's shown rather than written back
+	_, text := craft.DecodeFile(string(content))
+	if cfg.RenderEmoji {
+		text = renderCraftEmoji(text, args[0])
+	}
+	if useColor(os.Stdout) {
+		text = colorCraftThreads(text, args[0])
+	}
+	if flagViewNoPager || !isTerminal(os.Stdout) {
+		_, err := os.Stdout.WriteString(text)
+		return err
+	}
+	return runPager(vcs, text)
+}
+
+// renderCraftEmoji shows the :shortcode: emoji in the comment bodies of a
+// file's decoded content as Unicode.
+func renderCraftEmoji(content, path string) string {
+	lines := strings.Split(content, "\n")
+	for i, parsed := range craft.ParseCraftLines(lines, craft.GetCommentStyle(path).LinePrefix) {
+		if parsed.OK && parsed.Box == craft.BoxBody {
+			lines[i] = craft.RenderEmojiText(lines[i])
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// colorCraftThreads adds ANSI colors to the craft lines in a file's decoded
+// content: headers in cyan with the author in bold, new comments in yellow,
+// and resolved threads, quoted hunks and marker lines dimmed.
+func colorCraftThreads(content, path string) string {
+	style := craft.GetCommentStyle(path)
+	lines := strings.Split(content, "\n")
+
+	var resolved, isNew bool // of the current thread and comment
+	for i, parsed := range craft.ParseCraftLines(lines, style.LinePrefix) {
+		if !parsed.OK {
+			resolved, isNew = false, false
+			continue
+		}
+		line := lines[i]
+		switch parsed.Box {
+		case craft.BoxThread, craft.BoxReply:
+			h, ok := craft.ParseHeader(parsed.Content)
+			if !ok {
+				break
+			}
+			if parsed.Box == craft.BoxThread {
+				resolved = h.IsResolved
+			}
+			isNew = h.IsNew
+			switch {
+			case resolved:
+				line = ansiDim + line + ansiReset
+			case isNew:
+				line = ansiBold + ansiYellow + line + ansiReset
+			case h.Author != "":
+				// The author is the header's first field
+				line = ansiCyan + strings.Replace(line, "@"+h.Author, ansiBold+"@"+h.Author+ansiReset+ansiCyan, 1) + ansiReset
+			default:
+				line = ansiCyan + line + ansiReset
+			}
+		case craft.BoxBody:
+			switch {
+			case resolved:
+				line = ansiDim + line + ansiReset
+			case isNew:
+				line = ansiYellow + line + ansiReset
+			}
+		default: // quoted hunks and marker lines
+			line = ansiDim + line + ansiReset
+		}
+		lines[i] = line
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestColorCraftThreads(t *testing.T) {
+	content := "package main\n" +
+		"// ╓───── @bob ─ 2025-01-01 09:00 ─ resolved ─ v2\n" +
+		"// ║ Done\n" +
+		"// ╟───── @alice ─ 2025-01-02 09:00 ─ v2\n" +
+		"// ║ Thanks\n" +
+		"func main() {}\n" +
+		"// ╓───── @carol ─ 2025-01-03 09:00 ─ v2\n" +
+		"// ║ Why?\n" +
+		"// ╟───── new\n" +
+		"// ║ Because\n" +
+		"// not craft\n"
+	lines := strings.Split(colorCraftThreads(content, "main.go"), "\n")
+
+	assert.Equal(t, "package main", lines[0])
+	// Resolved threads are dimmed, replies too
+	for _, line := range lines[1:5] {
+		assert.True(t, strings.HasPrefix(line, ansiDim), line)
+	}
+	assert.Equal(t, "func main() {}", lines[5])
+	assert.Contains(t, lines[6], ansiBold+"@carol"+ansiReset)
+	assert.Equal(t, "// ║ Why?", lines[7])
+	assert.Equal(t, ansiBold+ansiYellow+"// ╟───── new"+ansiReset, lines[8])
+	assert.Equal(t, ansiYellow+"// ║ Because"+ansiReset, lines[9])
+	assert.Equal(t, "// not craft", lines[10])
+}
+
+func TestRenderCraftEmoji(t *testing.T) {
+	content := "s := \":+1:\"\n" +
+		"// ╓───── @carol ─ 2025-01-03 09:00 ─ v2\n" +
+		"// ║ Nice :+1: but :notanemoji: here\n"
+	assert.Equal(t, "s := \":+1:\"\n"+
+		"// ╓───── @carol ─ 2025-01-03 09:00 ─ v2\n"+
+		"// ║ Nice 👍 but :notanemoji: here\n", renderCraftEmoji(content, "main.go"))
+}
@@ -4,6 +4,7 @@ import (
 	"io"
 	"os"
 
+	"github.com/dnr/craft/pkg/craft"
 	"github.com/spf13/cobra"
 	"rsc.io/markdown"
 )
@@ -24,10 +25,14 @@ var unwrapCmd = &cobra.Command{
 	Args:  cobra.NoArgs,
 }
 
-var flagWrapWidth int
+var (
+	flagWrapWidth int
+	flagWrapEmoji bool
+)
 
 func init() {
 	wrapCmd.Flags().IntVarP(&flagWrapWidth, "width", "w", 80, "Line width for wrapping")
+	wrapCmd.Flags().BoolVar(&flagWrapEmoji, "emoji", false, "Render :shortcode: emoji as Unicode")
 }
 
 func runWrap(cmd *cobra.Command, args []string) error {
@@ -36,9 +41,11 @@ func runWrap(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
-	p := markdown.Parser{}
-	doc := p.Parse(string(input))
-	wrapped := Wrap(doc, flagWrapWidth)
+	doc := craft.NewMarkdownParser().Parse(string(input))
+	if flagWrapEmoji {
+		craft.RenderEmoji(doc)
+	}
+	wrapped := craft.Wrap(doc, flagWrapWidth)
 	os.Stdout.WriteString(markdown.Format(wrapped))
 	return nil
 }
@@ -49,9 +56,8 @@ func runUnwrap(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
-	p := markdown.Parser{}
-	doc := p.Parse(string(input))
-	unwrapped := Unwrap(doc)
+	doc := craft.NewMarkdownParser().Parse(string(input))
+	unwrapped := craft.Unwrap(doc)
 	os.Stdout.WriteString(markdown.Format(unwrapped))
 	return nil
 }
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// ANSI escapes for colored output
+const (
+	ansiReset     = "\x1b[0m"
+	ansiBold      = "\x1b[1m"
+	ansiDim       = "\x1b[2m"
+	ansiItalic    = "\x1b[3m"
+	ansiUnderline = "\x1b[4m"
+	ansiStrike    = "\x1b[9m"
+	ansiRed       = "\x1b[31m"
+	ansiGreen     = "\x1b[32m"
+	ansiYellow    = "\x1b[33m"
+	ansiCyan      = "\x1b[36m"
+)
+
+var (
+	flagColor   string
+	flagNoColor bool
+)
+
+func init() {
+	rootCmd.PersistentFlags().StringVar(&flagColor, "color", "auto", "Color output: auto, always or never")
+	rootCmd.PersistentFlags().BoolVar(&flagNoColor, "no-color", false, "Same as --color=never")
+	rootCmd.MarkFlagsMutuallyExclusive("color", "no-color")
+}
+
+// checkColorFlag reports an invalid --color.
+func checkColorFlag() error {
+	switch flagColor {
+	case "auto", "always", "never":
+		return nil
+	}
+	return fmt.Errorf("invalid --color %q (want auto, always or never)", flagColor)
+}
+
+// useColor reports whether to color output written to f: as --color says,
+// or by default when f is a terminal, TERM isn't dumb and NO_COLOR
+// (no-color.org) isn't set.
+func useColor(f *os.File) bool {
+	switch {
+	case flagNoColor || flagColor == "never":
+		return false
+	case flagColor == "always":
+		return true
+	case os.Getenv("NO_COLOR") != "" || os.Getenv("TERM") == "dumb":
+		return false
+	}
+	return isTerminal(f)
+}
+
+// isTerminal reports whether f is a terminal.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// paint wraps s in the given ANSI escapes if on is set.
+func paint(on bool, escapes, s string) string {
+	if !on || s == "" {
+		return s
+	}
+	return escapes + s + ansiReset
+}
+
+// colorDiff colors a unified diff like git does: headers bold, hunk headers
+// cyan, and removed and added lines red and green.
+func colorDiff(diff string) string {
+	lines := strings.Split(diff, "\n")
+	for i, line := range lines {
+		switch {
+		case strings.HasPrefix(line, "--- "), strings.HasPrefix(line, "+++ "), strings.HasPrefix(line, "diff "):
+			lines[i] = paint(true, ansiBold, line)
+		case strings.HasPrefix(line, "@@"):
+			lines[i] = paint(true, ansiCyan, line)
+		case strings.HasPrefix(line, "-"):
+			lines[i] = paint(true, ansiRed, line)
+		case strings.HasPrefix(line, "+"):
+			lines[i] = paint(true, ansiGreen, line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
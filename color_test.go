@@ -0,0 +1,43 @@
+package main
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUseColor(t *testing.T) {
+	f, err := os.CreateTemp(t.TempDir(), "out")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	oldColor, oldNoColor := flagColor, flagNoColor
+	t.Cleanup(func() { flagColor, flagNoColor = oldColor, oldNoColor })
+
+	t.Setenv("NO_COLOR", "")
+	flagColor, flagNoColor = "auto", false
+	assert.False(t, useColor(f), "not a terminal")
+	flagColor = "always"
+	assert.True(t, useColor(f))
+	t.Setenv("NO_COLOR", "1")
+	assert.True(t, useColor(f), "--color=always wins over NO_COLOR")
+	flagColor, flagNoColor = "auto", true
+	assert.False(t, useColor(f))
+
+	flagColor = "sometimes"
+	assert.Error(t, checkColorFlag())
+}
+
+func TestColorDiff(t *testing.T) {
+	diff := "--- a/main.go\n+++ b/main.go\n@@ -1,2 +1,2 @@\n package main\n-old\n+new\n"
+	want := ansiBold + "--- a/main.go" + ansiReset + "\n" +
+		ansiBold + "+++ b/main.go" + ansiReset + "\n" +
+		ansiCyan + "@@ -1,2 +1,2 @@" + ansiReset + "\n" +
+		" package main\n" +
+		ansiRed + "-old" + ansiReset + "\n" +
+		ansiGreen + "+new" + ansiReset + "\n"
+	assert.Equal(t, want, colorDiff(diff))
+	assert.Equal(t, "x", paint(false, ansiRed, "x"))
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// openPRCacheTTL is how long completion reuses the list of open PRs, so
+// pressing tab a few times makes one query.
+const openPRCacheTTL = time.Minute
+
+// completionTimeout bounds the query for open PRs, so a slow network doesn't
+// hang the shell.
+const completionTimeout = 5 * time.Second
+
+// completePRNumbers completes the PR argument of craft get with the numbers
+// of open PRs, described by their titles.
+func completePRNumbers(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	prs, err := openPRsForCompletion(cmd.Context())
+	if err != nil {
+		cobra.CompDebugln(err.Error(), true)
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	return prCompletions(prs, toComplete), cobra.ShellCompDirectiveNoFileComp | cobra.ShellCompDirectiveKeepOrder
+}
+
+// openPRsForCompletion returns the open PRs of the current repo, from the
+// cache if it's recent enough.
+func openPRsForCompletion(ctx context.Context) ([]OpenPR, error) {
+	vcs, err := DetectVCS(".")
+	if err != nil {
+		return nil, err
+	}
+	client, owner, repo, err := getGitHubClientAndRepo(vcs, resolveRemote(vcs, flagGetRemote))
+	if err != nil {
+		return nil, err
+	}
+
+	var cachePath string
+	if dir, err := os.UserCacheDir(); err == nil {
+		cachePath = filepath.Join(dir, "craft", fmt.Sprintf("open-prs-%s-%s.json", owner, repo))
+		if prs, ok := loadOpenPRCache(cachePath, time.Now()); ok {
+			return prs, nil
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, completionTimeout)
+	defer cancel()
+	prs, err := client.FetchOpenPRs(ctx, owner, repo)
+	if err != nil {
+		return nil, err
+	}
+	if cachePath != "" {
+		if err := saveOpenPRCache(cachePath, prs); err != nil {
+			cobra.CompDebugln(err.Error(), true)
+		}
+	}
+	return prs, nil
+}
+
+// loadOpenPRCache reads the open PRs cached at path, if they were cached
+// less than openPRCacheTTL before now.
+func loadOpenPRCache(path string, now time.Time) ([]OpenPR, bool) {
+	info, err := os.Stat(path)
+	if err != nil || now.Sub(info.ModTime()) >= openPRCacheTTL {
+		return nil, false
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false
+	}
+	var prs []OpenPR
+	if err := json.Unmarshal(data, &prs); err != nil {
+		return nil, false
+	}
+	return prs, true
+}
+
+// saveOpenPRCache writes the open PRs to path for loadOpenPRCache.
+func saveOpenPRCache(path string, prs []OpenPR) error {
+	data, err := json.Marshal(prs)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// prCompletions returns "number\ttitle" for each PR whose number starts
+// with toComplete; shells that can show descriptions show the title.
+func prCompletions(prs []OpenPR, toComplete string) []string {
+	var completions []string
+	for _, pr := range prs {
+		number := strconv.Itoa(pr.Number)
+		if !strings.HasPrefix(number, toComplete) {
+			continue
+		}
+		// A tab or newline in the title would break the completion format
+		title := strings.Join(strings.Fields(pr.Title), " ")
+		completions = append(completions, number+"\t"+title)
+	}
+	return completions
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPRCompletions(t *testing.T) {
+	prs := []OpenPR{
+		{Number: 123, Title: "Fix race in scheduler"},
+		{Number: 45, Title: "Tabs\tand\nnewlines"},
+		{Number: 12, Title: "Docs"},
+	}
+	assert.Equal(t, []string{"123\tFix race in scheduler", "45\tTabs and newlines", "12\tDocs"}, prCompletions(prs, ""))
+	assert.Equal(t, []string{"123\tFix race in scheduler", "12\tDocs"}, prCompletions(prs, "12"))
+	assert.Empty(t, prCompletions(prs, "9"))
+}
+
+func TestOpenPRCache(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "craft", "open-prs-dnr-craft.json")
+	now := time.Now()
+	_, ok := loadOpenPRCache(path, now)
+	assert.False(t, ok)
+
+	prs := []OpenPR{{Number: 1, Title: "One"}}
+	require.NoError(t, saveOpenPRCache(path, prs))
+	got, ok := loadOpenPRCache(path, now)
+	require.True(t, ok)
+	assert.Equal(t, prs, got)
+
+	// Stale after the TTL
+	require.NoError(t, os.Chtimes(path, now, now.Add(-openPRCacheTTL)))
+	_, ok = loadOpenPRCache(path, now)
+	assert.False(t, ok)
+}
@@ -0,0 +1,319 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/dnr/craft/pkg/craft"
+	"gopkg.in/yaml.v3"
+)
+
+// configFile is the repo-local craft configuration file, read from the repo root.
+const configFile = ".craft.yaml"
+
+// Config holds craft settings from the config files, with the settings that
+// can also be given in git config or the environment applied (see setting).
+type Config struct {
+	// Snippets maps a short name (e.g. "nit") to a markdown template.
+	// When a new comment body starts with the name, it's expanded using the
+	// template. See expandSnippet for the supported placeholders.
+	Snippets map[string]string `yaml:"snippets"`
+
+	// RenderEmoji shows :shortcode: emoji as Unicode in craft view and craft
+	// serve. The files keep the shortcodes, since they're sent back.
+	RenderEmoji bool `yaml:"renderEmoji"`
+
+	// EditorConfig wraps comments at max_line_length from .editorconfig
+	// when it applies to the file, instead of the configured wrap width.
+	EditorConfig bool `yaml:"editorConfig"`
+
+	// NoReflow stores comment bodies verbatim in serialized files instead of
+	// wrapping them, so they round-trip byte-for-byte.
+	NoReflow bool `yaml:"noReflow"`
+
+	// ASCII writes craft comments with ASCII markers (|> |+ |) instead of
+	// box drawing characters. Both are always recognized when reading.
+	ASCII bool `yaml:"ascii"`
+
+	// OutdatedFile collects outdated and resolved threads in PR-OUTDATED.txt,
+	// grouped by file, instead of leaving them in the source files.
+	OutdatedFile bool `yaml:"outdatedFile"`
+
+	// IncludeResolved serializes resolved threads, which are left out by
+	// default. Same as craft get --include-resolved.
+	IncludeResolved bool `yaml:"includeResolved"`
+
+	// MarkChanges adds a marker line (▼ 3 lines changed) before each run of
+	// lines the PR changed, and where it deleted lines.
+	MarkChanges bool `yaml:"markChanges"`
+
+	// Lint configures the checks on new comments before send.
+	Lint craft.LintConfig `yaml:"lint"`
+
+	// Spell configures the spell check of new comments in send --dry-run.
+	Spell SpellConfig `yaml:"spell"`
+}
+
+// boolSettings returns the Config fields that are also settings.
+func (c *Config) boolSettings() map[string]*bool {
+	return map[string]*bool{
+		"renderEmoji":     &c.RenderEmoji,
+		"editorConfig":    &c.EditorConfig,
+		"noReflow":        &c.NoReflow,
+		"ascii":           &c.ASCII,
+		"outdatedFile":    &c.OutdatedFile,
+		"includeResolved": &c.IncludeResolved,
+		"markChanges":     &c.MarkChanges,
+	}
+}
+
+// A setting is a single-valued craft setting. Its value comes from, lowest
+// first: its default, the user config file, .craft.yaml, git config
+// craft.<name>, the environment (CRAFT_<NAME>), and command flags.
+type setting struct {
+	Name    string
+	Default string
+	Doc     string
+	// NotInRepo settings name programs to run or servers to send code to, so
+	// .craft.yaml, which comes with the code under review, can't set them
+	NotInRepo bool
+}
+
+var settings = []setting{
+	{Name: "renderEmoji", Default: "false", Doc: "Show :shortcode: emoji as Unicode in craft view and craft serve"},
+	{Name: "editorConfig", Default: "false", Doc: "Wrap at max_line_length from .editorconfig"},
+	{Name: "noReflow", Default: "false", Doc: "Store comment bodies verbatim instead of wrapping them"},
+	{Name: "ascii", Default: "false", Doc: "Write ASCII markers instead of box drawing characters"},
+	{Name: "outdatedFile", Default: "false", Doc: "Collect outdated and resolved threads in PR-OUTDATED.txt"},
+	{Name: "includeResolved", Default: "false", Doc: "Serialize resolved threads"},
+	{Name: "markChanges", Default: "false", Doc: "Mark the lines the PR changed"},
+	{Name: "remoteName", Default: "origin", Doc: "Git remote of the GitHub repo"},
+	{Name: "wrapWidth", Default: strconv.Itoa(craft.DefaultWrap), Doc: "Line width for wrapping comments"},
+	{Name: "commentPosition", Default: "below", Doc: "Put threads above or below their line"},
+	{Name: "autoCommit", Default: "true", Doc: "Commit the changes get, send and suggest make"},
+	{Name: "signCommits", Default: "", Doc: "Sign craft's commits (default: as git or jj is configured)"},
+	{Name: "commitHooks", Default: "true", Doc: "Run git's commit hooks on craft's commits"},
+	{Name: "pager", Default: "", Doc: "Pager for craft diff and view (default: $PAGER or less)", NotInRepo: true},
+	{Name: "assistCommand", Default: "", Doc: "Program craft assist runs", NotInRepo: true},
+	{Name: "assistURL", Default: "", Doc: "Service craft assist posts to", NotInRepo: true},
+}
+
+// findSetting returns the setting with the given name, ignoring case like
+// git config does.
+func findSetting(name string) (setting, bool) {
+	for _, s := range settings {
+		if strings.EqualFold(s.Name, name) {
+			return s, true
+		}
+	}
+	return setting{}, false
+}
+
+// envName returns the environment variable for a setting, e.g.
+// CRAFT_RENDER_EMOJI for renderEmoji and CRAFT_ASSIST_URL for assistURL.
+func (s setting) envName() string {
+	var b strings.Builder
+	b.WriteString("CRAFT_")
+	runes := []rune(s.Name)
+	for i, r := range runes {
+		// A capital starts a word, unless it continues an acronym
+		if i > 0 && unicode.IsUpper(r) && !unicode.IsUpper(runes[i-1]) {
+			b.WriteByte('_')
+		}
+		b.WriteRune(unicode.ToUpper(r))
+	}
+	return b.String()
+}
+
+// Where a setting's value came from, as craft config shows it.
+const (
+	sourceDefault = "default"
+	sourceUser    = "user"
+	sourceRepo    = "repo"
+	sourceGit     = "git"
+	sourceEnv     = "env"
+)
+
+// configLayers holds the sources of setting values other than flags.
+type configLayers struct {
+	user, repo map[string]any // the config files, parsed
+	vcs        VCS            // may be nil
+}
+
+// loadConfigLayers reads the config files for resolving settings.
+func loadConfigLayers(fsys fs.FS, vcs VCS) (*configLayers, error) {
+	l := &configLayers{vcs: vcs}
+	data, err := readUserConfig()
+	if err != nil {
+		return nil, err
+	}
+	if err := yaml.Unmarshal(data, &l.user); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", userConfigFile(), err)
+	}
+	data, err = craft.ReadFile(fsys, configFile)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("reading %s: %w", configFile, err)
+	}
+	if err := yaml.Unmarshal(data, &l.repo); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", configFile, err)
+	}
+	return l, nil
+}
+
+// lookup returns the value of a setting and where it came from.
+func (l *configLayers) lookup(s setting) (value, source string) {
+	if v, ok := os.LookupEnv(s.envName()); ok {
+		return v, sourceEnv
+	}
+	if l.vcs != nil {
+		if v, err := l.vcs.GetConfigValue("craft." + s.Name); err == nil && v != "" {
+			return v, sourceGit
+		}
+	}
+	if v, ok := l.repo[s.Name]; ok && v != nil && !s.NotInRepo {
+		return fmt.Sprint(v), sourceRepo
+	}
+	if v, ok := l.user[s.Name]; ok && v != nil {
+		return fmt.Sprint(v), sourceUser
+	}
+	return s.Default, sourceDefault
+}
+
+// configValue returns the value of the named setting for the repo of vcs.
+// Config files that can't be read are ignored here; LoadConfig reports them.
+func configValue(vcs VCS, name string) string {
+	s, ok := findSetting(name)
+	if !ok {
+		panic("unknown setting " + name)
+	}
+	l, err := loadConfigLayers(craft.DirFS(vcs.Root()), vcs)
+	if err != nil {
+		l = &configLayers{vcs: vcs}
+	}
+	value, _ := l.lookup(s)
+	return value
+}
+
+// userConfigDir returns the user's craft config directory,
+// $XDG_CONFIG_HOME/craft or ~/.config/craft, or "" if there's no home.
+func userConfigDir() string {
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		dir = filepath.Join(home, ".config")
+	}
+	return filepath.Join(dir, "craft")
+}
+
+// userConfigFile returns the path of the user's craft config file,
+// config.yaml in userConfigDir.
+func userConfigFile() string {
+	dir := userConfigDir()
+	if dir == "" {
+		return ""
+	}
+	return filepath.Join(dir, "config.yaml")
+}
+
+// readUserConfig returns the user config file's contents, or nothing if
+// there isn't one.
+func readUserConfig() ([]byte, error) {
+	path := userConfigFile()
+	if path == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", path, err)
+	}
+	return data, nil
+}
+
+// LoadConfig reads the user config file and .craft.yaml from the root of
+// fsys, the latter taking precedence, and applies settings from git config
+// (if vcs isn't nil) and the environment.
+// Missing files are not an error and result in an empty config.
+func LoadConfig(fsys fs.FS, vcs VCS) (*Config, error) {
+	cfg := &Config{}
+	data, err := readUserConfig()
+	if err != nil {
+		return nil, err
+	}
+	if err := yaml.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", userConfigFile(), err)
+	}
+	data, err = craft.ReadFile(fsys, configFile)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("reading %s: %w", configFile, err)
+	}
+	if err := yaml.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", configFile, err)
+	}
+	if _, err := regexp.Compile(cfg.Lint.VaguePattern); err != nil {
+		return nil, fmt.Errorf("parsing %s: invalid lint.vaguePattern: %w", configFile, err)
+	}
+
+	// The files were read into cfg above; this adds git config and the
+	// environment
+	layers := &configLayers{vcs: vcs}
+	for name, field := range cfg.boolSettings() {
+		s, _ := findSetting(name)
+		value, source := layers.lookup(s)
+		if source == sourceDefault {
+			continue
+		}
+		var ok bool
+		if *field, ok = parseConfigBool(value); !ok {
+			return nil, fmt.Errorf("invalid value %q for %s (from %s)", value, name, source)
+		}
+	}
+	return cfg, nil
+}
+
+// getSerializeOptions returns the options to read and write the craft files
+// of vcs with, from the config, and the config. width overrides the wrap
+// width setting unless it's 0.
+func getSerializeOptions(vcs VCS, width int) (craft.SerializeOptions, *Config, error) {
+	opts := craft.SerializeOptions{FS: craft.DirFS(vcs.Root()), VCS: vcs}
+	cfg, err := LoadConfig(opts.FS, opts.VCS)
+	if err != nil {
+		return opts, nil, err
+	}
+	opts.EditorConfig = cfg.EditorConfig
+	opts.ASCII = cfg.ASCII
+	opts.NoReflow = cfg.NoReflow
+	opts.OutdatedFile = cfg.OutdatedFile || craft.HasOutdatedFile(opts.FS)
+	opts.MarkChanges = cfg.MarkChanges
+	opts.WrapWidth, err = resolveWrapWidth(vcs, width)
+	if err != nil {
+		return opts, nil, err
+	}
+	opts.CommentsAbove, err = resolveCommentsAbove(vcs)
+	if err != nil {
+		return opts, nil, err
+	}
+	return opts, cfg, nil
+}
+
+// parseConfigBool parses a boolean the ways git config allows.
+func parseConfigBool(value string) (bool, bool) {
+	switch strings.ToLower(value) {
+	case "true", "yes", "on", "1":
+		return true, true
+	case "false", "no", "off", "0", "":
+		return false, true
+	}
+	return false, false
+}
@@ -6,6 +6,7 @@ import (
 	"os"
 	"time"
 
+	"github.com/dnr/craft/pkg/craft"
 	"github.com/spf13/cobra"
 )
 
@@ -55,8 +56,8 @@ func runDebugComment(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("reading input file: %w", err)
 	}
 
-	var pr PullRequest
-	if err := json.Unmarshal(data, &pr); err != nil {
+	pr, err := craft.ParsePullRequestJSON(data)
+	if err != nil {
 		return fmt.Errorf("parsing input JSON: %w", err)
 	}
 
@@ -122,7 +123,7 @@ func runDebugComment(cmd *cobra.Command, args []string) error {
 	}
 
 	// Write output JSON
-	outData, err := json.MarshalIndent(&pr, "", "  ")
+	outData, err := json.MarshalIndent(pr, "", "  ")
 	if err != nil {
 		return fmt.Errorf("marshaling output JSON: %w", err)
 	}
@@ -38,7 +38,7 @@ func runDebugFetch(cmd *cobra.Command, args []string) error {
 	}
 
 	// Create client and fetch PR
-	client := NewGitHubClient(token)
+	client := newGitHubClient(token)
 	pr, err := client.FetchPullRequest(cmd.Context(), flagOwner, flagRepo, flagNumber)
 	if err != nil {
 		return fmt.Errorf("failed to fetch PR: %w", err)
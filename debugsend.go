@@ -1,10 +1,10 @@
 package main
 
 import (
-	"encoding/json"
 	"fmt"
 	"os"
 
+	"github.com/dnr/craft/pkg/craft"
 	"github.com/spf13/cobra"
 )
 
@@ -54,16 +54,18 @@ func runDebugSend(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("reading input file: %w", err)
 	}
 
-	var pr PullRequest
-	if err := json.Unmarshal(data, &pr); err != nil {
+	pr, err := craft.ParsePullRequestJSON(data)
+	if err != nil {
 		return fmt.Errorf("parsing input JSON: %w", err)
 	}
 
 	// Collect new comments using shared code
-	review, err := CollectNewComments(&pr)
+	review, err := craft.CollectNewComments(pr, craft.CollectOptions{})
 	if err != nil {
 		return err
 	}
+	// The JSON can't tell newly resolved threads from ones resolved on GitHub
+	review.Resolves = nil
 
 	if review.IsEmpty() {
 		fmt.Println("No new comments to send.")
@@ -82,7 +84,7 @@ func runDebugSend(cmd *cobra.Command, args []string) error {
 	fmt.Printf("Found %s\n", review.Summary())
 
 	if flagDebugSendDryRun {
-		review.PrintDryRun()
+		printDryRun(review, DryRunOptions{})
 		return nil
 	}
 
@@ -91,7 +93,7 @@ func runDebugSend(cmd *cobra.Command, args []string) error {
 	if err != nil {
 		return fmt.Errorf("getting GitHub token: %w", err)
 	}
-	client := NewGitHubClient(token)
+	client := newGitHubClient(token)
 
 	// Send the review using shared code
 	if err := review.Send(cmd.Context(), client, pr.ID, pr.HeadRefOID, flagDebugSendDiscardPendingReview); err != nil {
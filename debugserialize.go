@@ -2,9 +2,11 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 
+	"github.com/dnr/craft/pkg/craft"
 	"github.com/spf13/cobra"
 )
 
@@ -23,6 +25,9 @@ var debugDeserializeCmd = &cobra.Command{
 	Short: "Deserialize source files to PR JSON",
 	Long: `Reads comments from source files and PR-STATE.txt and outputs JSON.
 
+With --originals, comments whose text is unchanged since serializing get
+their bodies from that PR JSON file instead of the unwrapped local text.
+
 Example:
   craft debugdeserialize --workdir /path/to/repo --output pr.json`,
 	RunE: runDebugDeserialize,
@@ -32,6 +37,8 @@ var (
 	flagSerializeInput   string
 	flagSerializeWorkdir string
 	flagSerializeOutput  string
+	flagSerializeOrigs   string
+	flagSerializeFull    bool
 )
 
 func init() {
@@ -42,6 +49,8 @@ func init() {
 
 	debugDeserializeCmd.Flags().StringVar(&flagSerializeWorkdir, "workdir", "", "Working directory (repo root)")
 	debugDeserializeCmd.Flags().StringVar(&flagSerializeOutput, "output", "", "Output JSON file (default: stdout)")
+	debugDeserializeCmd.Flags().StringVar(&flagSerializeOrigs, "originals", "", "PR JSON file with original comment bodies")
+	debugDeserializeCmd.Flags().BoolVar(&flagSerializeFull, "full-scan", false, "Read every file, not just indexed and changed ones")
 	debugDeserializeCmd.MarkFlagRequired("workdir")
 }
 
@@ -52,8 +61,8 @@ func runDebugSerialize(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("reading input file: %w", err)
 	}
 
-	var pr PullRequest
-	if err := json.Unmarshal(data, &pr); err != nil {
+	pr, err := craft.ParsePullRequestJSON(data)
+	if err != nil {
 		return fmt.Errorf("parsing input JSON: %w", err)
 	}
 
@@ -61,11 +70,11 @@ func runDebugSerialize(cmd *cobra.Command, args []string) error {
 	vcs, _ := DetectVCS(flagSerializeWorkdir)
 
 	// Serialize to files
-	opts := SerializeOptions{
-		FS:  DirFS(flagSerializeWorkdir),
+	opts := craft.SerializeOptions{
+		FS:  craft.DirFS(flagSerializeWorkdir),
 		VCS: vcs,
 	}
-	if err := Serialize(&pr, opts); err != nil {
+	if err := craft.Serialize(pr, opts); err != nil {
 		return fmt.Errorf("serializing: %w", err)
 	}
 
@@ -77,13 +86,27 @@ func runDebugDeserialize(cmd *cobra.Command, args []string) error {
 	// Detect VCS for the workdir
 	vcs, _ := DetectVCS(flagSerializeWorkdir)
 
-	opts := SerializeOptions{
-		FS:  DirFS(flagSerializeWorkdir),
-		VCS: vcs,
+	opts := craft.SerializeOptions{
+		FS:       craft.DirFS(flagSerializeWorkdir),
+		VCS:      vcs,
+		FullScan: flagSerializeFull,
+	}
+	if flagSerializeOrigs != "" {
+		data, err := os.ReadFile(flagSerializeOrigs)
+		if err != nil {
+			return fmt.Errorf("reading originals file: %w", err)
+		}
+		orig, err := craft.ParsePullRequestJSON(data)
+		if err != nil {
+			return fmt.Errorf("parsing originals JSON: %w", err)
+		}
+		opts.Originals = orig.CommentBodies()
 	}
 
-	pr, err := Deserialize(opts)
-	if err != nil {
+	pr, err := craft.Deserialize(opts)
+	if errors.As(err, new(craft.ParseErrors)) {
+		fmt.Fprintf(os.Stderr, "warning: %v\n", err)
+	} else if err != nil {
 		return fmt.Errorf("deserializing: %w", err)
 	}
 
@@ -1,35 +1,38 @@
 package main
 
 import (
-	"context"
+	"cmp"
 	"fmt"
 	"os"
 	"path/filepath"
+	"runtime"
+	"strconv"
 	"strings"
-	"time"
 
-	"github.com/shurcooL/githubv4"
 	"github.com/zalando/go-keyring"
-	"golang.org/x/oauth2"
 	"gopkg.in/yaml.v3"
 )
 
-// GitHubClient wraps the GitHub GraphQL client.
-type GitHubClient struct {
-	client *githubv4.Client
-}
+// getGitHubToken reads the GitHub token from the GH_TOKEN or GITHUB_TOKEN env
+// var (in that order, like gh), the token file from 'craft login', or gh
+// CLI's config and keyring.
+func getGitHubToken() (string, error) {
+	// A replay doesn't go to GitHub
+	if flagReplay != "" {
+		return "replay", nil
+	}
 
-// NewGitHubClient creates a new GitHub GraphQL client with the given token.
-func NewGitHubClient(token string) *GitHubClient {
-	src := oauth2.StaticTokenSource(&oauth2.Token{AccessToken: token})
-	httpClient := oauth2.NewClient(context.Background(), src)
-	return &GitHubClient{client: githubv4.NewClient(httpClient)}
-}
+	// Try the environment first
+	for _, name := range []string{"GH_TOKEN", "GITHUB_TOKEN"} {
+		if token := os.Getenv(name); token != "" {
+			return token, nil
+		}
+	}
 
-// getGitHubToken reads the GitHub token from GITHUB_TOKEN env var or gh CLI's keyring.
-func getGitHubToken() (string, error) {
-	// Try GITHUB_TOKEN first
-	if token := os.Getenv("GITHUB_TOKEN"); token != "" {
+	// Then a token saved by 'craft login', for machines without a keyring
+	if token, err := readTokenFile(tokenFile()); err != nil {
+		return "", err
+	} else if token != "" {
 		return token, nil
 	}
 
@@ -37,15 +40,14 @@ func getGitHubToken() (string, error) {
 	hostname := "github.com"
 
 	// Read gh CLI config to get the username
-	home, err := os.UserHomeDir()
+	configDir, err := ghConfigDir(runtime.GOOS)
 	if err != nil {
-		return "", fmt.Errorf("could not get home directory: %w", err)
+		return "", err
 	}
-
-	hostsPath := filepath.Join(home, ".config", "gh", "hosts.yml")
+	hostsPath := filepath.Join(configDir, "hosts.yml")
 	data, err := os.ReadFile(hostsPath)
 	if err != nil {
-		return "", fmt.Errorf("no GITHUB_TOKEN and could not read gh config: %w", err)
+		return "", fmt.Errorf("no GH_TOKEN or GITHUB_TOKEN and could not read gh config: %w", err)
 	}
 
 	var hosts map[string]struct {
@@ -74,615 +76,118 @@ func getGitHubToken() (string, error) {
 	service := "gh:" + hostname
 	token, err := keyring.Get(service, hostConfig.User)
 	if err != nil {
-		return "", fmt.Errorf("could not get token from keyring (service=%q, user=%q): %w", service, hostConfig.User, err)
+		return "", fmt.Errorf("could not get token from keyring (service=%q, user=%q): %w\n"+
+			"without a keyring, save a token with 'craft login --stdin' or set GH_TOKEN", service, hostConfig.User, err)
 	}
 
 	return token, nil
 }
 
-// GraphQL response types for reuse across queries
-
-type gqlPageInfo struct {
-	HasNextPage githubv4.Boolean
-	EndCursor   githubv4.String
-}
-
-type gqlActor struct {
-	Login     githubv4.String
-	AvatarURL githubv4.URI `graphql:"avatarUrl"`
-	URL       githubv4.URI `graphql:"url"`
-}
-
-type gqlReviewComment struct {
-	ID         githubv4.ID
-	DatabaseID int64
-	Body       githubv4.String
-	CreatedAt  githubv4.DateTime
-	UpdatedAt  githubv4.DateTime
-	Author     gqlActor
-	ReplyTo    struct {
-		DatabaseID int64
-	}
-}
-
-type gqlReviewThread struct {
-	ID                githubv4.ID
-	IsResolved        githubv4.Boolean
-	IsOutdated        githubv4.Boolean
-	Path              githubv4.String
-	DiffSide          githubv4.String
-	Line              githubv4.Int
-	StartLine         *githubv4.Int
-	OriginalLine      githubv4.Int
-	OriginalStartLine *githubv4.Int
-	SubjectType       githubv4.String
-	Comments          struct {
-		PageInfo gqlPageInfo
-		Nodes    []gqlReviewComment
-	} `graphql:"comments(first: 100)"`
-}
-
-type gqlIssueComment struct {
-	ID         githubv4.ID
-	DatabaseID int64
-	Body       githubv4.String
-	CreatedAt  githubv4.DateTime
-	UpdatedAt  githubv4.DateTime
-	Author     gqlActor
-}
-
-type gqlReview struct {
-	ID          githubv4.ID
-	DatabaseID  int64
-	State       githubv4.String
-	Body        githubv4.String
-	SubmittedAt *githubv4.DateTime
-	CreatedAt   githubv4.DateTime
-	Author      gqlActor
-}
-
-// FetchPullRequest fetches all PR data including review threads, comments, and reviews.
-// Handles pagination for all collections.
-func (c *GitHubClient) FetchPullRequest(ctx context.Context, owner, repo string, number int) (*PullRequest, error) {
-	// Initial query for PR metadata and first page of everything
-	var prQuery struct {
-		Repository struct {
-			PullRequest struct {
-				ID            githubv4.ID
-				Number        githubv4.Int
-				Title         githubv4.String
-				Body          githubv4.String
-				State         githubv4.String
-				IsDraft       githubv4.Boolean
-				BaseRefName   githubv4.String
-				HeadRefName   githubv4.String
-				BaseRefOid    githubv4.GitObjectID
-				HeadRefOid    githubv4.GitObjectID
-				Author        gqlActor
-				ReviewThreads struct {
-					PageInfo gqlPageInfo
-					Nodes    []gqlReviewThread
-				} `graphql:"reviewThreads(first: 100)"`
-				Comments struct {
-					PageInfo gqlPageInfo
-					Nodes    []gqlIssueComment
-				} `graphql:"comments(first: 100)"`
-				Reviews struct {
-					PageInfo gqlPageInfo
-					Nodes    []gqlReview
-				} `graphql:"reviews(first: 100)"`
-			} `graphql:"pullRequest(number: $number)"`
-		} `graphql:"repository(owner: $owner, name: $name)"`
-	}
-
-	vars := map[string]interface{}{
-		"owner":  githubv4.String(owner),
-		"name":   githubv4.String(repo),
-		"number": githubv4.Int(number),
-	}
-
-	if err := c.client.Query(ctx, &prQuery, vars); err != nil {
-		return nil, fmt.Errorf("GraphQL query failed: %w", err)
-	}
-
-	ghPR := prQuery.Repository.PullRequest
-
-	// Collect all nodes, paginating as needed
-	allThreads := ghPR.ReviewThreads.Nodes
-	allIssueComments := ghPR.Comments.Nodes
-	allReviews := ghPR.Reviews.Nodes
-
-	// Paginate review threads
-	if ghPR.ReviewThreads.PageInfo.HasNextPage {
-		more, err := c.fetchAllReviewThreads(ctx, owner, repo, number, string(ghPR.ReviewThreads.PageInfo.EndCursor))
-		if err != nil {
-			return nil, err
-		}
-		allThreads = append(allThreads, more...)
-	}
-
-	// Paginate issue comments
-	if ghPR.Comments.PageInfo.HasNextPage {
-		more, err := c.fetchAllIssueComments(ctx, owner, repo, number, string(ghPR.Comments.PageInfo.EndCursor))
-		if err != nil {
-			return nil, err
-		}
-		allIssueComments = append(allIssueComments, more...)
-	}
-
-	// Paginate reviews
-	if ghPR.Reviews.PageInfo.HasNextPage {
-		more, err := c.fetchAllReviews(ctx, owner, repo, number, string(ghPR.Reviews.PageInfo.EndCursor))
-		if err != nil {
-			return nil, err
-		}
-		allReviews = append(allReviews, more...)
-	}
-
-	// Convert to our model
-	pr := &PullRequest{
-		ID:            string(ghPR.ID.(string)),
-		Number:        int(ghPR.Number),
-		Title:         string(ghPR.Title),
-		Body:          string(ghPR.Body),
-		State:         string(ghPR.State),
-		IsDraft:       bool(ghPR.IsDraft),
-		BaseRefName:   string(ghPR.BaseRefName),
-		HeadRefName:   string(ghPR.HeadRefName),
-		BaseRefOID:    string(ghPR.BaseRefOid),
-		HeadRefOID:    string(ghPR.HeadRefOid),
-		LastFetchedAt: time.Now(),
-		Author:        convertActor(ghPR.Author),
-	}
-
-	// Convert review threads (with nested comment pagination)
-	for _, t := range allThreads {
-		thread, err := c.convertReviewThread(ctx, t)
-		if err != nil {
-			return nil, err
-		}
-		pr.ReviewThreads = append(pr.ReviewThreads, thread)
-	}
-
-	// Convert issue comments
-	for _, c := range allIssueComments {
-		pr.IssueComments = append(pr.IssueComments, convertIssueComment(c))
-	}
-
-	// Convert reviews
-	for _, r := range allReviews {
-		pr.Reviews = append(pr.Reviews, convertReview(r))
-	}
-
-	return pr, nil
-}
-
-// fetchAllReviewThreads paginates through remaining review threads
-func (c *GitHubClient) fetchAllReviewThreads(ctx context.Context, owner, repo string, number int, cursor string) ([]gqlReviewThread, error) {
-	var result []gqlReviewThread
-
-	var query struct {
-		Repository struct {
-			PullRequest struct {
-				ReviewThreads struct {
-					PageInfo gqlPageInfo
-					Nodes    []gqlReviewThread
-				} `graphql:"reviewThreads(first: 100, after: $cursor)"`
-			} `graphql:"pullRequest(number: $number)"`
-		} `graphql:"repository(owner: $owner, name: $name)"`
-	}
-
-	for {
-		vars := map[string]interface{}{
-			"owner":  githubv4.String(owner),
-			"name":   githubv4.String(repo),
-			"number": githubv4.Int(number),
-			"cursor": githubv4.String(cursor),
-		}
-
-		if err := c.client.Query(ctx, &query, vars); err != nil {
-			return nil, fmt.Errorf("fetching review threads page: %w", err)
-		}
-
-		result = append(result, query.Repository.PullRequest.ReviewThreads.Nodes...)
-
-		if !query.Repository.PullRequest.ReviewThreads.PageInfo.HasNextPage {
-			break
-		}
-		cursor = string(query.Repository.PullRequest.ReviewThreads.PageInfo.EndCursor)
-	}
-
-	return result, nil
-}
-
-// FetchPRHead fetches just the current head OID of a PR (lightweight check).
-func (c *GitHubClient) FetchPRHead(ctx context.Context, owner, repo string, number int) (string, error) {
-	var query struct {
-		Repository struct {
-			PullRequest struct {
-				HeadRefOID githubv4.GitObjectID `graphql:"headRefOid"`
-			} `graphql:"pullRequest(number: $number)"`
-		} `graphql:"repository(owner: $owner, name: $name)"`
-	}
-
-	vars := map[string]interface{}{
-		"owner":  githubv4.String(owner),
-		"name":   githubv4.String(repo),
-		"number": githubv4.Int(number),
-	}
-
-	if err := c.client.Query(ctx, &query, vars); err != nil {
-		return "", fmt.Errorf("fetching PR head: %w", err)
-	}
-
-	return string(query.Repository.PullRequest.HeadRefOID), nil
-}
-
-// fetchAllIssueComments paginates through remaining issue comments
-func (c *GitHubClient) fetchAllIssueComments(ctx context.Context, owner, repo string, number int, cursor string) ([]gqlIssueComment, error) {
-	var result []gqlIssueComment
-
-	var query struct {
-		Repository struct {
-			PullRequest struct {
-				Comments struct {
-					PageInfo gqlPageInfo
-					Nodes    []gqlIssueComment
-				} `graphql:"comments(first: 100, after: $cursor)"`
-			} `graphql:"pullRequest(number: $number)"`
-		} `graphql:"repository(owner: $owner, name: $name)"`
-	}
-
-	for {
-		vars := map[string]interface{}{
-			"owner":  githubv4.String(owner),
-			"name":   githubv4.String(repo),
-			"number": githubv4.Int(number),
-			"cursor": githubv4.String(cursor),
-		}
-
-		if err := c.client.Query(ctx, &query, vars); err != nil {
-			return nil, fmt.Errorf("fetching issue comments page: %w", err)
-		}
-
-		result = append(result, query.Repository.PullRequest.Comments.Nodes...)
-
-		if !query.Repository.PullRequest.Comments.PageInfo.HasNextPage {
-			break
-		}
-		cursor = string(query.Repository.PullRequest.Comments.PageInfo.EndCursor)
-	}
-
-	return result, nil
-}
-
-// fetchAllReviews paginates through remaining reviews
-func (c *GitHubClient) fetchAllReviews(ctx context.Context, owner, repo string, number int, cursor string) ([]gqlReview, error) {
-	var result []gqlReview
-
-	var query struct {
-		Repository struct {
-			PullRequest struct {
-				Reviews struct {
-					PageInfo gqlPageInfo
-					Nodes    []gqlReview
-				} `graphql:"reviews(first: 100, after: $cursor)"`
-			} `graphql:"pullRequest(number: $number)"`
-		} `graphql:"repository(owner: $owner, name: $name)"`
+// ghConfigDir returns gh CLI's config directory the way gh finds it on goos:
+// $GH_CONFIG_DIR, $XDG_CONFIG_HOME/gh, %AppData%\GitHub CLI on Windows, or
+// ~/.config/gh.
+func ghConfigDir(goos string) (string, error) {
+	if dir := os.Getenv("GH_CONFIG_DIR"); dir != "" {
+		return dir, nil
 	}
-
-	for {
-		vars := map[string]interface{}{
-			"owner":  githubv4.String(owner),
-			"name":   githubv4.String(repo),
-			"number": githubv4.Int(number),
-			"cursor": githubv4.String(cursor),
-		}
-
-		if err := c.client.Query(ctx, &query, vars); err != nil {
-			return nil, fmt.Errorf("fetching reviews page: %w", err)
-		}
-
-		result = append(result, query.Repository.PullRequest.Reviews.Nodes...)
-
-		if !query.Repository.PullRequest.Reviews.PageInfo.HasNextPage {
-			break
-		}
-		cursor = string(query.Repository.PullRequest.Reviews.PageInfo.EndCursor)
-	}
-
-	return result, nil
-}
-
-// fetchMoreThreadComments fetches additional comments for a thread via node query
-func (c *GitHubClient) fetchMoreThreadComments(ctx context.Context, threadID string, cursor string) ([]gqlReviewComment, error) {
-	var result []gqlReviewComment
-
-	var query struct {
-		Node struct {
-			PullRequestReviewThread struct {
-				Comments struct {
-					PageInfo gqlPageInfo
-					Nodes    []gqlReviewComment
-				} `graphql:"comments(first: 100, after: $cursor)"`
-			} `graphql:"... on PullRequestReviewThread"`
-		} `graphql:"node(id: $id)"`
+	if dir := os.Getenv("XDG_CONFIG_HOME"); dir != "" {
+		return filepath.Join(dir, "gh"), nil
 	}
-
-	for {
-		vars := map[string]interface{}{
-			"id":     githubv4.ID(threadID),
-			"cursor": githubv4.String(cursor),
-		}
-
-		if err := c.client.Query(ctx, &query, vars); err != nil {
-			return nil, fmt.Errorf("fetching thread comments page: %w", err)
-		}
-
-		result = append(result, query.Node.PullRequestReviewThread.Comments.Nodes...)
-
-		if !query.Node.PullRequestReviewThread.Comments.PageInfo.HasNextPage {
-			break
-		}
-		cursor = string(query.Node.PullRequestReviewThread.Comments.PageInfo.EndCursor)
+	if dir := os.Getenv("AppData"); goos == "windows" && dir != "" {
+		return filepath.Join(dir, "GitHub CLI"), nil
 	}
-
-	return result, nil
-}
-
-// convertReviewThread converts a GraphQL thread to our model, fetching more comments if needed
-func (c *GitHubClient) convertReviewThread(ctx context.Context, t gqlReviewThread) (ReviewThread, error) {
-	thread := ReviewThread{
-		ID:           string(t.ID.(string)),
-		Path:         string(t.Path),
-		DiffSide:     DiffSide(t.DiffSide),
-		Line:         int(t.Line),
-		OriginalLine: int(t.OriginalLine),
-		IsOutdated:   bool(t.IsOutdated),
-		IsResolved:   bool(t.IsResolved),
-		SubjectType:  SubjectType(t.SubjectType),
-	}
-	if t.StartLine != nil {
-		sl := int(*t.StartLine)
-		thread.StartLine = &sl
-	}
-	if t.OriginalStartLine != nil {
-		osl := int(*t.OriginalStartLine)
-		thread.OriginalStartLine = &osl
-	}
-
-	// Collect all comments, paginating if needed
-	allComments := t.Comments.Nodes
-	if t.Comments.PageInfo.HasNextPage {
-		more, err := c.fetchMoreThreadComments(ctx, string(t.ID.(string)), string(t.Comments.PageInfo.EndCursor))
-		if err != nil {
-			return thread, err
-		}
-		allComments = append(allComments, more...)
-	}
-
-	for _, c := range allComments {
-		thread.Comments = append(thread.Comments, convertReviewComment(c))
-	}
-
-	return thread, nil
-}
-
-// Conversion helpers
-
-func convertActor(a gqlActor) Actor {
-	return Actor{
-		Login:     string(a.Login),
-		AvatarURL: a.AvatarURL.String(),
-		URL:       a.URL.String(),
-	}
-}
-
-func convertReviewComment(c gqlReviewComment) ReviewComment {
-	comment := ReviewComment{
-		ID:         string(c.ID.(string)),
-		DatabaseID: c.DatabaseID,
-		Body:       string(c.Body),
-		CreatedAt:  c.CreatedAt.Time,
-		UpdatedAt:  c.UpdatedAt.Time,
-		Author:     convertActor(c.Author),
-	}
-	if c.ReplyTo.DatabaseID != 0 {
-		rid := fmt.Sprintf("%d", c.ReplyTo.DatabaseID)
-		comment.ReplyToID = &rid
-	}
-	return comment
-}
-
-func convertIssueComment(c gqlIssueComment) IssueComment {
-	return IssueComment{
-		ID:         string(c.ID.(string)),
-		DatabaseID: c.DatabaseID,
-		Body:       string(c.Body),
-		CreatedAt:  c.CreatedAt.Time,
-		UpdatedAt:  c.UpdatedAt.Time,
-		Author:     convertActor(c.Author),
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("could not get home directory: %w", err)
 	}
+	return filepath.Join(home, ".config", "gh"), nil
 }
 
-func convertReview(r gqlReview) Review {
-	review := Review{
-		ID:         string(r.ID.(string)),
-		DatabaseID: r.DatabaseID,
-		State:      ReviewState(r.State),
-		Body:       string(r.Body),
-		CreatedAt:  r.CreatedAt.Time,
-		Author:     convertActor(r.Author),
-	}
-	if r.SubmittedAt != nil {
-		t := r.SubmittedAt.Time
-		review.SubmittedAt = &t
-	}
-	return review
-}
-
-// addReviewComment adds a reply comment to a pending review.
-func (c *GitHubClient) addReviewComment(ctx context.Context, reviewID githubv4.ID, replyToNodeID, body string) (string, error) {
-	var mutation struct {
-		AddPullRequestReviewComment struct {
-			Comment struct {
-				ID githubv4.ID
-			}
-		} `graphql:"addPullRequestReviewComment(input: $input)"`
-	}
-
-	bodyVal := githubv4.String(body)
-	replyToID := githubv4.ID(replyToNodeID)
-
-	input := githubv4.AddPullRequestReviewCommentInput{
-		PullRequestReviewID: &reviewID,
-		Body:                &bodyVal,
-		InReplyTo:           &replyToID,
-	}
-
-	if err := c.client.Mutate(ctx, &mutation, input, nil); err != nil {
-		return "", fmt.Errorf("addPullRequestReviewComment mutation failed: %w", err)
+// resolveRemote returns the remote name to use, from an explicit override
+// or the remoteName setting ("origin" by default).
+func resolveRemote(vcs VCS, override string) string {
+	if override != "" {
+		return override
 	}
-
-	return string(mutation.AddPullRequestReviewComment.Comment.ID.(string)), nil
+	return cmp.Or(configValue(vcs, "remoteName"), "origin")
 }
 
-// getPendingReview checks if there's an existing pending review.
-// Returns the review ID (if any), whether one exists, and any error.
-func (c *GitHubClient) getPendingReview(ctx context.Context, prNodeID string) (githubv4.ID, bool, error) {
-	var query struct {
-		Node struct {
-			PullRequest struct {
-				Reviews struct {
-					Nodes []struct {
-						ID githubv4.ID
-					}
-				} `graphql:"reviews(first: 1, states: PENDING)"`
-			} `graphql:"... on PullRequest"`
-		} `graphql:"node(id: $id)"`
-	}
-
-	vars := map[string]interface{}{
-		"id": githubv4.ID(prNodeID),
+// resolveWrapWidth returns the comment wrap width to use, from an explicit
+// override or the wrapWidth setting, or 0 (meaning craft.DefaultWrap).
+func resolveWrapWidth(vcs VCS, override int) (int, error) {
+	if override > 0 {
+		return override, nil
 	}
-
-	if err := c.client.Query(ctx, &query, vars); err != nil {
-		return nil, false, fmt.Errorf("checking for pending review: %w", err)
+	value := configValue(vcs, "wrapWidth")
+	if value == "" {
+		return 0, nil
 	}
-
-	if len(query.Node.PullRequest.Reviews.Nodes) > 0 {
-		return query.Node.PullRequest.Reviews.Nodes[0].ID, true, nil
+	width, err := strconv.Atoi(value)
+	if err != nil || width <= 0 {
+		return 0, fmt.Errorf("invalid wrapWidth setting: %q", value)
 	}
-
-	return nil, false, nil
+	return width, nil
 }
 
-// deletePendingReview deletes a pending review.
-func (c *GitHubClient) deletePendingReview(ctx context.Context, reviewID githubv4.ID) error {
-	var mutation struct {
-		DeletePullRequestReview struct {
-			PullRequestReview struct {
-				ID githubv4.ID
-			}
-		} `graphql:"deletePullRequestReview(input: $input)"`
+// resolveCommentsAbove reports whether threads go above their line, from the
+// commentPosition setting: "above", or "below" (the default).
+func resolveCommentsAbove(vcs VCS) (bool, error) {
+	value := configValue(vcs, "commentPosition")
+	switch value {
+	case "", "below":
+		return false, nil
+	case "above":
+		return true, nil
 	}
-
-	input := githubv4.DeletePullRequestReviewInput{
-		PullRequestReviewID: reviewID,
-	}
-
-	return c.client.Mutate(ctx, &mutation, input, nil)
+	return false, fmt.Errorf("invalid commentPosition setting: %q (want above or below)", value)
 }
 
-// startReviewWithThreads creates a new pending review with threads and returns its ID.
-// This works around a GitHub bug where adding threads to an existing review fails silently.
-func (c *GitHubClient) startReviewWithThreads(ctx context.Context, prNodeID, commitOID string, threads []NewThreadInfo) (githubv4.ID, error) {
-	var mutation struct {
-		AddPullRequestReview struct {
-			PullRequestReview struct {
-				ID githubv4.ID
-			}
-		} `graphql:"addPullRequestReview(input: $input)"`
+// resolveAutoCommit reports whether craft commits its changes: the
+// autoCommit setting, unless noCommit (a --no-commit flag) is set.
+func resolveAutoCommit(vcs VCS, noCommit bool) (bool, error) {
+	if noCommit {
+		return false, nil
 	}
-
-	prID := githubv4.ID(prNodeID)
-	commit := githubv4.GitObjectID(commitOID)
-
-	input := githubv4.AddPullRequestReviewInput{
-		PullRequestID: &prID,
-		CommitOID:     &commit,
-	}
-
-	// Add threads if provided
-	if len(threads) > 0 {
-		draftThreads := make([]*githubv4.DraftPullRequestReviewThread, len(threads))
-		for i, t := range threads {
-			line := githubv4.Int(t.Line)
-			side := githubv4.DiffSide(t.Side)
-			dt := &githubv4.DraftPullRequestReviewThread{
-				Path: githubv4.String(t.Path),
-				Line: line,
-				Body: githubv4.String(t.Body),
-				Side: &side,
-			}
-			if t.StartLine != nil {
-				startLine := githubv4.Int(*t.StartLine)
-				dt.StartLine = &startLine
-			}
-			draftThreads[i] = dt
-		}
-		input.Threads = &draftThreads
-	}
-
-	if err := c.client.Mutate(ctx, &mutation, input, nil); err != nil {
-		return nil, err
+	value := configValue(vcs, "autoCommit")
+	commit, ok := parseConfigBool(value)
+	if !ok {
+		return false, fmt.Errorf("invalid autoCommit setting: %q (want true or false)", value)
 	}
-
-	return mutation.AddPullRequestReview.PullRequestReview.ID, nil
+	return commit, nil
 }
 
-// submitReview submits a pending review with the given event type (COMMENT, APPROVE, REQUEST_CHANGES).
-// The body is optional and becomes the top-level review comment.
-func (c *GitHubClient) submitReview(ctx context.Context, reviewID githubv4.ID, eventType, body string) error {
-	var mutation struct {
-		SubmitPullRequestReview struct {
-			PullRequestReview struct {
-				ID githubv4.ID
-			}
-		} `graphql:"submitPullRequestReview(input: $input)"`
+// resolveCommitOptions returns how craft makes its commits: signed as the
+// signCommits setting says if it's set, or as the VCS is configured to, and
+// not signed if noSign (a --no-sign flag) is set; and without the commit
+// hooks if the commitHooks setting is false.
+func resolveCommitOptions(vcs VCS, noSign bool) (CommitOptions, error) {
+	var opts CommitOptions
+	value := configValue(vcs, "commitHooks")
+	hooks, ok := parseConfigBool(value)
+	if !ok {
+		return opts, fmt.Errorf("invalid commitHooks setting: %q (want true or false)", value)
 	}
+	opts.NoVerify = !hooks
 
-	event := githubv4.PullRequestReviewEvent(eventType)
-
-	input := githubv4.SubmitPullRequestReviewInput{
-		PullRequestReviewID: &reviewID,
-		Event:               event,
+	if noSign {
+		opts.NoSign = true
+		return opts, nil
 	}
-
-	if body != "" {
-		bodyVal := githubv4.String(body)
-		input.Body = &bodyVal
+	value = configValue(vcs, "signCommits")
+	if value == "" {
+		return opts, nil
 	}
-
-	return c.client.Mutate(ctx, &mutation, input, nil)
-}
-
-// resolveRemote returns the remote name to use, from an explicit override,
-// the craft.remoteName config, or "origin" as default.
-func resolveRemote(vcs VCS, override string) string {
-	if override != "" {
-		return override
-	}
-	remote, _ := vcs.GetConfigValue("craft.remoteName")
-	if remote == "" {
-		return "origin"
+	sign, ok := parseConfigBool(value)
+	if !ok {
+		return opts, fmt.Errorf("invalid signCommits setting: %q (want true or false)", value)
 	}
-	return remote
+	opts.Sign, opts.NoSign = sign, !sign
+	return opts, nil
 }
 
-// getGitHubClientAndRepo creates a GitHubClient and resolves the owner/repo
+// getGitHubClientAndRepo creates a GitHubAPI and resolves the owner/repo
 // from the given remote.
-func getGitHubClientAndRepo(vcs VCS, remote string) (*GitHubClient, string, string, error) {
+func getGitHubClientAndRepo(vcs VCS, remote string) (GitHubAPI, string, string, error) {
 	token, err := getGitHubToken()
 	if err != nil {
 		return nil, "", "", fmt.Errorf("getting GitHub token: %w", err)
@@ -695,7 +200,7 @@ func getGitHubClientAndRepo(vcs VCS, remote string) (*GitHubClient, string, stri
 	if err != nil {
 		return nil, "", "", err
 	}
-	return NewGitHubClient(token), owner, repo, nil
+	return newGitHubAPI(token), owner, repo, nil
 }
 
 // ParseGitHubRemote extracts owner and repo from a GitHub remote URL.
@@ -724,3 +229,24 @@ func ParseGitHubRemote(url string) (owner, repo string, err error) {
 
 	return "", "", fmt.Errorf("not a GitHub URL: %s", url)
 }
+
+// ParseGitHubPRURL extracts owner, repo and PR number from the URL of a PR
+// or a page of it, like https://github.com/owner/repo/pull/123/files.
+func ParseGitHubPRURL(url string) (owner, repo string, number int, err error) {
+	path := strings.TrimPrefix(strings.TrimPrefix(url, "https://"), "http://")
+	path, ok := strings.CutPrefix(path, "github.com/")
+	if !ok {
+		return "", "", 0, fmt.Errorf("not a GitHub PR URL: %s", url)
+	}
+	path, _, _ = strings.Cut(path, "#")
+	path, _, _ = strings.Cut(path, "?")
+	parts := strings.Split(path, "/")
+	if len(parts) < 4 || parts[0] == "" || parts[1] == "" || parts[2] != "pull" {
+		return "", "", 0, fmt.Errorf("not a GitHub PR URL: %s", url)
+	}
+	number, err = strconv.Atoi(parts[3])
+	if err != nil || number <= 0 {
+		return "", "", 0, fmt.Errorf("invalid PR number in URL: %s", url)
+	}
+	return parts[0], parts[1], number, nil
+}
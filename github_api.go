@@ -0,0 +1,32 @@
+package main
+
+import (
+	"context"
+
+	"github.com/dnr/craft/pkg/craft"
+	"golang.org/x/oauth2"
+)
+
+// GitHubAPI and the types it takes live in pkg/craft, so backends can be
+// written outside craft.
+type (
+	GitHubAPI     = craft.GitHubAPI
+	OpenPR        = craft.OpenPR
+	PRDescription = craft.PRDescription
+	NewThreadInfo = craft.NewThreadInfo
+	ReplyInfo     = craft.ReplyInfo
+)
+
+// newGitHubAPI makes the GitHubAPI commands use, given a token.
+var newGitHubAPI = func(token string) GitHubAPI {
+	return newGitHubClient(token)
+}
+
+// newGitHubClient creates a craft.GitHubClient with the given token, whose
+// requests are logged, and recorded or replayed with --record and --replay.
+func newGitHubClient(token string) *craft.GitHubClient {
+	src := oauth2.StaticTokenSource(&oauth2.Token{AccessToken: token})
+	httpClient := oauth2.NewClient(context.Background(), src)
+	httpClient.Transport = loggingTransport{base: recordReplayTransport(httpClient.Transport)}
+	return craft.NewGitHubClientWithHTTP(httpClient, "")
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// --record and --replay save GitHub API traffic to a directory and play it
+// back, so get and send can be tested without GitHub, and a bug report can
+// come with what GitHub said. Only methods, paths, bodies and statuses are
+// saved: no headers, so no token.
+
+var (
+	flagRecord string
+	flagReplay string
+)
+
+func init() {
+	rootCmd.PersistentFlags().StringVar(&flagRecord, "record", "", "Save GitHub API requests and responses in this directory")
+	rootCmd.PersistentFlags().StringVar(&flagReplay, "replay", "", "Answer GitHub API requests from a directory saved with --record, without GitHub")
+	rootCmd.MarkFlagsMutuallyExclusive("record", "replay")
+}
+
+// recordReplayTransport wraps base to record or replay, as the flags say.
+func recordReplayTransport(base http.RoundTripper) http.RoundTripper {
+	switch {
+	case flagReplay != "":
+		return &replayTransport{dir: flagReplay}
+	case flagRecord != "":
+		return &recordTransport{base: base, dir: flagRecord}
+	}
+	return base
+}
+
+// exchange is a request and its response, as saved in a file of its own.
+type exchange struct {
+	Method   string          `json:"method"`
+	Path     string          `json:"path"`
+	Request  json.RawMessage `json:"request,omitempty"`
+	Status   int             `json:"status"`
+	Response json.RawMessage `json:"response"`
+}
+
+// key is what a request is matched on when replaying: the host is left out,
+// so a recording from github.com replays against any URL.
+func (e exchange) key() string {
+	return e.Method + " " + e.Path + " " + string(e.Request)
+}
+
+// jsonBody returns data as JSON, compacted, or as a JSON string if it isn't
+// JSON.
+func jsonBody(data []byte) json.RawMessage {
+	if len(bytes.TrimSpace(data)) == 0 {
+		return nil
+	}
+	var buf bytes.Buffer
+	if json.Compact(&buf, data) == nil {
+		return buf.Bytes()
+	}
+	s, _ := json.Marshal(string(data))
+	return s
+}
+
+// readRequestBody returns req's body, leaving it for the request to send.
+func readRequestBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil {
+		return nil, nil
+	}
+	data, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	req.Body = io.NopCloser(bytes.NewReader(data))
+	return data, nil
+}
+
+// recordTransport saves each exchange with base in dir, as NNNN.json
+// numbered after any files already there.
+type recordTransport struct {
+	base http.RoundTripper
+	dir  string
+
+	mu   sync.Mutex
+	next int // 0 until the first request
+}
+
+func (t *recordTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := readRequestBody(req)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	data, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(data))
+
+	e := exchange{
+		Method:   req.Method,
+		Path:     req.URL.RequestURI(),
+		Request:  jsonBody(body),
+		Status:   resp.StatusCode,
+		Response: jsonBody(data),
+	}
+	if err := t.save(e); err != nil {
+		return nil, fmt.Errorf("recording: %w", err)
+	}
+	return resp, nil
+}
+
+func (t *recordTransport) save(e exchange) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.next == 0 {
+		if err := os.MkdirAll(t.dir, 0755); err != nil {
+			return err
+		}
+		names, err := exchangeFiles(t.dir)
+		if err != nil {
+			return err
+		}
+		t.next = len(names) + 1
+	}
+	data, err := json.MarshalIndent(e, "", "  ")
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(t.dir, fmt.Sprintf("%04d.json", t.next))
+	t.next++
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
+
+// exchangeFiles returns the names of the saved exchanges in dir, in order.
+func exchangeFiles(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasSuffix(entry.Name(), ".json") {
+			names = append(names, entry.Name())
+		}
+	}
+	slices.Sort(names)
+	return names, nil
+}
+
+// replayTransport answers each request with the first exchange in dir with
+// the same method, path and body that hasn't been used yet, so a query made
+// twice gets the two responses recorded, in order.
+type replayTransport struct {
+	dir string
+
+	once      sync.Once
+	err       error
+	mu        sync.Mutex
+	exchanges []exchange
+	used      []bool
+}
+
+func (t *replayTransport) load() {
+	names, err := exchangeFiles(t.dir)
+	if err != nil {
+		t.err = err
+		return
+	}
+	for _, name := range names {
+		data, err := os.ReadFile(filepath.Join(t.dir, name))
+		if err != nil {
+			t.err = err
+			return
+		}
+		var e exchange
+		if err := json.Unmarshal(data, &e); err != nil {
+			t.err = fmt.Errorf("%s: %w", name, err)
+			return
+		}
+		e.Request = jsonBody(e.Request)
+		t.exchanges = append(t.exchanges, e)
+	}
+	t.used = make([]bool, len(t.exchanges))
+}
+
+func (t *replayTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.once.Do(t.load)
+	if t.err != nil {
+		return nil, fmt.Errorf("replaying: %w", t.err)
+	}
+	body, err := readRequestBody(req)
+	if err != nil {
+		return nil, err
+	}
+	want := exchange{Method: req.Method, Path: req.URL.RequestURI(), Request: jsonBody(body)}.key()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for i, e := range t.exchanges {
+		if t.used[i] || e.key() != want {
+			continue
+		}
+		t.used[i] = true
+		data := []byte(e.Response)
+		var s string
+		if json.Unmarshal(data, &s) == nil {
+			data = []byte(s) // saved as a string since it wasn't JSON
+		}
+		return &http.Response{
+			Status:     fmt.Sprintf("%d %s", e.Status, http.StatusText(e.Status)),
+			StatusCode: e.Status,
+			Proto:      "HTTP/1.1",
+			ProtoMajor: 1,
+			ProtoMinor: 1,
+			Header:     http.Header{"Content-Type": {"application/json"}},
+			Body:       io.NopCloser(bytes.NewReader(data)),
+			Request:    req,
+		}, nil
+	}
+	return nil, fmt.Errorf("replaying: no recorded response left in %s for %s %s", t.dir, req.Method, req.URL.RequestURI())
+}
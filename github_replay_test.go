@@ -0,0 +1,61 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dnr/craft/pkg/craft"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecordReplay(t *testing.T) {
+	dir := t.TempDir()
+	logins := []string{"alice", "bob"}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		io.WriteString(w, `{"data":{"viewer":{"login":"`+logins[0]+`"}}}`)
+		logins = logins[1:]
+	}))
+	clientFor := func(rt http.RoundTripper) *craft.GitHubClient {
+		return craft.NewGitHubClientWithHTTP(&http.Client{Transport: rt}, srv.URL)
+	}
+
+	// Record two answers to the same query
+	withToken := roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		req.Header.Set("Authorization", "bearer secret-token")
+		return http.DefaultTransport.RoundTrip(req)
+	})
+	client := clientFor(&recordTransport{base: withToken, dir: dir})
+	for _, want := range []string{"alice", "bob"} {
+		login, err := client.FetchViewerLogin(t.Context())
+		require.NoError(t, err)
+		assert.Equal(t, want, login)
+	}
+	srv.Close()
+
+	names, err := exchangeFiles(dir)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"0001.json", "0002.json"}, names)
+	data, err := os.ReadFile(filepath.Join(dir, names[0]))
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"path": "/graphql"`)
+	assert.NotContains(t, string(data), "secret-token")
+
+	// Replay them in order, with GitHub gone
+	client = clientFor(&replayTransport{dir: dir})
+	for _, want := range []string{"alice", "bob"} {
+		login, err := client.FetchViewerLogin(t.Context())
+		require.NoError(t, err)
+		assert.Equal(t, want, login)
+	}
+	_, err = client.FetchViewerLogin(t.Context())
+	assert.ErrorContains(t, err, "no recorded response left")
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) { return f(req) }
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGHConfigDir(t *testing.T) {
+	home, err := os.UserHomeDir()
+	require.NoError(t, err)
+
+	tests := []struct {
+		name string
+		goos string
+		env  map[string]string
+		want string
+	}{
+		{"default", "linux", nil, filepath.Join(home, ".config", "gh")},
+		{"GH_CONFIG_DIR", "linux", map[string]string{"GH_CONFIG_DIR": "/gh", "XDG_CONFIG_HOME": "/xdg"}, "/gh"},
+		{"XDG_CONFIG_HOME", "linux", map[string]string{"XDG_CONFIG_HOME": "/xdg", "AppData": "/appdata"}, filepath.Join("/xdg", "gh")},
+		{"AppData on windows", "windows", map[string]string{"AppData": "/appdata"}, filepath.Join("/appdata", "GitHub CLI")},
+		{"AppData elsewhere", "darwin", map[string]string{"AppData": "/appdata"}, filepath.Join(home, ".config", "gh")},
+		{"windows without AppData", "windows", nil, filepath.Join(home, ".config", "gh")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, name := range []string{"GH_CONFIG_DIR", "XDG_CONFIG_HOME", "AppData"} {
+				t.Setenv(name, tt.env[name])
+			}
+			got, err := ghConfigDir(tt.goos)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
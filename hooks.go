@@ -0,0 +1,58 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/dnr/craft/pkg/craft"
+)
+
+// hooksDir holds executables run at points in get and send, like git hooks:
+//
+//	post-get  after the files are serialized, before they're committed
+//	pre-send  before anything is sent; a non-zero exit stops the send
+//
+// Each gets the PR as JSON on stdin, runs in the repo root, and has
+// CRAFT_PR set to the PR number.
+const hooksDir = ".craft/hooks"
+
+// runHook runs the named hook if it exists. A hook the PR added or changed
+// isn't run, so that reviewing a PR can't run code from it: that's an error,
+// so pre-send fails closed.
+func runHook(vcs VCS, name string, pr *PullRequest) error {
+	path := hooksDir + "/" + name
+	content, err := craft.ReadFile(craft.DirFS(vcs.Root()), path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("reading %s hook: %w", name, err)
+	}
+	// GetFileAtCommit trims its output
+	base, err := vcs.GetFileAtCommit(pr.BaseRefOID, path)
+	if pr.BaseRefOID == "" || err != nil || base != strings.TrimSpace(string(content)) {
+		return fmt.Errorf("not running %s: it isn't the same as on the base branch", path)
+	}
+
+	data, err := json.Marshal(pr)
+	if err != nil {
+		return err
+	}
+	cmd := exec.Command(filepath.Join(vcs.Root(), filepath.FromSlash(path)))
+	cmd.Dir = vcs.Root()
+	cmd.Env = append(os.Environ(), "CRAFT_PR="+strconv.Itoa(pr.Number))
+	cmd.Stdin = bytes.NewReader(data)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s hook: %w", name, err)
+	}
+	return nil
+}
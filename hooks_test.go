@@ -0,0 +1,33 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunHook(t *testing.T) {
+	repo := newTestGitRepo(t, map[string]string{
+		".craft/hooks/pre-send": "#!/bin/sh\ngrep -q '\"number\":7' && [ \"$CRAFT_PR\" = 7 ] && touch ran\n",
+		".craft/hooks/post-get": "#!/bin/sh\nexit 1\n",
+	})
+	for _, name := range []string{"pre-send", "post-get"} {
+		require.NoError(t, os.Chmod(filepath.Join(repo.root, hooksDir, name), 0755))
+	}
+	base, err := repo.run("rev-parse", "HEAD")
+	require.NoError(t, err)
+	pr := &PullRequest{Number: 7, BaseRefOID: base}
+
+	require.NoError(t, runHook(repo, "pre-send", pr))
+	assert.FileExists(t, filepath.Join(repo.root, "ran"))
+	assert.ErrorContains(t, runHook(repo, "post-get", pr), "post-get hook: exit status 1")
+	assert.NoError(t, runHook(repo, "missing", pr))
+
+	// A hook the PR changed isn't run
+	require.NoError(t, os.WriteFile(filepath.Join(repo.root, hooksDir, "post-get"), []byte("#!/bin/sh\ntouch evil\n"), 0755))
+	assert.ErrorContains(t, runHook(repo, "post-get", pr), "isn't the same as on the base branch")
+	assert.NoFileExists(t, filepath.Join(repo.root, "evil"))
+}
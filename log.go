@@ -0,0 +1,219 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dnr/craft/pkg/craft"
+)
+
+// Progress messages go through logger, so --quiet, -v/-vv and --log-json
+// apply to them. What a command is for (craft threads, send --dry-run)
+// is printed as before.
+
+// levelTrace is for -vv: GraphQL requests and other details.
+const levelTrace = slog.LevelDebug - 4
+
+var logger = slog.New(newTextLogHandler(os.Stdout, os.Stderr, slog.LevelInfo))
+
+var (
+	flagQuiet   bool
+	flagVerbose int
+	flagLogJSON bool
+)
+
+func init() {
+	rootCmd.PersistentFlags().BoolVarP(&flagQuiet, "quiet", "q", false, "Only print warnings and errors")
+	rootCmd.PersistentFlags().CountVarP(&flagVerbose, "verbose", "v", "Print more details (-vv for GraphQL requests)")
+	rootCmd.PersistentFlags().BoolVar(&flagLogJSON, "log-json", false, "Log progress as JSON lines on stderr")
+	rootCmd.MarkFlagsMutuallyExclusive("quiet", "verbose")
+}
+
+// setupLogging configures logger from the flags, and has pkg/craft log
+// through it.
+func setupLogging() {
+	level := slog.LevelInfo
+	switch {
+	case flagQuiet:
+		level = slog.LevelWarn
+	case flagVerbose == 1:
+		level = slog.LevelDebug
+	case flagVerbose > 1:
+		level = levelTrace
+	}
+	if flagLogJSON {
+		logger = slog.New(slog.NewJSONHandler(os.Stderr, &slog.HandlerOptions{Level: level}))
+	} else {
+		h := newTextLogHandler(os.Stdout, os.Stderr, level)
+		h.outColor, h.errColor = useColor(os.Stdout), useColor(os.Stderr)
+		logger = slog.New(h)
+	}
+	craft.Logger = logger
+}
+
+func logInfo(format string, args ...any) {
+	logger.Info(fmt.Sprintf(format, args...))
+}
+
+func logWarn(format string, args ...any) {
+	logger.Warn(fmt.Sprintf(format, args...))
+}
+
+func logDebug(format string, args ...any) {
+	logger.Debug(fmt.Sprintf(format, args...))
+}
+
+func logTrace(format string, args ...any) {
+	logger.Log(context.Background(), levelTrace, fmt.Sprintf(format, args...))
+}
+
+// A logStep is a step of a command that takes a while, shown as
+// "Fetching PR data... done".
+type logStep struct {
+	msg   string
+	start time.Time
+}
+
+// step is the step logStart started, for logEnd to finish.
+var step logStep
+
+// logStart logs the start of a step, which logEnd finishes.
+func logStart(format string, args ...any) {
+	step = logStep{msg: fmt.Sprintf(format, args...), start: time.Now()}
+	logger.Info(step.msg, "step", "start")
+}
+
+// logEnd logs how the current step ended, usually "done" or "ok".
+func logEnd(result string) {
+	logger.Info(step.msg, "step", "end", "result", result, "duration", time.Since(step.start))
+}
+
+// textLogHandler writes log records for people: steps as one line when
+// nothing comes between their start and end, info lines to stdout, and the
+// rest to stderr.
+type textLogHandler struct {
+	mu                 sync.Mutex
+	out, err           io.Writer
+	outColor, errColor bool
+	level              slog.Level
+	open               string // msg of a step started on the current line of out
+}
+
+func newTextLogHandler(out, err io.Writer, level slog.Level) *textLogHandler {
+	return &textLogHandler{out: out, err: err, level: level}
+}
+
+func (h *textLogHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level
+}
+
+func (h *textLogHandler) Handle(_ context.Context, r slog.Record) error {
+	var step, result string
+	var duration time.Duration
+	r.Attrs(func(a slog.Attr) bool {
+		switch a.Key {
+		case "step":
+			step = a.Value.String()
+		case "result":
+			result = a.Value.String()
+		case "duration":
+			duration = a.Value.Duration()
+		}
+		return true
+	})
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	switch {
+	case step == "start":
+		h.breakLine()
+		h.open = r.Message
+		_, err := fmt.Fprintf(h.out, "%s... ", r.Message)
+		return err
+	case step == "end":
+		if h.open != r.Message {
+			h.breakLine()
+			fmt.Fprintf(h.out, "%s... ", r.Message)
+		}
+		h.open = ""
+		// Results like "failed" and "changed!" are problems
+		color := ansiGreen
+		if strings.HasPrefix(result, "fail") || strings.HasSuffix(result, "!") {
+			color = ansiRed
+		}
+		result = paint(h.outColor, color, result)
+		if h.level <= slog.LevelDebug {
+			result += fmt.Sprintf(" (%s)", duration.Round(time.Millisecond))
+		}
+		_, err := fmt.Fprintln(h.out, result)
+		return err
+	}
+
+	h.breakLine()
+	switch {
+	case r.Level >= slog.LevelWarn:
+		_, err := fmt.Fprintf(h.err, "%s %s\n", paint(h.errColor, ansiYellow, "warning:"), r.Message)
+		return err
+	case r.Level >= slog.LevelInfo:
+		_, err := fmt.Fprintln(h.out, r.Message)
+		return err
+	}
+	_, err := fmt.Fprintf(h.err, "%s %s\n", paint(h.errColor, ansiDim, "debug:"), r.Message)
+	return err
+}
+
+// breakLine ends the line of an open step, for other output to go between
+// its start and end.
+func (h *textLogHandler) breakLine() {
+	if h.open != "" {
+		fmt.Fprintln(h.out)
+		h.open = ""
+	}
+}
+
+func (h *textLogHandler) WithAttrs([]slog.Attr) slog.Handler { return h }
+func (h *textLogHandler) WithGroup(string) slog.Handler      { return h }
+
+// loggingTransport logs GitHub API requests: each at -v, with its body and
+// response at -vv.
+type loggingTransport struct {
+	base http.RoundTripper
+}
+
+func (t loggingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	if !logger.Enabled(ctx, slog.LevelDebug) {
+		return t.base.RoundTrip(req)
+	}
+	if logger.Enabled(ctx, levelTrace) && req.Body != nil && req.GetBody != nil {
+		if body, err := req.GetBody(); err == nil {
+			data, _ := io.ReadAll(body)
+			logTrace("%s %s request: %s", req.Method, req.URL, bytes.TrimSpace(data))
+		}
+	}
+	start := time.Now()
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		logDebug("%s %s failed after %s: %v", req.Method, req.URL, time.Since(start).Round(time.Millisecond), err)
+		return nil, err
+	}
+	logDebug("%s %s: %s (%s)", req.Method, req.URL, resp.Status, time.Since(start).Round(time.Millisecond))
+	if logger.Enabled(ctx, levelTrace) {
+		data, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		logTrace("%s %s response: %s", req.Method, req.URL, bytes.TrimSpace(data))
+		resp.Body = io.NopCloser(bytes.NewReader(data))
+	}
+	return resp, nil
+}
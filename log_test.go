@@ -0,0 +1,76 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/dnr/craft/pkg/craft"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// captureLogs sends log output to buffers for the rest of the test.
+func captureLogs(t *testing.T, h func(out, err *bytes.Buffer) slog.Handler) (out, err *bytes.Buffer) {
+	out, err = new(bytes.Buffer), new(bytes.Buffer)
+	old, oldCraft := logger, craft.Logger
+	logger = slog.New(h(out, err))
+	craft.Logger = logger
+	t.Cleanup(func() { logger, craft.Logger = old, oldCraft })
+	return out, err
+}
+
+func TestTextLog(t *testing.T) {
+	out, errOut := captureLogs(t, func(out, err *bytes.Buffer) slog.Handler {
+		return newTextLogHandler(out, err, slog.LevelInfo)
+	})
+	logInfo("PR number: %d", 12)
+	logStart("Fetching PR data")
+	logEnd("done")
+	logStart("Serializing")
+	logWarn("file %s is gone", "a.go")
+	logEnd("done")
+	logDebug("not shown")
+	assert.Equal(t, "PR number: 12\nFetching PR data... done\nSerializing... \nSerializing... done\n", out.String())
+	assert.Equal(t, "warning: file a.go is gone\n", errOut.String())
+}
+
+func TestTextLogLevels(t *testing.T) {
+	out, errOut := captureLogs(t, func(out, err *bytes.Buffer) slog.Handler {
+		return newTextLogHandler(out, err, slog.LevelWarn)
+	})
+	logStart("Fetching")
+	logEnd("done")
+	logInfo("Ready")
+	logWarn("careful")
+	assert.Empty(t, out.String())
+	assert.Equal(t, "warning: careful\n", errOut.String())
+
+	out, errOut = captureLogs(t, func(out, err *bytes.Buffer) slog.Handler {
+		return newTextLogHandler(out, err, levelTrace)
+	})
+	logStart("Fetching")
+	logEnd("done")
+	logTrace("query %d", 1)
+	assert.Regexp(t, `^Fetching\.\.\. done \(\d+m?s\)\n$`, out.String())
+	assert.Equal(t, "debug: query 1\n", errOut.String())
+}
+
+func TestJSONLog(t *testing.T) {
+	_, errOut := captureLogs(t, func(out, err *bytes.Buffer) slog.Handler {
+		return slog.NewJSONHandler(err, nil)
+	})
+	logStart("Fetching PR data")
+	logEnd("done")
+
+	lines := strings.Split(strings.TrimSpace(errOut.String()), "\n")
+	require.Len(t, lines, 2)
+	var end map[string]any
+	require.NoError(t, json.Unmarshal([]byte(lines[1]), &end))
+	assert.Equal(t, "Fetching PR data", end["msg"])
+	assert.Equal(t, "end", end["step"])
+	assert.Equal(t, "done", end["result"])
+	assert.Contains(t, end, "duration")
+}
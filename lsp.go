@@ -0,0 +1,469 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/dnr/craft/pkg/craft"
+)
+
+// lspServer serves craft threads over the Language Server Protocol: open
+// documents get a diagnostic for each thread, and code actions to reply to a
+// thread or apply its suggestion. It only reads and edits documents through
+// the client; files on disk aren't touched.
+type lspServer struct {
+	in   *bufio.Reader
+	out  io.Writer
+	docs map[string]string // URI -> current text of open documents
+}
+
+func newLSPServer(in io.Reader, out io.Writer) *lspServer {
+	return &lspServer{in: bufio.NewReader(in), out: out, docs: make(map[string]string)}
+}
+
+// lspMessage is a JSON-RPC request, response or notification.
+type lspMessage struct {
+	JSONRPC string           `json:"jsonrpc"`
+	ID      *json.RawMessage `json:"id,omitempty"`
+	Method  string           `json:"method,omitempty"`
+	Params  json.RawMessage  `json:"params,omitempty"`
+	Result  any              `json:"result,omitempty"`
+	Error   *lspError        `json:"error,omitempty"`
+}
+
+type lspError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type lspPosition struct {
+	Line      int `json:"line"`
+	Character int `json:"character"` // in UTF-16 code units
+}
+
+type lspRange struct {
+	Start lspPosition `json:"start"`
+	End   lspPosition `json:"end"`
+}
+
+type lspDiagnostic struct {
+	Range    lspRange `json:"range"`
+	Severity int      `json:"severity"`
+	Source   string   `json:"source"`
+	Message  string   `json:"message"`
+}
+
+type lspTextEdit struct {
+	Range   lspRange `json:"range"`
+	NewText string   `json:"newText"`
+}
+
+type lspCodeAction struct {
+	Title string `json:"title"`
+	Kind  string `json:"kind"`
+	Edit  struct {
+		Changes map[string][]lspTextEdit `json:"changes"`
+	} `json:"edit"`
+}
+
+// Diagnostic severities
+const (
+	lspSeverityInformation = 3
+	lspSeverityHint        = 4
+	lspSeverityError       = 1
+)
+
+// Serve handles messages until the client sends exit or closes the input.
+func (s *lspServer) Serve() error {
+	for {
+		msg, err := s.read()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if msg.Method == "exit" {
+			return nil
+		}
+		result, rpcErr := s.handle(msg)
+		if msg.ID == nil {
+			continue // notification
+		}
+		if err := s.write(lspMessage{JSONRPC: "2.0", ID: msg.ID, Result: result, Error: rpcErr}); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *lspServer) handle(msg *lspMessage) (any, *lspError) {
+	var params struct {
+		TextDocument struct {
+			URI  string `json:"uri"`
+			Text string `json:"text"`
+		} `json:"textDocument"`
+		ContentChanges []struct {
+			Text string `json:"text"`
+		} `json:"contentChanges"`
+		Range   lspRange `json:"range"`
+		Changes []struct {
+			URI string `json:"uri"`
+		} `json:"changes"`
+	}
+	if len(msg.Params) > 0 {
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return nil, &lspError{Code: -32602, Message: err.Error()}
+		}
+	}
+	uri := params.TextDocument.URI
+
+	switch msg.Method {
+	case "initialize":
+		return map[string]any{
+			"capabilities": map[string]any{
+				"textDocumentSync":   1, // full
+				"codeActionProvider": true,
+			},
+			"serverInfo": map[string]any{"name": "craft"},
+		}, nil
+	case "shutdown":
+		return nil, nil
+	case "textDocument/didOpen":
+		s.docs[uri] = params.TextDocument.Text
+		s.publishDiagnostics(uri)
+	case "textDocument/didChange":
+		// Full sync: the last change is the whole document
+		if n := len(params.ContentChanges); n > 0 {
+			s.docs[uri] = params.ContentChanges[n-1].Text
+		}
+		s.publishDiagnostics(uri)
+	case "textDocument/didClose":
+		delete(s.docs, uri)
+		s.publishDiagnostics(uri)
+	case "workspace/didChangeWatchedFiles":
+		// craft get and send rewrite PR-STATE.txt along with the files, so
+		// refresh everything when it changes
+		for _, change := range params.Changes {
+			if path.Base(lspURIPath(change.URI)) == craft.PRStateFile {
+				for uri := range s.docs {
+					s.publishDiagnostics(uri)
+				}
+				break
+			}
+		}
+	case "textDocument/codeAction":
+		text, ok := s.docs[uri]
+		if !ok {
+			return []lspCodeAction{}, nil
+		}
+		return lspCodeActions(uri, text, params.Range), nil
+	default:
+		if msg.ID != nil {
+			return nil, &lspError{Code: -32601, Message: "method not found: " + msg.Method}
+		}
+	}
+	return nil, nil
+}
+
+func (s *lspServer) publishDiagnostics(uri string) {
+	diags := []lspDiagnostic{}
+	if text, ok := s.docs[uri]; ok {
+		diags = lspDiagnostics(lspURIPath(uri), text)
+	}
+	s.write(lspMessage{
+		JSONRPC: "2.0",
+		Method:  "textDocument/publishDiagnostics",
+		Params:  mustMarshal(map[string]any{"uri": uri, "diagnostics": diags}),
+	})
+}
+
+// read reads one message, framed by a Content-Length header.
+func (s *lspServer) read() (*lspMessage, error) {
+	length := -1
+	for {
+		line, err := s.in.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if line == "" {
+			break
+		}
+		if value, ok := strings.CutPrefix(line, "Content-Length: "); ok {
+			length, err = strconv.Atoi(value)
+			if err != nil {
+				return nil, fmt.Errorf("bad Content-Length: %q", value)
+			}
+		}
+	}
+	if length < 0 {
+		return nil, fmt.Errorf("message without Content-Length")
+	}
+	data := make([]byte, length)
+	if _, err := io.ReadFull(s.in, data); err != nil {
+		return nil, err
+	}
+	var msg lspMessage
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return nil, fmt.Errorf("parsing message: %w", err)
+	}
+	return &msg, nil
+}
+
+func (s *lspServer) write(msg lspMessage) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(s.out, "Content-Length: %d\r\n\r\n%s", len(data), data)
+	return err
+}
+
+func mustMarshal(v any) json.RawMessage {
+	data, err := json.Marshal(v)
+	if err != nil {
+		panic(err)
+	}
+	return data
+}
+
+// lspURIPath returns the path of a file URI, or the URI itself if it isn't
+// one. Only its extension matters, to pick the comment style.
+func lspURIPath(uri string) string {
+	if u, err := url.Parse(uri); err == nil && u.Scheme == "file" {
+		return u.Path
+	}
+	return uri
+}
+
+// lspThread is a craft thread in a document, located by 0-based line.
+type lspThread struct {
+	first, last int // the thread's craft lines
+	start, end  int // the code lines it's on
+	header      craft.Header
+	ascii       bool
+	indent      string
+	comments    []lspComment
+}
+
+type lspComment struct {
+	author string
+	isNew  bool
+	body   string
+}
+
+// scanThreads finds the threads in a document. Unlike craft.ParseFileComments, it
+// keeps where each one is in the document, craft lines and all.
+func scanThreads(path string, lines []string) []lspThread {
+	style := craft.GetCommentStyle(path)
+	parsed := craft.ParseCraftLines(lines, style.LinePrefix)
+
+	// codeIndex[i] is the index into codeLines of the code line at or
+	// before line i, or -1
+	var codeLines []int
+	codeIndex := make([]int, len(lines))
+	for i, p := range parsed {
+		if !p.OK {
+			codeLines = append(codeLines, i)
+		}
+		codeIndex[i] = len(codeLines) - 1
+	}
+
+	var threads []lspThread
+	for i := 0; i < len(lines); i++ {
+		if !parsed[i].OK || parsed[i].Box != craft.BoxThread {
+			continue
+		}
+		h, ok := craft.ParseHeader(parsed[i].Content)
+		if !ok {
+			continue
+		}
+		t := lspThread{first: i, header: h, ascii: parsed[i].ASCII, indent: craft.GetIndent(lines[i])}
+		var bodyLines []string
+		flush := func() {
+			if n := len(t.comments); n > 0 {
+				t.comments[n-1].body = craft.ParseCommentBody(bodyLines, false)
+			}
+			bodyLines = nil
+		}
+		t.comments = append(t.comments, lspComment{author: h.Author, isNew: h.IsNew})
+		j := i + 1
+		for ; j < len(lines) && parsed[j].OK; j++ {
+			box := parsed[j].Box
+			if box == craft.BoxThread || box == craft.BoxStart || box == craft.BoxChange {
+				break
+			}
+			if box == craft.BoxReply {
+				if rh, ok := craft.ParseHeader(parsed[j].Content); ok {
+					flush()
+					t.comments = append(t.comments, lspComment{author: rh.Author, isNew: rh.IsNew})
+				}
+			} else if box == craft.BoxBody {
+				bodyLines = append(bodyLines, parsed[j].Content)
+			}
+		}
+		flush()
+		t.last = j - 1
+
+		// The code line the thread is on: below it, or above with the above
+		// field. File threads go on the first line.
+		anchor := -1
+		switch {
+		case h.IsFile:
+		case h.IsAbove:
+			if k := codeIndex[t.last] + 1; k < len(codeLines) {
+				anchor = k
+			}
+		default:
+			anchor = codeIndex[t.first]
+		}
+		if anchor < 0 {
+			t.start, t.end = t.first, t.first
+		} else {
+			t.start, t.end = codeLines[anchor], codeLines[anchor]
+			if k := anchor + h.Range; h.Range < 0 && k >= 0 {
+				t.start = codeLines[k]
+			}
+			if h.Lines[0] > 0 && h.Lines[1] <= len(codeLines) {
+				t.start, t.end = codeLines[h.Lines[0]-1], codeLines[h.Lines[1]-1]
+			}
+		}
+		threads = append(threads, t)
+		i = t.last
+	}
+	return threads
+}
+
+// lspDiagnostics returns a diagnostic for each thread in a document, on the
+// code it's on, and one for each malformed craft line.
+func lspDiagnostics(path, text string) []lspDiagnostic {
+	lines := strings.Split(text, "\n")
+	diags := []lspDiagnostic{}
+	for _, t := range scanThreads(path, lines) {
+		first := t.comments[0]
+		who := "new comment"
+		if !first.isNew {
+			who = "@" + first.author
+		}
+		summary, _, _ := strings.Cut(first.body, "\n")
+		msg := who + ": " + summary
+		if n := len(t.comments) - 1; n == 1 {
+			msg += " (1 reply)"
+		} else if n > 1 {
+			msg += fmt.Sprintf(" (%d replies)", n)
+		}
+		severity := lspSeverityInformation
+		if t.header.IsResolved {
+			msg = "resolved: " + msg
+			severity = lspSeverityHint
+		}
+		diags = append(diags, lspDiagnostic{
+			Range:    lspLinesRange(lines, t.start, t.end),
+			Severity: severity,
+			Source:   "craft",
+			Message:  msg,
+		})
+	}
+	_, parseErrs := craft.ParseFileComments(craft.SerializeOptions{}, path, lines, craft.GetCommentStyle(path))
+	for _, pe := range parseErrs {
+		line := max(pe.Line-1, 0)
+		diags = append(diags, lspDiagnostic{
+			Range:    lspLinesRange(lines, line, line),
+			Severity: lspSeverityError,
+			Source:   "craft",
+			Message:  pe.Msg,
+		})
+	}
+	return diags
+}
+
+// lspLinesRange returns the range covering lines first through last.
+func lspLinesRange(lines []string, first, last int) lspRange {
+	var width int
+	if last < len(lines) {
+		width = len(utf16.Encode([]rune(lines[last])))
+	}
+	return lspRange{Start: lspPosition{Line: first}, End: lspPosition{Line: last, Character: width}}
+}
+
+// lspCodeActions returns actions for the threads that overlap rng: a reply
+// to each, and applying the latest suggestion in it.
+func lspCodeActions(uri, text string, rng lspRange) []lspCodeAction {
+	filePath := lspURIPath(uri)
+	style := craft.GetCommentStyle(filePath)
+	lines := strings.Split(text, "\n")
+	actions := []lspCodeAction{}
+
+	action := func(title string, edit lspTextEdit) {
+		a := lspCodeAction{Title: title, Kind: "quickfix"}
+		a.Edit.Changes = map[string][]lspTextEdit{uri: {edit}}
+		actions = append(actions, a)
+	}
+	overlaps := func(first, last int) bool {
+		return rng.Start.Line <= last && rng.End.Line >= first
+	}
+
+	for _, t := range scanThreads(filePath, lines) {
+		if !overlaps(t.first, t.last) && !overlaps(t.start, t.end) {
+			continue
+		}
+		boxes := craft.UnicodeBoxes
+		if t.ascii {
+			boxes = craft.ASCIIBoxes
+		}
+		who := "new comment"
+		if first := t.comments[0]; !first.isNew {
+			who = "@" + first.author
+		}
+
+		// A new reply after the thread's last line, for the body to be
+		// filled in
+		reply := t.indent + craft.FormatCraftLine(style.LinePrefix, boxes.Reply, craft.FormatHeader(craft.Header{IsNew: true})) + "\n" +
+			t.indent + craft.FormatCraftLine(style.LinePrefix, boxes.Body, "") + "\n"
+		insertAt := lspPosition{Line: t.last + 1}
+		action("Reply to "+who, lspTextEdit{Range: lspRange{Start: insertAt, End: insertAt}, NewText: reply})
+
+		// The latest suggestion replaces the code the thread is on
+		if suggestion, i, ok := threadSuggestion(t, lines, style.LinePrefix); ok {
+			var newText string
+			if suggestion != "" {
+				newText = suggestion + "\n"
+			}
+			author := "new comment"
+			if !t.comments[i].isNew {
+				author = "@" + t.comments[i].author
+			}
+			action("Apply suggestion from "+author, lspTextEdit{
+				Range:   lspRange{Start: lspPosition{Line: t.start}, End: lspPosition{Line: t.end + 1}},
+				NewText: newText,
+			})
+		}
+	}
+	return actions
+}
+
+// threadSuggestion returns the latest suggestion in a thread and the index
+// of its comment, if it can replace the code the thread is on: the thread
+// isn't on the file, and no other craft lines are in the way.
+func threadSuggestion(t lspThread, lines []string, prefix string) (string, int, bool) {
+	if t.header.IsFile || t.start > t.end {
+		return "", 0, false
+	}
+	for line := t.start; line <= t.end; line++ {
+		if _, _, isCraft := craft.ParseCraftLine(lines[line], prefix); isCraft {
+			return "", 0, false
+		}
+	}
+	for i := len(t.comments) - 1; i >= 0; i-- {
+		if suggestion, ok := craft.SuggestionBlock(t.comments[i].body); ok {
+			return suggestion, i, true
+		}
+	}
+	return "", 0, false
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const lspTestDoc = "package main\n" +
+	"\n" +
+	"func main() {\n" +
+	"\tx := 1\n" +
+	"\t// ╓───── @bob ─ at 2025-01-01 09:00 ─ v2\n" +
+	"\t// ║ Use y:\n" +
+	"\t// ║\n" +
+	"\t// ║ ```suggestion\n" +
+	"\t// ║ \ty := 1\n" +
+	"\t// ║ ```\n" +
+	"\t// ╟───── @alice ─ at 2025-01-02 09:00 ─ v2\n" +
+	"\t// ║ Sure\n" +
+	"\tprint(x)\n" +
+	"\t// ╓───── @carol ─ at 2025-01-03 09:00 ─ range -1 ─ resolved ─ v2\n" +
+	"\t// ║ Done\n" +
+	"}\n"
+
+func TestLSPDiagnostics(t *testing.T) {
+	diags := lspDiagnostics("main.go", lspTestDoc)
+	require.Len(t, diags, 2)
+
+	assert.Equal(t, "@bob: Use y: (1 reply)", diags[0].Message)
+	assert.Equal(t, lspSeverityInformation, diags[0].Severity)
+	assert.Equal(t, lspRange{Start: lspPosition{Line: 3}, End: lspPosition{Line: 3, Character: 7}}, diags[0].Range)
+
+	// The range covers both code lines, skipping the thread between them
+	assert.Equal(t, "resolved: @carol: Done", diags[1].Message)
+	assert.Equal(t, lspSeverityHint, diags[1].Severity)
+	assert.Equal(t, lspRange{Start: lspPosition{Line: 3}, End: lspPosition{Line: 12, Character: 9}}, diags[1].Range)
+
+	// Malformed craft lines are errors
+	diags = lspDiagnostics("main.go", "package main\n// ║ stray\n")
+	require.Len(t, diags, 1)
+	assert.Equal(t, lspSeverityError, diags[0].Severity)
+	assert.Equal(t, 1, diags[0].Range.Start.Line)
+}
+
+func TestLSPCodeActions(t *testing.T) {
+	uri := "file:///src/main.go"
+	// Line 5 is in bob's thread, and in the code carol's is on
+	actions := lspCodeActions(uri, lspTestDoc, lspRange{Start: lspPosition{Line: 5}, End: lspPosition{Line: 5}})
+	require.Len(t, actions, 3)
+
+	assert.Equal(t, "Reply to @bob", actions[0].Title)
+	assert.Equal(t, []lspTextEdit{{
+		Range:   lspRange{Start: lspPosition{Line: 12}, End: lspPosition{Line: 12}},
+		NewText: "\t// ╟───── new\n\t// ║\n",
+	}}, actions[0].Edit.Changes[uri])
+
+	assert.Equal(t, "Apply suggestion from @bob", actions[1].Title)
+	assert.Equal(t, []lspTextEdit{{
+		Range:   lspRange{Start: lspPosition{Line: 3}, End: lspPosition{Line: 4}},
+		NewText: "\ty := 1\n",
+	}}, actions[1].Edit.Changes[uri])
+	assert.Equal(t, "Reply to @carol", actions[2].Title)
+
+	// Only a reply for a thread without a suggestion
+	actions = lspCodeActions(uri, lspTestDoc, lspRange{Start: lspPosition{Line: 14}, End: lspPosition{Line: 14}})
+	require.Len(t, actions, 1)
+	assert.Equal(t, "Reply to @carol", actions[0].Title)
+
+	// Nothing away from threads
+	assert.Empty(t, lspCodeActions(uri, lspTestDoc, lspRange{Start: lspPosition{Line: 0}, End: lspPosition{Line: 1}}))
+}
+
+func TestLSPServe(t *testing.T) {
+	var in bytes.Buffer
+	send := func(msg string) {
+		fmt.Fprintf(&in, "Content-Length: %d\r\n\r\n%s", len(msg), msg)
+	}
+	doc, err := json.Marshal(lspTestDoc)
+	require.NoError(t, err)
+	send(`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{}}`)
+	send(`{"jsonrpc":"2.0","method":"textDocument/didOpen","params":{"textDocument":{"uri":"file:///main.go","text":` + string(doc) + `}}}`)
+	send(`{"jsonrpc":"2.0","id":2,"method":"bogus"}`)
+	send(`{"jsonrpc":"2.0","id":3,"method":"shutdown"}`)
+	send(`{"jsonrpc":"2.0","method":"exit"}`)
+
+	var out bytes.Buffer
+	require.NoError(t, newLSPServer(&in, &out).Serve())
+
+	reader := newLSPServer(&out, nil)
+	var msgs []*lspMessage
+	for {
+		msg, err := reader.read()
+		if err != nil {
+			break
+		}
+		msgs = append(msgs, msg)
+	}
+	require.Len(t, msgs, 4)
+	assert.Equal(t, "1", string(*msgs[0].ID))
+	assert.Equal(t, "textDocument/publishDiagnostics", msgs[1].Method)
+	assert.Equal(t, 2, strings.Count(string(msgs[1].Params), `"source":"craft"`))
+	assert.Equal(t, -32601, msgs[2].Error.Code)
+	assert.Equal(t, "3", string(*msgs[3].ID))
+}
@@ -12,6 +12,13 @@ var rootCmd = &cobra.Command{
 	Short:        "Code review tool for GitHub PRs",
 	Long:         `craft is a tool for doing GitHub code review locally with PR comments embedded in source files.`,
 	SilenceUsage: true,
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		if err := checkColorFlag(); err != nil {
+			return err
+		}
+		setupLogging()
+		return nil
+	},
 }
 
 func main() {
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/dnr/craft/pkg/craft"
+	"rsc.io/markdown"
+)
+
+// renderTerminal renders a comment body's markdown for a terminal, roughly as
+// GitHub shows it: markers become styles (with color on), lists get bullets
+// and quotes a bar, and links show their URLs. A suggestion block is shown
+// as a diff from old, the code it replaces, if that's known.
+func renderTerminal(body string, color bool, old []string) string {
+	doc := craft.NewMarkdownParser().Parse(body)
+	r := &mdRenderer{color: color, old: old}
+	return strings.Join(r.blocks(doc.Blocks), "\n")
+}
+
+type mdRenderer struct {
+	color bool
+	old   []string
+}
+
+// blocks renders blocks as lines, with a blank line between blocks.
+func (r *mdRenderer) blocks(blocks []markdown.Block) []string {
+	var lines []string
+	for _, b := range blocks {
+		rendered := r.block(b)
+		if len(rendered) == 0 {
+			continue
+		}
+		if len(lines) > 0 {
+			lines = append(lines, "")
+		}
+		lines = append(lines, rendered...)
+	}
+	return lines
+}
+
+func (r *mdRenderer) block(b markdown.Block) []string {
+	switch b := b.(type) {
+	case *markdown.Paragraph:
+		return strings.Split(r.inlines(b.Text.Inline), "\n")
+	case *markdown.Text:
+		return strings.Split(r.inlines(b.Inline), "\n")
+	case *markdown.Heading:
+		return []string{paint(r.color, ansiBold, r.inlines(b.Text.Inline))}
+	case *markdown.Quote:
+		lines := r.blocks(b.Blocks)
+		for i, line := range lines {
+			lines[i] = paint(r.color, ansiDim, "│ ") + line
+		}
+		return lines
+	case *markdown.List:
+		var lines []string
+		for i, item := range b.Items {
+			marker := "• "
+			if b.Ordered() {
+				marker = fmt.Sprintf("%d. ", b.Start+i)
+			}
+			var itemLines []string
+			if b.Loose {
+				itemLines = r.blocks(item.(*markdown.Item).Blocks)
+			} else {
+				for _, child := range item.(*markdown.Item).Blocks {
+					itemLines = append(itemLines, r.block(child)...)
+				}
+			}
+			indent := strings.Repeat(" ", craft.TextWidth(marker))
+			for j, line := range itemLines {
+				if j == 0 {
+					line = marker + line
+				} else if line != "" {
+					line = indent + line
+				}
+				lines = append(lines, line)
+			}
+		}
+		return lines
+	case *markdown.CodeBlock:
+		if strings.TrimSpace(b.Info) == "suggestion" {
+			return r.suggestion(b.Text)
+		}
+		lines := make([]string, len(b.Text))
+		for i, line := range b.Text {
+			lines[i] = "    " + paint(r.color, ansiCyan, line)
+		}
+		return lines
+	case *markdown.Table:
+		lines := []string{paint(r.color, ansiBold, r.row(b.Header))}
+		for _, row := range b.Rows {
+			lines = append(lines, r.row(row))
+		}
+		return lines
+	case *markdown.HTMLBlock:
+		lines := make([]string, len(b.Text))
+		for i, line := range b.Text {
+			lines[i] = paint(r.color, ansiDim, line)
+		}
+		return lines
+	case *markdown.ThematicBreak:
+		return []string{paint(r.color, ansiDim, "───")}
+	}
+	return nil // Empty
+}
+
+// suggestion renders a suggestion block as a diff from the code it replaces.
+func (r *mdRenderer) suggestion(lines []string) []string {
+	result := []string{paint(r.color, ansiBold, "Suggested change:")}
+	for _, line := range r.old {
+		result = append(result, paint(r.color, ansiRed, "- "+line))
+	}
+	for _, line := range lines {
+		result = append(result, paint(r.color, ansiGreen, "+ "+line))
+	}
+	return result
+}
+
+// row renders a table row, with cells separated by bars.
+func (r *mdRenderer) row(cells []*markdown.Text) string {
+	var parts []string
+	for _, cell := range cells {
+		parts = append(parts, r.inlines(cell.Inline))
+	}
+	return strings.Join(parts, " │ ")
+}
+
+func (r *mdRenderer) inlines(inlines markdown.Inlines) string {
+	var buf strings.Builder
+	for _, inl := range inlines {
+		switch x := inl.(type) {
+		case *markdown.Plain:
+			buf.WriteString(x.Text)
+		case *markdown.Escaped:
+			buf.WriteString(x.Text)
+		case *markdown.SoftBreak:
+			buf.WriteString(" ") // GitHub joins the lines of a paragraph
+		case *markdown.HardBreak:
+			buf.WriteString("\n")
+		case *markdown.Code:
+			buf.WriteString(paint(r.color, ansiCyan, x.Text))
+		case *markdown.Strong:
+			buf.WriteString(paint(r.color, ansiBold, r.inlines(x.Inner)))
+		case *markdown.Emph:
+			buf.WriteString(paint(r.color, ansiItalic, r.inlines(x.Inner)))
+		case *markdown.Del:
+			buf.WriteString(paint(r.color, ansiStrike, r.inlines(x.Inner)))
+		case *markdown.Link:
+			buf.WriteString(paint(r.color, ansiUnderline, r.inlines(x.Inner)))
+			buf.WriteString(paint(r.color, ansiDim, " ("+x.URL+")"))
+		case *markdown.Image:
+			buf.WriteString(paint(r.color, ansiDim, "[image: "+r.inlines(x.Inner)+"] ("+x.URL+")"))
+		case *markdown.AutoLink:
+			buf.WriteString(paint(r.color, ansiUnderline, x.Text))
+		case *markdown.Emoji:
+			buf.WriteString(x.Text)
+		case *markdown.Task:
+			if x.Checked {
+				buf.WriteString("[x] ")
+			} else {
+				buf.WriteString("[ ] ")
+			}
+		case *markdown.FootnoteLink:
+			buf.WriteString(paint(r.color, ansiDim, "[^"+x.Label+"]"))
+		case *markdown.HTMLTag:
+			buf.WriteString(paint(r.color, ansiDim, x.Text))
+		}
+	}
+	return buf.String()
+}
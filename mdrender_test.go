@@ -0,0 +1,42 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRenderTerminal(t *testing.T) {
+	body := "# Naming\n" +
+		"\n" +
+		"This is **bold**, `code`\n" +
+		"and a [link](https://example.com).\n" +
+		"\n" +
+		"- one\n" +
+		"- [x] two\n" +
+		"\n" +
+		"> quoted\n" +
+		"\n" +
+		"```suggestion\n" +
+		"y := 2\n" +
+		"```"
+	want := "Naming\n" +
+		"\n" +
+		"This is bold, code and a link (https://example.com).\n" +
+		"\n" +
+		"• one\n" +
+		"• [x] two\n" +
+		"\n" +
+		"│ quoted\n" +
+		"\n" +
+		"Suggested change:\n" +
+		"- x := 1\n" +
+		"+ y := 2"
+	assert.Equal(t, want, renderTerminal(body, false, []string{"x := 1"}))
+
+	// Markers become styles
+	assert.Equal(t, "a "+ansiBold+"b"+ansiReset+" "+ansiCyan+"c"+ansiReset, renderTerminal("a **b** `c`", true, nil))
+
+	// Without the code, a suggestion is only what it adds
+	assert.Equal(t, "Suggested change:\n+ new", renderTerminal("```suggestion\nnew\n```", false, nil))
+}
@@ -0,0 +1,95 @@
+package craft
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+)
+
+// AppliedField marks the headers of a thread whose suggestion is in the
+// code now, so 'craft resolve --applied' can resolve it.
+const AppliedField = "applied"
+
+// appliedSearchWindow is how far from a thread's line an applied suggestion
+// is looked for, since applying it usually moves or outdates the thread.
+const appliedSearchWindow = 10
+
+// MarkAppliedSuggestions sets IsApplied on the unresolved threads with a
+// suggestion that's now in the code in opts.FS, verbatim (except for
+// trailing whitespace), near the thread's line. It returns how many there
+// are. Suggestions that delete lines can't be seen in the code, and
+// suggestions of the code as it already was don't count.
+func MarkAppliedSuggestions(opts SerializeOptions, pr *PullRequest) int {
+	files := make(map[string][]string)
+	var n int
+	for i := range pr.ReviewThreads {
+		thread := &pr.ReviewThreads[i]
+		thread.IsApplied = false
+		if thread.IsResolved || thread.SubjectType == SubjectTypeFile || thread.DiffSide == DiffSideLeft {
+			continue
+		}
+		for _, c := range thread.Comments {
+			block, ok := SuggestionBlock(c.Body)
+			if !ok || block == "" {
+				continue
+			}
+			lines, ok := files[thread.Path]
+			if !ok {
+				lines, _ = CodeLines(opts, thread.Path)
+				files[thread.Path] = lines
+			}
+			suggested := strings.Split(block, "\n")
+			original := originalThreadLines(opts, thread)
+			if original != nil && linesEqual(suggested, original) {
+				continue
+			}
+			if containsLinesNear(lines, suggested, cmp.Or(thread.Line, thread.OriginalLine)) {
+				thread.IsApplied = true
+				n++
+				break
+			}
+		}
+	}
+	return n
+}
+
+// originalThreadLines returns the lines a thread was on when it was made,
+// or nil if they can't be read.
+func originalThreadLines(opts SerializeOptions, thread *ReviewThread) []string {
+	if opts.VCS == nil || thread.OriginalCommitOID == "" || thread.OriginalLine < 1 {
+		return nil
+	}
+	content, err := opts.VCS.GetFileAtCommit(thread.OriginalCommitOID, thread.Path)
+	if err != nil {
+		return nil
+	}
+	lines := strings.Split(content, "\n")
+	start := thread.OriginalLine
+	if thread.OriginalStartLine != nil {
+		start = *thread.OriginalStartLine
+	}
+	if start < 1 || start > thread.OriginalLine || thread.OriginalLine > len(lines) {
+		return nil
+	}
+	return lines[start-1 : thread.OriginalLine]
+}
+
+// containsLinesNear reports whether want appears in lines, ending within
+// appliedSearchWindow lines of line (1-based).
+func containsLinesNear(lines, want []string, line int) bool {
+	first := max(0, line-len(want)-appliedSearchWindow)
+	last := min(len(lines)-len(want), line+appliedSearchWindow)
+	for i := first; i <= last; i++ {
+		if linesEqual(lines[i:i+len(want)], want) {
+			return true
+		}
+	}
+	return false
+}
+
+// linesEqual compares lines ignoring trailing whitespace.
+func linesEqual(a, b []string) bool {
+	return slices.EqualFunc(a, b, func(x, y string) bool {
+		return strings.TrimRight(x, " \t\r") == strings.TrimRight(y, " \t\r")
+	})
+}
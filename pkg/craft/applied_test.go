@@ -0,0 +1,28 @@
+package craft
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMarkAppliedSuggestions(t *testing.T) {
+	code := "package main\n\nfunc main() {\n\tx := 1\n\tprintln(x + 1)\n}\n"
+	memfs := fstest.MapFS{"main.go": &fstest.MapFile{Data: []byte(code)}}
+	suggest := func(s string) []ReviewComment {
+		return []ReviewComment{{Author: Actor{Login: "alice"}, Body: "Try:\n```suggestion\n" + s + "\n```"}}
+	}
+	pr := &PullRequest{ReviewThreads: []ReviewThread{
+		{Path: "main.go", Line: 3, DiffSide: DiffSideRight, Comments: suggest("\tprintln(x + 1)")},
+		{Path: "main.go", Line: 5, DiffSide: DiffSideRight, Comments: suggest("\tprintln(x + 2)")},
+		{Path: "main.go", Line: 4, DiffSide: DiffSideRight, IsResolved: true, Comments: suggest("\tx := 1")},
+		{Path: "main.go", Line: 4, DiffSide: DiffSideRight, Comments: []ReviewComment{{Body: "no suggestion"}}},
+	}}
+
+	assert.Equal(t, 1, MarkAppliedSuggestions(SerializeOptions{FS: memfs}, pr))
+	assert.True(t, pr.ReviewThreads[0].IsApplied)
+	assert.False(t, pr.ReviewThreads[1].IsApplied)
+	assert.False(t, pr.ReviewThreads[2].IsApplied)
+	assert.False(t, pr.ReviewThreads[3].IsApplied)
+}
@@ -0,0 +1,93 @@
+package craft
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"strings"
+)
+
+// PR-DESCRIPTION.md has the PR's title and body, to edit and push with
+// 'craft send --description':
+//
+//	<!-- craft: description of PR #12 as fetched (0123456789ab); ... -->
+//	# Title
+//
+//	Body, as on GitHub
+//
+// The first line records a hash of the description as fetched, so send can
+// tell whether it was edited on GitHub since.
+
+// descriptionMarkerRe matches the first line of PR-DESCRIPTION.md.
+var descriptionMarkerRe = regexp.MustCompile(`^<!-- craft: .*\(([0-9a-f]+)\).*-->$`)
+
+// IsReviewFile reports whether path is one of the files craft writes the
+// review into besides the code: PR-STATE.txt, PR-OUTDATED.txt and
+// PR-DESCRIPTION.md.
+func IsReviewFile(path string) bool {
+	return path == PRStateFile || path == OutdatedFile || path == DescriptionFile
+}
+
+// DescriptionHash returns a short hash of a PR's title and body.
+func DescriptionHash(title, body string) string {
+	sum := sha256.Sum256([]byte(title + "\n" + NormalizeNewlines(body)))
+	return hex.EncodeToString(sum[:6])
+}
+
+// NormalizeNewlines turns the CRLFs GitHub keeps in bodies written in its UI
+// into LFs.
+func NormalizeNewlines(s string) string {
+	return strings.ReplaceAll(s, "\r\n", "\n")
+}
+
+// FormatDescription returns the content of PR-DESCRIPTION.md for a PR.
+func FormatDescription(number int, title, body string) string {
+	var buf strings.Builder
+	fmt.Fprintf(&buf, "<!-- craft: description of PR #%d as fetched (%s); edit the title and body, and use 'craft send --description' -->\n",
+		number, DescriptionHash(title, body))
+	buf.WriteString("# " + title + "\n")
+	if body = strings.TrimSpace(NormalizeNewlines(body)); body != "" {
+		buf.WriteString("\n" + body + "\n")
+	}
+	return buf.String()
+}
+
+// parseDescription reads PR-DESCRIPTION.md content, returning the title, the
+// body and the hash of the description as fetched.
+func parseDescription(content string) (title, body, fetched string, err error) {
+	marker, rest, _ := strings.Cut(NormalizeNewlines(content), "\n")
+	match := descriptionMarkerRe.FindStringSubmatch(strings.TrimSpace(marker))
+	if match == nil {
+		return "", "", "", fmt.Errorf("%s: first line isn't craft's; run 'craft get' to write it again", DescriptionFile)
+	}
+	heading, body, _ := strings.Cut(rest, "\n")
+	title, ok := strings.CutPrefix(heading, "# ")
+	if title = strings.TrimSpace(title); !ok || title == "" {
+		return "", "", "", fmt.Errorf("%s:2: the title goes on the second line, as \"# Title\"", DescriptionFile)
+	}
+	return title, strings.TrimSpace(body), match[1], nil
+}
+
+// serializeDescription writes PR-DESCRIPTION.md, for a PR fetched from
+// GitHub (one read back from the files has no title, and leaves it alone).
+func serializeDescription(pr *PullRequest, opts SerializeOptions) error {
+	if pr.IsLocal || pr.Title == "" {
+		return nil
+	}
+	return WriteFile(opts.FS, DescriptionFile, []byte(FormatDescription(pr.Number, pr.Title, pr.Body)))
+}
+
+// ReadDescription reads the title and body in PR-DESCRIPTION.md, and the
+// hash of the description as fetched.
+func ReadDescription(fsys fs.FS) (title, body, fetched string, err error) {
+	content, err := ReadFile(fsys, DescriptionFile)
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", "", "", fmt.Errorf("no %s; run 'craft get' to write it", DescriptionFile)
+	} else if err != nil {
+		return "", "", "", err
+	}
+	return parseDescription(string(content))
+}
@@ -0,0 +1,45 @@
+package craft
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDescriptionRoundTrip(t *testing.T) {
+	content := FormatDescription(12, "Fix the thing", "Body line\r\n\r\nMore\r\n")
+	assert.Equal(t, "<!-- craft: description of PR #12 as fetched ("+DescriptionHash("Fix the thing", "Body line\n\nMore\n")+
+		"); edit the title and body, and use 'craft send --description' -->\n"+
+		"# Fix the thing\n\nBody line\n\nMore\n", content)
+
+	title, body, fetched, err := parseDescription(content)
+	require.NoError(t, err)
+	assert.Equal(t, "Fix the thing", title)
+	assert.Equal(t, "Body line\n\nMore", body)
+	assert.Equal(t, DescriptionHash("Fix the thing", "Body line\r\n\r\nMore\r\n"), fetched)
+
+	_, _, _, err = parseDescription("# Title\n")
+	assert.ErrorContains(t, err, "first line isn't craft's")
+	_, _, _, err = parseDescription("<!-- craft: x (abc) -->\nTitle\n")
+	assert.ErrorContains(t, err, "the title goes on the second line")
+}
+
+func TestSerializeDescription(t *testing.T) {
+	memfs := fstest.MapFS{}
+	opts := SerializeOptions{FS: memfs}
+	require.NoError(t, Serialize(&PullRequest{Number: 3, Title: "Add it", Body: "Why"}, opts))
+	title, body, _, err := ReadDescription(memfs)
+	require.NoError(t, err)
+	assert.Equal(t, "Add it", title)
+	assert.Equal(t, "Why", body)
+
+	// A PR read back from the files has no title, and leaves it alone
+	pr, err := Deserialize(opts)
+	require.NoError(t, err)
+	require.NoError(t, Serialize(pr, opts))
+	title, _, _, err = ReadDescription(memfs)
+	require.NoError(t, err)
+	assert.Equal(t, "Add it", title)
+}
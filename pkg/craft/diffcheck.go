@@ -0,0 +1,141 @@
+package craft
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+// DiffContext is the number of context lines around each hunk of the PR
+// diff, which can be commented on like changed lines.
+const DiffContext = 3
+
+// lineSpan is an inclusive range of 1-based line numbers.
+type lineSpan struct{ first, last int }
+
+// diffHunks is where the PR diff of one file can be commented on.
+type diffHunks struct {
+	right, left []lineSpan // lines of the head and base versions
+}
+
+// spanContaining returns the hunk on side that contains line, if any.
+func (h diffHunks) spanContaining(side DiffSide, line int) (lineSpan, bool) {
+	spans := h.right
+	if side == DiffSideLeft {
+		spans = h.left
+	}
+	for _, span := range spans {
+		if line >= span.first && line <= span.last {
+			return span, true
+		}
+	}
+	return lineSpan{}, false
+}
+
+// computeDiffHunks diffs a file between the base and head versions, with
+// DiffContext lines of context like GitHub's diff view. A missing version
+// (an added or deleted file) counts as empty.
+func computeDiffHunks(vcs VCS, base, head, path string) diffHunks {
+	var versions [2][]string
+	for i, commit := range []string{base, head} {
+		if content, err := vcs.GetFileAtCommit(commit, path); err == nil {
+			versions[i] = strings.Split(content, "\n")
+		}
+	}
+	var hunks diffHunks
+	for _, group := range difflib.NewMatcher(versions[0], versions[1]).GetGroupedOpCodes(DiffContext) {
+		first, last := group[0], group[len(group)-1]
+		if last.J2 > first.J1 {
+			hunks.right = append(hunks.right, lineSpan{first.J1 + 1, last.J2})
+		}
+		if last.I2 > first.I1 {
+			hunks.left = append(hunks.left, lineSpan{first.I1 + 1, last.I2})
+		}
+	}
+	return hunks
+}
+
+// DiffErrors is returned by CollectNewComments for new threads on lines that
+// aren't in the PR diff, which GitHub won't accept.
+type DiffErrors []ParseError
+
+func (e DiffErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, de := range e {
+		msgs[i] = de.Error()
+	}
+	return fmt.Sprintf("%d new thread(s) outside the PR diff:\n%s", len(e), strings.Join(msgs, "\n"))
+}
+
+// CheckNewThreadsInDiff checks that each new line-level thread is within one
+// hunk of the diff from pr.BaseRefOID to pr.CheckoutOID(), which is what
+// GitHub checks for a review on that commit.
+func CheckNewThreadsInDiff(vcs VCS, pr *PullRequest, threads []NewThreadInfo) error {
+	if pr.BaseRefOID == "" || pr.CheckoutOID() == "" {
+		return nil
+	}
+	hunksByPath := make(map[string]diffHunks)
+	var errs DiffErrors
+	for _, t := range threads {
+		if t.Subject == SubjectTypeFile {
+			continue
+		}
+		hunks, ok := hunksByPath[t.Path]
+		if !ok {
+			hunks = computeDiffHunks(vcs, pr.BaseRefOID, pr.CheckoutOID(), t.Path)
+			hunksByPath[t.Path] = hunks
+		}
+		span, ok := hunks.spanContaining(t.Side, t.Line)
+		if !ok {
+			errs = append(errs, ParseError{Path: t.Path, Line: t.Line, Msg: "line is not in the PR diff"})
+		} else if t.StartLine != nil && *t.StartLine < span.first {
+			errs = append(errs, ParseError{Path: t.Path, Line: t.Line, Msg: fmt.Sprintf("range from line %d goes outside the diff hunk (lines %d-%d)", *t.StartLine, span.first, span.last)})
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// ChangeMarkerRe matches the text of a change marker line.
+var ChangeMarkerRe = regexp.MustCompile(`^[0-9]+ lines? (?:changed|deleted)$`)
+
+// changedLineMarkers diffs the base version of path against lines, its
+// current content without craft comments, and returns the text of a change
+// marker for each insertion point (0-based index of the line it goes before):
+// one before each run of changed or added lines, and one where lines were
+// deleted. An added file is one run.
+func changedLineMarkers(vcs VCS, base, path string, lines []string) map[int]string {
+	if base == "" {
+		return nil
+	}
+	var baseLines []string
+	if content, err := vcs.GetFileAtCommit(base, path); err == nil {
+		baseLines = strings.Split(strings.TrimSuffix(content, "\n"), "\n")
+	}
+	// A trailing newline splits into an empty last line, which is no line
+	// to mark
+	if n := len(lines); n > 0 && lines[n-1] == "" {
+		lines = lines[:n-1]
+	}
+	markers := make(map[int]string)
+	for _, op := range difflib.NewMatcher(baseLines, lines).GetOpCodes() {
+		switch op.Tag {
+		case 'r', 'i':
+			markers[op.J1] = countLines(op.J2-op.J1) + " changed"
+		case 'd':
+			markers[op.J1] = countLines(op.I2-op.I1) + " deleted"
+		}
+	}
+	return markers
+}
+
+func countLines(n int) string {
+	if n == 1 {
+		return "1 line"
+	}
+	return fmt.Sprintf("%d lines", n)
+}
@@ -0,0 +1,140 @@
+package craft
+
+import (
+	"bufio"
+	"bytes"
+	"io/fs"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// editorConfigFile is the name of EditorConfig files (https://editorconfig.org).
+const editorConfigFile = ".editorconfig"
+
+// editorConfigMaxLineLength returns max_line_length from the .editorconfig
+// files that apply to the given file, or 0 if it is unset or "off".
+// Files closer to the target take precedence, and the search stops at a file
+// declaring root = true or at the root of fsys.
+func editorConfigMaxLineLength(fsys fs.FS, file string) int {
+	value := ""
+	found := false
+	for dir := path.Dir(file); ; dir = path.Dir(dir) {
+		name := path.Join(dir, editorConfigFile)
+		if data, err := ReadFile(fsys, name); err == nil {
+			rel := strings.TrimPrefix(file, dir+"/")
+			if dir == "." {
+				rel = file
+			}
+			v, ok, root := parseEditorConfig(data, rel, "max_line_length")
+			if ok && !found {
+				value, found = v, true
+			}
+			if root {
+				break
+			}
+		}
+		if dir == "." || dir == "/" {
+			break
+		}
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		return 0
+	}
+	return n
+}
+
+// parseEditorConfig returns the value of key for the file at rel (relative
+// to the .editorconfig's directory), whether it was set, and whether the file
+// declares root = true. Later matching sections override earlier ones.
+func parseEditorConfig(data []byte, rel, key string) (value string, ok, root bool) {
+	matching := false
+	preamble := true
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' || line[0] == ';' {
+			continue
+		}
+		if line[0] == '[' && line[len(line)-1] == ']' {
+			preamble = false
+			matching = editorConfigGlobMatch(line[1:len(line)-1], rel)
+			continue
+		}
+		k, v, found := strings.Cut(line, "=")
+		if !found {
+			continue
+		}
+		k = strings.ToLower(strings.TrimSpace(k))
+		v = strings.ToLower(strings.TrimSpace(v))
+		switch {
+		case preamble && k == "root":
+			root = v == "true"
+		case matching && k == key:
+			value, ok = v, true
+		}
+	}
+	return value, ok, root
+}
+
+// editorConfigGlobMatch reports whether name matches an EditorConfig section
+// glob. Supports *, **, ?, [...] and {a,b} alternatives. Globs without a slash
+// match the base name in any directory.
+func editorConfigGlobMatch(glob, name string) bool {
+	if !strings.Contains(glob, "/") {
+		glob = "**/" + glob
+	}
+	glob = strings.TrimPrefix(glob, "/")
+	for _, g := range expandBraces(glob) {
+		if globMatch(g, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// expandBraces expands {a,b} alternatives in a glob into separate globs.
+func expandBraces(glob string) []string {
+	open := strings.IndexByte(glob, '{')
+	if open < 0 {
+		return []string{glob}
+	}
+	close := strings.IndexByte(glob[open:], '}')
+	if close < 0 {
+		return []string{glob}
+	}
+	close += open
+	var out []string
+	for _, alt := range strings.Split(glob[open+1:close], ",") {
+		out = append(out, expandBraces(glob[:open]+alt+glob[close+1:])...)
+	}
+	return out
+}
+
+// globMatch matches a slash-separated glob against name, where a "**"
+// segment matches zero or more directories.
+func globMatch(glob, name string) bool {
+	return matchSegments(strings.Split(glob, "/"), strings.Split(name, "/"))
+}
+
+func matchSegments(glob, name []string) bool {
+	if len(glob) == 0 {
+		return len(name) == 0
+	}
+	if glob[0] == "**" {
+		for i := 0; i <= len(name); i++ {
+			if matchSegments(glob[1:], name[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(name) == 0 {
+		return false
+	}
+	if ok, _ := path.Match(glob[0], name[0]); !ok {
+		return false
+	}
+	return matchSegments(glob[1:], name[1:])
+}
@@ -0,0 +1,244 @@
+package craft
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"math/rand/v2"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"sync"
+	"testing/fstest"
+)
+
+// WriteFS is a filesystem that serialization can write to.
+type WriteFS interface {
+	fs.FS
+	WriteFile(name string, data []byte) error
+	Remove(name string) error
+	Stat(name string) (fs.FileInfo, error)
+}
+
+// DirFS wraps a directory path and implements WriteFS.
+// Writes go through os.Root, so they can't escape the directory.
+// Names are slash-separated, as in io/fs, on every OS.
+type DirFS string
+
+func (d DirFS) Open(name string) (fs.File, error) {
+	p, err := d.path("open", name)
+	if err != nil {
+		return nil, err
+	}
+	return os.Open(p)
+}
+
+// path returns the OS path of name, which must be a valid io/fs path.
+func (d DirFS) path(op, name string) (string, error) {
+	if !fs.ValidPath(name) {
+		return "", &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	return filepath.Join(string(d), filepath.FromSlash(name)), nil
+}
+
+func (d DirFS) Root() string { return string(d) }
+
+// withRoot calls f with the directory opened as a RootFS.
+func (d DirFS) withRoot(f func(RootFS) error) error {
+	root, err := os.OpenRoot(string(d))
+	if err != nil {
+		return err
+	}
+	defer root.Close()
+	return f(RootFS{root})
+}
+
+func (d DirFS) WriteFile(name string, data []byte) error {
+	return d.withRoot(func(r RootFS) error { return r.WriteFile(name, data) })
+}
+
+func (d DirFS) Remove(name string) error {
+	return d.withRoot(func(r RootFS) error { return r.Remove(name) })
+}
+
+func (d DirFS) Stat(name string) (fs.FileInfo, error) {
+	p, err := d.path("stat", name)
+	if err != nil {
+		return nil, err
+	}
+	return os.Stat(p)
+}
+
+// RootFS is a WriteFS confined to an os.Root: paths can't use ".." or
+// symlinks to reach outside it.
+type RootFS struct {
+	root *os.Root
+}
+
+// NewRootFS returns a RootFS for root. The caller still owns root and must
+// close it when done.
+func NewRootFS(root *os.Root) RootFS { return RootFS{root} }
+
+func (r RootFS) Open(name string) (fs.File, error) { return r.root.FS().Open(name) }
+
+func (r RootFS) Remove(name string) error { return r.root.Remove(name) }
+
+func (r RootFS) Stat(name string) (fs.FileInfo, error) { return r.root.Stat(name) }
+
+// WriteFile replaces the contents of a file by writing a temp file in the
+// same directory and renaming it over the original, so a crash never leaves a
+// partly written file. The original's permissions are kept (0644 for new
+// files). Symlinks are written through in place rather than replaced.
+func (r RootFS) WriteFile(name string, data []byte) error {
+	mode := fs.FileMode(0644)
+	if fi, err := r.root.Lstat(name); err == nil {
+		if fi.Mode()&fs.ModeSymlink != 0 {
+			return r.root.WriteFile(name, data, 0644)
+		}
+		mode = fi.Mode().Perm()
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	tmpName := path.Join(path.Dir(name), fmt.Sprintf(".%s.craft-%08x", path.Base(name), rand.Uint32()))
+	tmp, err := r.root.OpenFile(tmpName, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+	defer r.root.Remove(tmpName) // no-op after a successful rename
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(mode); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return r.root.Rename(tmpName, name)
+}
+
+// SerializeOptions configures serialization behavior.
+type SerializeOptions struct {
+	FS  fs.FS // Filesystem to read/write (a WriteFS such as DirFS or RootFS, or fstest.MapFS)
+	VCS VCS   // Optional: VCS for listing files (required for DirFS in jj alternative workspaces)
+
+	WrapWidth    int  // Line width for comment text (0 means DefaultWrap)
+	EditorConfig bool // Use max_line_length from .editorconfig, overriding WrapWidth
+	NoReflow     bool // Store comment bodies verbatim instead of wrapping them
+	ASCII        bool // Write ASCII markers (|> |+ |) instead of box characters
+	FullScan     bool // Deserialize reads every file, not just indexed and changed ones
+	OutdatedFile bool // Collect outdated and resolved threads in PR-OUTDATED.txt
+	HideResolved bool // Leave resolved threads out of the files entirely
+	MarkChanges  bool // Add marker lines before lines changed since the PR base (needs VCS)
+
+	// CommentsAbove places threads above the line (or range) they're on,
+	// instead of below. Deserialize handles both either way.
+	CommentsAbove bool
+
+	// Originals maps comment node IDs to their bodies as fetched from GitHub.
+	// Deserialize returns these for comments whose local text is unchanged.
+	Originals map[string]string
+}
+
+// wrapWidth returns the line width to wrap comments at in the given file.
+func (o SerializeOptions) WidthFor(path string) int {
+	if o.EditorConfig {
+		if w := editorConfigMaxLineLength(o.FS, path); w > 0 {
+			return w
+		}
+	}
+	if o.WrapWidth > 0 {
+		return o.WrapWidth
+	}
+	return DefaultWrap
+}
+
+// boxes returns the markers to write craft comments with.
+func (o SerializeOptions) Boxes() BoxSet {
+	if o.ASCII {
+		return ASCIIBoxes
+	}
+	return UnicodeBoxes
+}
+
+// HasOutdatedFile reports whether PR-OUTDATED.txt exists, so a PR fetched
+// with --outdated-file keeps using it.
+func HasOutdatedFile(fsys fs.FS) bool {
+	_, err := fs.Stat(fsys, OutdatedFile)
+	return err == nil
+}
+
+// mapFSMu guards fstest.MapFS, which isn't safe for concurrent writes, while
+// files are (de)serialized in parallel.
+var mapFSMu sync.RWMutex
+
+// ReadFile reads a file from the filesystem.
+func ReadFile(fsys fs.FS, name string) ([]byte, error) {
+	if _, ok := fsys.(fstest.MapFS); ok {
+		mapFSMu.RLock()
+		defer mapFSMu.RUnlock()
+	}
+	return fs.ReadFile(fsys, name)
+}
+
+// WriteFile writes a file to the filesystem.
+func WriteFile(fsys fs.FS, name string, data []byte) error {
+	switch f := fsys.(type) {
+	case WriteFS:
+		return f.WriteFile(name, data)
+	case fstest.MapFS:
+		mapFSMu.Lock()
+		defer mapFSMu.Unlock()
+		f[name] = &fstest.MapFile{Data: data}
+		return nil
+	default:
+		return fmt.Errorf("unsupported filesystem type %T for writing", fsys)
+	}
+}
+
+// ListFiles returns all files to scan for comments.
+func ListFiles(opts SerializeOptions) ([]string, error) {
+	switch f := opts.FS.(type) {
+	case fstest.MapFS:
+		var files []string
+		for name := range f {
+			if !IsReviewFile(name) {
+				files = append(files, name)
+			}
+		}
+		sort.Strings(files)
+		return files, nil
+	}
+
+	// Use VCS.ListFiles if available (handles jj alternative workspaces)
+	if opts.VCS != nil {
+		return opts.VCS.ListFiles()
+	}
+	// Any other filesystem: walk it, skipping VCS metadata
+	var files []string
+	err := fs.WalkDir(opts.FS, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if name == ".git" || name == ".jj" || name == ".sl" {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if !IsReviewFile(name) {
+			files = append(files, name)
+		}
+		return nil
+	})
+	return files, err
+}
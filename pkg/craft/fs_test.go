@@ -0,0 +1,129 @@
+package craft
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDirFSWriteFile(t *testing.T) {
+	dir := t.TempDir()
+	fsys := DirFS(dir)
+
+	t.Run("preserves mode", func(t *testing.T) {
+		path := filepath.Join(dir, "script.sh")
+		require.NoError(t, os.WriteFile(path, []byte("old"), 0755))
+		require.NoError(t, os.Chmod(path, 0755)) // in case of umask
+
+		require.NoError(t, fsys.WriteFile("script.sh", []byte("new")))
+
+		data, err := os.ReadFile(path)
+		require.NoError(t, err)
+		assert.Equal(t, "new", string(data))
+		fi, err := os.Stat(path)
+		require.NoError(t, err)
+		assert.Equal(t, os.FileMode(0755), fi.Mode().Perm())
+	})
+
+	t.Run("new file", func(t *testing.T) {
+		require.NoError(t, fsys.WriteFile("new.txt", []byte("hello")))
+		fi, err := fsys.Stat("new.txt")
+		require.NoError(t, err)
+		assert.Equal(t, os.FileMode(0644), fi.Mode().Perm())
+	})
+
+	t.Run("follows symlinks", func(t *testing.T) {
+		target := filepath.Join(dir, "target.txt")
+		link := filepath.Join(dir, "link.txt")
+		require.NoError(t, os.WriteFile(target, []byte("old"), 0644))
+		require.NoError(t, os.Symlink("target.txt", link))
+
+		require.NoError(t, fsys.WriteFile("link.txt", []byte("new")))
+
+		fi, err := os.Lstat(link)
+		require.NoError(t, err)
+		assert.Equal(t, os.ModeSymlink, fi.Mode().Type())
+		data, err := os.ReadFile(target)
+		require.NoError(t, err)
+		assert.Equal(t, "new", string(data))
+	})
+
+	t.Run("can't escape the directory", func(t *testing.T) {
+		outside := filepath.Join(t.TempDir(), "outside.txt")
+		require.NoError(t, os.Symlink(outside, filepath.Join(dir, "escape.txt")))
+
+		assert.Error(t, fsys.WriteFile("../outside.txt", []byte("x")))
+		assert.Error(t, fsys.WriteFile("escape.txt", []byte("x")))
+		assert.NoFileExists(t, outside)
+	})
+
+	// No temp files left behind
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	assert.ElementsMatch(t, []string{"script.sh", "new.txt", "target.txt", "link.txt", "escape.txt"}, names)
+}
+
+func TestRootFSSerialize(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "src"), 0755))
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, ".git"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "src", "main.go"), []byte("package main\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, ".git", "config"), []byte("// ║ not craft\n"), 0644))
+
+	root, err := os.OpenRoot(dir)
+	require.NoError(t, err)
+	defer root.Close()
+	opts := SerializeOptions{FS: NewRootFS(root)}
+
+	files, err := ListFiles(opts)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"src/main.go"}, files)
+
+	pr := &PullRequest{
+		ID:         "PR_kwDOPgi5ks6k-agY",
+		HeadRefOID: "abc123",
+		ReviewThreads: []ReviewThread{{
+			Path: "src/main.go", Line: 1, DiffSide: DiffSideRight, SubjectType: SubjectTypeLine,
+			Comments: []ReviewComment{{IsNew: true, Body: "Hi"}},
+		}},
+	}
+	require.NoError(t, Serialize(pr, opts))
+
+	pr2, err := Deserialize(opts)
+	require.NoError(t, err)
+	require.Len(t, pr2.ReviewThreads, 1)
+	assert.Equal(t, "Hi", pr2.ReviewThreads[0].Comments[0].Body)
+
+	// Thread paths come from GitHub; they mustn't be able to escape
+	pr.ReviewThreads[0].Path = "../evil.go"
+	assert.Error(t, Serialize(pr, opts))
+	assert.NoFileExists(t, filepath.Join(filepath.Dir(dir), "evil.go"))
+}
+
+func TestDirFSSlashPaths(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "a", "b"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "a", "b", "c.txt"), []byte("hi"), 0644))
+	fsys := DirFS(dir)
+
+	data, err := ReadFile(fsys, "a/b/c.txt")
+	require.NoError(t, err)
+	assert.Equal(t, "hi", string(data))
+	_, err = fsys.Stat("a/b/c.txt")
+	assert.NoError(t, err)
+
+	for _, name := range []string{"../c.txt", "/a/b/c.txt", "a/../a/b/c.txt", ""} {
+		_, err := fsys.Open(name)
+		assert.ErrorIs(t, err, fs.ErrInvalid, name)
+		_, err = fsys.Stat(name)
+		assert.ErrorIs(t, err, fs.ErrInvalid, name)
+	}
+}
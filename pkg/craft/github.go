@@ -0,0 +1,77 @@
+package craft
+
+import (
+	"context"
+
+	"github.com/shurcooL/githubv4"
+)
+
+// GitHubAPI is what craft does on GitHub. GitHubClient is the real one;
+// tests use fakes, and another backend (a recording, an enterprise proxy)
+// can implement it outside craft.
+type GitHubAPI interface {
+	FetchPullRequest(ctx context.Context, owner, repo string, number int) (*PullRequest, error)
+	FetchPreviousBodies(ctx context.Context, pr *PullRequest) error
+	FindPRByBranch(ctx context.Context, owner, repo, branch string) (int, error)
+	FetchOpenPRs(ctx context.Context, owner, repo string) ([]OpenPR, error)
+	FetchPRHead(ctx context.Context, owner, repo string, number int) (head, state string, isDraft bool, err error)
+	FetchPRDraft(ctx context.Context, owner, repo string, number int) (id string, isDraft bool, err error)
+	FetchPRDescription(ctx context.Context, owner, repo string, number int) (*PRDescription, error)
+	FetchViewerLogin(ctx context.Context) (string, error)
+	FetchMentionableUsers(ctx context.Context, owner, repo string) ([]string, error)
+	FetchReviewCommentIDs(ctx context.Context, reviewID string) ([]string, error)
+
+	// Reviews and comments
+	GetPendingReview(ctx context.Context, prNodeID string) (githubv4.ID, bool, error)
+	DeletePendingReview(ctx context.Context, reviewID githubv4.ID) error
+	StartReviewWithThreads(ctx context.Context, prNodeID, commitOID string, threads []NewThreadInfo) (githubv4.ID, error)
+	AddFileThread(ctx context.Context, reviewID githubv4.ID, t NewThreadInfo) error
+	AddReviewComment(ctx context.Context, reviewID githubv4.ID, replyToNodeID, body string) (string, error)
+	SubmitReview(ctx context.Context, reviewID githubv4.ID, eventType, body string) error
+	UpdateReviewBody(ctx context.Context, reviewID githubv4.ID, body string) error
+	ResolveThread(ctx context.Context, threadID string) error
+	MarkReadyForReview(ctx context.Context, prNodeID string) error
+	ConvertToDraft(ctx context.Context, prNodeID string) error
+	UpdatePRDescription(ctx context.Context, prNodeID, title, body string) error
+	UpdateIssueComment(ctx context.Context, commentID, body string) error
+	DeleteIssueComment(ctx context.Context, commentID string) error
+	DeleteReviewComment(ctx context.Context, commentID string) error
+
+	// PostReview posts a whole review at once, for when GitHub refuses to
+	// start one: craft's GitHubClient goes through REST, which some tokens
+	// are allowed and the review mutations aren't.
+	PostReview(ctx context.Context, prNodeID, commitOID, event, body string, threads []NewThreadInfo, replies []ReplyInfo) error
+}
+
+// OpenPR is the number and title of an open PR, as listed by FetchOpenPRs.
+type OpenPR struct {
+	Number int    `json:"number"`
+	Title  string `json:"title"`
+}
+
+// PRDescription is a PR's title and body, and whether the viewer can edit
+// them: its author, or someone with write access.
+type PRDescription struct {
+	ID        string
+	Title     string
+	Body      string
+	CanUpdate bool
+}
+
+// NewThreadInfo is a new thread to start in a review.
+type NewThreadInfo struct {
+	Path      string
+	Line      int
+	StartLine *int // Start line for multi-line comments (nil for single line)
+	Side      DiffSide
+	Subject   SubjectType
+	Body      string
+}
+
+// ReplyInfo is a reply to an existing thread.
+type ReplyInfo struct {
+	ThreadPath    string
+	ThreadLine    int
+	Body          string
+	ReplyToNodeID string
+}
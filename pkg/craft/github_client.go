@@ -0,0 +1,1320 @@
+package craft
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"maps"
+	"net/http"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/shurcooL/githubv4"
+	"golang.org/x/oauth2"
+)
+
+// GitHubClient wraps the GitHub GraphQL client, and the REST API for the few
+// things some tokens can only do there (see github_rest.go).
+type GitHubClient struct {
+	client  *githubv4.Client
+	http    *http.Client
+	restURL string
+}
+
+var _ GitHubAPI = (*GitHubClient)(nil)
+
+// NewGitHubClient creates a client for github.com with the given token.
+func NewGitHubClient(token string) *GitHubClient {
+	src := oauth2.StaticTokenSource(&oauth2.Token{AccessToken: token})
+	return NewGitHubClientWithHTTP(oauth2.NewClient(context.Background(), src), "")
+}
+
+// NewGitHubClientWithHTTP creates a client that makes its requests with
+// httpClient, which adds the token. baseURL is where the API is, with the
+// GraphQL endpoint at baseURL/graphql; "" means github.com's.
+func NewGitHubClientWithHTTP(httpClient *http.Client, baseURL string) *GitHubClient {
+	baseURL = cmp.Or(strings.TrimSuffix(baseURL, "/"), gitHubAPIURL)
+	return &GitHubClient{
+		client:  githubv4.NewEnterpriseClient(baseURL+"/graphql", httpClient),
+		http:    httpClient,
+		restURL: baseURL,
+	}
+}
+
+// query runs a GraphQL query, adding a hint to errors craft knows how to fix.
+func (c *GitHubClient) query(ctx context.Context, q any, vars map[string]any) error {
+	return translateGitHubError(c.client.Query(ctx, q, vars))
+}
+
+// mutate runs a GraphQL mutation, adding a hint to errors craft knows how to
+// fix.
+func (c *GitHubClient) mutate(ctx context.Context, m any, input githubv4.Input, vars map[string]any) error {
+	return translateGitHubError(c.client.Mutate(ctx, m, input, vars))
+}
+
+// GraphQL response types for reuse across queries
+
+type gqlPageInfo struct {
+	HasNextPage githubv4.Boolean
+	EndCursor   githubv4.String
+}
+
+type gqlActor struct {
+	Login     githubv4.String
+	AvatarURL githubv4.URI `graphql:"avatarUrl"`
+	URL       githubv4.URI `graphql:"url"`
+}
+
+type gqlReviewComment struct {
+	ID         githubv4.ID
+	DatabaseID int64
+	Body       githubv4.String
+	CreatedAt  githubv4.DateTime
+	UpdatedAt  githubv4.DateTime
+	// LastEditedAt is set only by edits to the body, unlike UpdatedAt
+	LastEditedAt *githubv4.DateTime
+	Author       gqlActor
+	ReplyTo      struct {
+		DatabaseID int64
+	}
+	OriginalCommit *struct {
+		Oid githubv4.GitObjectID
+	}
+	DiffHunk githubv4.String
+}
+
+type gqlReviewThread struct {
+	ID                githubv4.ID
+	IsResolved        githubv4.Boolean
+	IsOutdated        githubv4.Boolean
+	Path              githubv4.String
+	DiffSide          githubv4.String
+	Line              githubv4.Int
+	StartLine         *githubv4.Int
+	OriginalLine      githubv4.Int
+	OriginalStartLine *githubv4.Int
+	SubjectType       githubv4.String
+	Comments          struct {
+		PageInfo gqlPageInfo
+		Nodes    []gqlReviewComment
+	} `graphql:"comments(first: 100)"`
+}
+
+type gqlIssueComment struct {
+	ID         githubv4.ID
+	DatabaseID int64
+	Body       githubv4.String
+	CreatedAt  githubv4.DateTime
+	UpdatedAt  githubv4.DateTime
+	// LastEditedAt is set only by edits to the body, unlike UpdatedAt
+	LastEditedAt *githubv4.DateTime
+	Author       gqlActor
+}
+
+type gqlReview struct {
+	ID          githubv4.ID
+	DatabaseID  int64
+	State       githubv4.String
+	Body        githubv4.String
+	SubmittedAt *githubv4.DateTime
+	CreatedAt   githubv4.DateTime
+	Author      gqlActor
+	Commit      *struct {
+		Oid githubv4.GitObjectID
+	}
+}
+
+type gqlCommit struct {
+	Commit struct {
+		Oid             githubv4.GitObjectID
+		MessageHeadline githubv4.String
+		CommittedDate   githubv4.DateTime
+		Author          struct {
+			Name githubv4.String
+			User *struct {
+				Login githubv4.String
+			}
+		}
+	}
+}
+
+type gqlForcePush struct {
+	HeadRefForcePushedEvent struct {
+		CreatedAt    githubv4.DateTime
+		Actor        *struct{ Login githubv4.String }
+		BeforeCommit *struct{ Oid githubv4.GitObjectID }
+		AfterCommit  *struct{ Oid githubv4.GitObjectID }
+	} `graphql:"... on HeadRefForcePushedEvent"`
+}
+
+// FetchPullRequest fetches all PR data including review threads, comments, and reviews.
+// Handles pagination for all collections.
+func (c *GitHubClient) FetchPullRequest(ctx context.Context, owner, repo string, number int) (*PullRequest, error) {
+	// Initial query for PR metadata and first page of everything
+	var prQuery struct {
+		Repository struct {
+			PullRequest struct {
+				ID            githubv4.ID
+				Number        githubv4.Int
+				Title         githubv4.String
+				Body          githubv4.String
+				State         githubv4.String
+				IsDraft       githubv4.Boolean
+				BaseRefName   githubv4.String
+				HeadRefName   githubv4.String
+				BaseRefOid    githubv4.GitObjectID
+				HeadRefOid    githubv4.GitObjectID
+				Author        gqlActor
+				ReviewThreads struct {
+					PageInfo gqlPageInfo
+					Nodes    []gqlReviewThread
+				} `graphql:"reviewThreads(first: 100)"`
+				Comments struct {
+					PageInfo gqlPageInfo
+					Nodes    []gqlIssueComment
+				} `graphql:"comments(first: 100)"`
+				Reviews struct {
+					PageInfo gqlPageInfo
+					Nodes    []gqlReview
+				} `graphql:"reviews(first: 100)"`
+				Commits struct {
+					PageInfo gqlPageInfo
+					Nodes    []gqlCommit
+				} `graphql:"commits(first: 100)"`
+				TimelineItems struct {
+					PageInfo gqlPageInfo
+					Nodes    []gqlForcePush
+				} `graphql:"timelineItems(first: 100, itemTypes: [HEAD_REF_FORCE_PUSHED_EVENT])"`
+			} `graphql:"pullRequest(number: $number)"`
+		} `graphql:"repository(owner: $owner, name: $name)"`
+	}
+
+	vars := map[string]interface{}{
+		"owner":  githubv4.String(owner),
+		"name":   githubv4.String(repo),
+		"number": githubv4.Int(number),
+	}
+
+	if err := c.query(ctx, &prQuery, vars); err != nil {
+		return nil, fmt.Errorf("GraphQL query failed: %w", err)
+	}
+
+	ghPR := prQuery.Repository.PullRequest
+
+	// Collect all nodes, paginating as needed
+	allThreads := ghPR.ReviewThreads.Nodes
+	allIssueComments := ghPR.Comments.Nodes
+	allReviews := ghPR.Reviews.Nodes
+	allCommits := ghPR.Commits.Nodes
+	allForcePushes := ghPR.TimelineItems.Nodes
+
+	// Paginate review threads
+	if ghPR.ReviewThreads.PageInfo.HasNextPage {
+		more, err := c.fetchAllReviewThreads(ctx, owner, repo, number, string(ghPR.ReviewThreads.PageInfo.EndCursor))
+		if err != nil {
+			return nil, err
+		}
+		allThreads = append(allThreads, more...)
+	}
+
+	// Paginate issue comments
+	if ghPR.Comments.PageInfo.HasNextPage {
+		more, err := c.fetchAllIssueComments(ctx, owner, repo, number, string(ghPR.Comments.PageInfo.EndCursor))
+		if err != nil {
+			return nil, err
+		}
+		allIssueComments = append(allIssueComments, more...)
+	}
+
+	// Paginate reviews
+	if ghPR.Reviews.PageInfo.HasNextPage {
+		more, err := c.fetchAllReviews(ctx, owner, repo, number, string(ghPR.Reviews.PageInfo.EndCursor))
+		if err != nil {
+			return nil, err
+		}
+		allReviews = append(allReviews, more...)
+	}
+
+	// Paginate commits and force pushes
+	if ghPR.Commits.PageInfo.HasNextPage {
+		more, err := c.fetchAllCommits(ctx, owner, repo, number, string(ghPR.Commits.PageInfo.EndCursor))
+		if err != nil {
+			return nil, err
+		}
+		allCommits = append(allCommits, more...)
+	}
+	if ghPR.TimelineItems.PageInfo.HasNextPage {
+		more, err := c.fetchAllForcePushes(ctx, owner, repo, number, string(ghPR.TimelineItems.PageInfo.EndCursor))
+		if err != nil {
+			return nil, err
+		}
+		allForcePushes = append(allForcePushes, more...)
+	}
+
+	// Convert to our model
+	pr := &PullRequest{
+		ID:            string(ghPR.ID.(string)),
+		Number:        int(ghPR.Number),
+		Title:         string(ghPR.Title),
+		Body:          string(ghPR.Body),
+		State:         string(ghPR.State),
+		IsDraft:       bool(ghPR.IsDraft),
+		BaseRefName:   string(ghPR.BaseRefName),
+		HeadRefName:   string(ghPR.HeadRefName),
+		BaseRefOID:    string(ghPR.BaseRefOid),
+		HeadRefOID:    string(ghPR.HeadRefOid),
+		LastFetchedAt: time.Now(),
+		Author:        convertActor(ghPR.Author),
+	}
+
+	// Check whether it's stacked on another PR
+	parent, parentOID, err := c.fetchStackParent(ctx, owner, repo, pr.BaseRefName)
+	if err != nil {
+		return nil, err
+	}
+	pr.StackParent, pr.StackParentOID = parent, parentOID
+
+	// Convert review threads, with the rest of their comments
+	cursors := make(map[string]string)
+	for _, t := range allThreads {
+		if t.Comments.PageInfo.HasNextPage {
+			cursors[t.ID.(string)] = string(t.Comments.PageInfo.EndCursor)
+		}
+	}
+	moreComments, err := c.fetchMoreThreadComments(ctx, cursors)
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range allThreads {
+		pr.ReviewThreads = append(pr.ReviewThreads, convertReviewThread(t, moreComments[t.ID.(string)]))
+	}
+
+	// Convert issue comments
+	for _, c := range allIssueComments {
+		pr.IssueComments = append(pr.IssueComments, convertIssueComment(c))
+	}
+
+	// Convert reviews
+	for _, r := range allReviews {
+		pr.Reviews = append(pr.Reviews, convertReview(r))
+	}
+
+	// Convert commits and force pushes
+	for _, c := range allCommits {
+		pr.Commits = append(pr.Commits, convertCommit(c))
+	}
+	for _, f := range allForcePushes {
+		pr.ForcePushes = append(pr.ForcePushes, convertForcePush(f))
+	}
+
+	return pr, nil
+}
+
+// fetchAllReviewThreads paginates through remaining review threads
+func (c *GitHubClient) fetchAllReviewThreads(ctx context.Context, owner, repo string, number int, cursor string) ([]gqlReviewThread, error) {
+	var result []gqlReviewThread
+
+	var query struct {
+		Repository struct {
+			PullRequest struct {
+				ReviewThreads struct {
+					PageInfo gqlPageInfo
+					Nodes    []gqlReviewThread
+				} `graphql:"reviewThreads(first: 100, after: $cursor)"`
+			} `graphql:"pullRequest(number: $number)"`
+		} `graphql:"repository(owner: $owner, name: $name)"`
+	}
+
+	for {
+		vars := map[string]interface{}{
+			"owner":  githubv4.String(owner),
+			"name":   githubv4.String(repo),
+			"number": githubv4.Int(number),
+			"cursor": githubv4.String(cursor),
+		}
+
+		if err := c.query(ctx, &query, vars); err != nil {
+			return nil, fmt.Errorf("fetching review threads page: %w", err)
+		}
+
+		result = append(result, query.Repository.PullRequest.ReviewThreads.Nodes...)
+
+		if !query.Repository.PullRequest.ReviewThreads.PageInfo.HasNextPage {
+			break
+		}
+		cursor = string(query.Repository.PullRequest.ReviewThreads.PageInfo.EndCursor)
+	}
+
+	return result, nil
+}
+
+// fetchStackParent finds the open PR, if any, whose head branch in this
+// repository is baseRefName, and returns its number and head OID. It returns
+// 0 if the PR isn't stacked on another one.
+func (c *GitHubClient) fetchStackParent(ctx context.Context, owner, repo, baseRefName string) (int, string, error) {
+	if baseRefName == "" {
+		return 0, "", nil
+	}
+
+	var query struct {
+		Repository struct {
+			PullRequests struct {
+				Nodes []struct {
+					Number            githubv4.Int
+					HeadRefOid        githubv4.GitObjectID
+					IsCrossRepository githubv4.Boolean
+				}
+			} `graphql:"pullRequests(headRefName: $head, states: OPEN, first: 10)"`
+		} `graphql:"repository(owner: $owner, name: $name)"`
+	}
+
+	vars := map[string]interface{}{
+		"owner": githubv4.String(owner),
+		"name":  githubv4.String(repo),
+		"head":  githubv4.String(baseRefName),
+	}
+
+	if err := c.query(ctx, &query, vars); err != nil {
+		return 0, "", fmt.Errorf("fetching stack parent: %w", err)
+	}
+
+	// A fork's branch can have the same name, but can't be our base
+	for _, node := range query.Repository.PullRequests.Nodes {
+		if !node.IsCrossRepository {
+			return int(node.Number), string(node.HeadRefOid), nil
+		}
+	}
+	return 0, "", nil
+}
+
+// FindPRByBranch returns the number of the open PR whose head branch is
+// branch. A PR from this repository is preferred to ones from forks, which
+// can use the same branch name.
+func (c *GitHubClient) FindPRByBranch(ctx context.Context, owner, repo, branch string) (int, error) {
+	var query struct {
+		Repository struct {
+			PullRequests struct {
+				Nodes []struct {
+					Number            githubv4.Int
+					IsCrossRepository githubv4.Boolean
+				}
+			} `graphql:"pullRequests(headRefName: $head, states: OPEN, first: 10)"`
+		} `graphql:"repository(owner: $owner, name: $name)"`
+	}
+
+	vars := map[string]interface{}{
+		"owner": githubv4.String(owner),
+		"name":  githubv4.String(repo),
+		"head":  githubv4.String(branch),
+	}
+
+	if err := c.query(ctx, &query, vars); err != nil {
+		return 0, fmt.Errorf("finding PR for branch %s: %w", branch, err)
+	}
+
+	var forks []string
+	for _, node := range query.Repository.PullRequests.Nodes {
+		if !node.IsCrossRepository {
+			return int(node.Number), nil
+		}
+		forks = append(forks, fmt.Sprintf("#%d", node.Number))
+	}
+	switch len(forks) {
+	case 0:
+		return 0, fmt.Errorf("no open PR for branch %s in %s/%s", branch, owner, repo)
+	case 1:
+		return int(query.Repository.PullRequests.Nodes[0].Number), nil
+	}
+	return 0, fmt.Errorf("branch %s has several open PRs from forks (%s); give the number", branch, strings.Join(forks, ", "))
+}
+
+// FetchOpenPRs returns the most recently updated open PRs, up to 100.
+func (c *GitHubClient) FetchOpenPRs(ctx context.Context, owner, repo string) ([]OpenPR, error) {
+	var query struct {
+		Repository struct {
+			PullRequests struct {
+				Nodes []struct {
+					Number githubv4.Int
+					Title  githubv4.String
+				}
+			} `graphql:"pullRequests(states: OPEN, first: 100, orderBy: {field: UPDATED_AT, direction: DESC})"`
+		} `graphql:"repository(owner: $owner, name: $name)"`
+	}
+
+	vars := map[string]interface{}{
+		"owner": githubv4.String(owner),
+		"name":  githubv4.String(repo),
+	}
+
+	if err := c.query(ctx, &query, vars); err != nil {
+		return nil, fmt.Errorf("fetching open PRs: %w", err)
+	}
+
+	var prs []OpenPR
+	for _, node := range query.Repository.PullRequests.Nodes {
+		prs = append(prs, OpenPR{Number: int(node.Number), Title: string(node.Title)})
+	}
+	return prs, nil
+}
+
+// FetchPRHead fetches just the current head OID, state and draft status of a
+// PR (lightweight check).
+func (c *GitHubClient) FetchPRHead(ctx context.Context, owner, repo string, number int) (head, state string, isDraft bool, err error) {
+	var query struct {
+		Repository struct {
+			PullRequest struct {
+				HeadRefOID githubv4.GitObjectID `graphql:"headRefOid"`
+				State      githubv4.String
+				IsDraft    githubv4.Boolean
+			} `graphql:"pullRequest(number: $number)"`
+		} `graphql:"repository(owner: $owner, name: $name)"`
+	}
+
+	vars := map[string]interface{}{
+		"owner":  githubv4.String(owner),
+		"name":   githubv4.String(repo),
+		"number": githubv4.Int(number),
+	}
+
+	if err := c.query(ctx, &query, vars); err != nil {
+		return "", "", false, fmt.Errorf("fetching PR head: %w", err)
+	}
+
+	pr := query.Repository.PullRequest
+	return string(pr.HeadRefOID), string(pr.State), bool(pr.IsDraft), nil
+}
+
+// FetchViewerLogin returns the login of the token's user.
+func (c *GitHubClient) FetchViewerLogin(ctx context.Context) (string, error) {
+	var query struct {
+		Viewer struct {
+			Login githubv4.String
+		}
+	}
+	if err := c.query(ctx, &query, nil); err != nil {
+		return "", fmt.Errorf("fetching user: %w", err)
+	}
+	return string(query.Viewer.Login), nil
+}
+
+// FetchPRDraft returns the node ID of a PR and whether it's a draft.
+func (c *GitHubClient) FetchPRDraft(ctx context.Context, owner, repo string, number int) (id string, isDraft bool, err error) {
+	var query struct {
+		Repository struct {
+			PullRequest struct {
+				ID      githubv4.ID
+				IsDraft githubv4.Boolean
+			} `graphql:"pullRequest(number: $number)"`
+		} `graphql:"repository(owner: $owner, name: $name)"`
+	}
+
+	vars := map[string]interface{}{
+		"owner":  githubv4.String(owner),
+		"name":   githubv4.String(repo),
+		"number": githubv4.Int(number),
+	}
+
+	if err := c.query(ctx, &query, vars); err != nil {
+		return "", false, fmt.Errorf("fetching PR: %w", err)
+	}
+
+	pr := query.Repository.PullRequest
+	id, _ = pr.ID.(string)
+	return id, bool(pr.IsDraft), nil
+}
+
+// FetchPRDescription returns the title and body of a PR.
+func (c *GitHubClient) FetchPRDescription(ctx context.Context, owner, repo string, number int) (*PRDescription, error) {
+	var query struct {
+		Repository struct {
+			PullRequest struct {
+				ID              githubv4.ID
+				Title           githubv4.String
+				Body            githubv4.String
+				ViewerCanUpdate githubv4.Boolean
+			} `graphql:"pullRequest(number: $number)"`
+		} `graphql:"repository(owner: $owner, name: $name)"`
+	}
+
+	vars := map[string]interface{}{
+		"owner":  githubv4.String(owner),
+		"name":   githubv4.String(repo),
+		"number": githubv4.Int(number),
+	}
+
+	if err := c.query(ctx, &query, vars); err != nil {
+		return nil, fmt.Errorf("fetching PR: %w", err)
+	}
+
+	pr := query.Repository.PullRequest
+	id, _ := pr.ID.(string)
+	return &PRDescription{
+		ID:        id,
+		Title:     string(pr.Title),
+		Body:      string(pr.Body),
+		CanUpdate: bool(pr.ViewerCanUpdate),
+	}, nil
+}
+
+// FetchMentionableUsers returns the logins of all users who can be @mentioned
+// in the repository (collaborators, org members, and participants).
+func (c *GitHubClient) FetchMentionableUsers(ctx context.Context, owner, repo string) ([]string, error) {
+	var result []string
+
+	var query struct {
+		Repository struct {
+			MentionableUsers struct {
+				PageInfo gqlPageInfo
+				Nodes    []struct {
+					Login githubv4.String
+				}
+			} `graphql:"mentionableUsers(first: 100, after: $cursor)"`
+		} `graphql:"repository(owner: $owner, name: $name)"`
+	}
+
+	vars := map[string]interface{}{
+		"owner":  githubv4.String(owner),
+		"name":   githubv4.String(repo),
+		"cursor": (*githubv4.String)(nil),
+	}
+
+	for {
+		if err := c.query(ctx, &query, vars); err != nil {
+			return nil, fmt.Errorf("fetching mentionable users: %w", err)
+		}
+
+		for _, u := range query.Repository.MentionableUsers.Nodes {
+			result = append(result, string(u.Login))
+		}
+
+		if !query.Repository.MentionableUsers.PageInfo.HasNextPage {
+			break
+		}
+		vars["cursor"] = githubv4.NewString(query.Repository.MentionableUsers.PageInfo.EndCursor)
+	}
+
+	return result, nil
+}
+
+// fetchAllIssueComments paginates through remaining issue comments
+func (c *GitHubClient) fetchAllIssueComments(ctx context.Context, owner, repo string, number int, cursor string) ([]gqlIssueComment, error) {
+	var result []gqlIssueComment
+
+	var query struct {
+		Repository struct {
+			PullRequest struct {
+				Comments struct {
+					PageInfo gqlPageInfo
+					Nodes    []gqlIssueComment
+				} `graphql:"comments(first: 100, after: $cursor)"`
+			} `graphql:"pullRequest(number: $number)"`
+		} `graphql:"repository(owner: $owner, name: $name)"`
+	}
+
+	for {
+		vars := map[string]interface{}{
+			"owner":  githubv4.String(owner),
+			"name":   githubv4.String(repo),
+			"number": githubv4.Int(number),
+			"cursor": githubv4.String(cursor),
+		}
+
+		if err := c.query(ctx, &query, vars); err != nil {
+			return nil, fmt.Errorf("fetching issue comments page: %w", err)
+		}
+
+		result = append(result, query.Repository.PullRequest.Comments.Nodes...)
+
+		if !query.Repository.PullRequest.Comments.PageInfo.HasNextPage {
+			break
+		}
+		cursor = string(query.Repository.PullRequest.Comments.PageInfo.EndCursor)
+	}
+
+	return result, nil
+}
+
+// fetchAllReviews paginates through remaining reviews
+func (c *GitHubClient) fetchAllReviews(ctx context.Context, owner, repo string, number int, cursor string) ([]gqlReview, error) {
+	var result []gqlReview
+
+	var query struct {
+		Repository struct {
+			PullRequest struct {
+				Reviews struct {
+					PageInfo gqlPageInfo
+					Nodes    []gqlReview
+				} `graphql:"reviews(first: 100, after: $cursor)"`
+			} `graphql:"pullRequest(number: $number)"`
+		} `graphql:"repository(owner: $owner, name: $name)"`
+	}
+
+	for {
+		vars := map[string]interface{}{
+			"owner":  githubv4.String(owner),
+			"name":   githubv4.String(repo),
+			"number": githubv4.Int(number),
+			"cursor": githubv4.String(cursor),
+		}
+
+		if err := c.query(ctx, &query, vars); err != nil {
+			return nil, fmt.Errorf("fetching reviews page: %w", err)
+		}
+
+		result = append(result, query.Repository.PullRequest.Reviews.Nodes...)
+
+		if !query.Repository.PullRequest.Reviews.PageInfo.HasNextPage {
+			break
+		}
+		cursor = string(query.Repository.PullRequest.Reviews.PageInfo.EndCursor)
+	}
+
+	return result, nil
+}
+
+// fetchAllCommits paginates through remaining commits
+func (c *GitHubClient) fetchAllCommits(ctx context.Context, owner, repo string, number int, cursor string) ([]gqlCommit, error) {
+	var result []gqlCommit
+
+	var query struct {
+		Repository struct {
+			PullRequest struct {
+				Commits struct {
+					PageInfo gqlPageInfo
+					Nodes    []gqlCommit
+				} `graphql:"commits(first: 100, after: $cursor)"`
+			} `graphql:"pullRequest(number: $number)"`
+		} `graphql:"repository(owner: $owner, name: $name)"`
+	}
+
+	for {
+		vars := map[string]interface{}{
+			"owner":  githubv4.String(owner),
+			"name":   githubv4.String(repo),
+			"number": githubv4.Int(number),
+			"cursor": githubv4.String(cursor),
+		}
+
+		if err := c.query(ctx, &query, vars); err != nil {
+			return nil, fmt.Errorf("fetching commits page: %w", err)
+		}
+
+		result = append(result, query.Repository.PullRequest.Commits.Nodes...)
+
+		if !query.Repository.PullRequest.Commits.PageInfo.HasNextPage {
+			break
+		}
+		cursor = string(query.Repository.PullRequest.Commits.PageInfo.EndCursor)
+	}
+
+	return result, nil
+}
+
+// fetchAllForcePushes paginates through remaining force push events
+func (c *GitHubClient) fetchAllForcePushes(ctx context.Context, owner, repo string, number int, cursor string) ([]gqlForcePush, error) {
+	var result []gqlForcePush
+
+	var query struct {
+		Repository struct {
+			PullRequest struct {
+				TimelineItems struct {
+					PageInfo gqlPageInfo
+					Nodes    []gqlForcePush
+				} `graphql:"timelineItems(first: 100, after: $cursor, itemTypes: [HEAD_REF_FORCE_PUSHED_EVENT])"`
+			} `graphql:"pullRequest(number: $number)"`
+		} `graphql:"repository(owner: $owner, name: $name)"`
+	}
+
+	for {
+		vars := map[string]interface{}{
+			"owner":  githubv4.String(owner),
+			"name":   githubv4.String(repo),
+			"number": githubv4.Int(number),
+			"cursor": githubv4.String(cursor),
+		}
+
+		if err := c.query(ctx, &query, vars); err != nil {
+			return nil, fmt.Errorf("fetching force pushes page: %w", err)
+		}
+
+		result = append(result, query.Repository.PullRequest.TimelineItems.Nodes...)
+
+		if !query.Repository.PullRequest.TimelineItems.PageInfo.HasNextPage {
+			break
+		}
+		cursor = string(query.Repository.PullRequest.TimelineItems.PageInfo.EndCursor)
+	}
+
+	return result, nil
+}
+
+// gqlCommentsPage is a page of a thread's comments.
+type gqlCommentsPage struct {
+	PageInfo gqlPageInfo
+	Nodes    []gqlReviewComment
+}
+
+// threadCommentsBatch is how many threads fetchMoreThreadComments pages
+// through in one query.
+const threadCommentsBatch = 50
+
+// fetchMoreThreadComments fetches the rest of the comments of the threads in
+// cursors, which maps thread IDs to the end cursor of their first page, and
+// returns them by thread ID. It pages through up to threadCommentsBatch
+// threads per query. nodes(ids:) can't take a cursor per thread, so each
+// thread is an aliased node field, of a query type built for the batch.
+func (c *GitHubClient) fetchMoreThreadComments(ctx context.Context, cursors map[string]string) (map[string][]gqlReviewComment, error) {
+	result := make(map[string][]gqlReviewComment)
+	pending := slices.Sorted(maps.Keys(cursors))
+	for len(pending) > 0 {
+		batch := pending[:min(len(pending), threadCommentsBatch)]
+		pending = pending[len(batch):]
+
+		var fields []reflect.StructField
+		vars := make(map[string]interface{})
+		for i, id := range batch {
+			thread := reflect.StructOf([]reflect.StructField{{
+				Name: "Comments",
+				Type: reflect.TypeOf(gqlCommentsPage{}),
+				Tag:  reflect.StructTag(fmt.Sprintf(`graphql:"comments(first: 100, after: $cursor%d)"`, i)),
+			}})
+			node := reflect.StructOf([]reflect.StructField{{
+				Name: "PullRequestReviewThread",
+				Type: thread,
+				Tag:  `graphql:"... on PullRequestReviewThread"`,
+			}})
+			fields = append(fields, reflect.StructField{
+				Name: fmt.Sprintf("T%d", i),
+				Type: node,
+				Tag:  reflect.StructTag(fmt.Sprintf(`graphql:"t%d: node(id: $id%d)"`, i, i)),
+			})
+			vars[fmt.Sprintf("id%d", i)] = githubv4.ID(id)
+			vars[fmt.Sprintf("cursor%d", i)] = githubv4.String(cursors[id])
+		}
+		query := reflect.New(reflect.StructOf(fields))
+		if err := c.query(ctx, query.Interface(), vars); err != nil {
+			return nil, fmt.Errorf("fetching thread comments page: %w", err)
+		}
+
+		for i, id := range batch {
+			page := query.Elem().Field(i).Field(0).Field(0).Interface().(gqlCommentsPage)
+			result[id] = append(result[id], page.Nodes...)
+			if page.PageInfo.HasNextPage {
+				cursors[id] = string(page.PageInfo.EndCursor)
+				pending = append(pending, id)
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// FetchPreviousBodies sets the PreviousBody of each edited review comment in
+// pr from its edit history, with a query per comment.
+func (c *GitHubClient) FetchPreviousBodies(ctx context.Context, pr *PullRequest) error {
+	var query struct {
+		Node struct {
+			PullRequestReviewComment struct {
+				// Newest first; the first is the current body
+				UserContentEdits struct {
+					Nodes []struct {
+						Diff *githubv4.String
+					}
+				} `graphql:"userContentEdits(first: 2)"`
+			} `graphql:"... on PullRequestReviewComment"`
+		} `graphql:"node(id: $id)"`
+	}
+
+	for i := range pr.ReviewThreads {
+		for j := range pr.ReviewThreads[i].Comments {
+			comment := &pr.ReviewThreads[i].Comments[j]
+			if !comment.IsEdited || comment.ID == "" {
+				continue
+			}
+			query.Node.PullRequestReviewComment.UserContentEdits.Nodes = nil
+			vars := map[string]interface{}{
+				"id": githubv4.ID(comment.ID),
+			}
+			if err := c.query(ctx, &query, vars); err != nil {
+				return fmt.Errorf("fetching edits of %s: %w", comment.ID, err)
+			}
+			edits := query.Node.PullRequestReviewComment.UserContentEdits.Nodes
+			if len(edits) > 1 && edits[1].Diff != nil {
+				comment.PreviousBody = string(*edits[1].Diff)
+			}
+		}
+	}
+	return nil
+}
+
+// convertReviewThread converts a GraphQL thread to our model, with more, the
+// comments past its first page.
+func convertReviewThread(t gqlReviewThread, more []gqlReviewComment) ReviewThread {
+	thread := ReviewThread{
+		ID:           string(t.ID.(string)),
+		Path:         string(t.Path),
+		DiffSide:     DiffSide(t.DiffSide),
+		Line:         int(t.Line),
+		OriginalLine: int(t.OriginalLine),
+		IsOutdated:   bool(t.IsOutdated),
+		IsResolved:   bool(t.IsResolved),
+		SubjectType:  SubjectType(t.SubjectType),
+	}
+	if t.StartLine != nil {
+		sl := int(*t.StartLine)
+		thread.StartLine = &sl
+	}
+	if t.OriginalStartLine != nil {
+		osl := int(*t.OriginalStartLine)
+		thread.OriginalStartLine = &osl
+	}
+
+	allComments := append(t.Comments.Nodes, more...)
+
+	for _, c := range allComments {
+		thread.Comments = append(thread.Comments, convertReviewComment(c))
+	}
+	if len(allComments) > 0 {
+		if allComments[0].OriginalCommit != nil {
+			thread.OriginalCommitOID = string(allComments[0].OriginalCommit.Oid)
+		}
+		thread.DiffHunk = string(allComments[0].DiffHunk)
+	}
+
+	return thread
+}
+
+// Conversion helpers
+
+func convertActor(a gqlActor) Actor {
+	return Actor{
+		Login:     string(a.Login),
+		AvatarURL: a.AvatarURL.String(),
+		URL:       a.URL.String(),
+	}
+}
+
+func convertReviewComment(c gqlReviewComment) ReviewComment {
+	comment := ReviewComment{
+		ID:         string(c.ID.(string)),
+		DatabaseID: c.DatabaseID,
+		Body:       string(c.Body),
+		CreatedAt:  c.CreatedAt.Time,
+		UpdatedAt:  c.UpdatedAt.Time,
+		IsEdited:   c.LastEditedAt != nil,
+		Author:     convertActor(c.Author),
+	}
+	if c.ReplyTo.DatabaseID != 0 {
+		rid := fmt.Sprintf("%d", c.ReplyTo.DatabaseID)
+		comment.ReplyToID = &rid
+	}
+	return comment
+}
+
+func convertIssueComment(c gqlIssueComment) IssueComment {
+	return IssueComment{
+		ID:         string(c.ID.(string)),
+		DatabaseID: c.DatabaseID,
+		Body:       string(c.Body),
+		CreatedAt:  c.CreatedAt.Time,
+		UpdatedAt:  c.UpdatedAt.Time,
+		IsEdited:   c.LastEditedAt != nil,
+		Author:     convertActor(c.Author),
+	}
+}
+
+func convertCommit(c gqlCommit) Commit {
+	commit := Commit{
+		OID:         string(c.Commit.Oid),
+		Author:      string(c.Commit.Author.Name),
+		Headline:    string(c.Commit.MessageHeadline),
+		CommittedAt: c.Commit.CommittedDate.Time,
+	}
+	if u := c.Commit.Author.User; u != nil && u.Login != "" {
+		commit.Author, commit.HasLogin = string(u.Login), true
+	}
+	return commit
+}
+
+func convertForcePush(f gqlForcePush) ForcePush {
+	e := f.HeadRefForcePushedEvent
+	push := ForcePush{CreatedAt: e.CreatedAt.Time}
+	if e.Actor != nil {
+		push.Actor = string(e.Actor.Login)
+	}
+	if e.BeforeCommit != nil {
+		push.BeforeOID = string(e.BeforeCommit.Oid)
+	}
+	if e.AfterCommit != nil {
+		push.AfterOID = string(e.AfterCommit.Oid)
+	}
+	return push
+}
+
+func convertReview(r gqlReview) Review {
+	review := Review{
+		ID:         string(r.ID.(string)),
+		DatabaseID: r.DatabaseID,
+		State:      ReviewState(r.State),
+		Body:       string(r.Body),
+		CreatedAt:  r.CreatedAt.Time,
+		Author:     convertActor(r.Author),
+	}
+	if r.SubmittedAt != nil {
+		t := r.SubmittedAt.Time
+		review.SubmittedAt = &t
+	}
+	if r.Commit != nil {
+		review.CommitOID = string(r.Commit.Oid)
+	}
+	return review
+}
+
+// AddReviewComment adds a reply comment to a pending review.
+func (c *GitHubClient) AddReviewComment(ctx context.Context, reviewID githubv4.ID, replyToNodeID, body string) (string, error) {
+	var mutation struct {
+		AddPullRequestReviewComment struct {
+			Comment struct {
+				ID githubv4.ID
+			}
+		} `graphql:"addPullRequestReviewComment(input: $input)"`
+	}
+
+	bodyVal := githubv4.String(body)
+	replyToID := githubv4.ID(replyToNodeID)
+
+	input := githubv4.AddPullRequestReviewCommentInput{
+		PullRequestReviewID: &reviewID,
+		Body:                &bodyVal,
+		InReplyTo:           &replyToID,
+	}
+
+	if err := c.mutate(ctx, &mutation, input, nil); err != nil {
+		return "", fmt.Errorf("addPullRequestReviewComment mutation failed: %w", err)
+	}
+
+	return string(mutation.AddPullRequestReviewComment.Comment.ID.(string)), nil
+}
+
+// GetPendingReview checks if there's an existing pending review.
+// Returns the review ID (if any), whether one exists, and any error.
+func (c *GitHubClient) GetPendingReview(ctx context.Context, prNodeID string) (githubv4.ID, bool, error) {
+	var query struct {
+		Node struct {
+			PullRequest struct {
+				Reviews struct {
+					Nodes []struct {
+						ID githubv4.ID
+					}
+				} `graphql:"reviews(first: 1, states: PENDING)"`
+			} `graphql:"... on PullRequest"`
+		} `graphql:"node(id: $id)"`
+	}
+
+	vars := map[string]interface{}{
+		"id": githubv4.ID(prNodeID),
+	}
+
+	if err := c.query(ctx, &query, vars); err != nil {
+		return nil, false, fmt.Errorf("checking for pending review: %w", err)
+	}
+
+	if len(query.Node.PullRequest.Reviews.Nodes) > 0 {
+		return query.Node.PullRequest.Reviews.Nodes[0].ID, true, nil
+	}
+
+	return nil, false, nil
+}
+
+// DeletePendingReview deletes a pending review.
+func (c *GitHubClient) DeletePendingReview(ctx context.Context, reviewID githubv4.ID) error {
+	var mutation struct {
+		DeletePullRequestReview struct {
+			PullRequestReview struct {
+				ID githubv4.ID
+			}
+		} `graphql:"deletePullRequestReview(input: $input)"`
+	}
+
+	input := githubv4.DeletePullRequestReviewInput{
+		PullRequestReviewID: reviewID,
+	}
+
+	return c.mutate(ctx, &mutation, input, nil)
+}
+
+// MarkReadyForReview takes a PR out of draft.
+func (c *GitHubClient) MarkReadyForReview(ctx context.Context, prNodeID string) error {
+	var mutation struct {
+		MarkPullRequestReadyForReview struct {
+			PullRequest struct {
+				IsDraft githubv4.Boolean
+			}
+		} `graphql:"markPullRequestReadyForReview(input: $input)"`
+	}
+
+	input := githubv4.MarkPullRequestReadyForReviewInput{
+		PullRequestID: githubv4.ID(prNodeID),
+	}
+
+	return c.mutate(ctx, &mutation, input, nil)
+}
+
+// UpdatePRDescription sets the title and body of a PR.
+func (c *GitHubClient) UpdatePRDescription(ctx context.Context, prNodeID, title, body string) error {
+	var mutation struct {
+		UpdatePullRequest struct {
+			PullRequest struct {
+				ID githubv4.ID
+			}
+		} `graphql:"updatePullRequest(input: $input)"`
+	}
+
+	t, b := githubv4.String(title), githubv4.String(body)
+	input := githubv4.UpdatePullRequestInput{
+		PullRequestID: githubv4.ID(prNodeID),
+		Title:         &t,
+		Body:          &b,
+	}
+
+	return c.mutate(ctx, &mutation, input, nil)
+}
+
+// UpdateIssueComment sets the body of a PR-level comment.
+func (c *GitHubClient) UpdateIssueComment(ctx context.Context, commentID, body string) error {
+	var mutation struct {
+		UpdateIssueComment struct {
+			IssueComment struct {
+				ID githubv4.ID
+			}
+		} `graphql:"updateIssueComment(input: $input)"`
+	}
+
+	input := githubv4.UpdateIssueCommentInput{
+		ID:   githubv4.ID(commentID),
+		Body: githubv4.String(body),
+	}
+
+	return c.mutate(ctx, &mutation, input, nil)
+}
+
+// FetchReviewCommentIDs returns the node IDs of the comments in a review,
+// threads and replies alike.
+func (c *GitHubClient) FetchReviewCommentIDs(ctx context.Context, reviewID string) ([]string, error) {
+	var query struct {
+		Node struct {
+			PullRequestReview struct {
+				Comments struct {
+					PageInfo gqlPageInfo
+					Nodes    []struct {
+						ID githubv4.ID
+					}
+				} `graphql:"comments(first: 100, after: $cursor)"`
+			} `graphql:"... on PullRequestReview"`
+		} `graphql:"node(id: $id)"`
+	}
+
+	var ids []string
+	var cursor *githubv4.String
+	for {
+		vars := map[string]interface{}{
+			"id":     githubv4.ID(reviewID),
+			"cursor": cursor,
+		}
+
+		if err := c.query(ctx, &query, vars); err != nil {
+			return nil, fmt.Errorf("fetching review comments: %w", err)
+		}
+
+		comments := query.Node.PullRequestReview.Comments
+		for _, n := range comments.Nodes {
+			ids = append(ids, n.ID.(string))
+		}
+		if !comments.PageInfo.HasNextPage {
+			break
+		}
+		cursor = githubv4.NewString(comments.PageInfo.EndCursor)
+	}
+	return ids, nil
+}
+
+// DeleteReviewComment deletes a comment in a review.
+func (c *GitHubClient) DeleteReviewComment(ctx context.Context, commentID string) error {
+	var mutation struct {
+		DeletePullRequestReviewComment struct {
+			ClientMutationID *githubv4.String
+		} `graphql:"deletePullRequestReviewComment(input: $input)"`
+	}
+
+	input := githubv4.DeletePullRequestReviewCommentInput{
+		ID: githubv4.ID(commentID),
+	}
+
+	return c.mutate(ctx, &mutation, input, nil)
+}
+
+// UpdateReviewBody sets the body of a review, pending or submitted.
+func (c *GitHubClient) UpdateReviewBody(ctx context.Context, reviewID githubv4.ID, body string) error {
+	var mutation struct {
+		UpdatePullRequestReview struct {
+			PullRequestReview struct {
+				ID githubv4.ID
+			}
+		} `graphql:"updatePullRequestReview(input: $input)"`
+	}
+
+	input := githubv4.UpdatePullRequestReviewInput{
+		PullRequestReviewID: reviewID,
+		Body:                githubv4.String(body),
+	}
+
+	return c.mutate(ctx, &mutation, input, nil)
+}
+
+// DeleteIssueComment deletes a PR-level comment.
+func (c *GitHubClient) DeleteIssueComment(ctx context.Context, commentID string) error {
+	var mutation struct {
+		DeleteIssueComment struct {
+			ClientMutationID *githubv4.String
+		} `graphql:"deleteIssueComment(input: $input)"`
+	}
+
+	input := githubv4.DeleteIssueCommentInput{
+		ID: githubv4.ID(commentID),
+	}
+
+	return c.mutate(ctx, &mutation, input, nil)
+}
+
+// ConvertToDraft makes a PR a draft.
+func (c *GitHubClient) ConvertToDraft(ctx context.Context, prNodeID string) error {
+	var mutation struct {
+		ConvertPullRequestToDraft struct {
+			PullRequest struct {
+				IsDraft githubv4.Boolean
+			}
+		} `graphql:"convertPullRequestToDraft(input: $input)"`
+	}
+
+	input := githubv4.ConvertPullRequestToDraftInput{
+		PullRequestID: githubv4.ID(prNodeID),
+	}
+
+	return c.mutate(ctx, &mutation, input, nil)
+}
+
+// ResolveThread marks a review thread resolved.
+func (c *GitHubClient) ResolveThread(ctx context.Context, threadID string) error {
+	var mutation struct {
+		ResolveReviewThread struct {
+			Thread struct {
+				ID githubv4.ID
+			}
+		} `graphql:"resolveReviewThread(input: $input)"`
+	}
+
+	input := githubv4.ResolveReviewThreadInput{
+		ThreadID: githubv4.ID(threadID),
+	}
+
+	return c.mutate(ctx, &mutation, input, nil)
+}
+
+// StartReviewWithThreads creates a new pending review with threads and returns its ID.
+// This works around a GitHub bug where adding threads to an existing review fails silently.
+func (c *GitHubClient) StartReviewWithThreads(ctx context.Context, prNodeID, commitOID string, threads []NewThreadInfo) (githubv4.ID, error) {
+	var mutation struct {
+		AddPullRequestReview struct {
+			PullRequestReview struct {
+				ID githubv4.ID
+			}
+		} `graphql:"addPullRequestReview(input: $input)"`
+	}
+
+	prID := githubv4.ID(prNodeID)
+	commit := githubv4.GitObjectID(commitOID)
+
+	input := githubv4.AddPullRequestReviewInput{
+		PullRequestID: &prID,
+		CommitOID:     &commit,
+	}
+
+	// Add threads if provided (file-level threads are added by addFileThread)
+	threads = slices.DeleteFunc(slices.Clone(threads), func(t NewThreadInfo) bool {
+		return t.Subject == SubjectTypeFile
+	})
+	if len(threads) > 0 {
+		draftThreads := make([]*githubv4.DraftPullRequestReviewThread, len(threads))
+		for i, t := range threads {
+			line := githubv4.Int(t.Line)
+			side := githubv4.DiffSide(t.Side)
+			dt := &githubv4.DraftPullRequestReviewThread{
+				Path: githubv4.String(t.Path),
+				Line: line,
+				Body: githubv4.String(t.Body),
+				Side: &side,
+			}
+			if t.StartLine != nil {
+				startLine := githubv4.Int(*t.StartLine)
+				dt.StartLine = &startLine
+			}
+			draftThreads[i] = dt
+		}
+		input.Threads = &draftThreads
+	}
+
+	if err := c.mutate(ctx, &mutation, input, nil); err != nil {
+		return nil, err
+	}
+
+	return mutation.AddPullRequestReview.PullRequestReview.ID, nil
+}
+
+// AddFileThread adds a file-level thread to a pending review.
+func (c *GitHubClient) AddFileThread(ctx context.Context, reviewID githubv4.ID, t NewThreadInfo) error {
+	var mutation struct {
+		AddPullRequestReviewThread struct {
+			Thread struct {
+				ID githubv4.ID
+			}
+		} `graphql:"addPullRequestReviewThread(input: $input)"`
+	}
+
+	subject := githubv4.PullRequestReviewThreadSubjectTypeFile
+	input := githubv4.AddPullRequestReviewThreadInput{
+		PullRequestReviewID: &reviewID,
+		Path:                githubv4.String(t.Path),
+		Body:                githubv4.String(t.Body),
+		SubjectType:         &subject,
+	}
+
+	return c.mutate(ctx, &mutation, input, nil)
+}
+
+// SubmitReview submits a pending review with the given event type (COMMENT, APPROVE, REQUEST_CHANGES).
+// The body is optional and becomes the top-level review comment.
+func (c *GitHubClient) SubmitReview(ctx context.Context, reviewID githubv4.ID, eventType, body string) error {
+	var mutation struct {
+		SubmitPullRequestReview struct {
+			PullRequestReview struct {
+				ID githubv4.ID
+			}
+		} `graphql:"submitPullRequestReview(input: $input)"`
+	}
+
+	event := githubv4.PullRequestReviewEvent(eventType)
+
+	input := githubv4.SubmitPullRequestReviewInput{
+		PullRequestReviewID: &reviewID,
+		Event:               event,
+	}
+
+	if body != "" {
+		bodyVal := githubv4.String(body)
+		input.Body = &bodyVal
+	}
+
+	return c.mutate(ctx, &mutation, input, nil)
+}
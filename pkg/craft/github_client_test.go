@@ -0,0 +1,78 @@
+package craft
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/shurcooL/githubv4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFetchMoreThreadComments(t *testing.T) {
+	// Thread A has two more pages, thread B one
+	pages := map[string][]string{
+		"A": {`{"pageInfo":{"hasNextPage":true,"endCursor":"a2"},"nodes":[{"id":"a2"}]}`, `{"pageInfo":{"hasNextPage":false},"nodes":[{"id":"a3"}]}`},
+		"B": {`{"pageInfo":{"hasNextPage":false},"nodes":[{"id":"b2"}]}`},
+	}
+	var queries int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		queries++
+		var body struct {
+			Query     string
+			Variables map[string]string
+		}
+		require.NoError(t, json.NewDecoder(req.Body).Decode(&body))
+		var fields []string
+		for i := 0; body.Variables[fmt.Sprintf("id%d", i)] != ""; i++ {
+			id := body.Variables[fmt.Sprintf("id%d", i)]
+			assert.Contains(t, body.Query, fmt.Sprintf("t%d: node(id: $id%d)", i, i))
+			fields = append(fields, fmt.Sprintf(`"t%d":{"comments":%s}`, i, pages[id][0]))
+			pages[id] = pages[id][1:]
+		}
+		io.WriteString(w, `{"data":{`+strings.Join(fields, ",")+`}}`)
+	}))
+	defer srv.Close()
+	client := &GitHubClient{client: githubv4.NewEnterpriseClient(srv.URL, srv.Client())}
+
+	more, err := client.fetchMoreThreadComments(t.Context(), map[string]string{"A": "a1", "B": "b1"})
+	require.NoError(t, err)
+	assert.Equal(t, 2, queries)
+	ids := func(comments []gqlReviewComment) (ids []string) {
+		for _, c := range comments {
+			ids = append(ids, c.ID.(string))
+		}
+		return ids
+	}
+	assert.Equal(t, []string{"a2", "a3"}, ids(more["A"]))
+	assert.Equal(t, []string{"b2"}, ids(more["B"]))
+}
+
+func TestFetchReviewCommentIDsPages(t *testing.T) {
+	pages := []string{
+		`{"pageInfo":{"hasNextPage":true,"endCursor":"c1"},"nodes":[{"id":"PRRC_1"},{"id":"PRRC_2"}]}`,
+		`{"pageInfo":{"hasNextPage":false},"nodes":[{"id":"PRRC_3"}]}`,
+	}
+	var cursors []any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		var body struct {
+			Variables map[string]any
+		}
+		require.NoError(t, json.NewDecoder(req.Body).Decode(&body))
+		cursors = append(cursors, body.Variables["cursor"])
+		io.WriteString(w, `{"data":{"node":{"comments":`+pages[0]+`}}}`)
+		pages = pages[1:]
+	}))
+	defer srv.Close()
+	client := &GitHubClient{client: githubv4.NewEnterpriseClient(srv.URL, srv.Client())}
+
+	ids, err := client.FetchReviewCommentIDs(t.Context(), "PRR_x")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"PRRC_1", "PRRC_2", "PRRC_3"}, ids)
+	assert.Equal(t, []any{nil, "c1"}, cursors)
+}
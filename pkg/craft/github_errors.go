@@ -0,0 +1,80 @@
+package craft
+
+import (
+	"errors"
+	"strings"
+)
+
+// gitHubError is an error from the GitHub API with a hint on how to fix it.
+type gitHubError struct {
+	err  error
+	hint string
+}
+
+func (e *gitHubError) Error() string {
+	return e.err.Error() + "\n" + e.hint
+}
+
+func (e *gitHubError) Unwrap() error { return e.err }
+
+// gitHubErrorHints maps text in GitHub's error messages to what to do about
+// them. githubv4 only keeps the message of a GraphQL error, not its type, so
+// the messages are all there is to go on.
+var gitHubErrorHints = []struct {
+	match []string
+	hint  string
+}{
+	{
+		[]string{"401 Unauthorized", "Bad credentials"},
+		"The GitHub token is invalid or expired. Run 'gh auth login', or save a new token with\n" +
+			"'craft login --stdin', and check GH_TOKEN and GITHUB_TOKEN aren't set to an old one.",
+	},
+	{
+		[]string{"SAML enforcement", "SAML SSO"},
+		"The organization requires SAML single sign-on. Authorize your token for it: for a\n" +
+			"personal access token, use \"Configure SSO\" at https://github.com/settings/tokens;\n" +
+			"for gh's token, run 'gh auth refresh'.",
+	},
+	{
+		[]string{"required scopes", "INSUFFICIENT_SCOPES"},
+		"The token is missing a scope craft needs. Run 'gh auth refresh -s repo', or use a\n" +
+			"classic token with the repo scope.",
+	},
+	{
+		[]string{"Resource not accessible by integration"},
+		"The token is a GitHub App or Actions token without access to this. In Actions, give\n" +
+			"the workflow 'permissions: pull-requests: write'; otherwise use a personal token.",
+	},
+	{
+		[]string{"Resource not accessible by personal access token"},
+		"The fine-grained token lacks a permission. Give it read and write access to\n" +
+			"\"Pull requests\" and read access to \"Contents\" on this repository.",
+	},
+	{
+		[]string{"Could not resolve to a PullRequest"},
+		"Check the PR number, and that it's in the repo craft uses (the remoteName setting,\n" +
+			"origin by default).",
+	},
+	{
+		[]string{"Could not resolve to a Repository"},
+		"The repository wasn't found, or the token can't see it. Check the remoteName setting\n" +
+			"(origin by default), and that the token has access to private repositories.",
+	},
+}
+
+// translateGitHubError adds a hint to err if it's a GitHub error craft
+// knows how to fix, and otherwise returns it as is.
+func translateGitHubError(err error) error {
+	if err == nil || errors.As(err, new(*gitHubError)) {
+		return err
+	}
+	msg := err.Error()
+	for _, h := range gitHubErrorHints {
+		for _, m := range h.match {
+			if strings.Contains(msg, m) {
+				return &gitHubError{err: err, hint: h.hint}
+			}
+		}
+	}
+	return err
+}
@@ -0,0 +1,38 @@
+package craft
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTranslateGitHubError(t *testing.T) {
+	tests := []struct {
+		err  string
+		hint string
+	}{
+		{`non-200 OK status code: 401 Unauthorized body: "{\"message\":\"Bad credentials\"}"`, "craft login --stdin"},
+		{"Resource protected by organization SAML enforcement. You must grant your Personal Access token access to this organization.", "Configure SSO"},
+		{"Your token has not been granted the required scopes to execute this query.", "gh auth refresh -s repo"},
+		{"Resource not accessible by integration", "pull-requests: write"},
+		{"Resource not accessible by personal access token", "\"Pull requests\""},
+		{"Could not resolve to a PullRequest with the number of 99999.", "Check the PR number"},
+		{"Could not resolve to a Repository with the name 'dnr/nope'.", "remoteName"},
+	}
+	for _, tt := range tests {
+		err := translateGitHubError(errors.New(tt.err))
+		assert.ErrorContains(t, err, tt.err)
+		assert.ErrorContains(t, err, tt.hint)
+		assert.Equal(t, tt.err, errors.Unwrap(err).Error())
+
+		// Wrapping it again doesn't add a second hint
+		wrapped := fmt.Errorf("GraphQL query failed: %w", err)
+		assert.Equal(t, wrapped, translateGitHubError(wrapped))
+	}
+
+	other := errors.New("something else")
+	assert.Equal(t, other, translateGitHubError(other))
+	assert.NoError(t, translateGitHubError(nil))
+}
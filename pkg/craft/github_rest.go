@@ -0,0 +1,220 @@
+package craft
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/shurcooL/githubv4"
+)
+
+const gitHubAPIURL = "https://api.github.com"
+
+// ErrRESTPending is returned when a pending review sent through REST would
+// need replies or file-level threads, which REST can only post right away.
+var ErrRESTPending = fmt.Errorf("a pending review can't have replies or file comments through the REST API; send without --pending")
+
+// rest makes a REST API request with in as the JSON body, decoding the
+// response into out if it's not nil.
+func (c *GitHubClient) rest(ctx context.Context, method, path string, in, out any) error {
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.restURL+path, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode/100 != 2 {
+		var e struct {
+			Message string `json:"message"`
+		}
+		msg := strings.TrimSpace(string(data))
+		if json.Unmarshal(data, &e) == nil && e.Message != "" {
+			msg = e.Message
+		}
+		return translateGitHubError(fmt.Errorf("%s %s: %s: %s", method, path, resp.Status, msg))
+	}
+	if out != nil {
+		return json.Unmarshal(data, out)
+	}
+	return nil
+}
+
+// PostReview posts a whole review through REST. REST can't put replies or
+// file-level threads in a review, so they're posted on their own after it,
+// and a pending review can't have them.
+func (c *GitHubClient) PostReview(ctx context.Context, prNodeID, commitOID, event, body string, threads []NewThreadInfo, replies []ReplyInfo) error {
+	logInfo("GitHub refused the GraphQL review mutation, sending through the REST API")
+	fileThreads := slices.DeleteFunc(slices.Clone(threads), func(t NewThreadInfo) bool {
+		return t.Subject != SubjectTypeFile
+	})
+	if event == "PENDING" && (len(replies) > 0 || len(fileThreads) > 0) {
+		return ErrRESTPending
+	}
+
+	owner, repo, number, err := c.prLocation(ctx, prNodeID)
+	if err != nil {
+		return fmt.Errorf("finding PR: %w", err)
+	}
+	// Look up every reply's comment before posting anything
+	replyTo := make([]int64, len(replies))
+	for i, reply := range replies {
+		if replyTo[i], err = c.commentDatabaseID(ctx, reply.ReplyToNodeID); err != nil {
+			return fmt.Errorf("finding comment to reply to in %s:%d: %w", reply.ThreadPath, reply.ThreadLine, err)
+		}
+	}
+
+	// A COMMENT review with nothing in it would be an error
+	lineThreads := len(threads) - len(fileThreads)
+	if event != "COMMENT" || body != "" || lineThreads > 0 {
+		logStart("Creating review (%s)", event)
+		if err := c.createReview(ctx, owner, repo, number, commitOID, event, body, threads); err != nil {
+			return fmt.Errorf("creating review: %w", err)
+		}
+		logEnd("done")
+	}
+	for _, t := range fileThreads {
+		logStart("Adding file comment on %s", t.Path)
+		if err := c.addFileComment(ctx, owner, repo, number, commitOID, t); err != nil {
+			return fmt.Errorf("adding file comment on %s: %w", t.Path, err)
+		}
+		logEnd("done")
+	}
+	for i, reply := range replies {
+		logStart("Adding reply in thread %s:%d", reply.ThreadPath, reply.ThreadLine)
+		if err := c.reply(ctx, owner, repo, number, replyTo[i], reply.Body); err != nil {
+			return fmt.Errorf("adding reply: %w", err)
+		}
+		logEnd("done")
+	}
+	return nil
+}
+
+// prLocation returns the owner, repo and number of the PR with the given
+// node ID, which REST needs instead.
+func (c *GitHubClient) prLocation(ctx context.Context, prNodeID string) (owner, repo string, number int, err error) {
+	var query struct {
+		Node struct {
+			PullRequest struct {
+				Number     int
+				Repository struct {
+					Name  string
+					Owner struct {
+						Login string
+					}
+				}
+			} `graphql:"... on PullRequest"`
+		} `graphql:"node(id: $id)"`
+	}
+	vars := map[string]any{"id": githubv4.ID(prNodeID)}
+	if err := c.query(ctx, &query, vars); err != nil {
+		return "", "", 0, err
+	}
+	pr := query.Node.PullRequest
+	if pr.Number == 0 {
+		return "", "", 0, fmt.Errorf("no pull request with ID %s", prNodeID)
+	}
+	return pr.Repository.Owner.Login, pr.Repository.Name, pr.Number, nil
+}
+
+// commentDatabaseID returns the REST ID of the review comment with the
+// given node ID.
+func (c *GitHubClient) commentDatabaseID(ctx context.Context, nodeID string) (int64, error) {
+	var query struct {
+		Node struct {
+			PullRequestReviewComment struct {
+				DatabaseID int64 `graphql:"databaseId"`
+			} `graphql:"... on PullRequestReviewComment"`
+		} `graphql:"node(id: $id)"`
+	}
+	vars := map[string]any{"id": githubv4.ID(nodeID)}
+	if err := c.query(ctx, &query, vars); err != nil {
+		return 0, err
+	}
+	if query.Node.PullRequestReviewComment.DatabaseID == 0 {
+		return 0, fmt.Errorf("no review comment with ID %s", nodeID)
+	}
+	return query.Node.PullRequestReviewComment.DatabaseID, nil
+}
+
+// restReviewComment is a line comment in a REST review.
+type restReviewComment struct {
+	Path      string `json:"path"`
+	Body      string `json:"body"`
+	Line      int    `json:"line"`
+	Side      string `json:"side"`
+	StartLine int    `json:"start_line,omitempty"`
+	StartSide string `json:"start_side,omitempty"`
+}
+
+// createReview creates a review with line threads through REST. An event
+// of PENDING leaves it pending.
+func (c *GitHubClient) createReview(ctx context.Context, owner, repo string, number int, commitOID, event, body string, threads []NewThreadInfo) error {
+	in := struct {
+		CommitID string              `json:"commit_id"`
+		Body     string              `json:"body,omitempty"`
+		Event    string              `json:"event,omitempty"`
+		Comments []restReviewComment `json:"comments,omitempty"`
+	}{CommitID: commitOID, Body: body}
+	if event != "PENDING" {
+		in.Event = event
+	}
+	for _, t := range threads {
+		if t.Subject == SubjectTypeFile {
+			continue
+		}
+		rc := restReviewComment{Path: t.Path, Body: t.Body, Line: t.Line, Side: string(t.Side)}
+		if t.StartLine != nil {
+			rc.StartLine, rc.StartSide = *t.StartLine, string(t.Side)
+		}
+		in.Comments = append(in.Comments, rc)
+	}
+	path := fmt.Sprintf("/repos/%s/%s/pulls/%d/reviews", owner, repo, number)
+	return c.rest(ctx, "POST", path, in, nil)
+}
+
+// addFileComment posts a file-level comment through REST. It's posted
+// right away, not as part of a review.
+func (c *GitHubClient) addFileComment(ctx context.Context, owner, repo string, number int, commitOID string, t NewThreadInfo) error {
+	in := map[string]string{
+		"body":         t.Body,
+		"commit_id":    commitOID,
+		"path":         t.Path,
+		"subject_type": "file",
+	}
+	path := fmt.Sprintf("/repos/%s/%s/pulls/%d/comments", owner, repo, number)
+	return c.rest(ctx, "POST", path, in, nil)
+}
+
+// reply posts a reply to a review comment through REST. It's posted right
+// away, not as part of a review.
+func (c *GitHubClient) reply(ctx context.Context, owner, repo string, number int, commentID int64, body string) error {
+	in := map[string]string{"body": body}
+	path := fmt.Sprintf("/repos/%s/%s/pulls/%d/comments/%d/replies", owner, repo, number, commentID)
+	return c.rest(ctx, "POST", path, in, nil)
+}
@@ -0,0 +1,86 @@
+package craft
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/shurcooL/githubv4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeDenyingGitHub is a GitHub that refuses GraphQL mutations, like it does
+// some fine-grained tokens, and records REST requests.
+func fakeDenyingGitHub(t *testing.T) (*GitHubClient, *[]string) {
+	var rest []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, err := io.ReadAll(req.Body)
+		require.NoError(t, err)
+		if req.URL.Path != "/graphql" {
+			rest = append(rest, req.Method+" "+req.URL.Path+" "+string(body))
+			w.WriteHeader(http.StatusCreated)
+			io.WriteString(w, "{}")
+			return
+		}
+		query := string(body)
+		switch {
+		case strings.Contains(query, "mutation"):
+			io.WriteString(w, `{"errors":[{"message":"Resource not accessible by personal access token"}]}`)
+		case strings.Contains(query, "reviews(first: 1"):
+			io.WriteString(w, `{"data":{"node":{"reviews":{"nodes":[]}}}}`)
+		case strings.Contains(query, "databaseId"):
+			io.WriteString(w, `{"data":{"node":{"databaseId":42}}}`)
+		case strings.Contains(query, "repository"):
+			io.WriteString(w, `{"data":{"node":{"number":7,"repository":{"name":"craft","owner":{"login":"dnr"}}}}}`)
+		default:
+			t.Errorf("unexpected query %s", query)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	client := &GitHubClient{
+		client:  githubv4.NewEnterpriseClient(srv.URL+"/graphql", srv.Client()),
+		http:    srv.Client(),
+		restURL: srv.URL,
+	}
+	return client, &rest
+}
+
+func TestSendReviewRESTFallback(t *testing.T) {
+	start := 3
+	review := &ReviewToSend{
+		NewThreads: []NewThreadInfo{
+			{Path: "a.go", Line: 5, StartLine: &start, Side: DiffSideRight, Body: "range"},
+			{Path: "b.go", Subject: SubjectTypeFile, Body: "file"},
+		},
+		Replies:     []ReplyInfo{{ThreadPath: "a.go", ThreadLine: 1, Body: "reply", ReplyToNodeID: "PRRC_x"}},
+		Body:        "overall",
+		ReviewEvent: "APPROVE",
+	}
+
+	t.Run("sends through REST", func(t *testing.T) {
+		client, rest := fakeDenyingGitHub(t)
+		require.NoError(t, review.Send(t.Context(), client, "PR_x", "abc123", false))
+		assert.Equal(t, []string{
+			`POST /repos/dnr/craft/pulls/7/reviews {"commit_id":"abc123","body":"overall","event":"APPROVE","comments":[{"path":"a.go","body":"range","line":5,"side":"RIGHT","start_line":3,"start_side":"RIGHT"}]}`,
+			`POST /repos/dnr/craft/pulls/7/comments {"body":"file","commit_id":"abc123","path":"b.go","subject_type":"file"}`,
+			`POST /repos/dnr/craft/pulls/7/comments/42/replies {"body":"reply"}`,
+		}, *rest)
+	})
+
+	t.Run("only replies", func(t *testing.T) {
+		client, rest := fakeDenyingGitHub(t)
+		r := &ReviewToSend{Replies: review.Replies, ReviewEvent: "COMMENT"}
+		require.NoError(t, r.Send(t.Context(), client, "PR_x", "abc123", false))
+		assert.Equal(t, []string{`POST /repos/dnr/craft/pulls/7/comments/42/replies {"body":"reply"}`}, *rest)
+	})
+
+	t.Run("pending with replies", func(t *testing.T) {
+		client, rest := fakeDenyingGitHub(t)
+		r := &ReviewToSend{Replies: review.Replies, ReviewEvent: "PENDING"}
+		assert.ErrorIs(t, r.Send(t.Context(), client, "PR_x", "abc123", false), ErrRESTPending)
+		assert.Empty(t, *rest)
+	})
+}
@@ -0,0 +1,116 @@
+package craft_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/dnr/craft/pkg/craft"
+	"github.com/shurcooL/githubv4"
+	"github.com/stretchr/testify/assert"
+)
+
+// offlineBackend is a GitHubAPI outside craft, as another backend would be.
+type offlineBackend struct{}
+
+var errOffline = errors.New("offline")
+
+func (offlineBackend) FetchPullRequest(ctx context.Context, owner, repo string, number int) (*craft.PullRequest, error) {
+	return nil, errOffline
+}
+
+func (offlineBackend) FetchPreviousBodies(ctx context.Context, pr *craft.PullRequest) error {
+	return errOffline
+}
+
+func (offlineBackend) FindPRByBranch(ctx context.Context, owner, repo, branch string) (int, error) {
+	return 0, errOffline
+}
+
+func (offlineBackend) FetchOpenPRs(ctx context.Context, owner, repo string) ([]craft.OpenPR, error) {
+	return nil, errOffline
+}
+
+func (offlineBackend) FetchPRHead(ctx context.Context, owner, repo string, number int) (head, state string, isDraft bool, err error) {
+	return "", "", false, errOffline
+}
+
+func (offlineBackend) FetchPRDraft(ctx context.Context, owner, repo string, number int) (id string, isDraft bool, err error) {
+	return "", false, errOffline
+}
+
+func (offlineBackend) FetchPRDescription(ctx context.Context, owner, repo string, number int) (*craft.PRDescription, error) {
+	return nil, errOffline
+}
+
+func (offlineBackend) FetchViewerLogin(ctx context.Context) (string, error) { return "", errOffline }
+
+func (offlineBackend) FetchMentionableUsers(ctx context.Context, owner, repo string) ([]string, error) {
+	return nil, errOffline
+}
+
+func (offlineBackend) FetchReviewCommentIDs(ctx context.Context, reviewID string) ([]string, error) {
+	return nil, errOffline
+}
+
+func (offlineBackend) GetPendingReview(ctx context.Context, prNodeID string) (githubv4.ID, bool, error) {
+	return nil, false, errOffline
+}
+
+func (offlineBackend) DeletePendingReview(ctx context.Context, reviewID githubv4.ID) error {
+	return errOffline
+}
+
+func (offlineBackend) StartReviewWithThreads(ctx context.Context, prNodeID, commitOID string, threads []craft.NewThreadInfo) (githubv4.ID, error) {
+	return nil, errOffline
+}
+
+func (offlineBackend) AddFileThread(ctx context.Context, reviewID githubv4.ID, t craft.NewThreadInfo) error {
+	return errOffline
+}
+
+func (offlineBackend) AddReviewComment(ctx context.Context, reviewID githubv4.ID, replyToNodeID, body string) (string, error) {
+	return "", errOffline
+}
+
+func (offlineBackend) SubmitReview(ctx context.Context, reviewID githubv4.ID, eventType, body string) error {
+	return errOffline
+}
+
+func (offlineBackend) UpdateReviewBody(ctx context.Context, reviewID githubv4.ID, body string) error {
+	return errOffline
+}
+
+func (offlineBackend) ResolveThread(ctx context.Context, threadID string) error { return errOffline }
+
+func (offlineBackend) MarkReadyForReview(ctx context.Context, prNodeID string) error {
+	return errOffline
+}
+
+func (offlineBackend) ConvertToDraft(ctx context.Context, prNodeID string) error { return errOffline }
+
+func (offlineBackend) UpdatePRDescription(ctx context.Context, prNodeID, title, body string) error {
+	return errOffline
+}
+
+func (offlineBackend) UpdateIssueComment(ctx context.Context, commentID, body string) error {
+	return errOffline
+}
+
+func (offlineBackend) DeleteIssueComment(ctx context.Context, commentID string) error {
+	return errOffline
+}
+
+func (offlineBackend) DeleteReviewComment(ctx context.Context, commentID string) error {
+	return errOffline
+}
+
+func (offlineBackend) PostReview(ctx context.Context, prNodeID, commitOID, event, body string, threads []craft.NewThreadInfo, replies []craft.ReplyInfo) error {
+	return errOffline
+}
+
+func TestGitHubAPIOutsideCraft(t *testing.T) {
+	var api craft.GitHubAPI = offlineBackend{}
+	_, err := api.FetchPullRequest(t.Context(), "owner", "repo", 1)
+	assert.ErrorIs(t, err, errOffline)
+}
@@ -0,0 +1,93 @@
+package craft
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// LintConfig configures the checks on new comments before they're sent.
+type LintConfig struct {
+	// Disable turns off all the checks.
+	Disable bool `yaml:"disable"`
+
+	// MaxLength is the longest body allowed, in bytes (0 means
+	// defaultMaxCommentLength).
+	MaxLength int `yaml:"maxLength"`
+
+	// VaguePattern matches whole bodies too vague to act on ("" means
+	// defaultVaguePattern).
+	VaguePattern string `yaml:"vaguePattern"`
+}
+
+const (
+	defaultMaxCommentLength = 4000
+	defaultVaguePattern     = `(?i)^(fix( this| it)?|this is wrong|wrong|why|\?+|no|nope)[.!?]*$`
+)
+
+// todoMarkerRe matches markers usually left in a draft by mistake.
+var todoMarkerRe = regexp.MustCompile(`\b(TODO|FIXME|XXX)\b`)
+
+// LintErrors is returned by CollectNewComments for new comments that fail
+// the checks in LintConfig.
+type LintErrors []ParseError
+
+func (e LintErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, le := range e {
+		msgs[i] = le.Error()
+	}
+	return fmt.Sprintf("%d problem(s) with new comments:\n%s", len(e), strings.Join(msgs, "\n"))
+}
+
+// lintReview checks the new comments in review. Threads and replies are
+// reported at their path and line, and the PR-level comment at PR-STATE.txt.
+func lintReview(cfg LintConfig, review *ReviewToSend) error {
+	if cfg.Disable {
+		return nil
+	}
+	maxLength := cfg.MaxLength
+	if maxLength <= 0 {
+		maxLength = defaultMaxCommentLength
+	}
+	pattern := cfg.VaguePattern
+	if pattern == "" {
+		pattern = defaultVaguePattern
+	}
+	vagueRe, err := regexp.Compile(pattern)
+	if err != nil {
+		return fmt.Errorf("invalid lint.vaguePattern: %w", err)
+	}
+
+	var errs LintErrors
+	check := func(path string, line int, body string) {
+		body = strings.TrimSpace(body)
+		var msg string
+		switch {
+		case body == "":
+			msg = "empty comment"
+		case len(body) > maxLength:
+			msg = fmt.Sprintf("comment is %d bytes long (more than %d)", len(body), maxLength)
+		case vagueRe.MatchString(body):
+			msg = fmt.Sprintf("comment %q doesn't say what to change", body)
+		case todoMarkerRe.MatchString(body):
+			msg = fmt.Sprintf("comment has a %s marker", todoMarkerRe.FindString(body))
+		default:
+			return
+		}
+		errs = append(errs, ParseError{Path: path, Line: line, Msg: msg})
+	}
+	for _, t := range review.NewThreads {
+		check(t.Path, t.Line, t.Body)
+	}
+	for _, r := range review.Replies {
+		check(r.ThreadPath, r.ThreadLine, r.Body)
+	}
+	if review.Body != "" {
+		check(PRStateFile, 0, review.Body)
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
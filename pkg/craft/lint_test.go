@@ -0,0 +1,39 @@
+package craft
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLintReview(t *testing.T) {
+	review := &ReviewToSend{
+		NewThreads: []NewThreadInfo{
+			{Path: "a.go", Line: 1, Body: "Fix this."},
+			{Path: "a.go", Line: 2, Body: "Consider a map here; lookups are O(n) now."},
+			{Path: "a.go", Line: 3, Body: "TODO: finish this thought"},
+			{Path: "b.go", Line: 4, Body: strings.Repeat("x", 4001)},
+		},
+		Replies: []ReplyInfo{{ThreadPath: "b.go", ThreadLine: 5, Body: "  "}},
+		Body:    "Looks good overall",
+	}
+	err := lintReview(LintConfig{}, review)
+	var lintErrs LintErrors
+	require.ErrorAs(t, err, &lintErrs)
+	assert.Equal(t, LintErrors{
+		{Path: "a.go", Line: 1, Msg: `comment "Fix this." doesn't say what to change`},
+		{Path: "a.go", Line: 3, Msg: "comment has a TODO marker"},
+		{Path: "b.go", Line: 4, Msg: "comment is 4001 bytes long (more than 4000)"},
+		{Path: "b.go", Line: 5, Msg: "empty comment"},
+	}, lintErrs)
+
+	// Configured limits
+	err = lintReview(LintConfig{MaxLength: 5000, VaguePattern: `^nit$`}, review)
+	require.ErrorAs(t, err, &lintErrs)
+	assert.Len(t, lintErrs, 2)
+
+	assert.NoError(t, lintReview(LintConfig{Disable: true}, review))
+	assert.ErrorContains(t, lintReview(LintConfig{VaguePattern: "("}, review), "invalid lint.vaguePattern")
+}
@@ -0,0 +1,41 @@
+package craft
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"time"
+)
+
+// Logger gets the progress of Send, as the craft command logs its own: a
+// step is a record with "step" "start", then one with "step" "end" and its
+// "result" and "duration". It discards them unless set.
+var Logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+// A logStep is a step of Send that takes a while, e.g. "Submitting review".
+type logStep struct {
+	msg   string
+	start time.Time
+}
+
+// step is the step logStart started, for logEnd to finish.
+var step logStep
+
+// logStart logs the start of a step, which logEnd finishes.
+func logStart(format string, args ...any) {
+	step = logStep{msg: fmt.Sprintf(format, args...), start: time.Now()}
+	Logger.Info(step.msg, "step", "start")
+}
+
+// logEnd logs how the current step ended, usually "done" or "denied".
+func logEnd(result string) {
+	Logger.Info(step.msg, "step", "end", "result", result, "duration", time.Since(step.start))
+}
+
+func logInfo(format string, args ...any) {
+	Logger.Info(fmt.Sprintf(format, args...))
+}
+
+func logWarn(format string, args ...any) {
+	Logger.Warn(fmt.Sprintf(format, args...))
+}
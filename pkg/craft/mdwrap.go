@@ -0,0 +1,558 @@
+package craft
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/mattn/go-runewidth"
+	"rsc.io/markdown"
+)
+
+// Unwrap transforms a markdown AST to join soft-wrapped lines.
+// SoftBreaks become spaces, and newlines within Plain text become spaces.
+// This is the inverse of Wrap and is used when sending comments to GitHub.
+func Unwrap(b markdown.Block) markdown.Block {
+	return walkBlock(b, 0, ignoreIndent(unwrapInlines))
+}
+
+// Wrap transforms a markdown AST to wrap text at the given width.
+// This is used when receiving comments from GitHub to make them readable in an editor.
+// Tables are never wrapped; rows wider than width are truncated instead.
+func Wrap(b markdown.Block, width int) markdown.Block {
+	truncateTables(b, width)
+	return Rewrap(b, width)
+}
+
+// Rewrap is Wrap without truncating tables, for text that's already in the
+// files: craft fmt re-wraps it at a new width, and must not lose any of it.
+func Rewrap(b markdown.Block, width int) markdown.Block {
+	return walkBlock(b, 0, func(inlines markdown.Inlines, indent int) markdown.Inlines {
+		return wrapInlines(inlines, width-indent)
+	})
+}
+
+// RenderEmoji transforms a markdown AST to replace :shortcode: emoji with
+// their Unicode form. This is only for display: GitHub renders both forms
+// the same, but the shortcode is what the author typed, so it's never done
+// to text that can be written back.
+func RenderEmoji(b markdown.Block) markdown.Block {
+	return walkBlock(b, 0, ignoreIndent(renderEmojiInlines))
+}
+
+var shortcodePattern = regexp.MustCompile(`:[a-z0-9_+-]+:`)
+
+// RenderEmojiText replaces the :shortcode: emoji in a line of text with their
+// Unicode form, for display. Anything else that looks like one is kept, as
+// are shortcodes inside words, like a:b:c.
+func RenderEmojiText(text string) string {
+	var buf strings.Builder
+	last := 0
+	for _, m := range shortcodePattern.FindAllStringIndex(text, -1) {
+		if m[0] > 0 && isWordByte(text[m[0]-1]) || m[1] < len(text) && isWordByte(text[m[1]]) {
+			continue
+		}
+		if e := parseEmoji(text[m[0]:m[1]]); e != "" {
+			buf.WriteString(text[last:m[0]] + e)
+			last = m[1]
+		}
+	}
+	return buf.String() + text[last:]
+}
+
+func isWordByte(c byte) bool {
+	return c == '_' || '0' <= c && c <= '9' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
+
+// parseEmoji returns the Unicode form of an emoji shortcode, or "".
+func parseEmoji(code string) string {
+	doc := NewMarkdownParser().Parse(code)
+	if len(doc.Blocks) != 1 {
+		return ""
+	}
+	if p, ok := doc.Blocks[0].(*markdown.Paragraph); ok && len(p.Text.Inline) == 1 {
+		if e, ok := p.Text.Inline[0].(*markdown.Emoji); ok {
+			return e.Text
+		}
+	}
+	return ""
+}
+
+// NewMarkdownParser returns a markdown parser configured for comment bodies.
+// Emoji shortcodes are parsed as separate inlines so they can be kept whole,
+// GitHub tables are parsed as tables so their rows aren't joined, and
+// footnote definitions are parsed as footnotes rather than dropped.
+func NewMarkdownParser() *markdown.Parser {
+	return &markdown.Parser{Emoji: true, Table: true, Footnote: true}
+}
+
+// An inlinesFunc transforms the Inlines of a block. indent is the width of
+// the block markers (such as "> " for each enclosing quote) that precede
+// each line of the block when formatted.
+type inlinesFunc func(inlines markdown.Inlines, indent int) markdown.Inlines
+
+// ignoreIndent adapts a transform that doesn't depend on line width.
+func ignoreIndent(fn func(markdown.Inlines) markdown.Inlines) inlinesFunc {
+	return func(inlines markdown.Inlines, _ int) markdown.Inlines {
+		return fn(inlines)
+	}
+}
+
+// walkBlock recursively walks a block, applying fn to any Inlines it contains.
+// Footnote definitions are reached through the FootnoteLinks that reference
+// them, since the parser moves them out of the document's blocks.
+func walkBlock(b markdown.Block, indent int, fn inlinesFunc) markdown.Block {
+	switch b := b.(type) {
+	case *markdown.Document:
+		var notes []*markdown.Footnote
+		seen := make(map[*markdown.Footnote]bool)
+		collect := func(inlines markdown.Inlines, indent int) markdown.Inlines {
+			inlines = fn(inlines, indent)
+			notes = appendFootnotes(notes, seen, inlines)
+			return inlines
+		}
+		for i, child := range b.Blocks {
+			b.Blocks[i] = walkBlock(child, indent, collect)
+		}
+		// Footnotes may reference other footnotes, so notes can grow
+		for i := 0; i < len(notes); i++ {
+			note := notes[i]
+			noteIndent := indent + TextWidth("[^"+note.Label+"]: ")
+			for j, child := range note.Blocks {
+				note.Blocks[j] = walkBlock(child, noteIndent, collect)
+			}
+		}
+	case *markdown.Paragraph:
+		b.Text.Inline = fn(b.Text.Inline, indent)
+	case *markdown.Heading:
+		b.Text.Inline = fn(b.Text.Inline, indent)
+	case *markdown.Quote:
+		for i, child := range b.Blocks {
+			b.Blocks[i] = walkBlock(child, indent+2, fn) // "> "
+		}
+	case *markdown.List:
+		for i, item := range b.Items {
+			b.Items[i] = walkBlock(item, indent, fn)
+		}
+	case *markdown.Item:
+		for i, child := range b.Blocks {
+			b.Blocks[i] = walkBlock(child, indent, fn)
+		}
+	case *markdown.Text:
+		b.Inline = fn(b.Inline, indent)
+		// Table cells can't span lines, so tables are left untouched (see truncateTables)
+		// CodeBlock, HTMLBlock, ThematicBreak, Empty - no inlines to process
+	}
+	return b
+}
+
+// appendFootnotes appends the footnotes referenced in inlines that aren't
+// already in seen.
+func appendFootnotes(notes []*markdown.Footnote, seen map[*markdown.Footnote]bool, inlines markdown.Inlines) []*markdown.Footnote {
+	for _, inl := range inlines {
+		switch inl := inl.(type) {
+		case *markdown.FootnoteLink:
+			if inl.Footnote != nil && !seen[inl.Footnote] {
+				seen[inl.Footnote] = true
+				notes = append(notes, inl.Footnote)
+			}
+		case *markdown.Strong:
+			notes = appendFootnotes(notes, seen, inl.Inner)
+		case *markdown.Emph:
+			notes = appendFootnotes(notes, seen, inl.Inner)
+		case *markdown.Del:
+			notes = appendFootnotes(notes, seen, inl.Inner)
+		case *markdown.Link:
+			notes = appendFootnotes(notes, seen, inl.Inner)
+		}
+	}
+	return notes
+}
+
+// truncateTables recursively finds tables in a block and truncates their
+// cells so that each row fits within width.
+func truncateTables(b markdown.Block, width int) {
+	switch b := b.(type) {
+	case *markdown.Document:
+		for _, child := range b.Blocks {
+			truncateTables(child, width)
+		}
+	case *markdown.Quote:
+		for _, child := range b.Blocks {
+			truncateTables(child, width-2) // "> "
+		}
+	case *markdown.List:
+		for _, item := range b.Items {
+			truncateTables(item, width)
+		}
+	case *markdown.Item:
+		for _, child := range b.Blocks {
+			truncateTables(child, width)
+		}
+	case *markdown.Table:
+		truncateTable(b, width)
+	}
+}
+
+// truncateTable shrinks the widest columns of a table, cutting off cell text
+// with "…", until a formatted row ("| a | b |") fits within width.
+func truncateTable(t *markdown.Table, width int) {
+	const minCol = 3
+	cells := [][]*markdown.Text{t.Header}
+	cells = append(cells, t.Rows...)
+
+	// Render each cell and find the column widths
+	text := make([][]string, len(cells))
+	cols := make([]int, len(t.Header))
+	for i, row := range cells {
+		text[i] = make([]string, len(row))
+		for j, cell := range row {
+			text[i][j] = strings.TrimSpace(markdown.Format(cell))
+			if j < len(cols) {
+				cols[j] = max(cols[j], TextWidth(text[i][j]))
+			}
+		}
+	}
+
+	rowWidth := func() int {
+		w := 1 // trailing "|"
+		for _, c := range cols {
+			w += c + 3 // "| " + cell + " "
+		}
+		return w
+	}
+	truncated := false
+	for rowWidth() > width {
+		widest := 0
+		for j, c := range cols {
+			if c > cols[widest] {
+				widest = j
+			}
+		}
+		if cols[widest] <= minCol {
+			break
+		}
+		cols[widest]--
+		truncated = true
+	}
+	if !truncated {
+		return
+	}
+
+	for i, row := range cells {
+		for j, cell := range row {
+			if j < len(cols) && TextWidth(text[i][j]) > cols[j] {
+				cell.Inline = markdown.Inlines{&markdown.Plain{Text: runewidth.Truncate(text[i][j], cols[j], "…")}}
+			}
+		}
+	}
+}
+
+// unwrapInlines replaces SoftBreaks with spaces and joins newlines in Plain text.
+func unwrapInlines(inlines markdown.Inlines) markdown.Inlines {
+	result := make(markdown.Inlines, 0, len(inlines))
+	for _, inl := range inlines {
+		switch inl := inl.(type) {
+		case *markdown.SoftBreak:
+			// Replace soft break with space
+			result = append(result, &markdown.Plain{Text: " "})
+		case *markdown.Plain:
+			// Replace newlines with spaces
+			text := strings.ReplaceAll(inl.Text, "\n", " ")
+			result = append(result, &markdown.Plain{Text: text})
+		case *markdown.Strong:
+			inl.Inner = unwrapInlines(inl.Inner)
+			result = append(result, inl)
+		case *markdown.Emph:
+			inl.Inner = unwrapInlines(inl.Inner)
+			result = append(result, inl)
+		case *markdown.Del:
+			inl.Inner = unwrapInlines(inl.Inner)
+			result = append(result, inl)
+		case *markdown.Link:
+			inl.Inner = unwrapInlines(inl.Inner)
+			result = append(result, inl)
+		case *markdown.Image:
+			inl.Inner = unwrapInlines(inl.Inner)
+			result = append(result, inl)
+		case *markdown.Emoji:
+			// Keep the :shortcode: as plain text. Merging it into the
+			// surrounding text makes it part of a word, so wrapPlain never
+			// splits it.
+			result = append(result, &markdown.Plain{Text: inl.Name})
+		default:
+			// HardBreak, Code, AutoLink, HTMLTag, Escaped - keep as-is
+			result = append(result, inl)
+		}
+	}
+	return mergePlain(result)
+}
+
+// renderEmojiInlines replaces Emoji inlines with their Unicode text.
+func renderEmojiInlines(inlines markdown.Inlines) markdown.Inlines {
+	result := make(markdown.Inlines, 0, len(inlines))
+	for _, inl := range inlines {
+		switch inl := inl.(type) {
+		case *markdown.Emoji:
+			result = append(result, &markdown.Plain{Text: inl.Text})
+		case *markdown.Strong:
+			inl.Inner = renderEmojiInlines(inl.Inner)
+			result = append(result, inl)
+		case *markdown.Emph:
+			inl.Inner = renderEmojiInlines(inl.Inner)
+			result = append(result, inl)
+		case *markdown.Del:
+			inl.Inner = renderEmojiInlines(inl.Inner)
+			result = append(result, inl)
+		case *markdown.Link:
+			inl.Inner = renderEmojiInlines(inl.Inner)
+			result = append(result, inl)
+		default:
+			result = append(result, inl)
+		}
+	}
+	return mergePlain(result)
+}
+
+// wrapInlines wraps text at the given width by inserting SoftBreaks.
+func wrapInlines(inlines markdown.Inlines, width int) markdown.Inlines {
+	// First unwrap to normalize, then re-wrap
+	inlines = unwrapInlines(inlines)
+
+	result := make(markdown.Inlines, 0, len(inlines))
+	pos := 0 // current position in line
+
+	for _, inl := range inlines {
+		switch inl := inl.(type) {
+		case *markdown.Plain:
+			wrapped, newPos := wrapPlain(inl.Text, width, pos)
+			result = append(result, wrapped...)
+			pos = newPos
+		case *markdown.Strong:
+			// Account for ** markers
+			inl.Inner = wrapInlinesAt(inl.Inner, width, pos+2)
+			result = append(result, inl)
+			pos += inlineLen(inl)
+		case *markdown.Emph:
+			// Account for * marker
+			inl.Inner = wrapInlinesAt(inl.Inner, width, pos+1)
+			result = append(result, inl)
+			pos += inlineLen(inl)
+		case *markdown.Del:
+			inl.Inner = wrapInlinesAt(inl.Inner, width, pos+2)
+			result = append(result, inl)
+			pos += inlineLen(inl)
+		case *markdown.Link:
+			// The URL can't be broken, so start a new line if the whole
+			// link doesn't fit. Only the link text is wrapped.
+			result, pos = breakBefore(result, pos, inlineLen(inl), width)
+			inl.Inner = wrapInlinesAt(inl.Inner, width, pos+1)
+			result = append(result, inl)
+			pos += inlineLen(inl)
+		case *markdown.Image:
+			result, pos = breakBefore(result, pos, inlineLen(inl), width)
+			inl.Inner = wrapInlinesAt(inl.Inner, width, pos+2)
+			result = append(result, inl)
+			pos += inlineLen(inl)
+		case *markdown.Code, *markdown.AutoLink:
+			// Atomic: never split, even if wider than the line
+			result, pos = breakBefore(result, pos, inlineLen(inl), width)
+			result = append(result, inl)
+			pos += inlineLen(inl)
+		case *markdown.HardBreak:
+			result = append(result, inl)
+			pos = 0
+		default:
+			result = append(result, inl)
+			pos += inlineLen(inl)
+		}
+	}
+	return result
+}
+
+// wrapInlinesAt wraps inlines starting at a given position.
+func wrapInlinesAt(inlines markdown.Inlines, width, startPos int) markdown.Inlines {
+	// For nested elements, we do a simplified wrap
+	// This could be improved but handles most cases
+	result := make(markdown.Inlines, 0, len(inlines))
+	pos := startPos
+
+	for _, inl := range inlines {
+		switch inl := inl.(type) {
+		case *markdown.Plain:
+			wrapped, newPos := wrapPlain(inl.Text, width, pos)
+			result = append(result, wrapped...)
+			pos = newPos
+		case *markdown.Code, *markdown.AutoLink:
+			result, pos = breakBefore(result, pos, inlineLen(inl), width)
+			result = append(result, inl)
+			pos += inlineLen(inl)
+		default:
+			result = append(result, inl)
+			pos += inlineLen(inl)
+		}
+	}
+	return result
+}
+
+// breakBefore starts a new line before an unbreakable inline of width w if
+// it doesn't fit on the current line. A break is only possible where the
+// preceding text ends in a space; that space is replaced by the SoftBreak.
+func breakBefore(result markdown.Inlines, pos, w, width int) (markdown.Inlines, int) {
+	if pos == 0 || pos+w <= width || len(result) == 0 {
+		return result, pos
+	}
+	prev, ok := result[len(result)-1].(*markdown.Plain)
+	if !ok || !strings.HasSuffix(prev.Text, " ") {
+		return result, pos
+	}
+	prev.Text = strings.TrimRight(prev.Text, " ")
+	if prev.Text == "" {
+		result = result[:len(result)-1]
+	}
+	return append(result, &markdown.SoftBreak{}), 0
+}
+
+// wrapPlain wraps plain text, returning the resulting inlines and final position.
+func wrapPlain(text string, width, pos int) (markdown.Inlines, int) {
+	if width <= 0 || text == "" {
+		return markdown.Inlines{&markdown.Plain{Text: text}}, pos + TextWidth(text)
+	}
+
+	var result markdown.Inlines
+	var current strings.Builder
+
+	// Preserve leading space
+	hasLeadingSpace := len(text) > 0 && (text[0] == ' ' || text[0] == '\t')
+	hasTrailingSpace := len(text) > 0 && (text[len(text)-1] == ' ' || text[len(text)-1] == '\t')
+
+	words := strings.Fields(text)
+	if len(words) == 0 {
+		// Text is all whitespace
+		return markdown.Inlines{&markdown.Plain{Text: text}}, pos + TextWidth(text)
+	}
+
+	for i, word := range words {
+		wordLen := TextWidth(word)
+
+		// Need space before this word?
+		needSpace := (i > 0) || (hasLeadingSpace && pos > 0)
+
+		// If this word would exceed width and we're not at start of line
+		if pos > 0 && pos+boolToInt(needSpace)+wordLen > width {
+			// Emit current text and soft break
+			if current.Len() > 0 {
+				result = append(result, &markdown.Plain{Text: current.String()})
+				current.Reset()
+			}
+			result = append(result, &markdown.SoftBreak{})
+			pos = 0
+			needSpace = false
+		}
+
+		// Add space before word if needed
+		if needSpace && (current.Len() > 0 || pos > 0) {
+			current.WriteByte(' ')
+			pos++
+		}
+
+		current.WriteString(word)
+		pos += wordLen
+	}
+
+	// Emit remaining text
+	if current.Len() > 0 {
+		s := current.String()
+		if hasTrailingSpace {
+			s += " "
+			pos++
+		}
+		result = append(result, &markdown.Plain{Text: s})
+	}
+
+	return result, pos
+}
+
+func boolToInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// TextWidth returns the display width of s in terminal columns.
+// Wide characters such as CJK and most emoji count as two columns.
+func TextWidth(s string) int {
+	return runewidth.StringWidth(s)
+}
+
+// inlineLen estimates the rendered length of an inline element.
+func inlineLen(inl markdown.Inline) int {
+	switch inl := inl.(type) {
+	case *markdown.Plain:
+		return TextWidth(inl.Text)
+	case *markdown.Code:
+		return TextWidth(inl.Text) + 2
+	case *markdown.Strong:
+		return inlinesLen(inl.Inner) + 4
+	case *markdown.Emph:
+		return inlinesLen(inl.Inner) + 2
+	case *markdown.Del:
+		return inlinesLen(inl.Inner) + 4
+	case *markdown.Link:
+		// [text](url)
+		return inlinesLen(inl.Inner) + TextWidth(inl.URL) + 4
+	case *markdown.Image:
+		// ![text](url)
+		return inlinesLen(inl.Inner) + TextWidth(inl.URL) + 5
+	case *markdown.SoftBreak, *markdown.HardBreak:
+		return 0
+	case *markdown.Emoji:
+		return TextWidth(inl.Name)
+	case *markdown.FootnoteLink:
+		// [^label]
+		return TextWidth(inl.Label) + 3
+	case *markdown.AutoLink:
+		// <url>
+		return TextWidth(inl.Text) + 2
+	default:
+		return 0
+	}
+}
+
+// inlinesLen estimates the total rendered length of inline elements.
+func inlinesLen(inlines markdown.Inlines) int {
+	total := 0
+	for _, inl := range inlines {
+		total += inlineLen(inl)
+	}
+	return total
+}
+
+// mergePlain merges adjacent Plain elements.
+func mergePlain(inlines markdown.Inlines) markdown.Inlines {
+	if len(inlines) == 0 {
+		return inlines
+	}
+	result := make(markdown.Inlines, 0, len(inlines))
+	var pending *markdown.Plain
+
+	for _, inl := range inlines {
+		if p, ok := inl.(*markdown.Plain); ok {
+			if pending == nil {
+				pending = &markdown.Plain{Text: p.Text}
+			} else {
+				pending.Text += p.Text
+			}
+		} else {
+			if pending != nil {
+				result = append(result, pending)
+				pending = nil
+			}
+			result = append(result, inl)
+		}
+	}
+	if pending != nil {
+		result = append(result, pending)
+	}
+	return result
+}
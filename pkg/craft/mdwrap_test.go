@@ -0,0 +1,332 @@
+package craft
+
+import (
+	"strings"
+	"testing"
+
+	"rsc.io/markdown"
+)
+
+func TestUnwrap(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{
+			name:  "single line",
+			input: "Hello world",
+			want:  "Hello world\n",
+		},
+		{
+			name:  "soft wrapped",
+			input: "Hello\nworld",
+			want:  "Hello world\n",
+		},
+		{
+			name:  "multiple soft wraps",
+			input: "This is a\nlong paragraph\nthat spans\nmultiple lines",
+			want:  "This is a long paragraph that spans multiple lines\n",
+		},
+		{
+			name:  "preserves paragraphs",
+			input: "First paragraph.\n\nSecond paragraph.",
+			want:  "First paragraph.\n\nSecond paragraph.\n",
+		},
+		{
+			name:  "preserves hard breaks",
+			input: "Line one\\\nLine two",
+			want:  "Line one\\\nLine two\n",
+		},
+		{
+			name:  "preserves code blocks",
+			input: "Text before\n\n```\ncode\nhere\n```\n\nText after",
+			want:  "Text before\n\n```\ncode\nhere\n```\n\nText after\n",
+		},
+		{
+			name:  "preserves emphasis",
+			input: "This is *very\nimportant* text",
+			want:  "This is *very important* text\n",
+		},
+	}
+
+	p := markdown.Parser{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc := p.Parse(tt.input)
+			result := Unwrap(doc)
+			got := markdown.Format(result)
+			if got != tt.want {
+				t.Errorf("Unwrap() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWrap(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		width int
+		want  string
+	}{
+		{
+			name:  "short line unchanged",
+			input: "Hello world",
+			width: 80,
+			want:  "Hello world\n",
+		},
+		{
+			name:  "long line wrapped",
+			input: "This is a very long line that should be wrapped at the specified width",
+			width: 30,
+			want:  "This is a very long line that\nshould be wrapped at the\nspecified width\n",
+		},
+		{
+			name:  "preserves paragraphs",
+			input: "First paragraph with some longer text here.\n\nSecond paragraph also with text.",
+			width: 25,
+			want:  "First paragraph with some\nlonger text here.\n\nSecond paragraph also\nwith text.\n",
+		},
+		{
+			name:  "preserves code blocks",
+			input: "Text\n\n```\nlong code line that should not be wrapped\n```",
+			width: 20,
+			want:  "Text\n\n```\nlong code line that should not be wrapped\n```\n",
+		},
+	}
+
+	p := markdown.Parser{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc := p.Parse(tt.input)
+			result := Wrap(doc, tt.width)
+			got := markdown.Format(result)
+			if got != tt.want {
+				t.Errorf("Wrap(%d) = %q, want %q", tt.width, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWrapLists(t *testing.T) {
+	input := "- This is a list item with a long description that should wrap\n- Second item"
+	p := markdown.Parser{}
+	doc := p.Parse(input)
+	result := Wrap(doc, 30)
+	got := markdown.Format(result)
+
+	// Should wrap within list items but preserve list structure
+	if !strings.Contains(got, "- This is a list item") {
+		t.Errorf("List structure not preserved: %q", got)
+	}
+	if !strings.Contains(got, "- Second item") {
+		t.Errorf("Second list item missing: %q", got)
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	// Test that unwrap(wrap(text)) preserves meaning
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{
+			name:  "simple paragraph",
+			input: "This is a simple paragraph with some text.",
+		},
+		{
+			name:  "with emphasis",
+			input: "This has *emphasis* and **strong** text.",
+		},
+		{
+			name:  "with links",
+			input: "Check out [this link](https://example.com) for more.",
+		},
+		{
+			name:  "multiple paragraphs",
+			input: "First paragraph.\n\nSecond paragraph.",
+		},
+	}
+
+	p := markdown.Parser{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc := p.Parse(tt.input)
+
+			// Wrap then unwrap
+			wrapped := Wrap(doc, 20)
+			unwrapped := Unwrap(wrapped)
+
+			// Compare unwrapped text with original (after normalizing)
+			original := Unwrap(p.Parse(tt.input))
+			got := markdown.Format(unwrapped)
+			want := markdown.Format(original)
+
+			if got != want {
+				t.Errorf("Round trip failed:\n  got:  %q\n  want: %q", got, want)
+			}
+		})
+	}
+}
+
+func TestWrapEmoji(t *testing.T) {
+	input := "Looks good :white_check_mark: but *see :warning: here* ok:+1:"
+
+	// Shortcodes are kept whole and never split across lines
+	doc := NewMarkdownParser().Parse(input)
+	got := markdown.Format(Wrap(doc, 12))
+	want := "Looks good\n:white_check_mark:\nbut *see\n:warning:\nhere*\nok:+1:\n"
+	if got != want {
+		t.Errorf("Wrap() = %q, want %q", got, want)
+	}
+
+	// Unwrap restores the shortcodes rather than Unicode
+	got = markdown.Format(Unwrap(NewMarkdownParser().Parse(got)))
+	if got != input+"\n" {
+		t.Errorf("Unwrap() = %q, want %q", got, input+"\n")
+	}
+
+	// Optionally render as Unicode for display
+	doc = NewMarkdownParser().Parse(input)
+	got = markdown.Format(Wrap(RenderEmoji(doc), 80))
+	want = "Looks good ✅ but *see ⚠️ here* ok👍\n"
+	if got != want {
+		t.Errorf("RenderEmoji() = %q, want %q", got, want)
+	}
+}
+
+func TestWrapWideCharacters(t *testing.T) {
+	// Each CJK character is two columns wide, so only two words fit in 20 columns
+	input := "这是一个 很长的中文 句子需要 换行显示"
+	doc := NewMarkdownParser().Parse(input)
+	got := markdown.Format(Wrap(doc, 20))
+	want := "这是一个 很长的中文\n句子需要 换行显示\n"
+	if got != want {
+		t.Errorf("Wrap() = %q, want %q", got, want)
+	}
+	for _, line := range strings.Split(strings.TrimSuffix(got, "\n"), "\n") {
+		if w := TextWidth(line); w > 20 {
+			t.Errorf("line %q is %d columns wide", line, w)
+		}
+	}
+}
+
+func TestWrapAtomicLinksAndCode(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{
+			name:  "autolink moved to next line",
+			input: "See this page <https://example.com/a/very/long/path/that/exceeds/the/width> for details",
+			want:  "See this page\n<https://example.com/a/very/long/path/that/exceeds/the/width>\nfor details\n",
+		},
+		{
+			name:  "code span moved to next line",
+			input: "Call the function `someVeryLongFunctionName(withArguments)` here",
+			want:  "Call the function\n`someVeryLongFunctionName(withArguments)`\nhere\n",
+		},
+		{
+			name:  "link URL kept on one line",
+			input: "Read [the docs](https://example.com/some/long/documentation/path) first",
+			want:  "Read\n[the docs](https://example.com/some/long/documentation/path)\nfirst\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc := NewMarkdownParser().Parse(tt.input)
+			got := markdown.Format(Wrap(doc, 30))
+			if got != tt.want {
+				t.Errorf("Wrap() = %q, want %q", got, tt.want)
+			}
+			// And it round-trips
+			unwrapped := markdown.Format(Unwrap(NewMarkdownParser().Parse(got)))
+			if unwrapped != tt.input+"\n" {
+				t.Errorf("Unwrap() = %q, want %q", unwrapped, tt.input+"\n")
+			}
+		})
+	}
+}
+
+func TestWrapTables(t *testing.T) {
+	input := "Results:\n\n| name | value |\n| --- | ---: |\n| a | 1 |\n| b | 22 |\n\nDone."
+	doc := NewMarkdownParser().Parse(input)
+	got := markdown.Format(Wrap(doc, 30))
+	// markdown.Format drops the blank line before a table; GitHub still
+	// parses the header row as the start of a table.
+	want := "Results:\n| name | value |\n| ---- | ----: |\n| a    |     1 |\n| b    |    22 |\n\nDone.\n"
+	if got != want {
+		t.Errorf("Wrap() = %q, want %q", got, want)
+	}
+
+	// Unwrap leaves the rows alone
+	unwrapped := markdown.Format(Unwrap(NewMarkdownParser().Parse(got)))
+	if unwrapped != want {
+		t.Errorf("Unwrap() = %q, want %q", unwrapped, want)
+	}
+
+	// Wide tables are truncated rather than wrapped
+	input = "| key | description |\n| --- | --- |\n| x | a rather long description of x |"
+	doc = NewMarkdownParser().Parse(input)
+	got = markdown.Format(Wrap(doc, 30))
+	want = "| key | description          |\n| --- | -------------------- |\n| x   | a rather long descr… |\n"
+	if got != want {
+		t.Errorf("Wrap() = %q, want %q", got, want)
+	}
+	for _, line := range strings.Split(strings.TrimSuffix(got, "\n"), "\n") {
+		if w := TextWidth(line); w > 30 {
+			t.Errorf("line %q is %d columns wide", line, w)
+		}
+	}
+}
+
+func TestWrapFootnotesAndNestedQuotes(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		wrapped string
+		want    string // after Unwrap
+	}{
+		{
+			// markdown.Format separates footnotes with an extra blank line
+			name:    "footnote definition is kept and wrapped",
+			input:   "See this[^1] claim which is long enough to need wrapping.\n\n[^1]: A footnote that is also quite long and should be wrapped.",
+			wrapped: "See this[^1] claim which is\nlong enough to need wrapping.\n\n\n[^1]: A footnote that is also\n  quite long and should be\n  wrapped.",
+			want:    "See this[^1] claim which is long enough to need wrapping.\n\n\n[^1]: A footnote that is also quite long and should be wrapped.",
+		},
+		{
+			name:    "nested quote accounts for markers",
+			input:   "> quoted text that is long enough to be wrapped\n>\n> > nested quote text that is long enough to wrap\n>\n> outer again",
+			wrapped: "> quoted text that is long\n> enough to be wrapped\n> > nested quote text that is\n> > long enough to wrap\n>\n> outer again\n",
+			want:    "> quoted text that is long enough to be wrapped\n> > nested quote text that is long enough to wrap\n>\n> outer again\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := markdown.Format(Wrap(NewMarkdownParser().Parse(tt.input), 30))
+			if got != tt.wrapped {
+				t.Errorf("Wrap() = %q, want %q", got, tt.wrapped)
+			}
+			for _, line := range strings.Split(got, "\n") {
+				if w := TextWidth(line); w > 30 {
+					t.Errorf("line %q is %d columns wide", line, w)
+				}
+			}
+			unwrapped := markdown.Format(Unwrap(NewMarkdownParser().Parse(got)))
+			if unwrapped != tt.want {
+				t.Errorf("Unwrap() = %q, want %q", unwrapped, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenderEmojiText(t *testing.T) {
+	got := RenderEmojiText("ok :+1: :tada:, not :nope: or a:b:c")
+	if want := "ok 👍 🎉, not :nope: or a:b:c"; got != want {
+		t.Errorf("renderEmojiText() = %q, want %q", got, want)
+	}
+}
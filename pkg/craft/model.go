@@ -0,0 +1,301 @@
+// Package craft is craft's review pipeline as a library. It has the model of
+// a pull request review (the PR, its threads, comments and reviews),
+// GitHubAPI and GitHubClient to fetch it from GitHub, Serialize and
+// Deserialize to write it into the files of a working copy and read it back,
+// and CollectNewComments and ReviewToSend.Send to send what was added. The
+// craft command is a thin wrapper that finds the token, repo and settings
+// and runs these.
+//
+// Send logs its progress to Logger. The VCS implementations for git, jj and
+// Sapling are in the craft command; a tool brings its own, or serializes
+// without one.
+package craft
+
+import (
+	"slices"
+	"strings"
+	"time"
+)
+
+// Actor represents a GitHub user or bot.
+type Actor struct {
+	Login     string `json:"login"`
+	AvatarURL string `json:"avatarUrl,omitempty"`
+	URL       string `json:"url,omitempty"`
+}
+
+// ReviewState represents the state of a pull request review.
+type ReviewState string
+
+const (
+	ReviewStatePending          ReviewState = "PENDING"
+	ReviewStateCommented        ReviewState = "COMMENTED"
+	ReviewStateApproved         ReviewState = "APPROVED"
+	ReviewStateChangesRequested ReviewState = "CHANGES_REQUESTED"
+)
+
+// DiffSide indicates which side of the diff a comment is on.
+type DiffSide string
+
+const (
+	DiffSideLeft  DiffSide = "LEFT"
+	DiffSideRight DiffSide = "RIGHT"
+)
+
+// SubjectType indicates whether a comment is on a line or file.
+type SubjectType string
+
+const (
+	SubjectTypeLine SubjectType = "LINE"
+	SubjectTypeFile SubjectType = "FILE"
+)
+
+// ReviewComment is a comment within a review thread.
+type ReviewComment struct {
+	ID         string    `json:"id"`         // GraphQL node ID
+	DatabaseID int64     `json:"databaseId"` // Numeric ID
+	Author     Actor     `json:"author"`
+	Body       string    `json:"body"`
+	CreatedAt  time.Time `json:"createdAt"`
+	UpdatedAt  time.Time `json:"updatedAt"`
+	ReplyToID  *string   `json:"replyToId,omitempty"` // Parent comment ID (for replies within thread)
+
+	IsEdited     bool   `json:"isEdited,omitempty"`     // Edited on GitHub after it was posted
+	PreviousBody string `json:"previousBody,omitempty"` // Body before the last edit, if fetched
+
+	// For tracking local changes
+	IsNew      bool `json:"isNew,omitempty"`      // Created locally, not yet pushed
+	IsModified bool `json:"isModified,omitempty"` // Edited locally
+
+	HeaderExtra []string `json:"headerExtra,omitempty"` // Unknown header fields, kept verbatim
+}
+
+// ReviewThread is a thread of comments on a specific code location.
+type ReviewThread struct {
+	ID                string          `json:"id"` // GraphQL node ID
+	Path              string          `json:"path"`
+	DiffSide          DiffSide        `json:"diffSide"`
+	Line              int             `json:"line"`              // End line (or single line)
+	StartLine         *int            `json:"startLine"`         // Start line for ranges (nil if single line)
+	OriginalLine      int             `json:"originalLine"`      // Original line (before PR changes)
+	OriginalStartLine *int            `json:"originalStartLine"` // Original start line for ranges
+	IsOutdated        bool            `json:"isOutdated"`
+	IsApprox          bool            `json:"isApprox,omitempty"` // Outdated, but placed by craft near its original line
+	IsResolved        bool            `json:"isResolved"`
+	IsApplied         bool            `json:"isApplied,omitempty"` // Its suggestion is in the code now
+	SubjectType       SubjectType     `json:"subjectType"`
+	OriginalCommitOID string          `json:"originalCommitOid,omitempty"` // Commit the thread was created on
+	DiffHunk          string          `json:"diffHunk,omitempty"`          // Diff context of the first comment, ending at its line
+	Comments          []ReviewComment `json:"comments"`
+}
+
+// IssueComment is a general PR comment (not attached to code).
+type IssueComment struct {
+	ID         string    `json:"id"`
+	DatabaseID int64     `json:"databaseId"`
+	Author     Actor     `json:"author"`
+	Body       string    `json:"body"`
+	CreatedAt  time.Time `json:"createdAt"`
+	UpdatedAt  time.Time `json:"updatedAt"`
+	IsEdited   bool      `json:"isEdited,omitempty"`
+
+	IsNew      bool `json:"isNew,omitempty"`
+	IsModified bool `json:"isModified,omitempty"`
+
+	HeaderExtra []string `json:"headerExtra,omitempty"`
+}
+
+// Review is a formal review submission.
+type Review struct {
+	ID          string      `json:"id"`
+	DatabaseID  int64       `json:"databaseId"`
+	Author      Actor       `json:"author"`
+	State       ReviewState `json:"state"`
+	Body        string      `json:"body"`
+	SubmittedAt *time.Time  `json:"submittedAt,omitempty"` // nil if pending
+	CreatedAt   time.Time   `json:"createdAt"`
+	CommitOID   string      `json:"commitOid,omitempty"` // Head when the review was made
+
+	IsModified bool `json:"isModified,omitempty"` // pending review body edited locally
+}
+
+// Commit is one of the PR's commits.
+type Commit struct {
+	OID         string    `json:"oid"`
+	Author      string    `json:"author"` // GitHub login, or the git author name if there's none
+	HasLogin    bool      `json:"hasLogin,omitempty"`
+	Headline    string    `json:"headline"` // First line of the message
+	CommittedAt time.Time `json:"committedAt"`
+}
+
+// ForcePush is a force push to the PR's head branch. The OIDs are empty if
+// GitHub no longer has the commits.
+type ForcePush struct {
+	Actor     string    `json:"actor"`
+	BeforeOID string    `json:"beforeOid,omitempty"`
+	AfterOID  string    `json:"afterOid,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// PullRequest represents the complete PR state.
+type PullRequest struct {
+	// Version of the JSON; see SchemaVersion
+	SchemaVersion int `json:"schemaVersion"`
+
+	// Identity
+	ID     string `json:"id"` // GraphQL node ID
+	Number int    `json:"number"`
+
+	// Metadata
+	Title   string `json:"title"`
+	Body    string `json:"body"`
+	Author  Actor  `json:"author"`
+	State   string `json:"state"` // OPEN, CLOSED, MERGED
+	IsDraft bool   `json:"isDraft"`
+
+	// Branch info
+	BaseRefName string `json:"baseRefName"`
+	HeadRefName string `json:"headRefName"`
+	BaseRefOID  string `json:"baseRefOid"`
+	HeadRefOID  string `json:"headRefOid"`
+
+	// The base before the last get that found it moved, for craft diff
+	// --base-change
+	PreviousBaseRefOID string `json:"previousBaseRefOid,omitempty"`
+
+	// Stacked PRs: the open PR whose head branch is this PR's base branch
+	StackParent    int    `json:"stackParent,omitempty"`    // 0 if not stacked
+	StackParentOID string `json:"stackParentOid,omitempty"` // Head of the parent PR
+
+	// Per-commit review: the PR commit checked out instead of the head
+	ReviewCommitOID string `json:"reviewCommitOid,omitempty"`
+
+	// A review of a local range made by craft local, with no PR (yet)
+	IsLocal bool `json:"isLocal,omitempty"`
+
+	// Author mode: serialized by get --in-place onto the working copy of the
+	// PR's own branch, not a pr-N branch
+	InPlace bool `json:"inPlace,omitempty"`
+
+	// Review data - the core of what we sync
+	ReviewThreads []ReviewThread `json:"reviewThreads"`
+	IssueComments []IssueComment `json:"issueComments"`
+	Reviews       []Review       `json:"reviews"`
+
+	// How the branch got to where it is (informational)
+	Commits     []Commit    `json:"commits,omitempty"`
+	ForcePushes []ForcePush `json:"forcePushes,omitempty"`
+
+	// Sync metadata
+	LastFetchedAt time.Time `json:"lastFetchedAt"`
+	HeaderExtra   []string  `json:"headerExtra,omitempty"` // Unknown PR-STATE.txt header fields
+}
+
+// EffectiveBase returns the commit to review the PR against: the previous
+// commit of the PR when reviewing one commit, the parent PR's head for a
+// stacked PR, so only the incremental change shows, and otherwise the base
+// commit.
+func (pr *PullRequest) EffectiveBase() string {
+	if parent := pr.reviewCommitParent(); parent != "" {
+		return parent
+	}
+	if pr.StackParentOID != "" {
+		return pr.StackParentOID
+	}
+	return pr.BaseRefOID
+}
+
+// CheckoutOID returns the commit the files are at: the reviewed commit in
+// per-commit review, and otherwise the PR head.
+func (pr *PullRequest) CheckoutOID() string {
+	if pr.ReviewCommitOID != "" {
+		return pr.ReviewCommitOID
+	}
+	return pr.HeadRefOID
+}
+
+// reviewCommitParent returns the PR commit before ReviewCommitOID, or "" if
+// it's the first one or not reviewing a commit. Commits may be abbreviated.
+func (pr *PullRequest) reviewCommitParent() string {
+	if pr.ReviewCommitOID == "" {
+		return ""
+	}
+	i := slices.IndexFunc(pr.Commits, func(c Commit) bool {
+		return c.OID != "" && strings.HasPrefix(pr.ReviewCommitOID, c.OID)
+	})
+	if i < 1 {
+		return ""
+	}
+	return pr.Commits[i-1].OID
+}
+
+// IsClosed reports whether the PR was merged or closed, so its review is
+// for reading, not adding to.
+func (pr *PullRequest) IsClosed() bool {
+	return IsClosedState(pr.State)
+}
+
+// IsClosedState reports whether a PR state from GitHub is merged or closed.
+func IsClosedState(state string) bool {
+	return state == "MERGED" || state == "CLOSED"
+}
+
+// ResolvedThreadCount returns the number of resolved review threads.
+func (pr *PullRequest) ResolvedThreadCount() int {
+	var n int
+	for _, thread := range pr.ReviewThreads {
+		if thread.IsResolved {
+			n++
+		}
+	}
+	return n
+}
+
+// CommentBodies returns the body of every review and issue comment, keyed by
+// node ID. Used as SerializeOptions.Originals.
+func (pr *PullRequest) CommentBodies() map[string]string {
+	bodies := make(map[string]string)
+	for _, thread := range pr.ReviewThreads {
+		for _, c := range thread.Comments {
+			if c.ID != "" {
+				bodies[c.ID] = c.Body
+			}
+		}
+	}
+	for _, c := range pr.IssueComments {
+		if c.ID != "" {
+			bodies[c.ID] = c.Body
+		}
+	}
+	return bodies
+}
+
+// CopyHeaderExtras copies unknown header fields from a deserialized PR onto
+// the same PR and comments in pr (typically freshly fetched), so they
+// survive re-serializing.
+func (pr *PullRequest) CopyHeaderExtras(from *PullRequest) {
+	pr.HeaderExtra = from.HeaderExtra
+	extras := make(map[string][]string)
+	for _, thread := range from.ReviewThreads {
+		for _, c := range thread.Comments {
+			if c.ID != "" && len(c.HeaderExtra) > 0 {
+				extras[c.ID] = c.HeaderExtra
+			}
+		}
+	}
+	for _, c := range from.IssueComments {
+		if c.ID != "" && len(c.HeaderExtra) > 0 {
+			extras[c.ID] = c.HeaderExtra
+		}
+	}
+	for i := range pr.ReviewThreads {
+		for j := range pr.ReviewThreads[i].Comments {
+			c := &pr.ReviewThreads[i].Comments[j]
+			c.HeaderExtra = extras[c.ID]
+		}
+	}
+	for i := range pr.IssueComments {
+		c := &pr.IssueComments[i]
+		c.HeaderExtra = extras[c.ID]
+	}
+}
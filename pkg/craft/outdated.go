@@ -0,0 +1,156 @@
+package craft
+
+import (
+	"strings"
+
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+// placeOutdatedThreads finds a place in the current file for outdated and
+// LEFT-side threads, which GitHub gives no current line, so they can be
+// written near the code they were about instead of at the end of the file.
+// The thread's original line is mapped through a line diff from the file at
+// the thread's original commit (the base, for LEFT-side threads) to lines, the
+// file as it is now. A changed or deleted line maps to the nearest surviving
+// one. Placed threads get Line and IsApprox set; threads that can't be placed
+// are left alone.
+func placeOutdatedThreads(vcs VCS, pr *PullRequest, path string, lines []string, threads []ReviewThread) {
+	opcodesByCommit := make(map[string][]difflib.OpCode)
+	for i := range threads {
+		thread := &threads[i]
+		if !needsPlacement(*thread, len(lines)) {
+			continue
+		}
+		commit := thread.OriginalCommitOID
+		if thread.DiffSide == DiffSideLeft {
+			commit = pr.BaseRefOID
+		}
+		if commit == "" {
+			continue
+		}
+		opcodes, ok := opcodesByCommit[commit]
+		if !ok {
+			// No opcodes (e.g. the commit isn't available) means no placement
+			if content, err := vcs.GetFileAtCommit(commit, path); err == nil {
+				opcodes = difflib.NewMatcher(strings.Split(content, "\n"), lines).GetOpCodes()
+			}
+			opcodesByCommit[commit] = opcodes
+		}
+		if line := mapOriginalLine(opcodes, thread.OriginalLine); line >= 1 && line <= len(lines) {
+			thread.Line = line
+			thread.StartLine = nil
+			thread.IsOutdated = true
+			thread.IsApprox = true
+		}
+	}
+}
+
+// needsPlacement reports whether a thread would otherwise go in the outdated
+// comments section of a file with numLines lines.
+func needsPlacement(thread ReviewThread, numLines int) bool {
+	if thread.IsApprox || thread.SubjectType == SubjectTypeFile || thread.OriginalLine < 1 {
+		return false
+	}
+	return thread.DiffSide == DiffSideLeft || thread.Line < 1 || thread.Line > numLines
+}
+
+// mapOriginalLine maps a 1-based line number through diff opcodes from the
+// original file to the new one. Lines in a replaced block map to the
+// corresponding line of the replacement (or its last line), and deleted
+// lines map to the line before the deletion. Returns 0 if line is out of
+// range.
+func mapOriginalLine(opcodes []difflib.OpCode, line int) int {
+	i := line - 1
+	for _, op := range opcodes {
+		if i < op.I1 || i >= op.I2 {
+			continue
+		}
+		switch op.Tag {
+		case 'e':
+			return op.J1 + (i - op.I1) + 1
+		case 'r':
+			return op.J1 + min(i-op.I1, op.J2-op.J1-1) + 1
+		case 'd':
+			return max(op.J1, 1)
+		}
+	}
+	return 0
+}
+
+// PlaceInWorkingCopy moves threads from their lines at the PR head to the
+// same code in the files as they are now, for get --in-place, where the
+// working copy can have changes that aren't in the PR. Threads whose line
+// was changed get IsApprox set; threads on files that can't be read at the
+// head are left alone.
+func PlaceInWorkingCopy(opts SerializeOptions, pr *PullRequest) {
+	type lineMap struct {
+		opcodes   []difflib.OpCode
+		head, cur []string
+	}
+	byPath := make(map[string]*lineMap)
+	for i := range pr.ReviewThreads {
+		thread := &pr.ReviewThreads[i]
+		if thread.SubjectType == SubjectTypeFile || thread.DiffSide == DiffSideLeft || thread.Line < 1 {
+			continue
+		}
+		m, ok := byPath[thread.Path]
+		if !ok {
+			head, cur, err := workingCopyLines(opts, pr.HeadRefOID, thread.Path)
+			if err == nil {
+				m = &lineMap{difflib.NewMatcher(head, cur).GetOpCodes(), head, cur}
+			}
+			byPath[thread.Path] = m
+		}
+		if m == nil || thread.Line > len(m.head) {
+			continue
+		}
+		line := mapOriginalLine(m.opcodes, thread.Line)
+		if line < 1 || line > len(m.cur) {
+			continue
+		}
+		if m.cur[line-1] != m.head[thread.Line-1] {
+			thread.IsApprox = true
+			thread.StartLine = nil
+		} else if thread.StartLine != nil {
+			start := mapOriginalLine(m.opcodes, *thread.StartLine)
+			if start >= 1 && start < line {
+				thread.StartLine = &start
+			} else {
+				thread.StartLine = nil
+			}
+		}
+		thread.Line = line
+	}
+}
+
+// workingCopyLines returns the lines of path at commit and in opts.FS, with
+// any craft comments left out.
+func workingCopyLines(opts SerializeOptions, commit, path string) (head, cur []string, err error) {
+	content, err := opts.VCS.GetFileAtCommit(commit, path)
+	if err != nil {
+		return nil, nil, err
+	}
+	cur, err = CodeLines(opts, path)
+	if err != nil {
+		return nil, nil, err
+	}
+	return strings.Split(content, "\n"), cur, nil
+}
+
+// CodeLines returns the lines of path in opts.FS with any craft comments
+// left out.
+func CodeLines(opts SerializeOptions, path string) ([]string, error) {
+	data, err := ReadFile(opts.FS, path)
+	if err != nil {
+		return nil, err
+	}
+	_, text := DecodeFile(string(data))
+	fileLines := strings.Split(text, "\n")
+	var lines []string
+	for i, parsed := range ParseCraftLines(fileLines, GetCommentStyle(path).LinePrefix) {
+		if !parsed.OK {
+			lines = append(lines, fileLines[i])
+		}
+	}
+	return lines, nil
+}
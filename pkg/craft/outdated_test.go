@@ -0,0 +1,30 @@
+package craft
+
+import (
+	"testing"
+
+	"github.com/pmezard/go-difflib/difflib"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMapOriginalLine(t *testing.T) {
+	original := []string{"a", "b", "c", "d", "e", "f"}
+	current := []string{"a", "x", "c", "e", "f", "g"}
+	opcodes := difflib.NewMatcher(original, current).GetOpCodes()
+
+	tests := []struct {
+		line, want int
+	}{
+		{1, 1}, // unchanged
+		{2, 2}, // replaced by x
+		{3, 3}, // unchanged
+		{4, 3}, // deleted: after c
+		{5, 4}, // unchanged, shifted up
+		{6, 5},
+		{7, 0}, // past the end
+		{0, 0},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, mapOriginalLine(opcodes, tt.line), "line %d", tt.line)
+	}
+}
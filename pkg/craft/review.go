@@ -0,0 +1,521 @@
+package craft
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/shurcooL/githubv4"
+)
+
+// ReviewToSend contains all the new comments to send in a review.
+type ReviewToSend struct {
+	NewThreads  []NewThreadInfo
+	Replies     []ReplyInfo
+	Body        string // PR-level comment (at most one)
+	ReviewEvent string // COMMENT, APPROVE, REQUEST_CHANGES, or PENDING (not a real event)
+	Resolves    []ResolveInfo
+
+	// Existing PR-level comments edited or deleted in PR-STATE.txt
+	IssueCommentChanges []IssueCommentChange
+
+	// Your pending review, if its body was edited in PR-STATE.txt
+	PendingReviewID   string
+	PendingReviewBody string
+
+	// Set by Send: the review the comments went in, if sent through GraphQL,
+	// whether Send created it (rather than adding to a pending one), and the
+	// comments it made there; or that it went through REST, which doesn't
+	// say what it made
+	SentReviewID      string
+	SentReviewCreated bool
+	SentCommentIDs    []string
+	SentViaREST       bool
+}
+
+// IssueCommentChange is an existing PR-level comment whose body was edited
+// in PR-STATE.txt, or that has deleteField added to its header.
+type IssueCommentChange struct {
+	ID     string
+	Author string
+	Body   string // the new body, if not deleted
+	Delete bool
+}
+
+// deleteField, added to the header of an existing PR-level comment, has
+// craft send delete it.
+const deleteField = "delete"
+
+// DraftField marks new comments added by craft assist, which send refuses
+// until it's removed. It's kept as an unknown header field.
+const DraftField = "draft"
+
+// pendingReviewField marks the header of your pending review in
+// PR-STATE.txt, whose body is edited like a PR-level comment's.
+const pendingReviewField = "pending"
+
+// pendingReview returns the viewer's pending review of pr, the only one
+// GitHub shows, or nil.
+func pendingReview(pr *PullRequest) *Review {
+	for i, r := range pr.Reviews {
+		if r.State == ReviewStatePending {
+			return &pr.Reviews[i]
+		}
+	}
+	return nil
+}
+
+// ResolveInfo is an existing thread marked resolved in the files.
+type ResolveInfo struct {
+	ThreadPath     string
+	ThreadLine     int
+	FirstCommentID string // identifies the thread until MatchResolves
+	ThreadID       string // set by MatchResolves
+}
+
+// CollectOptions configures how new comments are collected.
+type CollectOptions struct {
+	Snippets map[string]string // Snippet templates from .craft.yaml (may be nil)
+	VCS      VCS               // Optional: check new threads are in the PR diff (see DiffErrors)
+	Lint     *LintConfig       // Optional: check new comments (see LintErrors)
+	Viewer   string            // Optional: your login; edits of others' PR-level comments are an error
+}
+
+// isDraft reports whether a comment was added by craft assist and not yet
+// accepted.
+func isDraft(c ReviewComment) bool {
+	return c.IsNew && slices.Contains(c.HeaderExtra, DraftField)
+}
+
+// IsIssueCommentChanged reports whether an existing PR-level comment was
+// edited or marked for deletion.
+func IsIssueCommentChanged(c IssueComment) bool {
+	return c.ID != "" && !c.IsNew && (c.IsModified || slices.Contains(c.HeaderExtra, deleteField))
+}
+
+// CollectNewComments extracts new comments from a PullRequest into a ReviewToSend.
+// Returns an error if there's more than one new PR-level comment.
+func CollectNewComments(pr *PullRequest, opts CollectOptions) (*ReviewToSend, error) {
+	review := &ReviewToSend{
+		ReviewEvent: "COMMENT",
+	}
+
+	var drafts []string
+	for _, thread := range pr.ReviewThreads {
+		if len(thread.Comments) == 0 {
+			continue
+		}
+		for _, c := range thread.Comments {
+			if isDraft(c) {
+				drafts = append(drafts, fmt.Sprintf("%s:%d", thread.Path, thread.Line))
+			}
+		}
+
+		// Check if this is a new thread by looking at the first comment's ID.
+		// Thread IDs (PRRT) aren't round-tripped through serialization, but
+		// comment IDs (PRRC) are, so we use the first comment's ID to distinguish
+		// new threads from existing ones.
+		firstComment := thread.Comments[0]
+		isNewThread := firstComment.ID == ""
+
+		if isNewThread {
+			if !firstComment.IsNew {
+				continue
+			}
+			// There's nothing to reply to until the thread is made, so new
+			// comments under it go in its first, as paragraphs after it
+			body := expandSnippet(opts.Snippets, firstComment.Body, thread.Path, thread.Line)
+			for _, c := range thread.Comments[1:] {
+				if c.IsNew && strings.TrimSpace(c.Body) != "" {
+					body += "\n\n" + expandSnippet(opts.Snippets, c.Body, thread.Path, thread.Line)
+				}
+			}
+			review.NewThreads = append(review.NewThreads, NewThreadInfo{
+				Path:      thread.Path,
+				Line:      thread.Line,
+				StartLine: thread.StartLine,
+				Side:      thread.DiffSide,
+				Subject:   thread.SubjectType,
+				Body:      body,
+			})
+		} else {
+			// Existing thread - look for new replies
+			for _, c := range thread.Comments {
+				if !c.IsNew {
+					continue
+				}
+				review.Replies = append(review.Replies, ReplyInfo{
+					ThreadPath:    thread.Path,
+					ThreadLine:    thread.Line,
+					Body:          expandSnippet(opts.Snippets, c.Body, thread.Path, thread.Line),
+					ReplyToNodeID: firstComment.ID,
+				})
+			}
+			if thread.IsResolved {
+				review.Resolves = append(review.Resolves, ResolveInfo{
+					ThreadPath:     thread.Path,
+					ThreadLine:     thread.Line,
+					FirstCommentID: firstComment.ID,
+				})
+			}
+		}
+	}
+
+	// Check for new issue comments (PR-level), and edits and deletions of
+	// existing ones, told by their body no longer matching the sum in their
+	// header
+	for _, c := range pr.IssueComments {
+		if c.IsNew {
+			if review.Body != "" {
+				return nil, fmt.Errorf("only one new PR-level comment is supported per review")
+			}
+			review.Body = expandSnippet(opts.Snippets, c.Body, "", 0)
+			continue
+		}
+		if c.ID == "" {
+			continue
+		}
+		if opts.Viewer != "" && c.Author.Login != opts.Viewer && IsIssueCommentChanged(c) {
+			return nil, fmt.Errorf("PR-level comment by @%s was edited or marked %q, but only your own can be changed; undo that in %s",
+				c.Author.Login, deleteField, PRStateFile)
+		}
+		if slices.Contains(c.HeaderExtra, deleteField) {
+			review.IssueCommentChanges = append(review.IssueCommentChanges, IssueCommentChange{ID: c.ID, Author: c.Author.Login, Delete: true})
+		} else if c.IsModified {
+			if strings.TrimSpace(c.Body) == "" {
+				return nil, fmt.Errorf("PR-level comment by @%s is empty; add %q to its header to delete it", c.Author.Login, deleteField)
+			}
+			review.IssueCommentChanges = append(review.IssueCommentChanges, IssueCommentChange{ID: c.ID, Author: c.Author.Login, Body: c.Body})
+		}
+	}
+
+	if r := pendingReview(pr); r != nil && r.IsModified {
+		review.PendingReviewID, review.PendingReviewBody = r.ID, r.Body
+	}
+
+	if len(drafts) > 0 {
+		return nil, fmt.Errorf("%d draft comment(s) from craft assist, at %s\nedit them and remove %q from their headers, or delete them",
+			len(drafts), strings.Join(drafts, ", "), DraftField)
+	}
+
+	if opts.Lint != nil {
+		if err := lintReview(*opts.Lint, review); err != nil {
+			return nil, err
+		}
+	}
+
+	if opts.VCS != nil {
+		if err := CheckNewThreadsInDiff(opts.VCS, pr, review.NewThreads); err != nil {
+			return nil, err
+		}
+	}
+
+	return review, nil
+}
+
+// expandSnippet expands a snippet if the first word of body names one.
+// A trailing colon on the first word is ignored, so "nit: foo" matches "nit".
+// Templates may contain these placeholders:
+//
+//	{body}  the rest of the comment after the snippet name
+//	{path}  the file the comment is on (empty for PR-level comments)
+//	{line}  the line the comment is on (empty for PR-level comments)
+//
+// If the template has no {body} placeholder, the rest of the comment is
+// appended after a space.
+func expandSnippet(snippets map[string]string, body, path string, line int) string {
+	if len(snippets) == 0 {
+		return body
+	}
+	word, rest := strings.TrimSpace(body), ""
+	if i := strings.IndexAny(word, " \t\n"); i >= 0 {
+		word, rest = word[:i], strings.TrimSpace(word[i:])
+	}
+	tmpl, ok := snippets[strings.TrimSuffix(word, ":")]
+	if !ok {
+		return body
+	}
+
+	lineStr := ""
+	if line > 0 {
+		lineStr = fmt.Sprint(line)
+	}
+	expanded := strings.NewReplacer("{path}", path, "{line}", lineStr).Replace(strings.TrimSpace(tmpl))
+	if strings.Contains(expanded, "{body}") {
+		return strings.ReplaceAll(expanded, "{body}", rest)
+	}
+	if rest == "" {
+		return expanded
+	}
+	return expanded + " " + rest
+}
+
+// Mentions returns the unique user logins @mentioned in any comment body.
+func (r *ReviewToSend) Mentions() []string {
+	bodies := []string{r.Body, r.PendingReviewBody}
+	for _, t := range r.NewThreads {
+		bodies = append(bodies, t.Body)
+	}
+	for _, reply := range r.Replies {
+		bodies = append(bodies, reply.Body)
+	}
+	for _, change := range r.IssueCommentChanges {
+		bodies = append(bodies, change.Body)
+	}
+
+	seen := make(map[string]bool)
+	var mentions []string
+	for _, body := range bodies {
+		for _, m := range extractMentions(body) {
+			if !seen[m] {
+				seen[m] = true
+				mentions = append(mentions, m)
+			}
+		}
+	}
+	return mentions
+}
+
+var (
+	// mentionRe matches @login and @org/team mentions that aren't part of an
+	// email address or another word.
+	mentionRe = regexp.MustCompile(`(?:^|[^\w@.])@([A-Za-z0-9][A-Za-z0-9-]*)(/[\w.-]+)?`)
+
+	// codeRe matches fenced code blocks and inline code spans, where mentions
+	// aren't rendered as links.
+	codeRe = regexp.MustCompile("(?s)```.*?```|`[^`\n]*`")
+)
+
+// extractMentions returns the user logins @mentioned in a markdown body.
+// Team mentions (@org/team) and mentions inside code are ignored.
+func extractMentions(body string) []string {
+	body = codeRe.ReplaceAllString(body, "")
+	var logins []string
+	for _, m := range mentionRe.FindAllStringSubmatch(body, -1) {
+		if m[2] != "" {
+			continue // team mention
+		}
+		logins = append(logins, m[1])
+	}
+	return logins
+}
+
+// IsEmpty returns true if there are no comments to send, threads to resolve
+// or PR-level comments or pending review body to change.
+func (r *ReviewToSend) IsEmpty() bool {
+	return !r.hasComments() && len(r.Resolves) == 0 && len(r.IssueCommentChanges) == 0 && r.PendingReviewID == ""
+}
+
+// hasComments reports whether there are any comments to send.
+func (r *ReviewToSend) hasComments() bool {
+	return len(r.NewThreads) > 0 || len(r.Replies) > 0 || r.Body != ""
+}
+
+// Summary returns a human-readable summary of what will be sent.
+func (r *ReviewToSend) Summary() string {
+	s := fmt.Sprintf("%d new thread(s), %d reply/replies, PR-level comment: %v",
+		len(r.NewThreads), len(r.Replies), r.Body != "")
+	if len(r.Resolves) > 0 {
+		s += fmt.Sprintf(", %d thread(s) to resolve", len(r.Resolves))
+	}
+	if len(r.IssueCommentChanges) > 0 {
+		s += fmt.Sprintf(", %d PR-level comment(s) to edit or delete", len(r.IssueCommentChanges))
+	}
+	if r.PendingReviewID != "" {
+		s += ", pending review body edited"
+	}
+	return s
+}
+
+// MatchResolves keeps the threads to resolve that are unresolved in remote,
+// the PR as it is on GitHub, and sets their thread IDs. Every thread
+// resolved on GitHub is also resolved in the files, so this leaves just the
+// ones newly marked resolved.
+func (r *ReviewToSend) MatchResolves(remote *PullRequest) {
+	threads := make(map[string]ReviewThread)
+	for _, t := range remote.ReviewThreads {
+		if len(t.Comments) > 0 {
+			threads[t.Comments[0].ID] = t
+		}
+	}
+	var resolves []ResolveInfo
+	for _, res := range r.Resolves {
+		t, ok := threads[res.FirstCommentID]
+		if !ok || t.IsResolved || t.ID == "" {
+			continue
+		}
+		res.ThreadID = t.ID
+		resolves = append(resolves, res)
+	}
+	r.Resolves = resolves
+}
+
+// ErrPendingReviewExists is returned when there's an existing pending review
+// and new threads need to be created.
+var ErrPendingReviewExists = fmt.Errorf("pending review exists")
+
+// isMutationDenied reports whether err is GitHub refusing a GraphQL mutation
+// for lack of permission. Fine-grained personal access tokens are refused
+// some review mutations that the REST API allows them.
+func isMutationDenied(err error) bool {
+	if err == nil {
+		return false
+	}
+	msg := err.Error()
+	return strings.Contains(msg, "Resource not accessible by personal access token") ||
+		strings.Contains(msg, "Resource not accessible by integration")
+}
+
+// Send sends the review to GitHub.
+// If discardPendingReview is true and there's an existing pending review with new threads
+// to add, the existing review will be discarded.
+// If ReviewEvent is "PENDING", the review will not be submitted (left in pending state).
+// Threads to resolve are resolved after the review is sent, so replies come
+// first, and then PR-level comments are edited and deleted; with only those
+// to send, no review is made. An edited pending review body is saved before
+// anything else, so the review is submitted with it.
+func (r *ReviewToSend) Send(ctx context.Context, client GitHubAPI, prNodeID, headRefOID string, discardPendingReview bool) error {
+	if r.PendingReviewID != "" {
+		logStart("Updating pending review body")
+		if err := client.UpdateReviewBody(ctx, githubv4.ID(r.PendingReviewID), r.PendingReviewBody); err != nil {
+			return fmt.Errorf("updating pending review: %w", err)
+		}
+		logEnd("done")
+	}
+	if r.hasComments() || r.ReviewEvent == "APPROVE" {
+		if err := r.sendReview(ctx, client, prNodeID, headRefOID, discardPendingReview); err != nil {
+			return err
+		}
+	}
+	for _, res := range r.Resolves {
+		logStart("Resolving thread %s:%d", res.ThreadPath, res.ThreadLine)
+		err := client.ResolveThread(ctx, res.ThreadID)
+		if isMutationDenied(err) {
+			// REST has no way to resolve a thread, and the review is sent
+			logEnd("denied")
+			logWarn("GitHub refused to resolve %s:%d for this token; resolve it in the GitHub UI", res.ThreadPath, res.ThreadLine)
+			continue
+		} else if err != nil {
+			return fmt.Errorf("resolving thread: %w", err)
+		}
+		logEnd("done")
+	}
+	for _, change := range r.IssueCommentChanges {
+		if change.Delete {
+			logStart("Deleting PR-level comment by @%s", change.Author)
+			if err := client.DeleteIssueComment(ctx, change.ID); err != nil {
+				return fmt.Errorf("deleting PR-level comment: %w", err)
+			}
+		} else {
+			logStart("Editing PR-level comment by @%s", change.Author)
+			if err := client.UpdateIssueComment(ctx, change.ID, change.Body); err != nil {
+				return fmt.Errorf("editing PR-level comment: %w", err)
+			}
+		}
+		logEnd("done")
+	}
+	return nil
+}
+
+func (r *ReviewToSend) sendReview(ctx context.Context, client GitHubAPI, prNodeID, headRefOID string, discardPendingReview bool) error {
+	var reviewID interface{}
+	var err error
+
+	// Check for existing pending review
+	logStart("Getting/creating pending review")
+	existingReviewID, hasPending, err := client.GetPendingReview(ctx, prNodeID)
+	if err != nil {
+		return fmt.Errorf("checking for pending review: %w", err)
+	}
+
+	if len(r.NewThreads) > 0 {
+		// We have new threads - due to a GitHub bug, we must create them atomically
+		// with the review, not add them to an existing review.
+		if hasPending {
+			if !discardPendingReview {
+				logEnd("failed!")
+				return fmt.Errorf("%w: you have an existing pending review; use --discard-pending-review to discard it, or submit/discard it in the GitHub UI first", ErrPendingReviewExists)
+			}
+			// Discard the existing review
+			logInfo("Discarding the existing pending review")
+			if err := client.DeletePendingReview(ctx, existingReviewID); err != nil {
+				return fmt.Errorf("discarding pending review: %w", err)
+			}
+		}
+		// Create new review with threads
+		reviewID, err = client.StartReviewWithThreads(ctx, prNodeID, headRefOID, r.NewThreads)
+		if isMutationDenied(err) {
+			logEnd("denied")
+			return r.postReview(ctx, client, prNodeID, headRefOID)
+		} else if err != nil {
+			return fmt.Errorf("creating review with threads: %w", err)
+		}
+		// The review's draft threads can only be on lines, so file-level
+		// threads are added to it separately
+		for _, t := range r.NewThreads {
+			if t.Subject != SubjectTypeFile {
+				continue
+			}
+			if err := client.AddFileThread(ctx, reviewID, t); err != nil {
+				return fmt.Errorf("adding file thread on %s: %w", t.Path, err)
+			}
+		}
+		r.SentReviewCreated = true
+		// The review is new, so all its comments are this send's
+		if id, ok := reviewID.(string); ok {
+			if r.SentCommentIDs, err = client.FetchReviewCommentIDs(ctx, id); err != nil {
+				logWarn("could not list the comments sent, for craft undo-send: %v", err)
+			}
+		}
+	} else {
+		// No new threads - just get or create a pending review for replies
+		if hasPending {
+			reviewID = existingReviewID
+		} else {
+			reviewID, err = client.StartReviewWithThreads(ctx, prNodeID, headRefOID, nil)
+			if isMutationDenied(err) {
+				logEnd("denied")
+				return r.postReview(ctx, client, prNodeID, headRefOID)
+			} else if err != nil {
+				return fmt.Errorf("creating review: %w", err)
+			}
+			r.SentReviewCreated = true
+		}
+	}
+	logEnd("done")
+	if id, ok := reviewID.(string); ok {
+		r.SentReviewID = id
+	}
+
+	// Add replies
+	for _, reply := range r.Replies {
+		logStart("Adding reply in thread %s:%d", reply.ThreadPath, reply.ThreadLine)
+		id, err := client.AddReviewComment(ctx, reviewID, reply.ReplyToNodeID, reply.Body)
+		if err != nil {
+			return fmt.Errorf("adding reply: %w", err)
+		}
+		r.SentCommentIDs = append(r.SentCommentIDs, id)
+		logEnd("done")
+	}
+
+	// Submit the review (unless PENDING)
+	if r.ReviewEvent != "PENDING" {
+		logStart("Submitting review (%s)", r.ReviewEvent)
+		if err := client.SubmitReview(ctx, reviewID, r.ReviewEvent, r.Body); err != nil {
+			return fmt.Errorf("submitting review: %w", err)
+		}
+		logEnd("done")
+	}
+
+	return nil
+}
+
+// postReview sends the whole review at once with PostReview, for when GitHub
+// refuses to start one. GitHub doesn't say what that made, so it can't be
+// undone.
+func (r *ReviewToSend) postReview(ctx context.Context, client GitHubAPI, prNodeID, headRefOID string) error {
+	r.SentViaREST = true
+	return client.PostReview(ctx, prNodeID, headRefOID, r.ReviewEvent, r.Body, r.NewThreads, r.Replies)
+}
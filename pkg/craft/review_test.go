@@ -0,0 +1,391 @@
+package craft
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/shurcooL/githubv4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExpandSnippet(t *testing.T) {
+	snippets := map[string]string{
+		"nit":      "**nit:** {body}",
+		"blocking": "**blocking:** {body}\n\nPlease fix before merging.",
+		"praise":   "👍 Nice!",
+		"where":    "See {path}:{line}.",
+	}
+
+	tests := []struct {
+		name string
+		body string
+		path string
+		line int
+		want string
+	}{
+		{"no snippet", "just a comment", "a.go", 1, "just a comment"},
+		{"body placeholder", "nit use a constant", "a.go", 1, "**nit:** use a constant"},
+		{"trailing colon", "nit: use a constant", "a.go", 1, "**nit:** use a constant"},
+		{"multi paragraph", "blocking\nthis leaks", "a.go", 1, "**blocking:** this leaks\n\nPlease fix before merging."},
+		{"no placeholder appends", "praise great test", "a.go", 1, "👍 Nice! great test"},
+		{"no placeholder alone", "praise", "a.go", 1, "👍 Nice!"},
+		{"path and line", "where", "a.go", 12, "See a.go:12."},
+		{"not first word", "this is a nit", "a.go", 1, "this is a nit"},
+		{"case sensitive", "Nit foo", "a.go", 1, "Nit foo"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, expandSnippet(snippets, tt.body, tt.path, tt.line))
+		})
+	}
+}
+
+func TestCollectNewCommentsExpandsSnippets(t *testing.T) {
+	pr := &PullRequest{
+		ReviewThreads: []ReviewThread{
+			{
+				Path:     "file.go",
+				Line:     10,
+				DiffSide: DiffSideRight,
+				Comments: []ReviewComment{
+					{ID: "PRRC_existing", Author: Actor{Login: "alice"}, Body: "nit not expanded"},
+					{IsNew: true, Body: "nit reply"},
+				},
+			},
+			{
+				Path:     "file.go",
+				Line:     20,
+				DiffSide: DiffSideRight,
+				Comments: []ReviewComment{{IsNew: true, Body: "nit: new thread"}},
+			},
+		},
+		IssueComments: []IssueComment{{IsNew: true, Body: "nit overall"}},
+	}
+
+	opts := CollectOptions{Snippets: map[string]string{"nit": "**nit:** {body}"}}
+	review, err := CollectNewComments(pr, opts)
+	require.NoError(t, err)
+
+	require.Len(t, review.NewThreads, 1)
+	require.Len(t, review.Replies, 1)
+	assert.Equal(t, "**nit:** new thread", review.NewThreads[0].Body)
+	assert.Equal(t, "**nit:** reply", review.Replies[0].Body)
+	assert.Equal(t, "**nit:** overall", review.Body)
+	assert.Equal(t, "nit not expanded", pr.ReviewThreads[0].Comments[0].Body)
+}
+
+func TestCollectNewThreadFollowUps(t *testing.T) {
+	pr := &PullRequest{
+		ReviewThreads: []ReviewThread{{
+			Path:     "file.go",
+			Line:     10,
+			DiffSide: DiffSideRight,
+			Comments: []ReviewComment{
+				{IsNew: true, Body: "First"},
+				{IsNew: true, Body: "nit second"},
+				{IsNew: true, Body: ""},
+			},
+		}},
+	}
+	review, err := CollectNewComments(pr, CollectOptions{Snippets: map[string]string{"nit": "**nit:** {body}"}})
+	require.NoError(t, err)
+	require.Len(t, review.NewThreads, 1)
+	assert.Empty(t, review.Replies)
+	assert.Equal(t, "First\n\n**nit:** second", review.NewThreads[0].Body)
+}
+
+func TestCollectNewRangeComments(t *testing.T) {
+	content := "package main\n" +
+		"\n" +
+		"func main() {\n" +
+		"\tx()\n" +
+		"// ╓───── new ─ range -2\n" +
+		"// ║ Relative range\n" +
+		"\ty()\n" +
+		"}\n" +
+		"// ╓───── new ─ lines 3-5\n" +
+		"// ║ Absolute range\n" +
+		"// ╓───── new ─ lines 1\n" +
+		"// ║ Absolute line\n"
+	threads, parseErrs := ParseFileComments(SerializeOptions{}, "main.go", strings.Split(content, "\n"), GetCommentStyle("main.go"))
+	require.Empty(t, parseErrs)
+
+	review, err := CollectNewComments(&PullRequest{ReviewThreads: threads}, CollectOptions{})
+	require.NoError(t, err)
+	type span struct{ start, end int }
+	var spans []span
+	for _, thread := range review.NewThreads {
+		s := span{0, thread.Line}
+		if thread.StartLine != nil {
+			s.start = *thread.StartLine
+		}
+		spans = append(spans, s)
+	}
+	assert.Equal(t, []span{{2, 4}, {3, 5}, {0, 1}}, spans)
+
+	// Ranges that can't be right are reported
+	for _, header := range []string{"range -5", "lines 4-2", "lines 0-1"} {
+		lines := []string{"package main", "// ╓───── new ─ " + header, "// ║ Bad"}
+		_, parseErrs := ParseFileComments(SerializeOptions{}, "main.go", lines, GetCommentStyle("main.go"))
+		require.Len(t, parseErrs, 1, header)
+		assert.Equal(t, 2, parseErrs[0].Line)
+	}
+}
+
+func TestCollectResolves(t *testing.T) {
+	thread := func(id string, resolved bool, comments ...ReviewComment) ReviewThread {
+		return ReviewThread{ID: id, Path: "main.go", Line: 3, IsResolved: resolved, Comments: comments}
+	}
+	local := &PullRequest{ReviewThreads: []ReviewThread{
+		thread("", true, ReviewComment{ID: "PRRC_a"}),                                           // newly resolved
+		thread("", true, ReviewComment{ID: "PRRC_b"}, ReviewComment{IsNew: true, Body: "Done"}), // and replied to
+		thread("", true, ReviewComment{ID: "PRRC_c"}),                                           // resolved on GitHub already
+		thread("", false, ReviewComment{ID: "PRRC_d"}),
+		thread("", true, ReviewComment{IsNew: true, Body: "New"}), // new threads can't be resolved
+	}}
+	review, err := CollectNewComments(local, CollectOptions{})
+	require.NoError(t, err)
+	assert.Len(t, review.Resolves, 3)
+	assert.Len(t, review.Replies, 1)
+	assert.Contains(t, review.Summary(), "3 thread(s) to resolve")
+
+	remote := &PullRequest{ReviewThreads: []ReviewThread{
+		thread("PRRT_a", false, ReviewComment{ID: "PRRC_a"}),
+		thread("PRRT_b", false, ReviewComment{ID: "PRRC_b"}),
+		thread("PRRT_c", true, ReviewComment{ID: "PRRC_c"}),
+		thread("PRRT_d", false, ReviewComment{ID: "PRRC_d"}),
+	}}
+	review.MatchResolves(remote)
+	var ids []string
+	for _, res := range review.Resolves {
+		ids = append(ids, res.ThreadID)
+	}
+	assert.Equal(t, []string{"PRRT_a", "PRRT_b"}, ids)
+
+	// Resolving alone isn't an empty review
+	review.NewThreads, review.Replies = nil, nil
+	assert.False(t, review.IsEmpty())
+	review.Resolves = nil
+	assert.True(t, review.IsEmpty())
+}
+
+func TestExtractMentions(t *testing.T) {
+	body := "Thanks @alice and @Bob-2! cc @org/team, mail me at x@example.com.\n" +
+		"`@notme` and\n```\n@alsonotme\n```\n@carol"
+	assert.Equal(t, []string{"alice", "Bob-2", "carol"}, extractMentions(body))
+}
+
+// fakeGitHub is a GitHubAPI that records the review mutations made, and has
+// a pending review if pending is set. Methods it doesn't override panic.
+type fakeGitHub struct {
+	GitHubAPI
+	pending    bool
+	failDelete string // a comment whose deletion fails
+	calls      []string
+}
+
+func (f *fakeGitHub) GetPendingReview(ctx context.Context, prNodeID string) (githubv4.ID, bool, error) {
+	if f.pending {
+		return "PRR_old", true, nil
+	}
+	return nil, false, nil
+}
+
+func (f *fakeGitHub) DeletePendingReview(ctx context.Context, reviewID githubv4.ID) error {
+	f.calls = append(f.calls, fmt.Sprintf("delete %v", reviewID))
+	return nil
+}
+
+func (f *fakeGitHub) StartReviewWithThreads(ctx context.Context, prNodeID, commitOID string, threads []NewThreadInfo) (githubv4.ID, error) {
+	f.calls = append(f.calls, fmt.Sprintf("start %s@%s with %d thread(s)", prNodeID, commitOID, len(threads)))
+	return "PRR_new", nil
+}
+
+func (f *fakeGitHub) AddFileThread(ctx context.Context, reviewID githubv4.ID, t NewThreadInfo) error {
+	f.calls = append(f.calls, fmt.Sprintf("file thread %v %s", reviewID, t.Path))
+	return nil
+}
+
+func (f *fakeGitHub) AddReviewComment(ctx context.Context, reviewID githubv4.ID, replyToNodeID, body string) (string, error) {
+	f.calls = append(f.calls, fmt.Sprintf("reply %v %s %q", reviewID, replyToNodeID, body))
+	return "PRRC_reply", nil
+}
+
+func (f *fakeGitHub) SubmitReview(ctx context.Context, reviewID githubv4.ID, eventType, body string) error {
+	f.calls = append(f.calls, fmt.Sprintf("submit %v %s %q", reviewID, eventType, body))
+	return nil
+}
+
+func (f *fakeGitHub) ResolveThread(ctx context.Context, threadID string) error {
+	f.calls = append(f.calls, "resolve "+threadID)
+	return nil
+}
+
+func (f *fakeGitHub) FetchReviewCommentIDs(ctx context.Context, reviewID string) ([]string, error) {
+	return []string{"PRRC_1", "PRRC_2"}, nil
+}
+
+func TestSendReview(t *testing.T) {
+	review := &ReviewToSend{
+		NewThreads: []NewThreadInfo{
+			{Path: "a.go", Line: 5, Side: DiffSideRight, Body: "line"},
+			{Path: "b.go", Subject: SubjectTypeFile, Body: "file"},
+		},
+		Replies:     []ReplyInfo{{ThreadPath: "a.go", ThreadLine: 1, Body: "reply", ReplyToNodeID: "PRRC_x"}},
+		Resolves:    []ResolveInfo{{ThreadPath: "a.go", ThreadLine: 1, ThreadID: "PRRT_x"}},
+		Body:        "overall",
+		ReviewEvent: "COMMENT",
+	}
+
+	t.Run("new review", func(t *testing.T) {
+		client := &fakeGitHub{}
+		require.NoError(t, review.Send(t.Context(), client, "PR_x", "abc123", false))
+		assert.Equal(t, []string{
+			"start PR_x@abc123 with 2 thread(s)",
+			"file thread PRR_new b.go",
+			`reply PRR_new PRRC_x "reply"`,
+			`submit PRR_new COMMENT "overall"`,
+			"resolve PRRT_x",
+		}, client.calls)
+	})
+
+	t.Run("pending review in the way", func(t *testing.T) {
+		client := &fakeGitHub{pending: true}
+		assert.ErrorIs(t, review.Send(t.Context(), client, "PR_x", "abc123", false), ErrPendingReviewExists)
+		assert.Empty(t, client.calls)
+	})
+
+	t.Run("pending review discarded", func(t *testing.T) {
+		client := &fakeGitHub{pending: true}
+		require.NoError(t, review.Send(t.Context(), client, "PR_x", "abc123", true))
+		assert.Equal(t, "delete PRR_old", client.calls[0])
+	})
+
+	t.Run("replies go in the pending review", func(t *testing.T) {
+		client := &fakeGitHub{pending: true}
+		r := &ReviewToSend{Replies: review.Replies, ReviewEvent: "PENDING"}
+		require.NoError(t, r.Send(t.Context(), client, "PR_x", "abc123", false))
+		assert.Equal(t, []string{`reply PRR_old PRRC_x "reply"`}, client.calls)
+	})
+}
+
+func (f *fakeGitHub) UpdateIssueComment(ctx context.Context, commentID, body string) error {
+	f.calls = append(f.calls, fmt.Sprintf("edit %s %q", commentID, body))
+	return nil
+}
+
+func (f *fakeGitHub) DeleteIssueComment(ctx context.Context, commentID string) error {
+	f.calls = append(f.calls, "delete "+commentID)
+	return nil
+}
+
+func (f *fakeGitHub) FetchViewerLogin(ctx context.Context) (string, error) {
+	return "me", nil
+}
+
+func TestIssueCommentChanges(t *testing.T) {
+	pr := &PullRequest{
+		ID: "PR_x", Number: 1, HeadRefOID: "abc123",
+		IssueComments: []IssueComment{
+			{ID: "IC_a", Author: Actor{Login: "me"}, Body: "Frist!"},
+			{ID: "IC_b", Author: Actor{Login: "me"}, Body: "Oops, wrong PR"},
+			{ID: "IC_c", Author: Actor{Login: "bob"}, Body: "Left alone"},
+		},
+	}
+	memfs := fstest.MapFS{}
+	opts := SerializeOptions{FS: memfs}
+	require.NoError(t, Serialize(pr, opts))
+
+	// Edit one comment's body and mark another deleted
+	content := string(memfs[PRStateFile].Data)
+	content = strings.Replace(content, "Frist!", "First!", 1)
+	lines := strings.Split(content, "\n")
+	for i, line := range lines {
+		if strings.HasPrefix(line, "Oops") {
+			lines[i-1] += HeaderFieldSep + deleteField
+		}
+	}
+	memfs[PRStateFile] = &fstest.MapFile{Data: []byte(strings.Join(lines, "\n"))}
+
+	got, err := Deserialize(opts)
+	require.NoError(t, err)
+	review, err := CollectNewComments(got, CollectOptions{Viewer: "me"})
+	require.NoError(t, err)
+	assert.Equal(t, []IssueCommentChange{
+		{ID: "IC_a", Author: "me", Body: "First!"},
+		{ID: "IC_b", Author: "me", Delete: true},
+	}, review.IssueCommentChanges)
+	assert.False(t, review.IsEmpty())
+
+	client := &fakeGitHub{}
+	require.NoError(t, review.Send(t.Context(), client, "PR_x", "abc123", false))
+	assert.Equal(t, []string{`edit IC_a "First!"`, "delete IC_b"}, client.calls)
+
+	// Someone else's comment can't be edited, or deleted
+	for _, edit := range []func(string) string{
+		func(s string) string { return strings.Replace(s, "Left alone", "Not left alone", 1) },
+		func(s string) string {
+			lines := strings.Split(s, "\n")
+			for i, line := range lines {
+				if line == "Left alone" {
+					lines[i-1] += HeaderFieldSep + deleteField
+				}
+			}
+			return strings.Join(lines, "\n")
+		},
+	} {
+		memfs[PRStateFile] = &fstest.MapFile{Data: []byte(edit(content))}
+		got, err = Deserialize(opts)
+		require.NoError(t, err)
+		_, err = CollectNewComments(got, CollectOptions{Viewer: "me"})
+		assert.ErrorContains(t, err, "PR-level comment by @bob")
+	}
+}
+
+func (f *fakeGitHub) UpdateReviewBody(ctx context.Context, reviewID githubv4.ID, body string) error {
+	f.calls = append(f.calls, fmt.Sprintf("body %v %q", reviewID, body))
+	return nil
+}
+
+func TestPendingReviewBody(t *testing.T) {
+	pr := &PullRequest{
+		ID: "PR_x", Number: 1, HeadRefOID: "abc123",
+		Reviews: []Review{
+			{ID: "PRR_done", Author: Actor{Login: "bob"}, State: ReviewStateApproved, Body: "LGTM"},
+			{ID: "PRR_old", Author: Actor{Login: "me"}, State: ReviewStatePending, Body: "Summary from the web"},
+		},
+	}
+	memfs := fstest.MapFS{}
+	opts := SerializeOptions{FS: memfs}
+	require.NoError(t, Serialize(pr, opts))
+	content := string(memfs[PRStateFile].Data)
+	assert.Contains(t, content, HeaderFieldSep+pendingReviewField)
+	assert.NotContains(t, content, "LGTM")
+
+	// Unchanged, there's nothing to send
+	got, err := Deserialize(opts)
+	require.NoError(t, err)
+	review, err := CollectNewComments(got, CollectOptions{})
+	require.NoError(t, err)
+	assert.True(t, review.IsEmpty())
+
+	content = strings.Replace(content, "Summary from the web", "Summary, edited", 1)
+	memfs[PRStateFile] = &fstest.MapFile{Data: []byte(content)}
+	got, err = Deserialize(opts)
+	require.NoError(t, err)
+	assert.Empty(t, got.IssueComments)
+	review, err = CollectNewComments(got, CollectOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "PRR_old", review.PendingReviewID)
+	assert.Equal(t, "Summary, edited", review.PendingReviewBody)
+
+	client := &fakeGitHub{pending: true}
+	review.ReviewEvent = "PENDING"
+	require.NoError(t, review.Send(t.Context(), client, "PR_x", "abc123", false))
+	assert.Equal(t, []string{`body PRR_old "Summary, edited"`}, client.calls)
+}
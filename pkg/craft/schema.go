@@ -0,0 +1,73 @@
+package craft
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// SchemaVersion is the version of the JSON of a PullRequest, written in its
+// schemaVersion field. It goes up when a change to the model would make
+// older JSON mean something else, with a migration in migrations.
+const SchemaVersion = 1
+
+// migrations[v] turns the JSON of schema version v, decoded into a map, into
+// that of version v+1.
+var migrations = map[int]func(pr map[string]any) error{
+	// JSON from before there were versions is the same as version 1
+	0: func(map[string]any) error { return nil },
+}
+
+// MarshalJSON writes the PR with the current schemaVersion.
+func (pr PullRequest) MarshalJSON() ([]byte, error) {
+	type plain PullRequest // without this method
+	p := plain(pr)
+	p.SchemaVersion = SchemaVersion
+	return json.Marshal(p)
+}
+
+// ParsePullRequestJSON reads the JSON of a PullRequest, migrating it from an
+// older schema version if need be. JSON of a newer version than this craft
+// knows is an error, rather than being read wrong.
+func ParsePullRequestJSON(data []byte) (*PullRequest, error) {
+	var raw map[string]any
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber() // so IDs survive a migration exactly
+	if err := dec.Decode(&raw); err != nil {
+		return nil, err
+	}
+
+	version := 0
+	if v, ok := raw["schemaVersion"]; ok {
+		n, ok := v.(json.Number)
+		if !ok {
+			return nil, fmt.Errorf("invalid schemaVersion %v", v)
+		}
+		i, err := n.Int64()
+		if err != nil || i < 0 {
+			return nil, fmt.Errorf("invalid schemaVersion %v", v)
+		}
+		version = int(i)
+	}
+	if version > SchemaVersion {
+		return nil, fmt.Errorf("schemaVersion %d is newer than this craft reads (%d); upgrade craft", version, SchemaVersion)
+	}
+	if version < SchemaVersion {
+		for v := version; v < SchemaVersion; v++ {
+			if err := migrations[v](raw); err != nil {
+				return nil, fmt.Errorf("migrating from schemaVersion %d: %w", v, err)
+			}
+		}
+		var err error
+		if data, err = json.Marshal(raw); err != nil {
+			return nil, err
+		}
+	}
+
+	pr := &PullRequest{}
+	if err := json.Unmarshal(data, pr); err != nil {
+		return nil, err
+	}
+	pr.SchemaVersion = SchemaVersion
+	return pr, nil
+}
@@ -0,0 +1,32 @@
+package craft
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPullRequestJSONSchemaVersion(t *testing.T) {
+	pr := &PullRequest{Number: 7, ReviewThreads: []ReviewThread{{ID: "T1", Comments: []ReviewComment{{DatabaseID: 9007199254740993}}}}}
+	data, err := json.Marshal(pr)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"schemaVersion":1`)
+
+	got, err := ParsePullRequestJSON(data)
+	require.NoError(t, err)
+	assert.Equal(t, 7, got.Number)
+	assert.Equal(t, SchemaVersion, got.SchemaVersion)
+
+	// Unversioned JSON is migrated, keeping big IDs exact
+	got, err = ParsePullRequestJSON([]byte(`{"number":7,"reviewThreads":[{"id":"T1","comments":[{"databaseId":9007199254740993}]}]}`))
+	require.NoError(t, err)
+	assert.Equal(t, SchemaVersion, got.SchemaVersion)
+	assert.Equal(t, int64(9007199254740993), got.ReviewThreads[0].Comments[0].DatabaseID)
+
+	_, err = ParsePullRequestJSON([]byte(`{"schemaVersion":99}`))
+	assert.ErrorContains(t, err, "newer")
+	_, err = ParsePullRequestJSON([]byte(`{"schemaVersion":"one"}`))
+	assert.ErrorContains(t, err, "invalid schemaVersion")
+}
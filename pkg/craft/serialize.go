@@ -0,0 +1,1984 @@
+package craft
+
+import (
+	"bytes"
+	"cmp"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/pmezard/go-difflib/difflib"
+	"rsc.io/markdown"
+)
+
+const (
+	// Box drawing characters for craft comments
+	BoxThread = "╓" // start of new thread (header line)
+	BoxReply  = "╟" // reply within thread (header line)
+	BoxBody   = "║" // body line
+	BoxHunk   = "┆" // quoted diff hunk or previous body under a header
+	BoxStart  = "╒" // marks the first line of a range thread's range
+	BoxChange = "▼" // marks lines changed in the PR (see SerializeOptions.MarkChanges)
+
+	// ASCII alternatives to the box characters, for terminals and fonts that
+	// render them poorly (see SerializeOptions.ASCII). Both are always parsed.
+	ASCIIThread = "|>"
+	ASCIIReply  = "|+"
+	ASCIIBody   = "|"
+	ASCIIHunk   = "|:"
+	ASCIIStart  = "|^"
+	ASCIIChange = "|v"
+
+	RangeStartText = "range start" // content of the BoxStart marker line
+
+	HeaderStart     = "─────"
+	HeaderFieldSep  = " ─ "
+	PRStateFile     = "PR-STATE.txt"
+	OutdatedFile    = "PR-OUTDATED.txt"   // see SerializeOptions.OutdatedFile
+	DescriptionFile = "PR-DESCRIPTION.md" // see serializeDescription
+	DefaultWrap     = 80                  // Default wrap width for comment text (see SerializeOptions.WrapWidth)
+
+	// HeaderStart and HeaderFieldSep, in headers written with ASCII markers
+	ASCIIHeaderStart = "-----"
+	ASCIIFieldSep    = " - "
+
+	OutdatedCommentsHeader = "━━━━━━━━━ outdated comments"
+
+	// maxHunkLines is how many lines of an outdated thread's diff hunk are
+	// quoted, counting back from the commented line
+	maxHunkLines = 6
+)
+
+// GetIndent returns the leading whitespace of a line.
+func GetIndent(line string) string {
+	for i, c := range line {
+		if c != ' ' && c != '\t' {
+			return line[:i]
+		}
+	}
+	return line // all whitespace
+}
+
+// utf8BOM is the byte order mark some editors put at the start of files.
+const utf8BOM = "\uFEFF"
+
+// FileEncoding records a file's BOM and line endings, which serialization
+// works without and restores on write.
+type FileEncoding struct {
+	bom  bool
+	crlf bool // every line ends in \r\n
+}
+
+// DecodeFile strips the BOM from content and converts CRLF line endings to LF.
+// Files with mixed line endings are left as they are.
+func DecodeFile(content string) (FileEncoding, string) {
+	var enc FileEncoding
+	content, enc.bom = strings.CutPrefix(content, utf8BOM)
+	if n := strings.Count(content, "\r\n"); n > 0 && n == strings.Count(content, "\n") {
+		enc.crlf = true
+		content = strings.ReplaceAll(content, "\r\n", "\n")
+	}
+	return enc, content
+}
+
+// encode reverses DecodeFile.
+func (e FileEncoding) Encode(content string) string {
+	if e.crlf {
+		content = strings.ReplaceAll(content, "\n", "\r\n")
+	}
+	if e.bom {
+		content = utf8BOM + content
+	}
+	return content
+}
+
+// wrapCommentBody wraps a comment body to fit within the given width,
+// accounting for the prefix that will be added to each line.
+func wrapCommentBody(body string, width, prefixLen int) string {
+	return wrapBody(body, width, prefixLen, Wrap)
+}
+
+// RewrapCommentBody is wrapCommentBody for a body read back from the files,
+// which keeps tables whole so the body still matches its sum.
+func RewrapCommentBody(body string, width, prefixLen int) string {
+	return wrapBody(body, width, prefixLen, Rewrap)
+}
+
+func wrapBody(body string, width, prefixLen int, wrap func(markdown.Block, int) markdown.Block) string {
+	width -= prefixLen
+	if width < 20 {
+		width = 20 // minimum reasonable width
+	}
+
+	doc := NewMarkdownParser().Parse(body)
+	wrapped := wrap(doc, width)
+	result := markdown.Format(wrapped)
+
+	// Trim trailing newline that Format adds
+	return EscapeCommentBody(strings.TrimSuffix(result, "\n"))
+}
+
+// formatCommentBody returns the body lines to serialize for a comment. In
+// verbatim mode the body is written as-is; otherwise it is wrapped.
+func formatCommentBody(body string, width, prefixLen int, opts SerializeOptions) string {
+	if opts.NoReflow {
+		return EscapeCommentBody(body)
+	}
+	return wrapCommentBody(body, width, prefixLen)
+}
+
+// ParseCommentBody reverses formatCommentBody. Verbatim bodies are only
+// stripped of surrounding blank lines, so they round-trip byte-for-byte.
+func ParseCommentBody(bodyLines []string, verbatim bool) string {
+	body := unescapeCommentBody(strings.Join(bodyLines, "\n"))
+	if verbatim {
+		return strings.Trim(body, "\n")
+	}
+	// Unwrap soft-wrapped lines to restore original markdown
+	return unwrapCommentBody(strings.TrimSpace(body))
+}
+
+var (
+	// Body lines that start with ─ (after any whitespace and backslashes)
+	// could be mistaken for a header, so they get an extra backslash.
+	escapeBodyRe   = regexp.MustCompile(`(?m)^([ \t]*)(\\*─)`)
+	unescapeBodyRe = regexp.MustCompile(`(?m)^([ \t]*)\\(\\*─)`)
+)
+
+// EscapeCommentBody escapes body lines that look like headers, so that any
+// body round-trips through serialization. See unescapeCommentBody.
+func EscapeCommentBody(body string) string {
+	return escapeBodyRe.ReplaceAllString(body, `$1\$2`)
+}
+
+// unescapeCommentBody reverses EscapeCommentBody.
+func unescapeCommentBody(body string) string {
+	return unescapeBodyRe.ReplaceAllString(body, `$1$2`)
+}
+
+// bodySum returns a short hash of a comment body, used to tell whether a
+// serialized comment was edited locally.
+func bodySum(body string) string {
+	sum := sha256.Sum256([]byte(body))
+	return hex.EncodeToString(sum[:4])
+}
+
+// serializedBodySum returns the bodySum of what deserializing the formatted
+// body will produce, so untouched comments match regardless of how wrapping
+// and unwrapping normalized their markdown.
+func serializedBodySum(formatted string, verbatim bool) string {
+	return bodySum(ParseCommentBody(strings.Split(formatted, "\n"), verbatim))
+}
+
+// restoreCommentBody checks a deserialized body against the sum from its
+// header. It returns the original body from opts.Originals if the comment
+// is unchanged, or the local body and whether it was modified.
+func restoreCommentBody(opts SerializeOptions, h Header, body string) (string, bool) {
+	if h.Sum == "" {
+		return body, false
+	}
+	if bodySum(body) != h.Sum {
+		return body, true
+	}
+	if orig, ok := opts.Originals[h.NodeID]; ok {
+		return orig, false
+	}
+	return body, false
+}
+
+// unwrapCommentBody joins soft-wrapped lines in a comment body.
+func unwrapCommentBody(body string) string {
+	doc := NewMarkdownParser().Parse(body)
+	unwrapped := Unwrap(doc)
+	result := markdown.Format(unwrapped)
+
+	// Trim trailing newline that Format adds
+	return strings.TrimSuffix(result, "\n")
+}
+
+// CommentStyle defines how comments work for a language.
+type CommentStyle struct {
+	LinePrefix string // e.g., "//" or "#"
+}
+
+var commentStyles = map[string]CommentStyle{
+	".go":    {LinePrefix: "//"},
+	".js":    {LinePrefix: "//"},
+	".ts":    {LinePrefix: "//"},
+	".tsx":   {LinePrefix: "//"},
+	".jsx":   {LinePrefix: "//"},
+	".java":  {LinePrefix: "//"},
+	".c":     {LinePrefix: "//"},
+	".cpp":   {LinePrefix: "//"},
+	".cc":    {LinePrefix: "//"},
+	".h":     {LinePrefix: "//"},
+	".hpp":   {LinePrefix: "//"},
+	".rs":    {LinePrefix: "//"},
+	".swift": {LinePrefix: "//"},
+	".kt":    {LinePrefix: "//"},
+	".scala": {LinePrefix: "//"},
+	".py":    {LinePrefix: "#"},
+	".rb":    {LinePrefix: "#"},
+	".sh":    {LinePrefix: "#"},
+	".bash":  {LinePrefix: "#"},
+	".zsh":   {LinePrefix: "#"},
+	".pl":    {LinePrefix: "#"},
+	".pm":    {LinePrefix: "#"},
+	".r":     {LinePrefix: "#"},
+	".R":     {LinePrefix: "#"},
+	".yaml":  {LinePrefix: "#"},
+	".yml":   {LinePrefix: "#"},
+	".toml":  {LinePrefix: "#"},
+	".tf":    {LinePrefix: "#"},
+	".lua":   {LinePrefix: "--"},
+	".hs":    {LinePrefix: "--"},
+	".sql":   {LinePrefix: "--"},
+	".elm":   {LinePrefix: "--"},
+	".erl":   {LinePrefix: "%"},
+	".ex":    {LinePrefix: "#"},
+	".exs":   {LinePrefix: "#"},
+	".clj":   {LinePrefix: ";;"},
+	".lisp":  {LinePrefix: ";;"},
+	".scm":   {LinePrefix: ";;"},
+	".vim":   {LinePrefix: "\""},
+	".el":    {LinePrefix: ";;"},
+}
+
+func GetCommentStyle(path string) CommentStyle {
+	if path == OutdatedFile {
+		return CommentStyle{} // nothing but craft comments
+	}
+	ext := filepath.Ext(path)
+	if style, ok := commentStyles[ext]; ok {
+		return style
+	}
+	// Default to // for unknown extensions
+	return CommentStyle{LinePrefix: "//"}
+}
+
+// CraftPrefix returns what craft lines start with in a file whose comments
+// start with linePrefix. Files with no comment syntax (linePrefix "") have
+// craft lines start with the box character.
+func CraftPrefix(linePrefix string) string {
+	if linePrefix == "" {
+		return ""
+	}
+	return linePrefix + " "
+}
+
+// FormatCraftLine formats a line of craft content for a source file.
+// boxChar should be BoxThread, BoxReply, or BoxBody.
+// For headers (starting with ─), no space between box char and content: ╓─────
+// For body lines, space after box char: ║ text
+func FormatCraftLine(linePrefix, boxChar, content string) string {
+	if strings.HasPrefix(content, "─") {
+		if boxChar == ASCIIThread || boxChar == ASCIIReply {
+			content = replaceHeaderDashes(content, HeaderStart, HeaderFieldSep, ASCIIHeaderStart, ASCIIFieldSep)
+		}
+		return CraftPrefix(linePrefix) + boxChar + content
+	}
+	if content == "" {
+		return CraftPrefix(linePrefix) + boxChar
+	}
+	return CraftPrefix(linePrefix) + boxChar + " " + content
+}
+
+// replaceHeaderDashes rewrites the start and field separators of a header
+// line, outside quoted values, to switch it between the box drawing and ASCII
+// forms. Other lines are returned as they are.
+func replaceHeaderDashes(header, fromStart, fromSep, toStart, toSep string) string {
+	rest, ok := strings.CutPrefix(header, fromStart)
+	if !ok {
+		return header
+	}
+	var b strings.Builder
+	b.WriteString(toStart)
+	inQuote := false
+	for i := 0; i < len(rest); i++ {
+		switch {
+		case inQuote && rest[i] == '\\' && i+1 < len(rest):
+			b.WriteString(rest[i : i+2]) // escaped char
+			i++
+			continue
+		case rest[i] == '"':
+			inQuote = !inQuote
+		case !inQuote && strings.HasPrefix(rest[i:], fromSep):
+			b.WriteString(toSep)
+			i += len(fromSep) - 1
+			continue
+		}
+		b.WriteByte(rest[i])
+	}
+	return b.String()
+}
+
+// formatHunkLine formats a quoted diff hunk line. Unlike FormatCraftLine, the
+// content is always separated by a space, since diff lines can start with ─.
+func formatHunkLine(linePrefix, boxChar, content string) string {
+	return strings.TrimRight(CraftPrefix(linePrefix)+boxChar+" "+content, " \t")
+}
+
+// SuggestionBlock returns the contents of the first ```suggestion block in
+// a comment body.
+func SuggestionBlock(body string) (string, bool) {
+	var inBlock bool
+	var result []string
+	for _, line := range strings.Split(body, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if !inBlock {
+			inBlock = trimmed == "```suggestion"
+			continue
+		}
+		if trimmed == "```" {
+			return strings.Join(result, "\n"), true
+		}
+		result = append(result, line)
+	}
+	return "", false
+}
+
+// quotedHunk returns the last lines of a comment's diff hunk, which ends at
+// the commented line, to quote under an outdated thread.
+func quotedHunk(diffHunk string) []string {
+	if diffHunk == "" {
+		return nil
+	}
+	lines := strings.Split(strings.TrimRight(diffHunk, "\n"), "\n")
+	if len(lines) > maxHunkLines {
+		lines = lines[len(lines)-maxHunkLines:]
+	}
+	return lines
+}
+
+// quotedPreviousBody returns the lines quoting a comment's body from before
+// its last edit, at most maxHunkLines of it.
+func quotedPreviousBody(body string) []string {
+	body = strings.TrimSpace(body)
+	if body == "" {
+		return nil
+	}
+	lines := strings.Split(body, "\n")
+	if len(lines) > maxHunkLines {
+		lines = append(lines[:maxHunkLines], "…")
+	}
+	return append([]string{"before edit:"}, lines...)
+}
+
+// BoxSet is the set of markers used to write craft comments.
+type BoxSet struct {
+	Thread, Reply, Body, Hunk, Start, Change string
+}
+
+var (
+	UnicodeBoxes = BoxSet{Thread: BoxThread, Reply: BoxReply, Body: BoxBody, Hunk: BoxHunk, Start: BoxStart, Change: BoxChange}
+	ASCIIBoxes   = BoxSet{Thread: ASCIIThread, Reply: ASCIIReply, Body: ASCIIBody, Hunk: ASCIIHunk, Start: ASCIIStart, Change: ASCIIChange}
+)
+
+// isCraftLine checks if a line (after trimming) starts with a craft box character.
+// Returns the box char and remaining content, or empty string if not a craft line.
+// ASCII headers are recognized and returned as the equivalent box char, but
+// ASCII body lines are not, since they look like ordinary comments out of
+// context; use ParseCraftLines for those.
+func ParseCraftLine(line, commentPrefix string) (boxChar, content string, ok bool) {
+	l := parseCraftLineASCII(line, commentPrefix, false)
+	return l.Box, l.Content, l.OK
+}
+
+// CraftLine is a parsed line of a source file.
+type CraftLine struct {
+	Box     string // BoxThread, BoxReply, BoxBody, BoxHunk, BoxStart or BoxChange, for either alphabet
+	Content string
+	ASCII   bool // written with ASCII markers
+	OK      bool // is a craft line
+}
+
+// ParseCraftLines parses each line of a source file like ParseCraftLine,
+// additionally recognizing ASCII body lines in a comment opened by an ASCII
+// header, up to the first line that isn't craft data.
+func ParseCraftLines(lines []string, commentPrefix string) []CraftLine {
+	result := make([]CraftLine, len(lines))
+	inASCII := false
+	for i, line := range lines {
+		l := parseCraftLineASCII(line, commentPrefix, inASCII)
+		result[i] = l
+		if l.Box == BoxThread || l.Box == BoxReply {
+			inASCII = l.ASCII
+		} else if !l.OK {
+			inASCII = false
+		}
+	}
+	return result
+}
+
+// parseCraftLineASCII parses a single line. allowASCIIBody reports whether
+// an ASCII body line is allowed here.
+func parseCraftLineASCII(line, commentPrefix string, allowASCIIBody bool) CraftLine {
+	line = strings.TrimSpace(line)
+	prefix := CraftPrefix(commentPrefix)
+	if !strings.HasPrefix(line, prefix) {
+		return CraftLine{}
+	}
+	line = strings.TrimPrefix(line, prefix)
+	// Check for any of the box characters
+	for _, box := range []string{BoxThread, BoxReply, BoxBody, BoxHunk, BoxStart, BoxChange} {
+		if strings.HasPrefix(line, box) {
+			content := strings.TrimPrefix(line, box)
+			content = strings.TrimPrefix(content, " ") // optional space after box char
+			return CraftLine{Box: box, Content: content, OK: true}
+		}
+	}
+	// ASCII headers are unambiguous: |>----- or |+-----, or |>───── and
+	// |+───── as written by older versions. Their content is returned in the
+	// box drawing form, for ParseHeader.
+	for _, b := range []struct{ box, ascii string }{{BoxThread, ASCIIThread}, {BoxReply, ASCIIReply}} {
+		rest, ok := strings.CutPrefix(line, b.ascii)
+		if ok && strings.HasPrefix(rest, ASCIIHeaderStart) {
+			content := replaceHeaderDashes(rest, ASCIIHeaderStart, ASCIIFieldSep, HeaderStart, HeaderFieldSep)
+			return CraftLine{Box: b.box, Content: content, ASCII: true, OK: true}
+		}
+		if ok && strings.HasPrefix(rest, HeaderStart) {
+			return CraftLine{Box: b.box, Content: rest, ASCII: true, OK: true}
+		}
+	}
+	// ASCII range start markers are only recognized with their text
+	if rest, ok := strings.CutPrefix(line, ASCIIStart); ok && strings.TrimSpace(rest) == RangeStartText {
+		return CraftLine{Box: BoxStart, Content: RangeStartText, ASCII: true, OK: true}
+	}
+	if rest, ok := strings.CutPrefix(line, ASCIIChange); ok && ChangeMarkerRe.MatchString(strings.TrimSpace(rest)) {
+		return CraftLine{Box: BoxChange, Content: strings.TrimSpace(rest), ASCII: true, OK: true}
+	}
+	if allowASCIIBody && strings.HasPrefix(line, ASCIIHunk) {
+		content := strings.TrimPrefix(line, ASCIIHunk)
+		return CraftLine{Box: BoxHunk, Content: strings.TrimPrefix(content, " "), ASCII: true, OK: true}
+	}
+	if allowASCIIBody && strings.HasPrefix(line, ASCIIBody) {
+		content := strings.TrimPrefix(line, ASCIIBody)
+		if content == "" || strings.HasPrefix(content, " ") {
+			return CraftLine{Box: BoxBody, Content: strings.TrimPrefix(content, " "), ASCII: true, OK: true}
+		}
+	}
+	return CraftLine{}
+}
+
+// rawCraftBody returns the content of a craft body line like ParseCraftLine,
+// but keeps trailing whitespace (which is significant in verbatim bodies).
+func rawCraftBody(line, commentPrefix string) string {
+	line = strings.TrimLeft(line, " \t")
+	for _, body := range []string{BoxBody, ASCIIBody} {
+		if rest, ok := strings.CutPrefix(line, CraftPrefix(commentPrefix)+body); ok {
+			return strings.TrimPrefix(rest, " ")
+		}
+	}
+	return line
+}
+
+// Header represents a parsed comment header.
+type Header struct {
+	Author     string
+	Timestamp  time.Time
+	NodeID     string // Full node ID like "PRRC_kwDOPgi5ks6ZBMOo"
+	IsNew      bool
+	IsFile     bool   // file-level comment
+	Range      int    // negative number for range comments (e.g., -12 means 12 lines above)
+	IsAbove    bool   // thread is placed above its line (or range) instead of below
+	Lines      [2]int // first and last line from a lines field, instead of where the thread is (read only)
+	IsOutdated bool   // code has changed since comment was made
+	IsApprox   bool   // outdated thread placed by craft near its original line
+	IsApplied  bool   // thread's suggestion has been applied to the code
+	IsResolved bool   // thread has been resolved
+	IsEdited   bool   // comment was edited on GitHub after it was posted
+	OrigLine   int    // original line number (for outdated threads)
+	IsVerbatim bool   // body is stored as-is, not wrapped
+	Sum        string // bodySum of the body as serialized (empty for new comments)
+	Version    int    // format version from the vN field (0 if absent)
+
+	// Extra holds fields this version doesn't understand, verbatim, so they
+	// survive a round trip through an older craft.
+	Extra []string
+}
+
+// headerVersion is the header format version written by this craft.
+// Version 2 added the sum, verbatim and quoted-value fields.
+const headerVersion = 2
+
+var headerVersionRe = regexp.MustCompile(`^v([0-9]+)$`)
+
+// formatHeaderVersion returns the version field to write alongside extra, or
+// "" if extra already carries one (from a newer craft).
+func formatHeaderVersion(extra []string) string {
+	for _, field := range extra {
+		if headerVersionRe.MatchString(field) {
+			return ""
+		}
+	}
+	return fmt.Sprintf("v%d", headerVersion)
+}
+
+// parseHeaderVersion parses a vN field. Versions newer than headerVersion are
+// reported as not ok so the caller keeps the field verbatim.
+func parseHeaderVersion(field string) (int, bool) {
+	match := headerVersionRe.FindStringSubmatch(field)
+	if match == nil {
+		return 0, false
+	}
+	v, err := strconv.Atoi(match[1])
+	if err != nil || v > headerVersion {
+		return v, false
+	}
+	return v, true
+}
+
+// FormatNodeID converts a full node ID to the short format for headers.
+// "PRRC_kwDOPgi5ks6ZBMOo" -> "prrc kwDOPgi5ks6ZBMOo"
+func FormatNodeID(id string) string {
+	if id == "" {
+		return ""
+	}
+	idx := strings.Index(id, "_")
+	if idx == -1 {
+		return strings.ToLower(id)
+	}
+	prefix := strings.ToLower(id[:idx])
+	suffix := id[idx+1:]
+	return prefix + " " + suffix
+}
+
+// parseNodeID converts the short format back to full node ID.
+// "prrc kwDOPgi5ks6ZBMOo" -> "PRRC_kwDOPgi5ks6ZBMOo"
+func parseNodeID(s string) string {
+	if s == "" {
+		return ""
+	}
+	parts := strings.SplitN(s, " ", 2)
+	if len(parts) == 1 {
+		return strings.ToUpper(s)
+	}
+	return strings.ToUpper(parts[0]) + "_" + parts[1]
+}
+
+// FormatHeader creates a header line from a Header struct.
+// Result looks like: ────── @author ─ at 2025-01-15 12:34 ─ prrc xxx
+func FormatHeader(h Header) string {
+	var fields []string
+
+	if h.IsNew {
+		fields = append(fields, "new")
+	} else {
+		if h.Author != "" {
+			fields = append(fields, "@"+formatHeaderValue(h.Author))
+		}
+		if !h.Timestamp.IsZero() {
+			fields = append(fields, "at "+h.Timestamp.Format("2006-01-02 15:04"))
+		}
+	}
+
+	if h.IsFile {
+		fields = append(fields, "file")
+	}
+
+	if h.Range != 0 {
+		fields = append(fields, fmt.Sprintf("range %d", h.Range))
+	}
+
+	if h.IsAbove {
+		fields = append(fields, "above")
+	}
+
+	if h.IsOutdated {
+		fields = append(fields, "outdated")
+	}
+
+	if h.IsApprox {
+		fields = append(fields, "approx")
+	}
+
+	if h.IsApplied {
+		fields = append(fields, AppliedField)
+	}
+
+	if h.IsResolved {
+		fields = append(fields, "resolved")
+	}
+
+	if h.IsEdited {
+		fields = append(fields, "edited")
+	}
+
+	if h.IsVerbatim {
+		fields = append(fields, "verbatim")
+	}
+
+	if h.OrigLine != 0 {
+		fields = append(fields, fmt.Sprintf("origline %d", h.OrigLine))
+	}
+
+	if h.Sum != "" {
+		fields = append(fields, "sum "+h.Sum)
+	}
+
+	fields = append(fields, h.Extra...)
+	if !h.IsNew {
+		if v := formatHeaderVersion(h.Extra); v != "" {
+			fields = append(fields, v)
+		}
+	}
+
+	if h.NodeID != "" {
+		fields = append(fields, FormatNodeID(h.NodeID))
+	}
+
+	return HeaderStart + " " + strings.Join(fields, HeaderFieldSep)
+}
+
+// HeaderFields splits a header line into its non-empty fields, keeping
+// quoted values (see formatHeaderValue) intact.
+// Accepts headers starting with ───── (trailing dashes optional for backwards compat).
+func HeaderFields(line string) ([]string, bool) {
+	if !strings.HasPrefix(line, HeaderStart) {
+		return nil, false
+	}
+
+	// Strip leading delimiter and optional trailing delimiter
+	content := strings.TrimPrefix(line, HeaderStart)
+	content = strings.TrimSuffix(content, HeaderStart) // optional, for backwards compat
+	content = strings.TrimSpace(content)
+
+	if content == "" {
+		return nil, false
+	}
+
+	var fields []string
+	add := func(field string) {
+		if field = strings.TrimSpace(field); field != "" {
+			fields = append(fields, field)
+		}
+	}
+	start, inQuote := 0, false
+	for i := 0; i < len(content); i++ {
+		switch {
+		case inQuote && content[i] == '\\':
+			i++ // skip escaped char
+		case content[i] == '"':
+			inQuote = !inQuote
+		case !inQuote && strings.HasPrefix(content[i:], HeaderFieldSep):
+			add(content[start:i])
+			i += len(HeaderFieldSep) - 1
+			start = i + 1
+		}
+	}
+	add(content[start:])
+	return fields, len(fields) > 0
+}
+
+// formatHeaderValue returns a free-text header value (such as an author),
+// quoted if it could otherwise be confused with header syntax.
+func formatHeaderValue(v string) string {
+	needsQuote := strings.TrimSpace(v) != v || strings.ContainsAny(v, `"\─`) || strings.Contains(v, ASCIIFieldSep) ||
+		strings.IndexFunc(v, func(r rune) bool { return !strconv.IsPrint(r) }) >= 0
+	if needsQuote {
+		return strconv.Quote(v)
+	}
+	return v
+}
+
+// parseHeaderValue reverses formatHeaderValue.
+func parseHeaderValue(s string) string {
+	if strings.HasPrefix(s, `"`) {
+		if v, err := strconv.Unquote(s); err == nil {
+			return v
+		}
+	}
+	return s
+}
+
+// ParseHeader parses a header line into a Header struct.
+// Accepts headers starting with ───── (trailing dashes optional for backwards compat).
+func ParseHeader(line string) (Header, bool) {
+	fields, ok := HeaderFields(line)
+	if !ok {
+		return Header{}, false
+	}
+
+	h := Header{}
+	for _, field := range fields {
+		switch {
+		case field == "new":
+			h.IsNew = true
+		case field == "file":
+			h.IsFile = true
+		case field == "outdated":
+			h.IsOutdated = true
+		case field == "approx":
+			h.IsApprox = true
+		case field == "above":
+			h.IsAbove = true
+		case field == AppliedField:
+			h.IsApplied = true
+		case field == "resolved":
+			h.IsResolved = true
+		case field == "edited":
+			h.IsEdited = true
+		case field == "verbatim":
+			h.IsVerbatim = true
+		case strings.HasPrefix(field, "@"):
+			h.Author = parseHeaderValue(strings.TrimPrefix(field, "@"))
+		case strings.HasPrefix(field, "by "):
+			h.Author = parseHeaderValue(strings.TrimPrefix(field, "by ")) // backwards compat
+		case strings.HasPrefix(field, "at "):
+			ts := strings.TrimPrefix(field, "at ")
+			if t, err := time.Parse("2006-01-02 15:04", ts); err == nil {
+				h.Timestamp = t
+			}
+		case strings.HasPrefix(field, "range "):
+			fmt.Sscanf(field, "range %d", &h.Range)
+		case strings.HasPrefix(field, "lines "):
+			if n, _ := fmt.Sscanf(field, "lines %d-%d", &h.Lines[0], &h.Lines[1]); n == 1 {
+				h.Lines[1] = h.Lines[0]
+			}
+		case strings.HasPrefix(field, "sum "):
+			h.Sum = strings.TrimPrefix(field, "sum ")
+		case strings.HasPrefix(field, "origline "):
+			fmt.Sscanf(field, "origline %d", &h.OrigLine)
+		case strings.HasPrefix(field, "prrc ") || strings.HasPrefix(field, "ic ") ||
+			strings.HasPrefix(field, "prrt ") || strings.HasPrefix(field, "prr ") || strings.HasPrefix(field, "pr "):
+			h.NodeID = parseNodeID(field)
+		case headerVersionRe.MatchString(field):
+			v, ok := parseHeaderVersion(field)
+			h.Version = v
+			if !ok {
+				h.Extra = append(h.Extra, field)
+			}
+		default:
+			h.Extra = append(h.Extra, field)
+		}
+	}
+
+	return h, true
+}
+
+// Serialize writes the PR data to files in the filesystem.
+func Serialize(pr *PullRequest, opts SerializeOptions) error {
+	// Group threads by file path
+	threadsByFile := make(map[string][]ReviewThread)
+	var movedThreads []ReviewThread
+	for _, thread := range pr.ReviewThreads {
+		if opts.HideResolved && thread.IsResolved {
+			// The file is still rewritten, to drop the thread if it was there
+			if _, ok := threadsByFile[thread.Path]; !ok {
+				threadsByFile[thread.Path] = nil
+			}
+			continue
+		}
+		if opts.OutdatedFile && movesToOutdatedFile(thread) {
+			movedThreads = append(movedThreads, thread)
+			// The file is still rewritten, to drop any old craft comments
+			if _, ok := threadsByFile[thread.Path]; !ok {
+				threadsByFile[thread.Path] = nil
+			}
+			continue
+		}
+		threadsByFile[thread.Path] = append(threadsByFile[thread.Path], thread)
+	}
+	if opts.MarkChanges && opts.VCS != nil && pr.BaseRefOID != "" {
+		// Files changed in the PR get markers even without threads
+		changed, err := opts.VCS.GetModifiedFiles(pr.BaseRefOID)
+		if err != nil {
+			return fmt.Errorf("listing changed files: %w", err)
+		}
+		for _, path := range changed {
+			if _, ok := threadsByFile[path]; !ok && !IsReviewFile(path) {
+				threadsByFile[path] = nil
+			}
+		}
+	}
+
+	// Process each file
+	paths := slices.Sorted(maps.Keys(threadsByFile))
+	errs := make([]error, len(paths))
+	forEachParallel(len(paths), func(i int) {
+		if err := serializeFileComments(opts, pr, paths[i], threadsByFile[paths[i]]); err != nil {
+			errs[i] = fmt.Errorf("serializing %s: %w", paths[i], err)
+		}
+	})
+	if err := errors.Join(errs...); err != nil {
+		return err
+	}
+
+	if opts.OutdatedFile {
+		if err := serializeOutdatedFile(opts, movedThreads); err != nil {
+			return fmt.Errorf("serializing %s: %w", OutdatedFile, err)
+		}
+	}
+
+	// Write PR-STATE.txt
+	if err := serializePRState(pr, opts); err != nil {
+		return fmt.Errorf("serializing PR state: %w", err)
+	}
+	if err := serializeDescription(pr, opts); err != nil {
+		return fmt.Errorf("serializing %s: %w", DescriptionFile, err)
+	}
+
+	return nil
+}
+
+// serializeFileComments writes review threads as comments into a source file.
+func serializeFileComments(opts SerializeOptions, pr *PullRequest, path string, threads []ReviewThread) error {
+	// Read original file (may not exist for deleted files)
+	content, err := ReadFile(opts.FS, path)
+	if errors.Is(err, syscall.EISDIR) {
+		// A thread on a submodule's pointer has nowhere to go
+		return nil
+	} else if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("reading file: %w", err)
+	}
+	if content == nil && len(threads) == 0 {
+		return nil // all its threads went to PR-OUTDATED.txt
+	}
+
+	style := GetCommentStyle(path)
+	enc, text := DecodeFile(string(content))
+
+	// Strip existing craft comments to make serialization idempotent
+	var lines []string
+	if content != nil {
+		fileLines := strings.Split(text, "\n")
+		for i, parsed := range ParseCraftLines(fileLines, style.LinePrefix) {
+			// Check if line contains any craft box character after comment prefix
+			if !parsed.OK {
+				lines = append(lines, fileLines[i])
+			}
+		}
+	}
+
+	if opts.VCS != nil && content != nil {
+		placeOutdatedThreads(opts.VCS, pr, path, lines, threads)
+	}
+
+	// Separate threads into valid (line in bounds, RIGHT side) and outdated
+	// LEFT side comments are on deleted/old code, so treat as outdated unless
+	// placed near surviving code
+	// File-level threads go at the top of the file
+	var validThreads, outdatedThreads, fileThreads []ReviewThread
+	for _, thread := range threads {
+		if thread.SubjectType == SubjectTypeFile && content != nil {
+			fileThreads = append(fileThreads, thread)
+		} else if thread.IsApprox && thread.Line >= 1 && thread.Line <= len(lines) {
+			validThreads = append(validThreads, thread)
+		} else if thread.DiffSide == DiffSideLeft {
+			// LEFT side = comment on old/deleted code
+			outdatedThreads = append(outdatedThreads, thread)
+		} else if thread.Line >= 1 && thread.Line <= len(lines) {
+			validThreads = append(validThreads, thread)
+		} else {
+			outdatedThreads = append(outdatedThreads, thread)
+		}
+	}
+
+	// Sort valid threads by line number (descending) so we insert from bottom to top
+	// This way line numbers don't shift as we insert
+	sort.Slice(validThreads, func(i, j int) bool {
+		return validThreads[i].Line > validThreads[j].Line
+	})
+
+	// Group threads by where they're inserted, for handling multiple threads
+	// on the same line: after the line, or before the first line of the range
+	// with CommentsAbove. Below the line, the start of a range is out of
+	// sight, so it gets a marker line before it.
+	threadsByLine := make(map[int][]ReviewThread)
+	rangeStarts := make(map[int]int) // insertion point -> number of markers
+	var changeMarkers map[int]string // insertion point -> marker text
+	if opts.MarkChanges && opts.VCS != nil && content != nil {
+		changeMarkers = changedLineMarkers(opts.VCS, pr.BaseRefOID, path, lines)
+	}
+	for _, thread := range validThreads {
+		insertAt := thread.Line
+		if opts.CommentsAbove {
+			insertAt = ThreadStartLine(thread) - 1
+		} else if start := ThreadStartLine(thread); start < thread.Line {
+			rangeStarts[start-1]++
+		}
+		threadsByLine[insertAt] = append(threadsByLine[insertAt], thread)
+	}
+
+	// Calculate prefix width for wrapping: "// ║ " = comment + space + box + space
+	boxes := opts.Boxes()
+	prefixLen := TextWidth(CraftPrefix(style.LinePrefix) + boxes.Body + " ")
+	width := opts.WidthFor(path)
+
+	// Get line numbers and sort in descending order so insertions don't shift earlier lines
+	var lineNums []int
+	for line := range threadsByLine {
+		lineNums = append(lineNums, line)
+	}
+	for line := range rangeStarts {
+		if _, ok := threadsByLine[line]; !ok {
+			lineNums = append(lineNums, line)
+		}
+	}
+	for line := range changeMarkers {
+		_, hasThreads := threadsByLine[line]
+		if _, hasStart := rangeStarts[line]; !hasThreads && !hasStart {
+			lineNums = append(lineNums, line)
+		}
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(lineNums)))
+
+	// The code as it is, for previews of suggestions, since lines gets
+	// comments inserted
+	code := lines
+
+	// formatThreads returns the lines of threads inserted together
+	formatThreads := func(lineThreads []ReviewThread, indent string, above bool) []string {
+		// Sort threads on same line by first comment time
+		sort.Slice(lineThreads, func(i, j int) bool {
+			if len(lineThreads[i].Comments) == 0 || len(lineThreads[j].Comments) == 0 {
+				return false
+			}
+			return lineThreads[i].Comments[0].CreatedAt.Before(lineThreads[j].Comments[0].CreatedAt)
+		})
+
+		var commentLines []string
+		for threadIdx, thread := range lineThreads {
+			for i, comment := range thread.Comments {
+				// Determine box char: ╓ for first comment or new thread, ╟ for replies
+				boxChar := boxes.Reply
+				if i == 0 && threadIdx == 0 {
+					boxChar = boxes.Thread // first comment of first thread
+				} else if i == 0 && threadIdx > 0 {
+					boxChar = boxes.Thread // first comment of subsequent thread (new thread)
+				}
+
+				header := Header{
+					Author:     comment.Author.Login,
+					Timestamp:  comment.CreatedAt,
+					NodeID:     comment.ID,
+					IsNew:      comment.IsNew,
+					IsFile:     thread.SubjectType == SubjectTypeFile,
+					IsOutdated: thread.IsOutdated,
+					IsApprox:   thread.IsApprox,
+					IsApplied:  thread.IsApplied,
+					IsResolved: thread.IsResolved,
+					IsEdited:   comment.IsEdited,
+					IsVerbatim: opts.NoReflow,
+					Extra:      comment.HeaderExtra,
+				}
+				if thread.IsApprox {
+					header.OrigLine = thread.OriginalLine
+				}
+
+				// Handle range comments
+				if thread.StartLine != nil && *thread.StartLine != thread.Line {
+					header.Range = *thread.StartLine - thread.Line // negative
+				}
+				header.IsAbove = above && i == 0
+
+				// Wrap and add body lines
+				wrappedBody := formatCommentBody(comment.Body, width, prefixLen+len(indent), opts)
+				if !comment.IsNew {
+					header.Sum = serializedBodySum(wrappedBody, header.IsVerbatim)
+				}
+				commentLines = append(commentLines, indent+FormatCraftLine(style.LinePrefix, boxChar, FormatHeader(header)))
+				for _, prevLine := range quotedPreviousBody(comment.PreviousBody) {
+					commentLines = append(commentLines, indent+formatHunkLine(style.LinePrefix, boxes.Hunk, prevLine))
+				}
+				for _, bodyLine := range strings.Split(wrappedBody, "\n") {
+					commentLines = append(commentLines, indent+FormatCraftLine(style.LinePrefix, boxes.Body, bodyLine))
+				}
+				for _, diffLine := range suggestionPreview(thread, comment.Body, code) {
+					commentLines = append(commentLines, indent+formatHunkLine(style.LinePrefix, boxes.Hunk, diffLine))
+				}
+			}
+		}
+		return commentLines
+	}
+
+	// Process each line's threads (in descending line order)
+	for _, line := range lineNums {
+		lineThreads := threadsByLine[line]
+
+		// Get indentation from the target line
+		var indent string
+		if opts.CommentsAbove {
+			indent = GetIndent(lines[line])
+		} else if len(lineThreads) > 0 {
+			indent = GetIndent(lines[line-1])
+		}
+
+		commentLines := formatThreads(lineThreads, indent, opts.CommentsAbove)
+		// Range start markers go right before the line they mark
+		for range rangeStarts[line] {
+			commentLines = append(commentLines, GetIndent(lines[line])+FormatCraftLine(style.LinePrefix, boxes.Start, RangeStartText))
+		}
+		// Change markers go after them, closest to the code
+		if text, ok := changeMarkers[line]; ok {
+			commentLines = append(commentLines, GetIndent(lines[line])+FormatCraftLine(style.LinePrefix, boxes.Change, text))
+		}
+
+		// Insert after the target line (line numbers are 1-based), or before
+		// it with CommentsAbove
+		newLines := make([]string, 0, len(lines)+len(commentLines))
+		newLines = append(newLines, lines[:line]...)
+		newLines = append(newLines, commentLines...)
+		newLines = append(newLines, lines[line:]...)
+		lines = newLines
+	}
+	if len(fileThreads) > 0 {
+		lines = append(formatThreads(fileThreads, "", false), lines...)
+	}
+
+	// Append outdated threads at end of file
+	if len(outdatedThreads) > 0 {
+		// Sort by original line number for consistent ordering
+		sort.Slice(outdatedThreads, func(i, j int) bool {
+			return outdatedThreads[i].OriginalLine < outdatedThreads[j].OriginalLine
+		})
+
+		lines = append(lines, "", style.LinePrefix+" "+OutdatedCommentsHeader)
+		for _, thread := range outdatedThreads {
+			lines = append(lines, formatOutdatedThread(opts, style, width, thread, true)...)
+		}
+	}
+
+	// Write back
+	return WriteFile(opts.FS, path, []byte(enc.Encode(strings.Join(lines, "\n"))))
+}
+
+// suggestionPreview returns a diff from the lines of code a comment on thread
+// suggests replacing to its suggestion, to quote under the comment, or nil if
+// there's no suggestion, or it changes nothing (as once it's applied), or the
+// lines aren't known to be in code.
+func suggestionPreview(thread ReviewThread, body string, code []string) []string {
+	block, ok := SuggestionBlock(body)
+	if !ok || thread.SubjectType == SubjectTypeFile || thread.DiffSide == DiffSideLeft || thread.IsApprox || thread.IsOutdated {
+		return nil
+	}
+	start := ThreadStartLine(thread)
+	if start < 1 || thread.Line > len(code) {
+		return nil
+	}
+	before := code[start-1 : thread.Line]
+	var after []string
+	if block != "" {
+		after = strings.Split(block, "\n")
+	}
+	if linesEqual(before, after) {
+		return nil
+	}
+	var diff []string
+	for _, op := range difflib.NewMatcher(before, after).GetOpCodes() {
+		switch op.Tag {
+		case 'e':
+			for _, line := range before[op.I1:op.I2] {
+				diff = append(diff, " "+line)
+			}
+		case 'd':
+			for _, line := range before[op.I1:op.I2] {
+				diff = append(diff, "-"+line)
+			}
+		case 'i':
+			for _, line := range after[op.J1:op.J2] {
+				diff = append(diff, "+"+line)
+			}
+		case 'r':
+			for _, line := range before[op.I1:op.I2] {
+				diff = append(diff, "-"+line)
+			}
+			for _, line := range after[op.J1:op.J2] {
+				diff = append(diff, "+"+line)
+			}
+		}
+	}
+	return diff
+}
+
+// ThreadStartLine returns the first line a thread is on: its StartLine for a
+// range, or else its Line.
+func ThreadStartLine(thread ReviewThread) int {
+	if thread.StartLine != nil && *thread.StartLine >= 1 && *thread.StartLine < thread.Line {
+		return *thread.StartLine
+	}
+	return thread.Line
+}
+
+// formatOutdatedThread returns the lines of a thread in an outdated comments
+// section, with the diff hunk quoted under the first header.
+func formatOutdatedThread(opts SerializeOptions, style CommentStyle, width int, thread ReviewThread, isOutdated bool) []string {
+	boxes := opts.Boxes()
+	prefixLen := TextWidth(CraftPrefix(style.LinePrefix) + boxes.Body + " ")
+	var lines []string
+	for i, comment := range thread.Comments {
+		// ╓ for first comment or new thread, ╟ for replies
+		boxChar := boxes.Reply
+		if i == 0 {
+			boxChar = boxes.Thread // new thread for each outdated thread
+		}
+
+		header := Header{
+			Author:     comment.Author.Login,
+			Timestamp:  comment.CreatedAt,
+			NodeID:     comment.ID,
+			IsNew:      comment.IsNew,
+			IsFile:     thread.SubjectType == SubjectTypeFile,
+			IsOutdated: isOutdated,
+			IsApplied:  thread.IsApplied,
+			IsResolved: thread.IsResolved,
+			IsEdited:   comment.IsEdited,
+			OrigLine:   thread.OriginalLine,
+			IsVerbatim: opts.NoReflow,
+			Extra:      comment.HeaderExtra,
+		}
+
+		// Wrap and add body lines
+		wrappedBody := formatCommentBody(comment.Body, width, prefixLen, opts)
+		if !comment.IsNew {
+			header.Sum = serializedBodySum(wrappedBody, header.IsVerbatim)
+		}
+		lines = append(lines, FormatCraftLine(style.LinePrefix, boxChar, FormatHeader(header)))
+		if i == 0 {
+			for _, hunkLine := range quotedHunk(thread.DiffHunk) {
+				lines = append(lines, formatHunkLine(style.LinePrefix, boxes.Hunk, hunkLine))
+			}
+		}
+		for _, prevLine := range quotedPreviousBody(comment.PreviousBody) {
+			lines = append(lines, formatHunkLine(style.LinePrefix, boxes.Hunk, prevLine))
+		}
+		for _, bodyLine := range strings.Split(wrappedBody, "\n") {
+			lines = append(lines, FormatCraftLine(style.LinePrefix, boxes.Body, bodyLine))
+		}
+	}
+	return lines
+}
+
+// movesToOutdatedFile reports whether a thread goes in PR-OUTDATED.txt rather
+// than its source file, with SerializeOptions.OutdatedFile.
+func movesToOutdatedFile(thread ReviewThread) bool {
+	if thread.IsResolved || thread.IsOutdated || thread.DiffSide == DiffSideLeft {
+		return true
+	}
+	return thread.Line < 1 && thread.SubjectType != SubjectTypeFile
+}
+
+// outdatedFileSectionField starts the section of PR-OUTDATED.txt for a file,
+// followed by the path.
+const outdatedFileSectionField = "file "
+
+// serializeOutdatedFile writes PR-OUTDATED.txt with threads grouped by file,
+// in order of original line within each file.
+func serializeOutdatedFile(opts SerializeOptions, threads []ReviewThread) error {
+	threads = slices.Clone(threads)
+	slices.SortStableFunc(threads, func(a, b ReviewThread) int {
+		return cmp.Or(strings.Compare(a.Path, b.Path), cmp.Compare(a.OriginalLine, b.OriginalLine))
+	})
+
+	style := GetCommentStyle(OutdatedFile)
+	width := opts.WidthFor(OutdatedFile)
+	var lines []string
+	for i, thread := range threads {
+		if i == 0 || thread.Path != threads[i-1].Path {
+			if i > 0 {
+				lines = append(lines, "")
+			}
+			lines = append(lines, HeaderStart+" "+outdatedFileSectionField+formatHeaderValue(thread.Path))
+		}
+		// Resolved threads on current code aren't outdated, just moved
+		isOutdated := thread.IsOutdated || thread.DiffSide == DiffSideLeft || (thread.Line < 1 && !thread.IsResolved)
+		lines = append(lines, formatOutdatedThread(opts, style, width, thread, isOutdated)...)
+	}
+	if len(lines) > 0 {
+		lines = append(lines, "")
+	}
+	return WriteFile(opts.FS, OutdatedFile, []byte(strings.Join(lines, "\n")))
+}
+
+// DeserializeOutdatedFile parses PR-OUTDATED.txt, if there is one. Like
+// DeserializeFileComments, malformed lines are reported as a ParseErrors.
+func DeserializeOutdatedFile(opts SerializeOptions) ([]ReviewThread, error) {
+	content, err := ReadFile(opts.FS, OutdatedFile)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	_, text := DecodeFile(string(content))
+	lines := strings.Split(text, "\n")
+	style := GetCommentStyle(OutdatedFile)
+
+	var threads []ReviewThread
+	var parseErrs ParseErrors
+	parseSection := func(path string, start, end int) {
+		if start < 0 {
+			return
+		}
+		sectionThreads, errs := ParseFileComments(opts, path, lines[start:end], style)
+		for i, thread := range sectionThreads {
+			// There's no code to anchor to, so only replies can be sent
+			sectionThreads[i].Line = 0
+			if thread.Comments[0].ID == "" && thread.Comments[0].IsNew {
+				parseErrs = append(parseErrs, ParseError{Path: OutdatedFile, Line: start, Msg: "new thread under " + path + "; start it in the file itself"})
+			}
+		}
+		threads = append(threads, sectionThreads...)
+		for _, pe := range errs {
+			parseErrs = append(parseErrs, ParseError{Path: OutdatedFile, Line: start + pe.Line, Msg: pe.Msg})
+		}
+	}
+
+	var path string
+	start := -1
+	for i, line := range lines {
+		fields, ok := HeaderFields(strings.TrimSpace(line))
+		if !ok || len(fields) != 1 || !strings.HasPrefix(fields[0], outdatedFileSectionField) {
+			continue
+		}
+		parseSection(path, start, i)
+		path = parseHeaderValue(strings.TrimPrefix(fields[0], outdatedFileSectionField))
+		start = i + 1
+	}
+	parseSection(path, start, len(lines))
+
+	if len(parseErrs) > 0 {
+		return threads, parseErrs
+	}
+	return threads, nil
+}
+
+// serializePRState writes PR-STATE.txt with metadata and issue comments.
+func serializePRState(pr *PullRequest, opts SerializeOptions) error {
+	var buf strings.Builder
+	width := opts.WidthFor(PRStateFile)
+
+	// PR metadata header
+	metaFields := []string{
+		"pr",
+		fmt.Sprintf("number %d", pr.Number),
+	}
+	if pr.Author.Login != "" {
+		metaFields = append(metaFields, "@"+formatHeaderValue(pr.Author.Login))
+	}
+	if pr.ID != "" {
+		metaFields = append(metaFields, FormatNodeID(pr.ID))
+	}
+	if pr.IsLocal {
+		metaFields = append(metaFields, localField)
+	}
+	if pr.InPlace {
+		metaFields = append(metaFields, inPlaceField)
+	}
+	if pr.IsClosed() {
+		metaFields = append(metaFields, pr.State) // MERGED or CLOSED
+	}
+	if pr.IsDraft {
+		metaFields = append(metaFields, DraftPRField)
+	}
+	metaFields = append(metaFields, "head "+pr.HeadRefOID)
+	if pr.BaseRefOID != "" {
+		metaFields = append(metaFields, "base "+pr.BaseRefOID)
+	}
+	if pr.PreviousBaseRefOID != "" {
+		metaFields = append(metaFields, "prevbase "+pr.PreviousBaseRefOID)
+	}
+	if pr.StackParent != 0 {
+		metaFields = append(metaFields, fmt.Sprintf("stack %d", pr.StackParent))
+		if pr.StackParentOID != "" {
+			metaFields = append(metaFields, "stackhead "+pr.StackParentOID)
+		}
+	}
+	if pr.ReviewCommitOID != "" {
+		metaFields = append(metaFields, "commit "+pr.ReviewCommitOID)
+	}
+	metaFields = append(metaFields, pr.HeaderExtra...)
+	if v := formatHeaderVersion(pr.HeaderExtra); v != "" {
+		metaFields = append(metaFields, v)
+	}
+	buf.WriteString(HeaderStart + " " + strings.Join(metaFields, HeaderFieldSep) + "\n")
+
+	// PR description body (informational only, ignored on deserialize)
+	if pr.Body != "" {
+		buf.WriteString(wrapCommentBody(pr.Body, width, 0) + "\n")
+	}
+	buf.WriteString("\n")
+
+	// Issue comments
+	for _, comment := range pr.IssueComments {
+		// Wrap body (no prefix for PR-STATE.txt)
+		wrappedBody := formatCommentBody(comment.Body, width, 0, opts)
+
+		header := Header{
+			Author:     comment.Author.Login,
+			Timestamp:  comment.CreatedAt,
+			NodeID:     comment.ID,
+			IsNew:      comment.IsNew,
+			IsEdited:   comment.IsEdited,
+			IsVerbatim: opts.NoReflow,
+			Extra:      comment.HeaderExtra,
+		}
+		if !comment.IsNew {
+			header.Sum = serializedBodySum(wrappedBody, header.IsVerbatim)
+		}
+		buf.WriteString(FormatHeader(header) + "\n")
+		for _, line := range strings.Split(wrappedBody, "\n") {
+			buf.WriteString(line + "\n")
+		}
+		buf.WriteString("\n")
+	}
+
+	// Your pending review, whose body can be edited here too
+	if review := pendingReview(pr); review != nil {
+		wrappedBody := formatCommentBody(review.Body, width, 0, opts)
+		header := Header{
+			Author:     review.Author.Login,
+			Timestamp:  review.CreatedAt,
+			NodeID:     review.ID,
+			IsVerbatim: opts.NoReflow,
+			Extra:      []string{pendingReviewField},
+		}
+		header.Sum = serializedBodySum(wrappedBody, header.IsVerbatim)
+		buf.WriteString(FormatHeader(header) + "\n")
+		for _, line := range strings.Split(wrappedBody, "\n") {
+			buf.WriteString(line + "\n")
+		}
+		buf.WriteString("\n")
+	}
+
+	// How the branch evolved
+	if timeline := formatTimeline(pr); len(timeline) > 0 {
+		buf.WriteString(HeaderStart + " " + commitsField + "\n")
+		for _, line := range timeline {
+			buf.WriteString(line + "\n")
+		}
+		buf.WriteString("\n")
+	}
+
+	// Index of files with threads, so Deserialize needn't read every file
+	var paths []string
+	for _, thread := range pr.ReviewThreads {
+		paths = append(paths, thread.Path)
+	}
+	slices.Sort(paths)
+	if paths = slices.Compact(paths); len(paths) > 0 {
+		buf.WriteString(HeaderStart + " " + fileIndexField + "\n")
+		for _, path := range paths {
+			buf.WriteString(path + "\n")
+		}
+		buf.WriteString("\n")
+	}
+
+	return WriteFile(opts.FS, PRStateFile, []byte(buf.String()))
+}
+
+// fileIndexField is the header of the PR-STATE.txt section listing the files
+// that have threads, one per line.
+const fileIndexField = "files"
+
+// IsFileIndexHeader reports whether a PR-STATE.txt line starts the file index.
+func IsFileIndexHeader(line string) bool {
+	fields, ok := HeaderFields(strings.TrimSpace(line))
+	return ok && len(fields) == 1 && fields[0] == fileIndexField
+}
+
+// commitsField is the header of the PR-STATE.txt section listing the PR's
+// commits and the force pushes to its branch, oldest first:
+//
+//	2025-01-15 12:34 0123456789ab @alice Add the thing
+//	2025-01-16 09:00 force-push @alice 0123456789ab → 89abcdef0123
+//
+// Commit authors without a GitHub account are Go-quoted names, and commits
+// GitHub no longer has are "unknown".
+const commitsField = "commits"
+
+// IsCommitsHeader reports whether a PR-STATE.txt line starts the commits
+// section.
+func IsCommitsHeader(line string) bool {
+	fields, ok := HeaderFields(strings.TrimSpace(line))
+	return ok && len(fields) == 1 && fields[0] == commitsField
+}
+
+const (
+	TimelineTimeFormat = "2006-01-02 15:04"
+	forcePushField     = "force-push"
+	unknownOID         = "unknown"
+)
+
+// formatTimeline returns the lines of the commits section for pr: commits by
+// commit time, with the force pushes between them.
+func formatTimeline(pr *PullRequest) []string {
+	type event struct {
+		at   time.Time
+		line string
+	}
+	var events []event
+	for _, c := range pr.Commits {
+		author := strconv.Quote(c.Author)
+		if c.HasLogin {
+			author = "@" + c.Author
+		}
+		events = append(events, event{c.CommittedAt, fmt.Sprintf("%s %s %s %s",
+			c.CommittedAt.Format(TimelineTimeFormat), ShortOID(c.OID), author, c.Headline)})
+	}
+	for _, f := range pr.ForcePushes {
+		actor := cmp.Or(f.Actor, "ghost")
+		events = append(events, event{f.CreatedAt, fmt.Sprintf("%s %s @%s %s → %s",
+			f.CreatedAt.Format(TimelineTimeFormat), forcePushField, actor, ShortOID(f.BeforeOID), ShortOID(f.AfterOID))})
+	}
+	slices.SortStableFunc(events, func(a, b event) int { return a.at.Compare(b.at) })
+	lines := make([]string, len(events))
+	for i, e := range events {
+		lines[i] = e.line
+	}
+	return lines
+}
+
+// ShortOID abbreviates a commit ID for the commits section.
+func ShortOID(oid string) string {
+	if oid == "" {
+		return unknownOID
+	}
+	return oid[:min(len(oid), 12)]
+}
+
+// parseTimelineLine adds the commit or force push on a line of the commits
+// section to pr, ignoring lines it can't parse.
+func parseTimelineLine(pr *PullRequest, line string) {
+	if len(line) < len(TimelineTimeFormat)+1 {
+		return
+	}
+	at, err := time.Parse(TimelineTimeFormat, line[:len(TimelineTimeFormat)])
+	if err != nil {
+		return
+	}
+	rest := line[len(TimelineTimeFormat)+1:]
+	oid := func(s string) string {
+		if s == unknownOID {
+			return ""
+		}
+		return s
+	}
+
+	if rest, ok := strings.CutPrefix(rest, forcePushField+" @"); ok {
+		var actor, before, after string
+		if n, _ := fmt.Sscanf(rest, "%s %s → %s", &actor, &before, &after); n == 3 {
+			pr.ForcePushes = append(pr.ForcePushes, ForcePush{Actor: actor, BeforeOID: oid(before), AfterOID: oid(after), CreatedAt: at})
+		}
+		return
+	}
+
+	id, rest, ok := strings.Cut(rest, " ")
+	if !ok {
+		return
+	}
+	c := Commit{OID: oid(id), CommittedAt: at}
+	if login, ok := strings.CutPrefix(rest, "@"); ok {
+		c.Author, rest, _ = strings.Cut(login, " ")
+		c.HasLogin = true
+	} else {
+		quoted, err := strconv.QuotedPrefix(rest)
+		if err != nil {
+			return
+		}
+		c.Author, _ = strconv.Unquote(quoted)
+		rest = strings.TrimPrefix(rest[len(quoted):], " ")
+	}
+	c.Headline = rest
+	pr.Commits = append(pr.Commits, c)
+}
+
+// SplitFileIndex removes the file index section from PR-STATE.txt content.
+// It returns the remaining content, the indexed files, and whether there was
+// an index.
+func SplitFileIndex(content string) (string, []string, bool) {
+	var rest, files []string
+	var inIndex, found bool
+	for _, line := range strings.Split(content, "\n") {
+		if IsFileIndexHeader(line) {
+			inIndex, found = true, true
+			continue
+		}
+		if inIndex {
+			if _, isHeader := ParseHeader(strings.TrimSpace(line)); !isHeader {
+				if line != "" {
+					files = append(files, line)
+				}
+				continue
+			}
+			inIndex = false
+		}
+		rest = append(rest, line)
+	}
+	return strings.Join(rest, "\n"), files, found
+}
+
+// deserializeFileList returns the files Deserialize should read. Unless
+// opts.FullScan is set, that's the PR-STATE.txt file index plus any files
+// changed since the PR head (or reviewed commit), when both are available;
+// otherwise every file.
+func deserializeFileList(opts SerializeOptions, pr *PullRequest, stateContent string) ([]string, error) {
+	if !opts.FullScan && opts.VCS != nil && pr.CheckoutOID() != "" {
+		if _, files, ok := SplitFileIndex(stateContent); ok {
+			changed, err := opts.VCS.GetChangedFiles(pr.CheckoutOID())
+			if err == nil {
+				files = append(files, changed...)
+				slices.Sort(files)
+				return slices.Compact(files), nil
+			}
+			// e.g. the PR head isn't fetched; fall back to a full scan
+		}
+	}
+	return ListFiles(opts)
+}
+
+// ParseError describes a craft line that couldn't be parsed.
+type ParseError struct {
+	Path string
+	Line int // 1-based line in the file
+	Msg  string
+}
+
+func (e ParseError) Error() string {
+	return fmt.Sprintf("%s:%d: %s", e.Path, e.Line, e.Msg)
+}
+
+// ParseErrors is returned by Deserialize (along with the PullRequest) when
+// some craft lines couldn't be parsed. Those lines are left out of the result.
+type ParseErrors []ParseError
+
+func (e ParseErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, pe := range e {
+		msgs[i] = pe.Error()
+	}
+	return fmt.Sprintf("%d malformed craft line(s):\n%s", len(e), strings.Join(msgs, "\n"))
+}
+
+// maxParallelFiles bounds how many files Serialize and Deserialize process at
+// once.
+var maxParallelFiles = runtime.GOMAXPROCS(0)
+
+// forEachParallel calls f(i) for i in [0, n), running up to maxParallelFiles
+// calls at a time, and returns once they've all finished.
+func forEachParallel(n int, f func(i int)) {
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, max(maxParallelFiles, 1))
+	for i := range n {
+		sem <- struct{}{}
+		wg.Go(func() {
+			defer func() { <-sem }()
+			f(i)
+		})
+	}
+	wg.Wait()
+}
+
+// Deserialize reads PR data from files in the filesystem.
+// If some craft lines are malformed, it returns everything else it could read
+// along with a ParseErrors.
+func Deserialize(opts SerializeOptions) (*PullRequest, error) {
+	pr := &PullRequest{}
+
+	// Read PR-STATE.txt first to get metadata
+	stateContent, err := ReadFile(opts.FS, PRStateFile)
+	if err != nil {
+		return nil, fmt.Errorf("reading PR state: %w", err)
+	}
+
+	if err := DeserializePRState(opts, pr, string(stateContent)); err != nil {
+		return nil, fmt.Errorf("parsing PR state: %w", err)
+	}
+
+	// Get list of files
+	files, err := deserializeFileList(opts, pr, string(stateContent))
+	if err != nil {
+		return nil, fmt.Errorf("listing files: %w", err)
+	}
+	files = slices.DeleteFunc(files, func(path string) bool { return path == OutdatedFile || path == DescriptionFile })
+
+	// Read comments from each file
+	threadsByFile := make([][]ReviewThread, len(files))
+	errs := make([]error, len(files))
+	forEachParallel(len(files), func(i int) {
+		threadsByFile[i], errs[i] = DeserializeFileComments(opts, files[i])
+	})
+
+	// Collect results in file order
+	var parseErrs ParseErrors
+	var fileErrs []error
+	for i, path := range files {
+		err := errs[i]
+		var pe ParseErrors
+		if errors.As(err, &pe) {
+			parseErrs = append(parseErrs, pe...)
+		} else if err != nil {
+			if errors.Is(err, syscall.EISDIR) {
+				// harmless error caused by submodules
+				continue
+			}
+			if errors.Is(err, fs.ErrNotExist) {
+				// file listed but not present (e.g., submodule in jj workspace)
+				continue
+			}
+			fileErrs = append(fileErrs, fmt.Errorf("deserializing %s: %w", path, err))
+			continue
+		}
+		pr.ReviewThreads = append(pr.ReviewThreads, threadsByFile[i]...)
+	}
+
+	// Threads collected in PR-OUTDATED.txt
+	outdatedThreads, err := DeserializeOutdatedFile(opts)
+	var pe ParseErrors
+	if errors.As(err, &pe) {
+		parseErrs = append(parseErrs, pe...)
+	} else if err != nil {
+		fileErrs = append(fileErrs, fmt.Errorf("deserializing %s: %w", OutdatedFile, err))
+	}
+	pr.ReviewThreads = append(pr.ReviewThreads, outdatedThreads...)
+
+	if err := errors.Join(fileErrs...); err != nil {
+		return nil, err
+	}
+	if len(parseErrs) > 0 {
+		return pr, parseErrs
+	}
+	return pr, nil
+}
+
+// localField marks the PR-STATE.txt of a craft local review.
+const localField = "local"
+
+// inPlaceField marks the PR-STATE.txt of a review serialized by get --in-place.
+const inPlaceField = "inplace"
+
+// DraftPRField marks the PR-STATE.txt of a draft PR. (Not DraftField, the
+// assist draft comment marker.)
+const DraftPRField = "DRAFT"
+
+// prMetaFieldRe matches the PR metadata fields that ParseHeader doesn't handle.
+var prMetaFieldRe = regexp.MustCompile(`^(number|head|base|prevbase|stack|stackhead|commit) ([0-9a-f]+)$`)
+
+// DeserializePRState parses PR-STATE.txt into the PullRequest.
+func DeserializePRState(opts SerializeOptions, pr *PullRequest, content string) error {
+	lines := strings.Split(content, "\n")
+	var currentComment *IssueComment
+	var currentHeader Header
+	var bodyLines []string
+	var inCommits bool
+
+	flushComment := func() {
+		if currentComment != nil {
+			body := ParseCommentBody(bodyLines, currentHeader.IsVerbatim)
+			currentComment.Body, currentComment.IsModified = restoreCommentBody(opts, currentHeader, body)
+			if slices.Contains(currentHeader.Extra, pendingReviewField) {
+				pr.Reviews = append(pr.Reviews, Review{
+					ID:         currentComment.ID,
+					Author:     currentComment.Author,
+					State:      ReviewStatePending,
+					Body:       currentComment.Body,
+					CreatedAt:  currentComment.CreatedAt,
+					IsModified: currentComment.IsModified,
+				})
+			} else {
+				pr.IssueComments = append(pr.IssueComments, *currentComment)
+			}
+			currentComment = nil
+			bodyLines = nil
+		}
+	}
+
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+
+		// Check for header
+		header, isHeader := ParseHeader(trimmed)
+		if !isHeader {
+			// Body line for current comment
+			if currentComment != nil {
+				bodyLines = append(bodyLines, line)
+			} else if inCommits && trimmed != "" {
+				parseTimelineLine(pr, trimmed)
+			}
+			continue
+		}
+
+		flushComment()
+
+		if IsFileIndexHeader(trimmed) {
+			inCommits = false
+			continue // used by deserializeFileList
+		}
+		if inCommits = IsCommitsHeader(trimmed); inCommits {
+			continue
+		}
+
+		// Check if it's the PR metadata header
+		fields, _ := HeaderFields(trimmed)
+		if slices.Contains(fields, "pr") {
+			// Parse PR metadata from header
+			pr.ID = header.NodeID
+			pr.Author.Login = header.Author
+			// Parse additional fields; the rest of header.Extra is preserved
+			for _, field := range header.Extra {
+				match := prMetaFieldRe.FindStringSubmatch(field)
+				if field == localField {
+					pr.IsLocal = true
+					continue
+				}
+				if field == inPlaceField {
+					pr.InPlace = true
+					continue
+				}
+				if IsClosedState(field) {
+					pr.State = field
+					continue
+				}
+				if field == DraftPRField {
+					pr.IsDraft = true
+					continue
+				}
+				if match == nil {
+					if field != "pr" {
+						pr.HeaderExtra = append(pr.HeaderExtra, field)
+					}
+					continue
+				}
+				switch match[1] {
+				case "number":
+					fmt.Sscanf(match[2], "%d", &pr.Number)
+				case "head":
+					pr.HeadRefOID = match[2]
+				case "base":
+					pr.BaseRefOID = match[2]
+				case "prevbase":
+					pr.PreviousBaseRefOID = match[2]
+				case "stack":
+					fmt.Sscanf(match[2], "%d", &pr.StackParent)
+				case "stackhead":
+					pr.StackParentOID = match[2]
+				case "commit":
+					pr.ReviewCommitOID = match[2]
+				}
+			}
+			continue
+		}
+
+		// It's a comment header
+		currentHeader = header
+		currentComment = &IssueComment{
+			ID:          header.NodeID,
+			Author:      Actor{Login: header.Author},
+			CreatedAt:   header.Timestamp,
+			UpdatedAt:   header.Timestamp,
+			IsEdited:    header.IsEdited,
+			IsNew:       header.IsNew,
+			HeaderExtra: header.Extra,
+		}
+	}
+
+	flushComment()
+	return nil
+}
+
+// DeserializeFileComments parses craft comments from a source file.
+// Malformed craft lines are skipped and reported as a ParseErrors, returned
+// along with the threads that could be parsed.
+func DeserializeFileComments(opts SerializeOptions, path string) ([]ReviewThread, error) {
+	content, err := ReadFile(opts.FS, path)
+	if err != nil {
+		return nil, err
+	}
+
+	style := GetCommentStyle(path)
+
+	// Skip binary files or files that don't contain any box characters
+	if bytes.IndexByte(content, 0) >= 0 {
+		return nil, nil
+	}
+	hasBox := bytes.Contains(content, []byte(BoxThread)) ||
+		bytes.Contains(content, []byte(BoxReply)) ||
+		bytes.Contains(content, []byte(BoxBody)) ||
+		bytes.Contains(content, []byte(ASCIIThread+HeaderStart)) ||
+		bytes.Contains(content, []byte(ASCIIReply+HeaderStart)) ||
+		bytes.Contains(content, []byte(ASCIIThread+ASCIIHeaderStart)) ||
+		bytes.Contains(content, []byte(ASCIIReply+ASCIIHeaderStart)) ||
+		(style.LinePrefix != "" && bytes.Contains(content, []byte(style.LinePrefix+shorthandMarker)))
+	if !hasBox {
+		return nil, nil
+	}
+
+	_, text := DecodeFile(string(content))
+	lines, _ := ExpandShorthand(strings.Split(text, "\n"), style.LinePrefix)
+	threads, parseErrs := ParseFileComments(opts, path, lines, style)
+	if len(parseErrs) > 0 {
+		return threads, parseErrs
+	}
+	return threads, nil
+}
+
+// ParseFileComments parses the craft comments in the lines of a file, with
+// threads on path. Malformed craft lines are skipped and reported.
+func ParseFileComments(opts SerializeOptions, path string, lines []string, style CommentStyle) ([]ReviewThread, ParseErrors) {
+	var threads []ReviewThread
+	var currentThread *ReviewThread
+	var currentComment *ReviewComment
+	var currentHeader Header
+	var bodyLines []string
+	var lastCodeLine int // Line number of the last non-craft line
+	var parseErrs ParseErrors
+	var skipBody bool // after a malformed header, already reported
+
+	flushComment := func() {
+		if currentComment != nil {
+			body := ParseCommentBody(bodyLines, currentHeader.IsVerbatim)
+			currentComment.Body, currentComment.IsModified = restoreCommentBody(opts, currentHeader, body)
+			if currentThread != nil {
+				currentThread.Comments = append(currentThread.Comments, *currentComment)
+			}
+			currentComment = nil
+			bodyLines = nil
+		}
+	}
+
+	// Threads marked above belong to the next source line, so their lines
+	// are filled in when it's reached
+	var aboveThreads []int // indexes into threads
+	var currentAbove bool
+	placeAboveThreads := func(line int) {
+		for _, i := range aboveThreads {
+			thread := &threads[i]
+			if thread.StartLine != nil {
+				// range is relative to the last line, below the first
+				startLine := line
+				thread.Line = line - (*thread.StartLine - thread.Line)
+				thread.StartLine = &startLine
+			} else {
+				thread.Line = line
+			}
+		}
+		aboveThreads = nil
+	}
+
+	flushThread := func() {
+		flushComment()
+		if currentThread != nil && len(currentThread.Comments) > 0 {
+			if currentAbove {
+				aboveThreads = append(aboveThreads, len(threads))
+			}
+			threads = append(threads, *currentThread)
+		}
+		currentThread = nil
+	}
+
+	sourceLineNum := 0 // line number excluding craft comments
+	for i, parsed := range ParseCraftLines(lines, style.LinePrefix) {
+		line := lines[i]
+		// Check if this is a craft line
+		boxChar, craftContent := parsed.Box, parsed.Content
+		if !parsed.OK {
+			// Non-craft line - this ends any current thread
+			flushThread()
+			skipBody = false
+			sourceLineNum++
+			lastCodeLine = sourceLineNum
+			placeAboveThreads(sourceLineNum)
+			continue
+		}
+		if boxChar == BoxHunk {
+			continue // informational only
+		}
+		if boxChar == BoxStart || boxChar == BoxChange {
+			continue // also informational: the range is in the thread's header
+		}
+
+		// Check for header (starts with ─────)
+		header, isHeader := ParseHeader(craftContent)
+		if !isHeader {
+			if boxChar != BoxBody {
+				parseErrs = append(parseErrs, ParseError{Path: path, Line: i + 1, Msg: fmt.Sprintf("%s without a valid header", boxChar)})
+				flushComment()
+				skipBody = true
+				continue
+			}
+			// Body line (║)
+			if skipBody {
+				continue
+			} else if currentComment == nil {
+				parseErrs = append(parseErrs, ParseError{Path: path, Line: i + 1, Msg: "comment body line without a header"})
+			} else if currentHeader.IsVerbatim {
+				bodyLines = append(bodyLines, rawCraftBody(line, style.LinePrefix))
+			} else {
+				bodyLines = append(bodyLines, craftContent)
+			}
+			continue
+		}
+
+		// Header line - flush current comment
+		flushComment()
+		skipBody = false
+
+		// ╓ = new thread, ╟ = reply within same thread
+		if currentThread == nil || boxChar == BoxThread {
+			flushThread()
+			currentThread = &ReviewThread{
+				Path:        path,
+				Line:        lastCodeLine,
+				DiffSide:    DiffSideRight, // Default
+				SubjectType: SubjectTypeLine,
+			}
+			if header.IsFile {
+				currentThread.SubjectType = SubjectTypeFile
+				currentThread.Line = 0 // wherever it is
+			}
+			if header.Range != 0 {
+				startLine := lastCodeLine + header.Range
+				currentThread.StartLine = &startLine
+				if startLine < 1 && !header.IsAbove {
+					parseErrs = append(parseErrs, ParseError{Path: path, Line: i + 1, Msg: fmt.Sprintf("range %d starts before line 1", header.Range)})
+				}
+			}
+			currentAbove = header.IsAbove && !header.IsFile
+			if header.Lines != [2]int{} {
+				// Explicit lines, wherever the thread is
+				if header.Lines[0] < 1 || header.Lines[1] < header.Lines[0] {
+					parseErrs = append(parseErrs, ParseError{Path: path, Line: i + 1, Msg: fmt.Sprintf("invalid lines %d-%d", header.Lines[0], header.Lines[1])})
+				}
+				currentThread.Line = header.Lines[1]
+				currentThread.StartLine = nil
+				if header.Lines[0] != header.Lines[1] {
+					startLine := header.Lines[0]
+					currentThread.StartLine = &startLine
+				}
+				currentAbove = false
+			}
+			currentThread.IsOutdated = header.IsOutdated
+			currentThread.IsApprox = header.IsApprox
+			currentThread.IsApplied = header.IsApplied
+			currentThread.IsResolved = header.IsResolved
+			currentThread.OriginalLine = header.OrigLine
+		}
+
+		currentHeader = header
+		currentComment = &ReviewComment{
+			ID:          header.NodeID,
+			Author:      Actor{Login: header.Author},
+			CreatedAt:   header.Timestamp,
+			UpdatedAt:   header.Timestamp,
+			IsEdited:    header.IsEdited,
+			IsNew:       header.IsNew,
+			HeaderExtra: header.Extra,
+		}
+	}
+
+	flushThread()
+	placeAboveThreads(lastCodeLine) // nothing below them
+	return threads, parseErrs
+}
@@ -0,0 +1,1901 @@
+package craft
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHeaderRoundTrip(t *testing.T) {
+	tests := []struct {
+		name   string
+		header Header
+	}{
+		{
+			name: "basic comment",
+			header: Header{
+				Author:    "alice",
+				Timestamp: time.Date(2025, 1, 15, 12, 34, 0, 0, time.UTC),
+				NodeID:    "PRRC_kwDOPgi5ks6ZBMOo",
+			},
+		},
+		{
+			name: "new comment",
+			header: Header{
+				IsNew: true,
+			},
+		},
+		{
+			name: "file comment",
+			header: Header{
+				Author:    "bob",
+				Timestamp: time.Date(2025, 2, 20, 8, 0, 0, 0, time.UTC),
+				NodeID:    "PRRC_kwDOPgi5ks6ABC123",
+				IsFile:    true,
+			},
+		},
+		{
+			name: "range comment",
+			header: Header{
+				Author:    "carol",
+				Timestamp: time.Date(2025, 3, 10, 16, 45, 0, 0, time.UTC),
+				NodeID:    "PRRC_kwDOPgi5ks6XYZ789",
+				Range:     -5,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			formatted := FormatHeader(tt.header)
+			parsed, ok := ParseHeader(formatted)
+			require.True(t, ok, "parseHeader should succeed")
+
+			assert.Equal(t, tt.header.Author, parsed.Author)
+			assert.Equal(t, tt.header.Timestamp.Unix(), parsed.Timestamp.Unix())
+			assert.Equal(t, tt.header.NodeID, parsed.NodeID)
+			assert.Equal(t, tt.header.IsNew, parsed.IsNew)
+			assert.Equal(t, tt.header.IsFile, parsed.IsFile)
+			assert.Equal(t, tt.header.Range, parsed.Range)
+		})
+	}
+}
+
+func TestNodeIDFormat(t *testing.T) {
+	tests := []struct {
+		full  string
+		short string
+	}{
+		{"PRRC_kwDOPgi5ks6ZBMOo", "prrc kwDOPgi5ks6ZBMOo"},
+		{"IC_kwDOPgi5ks1234567", "ic kwDOPgi5ks1234567"},
+		{"PR_kwDOPgi5ks6k-agY", "pr kwDOPgi5ks6k-agY"},
+		{"PRRT_kwDOPgi5ks5YVUJi", "prrt kwDOPgi5ks5YVUJi"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.full, func(t *testing.T) {
+			assert.Equal(t, tt.short, FormatNodeID(tt.full))
+			assert.Equal(t, tt.full, parseNodeID(tt.short))
+		})
+	}
+}
+
+func TestDataRoundTrip(t *testing.T) {
+	// Create test PR data
+	pr := &PullRequest{
+		ID:         "PR_kwDOPgi5ks6k-agY",
+		Number:     42,
+		HeadRefOID: "e6be80e7693c38dbdb464c92722f5e731df69993",
+		ReviewThreads: []ReviewThread{
+			{
+				ID:          "PRRT_kwDOPgi5ks5YVUJi",
+				Path:        "main.go",
+				DiffSide:    DiffSideRight,
+				Line:        10,
+				SubjectType: SubjectTypeLine,
+				Comments: []ReviewComment{
+					{
+						ID:        "PRRC_kwDOPgi5ks6IymTJ",
+						Author:    Actor{Login: "alice"},
+						Body:      "This looks good!",
+						CreatedAt: time.Date(2025, 1, 15, 12, 34, 0, 0, time.UTC),
+						UpdatedAt: time.Date(2025, 1, 15, 12, 34, 0, 0, time.UTC),
+					},
+					{
+						ID:        "PRRC_kwDOPgi5ks6ZBO2r",
+						Author:    Actor{Login: "bob"},
+						Body:      "Thanks for the review!",
+						CreatedAt: time.Date(2025, 1, 15, 14, 0, 0, 0, time.UTC),
+						UpdatedAt: time.Date(2025, 1, 15, 14, 0, 0, 0, time.UTC),
+					},
+				},
+			},
+			{
+				ID:          "PRRT_kwDOPgi5ks5jw2bR",
+				Path:        "util.go",
+				DiffSide:    DiffSideRight,
+				Line:        5,
+				SubjectType: SubjectTypeLine,
+				Comments: []ReviewComment{
+					{
+						ID:        "PRRC_kwDOPgi5ks6ZBMOo",
+						Author:    Actor{Login: "carol"},
+						Body:      "Consider adding a comment here.",
+						CreatedAt: time.Date(2025, 1, 16, 9, 0, 0, 0, time.UTC),
+						UpdatedAt: time.Date(2025, 1, 16, 9, 0, 0, 0, time.UTC),
+					},
+				},
+			},
+		},
+		IssueComments: []IssueComment{
+			{
+				ID:        "IC_kwDOPgi5ks1234567",
+				Author:    Actor{Login: "dave"},
+				Body:      "Overall LGTM!",
+				CreatedAt: time.Date(2025, 1, 17, 10, 0, 0, 0, time.UTC),
+				UpdatedAt: time.Date(2025, 1, 17, 10, 0, 0, 0, time.UTC),
+			},
+		},
+	}
+
+	// Create in-memory filesystem with source files
+	memfs := fstest.MapFS{
+		"main.go": &fstest.MapFile{
+			Data: []byte(`package main
+
+import "fmt"
+
+func main() {
+	fmt.Println("line 6")
+	fmt.Println("line 7")
+	fmt.Println("line 8")
+	fmt.Println("line 9")
+	fmt.Println("line 10")
+	fmt.Println("line 11")
+}
+`),
+		},
+		"util.go": &fstest.MapFile{
+			Data: []byte(`package main
+
+func helper() {
+	// line 4
+	return // line 5
+}
+`),
+		},
+	}
+
+	// Serialize
+	opts := SerializeOptions{FS: memfs}
+	err := Serialize(pr, opts)
+	require.NoError(t, err)
+
+	// Verify PR-STATE.txt was created
+	_, ok := memfs[PRStateFile]
+	require.True(t, ok, "PR-STATE.txt should exist")
+
+	// Deserialize
+	pr2, err := Deserialize(opts)
+	require.NoError(t, err)
+
+	// Compare key fields
+	assert.Equal(t, pr.ID, pr2.ID)
+	assert.Equal(t, pr.Number, pr2.Number)
+	assert.Equal(t, pr.HeadRefOID, pr2.HeadRefOID)
+
+	// Compare threads
+	require.Len(t, pr2.ReviewThreads, len(pr.ReviewThreads))
+	for i, thread := range pr.ReviewThreads {
+		t2 := pr2.ReviewThreads[i]
+		assert.Equal(t, thread.Path, t2.Path)
+		assert.Equal(t, thread.Line, t2.Line)
+		assert.Equal(t, thread.SubjectType, t2.SubjectType)
+
+		require.Len(t, t2.Comments, len(thread.Comments))
+		for j, comment := range thread.Comments {
+			c2 := t2.Comments[j]
+			assert.Equal(t, comment.ID, c2.ID)
+			assert.Equal(t, comment.Author.Login, c2.Author.Login)
+			assert.Equal(t, comment.Body, c2.Body)
+		}
+	}
+
+	// Compare issue comments
+	require.Len(t, pr2.IssueComments, len(pr.IssueComments))
+	for i, comment := range pr.IssueComments {
+		c2 := pr2.IssueComments[i]
+		assert.Equal(t, comment.ID, c2.ID)
+		assert.Equal(t, comment.Author.Login, c2.Author.Login)
+		assert.Equal(t, comment.Body, c2.Body)
+	}
+}
+
+func TestFileRoundTrip(t *testing.T) {
+	// Start with files that already have craft comments (new box char format)
+	mainGoWithComments := `package main
+
+import "fmt"
+
+func main() {
+	fmt.Println("hello")
+	// ` + /* break up string so we can use craft in this repo */ `╓───── @alice ─ at 2025-01-15 12:34 ─ sum 50c8483b ─ v2 ─ prrc kwDOPgi5ks6IymTJ
+	// ║ Nice print statement!
+	fmt.Println("world")
+}
+`
+	prState := `───── pr ─ number 42 ─ pr kwDOPgi5ks6k-agY ─ head abc123 ─ v2
+
+───── @dave ─ at 2025-01-17 10:00 ─ sum 15711cf4 ─ v2 ─ ic kwDOPgi5ks1234567
+Overall LGTM!
+
+───── files
+main.go
+
+`
+
+	memfs := fstest.MapFS{
+		"main.go":   &fstest.MapFile{Data: []byte(mainGoWithComments)},
+		PRStateFile: &fstest.MapFile{Data: []byte(prState)},
+	}
+
+	// Deserialize
+	opts := SerializeOptions{FS: memfs}
+	pr, err := Deserialize(opts)
+	require.NoError(t, err)
+
+	// Verify we got the data
+	assert.Equal(t, 42, pr.Number)
+	require.Len(t, pr.ReviewThreads, 1)
+	assert.Equal(t, "main.go", pr.ReviewThreads[0].Path)
+	assert.Equal(t, 6, pr.ReviewThreads[0].Line) // Comment is after line 6
+	assert.Equal(t, "Nice print statement!", pr.ReviewThreads[0].Comments[0].Body)
+	require.Len(t, pr.IssueComments, 1)
+	assert.Equal(t, "Overall LGTM!", pr.IssueComments[0].Body)
+
+	// Serialize right on top of existing files (should be idempotent)
+	err = Serialize(pr, opts)
+	require.NoError(t, err)
+
+	// Check for exact byte match
+	assert.Equal(t, mainGoWithComments, string(memfs["main.go"].Data))
+	assert.Equal(t, prState, string(memfs[PRStateFile].Data))
+}
+
+func TestNewCommentRoundTrip(t *testing.T) {
+	// Test that new comments (isNew: true) round-trip correctly
+	pr := &PullRequest{
+		ID:         "PR_kwDOPgi5ks6k-agY",
+		Number:     99,
+		HeadRefOID: "deadbeef",
+		ReviewThreads: []ReviewThread{
+			{
+				Path:        "test.go",
+				DiffSide:    DiffSideRight,
+				Line:        3,
+				SubjectType: SubjectTypeLine,
+				Comments: []ReviewComment{
+					{
+						Body:  "This is a new comment",
+						IsNew: true,
+					},
+				},
+			},
+		},
+	}
+
+	memfs := fstest.MapFS{
+		"test.go": &fstest.MapFile{
+			Data: []byte("line 1\nline 2\nline 3\nline 4\n"),
+		},
+	}
+
+	opts := SerializeOptions{FS: memfs}
+	err := Serialize(pr, opts)
+	require.NoError(t, err)
+
+	pr2, err := Deserialize(opts)
+	require.NoError(t, err)
+
+	require.Len(t, pr2.ReviewThreads, 1)
+	require.Len(t, pr2.ReviewThreads[0].Comments, 1)
+	assert.True(t, pr2.ReviewThreads[0].Comments[0].IsNew)
+	assert.Equal(t, "This is a new comment", pr2.ReviewThreads[0].Comments[0].Body)
+}
+
+func TestMultipleThreadsSameLine(t *testing.T) {
+	// Test multiple threads on the same line
+	pr := &PullRequest{
+		ID:         "PR_kwDOPgi5ks6k-agY",
+		Number:     100,
+		HeadRefOID: "cafebabe",
+		ReviewThreads: []ReviewThread{
+			{
+				ID:          "PRRT_1",
+				Path:        "code.go",
+				DiffSide:    DiffSideRight,
+				Line:        2,
+				SubjectType: SubjectTypeLine,
+				Comments: []ReviewComment{
+					{
+						ID:        "PRRC_1",
+						Author:    Actor{Login: "alice"},
+						Body:      "First thread comment",
+						CreatedAt: time.Date(2025, 1, 1, 10, 0, 0, 0, time.UTC),
+					},
+				},
+			},
+			{
+				ID:          "PRRT_2",
+				Path:        "code.go",
+				DiffSide:    DiffSideRight,
+				Line:        2,
+				SubjectType: SubjectTypeLine,
+				Comments: []ReviewComment{
+					{
+						ID:        "PRRC_2",
+						Author:    Actor{Login: "bob"},
+						Body:      "Second thread comment",
+						CreatedAt: time.Date(2025, 1, 1, 11, 0, 0, 0, time.UTC),
+					},
+				},
+			},
+		},
+	}
+
+	memfs := fstest.MapFS{
+		"code.go": &fstest.MapFile{
+			Data: []byte("line 1\nline 2\nline 3\n"),
+		},
+	}
+
+	opts := SerializeOptions{FS: memfs}
+	err := Serialize(pr, opts)
+	require.NoError(t, err)
+
+	pr2, err := Deserialize(opts)
+	require.NoError(t, err)
+
+	// Should have 2 threads
+	require.Len(t, pr2.ReviewThreads, 2)
+	assert.Equal(t, "First thread comment", pr2.ReviewThreads[0].Comments[0].Body)
+	assert.Equal(t, "Second thread comment", pr2.ReviewThreads[1].Comments[0].Body)
+}
+
+func TestMultilineCommentBody(t *testing.T) {
+	pr := &PullRequest{
+		ID:         "PR_test",
+		Number:     1,
+		HeadRefOID: "abcd1234",
+		ReviewThreads: []ReviewThread{
+			{
+				ID:          "PRRT_1",
+				Path:        "file.go",
+				DiffSide:    DiffSideRight,
+				Line:        1,
+				SubjectType: SubjectTypeLine,
+				Comments: []ReviewComment{
+					{
+						ID:        "PRRC_1",
+						Author:    Actor{Login: "alice"},
+						Body:      "Line one\n\nLine two\n\nLine three", // Paragraph breaks preserve lines
+						CreatedAt: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+					},
+				},
+			},
+		},
+	}
+
+	memfs := fstest.MapFS{
+		"file.go": &fstest.MapFile{
+			Data: []byte("code here\n"),
+		},
+	}
+
+	opts := SerializeOptions{FS: memfs}
+	err := Serialize(pr, opts)
+	require.NoError(t, err)
+
+	pr2, err := Deserialize(opts)
+	require.NoError(t, err)
+
+	require.Len(t, pr2.ReviewThreads, 1)
+	assert.Equal(t, "Line one\n\nLine two\n\nLine three", pr2.ReviewThreads[0].Comments[0].Body)
+}
+
+func TestPRStateAuthorAndBody(t *testing.T) {
+	pr := &PullRequest{
+		ID:         "PR_kwDOPgi5ks6k-agY",
+		Number:     42,
+		HeadRefOID: "abc123",
+		Author:     Actor{Login: "alice"},
+		Body:       "This is the PR description.\n\nIt has multiple paragraphs.",
+		IssueComments: []IssueComment{
+			{
+				ID:        "IC_kwDOPgi5ks1234567",
+				Author:    Actor{Login: "bob"},
+				Body:      "LGTM!",
+				CreatedAt: time.Date(2025, 1, 17, 10, 0, 0, 0, time.UTC),
+			},
+		},
+	}
+
+	memfs := fstest.MapFS{}
+	opts := SerializeOptions{FS: memfs}
+	err := Serialize(pr, opts)
+	require.NoError(t, err)
+
+	// Verify PR-STATE.txt contains author and body
+	stateData := string(memfs[PRStateFile].Data)
+	assert.Contains(t, stateData, "@alice")
+	assert.Contains(t, stateData, "This is the PR description.")
+
+	// Deserialize and verify author is parsed, body is ignored
+	pr2, err := Deserialize(opts)
+	require.NoError(t, err)
+
+	assert.Equal(t, "alice", pr2.Author.Login)
+	assert.Empty(t, pr2.Body) // Body is not deserialized
+	require.Len(t, pr2.IssueComments, 1)
+	assert.Equal(t, "LGTM!", pr2.IssueComments[0].Body)
+}
+
+func TestPRStateStackParent(t *testing.T) {
+	pr := &PullRequest{
+		ID:             "PR_kwDOPgi5ks6k-agY",
+		Number:         43,
+		HeadRefOID:     "abc123",
+		BaseRefOID:     "def456",
+		StackParent:    42,
+		StackParentOID: "789abc",
+	}
+
+	memfs := fstest.MapFS{}
+	opts := SerializeOptions{FS: memfs}
+	require.NoError(t, Serialize(pr, opts))
+	assert.Contains(t, string(memfs[PRStateFile].Data), "─ base def456 ─ stack 42 ─ stackhead 789abc ─")
+
+	pr2, err := Deserialize(opts)
+	require.NoError(t, err)
+	assert.Equal(t, 42, pr2.StackParent)
+	assert.Equal(t, "789abc", pr2.StackParentOID)
+	assert.Empty(t, pr2.HeaderExtra)
+	assert.Equal(t, "789abc", pr2.EffectiveBase())
+
+	// Not stacked: no fields, and the base is the base commit
+	pr.StackParent, pr.StackParentOID = 0, ""
+	require.NoError(t, Serialize(pr, opts))
+	assert.NotContains(t, string(memfs[PRStateFile].Data), "stack")
+	pr2, err = Deserialize(opts)
+	require.NoError(t, err)
+	assert.Equal(t, "def456", pr2.EffectiveBase())
+
+	// Reviewing a commit
+	pr.ReviewCommitOID = "fed987"
+	require.NoError(t, Serialize(pr, opts))
+	assert.Contains(t, string(memfs[PRStateFile].Data), "─ base def456 ─ commit fed987 ─")
+	pr2, err = Deserialize(opts)
+	require.NoError(t, err)
+	assert.Equal(t, "fed987", pr2.CheckoutOID())
+	assert.Empty(t, pr2.HeaderExtra)
+
+	// The base before it moved
+	pr.PreviousBaseRefOID = "0123ab"
+	require.NoError(t, Serialize(pr, opts))
+	assert.Contains(t, string(memfs[PRStateFile].Data), "─ base def456 ─ prevbase 0123ab ─")
+	pr2, err = Deserialize(opts)
+	require.NoError(t, err)
+	assert.Equal(t, "0123ab", pr2.PreviousBaseRefOID)
+	assert.Empty(t, pr2.HeaderExtra)
+}
+
+func TestPRStateLocal(t *testing.T) {
+	pr := &PullRequest{IsLocal: true, HeadRefOID: "abc123", BaseRefOID: "def456"}
+
+	memfs := fstest.MapFS{}
+	opts := SerializeOptions{FS: memfs}
+	require.NoError(t, Serialize(pr, opts))
+	assert.Contains(t, string(memfs[PRStateFile].Data), "───── pr ─ number 0 ─ local ─ head abc123 ─ base def456 ─ v2\n")
+
+	pr2, err := Deserialize(opts)
+	require.NoError(t, err)
+	assert.True(t, pr2.IsLocal)
+	assert.Empty(t, pr2.ID)
+	assert.Empty(t, pr2.HeaderExtra)
+}
+
+func TestPRStateInPlace(t *testing.T) {
+	pr := &PullRequest{Number: 7, ID: "PR_kwDOabc", InPlace: true, HeadRefOID: "abc123"}
+
+	memfs := fstest.MapFS{}
+	opts := SerializeOptions{FS: memfs}
+	require.NoError(t, Serialize(pr, opts))
+	assert.Contains(t, string(memfs[PRStateFile].Data), " ─ inplace ─ head abc123 ─")
+
+	pr2, err := Deserialize(opts)
+	require.NoError(t, err)
+	assert.True(t, pr2.InPlace)
+	assert.Equal(t, 7, pr2.Number)
+	assert.Empty(t, pr2.HeaderExtra)
+}
+
+func TestPRStateClosed(t *testing.T) {
+	for _, state := range []string{"MERGED", "CLOSED"} {
+		pr := &PullRequest{Number: 7, ID: "PR_kwDOabc", State: state, HeadRefOID: "abc123"}
+
+		memfs := fstest.MapFS{}
+		opts := SerializeOptions{FS: memfs}
+		require.NoError(t, Serialize(pr, opts))
+		assert.Contains(t, string(memfs[PRStateFile].Data), " ─ "+state+" ─ head abc123 ─")
+
+		pr2, err := Deserialize(opts)
+		require.NoError(t, err)
+		assert.True(t, pr2.IsClosed())
+		assert.Equal(t, state, pr2.State)
+		assert.Empty(t, pr2.HeaderExtra)
+	}
+
+	// An open PR's state isn't written
+	pr := &PullRequest{Number: 7, ID: "PR_kwDOabc", State: "OPEN", HeadRefOID: "abc123"}
+	memfs := fstest.MapFS{}
+	require.NoError(t, Serialize(pr, SerializeOptions{FS: memfs}))
+	assert.NotContains(t, string(memfs[PRStateFile].Data), "OPEN")
+	pr2, err := Deserialize(SerializeOptions{FS: memfs})
+	require.NoError(t, err)
+	assert.False(t, pr2.IsClosed())
+}
+
+func TestPRStateDraft(t *testing.T) {
+	pr := &PullRequest{Number: 7, ID: "PR_kwDOabc", IsDraft: true, HeadRefOID: "abc123"}
+
+	memfs := fstest.MapFS{}
+	opts := SerializeOptions{FS: memfs}
+	require.NoError(t, Serialize(pr, opts))
+	assert.Contains(t, string(memfs[PRStateFile].Data), " ─ DRAFT ─ head abc123 ─")
+
+	pr2, err := Deserialize(opts)
+	require.NoError(t, err)
+	assert.True(t, pr2.IsDraft)
+	assert.Empty(t, pr2.HeaderExtra)
+}
+
+func TestNewPRLevelComment(t *testing.T) {
+	// Test that new PR-level comments (───── new) are detected in PR-STATE.txt
+	prState := `───── pr ─ number 42 ─ pr kwDOPgi5ks6k-agY ─ head abc123
+
+───── @alice ─ at 2025-01-15 12:00 ─ ic kwDOPgi5ks1234567
+Existing comment from alice
+
+───── new
+This is a new PR-level comment
+with multiple lines
+`
+
+	memfs := fstest.MapFS{
+		PRStateFile: &fstest.MapFile{Data: []byte(prState)},
+	}
+
+	opts := SerializeOptions{FS: memfs}
+	pr, err := Deserialize(opts)
+	require.NoError(t, err)
+
+	// Should have 2 issue comments
+	require.Len(t, pr.IssueComments, 2)
+
+	// First comment is existing (not new)
+	assert.False(t, pr.IssueComments[0].IsNew)
+	assert.Equal(t, "alice", pr.IssueComments[0].Author.Login)
+	assert.Equal(t, "Existing comment from alice", pr.IssueComments[0].Body)
+
+	// Second comment is new (soft line breaks are unwrapped)
+	assert.True(t, pr.IssueComments[1].IsNew)
+	assert.Equal(t, "This is a new PR-level comment with multiple lines", pr.IssueComments[1].Body)
+}
+
+func TestOutdatedResolvedHeaders(t *testing.T) {
+	tests := []struct {
+		name   string
+		header Header
+	}{
+		{
+			name: "outdated comment",
+			header: Header{
+				Author:     "alice",
+				Timestamp:  time.Date(2025, 1, 15, 12, 34, 0, 0, time.UTC),
+				NodeID:     "PRRC_kwDOPgi5ks6ZBMOo",
+				IsOutdated: true,
+			},
+		},
+		{
+			name: "resolved comment",
+			header: Header{
+				Author:     "bob",
+				Timestamp:  time.Date(2025, 2, 20, 8, 0, 0, 0, time.UTC),
+				NodeID:     "PRRC_kwDOPgi5ks6ABC123",
+				IsResolved: true,
+			},
+		},
+		{
+			name: "outdated and resolved",
+			header: Header{
+				Author:     "carol",
+				Timestamp:  time.Date(2025, 3, 10, 16, 45, 0, 0, time.UTC),
+				NodeID:     "PRRC_kwDOPgi5ks6XYZ789",
+				IsOutdated: true,
+				IsResolved: true,
+			},
+		},
+		{
+			name: "with origline",
+			header: Header{
+				Author:     "dave",
+				Timestamp:  time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC),
+				NodeID:     "PRRC_kwDOPgi5ks6DEF456",
+				IsOutdated: true,
+				OrigLine:   42,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			formatted := FormatHeader(tt.header)
+			parsed, ok := ParseHeader(formatted)
+			require.True(t, ok, "parseHeader should succeed")
+
+			assert.Equal(t, tt.header.Author, parsed.Author)
+			assert.Equal(t, tt.header.Timestamp.Unix(), parsed.Timestamp.Unix())
+			assert.Equal(t, tt.header.NodeID, parsed.NodeID)
+			assert.Equal(t, tt.header.IsOutdated, parsed.IsOutdated)
+			assert.Equal(t, tt.header.IsResolved, parsed.IsResolved)
+			assert.Equal(t, tt.header.OrigLine, parsed.OrigLine)
+		})
+	}
+}
+
+func TestOutdatedThreadsAtEndOfFile(t *testing.T) {
+	// Test that threads with out-of-bounds line numbers go to end of file
+	pr := &PullRequest{
+		ID:         "PR_test",
+		Number:     1,
+		HeadRefOID: "abcd1234",
+		ReviewThreads: []ReviewThread{
+			{
+				ID:           "PRRT_valid",
+				Path:         "file.go",
+				DiffSide:     DiffSideRight,
+				Line:         2, // valid line
+				OriginalLine: 2,
+				SubjectType:  SubjectTypeLine,
+				Comments: []ReviewComment{
+					{
+						ID:        "PRRC_valid",
+						Author:    Actor{Login: "alice"},
+						Body:      "Valid comment on line 2",
+						CreatedAt: time.Date(2025, 1, 1, 10, 0, 0, 0, time.UTC),
+					},
+				},
+			},
+			{
+				ID:           "PRRT_outdated",
+				Path:         "file.go",
+				DiffSide:     DiffSideRight,
+				Line:         0, // out of bounds - outdated
+				OriginalLine: 50,
+				SubjectType:  SubjectTypeLine,
+				IsResolved:   true,
+				Comments: []ReviewComment{
+					{
+						ID:        "PRRC_outdated",
+						Author:    Actor{Login: "bob"},
+						Body:      "Outdated comment",
+						CreatedAt: time.Date(2025, 1, 1, 9, 0, 0, 0, time.UTC),
+					},
+				},
+			},
+		},
+	}
+
+	memfs := fstest.MapFS{
+		"file.go": &fstest.MapFile{
+			Data: []byte("line 1\nline 2\nline 3\n"),
+		},
+	}
+
+	opts := SerializeOptions{FS: memfs}
+	err := Serialize(pr, opts)
+	require.NoError(t, err)
+
+	// Check the file content
+	content := string(memfs["file.go"].Data)
+
+	// Should have the valid comment after line 2
+	assert.Contains(t, content, "Valid comment on line 2")
+
+	// Should have outdated section at end
+	assert.Contains(t, content, "━━━━━━━━━ outdated comments")
+	assert.Contains(t, content, "Outdated comment")
+	assert.Contains(t, content, "origline 50")
+	assert.Contains(t, content, "resolved")
+
+	// Deserialize and verify we get both threads back
+	pr2, err := Deserialize(opts)
+	require.NoError(t, err)
+
+	require.Len(t, pr2.ReviewThreads, 2)
+
+	// Find the valid and outdated comments
+	var validThread, outdatedThread *ReviewThread
+	for i := range pr2.ReviewThreads {
+		if pr2.ReviewThreads[i].Comments[0].Body == "Valid comment on line 2" {
+			validThread = &pr2.ReviewThreads[i]
+		} else {
+			outdatedThread = &pr2.ReviewThreads[i]
+		}
+	}
+
+	require.NotNil(t, validThread)
+	require.NotNil(t, outdatedThread)
+
+	assert.Equal(t, 2, validThread.Line)
+	assert.Equal(t, "Outdated comment", outdatedThread.Comments[0].Body)
+}
+
+func TestEditedComment(t *testing.T) {
+	pr := &PullRequest{
+		ID:         "PR_test",
+		Number:     1,
+		HeadRefOID: "abcd1234",
+		ReviewThreads: []ReviewThread{{
+			ID:          "PRRT_1",
+			Path:        "file.go",
+			Line:        1,
+			DiffSide:    DiffSideRight,
+			SubjectType: SubjectTypeLine,
+			Comments: []ReviewComment{
+				{
+					ID:           "PRRC_first",
+					Author:       Actor{Login: "bob"},
+					Body:         "Rename this to total.",
+					CreatedAt:    time.Date(2025, 1, 1, 9, 0, 0, 0, time.UTC),
+					IsEdited:     true,
+					PreviousBody: "Rename this to count.",
+				},
+				{
+					ID:        "PRRC_reply",
+					Author:    Actor{Login: "alice"},
+					Body:      "Done",
+					CreatedAt: time.Date(2025, 1, 1, 10, 0, 0, 0, time.UTC),
+				},
+			},
+		}},
+		IssueComments: []IssueComment{{
+			ID:        "IC_1",
+			Author:    Actor{Login: "carol"},
+			Body:      "LGTM",
+			CreatedAt: time.Date(2025, 1, 2, 9, 0, 0, 0, time.UTC),
+			IsEdited:  true,
+		}},
+	}
+
+	memfs := fstest.MapFS{"file.go": &fstest.MapFile{Data: []byte("package main\n")}}
+	opts := SerializeOptions{FS: memfs, Originals: pr.CommentBodies()}
+	require.NoError(t, Serialize(pr, opts))
+	content := string(memfs["file.go"].Data)
+	assert.Contains(t, content, " ─ edited ─ ")
+	assert.Contains(t, content, "// ┆ before edit:\n// ┆ Rename this to count.\n// ║ Rename this to total.\n")
+	assert.Equal(t, 1, strings.Count(content, "edited"))
+	assert.Contains(t, string(memfs[PRStateFile].Data), "@carol ─ at 2025-01-02 09:00 ─ edited ─ ")
+
+	pr2, err := Deserialize(opts)
+	require.NoError(t, err)
+	require.Len(t, pr2.ReviewThreads, 1)
+	comments := pr2.ReviewThreads[0].Comments
+	require.Len(t, comments, 2)
+	assert.True(t, comments[0].IsEdited)
+	assert.False(t, comments[0].IsModified)
+	assert.Equal(t, "Rename this to total.", comments[0].Body)
+	assert.False(t, comments[1].IsEdited)
+	require.Len(t, pr2.IssueComments, 1)
+	assert.True(t, pr2.IssueComments[0].IsEdited)
+	assert.False(t, pr2.IssueComments[0].IsModified)
+}
+
+func TestOutdatedFile(t *testing.T) {
+	thread := func(id, path string, line, originalLine int) ReviewThread {
+		return ReviewThread{
+			ID:           "PRRT_" + id,
+			Path:         path,
+			Line:         line,
+			OriginalLine: originalLine,
+			DiffSide:     DiffSideRight,
+			SubjectType:  SubjectTypeLine,
+			Comments: []ReviewComment{{
+				ID:        "PRRC_" + id,
+				Author:    Actor{Login: "bob"},
+				Body:      "About " + id,
+				CreatedAt: time.Date(2025, 1, 1, 9, 0, 0, 0, time.UTC),
+			}},
+		}
+	}
+	current := thread("current", "a.go", 2, 2)
+	resolved := thread("resolved", "a.go", 1, 1)
+	resolved.IsResolved = true
+	outdated := thread("outdated", "b.go", 0, 7)
+	outdated.IsOutdated = true
+	outdated.DiffHunk = "@@ -7,1 +7,1 @@\n-old()\n+new()"
+	left := thread("left", "a.go", 0, 5)
+	left.DiffSide = DiffSideLeft
+	pr := &PullRequest{
+		ID:            "PR_test",
+		Number:        1,
+		HeadRefOID:    "abcd1234",
+		ReviewThreads: []ReviewThread{current, resolved, outdated, left},
+	}
+
+	memfs := fstest.MapFS{
+		"a.go": &fstest.MapFile{Data: []byte("package a\n\nfunc a() {}\n")},
+		"b.go": &fstest.MapFile{Data: []byte("package b\n// ╓ ─────── @old ─ PRRC_stale\n// ║ Stale\n")},
+	}
+	opts := SerializeOptions{FS: memfs, OutdatedFile: true, Originals: pr.CommentBodies()}
+	require.NoError(t, Serialize(pr, opts))
+
+	// Only the current thread stays in the source files
+	a := string(memfs["a.go"].Data)
+	assert.Contains(t, a, "About current")
+	assert.NotContains(t, a, "About resolved")
+	assert.NotContains(t, a, OutdatedCommentsHeader)
+	assert.Equal(t, "package b\n", string(memfs["b.go"].Data))
+
+	// Grouped by file, in order of original line
+	content := string(memfs[OutdatedFile].Data)
+	assert.Regexp(t, `(?s)^───── file a\.go\n╓.* resolved ─ origline 1 .*About resolved`+
+		`\n╓.* outdated ─ origline 5 .*About left\n\n───── file b\.go\n╓.* outdated ─ origline 7 .*`+
+		`\n┆ -old\(\)\n┆ \+new\(\)\n║ About outdated\n$`, content)
+	assert.NotRegexp(t, `outdated ─ resolved`, content)
+
+	// Round trip
+	pr2, err := Deserialize(opts)
+	require.NoError(t, err)
+	byID := make(map[string]ReviewThread)
+	for _, th := range pr2.ReviewThreads {
+		require.Len(t, th.Comments, 1)
+		assert.False(t, th.Comments[0].IsModified, th.Comments[0].Body)
+		byID[th.Comments[0].ID] = th
+	}
+	require.Len(t, byID, 4)
+	assert.Equal(t, "a.go", byID["PRRC_resolved"].Path)
+	assert.True(t, byID["PRRC_resolved"].IsResolved)
+	assert.Equal(t, 1, byID["PRRC_resolved"].OriginalLine)
+	assert.Equal(t, "b.go", byID["PRRC_outdated"].Path)
+	assert.True(t, byID["PRRC_outdated"].IsOutdated)
+	assert.Equal(t, 2, byID["PRRC_current"].Line)
+
+	// The hunk isn't stored, but comes back when fetched again
+	for i := range pr2.ReviewThreads {
+		if pr2.ReviewThreads[i].Comments[0].ID == outdated.Comments[0].ID {
+			pr2.ReviewThreads[i].DiffHunk = outdated.DiffHunk
+		}
+	}
+	require.NoError(t, Serialize(pr2, opts))
+	assert.Equal(t, content, string(memfs[OutdatedFile].Data))
+	assert.Equal(t, a, string(memfs["a.go"].Data))
+
+	// Replies and parse errors are found in the outdated file
+	memfs[OutdatedFile].Data = []byte(strings.Replace(content, "║ About outdated\n", "║ About outdated\n╟ ─────── @alice ─ new\n║ Fixed\n║ stray\n\n║ orphan\n", 1))
+	_, err = Deserialize(opts)
+	var parseErrs ParseErrors
+	require.ErrorAs(t, err, &parseErrs)
+	require.Len(t, parseErrs, 1)
+	assert.Equal(t, OutdatedFile, parseErrs[0].Path)
+	lines := strings.Split(string(memfs[OutdatedFile].Data), "\n")
+	assert.Equal(t, "║ orphan", lines[parseErrs[0].Line-1])
+
+	// New threads need a line to go on
+	memfs[OutdatedFile].Data = []byte(content + "╓───── new\n║ Where does this go?\n")
+	_, err = Deserialize(opts)
+	require.ErrorAs(t, err, &parseErrs)
+	require.Len(t, parseErrs, 1)
+	assert.Equal(t, "PR-OUTDATED.txt:7: new thread under b.go; start it in the file itself", parseErrs[0].Error())
+}
+
+func TestHideResolved(t *testing.T) {
+	thread := func(path string, resolved bool) ReviewThread {
+		return ReviewThread{
+			Path:        path,
+			Line:        1,
+			DiffSide:    DiffSideRight,
+			SubjectType: SubjectTypeLine,
+			IsResolved:  resolved,
+			Comments: []ReviewComment{{
+				ID:        fmt.Sprintf("PRRC_%s_%v", path, resolved),
+				Author:    Actor{Login: "bob"},
+				Body:      fmt.Sprintf("resolved=%v", resolved),
+				CreatedAt: time.Date(2025, 1, 1, 9, 0, 0, 0, time.UTC),
+			}},
+		}
+	}
+	pr := &PullRequest{
+		ID:         "PR_test",
+		Number:     1,
+		HeadRefOID: "abcd1234",
+		ReviewThreads: []ReviewThread{
+			thread("a.go", false),
+			thread("a.go", true),
+			thread("b.go", true),
+		},
+	}
+	assert.Equal(t, 2, pr.ResolvedThreadCount())
+
+	memfs := fstest.MapFS{
+		"a.go": &fstest.MapFile{Data: []byte("package a\n")},
+		"b.go": &fstest.MapFile{Data: []byte("package b\n")},
+	}
+	opts := SerializeOptions{FS: memfs}
+	require.NoError(t, Serialize(pr, opts))
+	assert.Contains(t, string(memfs["a.go"].Data), "resolved=true")
+	assert.Contains(t, string(memfs["b.go"].Data), "resolved=true")
+
+	// Hiding them again takes them out of the files
+	opts.HideResolved = true
+	require.NoError(t, Serialize(pr, opts))
+	assert.Contains(t, string(memfs["a.go"].Data), "resolved=false")
+	assert.NotContains(t, string(memfs["a.go"].Data), "resolved=true")
+	assert.Equal(t, "package b\n", string(memfs["b.go"].Data))
+
+	pr2, err := Deserialize(opts)
+	require.NoError(t, err)
+	require.Len(t, pr2.ReviewThreads, 1)
+	assert.Zero(t, pr2.ResolvedThreadCount())
+
+	// Not in PR-OUTDATED.txt either
+	opts.OutdatedFile = true
+	require.NoError(t, Serialize(pr, opts))
+	assert.Empty(t, memfs[OutdatedFile].Data)
+}
+
+func TestCommentsAbove(t *testing.T) {
+	startLine := 3
+	pr := &PullRequest{
+		ID:         "PR_test",
+		Number:     1,
+		HeadRefOID: "abcd1234",
+		ReviewThreads: []ReviewThread{
+			{
+				Path: "main.go", Line: 4, StartLine: &startLine, DiffSide: DiffSideRight, SubjectType: SubjectTypeLine,
+				Comments: []ReviewComment{
+					{ID: "PRRC_range", Author: Actor{Login: "bob"}, Body: "Range", CreatedAt: time.Date(2025, 1, 1, 9, 0, 0, 0, time.UTC)},
+					{ID: "PRRC_reply", Author: Actor{Login: "alice"}, Body: "Reply", CreatedAt: time.Date(2025, 1, 1, 10, 0, 0, 0, time.UTC)},
+				},
+			},
+			{
+				Path: "main.go", Line: 3, DiffSide: DiffSideRight, SubjectType: SubjectTypeLine,
+				Comments: []ReviewComment{
+					{ID: "PRRC_line", Author: Actor{Login: "bob"}, Body: "Line", CreatedAt: time.Date(2025, 1, 2, 9, 0, 0, 0, time.UTC)},
+				},
+			},
+			{
+				Path: "main.go", Line: 1, DiffSide: DiffSideRight, SubjectType: SubjectTypeLine,
+				Comments: []ReviewComment{
+					{ID: "PRRC_first", Author: Actor{Login: "bob"}, Body: "First", CreatedAt: time.Date(2025, 1, 3, 9, 0, 0, 0, time.UTC)},
+				},
+			},
+		},
+	}
+
+	memfs := fstest.MapFS{
+		"main.go": &fstest.MapFile{Data: []byte("package main\n\nfunc main() {\n\tx()\n}\n")},
+	}
+	opts := SerializeOptions{FS: memfs, CommentsAbove: true}
+	require.NoError(t, Serialize(pr, opts))
+	expected := "// ╓───── @bob ─ at 2025-01-03 09:00 ─ above ─ sum a151ceb1 ─ v2 ─ prrc first\n" +
+		"// ║ First\n" +
+		"package main\n" +
+		"\n" +
+		"// ╓───── @bob ─ at 2025-01-01 09:00 ─ range -1 ─ above ─ sum 5de74a81 ─ v2 ─ prrc range\n" +
+		"// ║ Range\n" +
+		"// ╟───── @alice ─ at 2025-01-01 10:00 ─ range -1 ─ sum c253f451 ─ v2 ─ prrc reply\n" +
+		"// ║ Reply\n" +
+		"// ╓───── @bob ─ at 2025-01-02 09:00 ─ above ─ sum d7852cd0 ─ v2 ─ prrc line\n" +
+		"// ║ Line\n" +
+		"func main() {\n" +
+		"\tx()\n" +
+		"}\n"
+	assert.Equal(t, expected, string(memfs["main.go"].Data))
+
+	// Read back the same, whatever the option
+	opts.CommentsAbove = false
+	pr2, err := Deserialize(opts)
+	require.NoError(t, err)
+	require.Len(t, pr2.ReviewThreads, 3)
+	lines := make(map[string][2]int)
+	for _, thread := range pr2.ReviewThreads {
+		start := 0
+		if thread.StartLine != nil {
+			start = *thread.StartLine
+		}
+		lines[thread.Comments[0].ID] = [2]int{start, thread.Line}
+	}
+	assert.Equal(t, map[string][2]int{
+		"PRRC_first": {0, 1},
+		"PRRC_range": {3, 4},
+		"PRRC_line":  {0, 3},
+	}, lines)
+	assert.Len(t, pr2.ReviewThreads[1].Comments, 2)
+
+	// Switching back moves them below
+	require.NoError(t, Serialize(pr2, opts))
+	assert.Contains(t, string(memfs["main.go"].Data), "\tx()\n\t// ╓───── @bob ─ at 2025-01-01 09:00 ─ range -1 ─ sum")
+	assert.NotContains(t, string(memfs["main.go"].Data), "above")
+
+	// Hand-typed threads above the last line, and trailing ones
+	memfs["main.go"].Data = []byte("package main\n// ╓───── new ─ above\n// ║ Before\nfunc f() {}\n// ╓───── new ─ above\n// ║ At the end")
+	pr3, err := Deserialize(opts)
+	require.NoError(t, err)
+	require.Len(t, pr3.ReviewThreads, 2)
+	assert.Equal(t, 2, pr3.ReviewThreads[0].Line)
+	assert.Equal(t, 2, pr3.ReviewThreads[1].Line)
+}
+
+func TestFileLevelThreads(t *testing.T) {
+	pr := &PullRequest{
+		ID:         "PR_test",
+		Number:     1,
+		HeadRefOID: "abcd1234",
+		ReviewThreads: []ReviewThread{
+			{
+				Path: "main.go", Line: 2, DiffSide: DiffSideRight, SubjectType: SubjectTypeLine,
+				Comments: []ReviewComment{
+					{ID: "PRRC_line", Author: Actor{Login: "bob"}, Body: "Line", CreatedAt: time.Date(2025, 1, 1, 9, 0, 0, 0, time.UTC)},
+				},
+			},
+			{
+				// GitHub has no line for file-level threads
+				Path: "main.go", DiffSide: DiffSideRight, SubjectType: SubjectTypeFile,
+				Comments: []ReviewComment{
+					{ID: "PRRC_file", Author: Actor{Login: "bob"}, Body: "Whole file", CreatedAt: time.Date(2025, 1, 2, 9, 0, 0, 0, time.UTC)},
+				},
+			},
+		},
+	}
+
+	memfs := fstest.MapFS{"main.go": &fstest.MapFile{Data: []byte("package main\n\n\tfunc main() {}\n")}}
+	opts := SerializeOptions{FS: memfs}
+	require.NoError(t, Serialize(pr, opts))
+	content := string(memfs["main.go"].Data)
+	assert.True(t, strings.HasPrefix(content, "// ╓───── @bob ─ at 2025-01-02 09:00 ─ file ─ sum "), content)
+	assert.NotContains(t, content, OutdatedCommentsHeader)
+
+	// A new one can go anywhere
+	content = strings.Replace(content, "\tfunc main() {}\n", "\tfunc main() {}\n\t// ╓───── new ─ file\n\t// ║ Also whole file\n", 1)
+	memfs["main.go"].Data = []byte(content)
+	pr2, err := Deserialize(opts)
+	require.NoError(t, err)
+	review, err := CollectNewComments(pr2, CollectOptions{})
+	require.NoError(t, err)
+	require.Len(t, review.NewThreads, 1)
+	assert.Equal(t, NewThreadInfo{Path: "main.go", Side: DiffSideRight, Subject: SubjectTypeFile, Body: "Also whole file"}, review.NewThreads[0])
+	for _, thread := range pr2.ReviewThreads {
+		if thread.SubjectType == SubjectTypeFile {
+			assert.Zero(t, thread.Line)
+		} else {
+			assert.Equal(t, 2, thread.Line)
+		}
+	}
+
+	// They stay in the file with OutdatedFile, and don't get above
+	memfs["main.go"].Data = []byte("package main\n\n\tfunc main() {}\n")
+	opts.OutdatedFile = true
+	opts.CommentsAbove = true
+	require.NoError(t, Serialize(pr, opts))
+	content = string(memfs["main.go"].Data)
+	assert.Contains(t, content, "Whole file")
+	assert.NotContains(t, content, "file ─ above")
+	assert.Empty(t, memfs[OutdatedFile].Data)
+}
+
+func TestLineNumbersIgnoreCraftComments(t *testing.T) {
+	// Test that deserialize computes correct line numbers
+	// by not counting craft comment lines
+	fileContent := `line 1
+line 2
+// ` + `╓───── @alice ─ at 2025-01-15 12:34 ─ prrc kwDOPgi5ks6AAA111
+// ║ Comment on line 2
+line 3
+// ` + `╓───── @bob ─ at 2025-01-15 13:00 ─ prrc kwDOPgi5ks6BBB222
+// ║ Comment on line 3
+line 4
+`
+	prState := `───── pr ─ number 1 ─ pr kwDOPgi5ks6k-agY ─ head abc123
+
+`
+
+	memfs := fstest.MapFS{
+		"test.go":   &fstest.MapFile{Data: []byte(fileContent)},
+		PRStateFile: &fstest.MapFile{Data: []byte(prState)},
+	}
+
+	opts := SerializeOptions{FS: memfs}
+	pr, err := Deserialize(opts)
+	require.NoError(t, err)
+
+	require.Len(t, pr.ReviewThreads, 2)
+
+	// Sort by comment ID to get consistent ordering
+	threads := pr.ReviewThreads
+	if threads[0].Comments[0].ID > threads[1].Comments[0].ID {
+		threads[0], threads[1] = threads[1], threads[0]
+	}
+
+	// First comment should be on line 2 (not line 2 + craft lines)
+	assert.Equal(t, 2, threads[0].Line, "First comment should be on source line 2")
+	assert.Equal(t, "Comment on line 2", threads[0].Comments[0].Body)
+
+	// Second comment should be on line 3 (not line 3 + craft lines)
+	assert.Equal(t, 3, threads[1].Line, "Second comment should be on source line 3")
+	assert.Equal(t, "Comment on line 3", threads[1].Comments[0].Body)
+}
+
+func TestOutdatedResolvedInSerialize(t *testing.T) {
+	// Test that IsOutdated and IsResolved from threads are included in headers
+	pr := &PullRequest{
+		ID:         "PR_test",
+		Number:     1,
+		HeadRefOID: "abcd1234",
+		ReviewThreads: []ReviewThread{
+			{
+				ID:          "PRRT_1",
+				Path:        "file.go",
+				DiffSide:    DiffSideRight,
+				Line:        1,
+				SubjectType: SubjectTypeLine,
+				IsOutdated:  true,
+				IsResolved:  true,
+				Comments: []ReviewComment{
+					{
+						ID:        "PRRC_1",
+						Author:    Actor{Login: "alice"},
+						Body:      "Outdated and resolved",
+						CreatedAt: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+					},
+				},
+			},
+		},
+	}
+
+	memfs := fstest.MapFS{
+		"file.go": &fstest.MapFile{
+			Data: []byte("code here\n"),
+		},
+	}
+
+	opts := SerializeOptions{FS: memfs}
+	err := Serialize(pr, opts)
+	require.NoError(t, err)
+
+	content := string(memfs["file.go"].Data)
+	assert.Contains(t, content, "outdated")
+	assert.Contains(t, content, "resolved")
+}
+
+func TestDeletedFileCreatesOutdatedOnly(t *testing.T) {
+	// Test that comments on a deleted file create the file with just outdated section
+	pr := &PullRequest{
+		ID:         "PR_test",
+		Number:     1,
+		HeadRefOID: "abcd1234",
+		ReviewThreads: []ReviewThread{
+			{
+				ID:           "PRRT_deleted",
+				Path:         "deleted.go", // This file doesn't exist
+				DiffSide:     DiffSideRight,
+				Line:         10,
+				OriginalLine: 10,
+				SubjectType:  SubjectTypeLine,
+				Comments: []ReviewComment{
+					{
+						ID:        "PRRC_deleted",
+						Author:    Actor{Login: "alice"},
+						Body:      "Comment on deleted file",
+						CreatedAt: time.Date(2025, 1, 1, 10, 0, 0, 0, time.UTC),
+					},
+				},
+			},
+		},
+	}
+
+	memfs := fstest.MapFS{
+		// Note: deleted.go is NOT in the filesystem
+	}
+
+	opts := SerializeOptions{FS: memfs}
+	err := Serialize(pr, opts)
+	require.NoError(t, err)
+
+	// File should be created with outdated section
+	deletedFile, ok := memfs["deleted.go"]
+	require.True(t, ok, "deleted.go should be created")
+
+	content := string(deletedFile.Data)
+	assert.Contains(t, content, "━━━━━━━━━ outdated comments")
+	assert.Contains(t, content, "Comment on deleted file")
+	assert.Contains(t, content, "origline 10")
+}
+
+func TestLeftSideCommentsAreOutdated(t *testing.T) {
+	// Test that LEFT side comments (on old/deleted code) are treated as outdated
+	pr := &PullRequest{
+		ID:         "PR_test",
+		Number:     1,
+		HeadRefOID: "abcd1234",
+		ReviewThreads: []ReviewThread{
+			{
+				ID:           "PRRT_right",
+				Path:         "file.go",
+				DiffSide:     DiffSideRight,
+				Line:         2,
+				OriginalLine: 2,
+				SubjectType:  SubjectTypeLine,
+				Comments: []ReviewComment{
+					{
+						ID:        "PRRC_right",
+						Author:    Actor{Login: "alice"},
+						Body:      "Comment on new code",
+						CreatedAt: time.Date(2025, 1, 1, 10, 0, 0, 0, time.UTC),
+					},
+				},
+			},
+			{
+				ID:           "PRRT_left",
+				Path:         "file.go",
+				DiffSide:     DiffSideLeft, // LEFT = old/deleted code
+				Line:         5,
+				OriginalLine: 5,
+				SubjectType:  SubjectTypeLine,
+				Comments: []ReviewComment{
+					{
+						ID:        "PRRC_left",
+						Author:    Actor{Login: "bob"},
+						Body:      "Comment on deleted code",
+						CreatedAt: time.Date(2025, 1, 1, 11, 0, 0, 0, time.UTC),
+					},
+				},
+			},
+		},
+	}
+
+	memfs := fstest.MapFS{
+		"file.go": &fstest.MapFile{
+			Data: []byte("line 1\nline 2\nline 3\n"),
+		},
+	}
+
+	opts := SerializeOptions{FS: memfs}
+	err := Serialize(pr, opts)
+	require.NoError(t, err)
+
+	content := string(memfs["file.go"].Data)
+
+	// RIGHT side comment should be inline after line 2
+	assert.Contains(t, content, "Comment on new code")
+
+	// LEFT side comment should be in outdated section at end
+	assert.Contains(t, content, "━━━━━━━━━ outdated comments")
+	assert.Contains(t, content, "Comment on deleted code")
+
+	// Verify the inline comment comes before the outdated section
+	rightIdx := strings.Index(content, "Comment on new code")
+	outdatedIdx := strings.Index(content, "━━━━━━━━━ outdated comments")
+	assert.True(t, rightIdx < outdatedIdx, "inline comment should come before outdated section")
+}
+
+func TestMultipleThreadsDifferentLines(t *testing.T) {
+	// Test multiple threads on different lines of the same file.
+	// This would catch the bug where map iteration order caused comments
+	// to be inserted at wrong positions when processing wasn't done in
+	// descending line order.
+	pr := &PullRequest{
+		ID:         "PR_test",
+		Number:     1,
+		HeadRefOID: "abcd1234",
+		ReviewThreads: []ReviewThread{
+			{
+				ID:          "PRRT_1",
+				Path:        "code.go",
+				DiffSide:    DiffSideRight,
+				Line:        2,
+				SubjectType: SubjectTypeLine,
+				Comments: []ReviewComment{
+					{
+						ID:        "PRRC_1",
+						Author:    Actor{Login: "alice"},
+						Body:      "Comment on line 2",
+						CreatedAt: time.Date(2025, 1, 1, 10, 0, 0, 0, time.UTC),
+					},
+				},
+			},
+			{
+				ID:          "PRRT_2",
+				Path:        "code.go",
+				DiffSide:    DiffSideRight,
+				Line:        5,
+				SubjectType: SubjectTypeLine,
+				Comments: []ReviewComment{
+					{
+						ID:        "PRRC_2",
+						Author:    Actor{Login: "bob"},
+						Body:      "Comment on line 5",
+						CreatedAt: time.Date(2025, 1, 1, 11, 0, 0, 0, time.UTC),
+					},
+				},
+			},
+			{
+				ID:          "PRRT_3",
+				Path:        "code.go",
+				DiffSide:    DiffSideRight,
+				Line:        8,
+				SubjectType: SubjectTypeLine,
+				Comments: []ReviewComment{
+					{
+						ID:        "PRRC_3",
+						Author:    Actor{Login: "carol"},
+						Body:      "Comment on line 8",
+						CreatedAt: time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC),
+					},
+				},
+			},
+			{
+				ID:          "PRRT_4",
+				Path:        "code.go",
+				DiffSide:    DiffSideRight,
+				Line:        10,
+				SubjectType: SubjectTypeLine,
+				Comments: []ReviewComment{
+					{
+						ID:        "PRRC_4",
+						Author:    Actor{Login: "dave"},
+						Body:      "Comment on line 10",
+						CreatedAt: time.Date(2025, 1, 1, 13, 0, 0, 0, time.UTC),
+					},
+				},
+			},
+		},
+	}
+
+	memfs := fstest.MapFS{
+		"code.go": &fstest.MapFile{
+			Data: []byte("line 1\nline 2\nline 3\nline 4\nline 5\nline 6\nline 7\nline 8\nline 9\nline 10\nline 11\n"),
+		},
+	}
+
+	opts := SerializeOptions{FS: memfs}
+	err := Serialize(pr, opts)
+	require.NoError(t, err)
+
+	// Deserialize and verify line numbers are correct
+	pr2, err := Deserialize(opts)
+	require.NoError(t, err)
+
+	require.Len(t, pr2.ReviewThreads, 4)
+
+	// Create a map of comment body to line number for verification
+	lineByBody := make(map[string]int)
+	for _, thread := range pr2.ReviewThreads {
+		if len(thread.Comments) > 0 {
+			lineByBody[thread.Comments[0].Body] = thread.Line
+		}
+	}
+
+	// Verify each comment is on the correct line
+	assert.Equal(t, 2, lineByBody["Comment on line 2"], "Comment should be on line 2")
+	assert.Equal(t, 5, lineByBody["Comment on line 5"], "Comment should be on line 5")
+	assert.Equal(t, 8, lineByBody["Comment on line 8"], "Comment should be on line 8")
+	assert.Equal(t, 10, lineByBody["Comment on line 10"], "Comment should be on line 10")
+}
+
+func TestPreservesTrailingNewline(t *testing.T) {
+	// File with trailing newline
+	withNewline := "line 1\nline 2\n"
+	// File without trailing newline
+	withoutNewline := "line 1\nline 2"
+
+	pr := &PullRequest{
+		ID:         "PR_test",
+		Number:     1,
+		HeadRefOID: "1234",
+		ReviewThreads: []ReviewThread{
+			{
+				Path:        "with.go",
+				DiffSide:    DiffSideRight,
+				Line:        1,
+				SubjectType: SubjectTypeLine,
+				Comments: []ReviewComment{
+					{ID: "PRRC_1", Author: Actor{Login: "a"}, Body: "comment"},
+				},
+			},
+			{
+				Path:        "without.go",
+				DiffSide:    DiffSideRight,
+				Line:        1,
+				SubjectType: SubjectTypeLine,
+				Comments: []ReviewComment{
+					{ID: "PRRC_2", Author: Actor{Login: "b"}, Body: "comment"},
+				},
+			},
+		},
+	}
+
+	memfs := fstest.MapFS{
+		"with.go":    &fstest.MapFile{Data: []byte(withNewline)},
+		"without.go": &fstest.MapFile{Data: []byte(withoutNewline)},
+	}
+
+	opts := SerializeOptions{FS: memfs}
+	err := Serialize(pr, opts)
+	require.NoError(t, err)
+
+	// Check that trailing newline status is preserved
+	withData := string(memfs["with.go"].Data)
+	withoutData := string(memfs["without.go"].Data)
+
+	assert.True(t, withData[len(withData)-1] == '\n', "should preserve trailing newline")
+	assert.True(t, withoutData[len(withoutData)-1] != '\n', "should preserve no trailing newline")
+}
+
+func TestWrapWidth(t *testing.T) {
+	body := strings.Repeat("word ", 30)
+	pr := &PullRequest{
+		ID:         "PR_test",
+		Number:     1,
+		HeadRefOID: "abcd1234",
+		ReviewThreads: []ReviewThread{
+			{
+				Path:        "a.go",
+				DiffSide:    DiffSideRight,
+				Line:        1,
+				SubjectType: SubjectTypeLine,
+				Comments:    []ReviewComment{{ID: "PRRC_1", Author: Actor{Login: "a"}, Body: body}},
+			},
+			{
+				Path:        "sub/b.go",
+				DiffSide:    DiffSideRight,
+				Line:        1,
+				SubjectType: SubjectTypeLine,
+				Comments:    []ReviewComment{{ID: "PRRC_2", Author: Actor{Login: "b"}, Body: body}},
+			},
+		},
+	}
+
+	maxWidth := func(data []byte) int {
+		w := 0
+		for _, line := range strings.Split(string(data), "\n") {
+			if strings.Contains(line, "word") {
+				w = max(w, TextWidth(line))
+			}
+		}
+		return w
+	}
+
+	memfs := fstest.MapFS{
+		"a.go":     &fstest.MapFile{Data: []byte("code\n")},
+		"sub/b.go": &fstest.MapFile{Data: []byte("code\n")},
+		".editorconfig": &fstest.MapFile{Data: []byte(
+			"root = true\n\n[*]\nmax_line_length = off\n\n[sub/*.{go,py}]\nmax_line_length = 60\n")},
+	}
+	opts := SerializeOptions{FS: memfs, WrapWidth: 40, EditorConfig: true}
+	require.NoError(t, Serialize(pr, opts))
+
+	assert.LessOrEqual(t, maxWidth(memfs["a.go"].Data), 40)
+	assert.LessOrEqual(t, maxWidth(memfs["sub/b.go"].Data), 60)
+	assert.Greater(t, maxWidth(memfs["sub/b.go"].Data), 40)
+
+	pr2, err := Deserialize(opts)
+	require.NoError(t, err)
+	require.Len(t, pr2.ReviewThreads, 2)
+	for _, thread := range pr2.ReviewThreads {
+		assert.Equal(t, strings.TrimSpace(body), thread.Comments[0].Body)
+	}
+}
+
+func TestNoReflowRoundTrip(t *testing.T) {
+	body := "* item one\n+ item two\n\n__bold__ and a hard break  \nnext line " + strings.Repeat("long ", 30) + "\n\n    indented code"
+	pr := &PullRequest{
+		ID:         "PR_test",
+		Number:     1,
+		HeadRefOID: "abcd1234",
+		ReviewThreads: []ReviewThread{
+			{
+				Path:        "file.go",
+				DiffSide:    DiffSideRight,
+				Line:        1,
+				SubjectType: SubjectTypeLine,
+				Comments:    []ReviewComment{{ID: "PRRC_1", Author: Actor{Login: "alice"}, Body: body}},
+			},
+		},
+		IssueComments: []IssueComment{
+			{ID: "IC_1", Author: Actor{Login: "bob"}, Body: body},
+		},
+	}
+
+	memfs := fstest.MapFS{
+		"file.go": &fstest.MapFile{Data: []byte("\tcode here\n")},
+	}
+
+	opts := SerializeOptions{FS: memfs, NoReflow: true}
+	require.NoError(t, Serialize(pr, opts))
+	assert.Contains(t, string(memfs["file.go"].Data), "─ verbatim ─")
+
+	// Deserializing doesn't depend on the option; the header marks the body.
+	pr2, err := Deserialize(SerializeOptions{FS: memfs})
+	require.NoError(t, err)
+
+	require.Len(t, pr2.ReviewThreads, 1)
+	assert.Equal(t, body, pr2.ReviewThreads[0].Comments[0].Body)
+	require.Len(t, pr2.IssueComments, 1)
+	assert.Equal(t, body, pr2.IssueComments[0].Body)
+}
+
+func TestUnmodifiedCommentKeepsOriginalBody(t *testing.T) {
+	// Wrapping and unwrapping normalizes list markers and emphasis
+	original := "* one\n* two\n\n__bold__ text"
+	pr := &PullRequest{
+		ID:         "PR_test",
+		Number:     1,
+		HeadRefOID: "abcd1234",
+		ReviewThreads: []ReviewThread{
+			{
+				Path:        "file.go",
+				DiffSide:    DiffSideRight,
+				Line:        1,
+				SubjectType: SubjectTypeLine,
+				Comments: []ReviewComment{
+					{ID: "PRRC_1", Author: Actor{Login: "alice"}, Body: original},
+					{ID: "PRRC_2", Author: Actor{Login: "bob"}, Body: "reply"},
+				},
+			},
+		},
+	}
+
+	memfs := fstest.MapFS{
+		"file.go": &fstest.MapFile{Data: []byte("code here\n")},
+	}
+	opts := SerializeOptions{FS: memfs}
+	require.NoError(t, Serialize(pr, opts))
+
+	// Edit the reply locally
+	data := strings.Replace(string(memfs["file.go"].Data), "║ reply", "║ edited reply", 1)
+	memfs["file.go"].Data = []byte(data)
+
+	opts.Originals = map[string]string{"PRRC_1": original, "PRRC_2": "reply"}
+	pr2, err := Deserialize(opts)
+	require.NoError(t, err)
+
+	require.Len(t, pr2.ReviewThreads, 1)
+	comments := pr2.ReviewThreads[0].Comments
+	require.Len(t, comments, 2)
+	assert.Equal(t, original, comments[0].Body)
+	assert.False(t, comments[0].IsModified)
+	assert.Equal(t, "edited reply", comments[1].Body)
+	assert.True(t, comments[1].IsModified)
+}
+
+func TestASCIIMarkers(t *testing.T) {
+	pr := &PullRequest{
+		ID:         "PR_test",
+		Number:     1,
+		HeadRefOID: "abcd1234",
+		ReviewThreads: []ReviewThread{
+			{
+				Path:        "file.go",
+				DiffSide:    DiffSideRight,
+				Line:        2,
+				SubjectType: SubjectTypeLine,
+				Comments: []ReviewComment{
+					{ID: "PRRC_1", Author: Actor{Login: "alice"}, Body: "First\n\nSecond", CreatedAt: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)},
+					{ID: "PRRC_2", Author: Actor{Login: "bob"}, Body: "Reply", CreatedAt: time.Date(2025, 1, 1, 1, 0, 0, 0, time.UTC)},
+				},
+			},
+		},
+	}
+
+	// An ordinary comment that looks like an ASCII body line must survive
+	source := "// | a | b |\nx := 1\n"
+	memfs := fstest.MapFS{
+		"file.go": &fstest.MapFile{Data: []byte(source)},
+	}
+
+	opts := SerializeOptions{FS: memfs, ASCII: true}
+	require.NoError(t, Serialize(pr, opts))
+	assert.Equal(t, "// | a | b |\n"+
+		"x := 1\n"+
+		"// |>----- @alice - at 2025-01-01 00:00 - sum 519b924a - v2 - prrc 1\n"+
+		"// | First\n"+
+		"// |\n"+
+		"// | Second\n"+
+		"// |+----- @bob - at 2025-01-01 01:00 - sum c253f451 - v2 - prrc 2\n"+
+		"// | Reply\n", string(memfs["file.go"].Data))
+
+	pr2, err := Deserialize(opts)
+	require.NoError(t, err)
+	require.Len(t, pr2.ReviewThreads, 1)
+	assert.Equal(t, 2, pr2.ReviewThreads[0].Line)
+	require.Len(t, pr2.ReviewThreads[0].Comments, 2)
+	assert.Equal(t, "First\n\nSecond", pr2.ReviewThreads[0].Comments[0].Body)
+	assert.Equal(t, "Reply", pr2.ReviewThreads[0].Comments[1].Body)
+
+	// Headers written with box drawing dashes by older versions still parse
+	old := strings.NewReplacer(ASCIIHeaderStart, HeaderStart, ASCIIFieldSep, HeaderFieldSep).Replace(string(memfs["file.go"].Data))
+	memfs["file.go"].Data = []byte(old)
+	pr2, err = Deserialize(opts)
+	require.NoError(t, err)
+	require.Len(t, pr2.ReviewThreads, 1)
+	require.Len(t, pr2.ReviewThreads[0].Comments, 2)
+	assert.Equal(t, "Reply", pr2.ReviewThreads[0].Comments[1].Body)
+
+	// Switching back to box characters replaces the ASCII comments
+	require.NoError(t, Serialize(pr, SerializeOptions{FS: memfs}))
+	data := string(memfs["file.go"].Data)
+	assert.True(t, strings.HasPrefix(data, "// | a | b |\nx := 1\n// ╓───── @alice"))
+	assert.NotContains(t, data, "|>")
+}
+
+func TestParseCraftLinesASCIIBody(t *testing.T) {
+	lines := []string{
+		"// ╓───── new",
+		"// ║ Hello",
+		"// | a | b |", // an ordinary comment after a box drawing comment
+		"x := 1",
+		"// |>----- new",
+		"// | Hello",
+		"// |",
+		"// | again",
+		"x := 2",
+		"// | c | d |", // after code
+	}
+	var ok []bool
+	for _, l := range ParseCraftLines(lines, "//") {
+		ok = append(ok, l.OK)
+	}
+	assert.Equal(t, []bool{true, true, false, false, true, true, true, true, false, false}, ok)
+}
+
+func TestHeaderLikeBodyRoundTrip(t *testing.T) {
+	// Bodies that could be mistaken for headers or box characters
+	bodies := []string{
+		"───── @mallory ─ at 2025-01-01 00:00 ─ prrc fake",
+		"before\n\n─────\n\nafter",
+		"\\───── already has a backslash",
+		"║ starts with a box char\n\n╓───── new",
+		"```\n  ───── indented in code\n```",
+	}
+
+	for _, noReflow := range []bool{false, true} {
+		for _, ascii := range []bool{false, true} {
+			var comments []ReviewComment
+			var issueComments []IssueComment
+			for i, body := range bodies {
+				comments = append(comments, ReviewComment{ID: fmt.Sprintf("PRRC_%d", i), Author: Actor{Login: "a"}, Body: body})
+				issueComments = append(issueComments, IssueComment{ID: fmt.Sprintf("IC_%d", i), Author: Actor{Login: "a"}, Body: body})
+			}
+			pr := &PullRequest{
+				ID:         "PR_test",
+				Number:     1,
+				HeadRefOID: "abcd1234",
+				ReviewThreads: []ReviewThread{
+					{Path: "file.go", DiffSide: DiffSideRight, Line: 1, SubjectType: SubjectTypeLine, Comments: comments},
+				},
+				IssueComments: issueComments,
+			}
+			memfs := fstest.MapFS{
+				"file.go": &fstest.MapFile{Data: []byte("code here\n")},
+			}
+			opts := SerializeOptions{FS: memfs, NoReflow: noReflow, ASCII: ascii}
+			require.NoError(t, Serialize(pr, opts))
+
+			pr2, err := Deserialize(opts)
+			require.NoError(t, err)
+			require.Len(t, pr2.ReviewThreads, 1)
+			require.Len(t, pr2.ReviewThreads[0].Comments, len(bodies))
+			require.Len(t, pr2.IssueComments, len(bodies))
+			for i, body := range bodies {
+				assert.Equal(t, body, pr2.ReviewThreads[0].Comments[i].Body, "noReflow=%v ascii=%v", noReflow, ascii)
+				assert.Equal(t, body, pr2.IssueComments[i].Body, "noReflow=%v ascii=%v", noReflow, ascii)
+			}
+		}
+	}
+}
+
+func TestHeaderAdversarialValues(t *testing.T) {
+	authors := []string{
+		"plain",
+		"with ─ separator",
+		"─────",
+		`"quoted"`,
+		`back\slash`,
+		"new",
+		"prrc kwDOfake",
+		"line\nbreak",
+		" padded ",
+		"number 7 ─ head beef",
+		"with - separator",
+		"-----",
+	}
+
+	for _, author := range authors {
+		t.Run(author, func(t *testing.T) {
+			h := Header{
+				Author:    author,
+				Timestamp: time.Date(2025, 1, 15, 12, 34, 0, 0, time.UTC),
+				NodeID:    "PRRC_kwDOPgi5ks6ZBMOo",
+				Range:     -3,
+			}
+			formatted := FormatHeader(h)
+			assert.NotContains(t, formatted, "\n")
+			parsed, ok := ParseHeader(formatted)
+			require.True(t, ok)
+			assert.Equal(t, h.Author, parsed.Author)
+			assert.Equal(t, h.NodeID, parsed.NodeID)
+			assert.Equal(t, h.Range, parsed.Range)
+			assert.False(t, parsed.IsNew)
+
+			// Written with ASCII markers
+			line := ParseCraftLines([]string{FormatCraftLine("//", ASCIIThread, formatted)}, "//")[0]
+			require.True(t, line.OK)
+			parsed, ok = ParseHeader(line.Content)
+			require.True(t, ok)
+			assert.Equal(t, h.Author, parsed.Author)
+			assert.Equal(t, h.Range, parsed.Range)
+
+			// PR metadata header in PR-STATE.txt
+			pr := &PullRequest{
+				ID:         "PR_kwDOPgi5ks6k-agY",
+				Number:     42,
+				Author:     Actor{Login: author},
+				HeadRefOID: "abc123",
+				BaseRefOID: "def456",
+			}
+			memfs := fstest.MapFS{}
+			opts := SerializeOptions{FS: memfs}
+			require.NoError(t, Serialize(pr, opts))
+			pr2, err := Deserialize(opts)
+			require.NoError(t, err)
+			assert.Equal(t, author, pr2.Author.Login)
+			assert.Equal(t, 42, pr2.Number)
+			assert.Equal(t, "abc123", pr2.HeadRefOID)
+			assert.Equal(t, "def456", pr2.BaseRefOID)
+			assert.Equal(t, pr.ID, pr2.ID)
+		})
+	}
+}
+
+func TestHeaderUnknownFieldsPreserved(t *testing.T) {
+	// Written by a hypothetical newer craft
+	fileContent := "x := 1\n" +
+		"// ╓───── @alice ─ at 2025-01-15 12:34 ─ color blue ─ pinned ─ v3 ─ prrc kwDOPgi5ks6AAA111\n" +
+		"// ║ Hello\n"
+	prState := "───── pr ─ number 1 ─ pr kwDOPgi5ks6k-agY ─ head abc123 ─ mode fancy ─ v3\n\n" +
+		"───── @bob ─ at 2025-01-15 13:00 ─ label x ─ ic kwDOPgi5ks1234567\nHi\n\n"
+	memfs := fstest.MapFS{
+		"main.go":   &fstest.MapFile{Data: []byte(fileContent)},
+		PRStateFile: &fstest.MapFile{Data: []byte(prState)},
+	}
+	opts := SerializeOptions{FS: memfs}
+
+	pr, err := Deserialize(opts)
+	require.NoError(t, err)
+	require.Len(t, pr.ReviewThreads, 1)
+	assert.Equal(t, []string{"color blue", "pinned", "v3"}, pr.ReviewThreads[0].Comments[0].HeaderExtra)
+	require.Len(t, pr.IssueComments, 1)
+	assert.Equal(t, []string{"label x"}, pr.IssueComments[0].HeaderExtra)
+	assert.Equal(t, []string{"mode fancy", "v3"}, pr.HeaderExtra)
+	assert.Equal(t, 1, pr.Number)
+	assert.Equal(t, "abc123", pr.HeadRefOID)
+
+	// Known fields are still understood
+	h, ok := ParseHeader("───── @alice ─ future thing ─ v3 ─ resolved ─ prrc kwDOx")
+	require.True(t, ok)
+	assert.Equal(t, 3, h.Version)
+	assert.True(t, h.IsResolved)
+	assert.Equal(t, "PRRC_kwDOx", h.NodeID)
+
+	// Simulate send: fresh data from GitHub, extras carried over
+	fetched := *pr
+	fetched.HeaderExtra = nil
+	fetched.ReviewThreads = []ReviewThread{{
+		Path: "main.go", Line: 1, DiffSide: DiffSideRight, SubjectType: SubjectTypeLine,
+		Comments: []ReviewComment{{
+			ID:        "PRRC_kwDOPgi5ks6AAA111",
+			Author:    Actor{Login: "alice"},
+			Body:      "Hello",
+			CreatedAt: time.Date(2025, 1, 15, 12, 34, 0, 0, time.UTC),
+		}},
+	}}
+	fetched.IssueComments = []IssueComment{{
+		ID:        "IC_kwDOPgi5ks1234567",
+		Author:    Actor{Login: "bob"},
+		Body:      "Hi",
+		CreatedAt: time.Date(2025, 1, 15, 13, 0, 0, 0, time.UTC),
+	}}
+	fetched.CopyHeaderExtras(pr)
+	require.NoError(t, Serialize(&fetched, opts))
+
+	data := string(memfs["main.go"].Data)
+	assert.Contains(t, data, "─ color blue ─ pinned ─ v3 ─ prrc kwDOPgi5ks6AAA111\n")
+	assert.NotContains(t, data, "v2")
+	state := string(memfs[PRStateFile].Data)
+	assert.Contains(t, state, "─ head abc123 ─ mode fancy ─ v3\n")
+	assert.Contains(t, state, "─ label x ─ v2 ─ ic kwDOPgi5ks1234567\n")
+
+	// And they survive another round trip
+	pr2, err := Deserialize(opts)
+	require.NoError(t, err)
+	assert.Equal(t, pr.ReviewThreads[0].Comments[0].HeaderExtra, pr2.ReviewThreads[0].Comments[0].HeaderExtra)
+	assert.Equal(t, pr.HeaderExtra, pr2.HeaderExtra)
+}
+
+func TestDeserializeParseErrors(t *testing.T) {
+	fileContent := "package main\n" +
+		"// ║ stray body line\n" +
+		"func main() {\n" +
+		"\t// ╓───── @alice ─ at 2025-01-15 12:34 ─ prrc kwDOPgi5ks6AAA111\n" +
+		"\t// ║ Good comment\n" +
+		"\t// ╟──── new\n" +
+		"\t// ║ reply with a broken header\n" +
+		"}\n"
+	memfs := fstest.MapFS{
+		"main.go":   &fstest.MapFile{Data: []byte(fileContent)},
+		PRStateFile: &fstest.MapFile{Data: []byte("───── pr ─ number 1 ─ pr kwDOPgi5ks6k-agY ─ head abc123\n")},
+	}
+
+	pr, err := Deserialize(SerializeOptions{FS: memfs})
+	var parseErrs ParseErrors
+	require.ErrorAs(t, err, &parseErrs)
+	assert.Equal(t, ParseErrors{
+		{Path: "main.go", Line: 2, Msg: "comment body line without a header"},
+		{Path: "main.go", Line: 6, Msg: "╟ without a valid header"},
+	}, parseErrs)
+	assert.Contains(t, err.Error(), "main.go:6: ╟ without a valid header")
+
+	// Everything else is still returned
+	require.NotNil(t, pr)
+	assert.Equal(t, 1, pr.Number)
+	require.Len(t, pr.ReviewThreads, 1)
+	require.Len(t, pr.ReviewThreads[0].Comments, 1)
+	assert.Equal(t, "Good comment", pr.ReviewThreads[0].Comments[0].Body)
+}
+
+func TestParallelSerialize(t *testing.T) {
+	memfs := fstest.MapFS{}
+	pr := &PullRequest{ID: "PR_kwDOPgi5ks6k-agY", HeadRefOID: "abc123"}
+	for i := range 50 {
+		path := fmt.Sprintf("pkg%d/file.go", i)
+		memfs[path] = &fstest.MapFile{Data: []byte("package pkg\n\nfunc f() {}\n")}
+		pr.ReviewThreads = append(pr.ReviewThreads, ReviewThread{
+			Path: path, Line: 3, DiffSide: DiffSideRight, SubjectType: SubjectTypeLine,
+			Comments: []ReviewComment{{IsNew: true, Body: fmt.Sprintf("Comment %d", i)}},
+		})
+	}
+	opts := SerializeOptions{FS: memfs}
+	require.NoError(t, Serialize(pr, opts))
+
+	pr2, err := Deserialize(opts)
+	require.NoError(t, err)
+	require.Len(t, pr2.ReviewThreads, 50)
+	// Results are in file order regardless of which finished first
+	for i, thread := range pr2.ReviewThreads {
+		assert.True(t, i == 0 || pr2.ReviewThreads[i-1].Path < thread.Path)
+	}
+
+	// Errors from every file are reported
+	pr.ReviewThreads[0].Path = "pkg1" // directories
+	pr.ReviewThreads[1].Path = "pkg2"
+	err = Serialize(pr, opts)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "serializing pkg1:")
+	assert.Contains(t, err.Error(), "serializing pkg2:")
+}
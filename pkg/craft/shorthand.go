@@ -0,0 +1,76 @@
+package craft
+
+import "strings"
+
+// Shorthand lets a comment be typed without its header: a code comment
+// starting with ++, as in
+//
+//	//++ Does this need a lock?
+//
+// under a code line starts a new thread there, and under a thread replies to
+// it. Consecutive shorthand lines are one comment. 'craft fmt' expands them
+// into craft comments, and so does reading the files, so send takes them as
+// they are.
+
+const shorthandMarker = "++"
+
+// ShorthandText returns the text of line if it's a shorthand line in a file
+// whose comments start with linePrefix. Files with no comment syntax have no
+// shorthand.
+func ShorthandText(line, linePrefix string) (string, bool) {
+	if linePrefix == "" {
+		return "", false
+	}
+	rest, ok := strings.CutPrefix(strings.TrimSpace(line), linePrefix+shorthandMarker)
+	if !ok || (rest != "" && rest[0] != ' ' && rest[0] != '\t') {
+		return "", false
+	}
+	return strings.TrimSpace(rest), true
+}
+
+// IsShorthandLine reports whether line is a shorthand line in a file with
+// style.
+func IsShorthandLine(line string, style CommentStyle) bool {
+	_, ok := ShorthandText(line, style.LinePrefix)
+	return ok
+}
+
+// ExpandShorthand replaces the shorthand lines in lines with craft comments:
+// a new thread, or a reply after a craft line. It returns the lines and the
+// number of comments expanded.
+func ExpandShorthand(lines []string, linePrefix string) ([]string, int) {
+	if linePrefix == "" {
+		return lines, 0
+	}
+	parsed := ParseCraftLines(lines, linePrefix)
+	var result []string
+	var count int
+	for i := 0; i < len(lines); i++ {
+		text, ok := ShorthandText(lines[i], linePrefix)
+		if !ok {
+			result = append(result, lines[i])
+			continue
+		}
+		count++
+		indent := GetIndent(lines[i])
+		headerBox, bodyBox := BoxThread, BoxBody
+		if prev := parsed[max(i-1, 0)]; i > 0 && prev.OK && prev.Box != BoxStart && prev.Box != BoxChange {
+			headerBox = BoxReply
+			if prev.ASCII {
+				headerBox, bodyBox = ASCIIReply, ASCIIBody
+			}
+		}
+		result = append(result, indent+FormatCraftLine(linePrefix, headerBox, HeaderStart+" new"))
+		for {
+			result = append(result, indent+FormatCraftLine(linePrefix, bodyBox, text))
+			if i+1 == len(lines) {
+				break
+			}
+			if text, ok = ShorthandText(lines[i+1], linePrefix); !ok {
+				break
+			}
+			i++
+		}
+	}
+	return result, count
+}
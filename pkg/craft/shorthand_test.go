@@ -0,0 +1,77 @@
+package craft
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShorthandText(t *testing.T) {
+	tests := []struct {
+		line   string
+		prefix string
+		text   string
+		ok     bool
+	}{
+		{"//++ Why?", "//", "Why?", true},
+		{"\t//++   spaced  ", "//", "spaced", true},
+		{"//++", "//", "", true},
+		{"# ++ not shorthand", "#", "", false},
+		{"#++ Why?", "#", "Why?", true},
+		{"//+++ Why?", "//", "", false},
+		{"x++ // i++", "//", "", false},
+		{"++ text", "", "", false},
+	}
+	for _, tt := range tests {
+		text, ok := ShorthandText(tt.line, tt.prefix)
+		assert.Equal(t, tt.ok, ok, "line: %q", tt.line)
+		assert.Equal(t, tt.text, text, "line: %q", tt.line)
+	}
+}
+
+func TestExpandShorthand(t *testing.T) {
+	input := "func f() {\n" +
+		"\tx := 1\n" +
+		"\t//++ Why one?\n" +
+		"\t//++ Two would do.\n" +
+		"\ty := 2\n" +
+		"\t// ╓───── @alice ─ at 2025-01-15 12:34 ─ prrc kwDOPgi5ks6AAA111\n" +
+		"\t// ║ Rename y\n" +
+		"\t//++ Done\n" +
+		"}\n"
+	lines, n := ExpandShorthand(strings.Split(input, "\n"), "//")
+	assert.Equal(t, 2, n)
+	assert.Equal(t, "func f() {\n"+
+		"\tx := 1\n"+
+		"\t// ╓───── new\n"+
+		"\t// ║ Why one?\n"+
+		"\t// ║ Two would do.\n"+
+		"\ty := 2\n"+
+		"\t// ╓───── @alice ─ at 2025-01-15 12:34 ─ prrc kwDOPgi5ks6AAA111\n"+
+		"\t// ║ Rename y\n"+
+		"\t// ╟───── new\n"+
+		"\t// ║ Done\n"+
+		"}\n", strings.Join(lines, "\n"))
+
+	// A reply keeps the thread's alphabet
+	lines, _ = ExpandShorthand([]string{"x", "// |>───── @alice", "// | Hi", "//++ Hello"}, "//")
+	assert.Equal(t, []string{"x", "// |>───── @alice", "// | Hi", "// |+----- new", "// | Hello"}, lines)
+}
+
+func TestDeserializeShorthand(t *testing.T) {
+	memfs := fstest.MapFS{
+		"main.go": &fstest.MapFile{Data: []byte("line 1\n//++ Look here\nline 2\n")},
+	}
+	threads, err := DeserializeFileComments(SerializeOptions{FS: memfs}, "main.go")
+	require.NoError(t, err)
+	require.Len(t, threads, 1)
+	thread := threads[0]
+	assert.Equal(t, "main.go", thread.Path)
+	assert.Equal(t, 1, thread.Line)
+	require.Len(t, thread.Comments, 1)
+	assert.True(t, thread.Comments[0].IsNew)
+	assert.Equal(t, "Look here", thread.Comments[0].Body)
+}
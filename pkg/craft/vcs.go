@@ -0,0 +1,79 @@
+package craft
+
+// VCS abstracts version control operations for git, jj and Sapling; the
+// craft command has the implementations.
+type VCS interface {
+	// Name returns "git", "jj" or "sl"
+	Name() string
+
+	// Root returns the repository root directory
+	Root() string
+
+	// HasUncommittedChanges returns true if there are uncommitted changes
+	HasUncommittedChanges() (bool, error)
+
+	// GetUncommittedFiles returns the files with uncommitted changes,
+	// including new files that aren't ignored
+	GetUncommittedFiles() ([]string, error)
+
+	// RevertFiles discards the uncommitted changes to paths, deleting the
+	// ones that are new
+	RevertFiles(paths []string) error
+
+	// CurrentCommit returns the commit the working copy is on (the parent of
+	// the working copy change in jj)
+	CurrentCommit() (string, error)
+
+	// FetchPRBranch fetches the PR branch from the remote
+	FetchPRBranch(remote string, prNumber int) error
+
+	// CreateAndSwitchBranch creates a local branch for the PR and switches to it.
+	// If the branch exists, it resets it to the fetched PR head.
+	CreateAndSwitchBranch(prNumber int, commitOID string) error
+
+	// Commit creates a commit with the given message.
+	// In jj, this creates a new change on top of the current one.
+	Commit(message string, opts CommitOptions) error
+
+	// GetRemoteURL returns the URL of the given remote
+	GetRemoteURL(remote string) (string, error)
+
+	// GetCurrentBranch returns the current branch name (or bookmark in jj)
+	GetCurrentBranch() (string, error)
+
+	// GetConfigValue returns a git/jj config value
+	GetConfigValue(key string) (string, error)
+
+	// SetConfigValue sets a config value for this repository
+	SetConfigValue(key, value string) error
+
+	// GetModifiedFiles returns files modified between commit and HEAD/current
+	GetModifiedFiles(commit string) ([]string, error)
+
+	// GetChangedFiles returns files changed between commit and the working
+	// copy, including uncommitted changes
+	GetChangedFiles(commit string) ([]string, error)
+
+	// GetRenamedFiles returns files renamed between commit and HEAD/current,
+	// mapping each old path to its new one
+	GetRenamedFiles(commit string) (map[string]string, error)
+
+	// GetFileDiff returns unified diff for a file between commit and HEAD/current
+	GetFileDiff(commit, path string) (string, error)
+
+	// GetFileAtCommit returns file content at a specific commit
+	GetFileAtCommit(commit, path string) (string, error)
+
+	// MergeBase returns the best common ancestor of two commits
+	MergeBase(a, b string) (string, error)
+
+	// ListFiles returns all tracked files in the repository
+	ListFiles() ([]string, error)
+}
+
+// CommitOptions are how VCS.Commit makes a commit.
+type CommitOptions struct {
+	Sign     bool // Sign it, whether or not the VCS is configured to
+	NoSign   bool // Don't sign it, even if the VCS is configured to
+	NoVerify bool // Skip the commit hooks (git's pre-commit and commit-msg)
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/dnr/craft/pkg/craft"
+)
+
+// SpellConfig configures the spell check of new comments in send --dry-run.
+type SpellConfig struct {
+	// Dictionary is a word list, one word per line, such as a hunspell .dic
+	// file (a leading count line and /flags are ignored). A relative path
+	// is relative to the repo root. Without one, there's no spell check.
+	Dictionary string `yaml:"dictionary"`
+
+	// Words are more words to accept, such as project names.
+	Words []string `yaml:"words"`
+}
+
+// spellChecker checks words against a dictionary.
+type spellChecker struct {
+	words map[string]bool // lowercase
+}
+
+// loadSpellChecker reads the dictionary in cfg, or returns nil if there
+// isn't one.
+func loadSpellChecker(fsys fs.FS, cfg SpellConfig) (*spellChecker, error) {
+	if cfg.Dictionary == "" {
+		return nil, nil
+	}
+	var data []byte
+	var err error
+	if filepath.IsAbs(cfg.Dictionary) {
+		data, err = os.ReadFile(cfg.Dictionary)
+	} else {
+		data, err = craft.ReadFile(fsys, filepath.ToSlash(cfg.Dictionary))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading spell dictionary: %w", err)
+	}
+
+	sc := &spellChecker{words: make(map[string]bool)}
+	for _, line := range strings.Split(string(data), "\n") {
+		word, _, _ := strings.Cut(strings.TrimSpace(line), "/")
+		if word != "" {
+			sc.words[strings.ToLower(word)] = true
+		}
+	}
+	for _, word := range cfg.Words {
+		sc.words[strings.ToLower(word)] = true
+	}
+	return sc, nil
+}
+
+var (
+	// Parts of a comment that aren't prose: code blocks and spans, links
+	// and @mentions
+	spellSkipRe = regexp.MustCompile("(?s)```.*?```|`[^`\n]*`|https?://\\S+|@[\\w-]+|\\]\\([^)]*\\)")
+	spellWordRe = regexp.MustCompile(`[\p{L}\p{N}_']+`)
+	spellCamel  = regexp.MustCompile(`\p{Ll}\p{Lu}`)
+)
+
+// suffixes whose stems are also looked up, since a word list without
+// hunspell's affix rules has few inflected forms
+var spellSuffixes = []string{"'s", "s", "es", "ed", "d", "ing", "ly", "er", "est"}
+
+// misspellings returns the words in a comment body that aren't in the
+// dictionary, each once. Words that look like code (with digits,
+// underscores or inner capitals) are skipped.
+func (sc *spellChecker) misspellings(body string) []string {
+	body = spellSkipRe.ReplaceAllString(body, " ")
+	var result []string
+	seen := make(map[string]bool)
+	for _, word := range spellWordRe.FindAllString(body, -1) {
+		word = strings.Trim(word, "'")
+		if len([]rune(word)) < 2 || seen[word] ||
+			strings.ContainsAny(word, "0123456789_") || spellCamel.MatchString(word) {
+			continue
+		}
+		seen[word] = true
+		if !sc.known(strings.ToLower(word)) {
+			result = append(result, word)
+		}
+	}
+	return result
+}
+
+func (sc *spellChecker) known(word string) bool {
+	if sc.words[word] {
+		return true
+	}
+	for _, suffix := range spellSuffixes {
+		if stem, ok := strings.CutSuffix(word, suffix); ok && len(stem) > 1 {
+			if sc.words[stem] || sc.words[stem+"e"] {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// spellCheckReview returns the misspellings in each new comment in review.
+func spellCheckReview(sc *spellChecker, review *craft.ReviewToSend) []craft.ParseError {
+	var result []craft.ParseError
+	check := func(path string, line int, body string) {
+		if words := sc.misspellings(body); len(words) > 0 {
+			result = append(result, craft.ParseError{Path: path, Line: line, Msg: "possible typos: " + strings.Join(words, ", ")})
+		}
+	}
+	for _, t := range review.NewThreads {
+		check(t.Path, t.Line, t.Body)
+	}
+	for _, r := range review.Replies {
+		check(r.ThreadPath, r.ThreadLine, r.Body)
+	}
+	if review.Body != "" {
+		check(craft.PRStateFile, 0, review.Body)
+	}
+	return result
+}
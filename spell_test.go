@@ -0,0 +1,33 @@
+package main
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/dnr/craft/pkg/craft"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSpellCheck(t *testing.T) {
+	memfs := fstest.MapFS{"words.dic": &fstest.MapFile{Data: []byte("14\nthis/S\nshould\nuse\na\nmap\nthe\nlookup/S\nrename\nit\nto\nsee\nand\nor\nin\n")}}
+	sc, err := loadSpellChecker(memfs, SpellConfig{Dictionary: "words.dic", Words: []string{"craft"}})
+	require.NoError(t, err)
+
+	assert.Empty(t, sc.misspellings("This should use a map: lookups `forEach(x)` see https://exmaple.com/a and @bob"))
+	assert.Empty(t, sc.misspellings("Rename it to fooBar, or foo_bar, or v2, in craft's lookup:\n\n```go\nmispeled()\n```"))
+	assert.Equal(t, []string{"shuold", "mapp"}, sc.misspellings("This shuold use a mapp, mapp"))
+
+	review := &craft.ReviewToSend{
+		NewThreads: []NewThreadInfo{{Path: "a.go", Line: 3, Body: "Use teh map"}},
+		Replies:    []ReplyInfo{{ThreadPath: "b.go", ThreadLine: 1, Body: "See it"}},
+	}
+	assert.Equal(t, []craft.ParseError{{Path: "a.go", Line: 3, Msg: "possible typos: teh"}}, spellCheckReview(sc, review))
+
+	// No dictionary, no spell check
+	sc, err = loadSpellChecker(memfs, SpellConfig{Words: []string{"craft"}})
+	require.NoError(t, err)
+	assert.Nil(t, sc)
+	_, err = loadSpellChecker(memfs, SpellConfig{Dictionary: "missing.dic"})
+	assert.Error(t, err)
+}
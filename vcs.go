@@ -1,74 +1,65 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
-)
-
-// VCS abstracts version control operations for git and jj.
-type VCS interface {
-	// Name returns "git" or "jj"
-	Name() string
-
-	// Root returns the repository root directory
-	Root() string
-
-	// HasUncommittedChanges returns true if there are uncommitted changes
-	HasUncommittedChanges() (bool, error)
-
-	// FetchPRBranch fetches the PR branch from the remote
-	FetchPRBranch(remote string, prNumber int) error
-
-	// CreateAndSwitchBranch creates a local branch for the PR and switches to it.
-	// If the branch exists, it resets it to the fetched PR head.
-	CreateAndSwitchBranch(prNumber int, commitOID string) error
-
-	// Commit creates a commit with the given message.
-	// In jj, this creates a new change on top of the current one.
-	Commit(message string) error
-
-	// GetRemoteURL returns the URL of the given remote
-	GetRemoteURL(remote string) (string, error)
-
-	// GetCurrentBranch returns the current branch name (or bookmark in jj)
-	GetCurrentBranch() (string, error)
-
-	// GetConfigValue returns a git/jj config value
-	GetConfigValue(key string) (string, error)
+	"time"
 
-	// GetModifiedFiles returns files modified between commit and HEAD/current
-	GetModifiedFiles(commit string) ([]string, error)
-
-	// GetFileDiff returns unified diff for a file between commit and HEAD/current
-	GetFileDiff(commit, path string) (string, error)
-
-	// GetFileAtCommit returns file content at a specific commit
-	GetFileAtCommit(commit, path string) (string, error)
+	"github.com/dnr/craft/pkg/craft"
+)
 
-	// ListFiles returns all tracked files in the repository
-	ListFiles() ([]string, error)
-}
+// VCS lives in pkg/craft, which serializes through it.
+type (
+	VCS           = craft.VCS
+	CommitOptions = craft.CommitOptions
+)
 
-// DetectVCS detects whether the current directory is a git or jj repo.
+// DetectVCS detects whether dir is inside a git, jj or Sapling repo, looking in
+// dir and then each parent for the nearest .jj, .sl or .git. The returned VCS
+// is rooted at the repository root, so commands work from any subdirectory.
 func DetectVCS(dir string) (VCS, error) {
-	// Check for jj first (it can colocate with git)
-	if _, err := os.Stat(filepath.Join(dir, ".jj")); err == nil {
-		return &JJRepo{root: dir}, nil
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	for d := abs; ; d = filepath.Dir(d) {
+		// Check for jj first (it can colocate with git)
+		if _, err := os.Stat(filepath.Join(d, ".jj")); err == nil {
+			return &JJRepo{root: d}, nil
+		}
+		// Sapling's dotgit mode keeps its state in .git/sl; treat that as
+		// Sapling too, since plain git commands don't see its commits
+		if _, err := os.Stat(filepath.Join(d, ".sl")); err == nil {
+			return &SaplingRepo{root: d}, nil
+		}
+		if _, err := os.Stat(filepath.Join(d, ".git", "sl")); err == nil {
+			return &SaplingRepo{root: d}, nil
+		}
+		// .git is a file in worktrees and submodules
+		if _, err := os.Stat(filepath.Join(d, ".git")); err == nil {
+			return &GitRepo{root: d}, nil
+		}
+		if filepath.Dir(d) == d {
+			break
+		}
 	}
 
-	// Check for git
+	// Let git find it (e.g. GIT_DIR set in the environment)
 	cmd := exec.Command("git", "rev-parse", "--show-toplevel")
 	cmd.Dir = dir
 	out, err := cmd.Output()
 	if err == nil {
-		return &GitRepo{root: strings.TrimSpace(string(out))}, nil
+		// git prints C:/... on Windows
+		return &GitRepo{root: filepath.Clean(strings.TrimSpace(string(out)))}, nil
 	}
 
-	return nil, fmt.Errorf("not a git or jj repository")
+	return nil, fmt.Errorf("not a git, jj or Sapling repository")
 }
 
 // GitRepo implements VCS for git repositories.
@@ -80,6 +71,12 @@ func (g *GitRepo) Name() string { return "git" }
 func (g *GitRepo) Root() string { return g.root }
 
 func (g *GitRepo) run(args ...string) (string, error) {
+	out, err := g.runRaw(args...)
+	return strings.TrimSpace(out), err
+}
+
+// runRaw is like run, but returns the output untrimmed.
+func (g *GitRepo) runRaw(args ...string) (string, error) {
 	cmd := exec.Command("git", args...)
 	cmd.Dir = g.root
 	out, err := cmd.Output()
@@ -89,7 +86,7 @@ func (g *GitRepo) run(args ...string) (string, error) {
 		}
 		return "", err
 	}
-	return strings.TrimSpace(string(out)), nil
+	return string(out), nil
 }
 
 func (g *GitRepo) runNoOutput(args ...string) error {
@@ -108,6 +105,35 @@ func (g *GitRepo) HasUncommittedChanges() (bool, error) {
 	return out != "", nil
 }
 
+func (g *GitRepo) GetUncommittedFiles() ([]string, error) {
+	changed, err := g.listPaths("diff", "--name-only", "HEAD")
+	if err != nil {
+		return nil, err
+	}
+	untracked, err := g.listPaths("ls-files", "--others", "--exclude-standard")
+	return append(changed, untracked...), err
+}
+
+func (g *GitRepo) RevertFiles(paths []string) error {
+	untracked, err := g.listPaths("ls-files", "--others", "--exclude-standard")
+	if err != nil {
+		return err
+	}
+	tracked, err := removeNewFiles(g.root, paths, untracked)
+	if err != nil || len(tracked) == 0 {
+		return err
+	}
+	return g.runNoOutput(append([]string{"checkout", "HEAD", "--"}, tracked...)...)
+}
+
+func (g *GitRepo) MergeBase(a, b string) (string, error) {
+	return g.run("merge-base", "--end-of-options", a, b)
+}
+
+func (g *GitRepo) CurrentCommit() (string, error) {
+	return g.run("rev-parse", "HEAD")
+}
+
 func (g *GitRepo) FetchPRBranch(remote string, prNumber int) error {
 	// Fetch the PR head ref
 	refspec := fmt.Sprintf("refs/pull/%d/head", prNumber)
@@ -119,13 +145,152 @@ func (g *GitRepo) CreateAndSwitchBranch(prNumber int, commitOID string) error {
 	return g.runNoOutput("switch", "-C", branchName, commitOID)
 }
 
-func (g *GitRepo) Commit(message string) error {
+// PRWorktreePath returns where craft get --worktree puts the worktree for a
+// PR: next to the main worktree, named after it with a -pr-N suffix.
+func (g *GitRepo) PRWorktreePath(prNumber int) (string, error) {
+	// Use the main worktree even when run from another one, so worktrees
+	// don't nest names like repo-pr-1-pr-2
+	commonDir, err := g.run("rev-parse", "--path-format=absolute", "--git-common-dir")
+	if err != nil {
+		return "", err
+	}
+	main := filepath.Dir(commonDir)
+	return filepath.Join(filepath.Dir(main), fmt.Sprintf("%s-pr-%d", filepath.Base(main), prNumber)), nil
+}
+
+// AddPRWorktree creates a worktree at path with branch pr-N reset to
+// commitOID, and returns it. If path is already a worktree, the branch is
+// reset there instead.
+func (g *GitRepo) AddPRWorktree(path string, prNumber int, commitOID string) (*GitRepo, error) {
+	wt := &GitRepo{root: path}
+	if _, err := os.Stat(filepath.Join(path, ".git")); err == nil {
+		return wt, wt.CreateAndSwitchBranch(prNumber, commitOID)
+	}
+	branchName := fmt.Sprintf("pr-%d", prNumber)
+	if err := g.runNoOutput("worktree", "add", "-B", branchName, path, commitOID); err != nil {
+		return nil, err
+	}
+	return wt, nil
+}
+
+// ResolveLocalRange resolves a git revision range for craft local, "A..B" or
+// "A" (meaning A..HEAD), to the commit IDs of the merge base of A and B, as
+// a PR's base would be, and of B.
+func (g *GitRepo) ResolveLocalRange(revRange string) (base, head string, err error) {
+	from, to, ok := strings.Cut(revRange, "..")
+	if !ok || to == "" {
+		to = "HEAD"
+	}
+	if strings.HasPrefix(to, ".") {
+		return "", "", fmt.Errorf("invalid range %q: use A..B", revRange)
+	}
+	if head, err = g.run("rev-parse", "--verify", "--end-of-options", to+"^{commit}"); err != nil {
+		return "", "", err
+	}
+	if base, err = g.run("merge-base", "--end-of-options", from, head); err != nil {
+		return "", "", err
+	}
+	return base, head, nil
+}
+
+// LocalCommits returns the commits in base..head, oldest first.
+func (g *GitRepo) LocalCommits(base, head string) ([]Commit, error) {
+	out, err := g.run("log", "--reverse", "--format=%H%x00%an%x00%cI%x00%s", base+".."+head, "--")
+	if err != nil || out == "" {
+		return nil, err
+	}
+	var commits []Commit
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.SplitN(line, "\x00", 4)
+		if len(fields) != 4 {
+			continue
+		}
+		at, _ := time.Parse(time.RFC3339, fields[2])
+		commits = append(commits, Commit{OID: fields[0], Author: fields[1], Headline: fields[3], CommittedAt: at.UTC()})
+	}
+	return commits, nil
+}
+
+// LogEntry is a commit with its whole message.
+type LogEntry struct {
+	OID     string
+	Message string
+}
+
+// Log returns the commits in base..HEAD, oldest first.
+func (g *GitRepo) Log(base string) ([]LogEntry, error) {
+	out, err := g.runRaw("log", "--reverse", "--format=%H%x00%B%x1e", base+"..HEAD", "--")
+	if err != nil {
+		return nil, err
+	}
+	var entries []LogEntry
+	for _, record := range strings.Split(out, "\x1e") {
+		oid, msg, ok := strings.Cut(strings.TrimLeft(record, "\n"), "\x00")
+		if ok {
+			entries = append(entries, LogEntry{OID: oid, Message: strings.TrimSpace(msg)})
+		}
+	}
+	return entries, nil
+}
+
+// IsAncestor reports whether commit is HEAD or an ancestor of it.
+func (g *GitRepo) IsAncestor(commit string) bool {
+	_, err := g.run("merge-base", "--is-ancestor", commit, "HEAD")
+	return err == nil
+}
+
+// Rebase rewrites the commits since base with git rebase -i, following todo
+// (lines like "pick <oid>") instead of asking for it to be edited. If a
+// commit doesn't apply, the rebase stops as git rebase -i would.
+func (g *GitRepo) Rebase(base string, todo []string) error {
+	f, err := os.CreateTemp("", "craft-rebase-todo-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	_, err = f.WriteString(strings.Join(todo, "\n") + "\n")
+	if err := errors.Join(err, f.Close()); err != nil {
+		return err
+	}
+	cmd := exec.Command("git", "rebase", "-i", base)
+	cmd.Dir = g.root
+	// git runs the editor with the shell, with the todo file's path appended
+	quoted := "'" + strings.ReplaceAll(filepath.ToSlash(f.Name()), "'", `'\''`) + "'"
+	cmd.Env = append(os.Environ(), "GIT_SEQUENCE_EDITOR=cp "+quoted)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+func (g *GitRepo) Commit(message string, opts CommitOptions) error {
 	// Stage all changes
 	if err := g.runNoOutput("add", "-A"); err != nil {
 		return err
 	}
 	// Commit (allow empty in case nothing changed)
-	return g.runNoOutput("commit", "--allow-empty", "-m", message)
+	args := append([]string{"commit", "--allow-empty", "-m", message}, gitCommitFlags(opts)...)
+	cmd := exec.Command("git", args...)
+	cmd.Dir = g.root
+	// gpg's pinentry and ssh-agent confirmations can need the terminal
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+// gitCommitFlags returns the git commit flags for opts. Without any, git
+// signs if commit.gpgsign says to, and runs the hooks.
+func gitCommitFlags(opts CommitOptions) []string {
+	var flags []string
+	if opts.Sign {
+		flags = append(flags, "--gpg-sign")
+	} else if opts.NoSign {
+		flags = append(flags, "--no-gpg-sign")
+	}
+	if opts.NoVerify {
+		flags = append(flags, "--no-verify")
+	}
+	return flags
 }
 
 func (g *GitRepo) GetRemoteURL(remote string) (string, error) {
@@ -140,15 +305,46 @@ func (g *GitRepo) GetConfigValue(key string) (string, error) {
 	return g.run("config", "--get", key)
 }
 
+func (g *GitRepo) SetConfigValue(key, value string) error {
+	_, err := g.run("config", key, value)
+	return err
+}
+
 func (g *GitRepo) GetModifiedFiles(commit string) ([]string, error) {
-	out, err := g.run("diff", "--name-only", commit, "HEAD")
+	return g.listPaths("diff", "--name-only", commit, "HEAD")
+}
+
+func (g *GitRepo) GetChangedFiles(commit string) ([]string, error) {
+	return g.listPaths("diff", "--name-only", commit)
+}
+
+func (g *GitRepo) GetRenamedFiles(commit string) (map[string]string, error) {
+	// With -z, each rename is "R<score>", old path, new path as separate fields
+	fields, err := g.listPaths("diff", "--name-status", "-M", "--diff-filter=R", commit, "HEAD")
 	if err != nil {
 		return nil, err
 	}
-	if out == "" {
-		return nil, nil
+	renames := make(map[string]string)
+	for i := 0; i+2 < len(fields); i += 3 {
+		renames[fields[i+1]] = fields[i+2]
 	}
-	return strings.Split(out, "\n"), nil
+	return renames, nil
+}
+
+// listPaths runs a git command that lists paths, with -z so that unusual
+// names (non-ASCII, quotes, newlines) come back unquoted.
+func (g *GitRepo) listPaths(args ...string) ([]string, error) {
+	out, err := g.runRaw(append(args, "-z")...)
+	if err != nil {
+		return nil, err
+	}
+	var paths []string
+	for _, path := range strings.Split(out, "\x00") {
+		if path != "" {
+			paths = append(paths, path)
+		}
+	}
+	return paths, nil
 }
 
 func (g *GitRepo) GetFileDiff(commit, path string) (string, error) {
@@ -159,15 +355,34 @@ func (g *GitRepo) GetFileAtCommit(commit, path string) (string, error) {
 	return g.run("show", commit+":"+path)
 }
 
+// gitlinkMode is the ls-files mode of a submodule entry.
+const gitlinkMode = "160000"
+
+// ListFiles returns tracked files, leaving out submodules. With git config
+// submodule.recurse=true, files inside submodules are listed instead.
 func (g *GitRepo) ListFiles() ([]string, error) {
-	out, err := g.run("ls-files")
+	args := []string{"ls-files", "--stage"}
+	if recurse, _ := g.run("config", "--type=bool", "--get", "submodule.recurse"); recurse == "true" {
+		args = append(args, "--recurse-submodules")
+	}
+	entries, err := g.listPaths(args...)
 	if err != nil {
 		return nil, err
 	}
-	if out == "" {
-		return nil, nil
+	// Entries are "<mode> <object> <stage>\t<path>"; conflicted files have
+	// one per stage
+	var files []string
+	for _, entry := range entries {
+		info, path, ok := strings.Cut(entry, "\t")
+		if !ok || strings.HasPrefix(info, gitlinkMode+" ") {
+			continue
+		}
+		if n := len(files); n > 0 && files[n-1] == path {
+			continue
+		}
+		files = append(files, path)
 	}
-	return strings.Split(out, "\n"), nil
+	return files, nil
 }
 
 // JJRepo implements VCS for jj repositories.
@@ -236,6 +451,23 @@ func (j *JJRepo) HasUncommittedChanges() (bool, error) {
 	return false, nil
 }
 
+func (j *JJRepo) GetUncommittedFiles() ([]string, error) {
+	return nil, nil
+}
+
+func (j *JJRepo) RevertFiles(paths []string) error {
+	// Nothing is uncommitted in jj
+	return nil
+}
+
+func (j *JJRepo) MergeBase(a, b string) (string, error) {
+	return j.run("log", "-r", fmt.Sprintf("fork_point(%s | %s)", a, b), "--no-graph", "--limit", "1", "-T", "commit_id")
+}
+
+func (j *JJRepo) CurrentCommit() (string, error) {
+	return j.run("log", "-r", "@-", "--no-graph", "--limit", "1", "-T", "commit_id")
+}
+
 func (j *JJRepo) FetchPRBranch(remote string, prNumber int) error {
 	refspec := fmt.Sprintf("refs/pull/%d/head:pr-%d", prNumber, prNumber)
 	if err := j.runGitNoOutput("fetch", "--force", remote, refspec); err != nil {
@@ -290,22 +522,30 @@ func (j *JJRepo) CreateAndSwitchBranch(prNumber int, commitOID string) error {
 	}
 
 	// Create a new change at the commit
-	if err := j.runNoOutput("new", "-m", "craft: pending review", commitOID); err != nil {
+	if err := j.runNoOutput("new", "-m", craftCommitMessage("craft: pending review", "", prNumber), commitOID); err != nil {
 		return err
 	}
 
 	// Clean up old craft changes from previous get/send cycles
 	abandonRevset := fmt.Sprintf(
-		`bookmarks("%s"):: & mutable() & mine() & description(glob:"craft:*") ~ ::@`,
-		bookmarkName)
+		`bookmarks("%s"):: & mutable() & mine() & (description(glob:"craft:*") | description(substring:"%s: true")) ~ ::@`,
+		bookmarkName, craftGeneratedTrailer)
 	j.run("abandon", "-r", abandonRevset) // ignore errors - revset might match nothing
 
 	return nil
 }
 
-func (j *JJRepo) Commit(message string) error {
-	// In jj, we describe the current change and then create a new one
-	if err := j.runNoOutput("describe", "-m", message); err != nil {
+func (j *JJRepo) Commit(message string, opts CommitOptions) error {
+	// In jj, we describe the current change and then create a new one.
+	// Changes are signed as jj's signing.behavior says, unless overridden.
+	// jj has no commit hooks, so NoVerify doesn't matter.
+	args := []string{"describe", "-m", message}
+	if opts.Sign {
+		args = append(args, "--config", "signing.behavior=force")
+	} else if opts.NoSign {
+		args = append(args, "--config", "signing.behavior=drop")
+	}
+	if err := j.runNoOutput(args...); err != nil {
 		return err
 	}
 	// Create a new empty change on top
@@ -332,31 +572,96 @@ func (j *JJRepo) GetConfigValue(key string) (string, error) {
 	return j.runGit("config", "--get", key)
 }
 
+func (j *JJRepo) SetConfigValue(key, value string) error {
+	_, err := j.run("config", "set", "--repo", key, value)
+	return err
+}
+
 func (j *JJRepo) GetModifiedFiles(commit string) ([]string, error) {
 	out, err := j.run("diff", "--summary", "--from", commit, "--to", "@")
 	if err != nil {
 		return nil, err
 	}
-	if out == "" {
-		return nil, nil
+	return parseJJSummary(out), nil
+}
+
+// jjSummaryEntry is one line of jj diff --summary output.
+type jjSummaryEntry struct {
+	Status  string // M, A, D, R or C
+	OldPath string // Same as Path unless renamed or copied
+	Path    string
+}
+
+// parseJJSummaryEntries parses jj diff --summary output, which has lines like
+// "M path", with renames and copies written "R dir/{old => new}".
+func parseJJSummaryEntries(out string) []jjSummaryEntry {
+	var entries []jjSummaryEntry
+	for _, line := range strings.Split(out, "\n") {
+		status, path, ok := strings.Cut(line, " ")
+		if !ok || path == "" {
+			continue
+		}
+		entry := jjSummaryEntry{Status: status, OldPath: path, Path: path}
+		if open := strings.Index(path, "{"); open >= 0 {
+			if end := strings.LastIndex(path, "}"); end > open {
+				if oldName, newName, ok := strings.Cut(path[open+1:end], " => "); ok {
+					// "{ => sub}/x" or "{sub => }/x" leaves a doubled or leading slash
+					clean := func(p string) string {
+						return strings.TrimPrefix(strings.ReplaceAll(p, "//", "/"), "/")
+					}
+					entry.OldPath = clean(path[:open] + oldName + path[end+1:])
+					entry.Path = clean(path[:open] + newName + path[end+1:])
+				}
+			}
+		} else if oldName, newName, ok := strings.Cut(path, " => "); ok {
+			entry.OldPath, entry.Path = oldName, newName
+		}
+		entries = append(entries, entry)
 	}
-	// jj diff --summary format: "M path" or "A path" etc.
+	return entries
+}
+
+// parseJJSummary returns the paths in jj diff --summary output. Renamed and
+// copied files are listed under their new path.
+func parseJJSummary(out string) []string {
 	var files []string
-	for _, line := range strings.Split(out, "\n") {
-		parts := strings.SplitN(line, " ", 2)
-		if len(parts) == 2 {
-			files = append(files, parts[1])
+	for _, entry := range parseJJSummaryEntries(out) {
+		files = append(files, entry.Path)
+	}
+	return files
+}
+
+// jjFileset returns a jj fileset matching exactly path (relative to the
+// repository root), so names like "a(b).go" aren't parsed as expressions.
+func jjFileset(path string) string {
+	return "root-file:" + strconv.Quote(path)
+}
+
+func (j *JJRepo) GetRenamedFiles(commit string) (map[string]string, error) {
+	out, err := j.run("diff", "--summary", "--from", commit, "--to", "@")
+	if err != nil {
+		return nil, err
+	}
+	renames := make(map[string]string)
+	for _, entry := range parseJJSummaryEntries(out) {
+		if entry.Status == "R" {
+			renames[entry.OldPath] = entry.Path
 		}
 	}
-	return files, nil
+	return renames, nil
+}
+
+func (j *JJRepo) GetChangedFiles(commit string) ([]string, error) {
+	// @ is the working copy, so this already includes uncommitted changes
+	return j.GetModifiedFiles(commit)
 }
 
 func (j *JJRepo) GetFileDiff(commit, path string) (string, error) {
-	return j.run("diff", "--git", "--context", "0", "-w", "--from", commit, "--to", "@", path)
+	return j.run("diff", "--git", "--context", "0", "-w", "--from", commit, "--to", "@", jjFileset(path))
 }
 
 func (j *JJRepo) GetFileAtCommit(commit, path string) (string, error) {
-	return j.run("file", "show", "-r", commit, path)
+	return j.run("file", "show", "-r", commit, jjFileset(path))
 }
 
 func (j *JJRepo) ListFiles() ([]string, error) {
@@ -370,6 +675,308 @@ func (j *JJRepo) ListFiles() ([]string, error) {
 	return strings.Split(out, "\n"), nil
 }
 
+// SaplingRepo implements VCS for Sapling (sl) repositories cloned from git.
+type SaplingRepo struct {
+	root string
+}
+
+func (s *SaplingRepo) Name() string { return "sl" }
+func (s *SaplingRepo) Root() string { return s.root }
+
+// command returns an sl command run at the root. HGPLAIN turns off aliases,
+// localized messages and cwd-relative paths, so output is stable.
+func (s *SaplingRepo) command(args ...string) *exec.Cmd {
+	cmd := exec.Command("sl", args...)
+	cmd.Dir = s.root
+	cmd.Env = append(os.Environ(), "HGPLAIN=1")
+	return cmd
+}
+
+func (s *SaplingRepo) run(args ...string) (string, error) {
+	out, err := s.command(args...).Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			return "", fmt.Errorf("sl %s: %s", strings.Join(args, " "), string(exitErr.Stderr))
+		}
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+func (s *SaplingRepo) runNoOutput(args ...string) error {
+	cmd := s.command(args...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+// listPaths runs an sl command that lists paths, with -0 so that unusual
+// names come back as is.
+func (s *SaplingRepo) listPaths(args ...string) ([]string, error) {
+	out, err := s.run(append(args, "-0")...)
+	if err != nil {
+		return nil, err
+	}
+	var paths []string
+	for _, path := range strings.Split(out, "\x00") {
+		if path != "" {
+			paths = append(paths, path)
+		}
+	}
+	return paths, nil
+}
+
+func (s *SaplingRepo) HasUncommittedChanges() (bool, error) {
+	out, err := s.run("status")
+	if err != nil {
+		return false, err
+	}
+	return out != "", nil
+}
+
+func (s *SaplingRepo) GetUncommittedFiles() ([]string, error) {
+	return s.listPaths("status", "--modified", "--added", "--removed", "--deleted", "--unknown", "--no-status")
+}
+
+func (s *SaplingRepo) RevertFiles(paths []string) error {
+	unknown, err := s.listPaths("status", "--unknown", "--no-status")
+	if err != nil {
+		return err
+	}
+	tracked, err := removeNewFiles(s.root, paths, unknown)
+	if err != nil || len(tracked) == 0 {
+		return err
+	}
+	args := []string{"revert", "--no-backup", "--"}
+	for _, path := range tracked {
+		args = append(args, slPath(path))
+	}
+	return s.runNoOutput(args...)
+}
+
+func (s *SaplingRepo) MergeBase(a, b string) (string, error) {
+	return s.run("log", "-r", fmt.Sprintf("ancestor(%s, %s)", a, b), "-T", "{node}")
+}
+
+func (s *SaplingRepo) CurrentCommit() (string, error) {
+	return s.run("log", "-r", ".", "-T", "{node}")
+}
+
+func (s *SaplingRepo) FetchPRBranch(remote string, prNumber int) error {
+	// sl pull can only name remote branches, not refs/pull/*, but it can pull
+	// a commit by hash. Look the hash up with git, which Sapling's git
+	// support needs anyway.
+	url, err := s.GetRemoteURL(remote)
+	if err != nil {
+		return err
+	}
+	ref := fmt.Sprintf("refs/pull/%d/head", prNumber)
+	cmd := exec.Command("git", "ls-remote", url, ref)
+	out, err := cmd.Output()
+	if err != nil {
+		return fmt.Errorf("git ls-remote %s %s: %w", url, ref, err)
+	}
+	hash, _, _ := strings.Cut(strings.TrimSpace(string(out)), "\t")
+	if hash == "" {
+		return fmt.Errorf("%s not found on %s", ref, remote)
+	}
+	return s.runNoOutput("pull", url, "--rev", hash)
+}
+
+func (s *SaplingRepo) CreateAndSwitchBranch(prNumber int, commitOID string) error {
+	bookmarkName := fmt.Sprintf("pr-%d", prNumber)
+	// --force moves the bookmark if it already exists
+	if err := s.runNoOutput("bookmark", "--force", "--rev", commitOID, bookmarkName); err != nil {
+		return err
+	}
+	// Going to a bookmark by name also activates it
+	return s.runNoOutput("goto", "--clean", bookmarkName)
+}
+
+func (s *SaplingRepo) Commit(message string, opts CommitOptions) error {
+	// Sapling signs when gpg.key is set, and can't without one. Its hooks
+	// are all in [hooks], named freely, so NoVerify can't skip them.
+	if opts.Sign {
+		return fmt.Errorf("signing craft's commits isn't supported with Sapling; set gpg.key in Sapling's config")
+	}
+	// Add new files and forget deleted ones, like git add -A. sl commit
+	// fails when there's nothing to commit, unlike git commit --allow-empty.
+	changed, err := s.HasUncommittedChanges()
+	if err != nil || !changed {
+		return err
+	}
+	args := []string{"commit", "--addremove", "-m", message}
+	if opts.NoSign {
+		args = append(args, "--config", "gpg.key=")
+	}
+	return s.runNoOutput(args...)
+}
+
+func (s *SaplingRepo) GetRemoteURL(remote string) (string, error) {
+	url, err := s.run("paths", remote)
+	if err != nil && remote == "origin" {
+		// Sapling calls the clone source "default"
+		return s.run("paths", "default")
+	}
+	return url, err
+}
+
+func (s *SaplingRepo) GetCurrentBranch() (string, error) {
+	return s.run("log", "-r", ".", "-T", "{activebookmark}")
+}
+
+func (s *SaplingRepo) GetConfigValue(key string) (string, error) {
+	return s.run("config", key)
+}
+
+func (s *SaplingRepo) SetConfigValue(key, value string) error {
+	_, err := s.run("config", "--local", key, value)
+	return err
+}
+
+func (s *SaplingRepo) GetModifiedFiles(commit string) ([]string, error) {
+	return s.listPaths("status", "--modified", "--added", "--removed", "--no-status", "--rev", commit, "--rev", ".")
+}
+
+func (s *SaplingRepo) GetChangedFiles(commit string) ([]string, error) {
+	// Deleted files not yet removed with sl rm show up as missing (--deleted)
+	return s.listPaths("status", "--modified", "--added", "--removed", "--deleted", "--no-status", "--rev", commit)
+}
+
+func (s *SaplingRepo) GetRenamedFiles(commit string) (map[string]string, error) {
+	out, err := s.run("status", "--added", "--removed", "--copies", "--rev", commit, "--rev", ".")
+	if err != nil {
+		return nil, err
+	}
+	return parseSaplingCopies(out), nil
+}
+
+// parseSaplingCopies returns the renames in sl status --copies output, where
+// an added file's copy source follows it on an indented line. A copy is a
+// rename if the source was removed.
+func parseSaplingCopies(out string) map[string]string {
+	copies := make(map[string]string)
+	removed := make(map[string]bool)
+	var added string
+	for _, line := range strings.Split(out, "\n") {
+		switch {
+		case strings.HasPrefix(line, "A "):
+			added = line[2:]
+		case strings.HasPrefix(line, "  ") && added != "":
+			copies[line[2:]] = added
+			added = ""
+		case strings.HasPrefix(line, "R "):
+			removed[line[2:]] = true
+			added = ""
+		default:
+			added = ""
+		}
+	}
+	renames := make(map[string]string)
+	for from, to := range copies {
+		if removed[from] {
+			renames[from] = to
+		}
+	}
+	return renames
+}
+
+// slPath returns a pattern matching exactly path (relative to the repository
+// root), so names with glob characters or a "kind:" prefix aren't special.
+func slPath(path string) string {
+	return "path:" + path
+}
+
+func (s *SaplingRepo) GetFileDiff(commit, path string) (string, error) {
+	return s.run("diff", "--git", "--unified", "0", "--ignore-all-space", "--rev", commit, "--rev", ".", slPath(path))
+}
+
+func (s *SaplingRepo) GetFileAtCommit(commit, path string) (string, error) {
+	return s.run("cat", "--rev", commit, slPath(path))
+}
+
+func (s *SaplingRepo) ListFiles() ([]string, error) {
+	return s.listPaths("files")
+}
+
+// retargetRenamedThreads moves threads on files that have since been renamed
+// to the new path, so they aren't written into a file that no longer exists.
+// Renames are detected between each thread's original commit and the current
+// one. Threads whose original commit isn't available locally keep their path.
+func retargetRenamedThreads(vcs VCS, pr *PullRequest) error {
+	renamesByCommit := make(map[string]map[string]string)
+	var errs []error
+	for i := range pr.ReviewThreads {
+		thread := &pr.ReviewThreads[i]
+		if thread.OriginalCommitOID == "" {
+			continue
+		}
+		if _, err := os.Lstat(filepath.Join(vcs.Root(), thread.Path)); err == nil {
+			continue
+		}
+		renames, ok := renamesByCommit[thread.OriginalCommitOID]
+		if !ok {
+			var err error
+			renames, err = vcs.GetRenamedFiles(thread.OriginalCommitOID)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("finding renames since %s: %w", thread.OriginalCommitOID, err))
+			}
+			renamesByCommit[thread.OriginalCommitOID] = renames
+		}
+		if newPath, ok := renames[thread.Path]; ok {
+			thread.Path = newPath
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// removeNewFiles deletes the paths that are in untracked, for RevertFiles,
+// and returns the others.
+func removeNewFiles(root string, paths, untracked []string) ([]string, error) {
+	var tracked []string
+	for _, path := range paths {
+		if !slices.Contains(untracked, path) {
+			tracked = append(tracked, path)
+		} else if err := os.Remove(filepath.Join(root, path)); err != nil {
+			return nil, err
+		}
+	}
+	return tracked, nil
+}
+
+// hasNonCraftChanges reports whether there are uncommitted changes other
+// than to craft comments and craft's own files, comparing each changed file
+// with its committed version with the craft comments cleared from both.
+func hasNonCraftChanges(vcs VCS) (bool, error) {
+	files, err := vcs.GetUncommittedFiles()
+	if err != nil || len(files) == 0 {
+		return false, err
+	}
+	head, err := vcs.CurrentCommit()
+	if err != nil {
+		return false, err
+	}
+	for _, path := range files {
+		if craft.IsReviewFile(path) {
+			continue
+		}
+		current, err := os.ReadFile(filepath.Join(vcs.Root(), path))
+		if err != nil {
+			return true, nil // deleted, or not a file
+		}
+		committed, err := vcs.GetFileAtCommit(head, path)
+		if err != nil {
+			return true, nil // new
+		}
+		a, _ := clearCraftContent(string(current), path)
+		b, _ := clearCraftContent(committed, path)
+		// GetFileAtCommit trims, so whitespace at the ends doesn't count
+		if strings.TrimSpace(a) != strings.TrimSpace(b) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
 
 // prNumberFromBranch returns the PR number from the current pr-N branch.
 func prNumberFromBranch(vcs VCS) (int, error) {
@@ -386,3 +993,42 @@ func prNumberFromBranch(vcs VCS) (int, error) {
 	}
 	return n, nil
 }
+
+// Trailers of the commits craft makes, so they can be told from the
+// reviewer's own commits without going by the subject.
+const (
+	craftGeneratedTrailer = "Craft-Generated"
+	craftPRTrailer        = "Craft-PR"
+)
+
+// craftCommitMessage returns the message of a commit craft makes: the
+// subject, the body if any, and the craft trailers, with Craft-PR if
+// prNumber isn't 0.
+func craftCommitMessage(subject, body string, prNumber int) string {
+	msg := subject + "\n\n"
+	if body != "" {
+		msg += body + "\n\n"
+	}
+	msg += craftGeneratedTrailer + ": true"
+	if prNumber != 0 {
+		msg += fmt.Sprintf("\n%s: %d", craftPRTrailer, prNumber)
+	}
+	return msg
+}
+
+// isCraftCommitMessage reports whether a commit message is of a commit craft
+// made: one with the Craft-Generated trailer, or, from before there were
+// trailers, a "craft: " subject.
+func isCraftCommitMessage(msg string) bool {
+	if strings.HasPrefix(msg, "craft: ") {
+		return true
+	}
+	paragraphs := strings.Split(strings.TrimSpace(msg), "\n\n")
+	for _, line := range strings.Split(paragraphs[len(paragraphs)-1], "\n") {
+		key, value, ok := strings.Cut(line, ":")
+		if ok && strings.EqualFold(key, craftGeneratedTrailer) && strings.TrimSpace(value) == "true" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,726 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/dnr/craft/pkg/craft"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestGitRepo creates a git repo in a temp dir with one commit of files.
+func newTestGitRepo(t *testing.T, files map[string]string) *GitRepo {
+	t.Helper()
+	dir := t.TempDir()
+	git := func(args ...string) {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		out, err := cmd.CombinedOutput()
+		require.NoError(t, err, "git %v: %s", args, out)
+	}
+	git("init", "-q")
+	git("config", "user.name", "test")
+	git("config", "user.email", "test@example.com")
+	for name, content := range files {
+		require.NoError(t, os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0644))
+	}
+	git("add", "-A")
+	git("commit", "-q", "-m", "initial")
+	return &GitRepo{root: dir}
+}
+
+func TestDetectVCSFromSubdirectory(t *testing.T) {
+	t.Run("git", func(t *testing.T) {
+		repo := newTestGitRepo(t, map[string]string{"a/b/c.go": "package b\n"})
+		vcs, err := DetectVCS(filepath.Join(repo.root, "a", "b"))
+		require.NoError(t, err)
+		assert.Equal(t, "git", vcs.Name())
+		assert.Equal(t, repo.root, vcs.Root())
+
+		// Paths are relative to the root, not the starting directory
+		files, err := vcs.ListFiles()
+		require.NoError(t, err)
+		assert.Equal(t, []string{"a/b/c.go"}, files)
+	})
+
+	t.Run("colocated jj", func(t *testing.T) {
+		repo := newTestGitRepo(t, map[string]string{"a/b/c.go": "package b\n"})
+		require.NoError(t, os.Mkdir(filepath.Join(repo.root, ".jj"), 0755))
+		vcs, err := DetectVCS(filepath.Join(repo.root, "a", "b"))
+		require.NoError(t, err)
+		assert.Equal(t, "jj", vcs.Name())
+		assert.Equal(t, repo.root, vcs.Root())
+	})
+
+	t.Run("sapling", func(t *testing.T) {
+		for _, marker := range []string{".sl", filepath.Join(".git", "sl")} {
+			root := t.TempDir()
+			require.NoError(t, os.MkdirAll(filepath.Join(root, marker), 0755))
+			require.NoError(t, os.MkdirAll(filepath.Join(root, "a", "b"), 0755))
+			vcs, err := DetectVCS(filepath.Join(root, "a", "b"))
+			require.NoError(t, err)
+			assert.Equal(t, "sl", vcs.Name(), marker)
+			assert.Equal(t, root, vcs.Root(), marker)
+		}
+	})
+
+	t.Run("nearest repo wins", func(t *testing.T) {
+		outer := t.TempDir()
+		require.NoError(t, os.Mkdir(filepath.Join(outer, ".jj"), 0755))
+		inner := filepath.Join(outer, "vendor", "lib")
+		require.NoError(t, os.MkdirAll(filepath.Join(inner, ".git"), 0755))
+		vcs, err := DetectVCS(filepath.Join(inner))
+		require.NoError(t, err)
+		assert.Equal(t, "git", vcs.Name())
+		assert.Equal(t, inner, vcs.Root())
+	})
+
+	t.Run("relative dir", func(t *testing.T) {
+		repo := newTestGitRepo(t, map[string]string{"a/c.go": "package a\n"})
+		t.Chdir(filepath.Join(repo.root, "a"))
+		vcs, err := DetectVCS(".")
+		require.NoError(t, err)
+		assert.Equal(t, repo.root, vcs.Root())
+	})
+}
+
+func TestGitChangedFiles(t *testing.T) {
+	repo := newTestGitRepo(t, map[string]string{
+		"main.go":     "package main\n",
+		"café.go":     "package main\n",
+		"keep/one.go": "package keep\n",
+	})
+	base, err := repo.run("rev-parse", "HEAD")
+	require.NoError(t, err)
+
+	require.NoError(t, os.WriteFile(filepath.Join(repo.root, "café.go"), []byte("package main\n\nvar x = 1\n"), 0644))
+	_, err = repo.run("commit", "-q", "-a", "-m", "edit")
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(repo.root, "main.go"), []byte("package main // edited\n"), 0644))
+
+	// Non-ASCII names come back unquoted
+	modified, err := repo.GetModifiedFiles(base)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"café.go"}, modified)
+
+	// GetChangedFiles includes the working tree
+	changed, err := repo.GetChangedFiles(base)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"café.go", "main.go"}, changed)
+
+	files, err := repo.ListFiles()
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"café.go", "keep/one.go", "main.go"}, files)
+
+	diff, err := repo.GetFileDiff(base, "café.go")
+	require.NoError(t, err)
+	assert.Contains(t, diff, "+var x = 1")
+
+	content, err := repo.GetFileAtCommit(base, "café.go")
+	require.NoError(t, err)
+	assert.Equal(t, "package main", content)
+}
+
+func TestParseJJSummary(t *testing.T) {
+	out := "M main.go\n" +
+		"A new file.go\n" +
+		"D gone.go\n" +
+		"R old.go => renamed.go\n" +
+		"R pkg/{a => b}/x.go\n" +
+		"C pkg/{x.go => y.go}\n" +
+		"R {pkg => }/moved.go\n" +
+		"R { => sub}/nested.go\n"
+	assert.Equal(t, []string{
+		"main.go",
+		"new file.go",
+		"gone.go",
+		"renamed.go",
+		"pkg/b/x.go",
+		"pkg/y.go",
+		"moved.go",
+		"sub/nested.go",
+	}, parseJJSummary(out))
+	assert.Nil(t, parseJJSummary(""))
+}
+
+func TestParseJJSummaryEntries(t *testing.T) {
+	out := "M main.go\n" +
+		"R old.go => renamed.go\n" +
+		"R pkg/{a => b}/x.go\n" +
+		"C pkg/{x.go => y.go}\n" +
+		"R {pkg => }/moved.go\n"
+	assert.Equal(t, []jjSummaryEntry{
+		{Status: "M", OldPath: "main.go", Path: "main.go"},
+		{Status: "R", OldPath: "old.go", Path: "renamed.go"},
+		{Status: "R", OldPath: "pkg/a/x.go", Path: "pkg/b/x.go"},
+		{Status: "C", OldPath: "pkg/x.go", Path: "pkg/y.go"},
+		{Status: "R", OldPath: "pkg/moved.go", Path: "moved.go"},
+	}, parseJJSummaryEntries(out))
+}
+
+func TestParseSaplingCopies(t *testing.T) {
+	out := "A copied.go\n" +
+		"  orig.go\n" +
+		"A new.go\n" +
+		"A renamed.go\n" +
+		"  old.go\n" +
+		"R old.go\n" +
+		"R gone.go"
+	assert.Equal(t, map[string]string{"old.go": "renamed.go"}, parseSaplingCopies(out))
+}
+
+func TestJJFileset(t *testing.T) {
+	assert.Equal(t, `root-file:"main.go"`, jjFileset("main.go"))
+	assert.Equal(t, `root-file:"a (b) \"c\".go"`, jjFileset(`a (b) "c".go`))
+}
+
+func TestJJChangedFiles(t *testing.T) {
+	if _, err := exec.LookPath("jj"); err != nil {
+		t.Skip("jj not installed")
+	}
+	repo := newTestGitRepo(t, map[string]string{"a (1).go": "package a\n", "b.go": "package b\n"})
+	cmd := exec.Command("jj", "git", "init", "--colocate")
+	cmd.Dir = repo.root
+	out, err := cmd.CombinedOutput()
+	require.NoError(t, err, "%s", out)
+	jj := &JJRepo{root: repo.root}
+
+	require.NoError(t, os.WriteFile(filepath.Join(repo.root, "a (1).go"), []byte("package a\n\nvar x = 1\n"), 0644))
+	require.NoError(t, os.Rename(filepath.Join(repo.root, "b.go"), filepath.Join(repo.root, "c.go")))
+
+	changed, err := jj.GetChangedFiles("@-")
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"a (1).go", "c.go"}, changed)
+
+	diff, err := jj.GetFileDiff("@-", "a (1).go")
+	require.NoError(t, err)
+	assert.Contains(t, diff, "+var x = 1")
+
+	content, err := jj.GetFileAtCommit("@-", "a (1).go")
+	require.NoError(t, err)
+	assert.Equal(t, "package a", content)
+}
+
+func TestSaplingChangedFiles(t *testing.T) {
+	if _, err := exec.LookPath("sl"); err != nil {
+		t.Skip("sl not installed")
+	}
+	root := t.TempDir()
+	sl := &SaplingRepo{root: root}
+	_, err := sl.run("init", "--git", root)
+	require.NoError(t, err)
+	_, err = sl.run("config", "--local", "ui.username", "test <test@example.com>")
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(root, "a [1].go"), []byte("package a\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "b.go"), []byte("package b\n"), 0644))
+	require.NoError(t, sl.Commit("initial", CommitOptions{}))
+	base, err := sl.run("log", "-r", ".", "-T", "{node}")
+	require.NoError(t, err)
+
+	files, err := sl.ListFiles()
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"a [1].go", "b.go"}, files)
+
+	require.NoError(t, os.WriteFile(filepath.Join(root, "a [1].go"), []byte("package a\n\nvar x = 1\n"), 0644))
+	require.NoError(t, sl.Commit("edit", CommitOptions{}))
+	require.NoError(t, os.Remove(filepath.Join(root, "b.go")))
+
+	modified, err := sl.GetModifiedFiles(base)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a [1].go"}, modified)
+
+	changed, err := sl.GetChangedFiles(base)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"a [1].go", "b.go"}, changed)
+
+	diff, err := sl.GetFileDiff(base, "a [1].go")
+	require.NoError(t, err)
+	assert.Contains(t, diff, "+var x = 1")
+
+	content, err := sl.GetFileAtCommit(base, "a [1].go")
+	require.NoError(t, err)
+	assert.Equal(t, "package a", content)
+}
+
+func TestGitPRWorktree(t *testing.T) {
+	repo := newTestGitRepo(t, map[string]string{"main.go": "package main\n"})
+	head, err := repo.run("rev-parse", "HEAD")
+	require.NoError(t, err)
+
+	path, err := repo.PRWorktreePath(5)
+	require.NoError(t, err)
+	assert.Equal(t, repo.root+"-pr-5", path)
+
+	wt, err := repo.AddPRWorktree(path, 5, head)
+	require.NoError(t, err)
+	branch, err := wt.GetCurrentBranch()
+	require.NoError(t, err)
+	assert.Equal(t, "pr-5", branch)
+	branch, err = repo.GetCurrentBranch()
+	require.NoError(t, err)
+	assert.NotEqual(t, "pr-5", branch, "main checkout should be left alone")
+
+	// Paths are based on the main worktree even from another one
+	path2, err := wt.PRWorktreePath(6)
+	require.NoError(t, err)
+	assert.Equal(t, repo.root+"-pr-6", path2)
+
+	// Again resets the branch in the existing worktree
+	require.NoError(t, os.WriteFile(filepath.Join(path, "main.go"), []byte("package main // edited\n"), 0644))
+	require.NoError(t, wt.Commit("edit", CommitOptions{}))
+	wt, err = repo.AddPRWorktree(path, 5, head)
+	require.NoError(t, err)
+	got, err := wt.run("rev-parse", "HEAD")
+	require.NoError(t, err)
+	assert.Equal(t, head, got)
+}
+
+func TestGitLocalRange(t *testing.T) {
+	repo := newTestGitRepo(t, map[string]string{"main.go": "package main\n"})
+	base, err := repo.run("rev-parse", "HEAD")
+	require.NoError(t, err)
+	_, err = repo.run("checkout", "-q", "-b", "feature")
+	require.NoError(t, err)
+	for _, msg := range []string{"one", "two"} {
+		require.NoError(t, os.WriteFile(filepath.Join(repo.root, "main.go"), []byte("package main // "+msg+"\n"), 0644))
+		require.NoError(t, repo.Commit(msg, CommitOptions{}))
+	}
+	head, err := repo.run("rev-parse", "HEAD")
+	require.NoError(t, err)
+
+	// A alone means A..HEAD, and the base is the merge base
+	for _, r := range []string{base, base + "..", base + "..HEAD", "HEAD~2..feature"} {
+		gotBase, gotHead, err := repo.ResolveLocalRange(r)
+		require.NoError(t, err, r)
+		assert.Equal(t, base, gotBase, r)
+		assert.Equal(t, head, gotHead, r)
+	}
+	_, _, err = repo.ResolveLocalRange("nope..HEAD")
+	assert.Error(t, err)
+	_, _, err = repo.ResolveLocalRange("HEAD...main")
+	assert.Error(t, err)
+
+	commits, err := repo.LocalCommits(base, head)
+	require.NoError(t, err)
+	require.Len(t, commits, 2)
+	assert.Equal(t, "one", commits[0].Headline)
+	assert.Equal(t, "two", commits[1].Headline)
+	assert.Equal(t, head, commits[1].OID)
+	assert.Equal(t, "test", commits[1].Author)
+	assert.False(t, commits[1].HasLogin)
+	assert.False(t, commits[1].CommittedAt.IsZero())
+}
+
+func TestGitListFilesSubmodules(t *testing.T) {
+	sub := newTestGitRepo(t, map[string]string{"lib.go": "package lib\n"})
+	repo := newTestGitRepo(t, map[string]string{"main.go": "package main\n"})
+	_, err := repo.run("-c", "protocol.file.allow=always", "submodule", "add", "-q", sub.root, "sub")
+	require.NoError(t, err)
+	_, err = repo.run("commit", "-q", "-m", "add submodule")
+	require.NoError(t, err)
+
+	files, err := repo.ListFiles()
+	require.NoError(t, err)
+	assert.Equal(t, []string{".gitmodules", "main.go"}, files)
+
+	_, err = repo.run("config", "submodule.recurse", "true")
+	require.NoError(t, err)
+	files, err = repo.ListFiles()
+	require.NoError(t, err)
+	assert.Equal(t, []string{".gitmodules", "main.go", "sub/lib.go"}, files)
+
+	// Threads on the submodule itself are skipped; ones inside it work
+	pr := &PullRequest{
+		Number:     1,
+		HeadRefOID: "abc123",
+		ReviewThreads: []ReviewThread{
+			{Path: "sub", Line: 1, DiffSide: DiffSideRight, Comments: []ReviewComment{{ID: "PRRC_a", Body: "bump"}}},
+			{Path: "sub/lib.go", Line: 1, DiffSide: DiffSideRight, Comments: []ReviewComment{{ID: "PRRC_b", Body: "inside"}}},
+		},
+	}
+	opts := craft.SerializeOptions{FS: craft.DirFS(repo.root), VCS: repo}
+	require.NoError(t, craft.Serialize(pr, opts))
+	pr2, err := craft.Deserialize(opts)
+	require.NoError(t, err)
+	require.Len(t, pr2.ReviewThreads, 1)
+	assert.Equal(t, "sub/lib.go", pr2.ReviewThreads[0].Path)
+}
+
+func TestRetargetRenamedThreads(t *testing.T) {
+	repo := newTestGitRepo(t, map[string]string{
+		"old.go":  "package main\n\nfunc f() {}\n",
+		"keep.go": "package main\n",
+	})
+	original, err := repo.run("rev-parse", "HEAD")
+	require.NoError(t, err)
+	_, err = repo.run("mv", "old.go", "new.go")
+	require.NoError(t, err)
+	_, err = repo.run("commit", "-q", "-m", "rename")
+	require.NoError(t, err)
+
+	renames, err := repo.GetRenamedFiles(original)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"old.go": "new.go"}, renames)
+
+	pr := &PullRequest{ReviewThreads: []ReviewThread{
+		{Path: "old.go", OriginalCommitOID: original},
+		{Path: "keep.go", OriginalCommitOID: original},
+		{Path: "old.go"}, // no original commit known
+	}}
+	require.NoError(t, retargetRenamedThreads(repo, pr))
+	assert.Equal(t, "new.go", pr.ReviewThreads[0].Path)
+	assert.Equal(t, "keep.go", pr.ReviewThreads[1].Path)
+	assert.Equal(t, "old.go", pr.ReviewThreads[2].Path)
+
+	// A commit that isn't available locally is reported, and the path kept
+	pr = &PullRequest{ReviewThreads: []ReviewThread{
+		{Path: "old.go", OriginalCommitOID: "0123456789abcdef0123456789abcdef01234567"},
+	}}
+	assert.Error(t, retargetRenamedThreads(repo, pr))
+	assert.Equal(t, "old.go", pr.ReviewThreads[0].Path)
+}
+
+func TestCraftCommitMessage(t *testing.T) {
+	msg := craftCommitMessage("craft: PR #12 state", "Fix the thing", 12)
+	assert.Equal(t, "craft: PR #12 state\n\nFix the thing\n\nCraft-Generated: true\nCraft-PR: 12", msg)
+	assert.Equal(t, "craft: clear review comments\n\nCraft-Generated: true", craftCommitMessage("craft: clear review comments", "", 0))
+
+	assert.True(t, isCraftCommitMessage(msg))
+	assert.True(t, isCraftCommitMessage("Reword\n\nCraft-Generated: true\n"))
+	assert.True(t, isCraftCommitMessage("craft: sent review on PR #3")) // before trailers
+	assert.False(t, isCraftCommitMessage("Fix craft: the parser"))
+	assert.False(t, isCraftCommitMessage("Mention Craft-Generated: true\n\nin the body"))
+
+	repo := newTestGitRepo(t, map[string]string{"a.txt": "a\n"})
+	require.NoError(t, repo.Commit(msg, CommitOptions{}))
+	body, err := repo.run("log", "-1", "--format=%(trailers:key=Craft-PR,valueonly)")
+	require.NoError(t, err)
+	assert.Equal(t, "12", body)
+}
+
+func TestHasNonCraftChanges(t *testing.T) {
+	repo := newTestGitRepo(t, map[string]string{"main.go": "package main\n\nfunc main() {}\n"})
+	write := func(name, content string) {
+		require.NoError(t, os.WriteFile(filepath.Join(repo.root, name), []byte(content), 0644))
+	}
+	changed, err := hasNonCraftChanges(repo)
+	require.NoError(t, err)
+	assert.False(t, changed)
+
+	write("main.go", "package main\n\nfunc main() {}\n// ╓───── @alice ─ sum 12345678\n// ║ Hmm\n")
+	write(craft.PRStateFile, "state\n")
+	changed, err = hasNonCraftChanges(repo)
+	require.NoError(t, err)
+	assert.False(t, changed, "only craft comments and files")
+
+	write("main.go", "package main\n\nfunc main() { println() }\n// ╓───── @alice ─ sum 12345678\n// ║ Hmm\n")
+	changed, err = hasNonCraftChanges(repo)
+	require.NoError(t, err)
+	assert.True(t, changed)
+
+	write("main.go", "package main\n\nfunc main() {}\n")
+	write("new.go", "package main\n")
+	changed, err = hasNonCraftChanges(repo)
+	require.NoError(t, err)
+	assert.True(t, changed, "new file")
+}
+
+func TestGitRevertFiles(t *testing.T) {
+	repo := newTestGitRepo(t, map[string]string{"main.go": "package main\n"})
+	require.NoError(t, os.WriteFile(filepath.Join(repo.root, "main.go"), []byte("package main\n// ╓───── @alice\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(repo.root, craft.PRStateFile), []byte("state\n"), 0644))
+
+	files, err := repo.GetUncommittedFiles()
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"main.go", craft.PRStateFile}, files)
+	require.NoError(t, repo.RevertFiles(files))
+
+	changed, err := repo.HasUncommittedChanges()
+	require.NoError(t, err)
+	assert.False(t, changed)
+	assert.NoFileExists(t, filepath.Join(repo.root, craft.PRStateFile))
+}
+
+func TestGitCommitSigning(t *testing.T) {
+	repo := newTestGitRepo(t, map[string]string{"a.txt": "a\n"})
+	// A signing program that always fails shows whether git tried to sign
+	for _, kv := range [][2]string{{"commit.gpgsign", "true"}, {"gpg.program", "false"}} {
+		_, err := repo.run("config", kv[0], kv[1])
+		require.NoError(t, err)
+	}
+	assert.Error(t, repo.Commit("signed", CommitOptions{}))
+	require.NoError(t, repo.Commit("unsigned", CommitOptions{NoSign: true}))
+
+	_, err := repo.run("config", "commit.gpgsign", "false")
+	require.NoError(t, err)
+	assert.Error(t, repo.Commit("signed", CommitOptions{Sign: true}))
+}
+
+func TestResolveCommitOptions(t *testing.T) {
+	repo := newTestGitRepo(t, map[string]string{"a.txt": "a\n"})
+	opts, err := resolveCommitOptions(repo, false)
+	require.NoError(t, err)
+	assert.Equal(t, CommitOptions{}, opts)
+
+	require.NoError(t, repo.SetConfigValue("craft.signCommits", "true"))
+	opts, err = resolveCommitOptions(repo, false)
+	require.NoError(t, err)
+	assert.Equal(t, CommitOptions{Sign: true}, opts)
+	opts, err = resolveCommitOptions(repo, true)
+	require.NoError(t, err)
+	assert.Equal(t, CommitOptions{NoSign: true}, opts)
+
+	require.NoError(t, repo.SetConfigValue("craft.signCommits", "maybe"))
+	_, err = resolveCommitOptions(repo, false)
+	assert.Error(t, err)
+}
+
+func TestGitCommitNoVerify(t *testing.T) {
+	repo := newTestGitRepo(t, map[string]string{"a.txt": "a\n"})
+	hook := filepath.Join(repo.root, ".git", "hooks", "pre-commit")
+	require.NoError(t, os.MkdirAll(filepath.Dir(hook), 0755))
+	require.NoError(t, os.WriteFile(hook, []byte("#!/bin/sh\nexit 1\n"), 0755))
+	assert.Error(t, repo.Commit("hooked", CommitOptions{}))
+	require.NoError(t, repo.Commit("unhooked", CommitOptions{NoVerify: true}))
+
+	require.NoError(t, repo.SetConfigValue("craft.commitHooks", "false"))
+	opts, err := resolveCommitOptions(repo, true)
+	require.NoError(t, err)
+	assert.Equal(t, CommitOptions{NoSign: true, NoVerify: true}, opts)
+}
+
+func TestCheckNewThreadsInDiff(t *testing.T) {
+	var original []string
+	for i := 1; i <= 20; i++ {
+		original = append(original, "line "+strings.Repeat("x", i))
+	}
+	repo := newTestGitRepo(t, map[string]string{
+		"main.go": strings.Join(original, "\n") + "\n",
+		"old.go":  "package old\n",
+	})
+	base, err := repo.run("rev-parse", "HEAD")
+	require.NoError(t, err)
+
+	// Change line 10, and add a file
+	changed := append([]string(nil), original...)
+	changed[9] = "changed"
+	require.NoError(t, os.WriteFile(filepath.Join(repo.root, "main.go"), []byte(strings.Join(changed, "\n")+"\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(repo.root, "new.go"), []byte("package new\n\nfunc f() {}\n"), 0644))
+	_, err = repo.run("add", "-A")
+	require.NoError(t, err)
+	_, err = repo.run("commit", "-q", "-m", "change")
+	require.NoError(t, err)
+	head, err := repo.run("rev-parse", "HEAD")
+	require.NoError(t, err)
+
+	pr := &PullRequest{BaseRefOID: base, HeadRefOID: head}
+	intPtr := func(i int) *int { return &i }
+	threads := []NewThreadInfo{
+		{Path: "main.go", Line: 10, Side: DiffSideRight},
+		{Path: "main.go", Line: 7, Side: DiffSideRight},                        // context
+		{Path: "main.go", Line: 13, StartLine: intPtr(8), Side: DiffSideRight}, // whole hunk
+		{Path: "main.go", Line: 10, Side: DiffSideLeft},
+		{Path: "new.go", Line: 3, Side: DiffSideRight},
+		{Path: "old.go", Subject: SubjectTypeFile, Side: DiffSideRight},
+	}
+	require.NoError(t, craft.CheckNewThreadsInDiff(repo, pr, threads))
+
+	threads = []NewThreadInfo{
+		{Path: "main.go", Line: 2, Side: DiffSideRight},
+		{Path: "main.go", Line: 12, StartLine: intPtr(5), Side: DiffSideRight},
+		{Path: "old.go", Line: 1, Side: DiffSideRight},
+	}
+	err = craft.CheckNewThreadsInDiff(repo, pr, threads)
+	var diffErrs craft.DiffErrors
+	require.ErrorAs(t, err, &diffErrs)
+	assert.Equal(t, "3 new thread(s) outside the PR diff:\n"+
+		"main.go:2: line is not in the PR diff\n"+
+		"main.go:12: range from line 5 goes outside the diff hunk (lines 7-13)\n"+
+		"old.go:1: line is not in the PR diff", err.Error())
+
+	// Through CollectNewComments
+	pr.ReviewThreads = []ReviewThread{{
+		Path: "main.go", Line: 1, DiffSide: DiffSideRight, SubjectType: SubjectTypeLine,
+		Comments: []ReviewComment{{IsNew: true, Body: "far away"}},
+	}}
+	_, err = craft.CollectNewComments(pr, craft.CollectOptions{VCS: repo})
+	require.ErrorAs(t, err, &diffErrs)
+	_, err = craft.CollectNewComments(pr, craft.CollectOptions{})
+	require.NoError(t, err)
+}
+
+func TestChangeMarkers(t *testing.T) {
+	repo := newTestGitRepo(t, map[string]string{
+		"main.go": "package main\n\nfunc main() {\n\ta()\n\tb()\n\tc()\n}\n",
+	})
+	base, err := repo.run("rev-parse", "HEAD")
+	require.NoError(t, err)
+
+	// Change a(), delete c(), and add a file
+	require.NoError(t, os.WriteFile(filepath.Join(repo.root, "main.go"), []byte("package main\n\nfunc main() {\n\tx()\n\tb()\n}\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(repo.root, "new.go"), []byte("package main\n"), 0644))
+	_, err = repo.run("add", "-A")
+	require.NoError(t, err)
+	_, err = repo.run("commit", "-q", "-m", "change")
+	require.NoError(t, err)
+
+	pr := &PullRequest{
+		ID:         "PR_test",
+		BaseRefOID: base,
+		ReviewThreads: []ReviewThread{{
+			Path: "main.go", Line: 4, DiffSide: DiffSideRight, SubjectType: SubjectTypeLine,
+			Comments: []ReviewComment{{ID: "PRRC_1", Author: Actor{Login: "bob"}, Body: "Why x?"}},
+		}},
+	}
+	opts := craft.SerializeOptions{FS: craft.DirFS(repo.root), VCS: repo, MarkChanges: true}
+	require.NoError(t, craft.Serialize(pr, opts))
+
+	content, err := os.ReadFile(filepath.Join(repo.root, "main.go"))
+	require.NoError(t, err)
+	lines := strings.Split(string(content), "\n")
+	assert.Equal(t, "\t// ▼ 1 line changed", lines[3])
+	assert.Equal(t, "\tx()", lines[4])
+	assert.Contains(t, lines[5], "╓")
+	assert.Equal(t, "\t// ║ Why x?", lines[6])
+	assert.Equal(t, "\tb()", lines[7])
+	assert.Equal(t, "// ▼ 1 line deleted", lines[8])
+	assert.Equal(t, "}", lines[9])
+
+	// Files without threads are marked too
+	content, err = os.ReadFile(filepath.Join(repo.root, "new.go"))
+	require.NoError(t, err)
+	assert.Equal(t, "// ▼ 1 line changed\npackage main\n", string(content))
+
+	// Markers don't move threads, and serializing again is a no-op
+	got, err := craft.Deserialize(opts)
+	require.NoError(t, err)
+	require.Len(t, got.ReviewThreads, 1)
+	assert.Equal(t, 4, got.ReviewThreads[0].Line)
+	require.NoError(t, craft.Serialize(pr, opts))
+	again, err := os.ReadFile(filepath.Join(repo.root, "main.go"))
+	require.NoError(t, err)
+	assert.Equal(t, strings.Join(lines, "\n"), string(again))
+
+	// ASCII markers are recognized by their text
+	box, _, ok := craft.ParseCraftLine("// |v 3 lines deleted", "//")
+	assert.True(t, ok)
+	assert.Equal(t, craft.BoxChange, box)
+	_, _, ok = craft.ParseCraftLine("// |v something else", "//")
+	assert.False(t, ok)
+}
+
+func TestPlaceOutdatedThreads(t *testing.T) {
+	repo := newTestGitRepo(t, map[string]string{
+		"main.go": "package main\n\nfunc f() {\n\told()\n}\n\nfunc g() {}\n",
+	})
+	original, err := repo.run("rev-parse", "HEAD")
+	require.NoError(t, err)
+	current := "package main\n\nimport \"fmt\"\n\nfunc f() {\n\tfmt.Println(\"new\")\n}\n\nfunc g() {}\n"
+	require.NoError(t, os.WriteFile(filepath.Join(repo.root, "main.go"), []byte(current), 0644))
+	_, err = repo.run("commit", "-q", "-a", "-m", "change")
+	require.NoError(t, err)
+
+	thread := func(id string, originalLine int, commit string) ReviewThread {
+		return ReviewThread{
+			ID:                id,
+			Path:              "main.go",
+			DiffSide:          DiffSideRight,
+			OriginalLine:      originalLine,
+			OriginalCommitOID: commit,
+			IsOutdated:        true,
+			SubjectType:       SubjectTypeLine,
+			Comments: []ReviewComment{{
+				ID:        "PRRC_" + id,
+				Author:    Actor{Login: "alice"},
+				Body:      "about line " + id,
+				CreatedAt: time.Date(2025, 1, 1, 9, 0, 0, 0, time.UTC),
+			}},
+		}
+	}
+	pr := &PullRequest{
+		Number:     1,
+		HeadRefOID: "abcd1234",
+		ReviewThreads: []ReviewThread{
+			thread("four", 4, original),      // old() was replaced
+			thread("seven", 7, original),     // func g() moved down
+			thread("unknown", 4, ""),         // no original commit
+			thread("missing", 4, "0badc0de"), // commit not available
+		},
+	}
+
+	opts := craft.SerializeOptions{FS: craft.DirFS(repo.root), VCS: repo}
+	require.NoError(t, craft.Serialize(pr, opts))
+	data, err := os.ReadFile(filepath.Join(repo.root, "main.go"))
+	require.NoError(t, err)
+	content := string(data)
+
+	lines := strings.Split(content, "\n")
+	assert.Equal(t, "\tfmt.Println(\"new\")", lines[5])
+	assert.Contains(t, lines[6], "outdated ─ approx ─ origline 4 ─")
+	assert.Equal(t, "func g() {}", lines[10])
+	assert.Contains(t, lines[11], "outdated ─ approx ─ origline 7 ─")
+
+	// Threads that can't be placed still go at the end
+	_, outdated, ok := strings.Cut(content, craft.OutdatedCommentsHeader)
+	require.True(t, ok)
+	assert.Contains(t, outdated, "about line unknown")
+	assert.Contains(t, outdated, "about line missing")
+	assert.NotContains(t, outdated, "about line four")
+
+	// Placement survives a round trip, without a VCS
+	pr2, err := craft.Deserialize(opts)
+	require.NoError(t, err)
+	var placed int
+	for _, th := range pr2.ReviewThreads {
+		if th.IsApprox {
+			placed++
+			assert.True(t, th.IsOutdated)
+		}
+	}
+	assert.Equal(t, 2, placed)
+	memfs := fstest.MapFS{"main.go": &fstest.MapFile{Data: []byte(current)}}
+	require.NoError(t, craft.Serialize(pr2, craft.SerializeOptions{FS: memfs}))
+	lines2 := strings.Split(string(memfs["main.go"].Data), "\n")
+	assert.Equal(t, lines[6], lines2[6])
+	assert.Equal(t, lines[11], lines2[11])
+}
+
+func TestPlaceInWorkingCopy(t *testing.T) {
+	repo := newTestGitRepo(t, map[string]string{
+		"main.go": "package main\n\nfunc f() {\n\told()\n}\n\nfunc g() {\n\tkeep()\n}\n",
+	})
+	head, err := repo.run("rev-parse", "HEAD")
+	require.NoError(t, err)
+	// Uncommitted work: an import above, and old() changed
+	current := "package main\n\nimport \"fmt\"\n\nfunc f() {\n\tfmt.Println(\"new\")\n}\n\nfunc g() {\n\tkeep()\n}\n"
+	require.NoError(t, os.WriteFile(filepath.Join(repo.root, "main.go"), []byte(current), 0644))
+
+	startLine := 7
+	pr := &PullRequest{
+		HeadRefOID: head,
+		ReviewThreads: []ReviewThread{
+			{Path: "main.go", Line: 4, DiffSide: DiffSideRight, SubjectType: SubjectTypeLine},
+			{Path: "main.go", Line: 8, StartLine: &startLine, DiffSide: DiffSideRight, SubjectType: SubjectTypeLine},
+			{Path: "main.go", SubjectType: SubjectTypeFile},
+			{Path: "gone.go", Line: 2, DiffSide: DiffSideRight, SubjectType: SubjectTypeLine},
+		},
+	}
+	craft.PlaceInWorkingCopy(craft.SerializeOptions{FS: craft.DirFS(repo.root), VCS: repo}, pr)
+
+	threads := pr.ReviewThreads
+	assert.Equal(t, 6, threads[0].Line, "changed line")
+	assert.True(t, threads[0].IsApprox)
+	assert.Equal(t, 10, threads[1].Line, "unchanged line")
+	require.NotNil(t, threads[1].StartLine)
+	assert.Equal(t, 9, *threads[1].StartLine)
+	assert.False(t, threads[1].IsApprox)
+	assert.Equal(t, 0, threads[2].Line, "file thread")
+	assert.Equal(t, 2, threads[3].Line, "missing file")
+}
@@ -26,7 +26,16 @@ endfunction
 " Check if a line is a craft comment (contains any box char)
 function! craft#IsCraftLine(lnum)
   let l:line = getline(a:lnum)
-  return l:line =~# '[╓╟║]'
+  return l:line =~# '[╓╟║┆╒▼]'
+endfunction
+
+" Whether new threads go above their line or range (git config
+" craft.commentPosition=above, or g:craft_comment_position)
+function! craft#CommentsAbove()
+  if !exists('g:craft_comment_position')
+    let g:craft_comment_position = trim(system('git config craft.commentPosition'))
+  endif
+  return g:craft_comment_position ==# 'above'
 endfunction
 
 " Set up buffer-local comments setting for craft line wrapping.
@@ -67,10 +76,11 @@ function! craft#SetupComments()
   setlocal formatoptions+=crqj
 endfunction
 
-" Find the end of a craft comment chain (last consecutive craft line)
+" Find the end of a craft comment chain (last consecutive craft line, not
+" counting a range start or change marker for the next line)
 function! craft#IsChainEnd(lnum)
   let l:end = a:lnum
-  while craft#IsCraftLine(l:end + 1)
+  while craft#IsCraftLine(l:end + 1) && getline(l:end + 1) !~# '[╒▼]'
     let l:end += 1
   endwhile
   return l:end
@@ -98,6 +108,15 @@ function! craft#Comment() range
     let l:insert_after = craft#IsChainEnd(line('.'))
     let l:indent = craft#GetIndent(line('.'))
     let l:header = l:indent . l:prefix . ' ' . s:box_reply . '───── new'
+  elseif craft#CommentsAbove()
+    " New comment above the current line or first line of selection
+    let l:insert_after = a:firstline - 1
+    let l:indent = craft#GetIndent(a:firstline)
+    let l:header = l:indent . l:prefix . ' ' . s:box_thread . '───── new'
+    if a:firstline != a:lastline
+      let l:header .= ' ─ range ' . (a:firstline - a:lastline)
+    endif
+    let l:header .= ' ─ above'
   elseif a:firstline != a:lastline
     " Visual range: add range comment after last line of selection
     let l:insert_after = a:lastline
@@ -129,12 +148,16 @@ function! craft#Suggestion() range
 
   " Build the comment
   let l:result = []
+  let l:header = l:indent . l:prefix . ' ' . s:box_thread . '───── new'
   if a:firstline != a:lastline
-    let l:range_size = a:firstline - a:lastline
-    call add(l:result, l:indent . l:prefix . ' ' . s:box_thread . '───── new ─ range ' . l:range_size)
-  else
-    call add(l:result, l:indent . l:prefix . ' ' . s:box_thread . '───── new')
+    let l:header .= ' ─ range ' . (a:firstline - a:lastline)
+  endif
+  let l:insert_after = a:lastline
+  if craft#CommentsAbove()
+    let l:header .= ' ─ above'
+    let l:insert_after = a:firstline - 1
   endif
+  call add(l:result, l:header)
   call add(l:result, l:indent . l:prefix . ' ' . s:box_body . ' ```suggestion')
 
   " Add the copied lines (preserving their exact content)
@@ -145,11 +168,11 @@ function! craft#Suggestion() range
 
   call add(l:result, l:indent . l:prefix . ' ' . s:box_body . ' ```')
 
-  " Insert after the last line of the selection
-  call append(a:lastline, l:result)
+  " Insert after the last line of the selection (or above the first)
+  call append(l:insert_after, l:result)
 
   " Position cursor at start of first copied line content
-  let l:cursor_line = a:lastline + l:first_content_line
+  let l:cursor_line = l:insert_after + l:first_content_line
   let l:body_prefix = l:indent . l:prefix . ' ' . s:box_body . ' '
   call cursor(l:cursor_line, len(l:body_prefix) + 1)
   call craft#SetupComments()
@@ -10,6 +10,7 @@ let g:loaded_craft = 1
 command! -nargs=? Cbase call craft#SetBase(<q-args>)
 command! Ctool call craft#Difftool()
 command! Csplit call craft#Diffsplit()
+command! Cthreads cexpr system('craft threads')
 
 " Mappings (users can override in their vimrc)
 if !exists('g:craft_no_mappings')